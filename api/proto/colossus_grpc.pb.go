@@ -0,0 +1,412 @@
+// gRPC API of the Colossus server, served alongside the HTTP API with
+// `colossus serve --grpc-port PORT`.
+//
+// Regenerate the Go code after changing this file with:
+//   make proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: colossus.proto
+
+package colossuspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Colossus_GenerateText_FullMethodName   = "/colossus.v1.Colossus/GenerateText"
+	Colossus_StreamText_FullMethodName     = "/colossus.v1.Colossus/StreamText"
+	Colossus_ChatCompletion_FullMethodName = "/colossus.v1.Colossus/ChatCompletion"
+	Colossus_StreamChat_FullMethodName     = "/colossus.v1.Colossus/StreamChat"
+	Colossus_ListModels_FullMethodName     = "/colossus.v1.Colossus/ListModels"
+	Colossus_LoadModel_FullMethodName      = "/colossus.v1.Colossus/LoadModel"
+	Colossus_UnloadModel_FullMethodName    = "/colossus.v1.Colossus/UnloadModel"
+)
+
+// ColossusClient is the client API for Colossus service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ColossusClient interface {
+	// GenerateText generates a response to a prompt
+	GenerateText(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error)
+	// StreamText generates a response to a prompt, streamed as it is
+	// generated. The last message has done set and carries the metrics.
+	StreamText(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (Colossus_StreamTextClient, error)
+	// ChatCompletion generates the next message of a conversation
+	ChatCompletion(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	// StreamChat generates the next message of a conversation, streamed as it
+	// is generated
+	StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (Colossus_StreamChatClient, error)
+	// ListModels lists the installed models
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	// LoadModel loads an installed model, so that the first request to it does
+	// not wait for it to load
+	LoadModel(ctx context.Context, in *LoadModelRequest, opts ...grpc.CallOption) (*LoadModelResponse, error)
+	// UnloadModel frees a loaded model
+	UnloadModel(ctx context.Context, in *UnloadModelRequest, opts ...grpc.CallOption) (*UnloadModelResponse, error)
+}
+
+type colossusClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewColossusClient(cc grpc.ClientConnInterface) ColossusClient {
+	return &colossusClient{cc}
+}
+
+func (c *colossusClient) GenerateText(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error) {
+	out := new(GenerateResponse)
+	err := c.cc.Invoke(ctx, Colossus_GenerateText_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *colossusClient) StreamText(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (Colossus_StreamTextClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Colossus_ServiceDesc.Streams[0], Colossus_StreamText_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &colossusStreamTextClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Colossus_StreamTextClient interface {
+	Recv() (*GenerateResponse, error)
+	grpc.ClientStream
+}
+
+type colossusStreamTextClient struct {
+	grpc.ClientStream
+}
+
+func (x *colossusStreamTextClient) Recv() (*GenerateResponse, error) {
+	m := new(GenerateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *colossusClient) ChatCompletion(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, Colossus_ChatCompletion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *colossusClient) StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (Colossus_StreamChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Colossus_ServiceDesc.Streams[1], Colossus_StreamChat_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &colossusStreamChatClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Colossus_StreamChatClient interface {
+	Recv() (*ChatResponse, error)
+	grpc.ClientStream
+}
+
+type colossusStreamChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *colossusStreamChatClient) Recv() (*ChatResponse, error) {
+	m := new(ChatResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *colossusClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, Colossus_ListModels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *colossusClient) LoadModel(ctx context.Context, in *LoadModelRequest, opts ...grpc.CallOption) (*LoadModelResponse, error) {
+	out := new(LoadModelResponse)
+	err := c.cc.Invoke(ctx, Colossus_LoadModel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *colossusClient) UnloadModel(ctx context.Context, in *UnloadModelRequest, opts ...grpc.CallOption) (*UnloadModelResponse, error) {
+	out := new(UnloadModelResponse)
+	err := c.cc.Invoke(ctx, Colossus_UnloadModel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ColossusServer is the server API for Colossus service.
+// All implementations must embed UnimplementedColossusServer
+// for forward compatibility
+type ColossusServer interface {
+	// GenerateText generates a response to a prompt
+	GenerateText(context.Context, *GenerateRequest) (*GenerateResponse, error)
+	// StreamText generates a response to a prompt, streamed as it is
+	// generated. The last message has done set and carries the metrics.
+	StreamText(*GenerateRequest, Colossus_StreamTextServer) error
+	// ChatCompletion generates the next message of a conversation
+	ChatCompletion(context.Context, *ChatRequest) (*ChatResponse, error)
+	// StreamChat generates the next message of a conversation, streamed as it
+	// is generated
+	StreamChat(*ChatRequest, Colossus_StreamChatServer) error
+	// ListModels lists the installed models
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	// LoadModel loads an installed model, so that the first request to it does
+	// not wait for it to load
+	LoadModel(context.Context, *LoadModelRequest) (*LoadModelResponse, error)
+	// UnloadModel frees a loaded model
+	UnloadModel(context.Context, *UnloadModelRequest) (*UnloadModelResponse, error)
+	mustEmbedUnimplementedColossusServer()
+}
+
+// UnimplementedColossusServer must be embedded to have forward compatible implementations.
+type UnimplementedColossusServer struct {
+}
+
+func (UnimplementedColossusServer) GenerateText(context.Context, *GenerateRequest) (*GenerateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateText not implemented")
+}
+func (UnimplementedColossusServer) StreamText(*GenerateRequest, Colossus_StreamTextServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamText not implemented")
+}
+func (UnimplementedColossusServer) ChatCompletion(context.Context, *ChatRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChatCompletion not implemented")
+}
+func (UnimplementedColossusServer) StreamChat(*ChatRequest, Colossus_StreamChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamChat not implemented")
+}
+func (UnimplementedColossusServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedColossusServer) LoadModel(context.Context, *LoadModelRequest) (*LoadModelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoadModel not implemented")
+}
+func (UnimplementedColossusServer) UnloadModel(context.Context, *UnloadModelRequest) (*UnloadModelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnloadModel not implemented")
+}
+func (UnimplementedColossusServer) mustEmbedUnimplementedColossusServer() {}
+
+// UnsafeColossusServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ColossusServer will
+// result in compilation errors.
+type UnsafeColossusServer interface {
+	mustEmbedUnimplementedColossusServer()
+}
+
+func RegisterColossusServer(s grpc.ServiceRegistrar, srv ColossusServer) {
+	s.RegisterService(&Colossus_ServiceDesc, srv)
+}
+
+func _Colossus_GenerateText_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ColossusServer).GenerateText(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Colossus_GenerateText_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ColossusServer).GenerateText(ctx, req.(*GenerateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Colossus_StreamText_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GenerateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ColossusServer).StreamText(m, &colossusStreamTextServer{stream})
+}
+
+type Colossus_StreamTextServer interface {
+	Send(*GenerateResponse) error
+	grpc.ServerStream
+}
+
+type colossusStreamTextServer struct {
+	grpc.ServerStream
+}
+
+func (x *colossusStreamTextServer) Send(m *GenerateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Colossus_ChatCompletion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ColossusServer).ChatCompletion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Colossus_ChatCompletion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ColossusServer).ChatCompletion(ctx, req.(*ChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Colossus_StreamChat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ColossusServer).StreamChat(m, &colossusStreamChatServer{stream})
+}
+
+type Colossus_StreamChatServer interface {
+	Send(*ChatResponse) error
+	grpc.ServerStream
+}
+
+type colossusStreamChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *colossusStreamChatServer) Send(m *ChatResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Colossus_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ColossusServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Colossus_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ColossusServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Colossus_LoadModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ColossusServer).LoadModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Colossus_LoadModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ColossusServer).LoadModel(ctx, req.(*LoadModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Colossus_UnloadModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnloadModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ColossusServer).UnloadModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Colossus_UnloadModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ColossusServer).UnloadModel(ctx, req.(*UnloadModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Colossus_ServiceDesc is the grpc.ServiceDesc for Colossus service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Colossus_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "colossus.v1.Colossus",
+	HandlerType: (*ColossusServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GenerateText",
+			Handler:    _Colossus_GenerateText_Handler,
+		},
+		{
+			MethodName: "ChatCompletion",
+			Handler:    _Colossus_ChatCompletion_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _Colossus_ListModels_Handler,
+		},
+		{
+			MethodName: "LoadModel",
+			Handler:    _Colossus_LoadModel_Handler,
+		},
+		{
+			MethodName: "UnloadModel",
+			Handler:    _Colossus_UnloadModel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamText",
+			Handler:       _Colossus_StreamText_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamChat",
+			Handler:       _Colossus_StreamChat_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "colossus.proto",
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/spf13/cobra"
+)
+
+var adapterCmd = &cobra.Command{
+	Use:   "adapter",
+	Short: "Manage LoRA adapters",
+	Long:  "Commands for managing the LoRA adapters applied to a model when it is loaded. Adapters are applied in order of their file names.",
+}
+
+var addAdapterCmd = &cobra.Command{
+	Use:   "add [MODEL_NAME] [ADAPTER_PATH]",
+	Short: "Install a LoRA adapter for a model",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runAddAdapter,
+}
+
+var removeAdapterCmd = &cobra.Command{
+	Use:   "remove [MODEL_NAME] [ADAPTER_NAME]",
+	Short: "Remove a LoRA adapter from a model",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRemoveAdapter,
+}
+
+var listAdaptersCmd = &cobra.Command{
+	Use:   "list [MODEL_NAME]",
+	Short: "List the LoRA adapters installed for a model",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runListAdapters,
+}
+
+func init() {
+	modelsCmd.AddCommand(adapterCmd)
+	adapterCmd.AddCommand(addAdapterCmd)
+	adapterCmd.AddCommand(removeAdapterCmd)
+	adapterCmd.AddCommand(listAdaptersCmd)
+}
+
+func runAddAdapter(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	path, err := manager.AddAdapter(args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to add adapter: %w", err)
+	}
+
+	fmt.Printf("Added adapter '%s' to model '%s'\n", filepath.Base(path), args[0])
+	fmt.Println("It is applied the next time the model is loaded")
+	return nil
+}
+
+func runRemoveAdapter(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	if err := manager.RemoveAdapter(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to remove adapter: %w", err)
+	}
+
+	fmt.Printf("Successfully removed adapter '%s' from model '%s'\n", args[1], args[0])
+	return nil
+}
+
+func runListAdapters(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	adapters, err := manager.ListAdapters(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to list adapters: %w", err)
+	}
+
+	if len(adapters) == 0 {
+		fmt.Printf("No adapters installed for model '%s'\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADAPTER\tSIZE")
+	for _, path := range adapters {
+		size := "-"
+		if info, err := os.Stat(path); err == nil {
+			size = formatSize(info.Size())
+		}
+		fmt.Fprintf(w, "%s\t%s\n", filepath.Base(path), size)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/spf13/cobra"
+)
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage model aliases",
+	Long:  "Commands for managing short names that refer to installed models or model files",
+}
+
+var setAliasCmd = &cobra.Command{
+	Use:   "set [ALIAS] [MODEL_NAME_OR_PATH]",
+	Short: "Create or update an alias",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSetAlias,
+}
+
+var removeAliasCmd = &cobra.Command{
+	Use:   "remove [ALIAS]",
+	Short: "Remove an alias",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRemoveAlias,
+}
+
+var listAliasesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List aliases",
+	RunE:  runListAliases,
+}
+
+func init() {
+	modelsCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(setAliasCmd)
+	aliasCmd.AddCommand(removeAliasCmd)
+	aliasCmd.AddCommand(listAliasesCmd)
+}
+
+func runSetAlias(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	if err := manager.AddAlias(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to set alias: %w", err)
+	}
+
+	fmt.Printf("Alias '%s' now refers to '%s'\n", args[0], args[1])
+	return nil
+}
+
+func runRemoveAlias(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	if err := manager.RemoveAlias(args[0]); err != nil {
+		return fmt.Errorf("failed to remove alias: %w", err)
+	}
+
+	fmt.Printf("Successfully removed alias '%s'\n", args[0])
+	return nil
+}
+
+func runListAliases(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	aliases, err := manager.ListAliases()
+	if err != nil {
+		return fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	if len(aliases) == 0 {
+		fmt.Println("No aliases defined")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tMODEL")
+	for _, alias := range sortedKeys(aliases) {
+		fmt.Fprintf(w, "%s\t%s\n", alias, aliases[alias])
+	}
+	return w.Flush()
+}
+
+// sortedKeys returns the keys of a string map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"colossus-cli/internal/api"
+	"colossus-cli/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Describe the server API",
+}
+
+var apiSpecCmd = &cobra.Command{
+	Use:   "spec",
+	Short: "Print the OpenAPI specification of the server API",
+	Long: `Print the OpenAPI 3.0 specification of the routes the server registers
+with the current configuration, e.g. with or without --ollama-compat. A
+running server started with --api-docs serves it at /api/openapi.json and
+Swagger UI at /api/docs.`,
+	Example: `  colossus api spec > openapi.json
+  colossus api spec --output openapi.json`,
+	Args: cobra.NoArgs,
+	RunE: runAPISpec,
+}
+
+func init() {
+	rootCmd.AddCommand(apiCmd)
+	apiCmd.AddCommand(apiSpecCmd)
+
+	apiSpecCmd.Flags().StringP("output", "o", "", "Write the specification to this file instead of stdout")
+}
+
+func runAPISpec(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	data, err := json.MarshalIndent(api.OpenAPISpec(config.Read()), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode specification: %w", err)
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write specification: %w", err)
+	}
+	fmt.Printf("Successfully wrote OpenAPI specification to %s\n", output)
+	return nil
+}
@@ -0,0 +1,473 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"colossus-cli/internal/buildinfo"
+	"colossus-cli/internal/gpu"
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark [MODEL_NAME]",
+	Short: "Measure prompt evaluation and generation speed",
+	Long: `Measure a model's prompt evaluation and generation throughput using a running
+Colossus server. Each iteration evaluates a fixed prompt and generates a fixed
+number of tokens. Results are appended to ~/.colossus/benchmarks.json.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBenchmark,
+}
+
+const (
+	// benchmarkPromptTokens is the length of the benchmark prompt
+	benchmarkPromptTokens = 512
+	// benchmarkGenerateTokens is the number of tokens generated per iteration
+	benchmarkGenerateTokens = 200
+)
+
+// benchmarkText is repeated to build the benchmark prompt
+const benchmarkText = "The quick brown fox jumps over the lazy dog while the curious cat watches from the warm windowsill. "
+
+// benchmarkRun is the measurement of one iteration
+type benchmarkRun struct {
+	PromptTokens       int
+	PromptDuration     time.Duration
+	GeneratedTokens    int
+	GenerationDuration time.Duration
+}
+
+// benchmarkStats summarizes a throughput in tokens per second
+type benchmarkStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  float64 `json:"max"`
+}
+
+// benchmarkSystem describes the hardware a benchmark ran on
+type benchmarkSystem struct {
+	OS         string   `json:"os"`
+	Arch       string   `json:"arch"`
+	CPUs       int      `json:"cpus"`
+	GPU        string   `json:"gpu"`
+	GPUDevices []string `json:"gpu_devices,omitempty"`
+}
+
+// benchmarkResult is the outcome of a benchmark, as printed with --json and
+// saved to the benchmarks file
+type benchmarkResult struct {
+	Model           string          `json:"model"`
+	Timestamp       time.Time       `json:"timestamp"`
+	Version         string          `json:"version,omitempty"`
+	Iterations      int             `json:"iterations"`
+	PromptTokens    int             `json:"prompt_tokens"`
+	GeneratedTokens int             `json:"generated_tokens"`
+	PromptEval      benchmarkStats  `json:"prompt_tokens_per_second"`
+	Generation      benchmarkStats  `json:"generation_tokens_per_second"`
+	System          benchmarkSystem `json:"system"`
+}
+
+func init() {
+	rootCmd.AddCommand(benchmarkCmd)
+
+	benchmarkCmd.Flags().IntP("iterations", "n", 3, "Number of iterations")
+	benchmarkCmd.Flags().Bool("json", false, "Output in JSON format")
+	benchmarkCmd.Flags().Int("batch", 0, "Compare the throughput of N prompts sent one at a time with one /api/batch/generate request")
+}
+
+func runBenchmark(cmd *cobra.Command, args []string) error {
+	modelName := args[0]
+	iterations, _ := cmd.Flags().GetInt("iterations")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	if iterations < 1 {
+		return fmt.Errorf("iterations must be at least 1")
+	}
+
+	if batch, _ := cmd.Flags().GetInt("batch"); batch != 0 {
+		if batch < 2 {
+			return fmt.Errorf("batch must be at least 2")
+		}
+		return runBatchBenchmark(modelName, batch, iterations, jsonOutput)
+	}
+
+	// Tokenizing the prompt also loads the model, so loading is not measured
+	prompt, err := benchmarkPrompt(modelName)
+	if err != nil {
+		return err
+	}
+
+	runs := make([]benchmarkRun, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "Running iteration %d/%d...\n", i+1, iterations)
+		}
+
+		run, err := runBenchmarkIteration(modelName, prompt)
+		if err != nil {
+			return fmt.Errorf("iteration %d failed: %w", i+1, err)
+		}
+		runs = append(runs, *run)
+	}
+
+	result := summarizeBenchmark(modelName, runs)
+
+	if err := saveBenchmarkResult(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal results: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printBenchmarkResult(result)
+	return nil
+}
+
+// benchmarkPrompt returns the token IDs of the benchmark prompt. The prompt is
+// sent as context tokens so it is exactly benchmarkPromptTokens long for any
+// tokenizer.
+func benchmarkPrompt(modelName string) ([]int, error) {
+	text := strings.Repeat(benchmarkText, benchmarkPromptTokens/10)
+	tokens, err := tokenizePrompt(modelName, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens.Tokens) < benchmarkPromptTokens {
+		return tokens.Tokens, nil
+	}
+	return tokens.Tokens[:benchmarkPromptTokens], nil
+}
+
+// runBenchmarkIteration streams one generation. The time to the first
+// response is the prompt evaluation time, and the rest is generation time.
+func runBenchmarkIteration(modelName string, prompt []int) (*benchmarkRun, error) {
+	req := types.GenerateRequest{
+		Model:   modelName,
+		Stream:  true,
+		Context: prompt,
+		Options: &types.Options{NumPredict: benchmarkGenerateTokens},
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("http://%s:%d/api/generate", viper.GetString("host"), viper.GetInt("port"))
+	httpReq, err := newAPIRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", string(body))
+	}
+
+	var firstResponse time.Time
+	var text strings.Builder
+	var context []int
+
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var chunk struct {
+			types.GenerateResponse
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, errors.New(chunk.Error)
+		}
+
+		if firstResponse.IsZero() {
+			firstResponse = time.Now()
+		}
+		text.WriteString(chunk.Response)
+
+		if chunk.Done {
+			context = chunk.Context
+			break
+		}
+	}
+	end := time.Now()
+
+	// The final context holds the prompt followed by the generated tokens.
+	// Engines that do not return it get the generated text tokenized instead.
+	generated := len(context) - len(prompt)
+	if len(context) == 0 {
+		tokens, err := tokenizePrompt(modelName, text.String())
+		if err != nil {
+			return nil, err
+		}
+		generated = tokens.Count
+	}
+
+	return &benchmarkRun{
+		PromptTokens:       len(prompt),
+		PromptDuration:     firstResponse.Sub(start),
+		GeneratedTokens:    generated,
+		GenerationDuration: end.Sub(firstResponse),
+	}, nil
+}
+
+// summarizeBenchmark computes the throughput statistics of the runs
+func summarizeBenchmark(modelName string, runs []benchmarkRun) *benchmarkResult {
+	promptRates := make([]float64, len(runs))
+	generationRates := make([]float64, len(runs))
+	generated := 0
+	for i, run := range runs {
+		promptRates[i] = tokensPerSecond(run.PromptTokens, run.PromptDuration)
+		generationRates[i] = tokensPerSecond(run.GeneratedTokens, run.GenerationDuration)
+		generated += run.GeneratedTokens
+	}
+
+	return &benchmarkResult{
+		Model:           modelName,
+		Timestamp:       time.Now(),
+		Version:         buildinfo.Version(),
+		Iterations:      len(runs),
+		PromptTokens:    runs[0].PromptTokens,
+		GeneratedTokens: generated / len(runs),
+		PromptEval:      computeBenchmarkStats(promptRates),
+		Generation:      computeBenchmarkStats(generationRates),
+		System:          benchmarkSystemInfo(),
+	}
+}
+
+// tokensPerSecond returns a throughput, or 0 when nothing was measured
+func tokensPerSecond(tokens int, elapsed time.Duration) float64 {
+	if tokens <= 0 || elapsed <= 0 {
+		return 0
+	}
+	return float64(tokens) / elapsed.Seconds()
+}
+
+// computeBenchmarkStats returns the minimum, mean and maximum of values
+func computeBenchmarkStats(values []float64) benchmarkStats {
+	stats := benchmarkStats{Min: values[0], Max: values[0]}
+	sum := 0.0
+	for _, v := range values {
+		if v < stats.Min {
+			stats.Min = v
+		}
+		if v > stats.Max {
+			stats.Max = v
+		}
+		sum += v
+	}
+	stats.Mean = sum / float64(len(values))
+	return stats
+}
+
+// benchmarkSystemInfo describes the CPU and GPUs of this machine
+func benchmarkSystemInfo() benchmarkSystem {
+	system := benchmarkSystem{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		CPUs: runtime.NumCPU(),
+		GPU:  string(gpu.GPUTypeNone),
+	}
+
+	gpuInfo := gpu.DetectGPUs()
+	if gpuInfo.Available {
+		system.GPU = string(gpuInfo.Type)
+		for _, device := range gpuInfo.Devices {
+			system.GPUDevices = append(system.GPUDevices, device.Name)
+		}
+	}
+	return system
+}
+
+// printBenchmarkResult prints the system configuration and a table of results
+func printBenchmarkResult(result *benchmarkResult) {
+	system := result.System
+	fmt.Printf("Model:    %s\n", result.Model)
+	fmt.Printf("CPU:      %s/%s, %d cores\n", system.OS, system.Arch, system.CPUs)
+	if len(system.GPUDevices) > 0 {
+		fmt.Printf("GPU:      %s (%s)\n", system.GPU, strings.Join(system.GPUDevices, ", "))
+	} else {
+		fmt.Printf("GPU:      %s\n", system.GPU)
+	}
+	fmt.Printf("Workload: %d prompt tokens, %d generated tokens, %d iterations\n\n",
+		result.PromptTokens, result.GeneratedTokens, result.Iterations)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tMIN\tMEAN\tMAX")
+	for _, row := range []struct {
+		name  string
+		stats benchmarkStats
+	}{
+		{"Prompt eval (tokens/s)", result.PromptEval},
+		{"Generation (tokens/s)", result.Generation},
+	} {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\n", row.name, row.stats.Min, row.stats.Mean, row.stats.Max)
+	}
+	w.Flush()
+}
+
+// batchBenchmarkResult compares the throughput of generating the responses to
+// several prompts one request at a time and in a single batch request
+type batchBenchmarkResult struct {
+	Model      string         `json:"model"`
+	Prompts    int            `json:"prompts"`
+	Iterations int            `json:"iterations"`
+	Sequential benchmarkStats `json:"sequential_tokens_per_second"`
+	Batch      benchmarkStats `json:"batch_tokens_per_second"`
+	Speedup    float64        `json:"speedup"`
+}
+
+// runBatchBenchmark measures the generation throughput of n prompts sent as
+// separate requests one after the other, then as one batch request
+func runBatchBenchmark(modelName string, n, iterations int, jsonOutput bool) error {
+	prompts := make([]string, n)
+	for i := range prompts {
+		prompts[i] = fmt.Sprintf("%d. %s", i+1, benchmarkText)
+	}
+	options := &types.Options{NumPredict: benchmarkGenerateTokens}
+
+	sequentialRates := make([]float64, 0, iterations)
+	batchRates := make([]float64, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "Running iteration %d/%d...\n", i+1, iterations)
+		}
+
+		start := time.Now()
+		var responses []*types.GenerateResponse
+		for _, prompt := range prompts {
+			var resp types.GenerateResponse
+			req := types.GenerateRequest{Model: modelName, Prompt: prompt, Options: options}
+			if err := postAPIRequest("/api/generate", req, &resp); err != nil {
+				return fmt.Errorf("iteration %d failed: %w", i+1, err)
+			}
+			responses = append(responses, &resp)
+		}
+		tokens, err := generatedTokens(modelName, responses)
+		if err != nil {
+			return err
+		}
+		sequentialRates = append(sequentialRates, tokensPerSecond(tokens, time.Since(start)))
+
+		start = time.Now()
+		var batch types.BatchGenerateResponse
+		req := types.BatchGenerateRequest{Model: modelName, Prompts: prompts, Options: options}
+		if err := postAPIRequest("/api/batch/generate", req, &batch); err != nil {
+			return fmt.Errorf("iteration %d failed: %w", i+1, err)
+		}
+		elapsed := time.Since(start)
+		if tokens, err = generatedTokens(modelName, batch.Responses); err != nil {
+			return err
+		}
+		batchRates = append(batchRates, tokensPerSecond(tokens, elapsed))
+	}
+
+	result := batchBenchmarkResult{
+		Model:      modelName,
+		Prompts:    n,
+		Iterations: iterations,
+		Sequential: computeBenchmarkStats(sequentialRates),
+		Batch:      computeBenchmarkStats(batchRates),
+	}
+	if result.Sequential.Mean > 0 {
+		result.Speedup = result.Batch.Mean / result.Sequential.Mean
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal results: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Model:    %s\n", result.Model)
+	fmt.Printf("Workload: %d prompts, up to %d generated tokens each, %d iterations\n\n",
+		result.Prompts, benchmarkGenerateTokens, result.Iterations)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODE (tokens/s)\tMIN\tMEAN\tMAX")
+	fmt.Fprintf(w, "Sequential\t%.2f\t%.2f\t%.2f\n", result.Sequential.Min, result.Sequential.Mean, result.Sequential.Max)
+	fmt.Fprintf(w, "Batch\t%.2f\t%.2f\t%.2f\n", result.Batch.Min, result.Batch.Mean, result.Batch.Max)
+	w.Flush()
+	fmt.Printf("\nSpeedup:  %.2fx\n", result.Speedup)
+	return nil
+}
+
+// generatedTokens counts the tokens generated for responses, from their
+// metrics or, for engines that do not report them, by tokenizing the text
+func generatedTokens(modelName string, responses []*types.GenerateResponse) (int, error) {
+	total := 0
+	for _, resp := range responses {
+		if resp.InferenceMetrics != nil && resp.EvalCount > 0 {
+			total += resp.EvalCount
+			continue
+		}
+		tokens, err := tokenizePrompt(modelName, resp.Response)
+		if err != nil {
+			return 0, err
+		}
+		total += tokens.Count
+	}
+	return total, nil
+}
+
+// saveBenchmarkResult appends a result to ~/.colossus/benchmarks.json
+func saveBenchmarkResult(result *benchmarkResult) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to find home directory: %w", err)
+	}
+	path := filepath.Join(home, ".colossus", "benchmarks.json")
+
+	var results []benchmarkResult
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &results); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read benchmark results: %w", err)
+	}
+	results = append(results, *result)
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark results: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save benchmark results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBenchmarkCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	flags := newTestAPIServer(t, "tinyllama")
+
+	output, err := executeCommand(t, append([]string{"benchmark", "tinyllama", "--json", "-n", "2"}, flags...)...)
+	if err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+
+	var result benchmarkResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", output, err)
+	}
+	if result.Model != "tinyllama" || result.Iterations != 2 {
+		t.Errorf("result for %s with %d iterations, want tinyllama with 2", result.Model, result.Iterations)
+	}
+	if result.PromptTokens <= 0 || result.PromptTokens > benchmarkPromptTokens {
+		t.Errorf("prompt tokens = %d, want between 1 and %d", result.PromptTokens, benchmarkPromptTokens)
+	}
+	if result.GeneratedTokens <= 0 {
+		t.Errorf("generated tokens = %d, want some", result.GeneratedTokens)
+	}
+	for name, stats := range map[string]benchmarkStats{"prompt eval": result.PromptEval, "generation": result.Generation} {
+		if stats.Min <= 0 || stats.Min > stats.Mean || stats.Mean > stats.Max {
+			t.Errorf("%s stats = %+v, want 0 < min <= mean <= max", name, stats)
+		}
+	}
+
+	// Each run is appended to the results file
+	if _, err := executeCommand(t, append([]string{"benchmark", "tinyllama", "-n", "1"}, flags...)...); err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".colossus", "benchmarks.json"))
+	if err != nil {
+		t.Fatalf("results not saved: %v", err)
+	}
+	var saved []benchmarkResult
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("invalid results file: %v", err)
+	}
+	if len(saved) != 2 || saved[0].Iterations != 2 || saved[1].Iterations != 1 {
+		t.Errorf("saved %d results, want both runs", len(saved))
+	}
+}
+
+func TestBenchmarkTable(t *testing.T) {
+	flags := newTestAPIServer(t, "tinyllama")
+	t.Setenv("HOME", t.TempDir())
+
+	output, err := executeCommand(t, append([]string{"benchmark", "tinyllama", "-n", "1"}, flags...)...)
+	if err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+	for _, want := range []string{"Model:    tinyllama", "METRIC", "Prompt eval (tokens/s)", "Generation (tokens/s)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestComputeBenchmarkStats(t *testing.T) {
+	got := computeBenchmarkStats([]float64{20, 10, 30})
+	if want := (benchmarkStats{Min: 10, Mean: 20, Max: 30}); got != want {
+		t.Errorf("computeBenchmarkStats() = %+v, want %+v", got, want)
+	}
+}
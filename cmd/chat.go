@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"colossus-cli/internal/chat"
 	"colossus-cli/internal/types"
 
 	"github.com/spf13/cobra"
@@ -25,6 +27,16 @@ var chatCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(chatCmd)
+	
+	chatCmd.Flags().Int64("seed", -1, "Random seed for reproducible responses (-1 for random)")
+	chatCmd.Flags().String("history", "", "Load the conversation from this JSON file and save it after each turn")
+	chatCmd.Flags().Int("max-history", 0, "Maximum number of turns kept in the conversation (0 for no limit)")
+	chatCmd.Flags().String("system", "", "System prompt sent with every message")
+	chatCmd.Flags().String("system-file", "", "Read the system prompt from this file")
+	chatCmd.Flags().String("export", "", "Write the conversation to this file on exit")
+	chatCmd.Flags().String("export-format", chat.FormatMarkdown, "Format of the exported conversation: markdown, json or html")
+	chatCmd.Flags().Int("token-budget", 0, "Tokens the conversation may use, counted in the prompt; the server warns at 80% and refuses messages at 95% (default: the model's context size)")
+	chatCmd.MarkFlagsMutuallyExclusive("system", "system-file")
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
@@ -32,8 +44,77 @@ func runChat(cmd *cobra.Command, args []string) error {
 	host := viper.GetString("host")
 	port := viper.GetInt("port")
 	
+	var options *types.Options
+	if seed, _ := cmd.Flags().GetInt64("seed"); seed != -1 {
+		options = &types.Options{Seed: &seed}
+	}
+	
+	system, err := chatSystemPrompt(cmd)
+	if err != nil {
+		return err
+	}
+	if system != "" {
+		if err := checkSystemPrompt(modelName, system); err != nil {
+			return err
+		}
+	}
+	
+	budget, _ := cmd.Flags().GetInt("token-budget")
+	if budget < 0 {
+		return fmt.Errorf("--token-budget must not be negative")
+	}
+	if budget == 0 {
+		if info, err := loadModelInfo(modelName); err == nil {
+			budget = info.ContextLength
+		}
+	}
+	if budget > 0 {
+		if options == nil {
+			options = &types.Options{}
+		}
+		options.TokenBudget = budget
+	}
+	
+	historyFile, _ := cmd.Flags().GetString("history")
+	maxHistory, _ := cmd.Flags().GetInt("max-history")
+	
+	exportFile, _ := cmd.Flags().GetString("export")
+	exportFormat, _ := cmd.Flags().GetString("export-format")
+	if exportFile != "" {
+		if err := chat.ValidateExportFormat(exportFormat); err != nil {
+			return err
+		}
+	}
+	
+	var conversation []types.Message
+	if historyFile != "" {
+		var err error
+		conversation, err = loadChatHistory(historyFile)
+		if err != nil {
+			return err
+		}
+		if len(conversation) > 0 {
+			fmt.Printf("Loaded %d messages from %s\n", len(conversation), historyFile)
+		}
+	}
+	if system != "" {
+		conversation = append([]types.Message{{Role: "system", Content: system}}, chatTranscript(conversation)...)
+	}
+	
+	// The export covers the whole session; the system prompt and messages
+	// loaded from the history file have no timestamps
+	var exported []chat.TimestampedMessage
+	for _, msg := range conversation {
+		exported = append(exported, chat.TimestampedMessage{Message: msg})
+	}
+	
+	// The prompt counts the tokens of the conversation against the budget
+	prompt := func() {
+		fmt.Print(chatPrompt(modelName, conversation, budget))
+	}
+	
 	fmt.Printf("Starting chat with model '%s' (type '/bye' to exit)\n", modelName)
-	fmt.Print(">>> ")
+	prompt()
 	
 	scanner := bufio.NewScanner(os.Stdin)
 	
@@ -46,67 +127,299 @@ func runChat(cmd *cobra.Command, args []string) error {
 		}
 		
 		if input == "" {
-			fmt.Print(">>> ")
+			prompt()
+			continue
+		}
+		
+		if strings.HasPrefix(input, "/") {
+			if err := handleChatCommand(input, &conversation, historyFile); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			prompt()
 			continue
 		}
 		
-		if err := sendChatMessage(host, port, modelName, input); err != nil {
+		sentAt := time.Now()
+		messages := append(conversation, types.Message{Role: "user", Content: input})
+		reply, tokensRemaining, err := sendChatMessage(host, port, modelName, messages, options)
+		if err != nil {
 			fmt.Printf("Error: %v\n", err)
+			prompt()
+			continue
+		}
+		
+		if tokensRemaining > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d tokens of the %d token budget remain; start a new chat or lower --max-history\n", tokensRemaining, budget)
+		}
+		
+		// Only completed turns become part of the conversation
+		conversation = trimChatHistory(append(messages, types.Message{Role: "assistant", Content: reply}), maxHistory)
+		exported = append(exported,
+			chat.TimestampedMessage{Message: types.Message{Role: "user", Content: input}, Timestamp: sentAt},
+			chat.TimestampedMessage{Message: types.Message{Role: "assistant", Content: reply}, Timestamp: time.Now(), Model: modelName})
+		if historyFile != "" {
+			if err := saveChatHistory(historyFile, conversation); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
 		}
 		
-		fmt.Print(">>> ")
+		prompt()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	
+	if exportFile != "" {
+		if err := chat.ExportConversation(exported, exportFormat, exportFile); err != nil {
+			return fmt.Errorf("failed to export conversation: %w", err)
+		}
+		fmt.Printf("Exported the conversation to %s\n", exportFile)
+	}
+	return nil
+}
+
+// handleChatCommand runs a slash command entered in the chat prompt
+func handleChatCommand(input string, conversation *[]types.Message, historyFile string) error {
+	fields := strings.Fields(input)
+	
+	switch fields[0] {
+	case "/history":
+		if len(chatTranscript(*conversation)) == 0 {
+			fmt.Println("The conversation is empty")
+			return nil
+		}
+		for _, msg := range chatTranscript(*conversation) {
+			fmt.Printf("%s: %s\n", msg.Role, msg.Content)
+		}
+		return nil
+	
+	case "/clear":
+		// The system prompt applies to the new conversation as well
+		*conversation = (*conversation)[:len(*conversation)-len(chatTranscript(*conversation))]
+		if historyFile != "" {
+			if err := saveChatHistory(historyFile, *conversation); err != nil {
+				return err
+			}
+		}
+		fmt.Println("Cleared the conversation")
+		return nil
+	
+	case "/save":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: /save FILE")
+		}
+		if err := os.WriteFile(fields[1], []byte(chatMarkdown(chatTranscript(*conversation))), 0644); err != nil {
+			return fmt.Errorf("failed to save conversation: %w", err)
+		}
+		fmt.Printf("Saved the conversation to %s\n", fields[1])
+		return nil
+	
+	default:
+		return fmt.Errorf("unknown command %s (available: /history, /clear, /save FILE, /bye)", fields[0])
+	}
+}
+
+// loadChatHistory reads a conversation saved by saveChatHistory. A missing
+// file is an empty conversation.
+func loadChatHistory(path string) ([]types.Message, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	
+	var messages []types.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to parse history file %s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// saveChatHistory writes a conversation as a JSON array of messages
+func saveChatHistory(path string, messages []types.Message) error {
+	if messages == nil {
+		messages = []types.Message{}
+	}
+	
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// trimChatHistory keeps the last maxTurns user turns of a conversation, along
+// with the replies to them and the system prompt. Zero keeps every turn.
+func trimChatHistory(messages []types.Message, maxTurns int) []types.Message {
+	if maxTurns <= 0 {
+		return messages
+	}
+	
+	transcript := chatTranscript(messages)
+	// The full slice expression makes append copy rather than overwrite messages
+	system := messages[:len(messages)-len(transcript):len(messages)-len(transcript)]
+	
+	turns := 0
+	for i := len(transcript) - 1; i >= 0; i-- {
+		if transcript[i].Role != "user" {
+			continue
+		}
+		turns++
+		if turns == maxTurns {
+			return append(system, transcript[i:]...)
+		}
+	}
+	return messages
+}
+
+// chatTranscript returns the conversation without its leading system prompt,
+// as shown to the user
+func chatTranscript(messages []types.Message) []types.Message {
+	for len(messages) > 0 && messages[0].Role == "system" {
+		messages = messages[1:]
+	}
+	return messages
+}
+
+// chatSystemPrompt returns the system prompt given by --system or --system-file
+func chatSystemPrompt(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("system-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read system prompt: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
 	}
 	
-	return scanner.Err()
+	system, _ := cmd.Flags().GetString("system")
+	return strings.TrimSpace(system), nil
 }
 
-func sendChatMessage(host string, port int, modelName, message string) error {
+// checkSystemPrompt fails if the system prompt alone does not fit in the
+// model's context, and warns if it takes more than a quarter of it. The check
+// is skipped when the context size or token count is unavailable.
+func checkSystemPrompt(modelName, system string) error {
+	info, err := loadModelInfo(modelName)
+	if err != nil || info.ContextLength == 0 {
+		return nil
+	}
+	
+	tokens, err := tokenizePrompt(modelName, system)
+	if err != nil {
+		return nil
+	}
+	
+	if tokens.Count >= info.ContextLength {
+		return fmt.Errorf("system prompt is %d tokens, which exceeds the model's context size of %d", tokens.Count, info.ContextLength)
+	}
+	if tokens.Count*4 > info.ContextLength {
+		fmt.Fprintf(os.Stderr, "Warning: system prompt is %d tokens, %d%% of the model's %d token context\n",
+			tokens.Count, tokens.Count*100/info.ContextLength, info.ContextLength)
+	}
+	return nil
+}
+
+// chatMarkdown formats a conversation as a markdown transcript
+func chatMarkdown(messages []types.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		role := msg.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", role, msg.Content)
+	}
+	return b.String()
+}
+
+// chatPrompt returns the input prompt, with the tokens of the conversation
+// and the budget when there is one, e.g. ">>> [1234/4096 tokens] ". The
+// count is left out when the server cannot tokenize the conversation.
+func chatPrompt(modelName string, conversation []types.Message, budget int) string {
+	if budget <= 0 {
+		return ">>> "
+	}
+	
+	contents := make([]string, 0, len(conversation))
+	for _, msg := range conversation {
+		contents = append(contents, msg.Content)
+	}
+	tokens, err := tokenizePrompt(modelName, strings.Join(contents, "\n"))
+	if err != nil {
+		return ">>> "
+	}
+	return fmt.Sprintf(">>> [%d/%d tokens] ", tokens.Count, budget)
+}
+
+// sendChatMessage sends the conversation to the server, printing the reply as
+// it is streamed. It returns the full reply, and the tokens remaining of the
+// budget when the server warns that they are running out.
+func sendChatMessage(host string, port int, modelName string, messages []types.Message, options *types.Options) (string, int, error) {
 	url := fmt.Sprintf("http://%s:%d/api/chat", host, port)
 	
 	req := types.ChatRequest{
-		Model: modelName,
-		Messages: []types.Message{
-			{
-				Role:    "user",
-				Content: message,
-			},
-		},
-		Stream: true,
+		Model:    modelName,
+		Messages: messages,
+		Stream:   true,
+		Options:  options,
 	}
 	
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := newAPIRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server error: %s", string(body))
+		return "", 0, fmt.Errorf("server error: %s", string(body))
 	}
 	
 	// Handle streaming response
+	var reply strings.Builder
+	tokensRemaining := 0
 	decoder := json.NewDecoder(resp.Body)
 	for decoder.More() {
-		var chatResp types.ChatResponse
+		var chatResp struct {
+			types.ChatResponse
+			Error string `json:"error"`
+		}
 		if err := decoder.Decode(&chatResp); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
+			return "", 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+		
+		if chatResp.Error != "" {
+			fmt.Println()
+			return "", 0, fmt.Errorf("%s", chatResp.Error)
 		}
 		
 		if chatResp.Message.Content != "" {
 			fmt.Print(chatResp.Message.Content)
+			reply.WriteString(chatResp.Message.Content)
 		}
 		
 		if chatResp.Done {
+			tokensRemaining = chatResp.WarningTokensRemaining
 			break
 		}
 	}
 	
 	fmt.Println() // New line after response
-	return nil
+	return reply.String(), tokensRemaining, nil
 }
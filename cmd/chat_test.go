@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/chat"
+	"colossus-cli/internal/types"
+)
+
+// chatServer mocks /api/chat, replying "reply N" to the Nth request and
+// recording the messages of every request
+func chatServer(t *testing.T) (*[][]types.Message, []string) {
+	t.Helper()
+	var requests [][]types.Message
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req types.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		requests = append(requests, req.Messages)
+
+		reply := "reply " + string(rune('0'+len(requests)))
+		json.NewEncoder(w).Encode(types.ChatResponse{
+			Message: types.Message{Role: "assistant", Content: reply},
+			Done:    true,
+		})
+	})
+	return &requests, flags
+}
+
+// writeChatHistory saves messages as a history file and returns its path
+func writeChatHistory(t *testing.T, messages []types.Message) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := saveChatHistory(path, messages); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestChatCommandPrependsHistory(t *testing.T) {
+	requests, flags := chatServer(t)
+	history := []types.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	}
+	path := writeChatHistory(t, history)
+
+	setStdin(t, "first\nsecond\n/bye\n")
+	if _, err := executeCommand(t, append([]string{"chat", "tinyllama", "--history", path}, flags...)...); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	first := append(history, types.Message{Role: "user", Content: "first"})
+	second := append(append([]types.Message{}, first...),
+		types.Message{Role: "assistant", Content: "reply 1"},
+		types.Message{Role: "user", Content: "second"})
+	if want := [][]types.Message{first, second}; !reflect.DeepEqual(*requests, want) {
+		t.Errorf("requests = %+v, want %+v", *requests, want)
+	}
+
+	saved, err := loadChatHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(second, types.Message{Role: "assistant", Content: "reply 2"})
+	if !reflect.DeepEqual(saved, want) {
+		t.Errorf("saved history = %+v, want %+v", saved, want)
+	}
+}
+
+func TestChatCommandMaxHistory(t *testing.T) {
+	requests, flags := chatServer(t)
+	path := writeChatHistory(t, []types.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	})
+
+	setStdin(t, "first\nsecond\n/bye\n")
+	if _, err := executeCommand(t, append([]string{"chat", "tinyllama", "--history", path, "--max-history", "1"}, flags...)...); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	// Only the last turn is kept between prompts
+	want := []types.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply 1"},
+		{Role: "user", Content: "second"},
+	}
+	if got := (*requests)[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("second request = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadChatHistory(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages, err := loadChatHistory(filepath.Join(dir, "missing.json")); err != nil || messages != nil {
+		t.Errorf("missing file = %v, %v, want an empty conversation", messages, err)
+	}
+	if _, err := loadChatHistory(invalid); err == nil {
+		t.Error("invalid history file accepted")
+	}
+}
+
+func TestTrimChatHistory(t *testing.T) {
+	messages := []types.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "one"},
+		{Role: "assistant", Content: "1"},
+		{Role: "user", Content: "two"},
+		{Role: "assistant", Content: "2"},
+	}
+
+	tests := []struct {
+		name     string
+		maxTurns int
+		want     []types.Message
+	}{
+		{name: "no limit", maxTurns: 0, want: messages},
+		{name: "last turn with the system prompt", maxTurns: 1, want: []types.Message{messages[0], messages[3], messages[4]}},
+		{name: "every turn", maxTurns: 2, want: messages},
+		{name: "more than held", maxTurns: 5, want: messages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimChatHistory(messages, tt.maxTurns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trimChatHistory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatMarkdown(t *testing.T) {
+	got := chatMarkdown([]types.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	})
+	want := "## User\n\nhi\n\n## Assistant\n\nhello\n\n"
+	if got != want {
+		t.Errorf("chatMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestChatCommandExport(t *testing.T) {
+	_, flags := chatServer(t)
+	path := filepath.Join(t.TempDir(), "conversation.json")
+
+	setStdin(t, "first\n/bye\n")
+	output, err := executeCommand(t, append([]string{"chat", "tinyllama", "--system", "Be brief.", "--export", path, "--export-format", "json"}, flags...)...)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if !strings.Contains(output, "Exported the conversation to "+path) {
+		t.Errorf("output = %q, want the export path", output)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []chat.TimestampedMessage
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	var got []types.Message
+	for _, msg := range exported {
+		got = append(got, msg.Message)
+	}
+	want := []types.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply 1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exported %+v, want %+v", got, want)
+	}
+	if !exported[0].Timestamp.IsZero() || exported[1].Timestamp.IsZero() || exported[2].Model != "tinyllama" {
+		t.Errorf("exported %+v, want timestamps on the session's messages and the model on replies", exported)
+	}
+}
+
+func TestChatCommandRejectsUnknownExportFormat(t *testing.T) {
+	_, flags := chatServer(t)
+	setStdin(t, "/bye\n")
+	_, err := executeCommand(t, append([]string{"chat", "tinyllama", "--export", "out.pdf", "--export-format", "pdf"}, flags...)...)
+	if err == nil || !strings.Contains(err.Error(), "unsupported export format") {
+		t.Errorf("error = %v, want unsupported export format", err)
+	}
+}
+
+func TestChatCommandTokenCounter(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	flags := newTestAPIServer(t, "tinyllama")
+
+	setStdin(t, "hello\nwhat is your name\n/bye\n")
+	output, err := executeCommand(t, append([]string{"chat", "tinyllama", "--token-budget", "1000"}, flags...)...)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	// Each prompt counts the tokens of the conversation so far
+	var counts []int
+	for _, match := range regexp.MustCompile(`>>> \[(\d+)/1000 tokens\] `).FindAllStringSubmatch(output, -1) {
+		n, _ := strconv.Atoi(match[1])
+		counts = append(counts, n)
+	}
+	if len(counts) != 3 || counts[0] != 0 || counts[1] <= counts[0] || counts[2] <= counts[1] {
+		t.Errorf("token counts = %v in %q, want 3 increasing from 0", counts, output)
+	}
+}
+
+func TestChatCommandTokenBudgetWarning(t *testing.T) {
+	var budgets []int
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tokenize" {
+			json.NewEncoder(w).Encode(types.TokenizeResponse{Count: 3})
+			return
+		}
+		var req types.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		budgets = append(budgets, req.Options.TokenBudget)
+		json.NewEncoder(w).Encode(types.ChatResponse{
+			Message:                types.Message{Role: "assistant", Content: "reply"},
+			Done:                   true,
+			WarningTokensRemaining: 1,
+		})
+	})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	setStdin(t, "hello\n/bye\n")
+	output, err := executeCommand(t, append([]string{"chat", "tinyllama", "--token-budget", "4"}, flags...)...)
+	w.Close()
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	warnings, _ := io.ReadAll(r)
+
+	if !reflect.DeepEqual(budgets, []int{4}) {
+		t.Errorf("requests with budgets %v, want one with 4", budgets)
+	}
+	if !strings.Contains(output, ">>> [3/4 tokens] ") {
+		t.Errorf("output = %q, want the token counter", output)
+	}
+	if !strings.Contains(string(warnings), "1 tokens of the 4 token budget remain") {
+		t.Errorf("stderr = %q, want a warning of the tokens remaining", warnings)
+	}
+}
+
+func TestChatCommandRejectsNegativeTokenBudget(t *testing.T) {
+	_, flags := chatServer(t)
+	setStdin(t, "/bye\n")
+	_, err := executeCommand(t, append([]string{"chat", "tinyllama", "--token-budget", "-1"}, flags...)...)
+	if err == nil || !strings.Contains(err.Error(), "--token-budget") {
+		t.Errorf("error = %v, want a negative token budget error", err)
+	}
+}
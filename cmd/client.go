@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// newAPIRequest creates a JSON request to the Colossus server, authenticated
+// with the first configured API key if there is one
+func newAPIRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	key, _, _ := strings.Cut(viper.GetString("api_key"), ",")
+	if key = strings.TrimSpace(key); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	return req, nil
+}
+
+// postAPIRequest sends a non-streaming JSON request to an API path of the
+// Colossus server and decodes the response into out
+func postAPIRequest(path string, req, out interface{}) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return sendAPIRequest(http.MethodPost, path, bytes.NewBuffer(jsonData), out)
+}
+
+// getAPIRequest gets an API path of the Colossus server and decodes the
+// response into out
+func getAPIRequest(path string, out interface{}) error {
+	return sendAPIRequest(http.MethodGet, path, nil, out)
+}
+
+// sendAPIRequest sends a non-streaming request to an API path of the
+// Colossus server and decodes the JSON response into out
+func sendAPIRequest(method, path string, body io.Reader, out interface{}) error {
+	url := fmt.Sprintf("http://%s:%d%s", viper.GetString("host"), viper.GetInt("port"), path)
+	httpReq, err := newAPIRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
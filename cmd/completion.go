@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate the shell completion script",
+	Long: `Generate the completion script for the given shell. Model names are completed
+from the installed models.
+
+To load completions in the current bash session:
+
+  source <(colossus completion bash)
+
+To load them for every zsh session, add the script to your fpath:
+
+  colossus completion zsh > "${fpath[1]}/_colossus"`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	RunE:                  runCompletion,
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+
+	// Commands whose first argument is an installed model
+	for _, cmd := range []*cobra.Command{
+		chatCmd,
+		generateCmd,
+		runCmd,
+		benchmarkCmd,
+		removeModelCmd,
+		infoModelCmd,
+	} {
+		cmd.ValidArgsFunction = completeModelNames
+	}
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	switch args[0] {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		return rootCmd.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+	default:
+		return fmt.Errorf("unsupported shell %q: must be bash, zsh, fish or powershell", args[0])
+	}
+}
+
+// completeModelNames completes the first argument with the names of the
+// installed models and aliases
+func completeModelNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	models, err := manager.ListModels()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var names []string
+	for _, m := range models {
+		if strings.HasPrefix(m.Name, toComplete) {
+			names = append(names, m.Name)
+		}
+	}
+
+	aliases, _ := manager.ListAliases()
+	for alias := range aliases {
+		if strings.HasPrefix(alias, toComplete) {
+			names = append(names, alias)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/model"
+)
+
+func TestCompletionBashScript(t *testing.T) {
+	output, err := executeCommand(t, "completion", "bash")
+	if err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+
+	// Model names come from the __complete command, which runs completeModelNames
+	for _, want := range []string{"__start_colossus", "__complete"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("bash completion script is missing %s", want)
+		}
+	}
+}
+
+func TestCompleteModelNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	modelsPath := filepath.Join(home, ".colossus", "models")
+	if err := os.MkdirAll(modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"tinyllama", "llama2"} {
+		if err := os.WriteFile(filepath.Join(modelsPath, name+".gguf"), []byte("GGUF"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := model.NewManager(modelsPath).AddAlias("tiny", "tinyllama"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "chat", args: []string{"chat", ""}, want: []string{"llama2", "tiny", "tinyllama"}},
+		{name: "prefix", args: []string{"generate", "ti"}, want: []string{"tiny", "tinyllama"}},
+		{name: "models rm", args: []string{"models", "rm", "ll"}, want: []string{"llama2"}},
+		{name: "second argument", args: []string{"run", "tinyllama", ""}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executeCommand(t, append([]string{"__complete"}, tt.args...)...)
+			if err != nil {
+				t.Fatalf("__complete: %v", err)
+			}
+
+			// The output is one completion per line, then the directive
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+				if !strings.HasPrefix(line, ":") {
+					got = append(got, line)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("completions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
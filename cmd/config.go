@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"colossus-cli/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration",
+	Long: `Show and change configuration values. Values are written to
+~/.colossus/config.yaml, or to the file given with --config.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [KEY]",
+	Short: "Show a configuration value",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [KEY] [VALUE]",
+	Short: "Set a configuration value in the config file",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runConfigSet,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all configuration values and where they come from",
+	RunE:  runConfigList,
+}
+
+var configResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Remove the config file, restoring the defaults",
+	RunE:  runConfigReset,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check that the configuration values are valid",
+	RunE:  runConfigValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configResetCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configGetCmd.ValidArgs = config.Keys()
+	configSetCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	config.SetDefaults()
+
+	key := args[0]
+	if !isConfigKey(key) {
+		return fmt.Errorf("unknown config key: %s", key)
+	}
+
+	fmt.Println(viper.Get(key))
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	key, value := args[0], args[1]
+
+	parsed, err := config.ParseValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	path := configFileToWrite()
+	v, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	v.Set(key, parsed)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("Set %s = %v in %s\n", key, parsed, path)
+	return nil
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	config.SetDefaults()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+
+	for _, key := range config.Keys() {
+		value := fmt.Sprint(viper.Get(key))
+		if key == "api_key" && value != "" {
+			value = "********"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, config.Source(key))
+	}
+
+	return w.Flush()
+}
+
+func runConfigReset(cmd *cobra.Command, args []string) error {
+	path := configFileToWrite()
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("No config file at %s\n", path)
+			return nil
+		}
+		return fmt.Errorf("failed to remove config file: %w", err)
+	}
+
+	fmt.Printf("Removed %s\n", path)
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if err := config.Read().Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	fmt.Println("Configuration is valid")
+	return nil
+}
+
+// configFileToWrite returns the config file changed by the config command
+func configFileToWrite() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	return defaultConfigFile()
+}
+
+// readConfigFile loads the config file at path into its own viper instance,
+// so that only values from the file are written back. When the file does not
+// exist yet, it starts from the config file currently in use, if any.
+func readConfigFile(path string) (*viper.Viper, error) {
+	v := viper.New()
+
+	source := path
+	if !fileExists(source) {
+		source = viper.ConfigFileUsed()
+	}
+	if source == "" || !fileExists(source) {
+		return v, nil
+	}
+
+	v.SetConfigFile(source)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return v, nil
+}
+
+// isConfigKey reports whether key is a known configuration key
+func isConfigKey(key string) bool {
+	for _, k := range config.Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
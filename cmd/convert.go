@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/spf13/cobra"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert a model file to another format version",
+	Long: `Rewrite a GGUF file of an older version, which llama.cpp may no longer
+load, in the latest GGUF version. The input is a file or an installed model.
+Without --output the result is written next to it as <name>-v3.gguf.`,
+	Example: `  colossus convert --input old.gguf
+  colossus convert --input old.gguf --output new.gguf --format gguf-v3`,
+	Args: cobra.NoArgs,
+	RunE: runConvert,
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().String("input", "", "Model file or installed model to convert")
+	convertCmd.Flags().String("output", "", "Path of the converted file (default <input>-v3.gguf)")
+	convertCmd.Flags().String("format", "gguf-v3", "Format to convert to; only gguf-v3 is supported")
+	convertCmd.MarkFlagRequired("input")
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	input, _ := cmd.Flags().GetString("input")
+	output, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+
+	if format != "gguf-v3" {
+		return fmt.Errorf("unsupported format %q: must be gguf-v3", format)
+	}
+
+	if output != "" {
+		if err := model.MigrateGGUFFile(input, output); err != nil {
+			return fmt.Errorf("failed to convert model: %w", err)
+		}
+	} else {
+		cfg := config.Load()
+		manager := model.NewManager(cfg.ModelsPath)
+
+		var err error
+		if output, err = manager.MigrateGGUF(input); err != nil {
+			return fmt.Errorf("failed to convert model: %w", err)
+		}
+	}
+
+	fmt.Printf("Successfully converted %s to %s\n", input, output)
+	return nil
+}
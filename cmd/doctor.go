@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/gpu"
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/registry"
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// doctorMinFreeSpace is the free disk space below which the models
+	// directory is reported as too full, about the size of a 7B model
+	doctorMinFreeSpace = 5 << 30
+
+	// doctorServerTimeout limits requests checking that the server responds
+	doctorServerTimeout = 5 * time.Second
+
+	// doctorGenerateTimeout limits the sample generation, which may first
+	// have to load the model
+	doctorGenerateTimeout = 2 * time.Minute
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the installation for problems",
+	Long: `Check the models directory, GPU drivers, llama.cpp bindings, Hugging Face token,
+free disk space and a running server, and explain how to fix any problem found.
+Exits with status 1 if a check fails.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// DiagnosticStatus is the outcome of a diagnostic check
+type DiagnosticStatus int
+
+const (
+	DiagnosticPass DiagnosticStatus = iota
+	DiagnosticFail
+	// DiagnosticSkip is for checks that do not apply, e.g. without a GPU
+	DiagnosticSkip
+)
+
+// DiagnosticResult is the outcome of a check, with what to do about it when
+// it did not pass
+type DiagnosticResult struct {
+	Status      DiagnosticStatus
+	Message     string
+	Remediation string
+}
+
+// DiagnosticCheck is one check run by colossus doctor
+type DiagnosticCheck struct {
+	Name string
+	Run  func() DiagnosticResult
+}
+
+// diagnosticPass, diagnosticFail and diagnosticSkip create check results
+func diagnosticPass(format string, args ...interface{}) DiagnosticResult {
+	return DiagnosticResult{Status: DiagnosticPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func diagnosticFail(message, remediation string) DiagnosticResult {
+	return DiagnosticResult{Status: DiagnosticFail, Message: message, Remediation: remediation}
+}
+
+func diagnosticSkip(message, remediation string) DiagnosticResult {
+	return DiagnosticResult{Status: DiagnosticSkip, Message: message, Remediation: remediation}
+}
+
+// diagnosticChecks returns the checks run by colossus doctor, in order
+func diagnosticChecks(cfg *config.Config) []DiagnosticCheck {
+	serverURL := fmt.Sprintf("http://%s:%d", viper.GetString("host"), viper.GetInt("port"))
+	hfRegistry := registry.NewHuggingFaceRegistry(os.Getenv("HUGGINGFACE_TOKEN"))
+	// An invalid proxy is left unset here and reported by the commands using it
+	hfRegistry.SetProxy(cfg.Proxy, cfg.NoProxyHosts())
+
+	return []DiagnosticCheck{
+		{Name: "Models directory", Run: func() DiagnosticResult { return checkModelsDirectory(cfg.ModelsPath) }},
+		{Name: "Disk space", Run: func() DiagnosticResult { return checkDiskSpace(cfg.ModelsPath, doctorMinFreeSpace) }},
+		{Name: "GPU", Run: func() DiagnosticResult { return checkGPU(gpu.DetectGPUs()) }},
+		{Name: "llama.cpp", Run: func() DiagnosticResult { return checkLlamaCpp(llama.Available) }},
+		{Name: "Hugging Face token", Run: func() DiagnosticResult { return checkHuggingFaceToken(hfRegistry) }},
+		{Name: "Server", Run: func() DiagnosticResult { return checkServer(serverURL) }},
+		{Name: "Generation", Run: func() DiagnosticResult { return checkGenerate(serverURL) }},
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+
+	failed := 0
+	checks := diagnosticChecks(cfg)
+	for _, check := range checks {
+		result := check.Run()
+
+		mark := "✓"
+		switch result.Status {
+		case DiagnosticFail:
+			mark = "✗"
+			failed++
+		case DiagnosticSkip:
+			mark = "-"
+		}
+		fmt.Printf("%s %s: %s\n", mark, check.Name, result.Message)
+		if result.Remediation != "" {
+			fmt.Printf("    %s\n", result.Remediation)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// checkModelsDirectory checks that the models directory exists and that
+// models can be written to it
+func checkModelsDirectory(path string) DiagnosticResult {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return diagnosticFail(fmt.Sprintf("%s does not exist", path),
+			fmt.Sprintf("Create it with 'mkdir -p %s', or set models_path to another directory.", path))
+	}
+	if err != nil {
+		return diagnosticFail(err.Error(), "Check the permissions of the models directory's parent directories.")
+	}
+	if !info.IsDir() {
+		return diagnosticFail(fmt.Sprintf("%s is not a directory", path), "Set models_path to a directory.")
+	}
+
+	file, err := os.CreateTemp(path, ".doctor-*")
+	if err != nil {
+		return diagnosticFail(fmt.Sprintf("%s is not writable", path),
+			fmt.Sprintf("Give your user write access with 'chmod u+w %s', or set models_path to another directory.", path))
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return diagnosticPass("%s is writable", path)
+}
+
+// checkDiskSpace checks that the filesystem of the models directory has room
+// for at least minFree more bytes of models
+func checkDiskSpace(path string, minFree uint64) DiagnosticResult {
+	free, err := model.FreeDiskSpace(path)
+	if err != nil {
+		return diagnosticSkip(fmt.Sprintf("cannot determine free disk space: %v", err), "")
+	}
+
+	message := fmt.Sprintf("%s free in %s", formatSize(int64(free)), path)
+	if free < minFree {
+		return diagnosticFail(message,
+			fmt.Sprintf("Free up space or move models_path to a larger disk; most models need at least %s.", formatSize(int64(minFree))))
+	}
+	return diagnosticPass("%s", message)
+}
+
+// checkGPU reports the GPUs models can be offloaded to. Without one, models
+// run on the CPU, so it is not a failure.
+func checkGPU(info *gpu.GPUInfo) DiagnosticResult {
+	if info == nil || !info.Available {
+		return diagnosticSkip("no GPU detected, models run on the CPU",
+			"For GPU acceleration, install the NVIDIA (CUDA) or AMD (ROCm) drivers and check that nvidia-smi or rocm-smi works.")
+	}
+
+	message := fmt.Sprintf("%s, %d device(s)", info.Type, info.DeviceCount)
+	if info.DriverVersion != "" {
+		message += ", driver " + info.DriverVersion
+	}
+	return diagnosticPass("%s", message)
+}
+
+// checkLlamaCpp checks that the binary was built with the llama.cpp bindings
+// needed to run models
+func checkLlamaCpp(available bool) DiagnosticResult {
+	if !available {
+		return diagnosticFail("built without the llama.cpp bindings, so models cannot be run",
+			"Rebuild with 'CGO_ENABLED=1 go build -tags llamacpp_cgo', or set COLOSSUS_INFERENCE_ENGINE=simulated for testing.")
+	}
+	return diagnosticPass("bindings compiled in")
+}
+
+// checkHuggingFaceToken checks that the Hugging Face token, if one is set, is
+// accepted by Hugging Face
+func checkHuggingFaceToken(hf *registry.HuggingFaceRegistry) DiagnosticResult {
+	if hf.Token == "" {
+		return diagnosticSkip("HUGGINGFACE_TOKEN is not set, only public models can be pulled",
+			"To pull gated models, create a token at https://huggingface.co/settings/tokens and set HUGGINGFACE_TOKEN.")
+	}
+
+	user, err := hf.WhoAmI()
+	if errors.Is(err, registry.ErrInvalidToken) {
+		return diagnosticFail("HUGGINGFACE_TOKEN is invalid or expired",
+			"Create a new token at https://huggingface.co/settings/tokens and set HUGGINGFACE_TOKEN to it.")
+	}
+	if err != nil {
+		return diagnosticFail(fmt.Sprintf("cannot reach Hugging Face: %v", err),
+			"Check your internet connection, and set HTTPS_PROXY if you are behind a proxy.")
+	}
+	return diagnosticPass("valid, authenticated as %s", user)
+}
+
+// checkServer checks that a server started with colossus serve responds. No
+// server running is not a failure.
+func checkServer(serverURL string) DiagnosticResult {
+	client := &http.Client{Timeout: doctorServerTimeout}
+	req, err := newAPIRequest(http.MethodGet, serverURL+"/health", nil)
+	if err != nil {
+		return diagnosticFail(err.Error(), "")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return diagnosticSkip(fmt.Sprintf("no server running at %s", serverURL),
+			"Start one with 'colossus serve', or pass --host and --port of the running server.")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return diagnosticFail(fmt.Sprintf("%s responded with %s: %s", serverURL, resp.Status, bytes.TrimSpace(body)),
+			"Check the server's log; set api_key if the server requires an API key.")
+	}
+	return diagnosticPass("responding at %s", serverURL)
+}
+
+// checkGenerate sends a sample generate request for one token to the running
+// server, using the first model it has installed
+func checkGenerate(serverURL string) DiagnosticResult {
+	client := &http.Client{Timeout: doctorServerTimeout}
+	req, err := newAPIRequest(http.MethodGet, serverURL+"/api/tags", nil)
+	if err != nil {
+		return diagnosticFail(err.Error(), "")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return diagnosticSkip("no server running", "Start one with 'colossus serve' to test generation.")
+	}
+	var models types.ModelsResponse
+	err = json.NewDecoder(resp.Body).Decode(&models)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return diagnosticFail(fmt.Sprintf("cannot list the server's models: %s", resp.Status), "Check the server's log.")
+	}
+	if len(models.Models) == 0 {
+		return diagnosticSkip("no models installed", "Pull one with 'colossus models pull tinyllama'.")
+	}
+	name := models.Models[0].Name
+
+	body, err := json.Marshal(types.GenerateRequest{
+		Model:   name,
+		Prompt:  "Hello",
+		Options: &types.Options{NumPredict: 1},
+	})
+	if err != nil {
+		return diagnosticFail(err.Error(), "")
+	}
+	req, err = newAPIRequest(http.MethodPost, serverURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return diagnosticFail(err.Error(), "")
+	}
+
+	start := time.Now()
+	client.Timeout = doctorGenerateTimeout
+	resp, err = client.Do(req)
+	if err != nil {
+		var urlErr interface{ Timeout() bool }
+		if errors.As(err, &urlErr) && urlErr.Timeout() {
+			return diagnosticFail(fmt.Sprintf("%s did not respond within %s", name, doctorGenerateTimeout),
+				"The model may be too large for this machine; try a smaller quantization or offload layers to a GPU.")
+		}
+		return diagnosticFail(fmt.Sprintf("generate request failed: %v", err), "Check the server's log.")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp types.ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return diagnosticFail(fmt.Sprintf("%s failed to generate: %s", name, errResp.Error),
+			"Check the server's log; 'colossus models info "+name+"' shows whether the model file is valid.")
+	}
+	return diagnosticPass("%s responded in %s", name, time.Since(start).Round(time.Millisecond))
+}
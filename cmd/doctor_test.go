@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/gpu"
+	"colossus-cli/internal/registry"
+)
+
+func TestCheckModelsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  DiagnosticStatus
+		wantMessage string
+	}{
+		{name: "writable", path: dir, wantStatus: DiagnosticPass, wantMessage: "is writable"},
+		{name: "missing", path: filepath.Join(dir, "missing"), wantStatus: DiagnosticFail, wantMessage: "does not exist"},
+		{name: "file", path: file, wantStatus: DiagnosticFail, wantMessage: "is not a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkModelsDirectory(tt.path)
+			if result.Status != tt.wantStatus || !strings.Contains(result.Message, tt.wantMessage) {
+				t.Errorf("checkModelsDirectory() = %+v, want status %d with %q", result, tt.wantStatus, tt.wantMessage)
+			}
+			if tt.wantStatus == DiagnosticFail && result.Remediation == "" {
+				t.Error("failed check has no remediation")
+			}
+		})
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("models directory has %d entries after the check, want only the test file", len(entries))
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if result := checkDiskSpace(filepath.Join(dir, "models"), 0); result.Status != DiagnosticPass {
+		t.Errorf("checkDiskSpace(0) = %+v, want pass", result)
+	}
+	if result := checkDiskSpace(dir, 1<<62); result.Status != DiagnosticFail || result.Remediation == "" {
+		t.Errorf("checkDiskSpace(4 EiB) = %+v, want failure", result)
+	}
+}
+
+func TestCheckGPUAndLlamaCpp(t *testing.T) {
+	if result := checkGPU(&gpu.GPUInfo{}); result.Status != DiagnosticSkip {
+		t.Errorf("checkGPU(no GPU) = %+v, want skipped", result)
+	}
+	result := checkGPU(&gpu.GPUInfo{Available: true, Type: "cuda", DeviceCount: 2, DriverVersion: "550.54"})
+	if result.Status != DiagnosticPass || result.Message != "cuda, 2 device(s), driver 550.54" {
+		t.Errorf("checkGPU(CUDA) = %+v", result)
+	}
+
+	if result := checkLlamaCpp(false); result.Status != DiagnosticFail || !strings.Contains(result.Remediation, "llamacpp_cgo") {
+		t.Errorf("checkLlamaCpp(false) = %+v, want failure explaining the build tag", result)
+	}
+	if result := checkLlamaCpp(true); result.Status != DiagnosticPass {
+		t.Errorf("checkLlamaCpp(true) = %+v, want pass", result)
+	}
+}
+
+func TestCheckHuggingFaceToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/whoami" || r.Header.Get("Authorization") != "Bearer hf_valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"name":"alice"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		token       string
+		wantStatus  DiagnosticStatus
+		wantMessage string
+	}{
+		{token: "", wantStatus: DiagnosticSkip, wantMessage: "not set"},
+		{token: "hf_valid", wantStatus: DiagnosticPass, wantMessage: "authenticated as alice"},
+		{token: "hf_expired", wantStatus: DiagnosticFail, wantMessage: "invalid or expired"},
+	}
+
+	for _, tt := range tests {
+		hf := registry.NewHuggingFaceRegistry(tt.token)
+		hf.BaseURL = srv.URL
+		result := checkHuggingFaceToken(hf)
+		if result.Status != tt.wantStatus || !strings.Contains(result.Message, tt.wantMessage) {
+			t.Errorf("checkHuggingFaceToken(%q) = %+v, want status %d with %q", tt.token, result, tt.wantStatus, tt.wantMessage)
+		}
+	}
+}
+
+// serverURL returns the URL of the server the CLI flags point at
+func serverURL(flags []string) string {
+	return "http://" + flags[1] + ":" + flags[3]
+}
+
+func TestCheckServerAndGenerate(t *testing.T) {
+	url := serverURL(newTestAPIServer(t, "tinyllama"))
+	if result := checkServer(url); result.Status != DiagnosticPass {
+		t.Errorf("checkServer() = %+v, want pass", result)
+	}
+	if result := checkGenerate(url); result.Status != DiagnosticPass || !strings.HasPrefix(result.Message, "tinyllama responded in") {
+		t.Errorf("checkGenerate() = %+v, want pass", result)
+	}
+
+	empty := serverURL(newTestAPIServer(t))
+	if result := checkGenerate(empty); result.Status != DiagnosticSkip || result.Message != "no models installed" {
+		t.Errorf("checkGenerate(no models) = %+v, want skipped", result)
+	}
+
+	failing := serverURL(newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model store unavailable", http.StatusServiceUnavailable)
+	}))
+	if result := checkServer(failing); result.Status != DiagnosticFail || !strings.Contains(result.Message, "model store unavailable") {
+		t.Errorf("checkServer(failing) = %+v, want failure with the response", result)
+	}
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if result := checkServer(srv.URL); result.Status != DiagnosticSkip {
+		t.Errorf("checkServer(no server) = %+v, want skipped", result)
+	}
+}
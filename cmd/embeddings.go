@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var embeddingsCmd = &cobra.Command{
+	Use:   "embeddings [MODEL_NAME] [TEXT]",
+	Short: "Compute an embedding vector for text",
+	Long:  "Compute an embedding vector for text using a running Colossus server and print it as JSON",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runEmbeddings,
+}
+
+func init() {
+	rootCmd.AddCommand(embeddingsCmd)
+}
+
+func runEmbeddings(cmd *cobra.Command, args []string) error {
+	host := viper.GetString("host")
+	port := viper.GetInt("port")
+	url := fmt.Sprintf("http://%s:%d/v1/embeddings", host, port)
+
+	req := types.OpenAIEmbeddingRequest{
+		Model: args[0],
+		Input: types.OpenAIStringList{args[1]},
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := newAPIRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(body))
+	}
+
+	var embedResp types.OpenAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(embedResp.Data) == 0 {
+		return fmt.Errorf("server returned no embeddings")
+	}
+
+	output, err := json.Marshal(embedResp.Data[0].Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding: %w", err)
+	}
+
+	fmt.Println(string(output))
+	return nil
+}
@@ -0,0 +1,407 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [MODEL_NAME]",
+	Short: "Generate a response to a single prompt",
+	Long: `Generate a response to a single prompt using a running Colossus server and
+print it. The prompt is read from stdin when --prompt is not given.
+
+With --input-file, every line of a JSONL file is sent as a generate request,
+--parallel at a time, and the responses are written as JSONL in the same
+order. Requests without a model use MODEL_NAME.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenerate,
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().String("prompt", "", "Prompt text (read from stdin if not set)")
+	generateCmd.Flags().String("system", "", "System prompt placed before the prompt")
+	generateCmd.Flags().StringArray("image", nil, "PNG or JPEG image to send with the prompt, for multimodal models (repeatable)")
+	generateCmd.Flags().Float64("temperature", 0, "Sampling temperature")
+	generateCmd.Flags().Float64("top-p", 0, "Nucleus sampling probability")
+	generateCmd.Flags().Int("top-k", 0, "Sample from the K most likely tokens")
+	generateCmd.Flags().Int64("seed", -1, "Random seed for reproducible responses (-1 for random)")
+	generateCmd.Flags().Int("max-tokens", 0, "Maximum number of tokens to generate")
+	generateCmd.Flags().StringArray("stop", nil, "Stop generating at this text (repeatable)")
+	generateCmd.Flags().String("format", "", "Response format; \"json\" constrains the response to JSON")
+	generateCmd.Flags().Bool("no-stream", false, "Wait for the full response before printing it")
+	generateCmd.Flags().Bool("json", false, "Output the full response as JSON")
+	generateCmd.Flags().Bool("show-metrics", false, "Print the timing breakdown of the generation after the response")
+	generateCmd.Flags().String("input-file", "", "JSONL file of generate requests, one per line, to process instead of a single prompt")
+	generateCmd.Flags().String("output-file", "", "File the JSONL responses to --input-file are written to (default stdout)")
+	generateCmd.Flags().Int("parallel", 4, "Requests of --input-file sent at a time")
+	generateCmd.MarkFlagsMutuallyExclusive("prompt", "input-file")
+}
+
+func runGenerate(cmd *cobra.Command, args []string) error {
+	if inputFile, _ := cmd.Flags().GetString("input-file"); inputFile != "" {
+		return runGenerateFile(cmd, args[0], inputFile)
+	}
+
+	prompt, err := generatePrompt(cmd)
+	if err != nil {
+		return err
+	}
+
+	options, err := generateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	imagePaths, _ := cmd.Flags().GetStringArray("image")
+	images, err := readImages(imagePaths)
+	if err != nil {
+		return err
+	}
+
+	noStream, _ := cmd.Flags().GetBool("no-stream")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	system, _ := cmd.Flags().GetString("system")
+
+	req := types.GenerateRequest{
+		Model:   args[0],
+		Prompt:  prompt,
+		System:  system,
+		Images:  images,
+		Stream:  !noStream,
+		Options: options,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("http://%s:%d/api/generate", viper.GetString("host"), viper.GetInt("port"))
+	httpReq, err := newAPIRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(body))
+	}
+
+	metrics, err := printGenerateResponse(resp.Body, jsonOutput)
+	if err != nil {
+		return err
+	}
+
+	if showMetrics, _ := cmd.Flags().GetBool("show-metrics"); showMetrics && metrics != nil {
+		return printInferenceMetrics(metrics)
+	}
+	return nil
+}
+
+// generateResult is the outcome of one request of an input file
+type generateResult struct {
+	index int
+	line  []byte
+	err   error
+}
+
+// runGenerateFile sends every request of a JSONL input file to the server
+// using a pool of workers, and writes the responses in the order of the
+// requests, one JSON object per line. Failed requests are written as an
+// error object so that the lines of the input and output still match.
+func runGenerateFile(cmd *cobra.Command, modelName, inputFile string) error {
+	flags := cmd.Flags()
+	outputFile, _ := flags.GetString("output-file")
+	parallel, _ := flags.GetInt("parallel")
+	if parallel < 1 {
+		return fmt.Errorf("parallel must be at least 1")
+	}
+
+	options, err := generateOptions(cmd)
+	if err != nil {
+		return err
+	}
+	system, _ := flags.GetString("system")
+
+	reqs, err := readGenerateRequests(inputFile)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if req.Model == "" {
+			req.Model = modelName
+		}
+		if req.System == "" {
+			req.System = system
+		}
+		if req.Options == nil {
+			req.Options = options
+		}
+		req.Stream = false
+	}
+
+	out := os.Stdout
+	if outputFile != "" {
+		if out, err = os.Create(outputFile); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+	}
+	writer := bufio.NewWriter(out)
+
+	jobs := make(chan int)
+	results := make(chan generateResult)
+	for w := 0; w < min(parallel, len(reqs)); w++ {
+		go func() {
+			for i := range jobs {
+				var resp types.GenerateResponse
+				if err := postAPIRequest("/api/generate", reqs[i], &resp); err != nil {
+					results <- generateResult{index: i, err: err}
+					continue
+				}
+				line, err := json.Marshal(resp)
+				results <- generateResult{index: i, line: line, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range reqs {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	// Responses are held until those of all earlier requests are written
+	pending := make(map[int]generateResult)
+	next, failed := 0, 0
+	showProgress := isTerminal(os.Stderr)
+	for done := 1; done <= len(reqs); done++ {
+		result := <-results
+		if result.err != nil {
+			failed++
+			line, _ := json.Marshal(types.ErrorResponse{Error: result.err.Error()})
+			result.line = line
+		}
+		pending[result.index] = result
+
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			writer.Write(result.line)
+			writer.WriteByte('\n')
+			delete(pending, next)
+			next++
+		}
+
+		if showProgress {
+			fmt.Fprintf(os.Stderr, "\rCompleted %d/%d", done, len(reqs))
+		}
+	}
+	if showProgress {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write responses: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, len(reqs))
+	}
+	if outputFile != "" {
+		fmt.Fprintf(os.Stderr, "Successfully wrote %d responses to %s\n", len(reqs), outputFile)
+	}
+	return nil
+}
+
+// readGenerateRequests reads a JSONL file of generate requests. Blank lines
+// are skipped.
+func readGenerateRequests(path string) ([]*types.GenerateRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	var reqs []*types.GenerateRequest
+	scanner := bufio.NewScanner(file)
+	// Lines may hold base64 images
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req types.GenerateRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			return nil, fmt.Errorf("invalid request on line %d: %w", lineNumber, err)
+		}
+		reqs = append(reqs, &req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no requests in %s", path)
+	}
+	return reqs, nil
+}
+
+// generatePrompt returns the --prompt flag, or the prompt read from stdin
+func generatePrompt(cmd *cobra.Command) (string, error) {
+	if cmd.Flags().Changed("prompt") {
+		prompt, _ := cmd.Flags().GetString("prompt")
+		return prompt, nil
+	}
+
+	if isTerminal(os.Stdin) {
+		return "", errors.New("no prompt given: use --prompt or pipe the prompt to stdin")
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt: %w", err)
+	}
+
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", errors.New("empty prompt")
+	}
+	return prompt, nil
+}
+
+// readImages reads the images at paths, checking that they are PNG or JPEG
+// images before they are sent to the server
+func readImages(paths []string) ([][]byte, error) {
+	var images [][]byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		if contentType := http.DetectContentType(data); contentType != "image/png" && contentType != "image/jpeg" {
+			return nil, fmt.Errorf("%s is not a PNG or JPEG image", path)
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+// generateOptions builds the generation options from the flags that were set
+func generateOptions(cmd *cobra.Command) (*types.Options, error) {
+	flags := cmd.Flags()
+	options := &types.Options{}
+
+	options.Temperature, _ = flags.GetFloat64("temperature")
+	options.TopP, _ = flags.GetFloat64("top-p")
+	options.TopK, _ = flags.GetInt("top-k")
+	options.NumPredict, _ = flags.GetInt("max-tokens")
+	options.Stop, _ = flags.GetStringArray("stop")
+	if seed, _ := flags.GetInt64("seed"); seed != -1 {
+		options.Seed = &seed
+	}
+
+	format, _ := flags.GetString("format")
+	switch format {
+	case "":
+	case "json":
+		options.Format = format
+	default:
+		return nil, fmt.Errorf("unsupported format %q: must be json", format)
+	}
+
+	return options, nil
+}
+
+// printGenerateResponse prints a streamed or complete response as raw text,
+// or as one JSON object per response when jsonOutput is set. It returns the
+// metrics of the final response.
+func printGenerateResponse(body io.Reader, jsonOutput bool) (*types.InferenceMetrics, error) {
+	var metrics *types.InferenceMetrics
+	decoder := json.NewDecoder(body)
+	for decoder.More() {
+		// Stream errors arrive as a final chunk with an error message
+		var chunk struct {
+			types.GenerateResponse
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if chunk.Error != "" {
+			if !jsonOutput {
+				fmt.Println()
+			}
+			return nil, errors.New(chunk.Error)
+		}
+
+		if jsonOutput {
+			data, err := json.Marshal(chunk.GenerateResponse)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal response: %w", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Print(chunk.Response)
+		}
+
+		if chunk.Done {
+			metrics = chunk.InferenceMetrics
+			break
+		}
+	}
+
+	if !jsonOutput {
+		fmt.Println()
+	}
+	return metrics, nil
+}
+
+// printInferenceMetrics prints the timing breakdown of a generation to stderr,
+// keeping stdout for the response
+func printInferenceMetrics(metrics *types.InferenceMetrics) error {
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "total duration:\t%s\n", metrics.TotalDuration)
+	fmt.Fprintf(w, "load duration:\t%s\n", metrics.LoadDuration)
+	fmt.Fprintf(w, "prompt eval count:\t%d token(s)\n", metrics.PromptEvalCount)
+	fmt.Fprintf(w, "prompt eval duration:\t%s\n", metrics.PromptEvalDuration)
+	fmt.Fprintf(w, "prompt eval rate:\t%s\n", tokenRate(metrics.PromptEvalCount, metrics.PromptEvalDuration))
+	fmt.Fprintf(w, "eval count:\t%d token(s)\n", metrics.EvalCount)
+	fmt.Fprintf(w, "eval duration:\t%s\n", metrics.EvalDuration)
+	fmt.Fprintf(w, "eval rate:\t%s\n", tokenRate(metrics.EvalCount, metrics.EvalDuration))
+	return w.Flush()
+}
+
+// tokenRate formats the rate of tokens evaluated in a duration
+func tokenRate(count int, duration time.Duration) string {
+	if duration <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f tokens/s", float64(count)/duration.Seconds())
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// generateServer mocks /api/generate, streaming chunks and recording the
+// request it received
+func generateServer(t *testing.T, chunks ...interface{}) (*types.GenerateRequest, []string) {
+	t.Helper()
+	received := &types.GenerateRequest{}
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("request to %s, want /api/generate", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+
+		encoder := json.NewEncoder(w)
+		for _, chunk := range chunks {
+			encoder.Encode(chunk)
+		}
+	})
+	return received, flags
+}
+
+func TestGenerateCommandStreamsText(t *testing.T) {
+	received, flags := generateServer(t,
+		types.GenerateResponse{Model: "tinyllama", Response: "Hello"},
+		types.GenerateResponse{Model: "tinyllama", Response: " world", Done: true},
+	)
+
+	output, err := executeCommand(t, append([]string{"generate", "tinyllama", "--prompt", "hi"}, flags...)...)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if output != "Hello world\n" {
+		t.Errorf("output = %q, want %q", output, "Hello world\n")
+	}
+	if received.Model != "tinyllama" || received.Prompt != "hi" || !received.Stream {
+		t.Errorf("request = %+v, want a streamed request for tinyllama with prompt hi", received)
+	}
+}
+
+func TestGenerateCommandOptions(t *testing.T) {
+	received, flags := generateServer(t, types.GenerateResponse{Response: "{}", Done: true})
+
+	args := append([]string{"generate", "tinyllama", "--prompt", "hi",
+		"--system", "Be brief.", "--temperature", "0.5", "--top-p", "0.9", "--top-k", "20",
+		"--seed", "7", "--max-tokens", "64", "--stop", "\n", "--stop", "END",
+		"--format", "json", "--no-stream"}, flags...)
+	if _, err := executeCommand(t, args...); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	seed := int64(7)
+	want := &types.Options{
+		Temperature: 0.5,
+		TopP:        0.9,
+		TopK:        20,
+		Seed:        &seed,
+		NumPredict:  64,
+		Stop:        []string{"\n", "END"},
+		Format:      "json",
+	}
+	if !reflect.DeepEqual(received.Options, want) {
+		t.Errorf("options = %+v, want %+v", received.Options, want)
+	}
+	if received.System != "Be brief." || received.Stream {
+		t.Errorf("request = %+v, want an unstreamed request with the system prompt", received)
+	}
+}
+
+func TestGenerateCommandJSONOutput(t *testing.T) {
+	_, flags := generateServer(t,
+		types.GenerateResponse{Model: "tinyllama", Response: "Hello"},
+		types.GenerateResponse{Model: "tinyllama", Done: true, Context: []int{1, 2}},
+	)
+
+	output, err := executeCommand(t, append([]string{"generate", "tinyllama", "--prompt", "hi", "--json"}, flags...)...)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per response: %q", len(lines), output)
+	}
+	var last types.GenerateResponse
+	if err := json.Unmarshal([]byte(lines[1]), &last); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", lines[1], err)
+	}
+	if !last.Done || len(last.Context) != 2 {
+		t.Errorf("last response = %+v, want the final response", last)
+	}
+}
+
+func TestGenerateCommandPromptFromStdin(t *testing.T) {
+	received, flags := generateServer(t, types.GenerateResponse{Response: "ok", Done: true})
+
+	setStdin(t, "  summarize this\n")
+
+	if _, err := executeCommand(t, append([]string{"generate", "tinyllama"}, flags...)...); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if received.Prompt != "summarize this" {
+		t.Errorf("prompt = %q, want %q", received.Prompt, "summarize this")
+	}
+}
+
+func TestGenerateCommandErrors(t *testing.T) {
+	_, flags := generateServer(t,
+		types.GenerateResponse{Response: "Hel"},
+		map[string]interface{}{"error": "model crashed", "done": true},
+	)
+
+	if _, err := executeCommand(t, append([]string{"generate", "tinyllama", "--prompt", "hi"}, flags...)...); err == nil || err.Error() != "model crashed" {
+		t.Errorf("err = %v, want the stream error", err)
+	}
+	if _, err := executeCommand(t, "generate", "tinyllama", "--prompt", "hi", "--format", "yaml"); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
+
+func TestGenerateCommandImages(t *testing.T) {
+	received, flags := generateServer(t, types.GenerateResponse{Response: "a cat", Done: true})
+
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	image := filepath.Join(dir, "cat.png")
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(image, png, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(text, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := append([]string{"generate", "llava", "--prompt", "describe", "--image", image}, flags...)
+	if _, err := executeCommand(t, args...); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(received.Images) != 1 || !bytes.Equal(received.Images[0], png) {
+		t.Errorf("images = %q, want the PNG image", received.Images)
+	}
+
+	if _, err := executeCommand(t, "generate", "llava", "--prompt", "describe", "--image", text); err == nil || !strings.Contains(err.Error(), "not a PNG or JPEG image") {
+		t.Errorf("err = %v, want a rejected image", err)
+	}
+}
+
+func TestGenerateCommandInputFile(t *testing.T) {
+	// Later prompts are answered sooner, so responses arrive out of order
+	var mu sync.Mutex
+	var prompts []string
+	active, maxActive := 0, 0
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req types.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+
+		n, _ := strconv.Atoi(req.Prompt)
+		time.Sleep(time.Duration(10-n) * 5 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if req.Stream {
+			t.Errorf("request %s is streamed", req.Prompt)
+		}
+		json.NewEncoder(w).Encode(types.GenerateResponse{
+			Model:    req.Model,
+			Response: req.System + "re: " + req.Prompt,
+			Done:     true,
+		})
+	})
+
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	args := append([]string{"generate", "tinyllama", "--input-file", "testdata/prompts.jsonl",
+		"--output-file", output, "--parallel", "3"}, flags...)
+	if _, err := executeCommand(t, args...); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		"tinyllama re: 1",
+		"mistral re: 2",
+		"tinyllama Answer in French.re: 3",
+		"tinyllama re: 4",
+		"tinyllama re: 5",
+		"tinyllama re: 6",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d responses, want %d: %s", len(lines), len(want), data)
+	}
+	for i, line := range lines {
+		var resp types.GenerateResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response on line %d: %v", i+1, err)
+		}
+		if got := resp.Model + " " + resp.Response; got != want[i] {
+			t.Errorf("line %d = %q, want %q", i+1, got, want[i])
+		}
+	}
+	if len(prompts) != len(want) {
+		t.Errorf("server received %d requests, want %d", len(prompts), len(want))
+	}
+	if maxActive < 2 || maxActive > 3 {
+		t.Errorf("%d requests in flight at once, want up to --parallel 3", maxActive)
+	}
+}
+
+func TestGenerateCommandInputFileFailures(t *testing.T) {
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req types.GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Prompt == "3" {
+			http.Error(w, `{"error": "model crashed"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(types.GenerateResponse{Response: "ok", Done: true})
+	})
+
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	args := append([]string{"generate", "tinyllama", "--input-file", "testdata/prompts.jsonl", "--output-file", output}, flags...)
+	if _, err := executeCommand(t, args...); err == nil || err.Error() != "1 of 6 requests failed" {
+		t.Errorf("err = %v, want 1 failed request", err)
+	}
+
+	// The failed request keeps its line
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 6 || !strings.Contains(lines[2], "model crashed") {
+		t.Errorf("output = %s, want an error on line 3", data)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.jsonl")
+	if err := os.WriteFile(invalid, []byte("{\"prompt\": \"1\"}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := executeCommand(t, append([]string{"generate", "tinyllama", "--input-file", invalid}, flags...)...); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want the invalid line", err)
+	}
+}
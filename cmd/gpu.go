@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"colossus-cli/internal/gpu"
 
@@ -23,6 +27,13 @@ var gpuInfoCmd = &cobra.Command{
 	RunE:  runGPUInfo,
 }
 
+var gpuWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Show live GPU utilization, memory, temperature and power",
+	Long:  "Refresh a display of each GPU's utilization, VRAM usage, temperature and power draw until interrupted. Supported for CUDA and ROCm GPUs.",
+	RunE:  runGPUWatch,
+}
+
 var gpuStatusCmd = &cobra.Command{
 	Use:   "status", 
 	Short: "Check GPU acceleration status",
@@ -33,10 +44,13 @@ func init() {
 	rootCmd.AddCommand(gpuCmd)
 	gpuCmd.AddCommand(gpuInfoCmd)
 	gpuCmd.AddCommand(gpuStatusCmd)
+	gpuCmd.AddCommand(gpuWatchCmd)
 	
 	// Add flags for output format
 	gpuInfoCmd.Flags().Bool("json", false, "Output in JSON format")
 	gpuStatusCmd.Flags().Bool("json", false, "Output in JSON format")
+	gpuWatchCmd.Flags().Bool("json", false, "Print each sample as a line of JSON")
+	gpuWatchCmd.Flags().Duration("interval", time.Second, "Time between samples")
 }
 
 func runGPUInfo(cmd *cobra.Command, args []string) error {
@@ -95,12 +109,24 @@ func runGPUInfo(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  Environment: COLOSSUS_GPU_LAYERS=%d\n", optimalLayers)
 	} else {
 		fmt.Println("\nTo enable GPU acceleration:")
-		fmt.Println("  1. Install CUDA Toolkit (NVIDIA) or ROCm (AMD)")
+		fmt.Println("  1. Install CUDA Toolkit (NVIDIA), ROCm (AMD) or oneAPI (Intel)")
 		fmt.Println("  2. Ensure drivers are properly installed")
 		fmt.Println("  3. Set COLOSSUS_INFERENCE_ENGINE=llamacpp")
 		fmt.Println("  4. Restart Colossus server")
 	}
 	
+	// CPU inference on multi-socket machines depends on the NUMA mode
+	if nodes := gpu.DetectNUMATopology(); len(nodes) > 1 {
+		fmt.Println("\nNUMA Nodes:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tCPUS\tMEMORY")
+		for _, node := range nodes {
+			fmt.Fprintf(w, "%d\t%d\t%s\n", node.ID, len(node.CPUs), formatMemory(node.MemoryMB))
+		}
+		w.Flush()
+		fmt.Printf("  Set numa_mode in the model options or COLOSSUS_NUMA_MODE to place threads on them\n")
+	}
+	
 	return nil
 }
 
@@ -130,6 +156,60 @@ func runGPUStatus(cmd *cobra.Command, args []string) error {
 	}
 }
 
+func runGPUWatch(cmd *cobra.Command, args []string) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	
+	// Stop sampling on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	
+	encoder := json.NewEncoder(os.Stdout)
+	for sample := range gpu.Monitor(ctx, interval) {
+		if jsonOutput {
+			if err := encoder.Encode(sample); err != nil {
+				return fmt.Errorf("failed to encode GPU metrics: %w", err)
+			}
+			continue
+		}
+		
+		// Clear the screen and move the cursor to the top left
+		fmt.Print("\033[H\033[2J")
+		printGPUMetrics(&sample, interval)
+	}
+	
+	return nil
+}
+
+// printGPUMetrics prints a sample of GPU usage as a table
+func printGPUMetrics(sample *gpu.GPUMetrics, interval time.Duration) {
+	fmt.Printf("Every %s: colossus gpu watch  %s\n\n", interval, sample.Timestamp.Format(time.TimeOnly))
+	
+	if sample.Error != "" {
+		fmt.Printf("Error: %s\n", sample.Error)
+		return
+	}
+	
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tUTIL\tVRAM USED\tVRAM TOTAL\tTEMP\tPOWER")
+	for _, device := range sample.Devices {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			device.ID,
+			device.Name,
+			formatPercent(device.Utilization),
+			formatMemory(device.MemoryUsed),
+			formatMemory(device.MemoryTotal),
+			formatTemperature(device.Temperature),
+			formatPower(device.PowerDraw),
+		)
+	}
+	w.Flush()
+}
+
 // Helper functions for formatting
 
 func formatMemory(memoryMB int64) string {
@@ -158,6 +238,13 @@ func formatTemperature(temp int) string {
 	return fmt.Sprintf("%d°C", temp)
 }
 
+func formatPower(watts float64) string {
+	if watts <= 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.0f W", watts)
+}
+
 func formatBool(b bool) string {
 	if b {
 		return "✓"
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/spf13/cobra"
+)
+
+var infoModelCmd = &cobra.Command{
+	Use:   "info [MODEL_NAME_OR_PATH]",
+	Short: "Show model metadata",
+	Long:  "Show the header information and metadata of an installed model or a model file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInfoModel,
+}
+
+// modelInfoOutput is the JSON output of the models info command
+type modelInfoOutput struct {
+	Name              string                 `json:"name"`
+	Path              string                 `json:"path"`
+	Size              int64                  `json:"size"`
+	Format            string                 `json:"format"`
+	Version           string                 `json:"version,omitempty"`
+	Valid             bool                   `json:"valid"`
+	Error             string                 `json:"error,omitempty"`
+	Architecture      string                 `json:"architecture,omitempty"`
+	Parameters        int64                  `json:"parameters,omitempty"`
+	ContextLength     int                    `json:"context_length,omitempty"`
+	EmbeddingLength   int                    `json:"embedding_length,omitempty"`
+	FeedForwardLength int                    `json:"feed_forward_length,omitempty"`
+	AttentionHeads    int                    `json:"attention_heads,omitempty"`
+	AttentionHeadsKV  int                    `json:"attention_heads_kv,omitempty"`
+	Layers            int                    `json:"layers,omitempty"`
+	VocabSize         int                    `json:"vocab_size,omitempty"`
+	TokenizerModel    string                 `json:"tokenizer_model,omitempty"`
+	Quantization      string                 `json:"quantization,omitempty"`
+	QuantizationVer   int                    `json:"quantization_version,omitempty"`
+	BOSTokenID        *int                   `json:"bos_token_id,omitempty"`
+	EOSTokenID        *int                   `json:"eos_token_id,omitempty"`
+	TensorCount       int64                  `json:"tensor_count,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func init() {
+	modelsCmd.AddCommand(infoModelCmd)
+	infoModelCmd.Flags().Bool("json", false, "Output in JSON format")
+	infoModelCmd.Flags().Bool("model-card", false, "Show the model card of a model pulled from Hugging Face, or of a Hugging Face model ID")
+}
+
+func runInfoModel(cmd *cobra.Command, args []string) error {
+	if showCard, _ := cmd.Flags().GetBool("model-card"); showCard {
+		return runModelCard(args[0])
+	}
+
+	output, err := loadModelInfo(args[0])
+	if err != nil {
+		return err
+	}
+
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if jsonOutput {
+		data, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal model info: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printModelInfo(output)
+	return nil
+}
+
+// runModelCard prints the model card of a model, rendered when stdout is a
+// terminal and as markdown otherwise
+func runModelCard(name string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+	if err := manager.SetProxy(cfg.Proxy, cfg.NoProxyHosts()); err != nil {
+		return err
+	}
+
+	card, err := manager.GetModelCard(name)
+	if err != nil {
+		return fmt.Errorf("failed to get model card: %w", err)
+	}
+	card = stripFrontMatter(card)
+
+	if !isTerminal(os.Stdout) {
+		fmt.Print(card)
+		return nil
+	}
+
+	renderer, err := glamour.NewTermRenderer(glamour.WithAutoStyle(), glamour.WithWordWrap(100))
+	if err != nil {
+		return fmt.Errorf("failed to render model card: %w", err)
+	}
+	rendered, err := renderer.Render(card)
+	if err != nil {
+		return fmt.Errorf("failed to render model card: %w", err)
+	}
+	fmt.Print(rendered)
+	return nil
+}
+
+// stripFrontMatter removes the YAML metadata block Hugging Face model cards
+// start with, which is not meant to be displayed
+func stripFrontMatter(card string) string {
+	rest, ok := strings.CutPrefix(card, "---\n")
+	if !ok {
+		return card
+	}
+	if _, body, ok := strings.Cut(rest, "\n---\n"); ok {
+		return strings.TrimLeft(body, "\n")
+	}
+	return card
+}
+
+// loadModelInfo reads the header of an installed model, or of a model file
+// when name is a path
+func loadModelInfo(name string) (*modelInfoOutput, error) {
+	// Arbitrary files are read directly, anything else is looked up by name
+	path := name
+	if fileInfo, err := os.Stat(name); err != nil || fileInfo.IsDir() {
+		cfg := config.Load()
+		manager := model.NewManager(cfg.ModelsPath)
+
+		path, err = manager.GetModelPath(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model file: %w", err)
+	}
+
+	info, err := model.ValidateModel(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+
+	output := &modelInfoOutput{
+		Name:              name,
+		Path:              path,
+		Size:              fileInfo.Size(),
+		Format:            info.Format.String(),
+		Version:           info.Version,
+		Valid:             info.Valid,
+		Error:             info.Error,
+		Architecture:      info.Architecture,
+		Parameters:        info.Parameters,
+		ContextLength:     info.ContextSize,
+		EmbeddingLength:   info.EmbeddingLength,
+		FeedForwardLength: info.FeedForwardLength,
+		AttentionHeads:    info.AttentionHeads,
+		AttentionHeadsKV:  info.AttentionHeadsKV,
+		Layers:            info.Layers,
+		VocabSize:         info.VocabSize,
+		TokenizerModel:    info.TokenizerModel,
+		Quantization:      info.Quantization,
+		QuantizationVer:   info.QuantizationVersion,
+		TensorCount:       info.TensorCount,
+		Metadata:          info.Metadata,
+	}
+	// Token IDs of -1 mean the file does not define them
+	if info.Format == model.FormatGGUF && info.BOSTokenID >= 0 {
+		output.BOSTokenID = &info.BOSTokenID
+	}
+	if info.Format == model.FormatGGUF && info.EOSTokenID >= 0 {
+		output.EOSTokenID = &info.EOSTokenID
+	}
+
+	return output, nil
+}
+
+// printModelInfo prints the summary fields that are set, followed by every
+// metadata key-value pair
+func printModelInfo(info *modelInfoOutput) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	field := func(label string, value interface{}) {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				return
+			}
+		case int:
+			if v == 0 {
+				return
+			}
+		case int64:
+			if v == 0 {
+				return
+			}
+		case *int:
+			if v == nil {
+				return
+			}
+			value = *v
+		}
+		fmt.Fprintf(w, "%s:\t%v\n", label, value)
+	}
+
+	format := info.Format
+	if info.Version != "" {
+		format += " " + info.Version
+	}
+
+	field("Name", info.Name)
+	field("Path", info.Path)
+	field("Size", formatSize(info.Size))
+	field("Format", format)
+	if !info.Valid {
+		field("Error", info.Error)
+	}
+	field("Architecture", info.Architecture)
+	field("Parameters", info.Parameters)
+	field("Context length", info.ContextLength)
+	field("Embedding length", info.EmbeddingLength)
+	field("Feed-forward length", info.FeedForwardLength)
+	field("Attention heads", info.AttentionHeads)
+	if info.AttentionHeadsKV != info.AttentionHeads {
+		field("Attention heads (KV)", info.AttentionHeadsKV)
+	}
+	field("Layers", info.Layers)
+	field("Vocab size", info.VocabSize)
+	field("Tokenizer", info.TokenizerModel)
+	field("Quantization", info.Quantization)
+	field("Quantization version", info.QuantizationVer)
+	field("BOS token ID", info.BOSTokenID)
+	field("EOS token ID", info.EOSTokenID)
+	field("Tensors", info.TensorCount)
+	w.Flush()
+
+	if len(info.Metadata) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(info.Metadata))
+	for key := range info.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("\nMetadata:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", key, formatMetadataValue(info.Metadata[key]))
+	}
+	w.Flush()
+}
+
+// formatMetadataValue formats a GGUF metadata value on a single line
+func formatMetadataValue(value interface{}) string {
+	switch v := value.(type) {
+	case model.GGUFArray:
+		items := make([]string, len(v.Values))
+		for i, item := range v.Values {
+			items[i] = formatMetadataValue(item)
+		}
+		if uint64(len(v.Values)) < v.Len {
+			items = append(items, fmt.Sprintf("... (%d items)", v.Len))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case string:
+		// Long strings such as chat templates are shortened to their first line
+		line, _, multiline := strings.Cut(v, "\n")
+		if multiline || len(line) > 80 {
+			if len(line) > 80 {
+				line = line[:80]
+			}
+			return fmt.Sprintf("%q... (%d chars)", line, len(v))
+		}
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
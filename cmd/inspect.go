@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"colossus-cli/internal/model"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [FILE]",
+	Short: "Inspect the metadata and tensors of a GGUF file",
+	Long: `Print the metadata key-value pairs and tensor index of any GGUF file,
+whether or not it is an installed model.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInspect,
+}
+
+// inspectTensor is a tensor in the inspect output
+type inspectTensor struct {
+	Name   string   `json:"name"`
+	Shape  []uint64 `json:"shape"`
+	Type   string   `json:"type"`
+	Offset uint64   `json:"offset"`
+}
+
+// inspectTensorType summarizes the tensors of one type
+type inspectTensorType struct {
+	Type       string `json:"type"`
+	Tensors    int    `json:"tensors"`
+	Parameters int64  `json:"parameters"`
+}
+
+// inspectOutput is the JSON output of the inspect command
+type inspectOutput struct {
+	Path        string                 `json:"path"`
+	Size        int64                  `json:"size"`
+	Version     string                 `json:"version"`
+	TensorCount int64                  `json:"tensor_count"`
+	Parameters  int64                  `json:"parameters"`
+	TensorTypes []inspectTensorType    `json:"tensor_types"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Tensors     []inspectTensor        `json:"tensors,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().String("key", "", "Only show metadata keys matching this regular expression")
+	inspectCmd.Flags().Bool("tensors", false, "List every tensor with its shape and type")
+	inspectCmd.Flags().Bool("json", false, "Output in JSON format")
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	keyPattern, _ := cmd.Flags().GetString("key")
+	listTensors, _ := cmd.Flags().GetBool("tensors")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	var keyFilter *regexp.Regexp
+	if keyPattern != "" {
+		var err error
+		if keyFilter, err = regexp.Compile(keyPattern); err != nil {
+			return fmt.Errorf("invalid key pattern: %w", err)
+		}
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	info, err := model.ValidateModel(path)
+	if err != nil {
+		return fmt.Errorf("failed to read model: %w", err)
+	}
+	if info.Format != model.FormatGGUF {
+		return fmt.Errorf("%s is not a GGUF file (detected format: %s)", path, info.Format)
+	}
+	if !info.Valid {
+		return fmt.Errorf("invalid GGUF file: %s", info.Error)
+	}
+
+	output := inspectOutput{
+		Path:        path,
+		Size:        fileInfo.Size(),
+		Version:     info.Version,
+		TensorCount: info.TensorCount,
+		Parameters:  info.Parameters,
+		TensorTypes: summarizeTensorTypes(info.Tensors),
+		Metadata:    make(map[string]interface{}),
+	}
+	for key, value := range info.Metadata {
+		if keyFilter == nil || keyFilter.MatchString(key) {
+			output.Metadata[key] = value
+		}
+	}
+	if listTensors {
+		output.Tensors = make([]inspectTensor, len(info.Tensors))
+		for i := range info.Tensors {
+			tensor := &info.Tensors[i]
+			output.Tensors[i] = inspectTensor{
+				Name:   tensor.Name,
+				Shape:  tensor.Shape,
+				Type:   tensor.TypeName(),
+				Offset: tensor.Offset,
+			}
+		}
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printInspectOutput(&output)
+	return nil
+}
+
+// summarizeTensorTypes counts the tensors and parameters of each tensor type,
+// largest first
+func summarizeTensorTypes(tensors []model.GGUFTensorInfo) []inspectTensorType {
+	byType := make(map[string]*inspectTensorType)
+	var summary []inspectTensorType
+	for i := range tensors {
+		name := tensors[i].TypeName()
+		entry, ok := byType[name]
+		if !ok {
+			entry = &inspectTensorType{Type: name}
+			byType[name] = entry
+		}
+		entry.Tensors++
+		entry.Parameters += tensors[i].Elements()
+	}
+
+	for _, entry := range byType {
+		summary = append(summary, *entry)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Parameters > summary[j].Parameters
+	})
+	return summary
+}
+
+// printInspectOutput prints the file summary, metadata and tensors as tables
+func printInspectOutput(output *inspectOutput) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "File:\t%s\n", output.Path)
+	fmt.Fprintf(w, "Size:\t%s\n", formatSize(output.Size))
+	fmt.Fprintf(w, "Format:\tGGUF %s\n", output.Version)
+	fmt.Fprintf(w, "Tensors:\t%d\n", output.TensorCount)
+	fmt.Fprintf(w, "Parameters:\t%d\n", output.Parameters)
+	w.Flush()
+
+	keys := make([]string, 0, len(output.Metadata))
+	for key := range output.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("\nMetadata (%d keys):\n", len(keys))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  KEY\tVALUE")
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", key, formatMetadataValue(output.Metadata[key]))
+	}
+	w.Flush()
+
+	if len(output.TensorTypes) > 0 {
+		fmt.Println("\nTensor types:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tTENSORS\tPARAMETERS")
+		for _, entry := range output.TensorTypes {
+			fmt.Fprintf(w, "  %s\t%d\t%d\n", entry.Type, entry.Tensors, entry.Parameters)
+		}
+		w.Flush()
+	}
+
+	if output.Tensors != nil {
+		fmt.Println("\nTensors:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tSHAPE\tTYPE")
+		for _, tensor := range output.Tensors {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", tensor.Name, formatTensorShape(tensor.Shape), tensor.Type)
+		}
+		w.Flush()
+	}
+}
+
+// formatTensorShape formats a tensor shape, e.g. "[4096, 32000]"
+func formatTensorShape(shape []uint64) string {
+	dims := make([]string, len(shape))
+	for i, dim := range shape {
+		dims[i] = fmt.Sprintf("%d", dim)
+	}
+	return "[" + strings.Join(dims, ", ") + "]"
+}
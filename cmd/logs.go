@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the log of the running server",
+	Long: `Show the last lines of the log of a running Colossus server, which keeps
+the last 1000 lines in memory. With --follow, new lines are shown as they are
+logged until interrupted. Debug lines are only logged by a server started
+with --verbose.`,
+	Example: `  colossus logs
+  colossus logs --follow --level warn
+  colossus logs -f -n 20`,
+	Args: cobra.NoArgs,
+	RunE: runLogs,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep showing new log lines as they are logged")
+	logsCmd.Flags().IntP("lines", "n", 100, "Number of past log lines to show (0 for all kept by the server)")
+	logsCmd.Flags().String("level", "info", "Show lines logged at this level or a more severe one: debug, info, warn or error")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	follow, _ := cmd.Flags().GetBool("follow")
+	lines, _ := cmd.Flags().GetInt("lines")
+	level, _ := cmd.Flags().GetString("level")
+
+	if lines < 0 {
+		return fmt.Errorf("--lines must not be negative")
+	}
+	switch level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid level %q: must be debug, info, warn or error", level)
+	}
+
+	query := url.Values{}
+	query.Set("level", level)
+	query.Set("lines", strconv.Itoa(lines))
+	if follow {
+		query.Set("follow", "true")
+	}
+	logsURL := fmt.Sprintf("http://%s:%d/api/logs?%s", viper.GetString("host"), viper.GetInt("port"), query.Encode())
+
+	req, err := newAPIRequest(http.MethodGet, logsURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(body))
+	}
+
+	return printLogEvents(resp.Body)
+}
+
+// printLogEvents prints the log lines of a stream of server-sent events
+func printLogEvents(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var entry types.LogEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return fmt.Errorf("failed to decode log line: %w", err)
+		}
+		fmt.Println(entry.Line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	return nil
+}
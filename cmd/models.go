@@ -1,14 +1,24 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"colossus-cli/internal/config"
 	"colossus-cli/internal/model"
+	"colossus-cli/internal/registry"
+	"colossus-cli/internal/types"
 
 	"github.com/spf13/cobra"
 )
@@ -28,10 +38,83 @@ var listModelsCmd = &cobra.Command{
 var pullModelCmd = &cobra.Command{
 	Use:   "pull [MODEL_NAME]",
 	Short: "Download a model",
+	Long:  "Download a model. A quantization tag, e.g. llama3:q8_0, selects the variant to download; without one, the default quantization is pulled as llama3:latest. A model already pulled with the same content is not downloaded again. Models pushed to an OCI registry are pulled with their reference, e.g. ghcr.io/acme/llama3:q4_k_m.",
 	Args:  cobra.ExactArgs(1),
 	RunE:  runPullModel,
 }
 
+var copyModelCmd = &cobra.Command{
+	Use:   "copy [SOURCE] [DESTINATION]",
+	Short: "Copy a model under a new name",
+	Long:  "Copy a model under a new name without using more disk space. A quantization tag, e.g. llama3:q4_k_m, selects one variant of the source model.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runCopyModel,
+}
+
+var pruneModelsCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove incomplete downloads",
+	Long:  "Remove the files left behind by interrupted downloads: .part files and model files smaller than 1 MB. Models that fail validation are listed but kept.",
+	Args:  cobra.NoArgs,
+	RunE:  runPruneModels,
+}
+
+var pushModelCmd = &cobra.Command{
+	Use:   "push MODEL REGISTRY/NAMESPACE/NAME:TAG",
+	Short: "Push a model to an OCI registry",
+	Long:  "Push a model to an OCI registry such as Harbor, GHCR or ECR, from which it can be pulled with 'colossus models pull REGISTRY/NAMESPACE/NAME:TAG'. Registry credentials are read from COLOSSUS_REGISTRY_USERNAME and COLOSSUS_REGISTRY_PASSWORD.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runPushModel,
+}
+
+var exportModelCmd = &cobra.Command{
+	Use:   "export MODEL_NAME OUTPUT.tar.gz",
+	Short: "Export a model to an archive",
+	Long:  "Export a model with its manifest and options file to a gzipped tar, which 'colossus models import' installs on another machine. The archive's Colossusfile records the SHA256 digest of every file.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runExportModel,
+}
+
+var importModelCmd = &cobra.Command{
+	Use:   "import ARCHIVE.tar.gz",
+	Short: "Import a model from an archive",
+	Long:  "Import a model exported with 'colossus models export'. Every file is verified against its SHA256 digest before the model is installed, and installed models are not overwritten.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runImportModel,
+}
+
+var updateModelCmd = &cobra.Command{
+	Use:   "update [MODEL_NAME]",
+	Short: "Update models pulled from Hugging Face",
+	Long:  "Download a model again if its Hugging Face repository was modified since it was pulled, or every such model with --all. Repositories are checked at most once per --check-interval; in between, the result of the last check is used.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runUpdateModel,
+}
+
+var gcModelsCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove models that have not been used for a while",
+	Long:  "Remove the models that were neither loaded by the server nor pulled within --older-than, e.g. 30d. Models listed in ~/.colossus/pinned-models.txt, one name or path per line, are kept.",
+	Args:  cobra.NoArgs,
+	RunE:  runGCModels,
+}
+
+var statsModelCmd = &cobra.Command{
+	Use:   "stats MODEL_NAME",
+	Short: "Show the performance statistics of a model",
+	Long:  "Show the requests, tokens and generation speed of a model recorded by the running server since statistics were first kept. The server saves them to ~/.colossus/stats.json when it shuts down.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStatsModel,
+}
+
+var loadModelCmd = &cobra.Command{
+	Use:   "load MODEL_NAME",
+	Short: "Load a model into the running server",
+	Long:  "Load a model into the running server, so that its first request does not wait for it to load. A model loaded with another KV cache type than --kv-cache-type is loaded again.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runLoadModel,
+}
+
 var removeModelCmd = &cobra.Command{
 	Use:   "rm [MODEL_NAME]",
 	Short: "Remove a model",
@@ -43,41 +126,122 @@ func init() {
 	rootCmd.AddCommand(modelsCmd)
 	modelsCmd.AddCommand(listModelsCmd)
 	modelsCmd.AddCommand(pullModelCmd)
+	modelsCmd.AddCommand(copyModelCmd)
+	modelsCmd.AddCommand(pushModelCmd)
+	modelsCmd.AddCommand(exportModelCmd)
+	modelsCmd.AddCommand(importModelCmd)
+	modelsCmd.AddCommand(updateModelCmd)
 	modelsCmd.AddCommand(removeModelCmd)
+	modelsCmd.AddCommand(pruneModelsCmd)
+	modelsCmd.AddCommand(gcModelsCmd)
+	modelsCmd.AddCommand(statsModelCmd)
+	modelsCmd.AddCommand(loadModelCmd)
+	
+	pullModelCmd.Flags().Bool("verify", true, "Verify the SHA256 checksum of downloaded files when one is published")
+	pullModelCmd.Flags().Bool("force", false, "Download the model even if there does not seem to be enough free disk space")
+	pullModelCmd.Flags().Int("parallel-downloads", model.DefaultParallelDownloads(), "Download files larger than 1 GB in this many concurrent chunks")
+	pullModelCmd.Flags().BoolP("yes", "y", false, "Download without asking for confirmation of the download size")
+	pruneModelsCmd.Flags().BoolP("yes", "y", false, "Remove the files without asking for confirmation")
+	gcModelsCmd.Flags().String("older-than", "30d", "Remove models not used for this long, in days (e.g. 30d) or as a duration (e.g. 720h)")
+	gcModelsCmd.Flags().Bool("dry-run", false, "Only list the models that would be removed")
+	gcModelsCmd.Flags().BoolP("yes", "y", false, "Remove the models without asking for confirmation")
+	updateModelCmd.Flags().Bool("all", false, "Update every model pulled from Hugging Face")
+	updateModelCmd.Flags().Bool("dry-run", false, "Only print the models that would be updated")
+	updateModelCmd.Flags().Duration("check-interval", model.DefaultUpdateCheckInterval, "Check a model's repository again only after this long")
+	loadModelCmd.Flags().String("kv-cache-type", "", "Store the KV cache as f16, q8_0 (about half the memory, negligible quality loss) or q4_0 (about a quarter, noticeable quality loss)")
+	listModelsCmd.Flags().Bool("refresh", false, "Discard the cached model metadata and read every model file again")
 }
 
 func runListModels(cmd *cobra.Command, args []string) error {
 	cfg := config.Load()
-	manager := model.NewManager(cfg.ModelsPath)
+	manager, err := newModelManager(cfg)
+	if err != nil {
+		return err
+	}
+	
+	if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+		if err := manager.RefreshModelCache(); err != nil {
+			return fmt.Errorf("failed to refresh model cache: %w", err)
+		}
+	}
 	
 	models, err := manager.ListModels()
 	if err != nil {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
 
-	if len(models) == 0 {
+	aliases, err := manager.ListAliases()
+	if err != nil {
+		return fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	if len(models) == 0 && len(aliases) == 0 {
 		fmt.Println("No models found")
 		return nil
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+	fmt.Fprintln(w, "NAME\tID\tSIZE\tQUANTIZATION\tMODIFIED")
 	
 	for _, model := range models {
-		fmt.Fprintf(w, "%s\t%s\t%s\n", 
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", 
 			model.Name, 
+			shortDigest(model.Digest),
 			formatSize(model.Size), 
+			model.Quantization,
 			model.ModifiedAt.Format("2006-01-02 15:04:05"))
 	}
 	
+	// Aliases are listed with the size and date of the model they refer to
+	for _, alias := range sortedKeys(aliases) {
+		size, quantization, modified := "-", "-", "-"
+		if path, err := manager.GetModelPath(alias); err == nil {
+			if info, err := os.Stat(path); err == nil {
+				size = formatSize(info.Size())
+				modified = info.ModTime().Format("2006-01-02 15:04:05")
+			}
+			quantization = "unknown"
+			if modelInfo, err := model.ValidateModel(path); err == nil && modelInfo.Quantization != "" {
+				quantization = modelInfo.Quantization
+			}
+		}
+		fmt.Fprintf(w, "%s (alias)\t-\t%s\t%s\t%s\n", alias, size, quantization, modified)
+	}
+	
 	return w.Flush()
 }
 
 func runPullModel(cmd *cobra.Command, args []string) error {
+	verify, _ := cmd.Flags().GetBool("verify")
+	force, _ := cmd.Flags().GetBool("force")
+	parallel, _ := cmd.Flags().GetInt("parallel-downloads")
+	yes, _ := cmd.Flags().GetBool("yes")
+	return pullModel(cmd.Context(), args[0], verify, force, yes, parallel)
+}
+
+// pullModel downloads a model, showing a progress bar. Unless forced, models
+// that do not fit on the disk are not downloaded. Large files are downloaded
+// in parallel concurrent chunks. In a terminal, the size of a Hugging Face
+// download is confirmed first unless yes is set.
+func pullModel(ctx context.Context, modelName string, verify, force, yes bool, parallel int) error {
 	cfg := config.Load()
-	manager := model.NewManager(cfg.ModelsPath)
+	manager, err := newModelManager(cfg)
+	if err != nil {
+		return err
+	}
+	manager.SetVerifyChecksums(verify)
+	manager.SetCheckDiskSpace(!force)
+	manager.SetParallelDownloads(parallel)
+	if !yes && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		manager.SetConfirmDownload(func(fileName string, size int64) error {
+			return confirmDownload(os.Stdin, os.Stdout, fileName, size)
+		})
+	}
+	
+	// Stop the download and remove its partial file on Ctrl-C
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	
-	modelName := args[0]
 	fmt.Printf("Pulling model '%s'...\n", modelName)
 	
 	// Create progress callback with visual progress bar
@@ -86,8 +250,15 @@ func runPullModel(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	
-	if err := manager.PullModelWithProgress(modelName, progressCallback); err != nil {
+	if err := manager.PullModelWithProgress(ctx, modelName, progressCallback); err != nil {
+		if errors.Is(err, registry.ErrDownloadDeclined) {
+			fmt.Println("Nothing downloaded")
+			return nil
+		}
 		fmt.Println() // New line after progress bar
+		if ctx.Err() != nil {
+			return errors.New("download canceled")
+		}
 		return fmt.Errorf("failed to pull model: %w", err)
 	}
 	
@@ -96,10 +267,278 @@ func runPullModel(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func runRemoveModel(cmd *cobra.Command, args []string) error {
+// confirmDownload asks whether to download a file of the given size,
+// returning registry.ErrDownloadDeclined unless the answer is yes
+func confirmDownload(in io.Reader, out io.Writer, fileName string, size int64) error {
+	fmt.Fprintf(out, "Selected %s\n", fileName)
+	if size > 0 {
+		fmt.Fprintf(out, "Download %s? [y/N]: ", formatSize(size))
+	} else {
+		fmt.Fprint(out, "Download it (size unknown)? [y/N]: ")
+	}
+	
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return registry.ErrDownloadDeclined
+	}
+	return nil
+}
+
+func runUpdateModel(cmd *cobra.Command, args []string) error {
+	all, _ := cmd.Flags().GetBool("all")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	interval, _ := cmd.Flags().GetDuration("check-interval")
+	if all == (len(args) == 1) {
+		return fmt.Errorf("specify either a model name or --all")
+	}
+	
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+	if err := manager.SetProxy(cfg.Proxy, cfg.NoProxyHosts()); err != nil {
+		return err
+	}
+	manager.SetUpdateCheckInterval(interval)
+	
+	refs := args
+	if all {
+		var err error
+		if refs, err = manager.PulledModels(); err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+		if len(refs) == 0 {
+			fmt.Println("No models found")
+			return nil
+		}
+	}
+	
+	progressCallback := func(progress model.DownloadProgress) error {
+		showProgressBar(progress)
+		return nil
+	}
+	
+	// Stop the download and remove its partial file on Ctrl-C
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	
+	updated := 0
+	for _, ref := range refs {
+		update, err := manager.CheckForUpdate(ref)
+		if err != nil {
+			// With --all, models that cannot be checked, e.g. ones pulled
+			// from an OCI registry, are skipped
+			if all {
+				fmt.Printf("Skipping '%s': %v\n", ref, err)
+				continue
+			}
+			return fmt.Errorf("failed to check for updates: %w", err)
+		}
+		if !update {
+			fmt.Printf("'%s' is up to date\n", ref)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("'%s' would be updated\n", ref)
+			continue
+		}
+		
+		fmt.Printf("Updating model '%s'...\n", ref)
+		_, err = manager.UpdateModel(ctx, ref, progressCallback)
+		fmt.Println() // New line after progress bar
+		if ctx.Err() != nil {
+			return errors.New("download canceled")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update model: %w", err)
+		}
+		fmt.Printf("✅ Successfully updated model '%s'\n", ref)
+		updated++
+	}
+	
+	if all && !dryRun {
+		fmt.Printf("Updated %d of %d models\n", updated, len(refs))
+	}
+	return nil
+}
+
+func runCopyModel(cmd *cobra.Command, args []string) error {
 	cfg := config.Load()
 	manager := model.NewManager(cfg.ModelsPath)
 	
+	if err := manager.CopyModel(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to copy model: %w", err)
+	}
+	
+	fmt.Printf("Successfully copied model '%s' to '%s'\n", args[0], args[1])
+	return nil
+}
+
+func runPushModel(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+	
+	fmt.Printf("Pushing model '%s' to '%s'...\n", args[0], args[1])
+	if err := manager.PushModel(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to push model: %w", err)
+	}
+	
+	fmt.Printf("Successfully pushed model '%s' to '%s'\n", args[0], args[1])
+	return nil
+}
+
+func runExportModel(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	fmt.Printf("Exporting model '%s' to '%s'...\n", args[0], args[1])
+	if err := manager.ExportModel(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to export model: %w", err)
+	}
+
+	fmt.Printf("Successfully exported model '%s' to '%s'\n", args[0], args[1])
+	return nil
+}
+
+func runImportModel(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+
+	fmt.Printf("Importing model from '%s'...\n", args[0])
+	ref, err := manager.ImportModel(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to import model: %w", err)
+	}
+
+	fmt.Printf("Successfully imported model '%s'\n", ref)
+	return nil
+}
+
+func runPruneModels(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+	
+	invalid, err := manager.FindInvalidModels()
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		fmt.Printf("Found %d models that fail validation (not removed):\n", len(invalid))
+		for _, path := range invalid {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	
+	paths, err := manager.FindIncompleteDownloads()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("No incomplete downloads found")
+		return nil
+	}
+	
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	fmt.Printf("Found %d incomplete downloads totalling %s:\n", len(paths), formatSize(total))
+	for _, path := range paths {
+		fmt.Printf("  %s\n", path)
+	}
+	
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		fmt.Print("Remove them? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Nothing removed")
+			return nil
+		}
+	}
+	
+	for _, path := range paths {
+		if err := manager.RemoveModelFile(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	
+	fmt.Printf("Successfully removed %d incomplete downloads\n", len(paths))
+	return nil
+}
+
+func runGCModels(cmd *cobra.Command, args []string) error {
+	olderThan, _ := cmd.Flags().GetString("older-than")
+	keepDays, err := parseDays(olderThan)
+	if err != nil {
+		return err
+	}
+	
+	cfg := config.Load()
+	manager := model.NewManager(cfg.ModelsPath)
+	
+	unused, err := manager.FindUnusedModels(keepDays)
+	if err != nil {
+		return err
+	}
+	if len(unused) == 0 {
+		fmt.Printf("No models unused for %d days found\n", keepDays)
+		return nil
+	}
+	
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tLAST ACCESSED")
+	for _, m := range unused {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Path, formatSize(m.Size), m.LastAccessed.Format("2006-01-02 15:04:05"))
+		total += m.Size
+	}
+	w.Flush()
+	fmt.Printf("Removing %d models would free %s\n", len(unused), formatSize(total))
+	
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return nil
+	}
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		fmt.Print("Remove them? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Nothing removed")
+			return nil
+		}
+	}
+	
+	removed, freed, err := manager.GarbageCollect(keepDays)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Successfully removed %d files, freeing %s\n", len(removed), formatSize(freed))
+	return nil
+}
+
+// parseDays parses a number of days such as "30d", or a duration such as
+// "720h" rounded down to whole days
+func parseDays(s string) (int, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %q", s)
+		}
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: expected days, e.g. 30d, or a duration, e.g. 720h", s)
+	}
+	return int(d / (24 * time.Hour)), nil
+}
+
+func runRemoveModel(cmd *cobra.Command, args []string) error {
+	cfg := config.Load()
+	manager, err := newModelManager(cfg)
+	if err != nil {
+		return err
+	}
+	
 	modelName := args[0]
 	
 	if err := manager.RemoveModel(modelName); err != nil {
@@ -110,6 +549,80 @@ func runRemoveModel(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runLoadModel(cmd *cobra.Command, args []string) error {
+	kvCacheType, _ := cmd.Flags().GetString("kv-cache-type")
+	
+	var resp types.LoadResponse
+	req := types.LoadRequest{Model: args[0], KVCacheType: kvCacheType}
+	if err := postAPIRequest("/api/load", req, &resp); err != nil {
+		return err
+	}
+	
+	fmt.Printf("Loaded model '%s' (context %d, %d GPU layers", resp.Model, resp.ContextSize, resp.GPULayers)
+	if resp.KVCacheType != "" {
+		fmt.Printf(", %s KV cache", resp.KVCacheType)
+	}
+	fmt.Println(")")
+	return nil
+}
+
+func runStatsModel(cmd *cobra.Command, args []string) error {
+	var stats types.ModelStats
+	if err := getAPIRequest("/api/stats/"+url.PathEscape(args[0]), &stats); err != nil {
+		return err
+	}
+	
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Model:\t%s\n", stats.Model)
+	fmt.Fprintf(w, "Requests:\t%d\n", stats.RequestCount)
+	fmt.Fprintf(w, "Errors:\t%d\n", stats.ErrorCount)
+	fmt.Fprintf(w, "Prompt tokens:\t%d\n", stats.TotalPromptTokens)
+	fmt.Fprintf(w, "Generated tokens:\t%d\n", stats.TotalTokensGenerated)
+	fmt.Fprintf(w, "Generation time:\t%s\n", stats.TotalDuration.Round(time.Millisecond))
+	if stats.RequestCount > 0 {
+		average := stats.TotalDuration / time.Duration(stats.RequestCount)
+		fmt.Fprintf(w, "Average time per request:\t%s\n", average.Round(time.Millisecond))
+		fmt.Fprintf(w, "Average tokens per request:\t%.1f\n", float64(stats.TotalTokensGenerated)/float64(stats.RequestCount))
+	}
+	fmt.Fprintf(w, "Average speed:\t%.2f tokens/s\n", stats.AverageTokensPerSecond)
+	return w.Flush()
+}
+
+// shortDigest returns the first 12 hex digits of a model digest, or "-" for
+// models that were not pulled
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if digest == "" {
+		return "-"
+	}
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return digest
+}
+
+// newModelManager creates a model manager storing models in the configured
+// models backend
+func newModelManager(cfg *config.Config) (*model.Manager, error) {
+	manager := model.NewManager(cfg.ModelsPath)
+	if err := manager.SetProxy(cfg.Proxy, cfg.NoProxyHosts()); err != nil {
+		return nil, err
+	}
+	if cfg.ModelsBackend == "s3" {
+		storage, err := model.NewS3Backend(context.Background(), model.S3Options{
+			Bucket:   cfg.S3Bucket,
+			Prefix:   cfg.S3Prefix,
+			Region:   cfg.S3Region,
+			Endpoint: cfg.S3Endpoint,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure S3 storage: %w", err)
+		}
+		manager.SetStorage(storage)
+	}
+	return manager, nil
+}
+
 func formatSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/registry"
+	"colossus-cli/internal/types"
+)
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "30d", want: 30},
+		{value: "0d", want: 0},
+		{value: "720h", want: 30},
+		{value: "36h", want: 1},
+		{value: "-1d", wantErr: true},
+		{value: "thirty", wantErr: true},
+		{value: "-48h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDays(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDays(%q) = %d, %v, want %d, wantErr %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStripFrontMatter(t *testing.T) {
+	tests := []struct {
+		card string
+		want string
+	}{
+		{card: "---\nlicense: mit\ntags:\n- gguf\n---\n\n# Model\n", want: "# Model\n"},
+		{card: "# Model\n---\n", want: "# Model\n---\n"},
+		{card: "---\nlicense: mit\n", want: "---\nlicense: mit\n"},
+		{card: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := stripFrontMatter(tt.card); got != tt.want {
+			t.Errorf("stripFrontMatter(%q) = %q, want %q", tt.card, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmDownload(t *testing.T) {
+	tests := []struct {
+		name       string
+		answer     string
+		size       int64
+		wantPrompt string
+		wantErr    bool
+	}{
+		{name: "yes", answer: "y\n", size: 4509715660, wantPrompt: "Download 4.2 GB? [y/N]: "},
+		{name: "yes in full", answer: " YES \n", size: 4509715660, wantPrompt: "Download 4.2 GB? [y/N]: "},
+		{name: "no", answer: "n\n", size: 4509715660, wantErr: true},
+		{name: "default", answer: "\n", size: 4509715660, wantErr: true},
+		{name: "end of input", answer: "", size: 4509715660, wantErr: true},
+		{name: "unknown size", answer: "y\n", wantPrompt: "Download it (size unknown)? [y/N]: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := confirmDownload(strings.NewReader(tt.answer), &out, "llama3.Q4_K_M.gguf", tt.size)
+			if tt.wantErr != errors.Is(err, registry.ErrDownloadDeclined) {
+				t.Errorf("confirmDownload() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(out.String(), "Selected llama3.Q4_K_M.gguf\n") || !strings.HasSuffix(out.String(), tt.wantPrompt) {
+				t.Errorf("output = %q, want the file and the prompt %q", out.String(), tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	// Pulls with redirected input or output proceed without asking
+	for _, f := range []*os.File{r, w, file, devNull} {
+		if isTerminal(f) {
+			t.Errorf("isTerminal(%s) = true, want false", f.Name())
+		}
+	}
+}
+
+func TestModelsStatsCommand(t *testing.T) {
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/stats/tinyllama" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(types.ErrorResponse{Error: "no statistics for model " + strings.TrimPrefix(r.URL.Path, "/api/stats/")})
+			return
+		}
+		json.NewEncoder(w).Encode(types.ModelStats{
+			Model:                  "tinyllama",
+			RequestCount:           4,
+			TotalTokensGenerated:   200,
+			TotalPromptTokens:      40,
+			TotalDuration:          10 * time.Second,
+			AverageTokensPerSecond: 20,
+			ErrorCount:             1,
+		})
+	})
+
+	output, err := executeCommand(t, append([]string{"models", "stats", "tinyllama"}, flags...)...)
+	if err != nil {
+		t.Fatalf("models stats: %v", err)
+	}
+	for _, want := range []string{
+		"Requests:                    4",
+		"Errors:                      1",
+		"Prompt tokens:               40",
+		"Generated tokens:            200",
+		"Generation time:             10s",
+		"Average time per request:    2.5s",
+		"Average tokens per request:  50.0",
+		"Average speed:               20.00 tokens/s",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output = %q, want %q", output, want)
+		}
+	}
+
+	_, err = executeCommand(t, append([]string{"models", "stats", "mistral"}, flags...)...)
+	if err == nil || !strings.Contains(err.Error(), "no statistics for model mistral") {
+		t.Errorf("error = %v, want no statistics for mistral", err)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// profileTypes are the profiles served by net/http/pprof
+var profileTypes = []string{"profile", "heap", "allocs", "goroutine", "block", "mutex", "threadcreate"}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile [-- PPROF_ARGS...]",
+	Short: "Profile a running server with go tool pprof",
+	Long: `Profile a running Colossus server started with --pprof-addr by running
+go tool pprof against its pprof endpoint. Arguments after -- are passed to
+pprof, e.g. "colossus profile --type heap -- -top". Requires the Go toolchain.`,
+	RunE: runProfile,
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+
+	profileCmd.Flags().String("pprof-addr", "localhost:6060", "Address the server serves pprof profiles on (its --pprof-addr)")
+	profileCmd.Flags().String("type", "profile", "Profile to collect: profile (CPU), heap, allocs, goroutine, block, mutex or threadcreate")
+	profileCmd.Flags().Int("seconds", 30, "Duration of the CPU profile in seconds")
+}
+
+func runProfile(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("pprof-addr")
+	if !cmd.Flags().Changed("pprof-addr") && viper.GetString("pprof_addr") != "" {
+		addr = viper.GetString("pprof_addr")
+	}
+	profileType, _ := cmd.Flags().GetString("type")
+	seconds, _ := cmd.Flags().GetInt("seconds")
+
+	if !slices.Contains(profileTypes, profileType) {
+		return fmt.Errorf("unknown profile type %q", profileType)
+	}
+
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		return errors.New("go tool pprof is required: install Go from https://go.dev/dl/")
+	}
+
+	url := fmt.Sprintf("http://%s/debug/pprof/%s", addr, profileType)
+	if profileType == "profile" {
+		url += fmt.Sprintf("?seconds=%d", seconds)
+	}
+
+	pprofArgs := append([]string{"tool", "pprof"}, args...)
+	pprofArgs = append(pprofArgs, url)
+
+	pprof := exec.CommandContext(cmd.Context(), goTool, pprofArgs...)
+	pprof.Stdin = os.Stdin
+	pprof.Stdout = os.Stdout
+	pprof.Stderr = os.Stderr
+	return pprof.Run()
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeGoTool puts a go command on PATH that writes its arguments to the
+// returned file
+func fakeGoTool(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake go tool is a shell script")
+	}
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return argsFile
+}
+
+func TestProfileCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "CPU profile",
+			args: []string{"profile", "--seconds", "5"},
+			want: []string{"tool", "pprof", "http://localhost:6060/debug/pprof/profile?seconds=5"},
+		},
+		{
+			name: "heap profile with pprof arguments",
+			args: []string{"profile", "--type", "heap", "--pprof-addr", "127.0.0.1:7070", "--", "-top"},
+			want: []string{"tool", "pprof", "-top", "http://127.0.0.1:7070/debug/pprof/heap"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := fakeGoTool(t)
+
+			if _, err := executeCommand(t, tt.args...); err != nil {
+				t.Fatalf("profile: %v", err)
+			}
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Fields(string(data)); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("go called with %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileCommandErrors(t *testing.T) {
+	fakeGoTool(t)
+	if _, err := executeCommand(t, "profile", "--type", "cpu"); err == nil || !strings.Contains(err.Error(), `unknown profile type "cpu"`) {
+		t.Errorf("err = %v, want an unknown profile type", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := executeCommand(t, "profile"); err == nil || !strings.Contains(err.Error(), "go tool pprof is required") {
+		t.Errorf("err = %v, want the Go toolchain required", err)
+	}
+}
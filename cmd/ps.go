@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// psWatchInterval is how often --watch refreshes the list
+const psWatchInterval = 2 * time.Second
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List the models loaded by the server",
+	Long:  "List the models loaded by a running Colossus server with their memory usage, context size, requests in progress, queued requests and idle time",
+	Args:  cobra.NoArgs,
+	RunE:  runPs,
+}
+
+func init() {
+	rootCmd.AddCommand(psCmd)
+	psCmd.Flags().Bool("watch", false, "Refresh the list every 2 seconds")
+}
+
+func runPs(cmd *cobra.Command, args []string) error {
+	watch, _ := cmd.Flags().GetBool("watch")
+	if !watch {
+		models, err := fetchLoadedModels()
+		if err != nil {
+			return err
+		}
+		printLoadedModels(models)
+		return nil
+	}
+
+	ticker := time.NewTicker(psWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		models, err := fetchLoadedModels()
+
+		// Clear the screen and move the cursor to the top left
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Every %s: colossus ps  %s\n\n", psWatchInterval, time.Now().Format(time.TimeOnly))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		} else {
+			printLoadedModels(models)
+		}
+
+		<-ticker.C
+	}
+}
+
+// fetchLoadedModels lists the loaded models using the running server
+func fetchLoadedModels() ([]types.RunningModel, error) {
+	url := fmt.Sprintf("http://%s:%d/api/ps", viper.GetString("host"), viper.GetInt("port"))
+
+	req, err := newAPIRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", string(body))
+	}
+
+	var psResp types.ProcessResponse
+	if err := json.NewDecoder(resp.Body).Decode(&psResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return psResp.Models, nil
+}
+
+// printLoadedModels prints the loaded models as a table
+func printLoadedModels(models []types.RunningModel) {
+	if len(models) == 0 {
+		fmt.Println("No models loaded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tSIZE\tCONTEXT\tREQUESTS\tQUEUE\tIDLE\tCIRCUIT")
+	for _, m := range models {
+		idle := "-"
+		if m.ActiveRequests == 0 && m.QueuedRequests == 0 {
+			idle = formatIdleTime(time.Duration(m.IdleSeconds) * time.Second)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n", m.Name, formatSize(m.Size), m.ContextSize, m.ActiveRequests, m.QueuedRequests, idle, m.CircuitState)
+	}
+	w.Flush()
+}
+
+// formatIdleTime formats an idle time, e.g. "45s" or "3m12s"
+func formatIdleTime(d time.Duration) string {
+	if d < time.Second {
+		return "0s"
+	}
+	return formatDuration(d)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+func TestPsCommand(t *testing.T) {
+	flags := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ps" {
+			t.Errorf("request to %s, want /api/ps", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(types.ProcessResponse{Models: []types.RunningModel{
+			{Name: "llama2", Size: 4 << 30, ContextSize: 4096, ActiveRequests: 2},
+			{Name: "tinyllama", Size: 600 << 20, ContextSize: 2048, IdleSeconds: 192},
+		}})
+	})
+
+	output, err := executeCommand(t, append([]string{"ps"}, flags...)...)
+	if err != nil {
+		t.Fatalf("ps: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "MODEL") {
+		t.Fatalf("output = %q, want a header and one row per model", output)
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "llama2" || fields[len(fields)-1] != "-" {
+		t.Errorf("busy model row = %q, want no idle time", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "tinyllama" || fields[len(fields)-1] != formatIdleTime(192*time.Second) {
+		t.Errorf("idle model row = %q, want its idle time", lines[2])
+	}
+}
+
+func TestPsCommandNoModels(t *testing.T) {
+	flags := newTestAPIServer(t)
+
+	output, err := executeCommand(t, append([]string{"ps"}, flags...)...)
+	if err != nil {
+		t.Fatalf("ps: %v", err)
+	}
+	if strings.TrimSpace(output) != "No models loaded" {
+		t.Errorf("output = %q, want No models loaded", output)
+	}
+}
@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"colossus-cli/internal/logging"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -27,22 +30,41 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.colossus.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.colossus/config.yaml)")
 	rootCmd.PersistentFlags().String("host", "127.0.0.1", "Host to bind the server to")
 	rootCmd.PersistentFlags().Int("port", 11434, "Port to bind the server to")
 	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log output format: text or json")
+	rootCmd.PersistentFlags().String("models-backend", "local", "Where models are stored: local (the models directory) or s3")
+	rootCmd.PersistentFlags().String("s3-bucket", "", "S3 bucket of the s3 models backend")
+	rootCmd.PersistentFlags().String("s3-prefix", "", "Key prefix of the models in the S3 bucket")
+	rootCmd.PersistentFlags().String("s3-region", "", "Region of the S3 bucket (defaults to the AWS configuration)")
+	rootCmd.PersistentFlags().String("s3-endpoint", "", "Endpoint of an S3-compatible service, e.g. http://localhost:9000 for MinIO")
+	rootCmd.PersistentFlags().String("proxy", "", "Proxy URL of Hugging Face requests and model downloads (defaults to HTTP_PROXY and HTTPS_PROXY)")
+	rootCmd.PersistentFlags().String("no-proxy", "", "Comma-separated hosts to reach without the proxy (defaults to NO_PROXY)")
 
 	// Bind flags to viper
 	viper.BindPFlag("host", rootCmd.PersistentFlags().Lookup("host"))
 	viper.BindPFlag("port", rootCmd.PersistentFlags().Lookup("port"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("log_format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("models_backend", rootCmd.PersistentFlags().Lookup("models-backend"))
+	viper.BindPFlag("s3_bucket", rootCmd.PersistentFlags().Lookup("s3-bucket"))
+	viper.BindPFlag("s3_prefix", rootCmd.PersistentFlags().Lookup("s3-prefix"))
+	viper.BindPFlag("s3_region", rootCmd.PersistentFlags().Lookup("s3-region"))
+	viper.BindPFlag("s3_endpoint", rootCmd.PersistentFlags().Lookup("s3-endpoint"))
+	viper.BindPFlag("proxy", rootCmd.PersistentFlags().Lookup("proxy"))
+	viper.BindPFlag("no_proxy", rootCmd.PersistentFlags().Lookup("no-proxy"))
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
+	} else if path := defaultConfigFile(); fileExists(path) {
+		viper.SetConfigFile(path)
 	} else {
+		// Fall back to the original location, $HOME/.colossus.yaml
 		home, err := os.UserHomeDir()
 		cobra.CheckErr(err)
 
@@ -53,7 +75,28 @@ func initConfig() {
 
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err == nil {
+	err := viper.ReadInConfig()
+	cobra.CheckErr(logging.Setup(viper.GetString("log_format")))
+	if err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	} else if cfgFile != "" && fileExists(cfgFile) {
+		// A config file given explicitly must be usable. It may not exist
+		// yet when the config command is about to create it.
+		cobra.CheckErr(fmt.Errorf("failed to read config file: %w", err))
+	}
+}
+
+// defaultConfigFile returns the config file written by the config command
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
 	}
+	return filepath.Join(home, ".colossus", "config.yaml")
+}
+
+// fileExists reports whether path is an existing regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
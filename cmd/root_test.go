@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"colossus-cli/internal/api"
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// executeCommand runs the CLI with args and returns what it printed to
+// stdout. Flags are reset afterwards so that commands can run again.
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	defer resetFlags(rootCmd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		output <- buf.String()
+	}()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	w.Close()
+	return <-output, err
+}
+
+// setStdin replaces stdin with text for the rest of the test
+func setStdin(t *testing.T, text string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(text)
+		w.Close()
+	}()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+// resetFlags restores the flags of cmd and its subcommands to their defaults
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// newMockServer starts a server answering with handler, and returns the
+// flags that point the CLI at it
+func newMockServer(t *testing.T, handler http.HandlerFunc) []string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []string{"--host", host, "--port", port}
+}
+
+// newTestAPIServer starts an API server running the simulated engine with
+// the named models installed, and returns the flags that point the CLI at it
+func newTestAPIServer(t *testing.T, models ...string) []string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("COLOSSUS_INFERENCE_ENGINE", "simulated")
+
+	cfg := &config.Config{ModelsPath: filepath.Join(t.TempDir(), "models")}
+	if err := os.MkdirAll(cfg.ModelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range models {
+		if err := os.WriteFile(filepath.Join(cfg.ModelsPath, name+".gguf"), []byte("GGUF"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := api.NewServer(cfg, model.NewManager(cfg.ModelsPath))
+	return newMockServer(t, server.Router().ServeHTTP)
+}
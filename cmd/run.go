@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"colossus-cli/internal/types"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [MODEL_NAME]",
+	Short: "Run a model in an interactive prompt",
+	Long: `Run a model in an interactive prompt. The conversation context is kept
+between prompts until it is reset with /clear.
+
+End a line with \ to continue the prompt on the next line. Previous prompts
+are available with the arrow keys and Ctrl-R, and are kept in
+~/.colossus/history. Type /? for the list of commands.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRun,
+}
+
+// replHelp lists the commands available in the run prompt
+const replHelp = `Available commands:
+  /set OPTION VALUE   Set a generation option: temperature, top_p, top_k,
+                      num_predict, repeat_penalty or seed
+  /show info          Show model information
+  /show options       Show the options that have been set
+  /clear              Clear the conversation context
+  /bye                Exit (or press Ctrl-D)
+  /?, /help           Show this help`
+
+// replCommand is a slash command entered in the run prompt
+type replCommand struct {
+	name string
+	args []string
+}
+
+// replSession is the state of a run prompt
+type replSession struct {
+	model   string
+	url     string
+	options types.Options
+	context []int
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	historyPath, err := replHistoryPath()
+	if err != nil {
+		return err
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:            ">>> ",
+		HistoryFile:       historyPath,
+		HistorySearchFold: true,
+		InterruptPrompt:   "^C",
+		EOFPrompt:         "/bye",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start prompt: %w", err)
+	}
+	defer rl.Close()
+
+	session := &replSession{
+		model: args[0],
+		url:   fmt.Sprintf("http://%s:%d/api/generate", viper.GetString("host"), viper.GetInt("port")),
+	}
+
+	fmt.Printf("Running model '%s' (type /? for help, /bye or Ctrl-D to exit)\n", session.model)
+
+	for {
+		input, err := readReplInput(rl)
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		if command, ok := parseReplCommand(input); ok {
+			exit, err := session.handleCommand(command)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			if exit {
+				return nil
+			}
+			continue
+		}
+
+		if err := session.generate(input); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// replHistoryPath returns the prompt history file, ~/.colossus/history
+func replHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".colossus")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// readReplInput reads one prompt. Lines ending with a backslash continue on
+// the next line.
+func readReplInput(rl *readline.Instance) (string, error) {
+	defer rl.SetPrompt(">>> ")
+
+	var lines []string
+	for {
+		line, err := rl.Readline()
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.HasSuffix(line, `\`) {
+			lines = append(lines, line)
+			return strings.Join(lines, "\n"), nil
+		}
+
+		lines = append(lines, strings.TrimSuffix(line, `\`))
+		rl.SetPrompt("... ")
+	}
+}
+
+// parseReplCommand parses a line starting with a slash as a command
+func parseReplCommand(line string) (replCommand, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return replCommand{}, false
+	}
+
+	return replCommand{
+		name: strings.ToLower(strings.TrimPrefix(fields[0], "/")),
+		args: fields[1:],
+	}, true
+}
+
+// handleCommand runs a slash command, reporting whether the session should end
+func (s *replSession) handleCommand(command replCommand) (bool, error) {
+	switch command.name {
+	case "bye", "exit":
+		return true, nil
+
+	case "clear":
+		s.context = nil
+		fmt.Println("Cleared conversation context")
+		return false, nil
+
+	case "set":
+		if len(command.args) != 2 {
+			return false, fmt.Errorf("usage: /set OPTION VALUE")
+		}
+		if err := setReplOption(&s.options, command.args[0], command.args[1]); err != nil {
+			return false, err
+		}
+		fmt.Printf("Set %s to %s\n", command.args[0], command.args[1])
+		return false, nil
+
+	case "show":
+		if len(command.args) != 1 {
+			return false, fmt.Errorf("usage: /show info|options")
+		}
+		switch command.args[0] {
+		case "info":
+			info, err := loadModelInfo(s.model)
+			if err != nil {
+				return false, err
+			}
+			printModelInfo(info)
+		case "options":
+			data, _ := json.MarshalIndent(s.options, "", "  ")
+			fmt.Println(string(data))
+		default:
+			return false, fmt.Errorf("unknown /show topic %q: must be info or options", command.args[0])
+		}
+		return false, nil
+
+	case "?", "help":
+		fmt.Println(replHelp)
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("unknown command /%s, type /? for help", command.name)
+	}
+}
+
+// setReplOption sets a generation option from its name and text value
+func setReplOption(options *types.Options, name, value string) error {
+	errInvalid := fmt.Errorf("invalid value for %s: %q", name, value)
+
+	switch name {
+	case "temperature", "top_p", "repeat_penalty":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return errInvalid
+		}
+		switch name {
+		case "temperature":
+			options.Temperature = v
+		case "top_p":
+			options.TopP = v
+		case "repeat_penalty":
+			options.RepeatPenalty = float32(v)
+		}
+	case "top_k", "num_predict":
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return errInvalid
+		}
+		if name == "top_k" {
+			options.TopK = v
+		} else {
+			options.NumPredict = v
+		}
+	case "seed":
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errInvalid
+		}
+		options.Seed = &v
+	default:
+		return fmt.Errorf("unknown option %q", name)
+	}
+	return nil
+}
+
+// generate streams the response to a prompt, continuing the conversation
+// from the previous response's context. Ctrl-C stops the response.
+func (s *replSession) generate(prompt string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	options := s.options
+	req := types.GenerateRequest{
+		Model:   s.model,
+		Prompt:  prompt,
+		Stream:  true,
+		Options: &options,
+		Context: s.context,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := newAPIRequest(http.MethodPost, s.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(body))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		// Stream errors arrive as a final chunk with an error message
+		var chunk struct {
+			types.GenerateResponse
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			fmt.Println()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if chunk.Error != "" {
+			fmt.Println()
+			return errors.New(chunk.Error)
+		}
+
+		fmt.Print(chunk.Response)
+
+		if chunk.Done {
+			if len(chunk.Context) > 0 {
+				s.context = chunk.Context
+			}
+			break
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
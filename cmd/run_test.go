@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"colossus-cli/internal/types"
+)
+
+func TestParseReplCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   replCommand
+		wantOK bool
+	}{
+		{name: "prompt", line: "tell me about llamas", wantOK: false},
+		{name: "empty line", line: "   ", wantOK: false},
+		{name: "command", line: "/bye", want: replCommand{name: "bye", args: []string{}}, wantOK: true},
+		{name: "command with arguments", line: "/set temperature 0.2", want: replCommand{name: "set", args: []string{"temperature", "0.2"}}, wantOK: true},
+		{name: "upper case command", line: "  /SHOW info ", want: replCommand{name: "show", args: []string{"info"}}, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseReplCommand(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %t, want %t", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("command = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetReplOption(t *testing.T) {
+	seed := int64(42)
+
+	tests := []struct {
+		name    string
+		option  string
+		value   string
+		want    types.Options
+		wantErr bool
+	}{
+		{name: "temperature", option: "temperature", value: "0.2", want: types.Options{Temperature: 0.2}},
+		{name: "top_p", option: "top_p", value: "0.9", want: types.Options{TopP: 0.9}},
+		{name: "repeat_penalty", option: "repeat_penalty", value: "1.5", want: types.Options{RepeatPenalty: 1.5}},
+		{name: "top_k", option: "top_k", value: "40", want: types.Options{TopK: 40}},
+		{name: "num_predict", option: "num_predict", value: "128", want: types.Options{NumPredict: 128}},
+		{name: "seed", option: "seed", value: "42", want: types.Options{Seed: &seed}},
+		{name: "negative temperature", option: "temperature", value: "-1", wantErr: true},
+		{name: "fractional top_k", option: "top_k", value: "1.5", wantErr: true},
+		{name: "invalid seed", option: "seed", value: "abc", wantErr: true},
+		{name: "unknown option", option: "mirostat", value: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options types.Options
+			err := setReplOption(&options, tt.option, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(options, tt.want) {
+				t.Errorf("options = %+v, want %+v", options, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplSessionKeepsContext(t *testing.T) {
+	var requests []types.GenerateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		requests = append(requests, req)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(types.GenerateResponse{Response: "Hello"})
+		encoder.Encode(types.GenerateResponse{Response: "!", Done: true, Context: []int{len(requests), 2, 3}})
+	}))
+	defer srv.Close()
+
+	s := &replSession{model: "tinyllama", url: srv.URL}
+	for _, prompt := range []string{"hello", "again"} {
+		if err := s.generate(prompt); err != nil {
+			t.Fatalf("generate(%q): %v", prompt, err)
+		}
+	}
+
+	// Each prompt continues from the context of the previous response
+	if requests[0].Context != nil {
+		t.Errorf("first request context = %v, want none", requests[0].Context)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(requests[1].Context, want) {
+		t.Errorf("second request context = %v, want %v", requests[1].Context, want)
+	}
+	if want := []int{2, 2, 3}; !reflect.DeepEqual(s.context, want) {
+		t.Errorf("session context = %v, want %v", s.context, want)
+	}
+
+	// Clearing forgets the context
+	if _, err := s.handleCommand(replCommand{name: "clear"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.context != nil {
+		t.Errorf("context after /clear = %v, want none", s.context)
+	}
+}
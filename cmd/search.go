@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/registry"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var searchModelsCmd = &cobra.Command{
+	Use:   "search [QUERY]",
+	Short: "Search Hugging Face for models",
+	Long: `Search the Hugging Face Hub for models. When run in a terminal, a model can
+be picked from the results and pulled directly.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSearchModels,
+}
+
+// searchSortKeys maps the --sort values to Hugging Face sort fields
+var searchSortKeys = map[string]string{
+	"downloads": "downloads",
+	"likes":     "likes",
+	"updated":   "lastModified",
+}
+
+// paramCountPattern matches parameter counts in model IDs, e.g. "7B" or "1.1b"
+var paramCountPattern = regexp.MustCompile(`(?i)(?:^|[^a-z0-9.])(\d+(?:\.\d+)?)b(?:[^a-z0-9]|$)`)
+
+// searchRow is a search result with the details shown in the results table
+type searchRow struct {
+	model         registry.ModelInfo
+	size          string
+	quantizations []string
+}
+
+func init() {
+	modelsCmd.AddCommand(searchModelsCmd)
+
+	searchModelsCmd.Flags().Int("limit", 20, "Maximum number of results")
+	searchModelsCmd.Flags().Int("page-size", 0, "Results fetched per request, following further pages until --limit results are found (default --limit)")
+	searchModelsCmd.Flags().String("sort", "downloads", "Sort order: downloads, likes or updated")
+	searchModelsCmd.Flags().StringArray("filter", nil, "Filter results, as pipeline_tag=VALUE or library=VALUE (repeatable)")
+	searchModelsCmd.Flags().Bool("gguf-only", false, "Only show repositories with GGUF files, listing their quantizations")
+}
+
+func runSearchModels(cmd *cobra.Command, args []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	pageSize, _ := cmd.Flags().GetInt("page-size")
+	sortBy, _ := cmd.Flags().GetString("sort")
+	filters, _ := cmd.Flags().GetStringArray("filter")
+	ggufOnly, _ := cmd.Flags().GetBool("gguf-only")
+
+	sortKey, ok := searchSortKeys[sortBy]
+	if !ok {
+		return fmt.Errorf("invalid sort order %q: must be downloads, likes or updated", sortBy)
+	}
+	if limit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+	if pageSize <= 0 {
+		pageSize = limit
+	}
+
+	options := registry.SearchOptions{
+		Sort:      sortKey,
+		Direction: "-1",
+		Limit:     pageSize,
+		GGUFOnly:  ggufOnly,
+	}
+	for _, filter := range filters {
+		key, value, found := strings.Cut(filter, "=")
+		if !found || value == "" {
+			return fmt.Errorf("invalid filter %q: expected KEY=VALUE", filter)
+		}
+		switch key {
+		case "pipeline_tag":
+			options.PipelineTag = value
+		case "library":
+			options.Library = value
+		default:
+			return fmt.Errorf("unsupported filter %q: must be pipeline_tag or library", key)
+		}
+	}
+
+	query := ""
+	if len(args) > 0 {
+		query = args[0]
+	}
+
+	cfg := config.Read()
+	hfRegistry := registry.NewHuggingFaceRegistry(os.Getenv("HUGGINGFACE_TOKEN"))
+	if err := hfRegistry.SetProxy(cfg.Proxy, cfg.NoProxyHosts()); err != nil {
+		return err
+	}
+
+	// Pages are fetched until enough results are found, which with
+	// --gguf-only may take more than one
+	ctx, cancel := context.WithCancel(cmd.Context())
+	results, err := hfRegistry.SearchModelsAll(ctx, query, options)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to search models: %w", err)
+	}
+	var models []registry.ModelInfo
+	for result := range results {
+		if models = append(models, result); len(models) == limit {
+			break
+		}
+	}
+	cancel()
+
+	if len(models) == 0 {
+		fmt.Println("No models found")
+		return nil
+	}
+
+	rows := make([]searchRow, len(models))
+	for i, result := range models {
+		rows[i] = searchRow{model: result, size: estimateModelSize(result.ID)}
+
+		if ggufOnly {
+			files, err := hfRegistry.ListGGUFFiles(result.ID)
+			if err != nil || len(files) == 0 {
+				continue
+			}
+			if best := hfRegistry.SelectBestGGUF(files); best.Size > 0 {
+				rows[i].size = formatSize(best.Size)
+			}
+			rows[i].quantizations = fileQuantizations(files)
+		}
+	}
+
+	interactive := isTerminal(os.Stdin) && isTerminal(os.Stdout)
+	if err := printSearchResults(rows, ggufOnly, interactive); err != nil {
+		return err
+	}
+
+	if !interactive {
+		return nil
+	}
+
+	modelID, err := pickSearchResult(rows)
+	if err != nil || modelID == "" {
+		return err
+	}
+	// The model was picked with its size shown, so it is not confirmed again
+	return pullModel(cmd.Context(), modelID, true, false, true, model.DefaultParallelDownloads())
+}
+
+// printSearchResults renders search results as a table, numbering the rows
+// when they can be picked from
+func printSearchResults(rows []searchRow, ggufOnly, numbered bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	header := "ID\tDOWNLOADS\tLIKES\tSIZE\tTAGS"
+	if ggufOnly {
+		header += "\tQUANTIZATIONS"
+	}
+	if numbered {
+		header = "#\t" + header
+	}
+	fmt.Fprintln(w, header)
+
+	for i, row := range rows {
+		if numbered {
+			fmt.Fprintf(w, "%d\t", i+1)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s",
+			row.model.ID,
+			row.model.Downloads,
+			row.model.Likes,
+			row.size,
+			formatTags(row.model.Tags, 3))
+		if ggufOnly {
+			fmt.Fprintf(w, "\t%s", strings.Join(row.quantizations, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	return w.Flush()
+}
+
+// pickSearchResult prompts for a result to pull. Entering text narrows the
+// results to the model IDs that fuzzily match it. It returns an empty ID when
+// the user cancels.
+func pickSearchResult(rows []searchRow) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	candidates := rows
+
+	for {
+		fmt.Print("\nPull a model (number, text to filter, empty to cancel): ")
+		line, err := reader.ReadString('\n')
+		input := strings.TrimSpace(line)
+		if input == "" {
+			return "", nil
+		}
+
+		if n, convErr := strconv.Atoi(input); convErr == nil {
+			if n < 1 || n > len(candidates) {
+				fmt.Printf("Please enter a number between 1 and %d\n", len(candidates))
+				continue
+			}
+			return candidates[n-1].model.ID, nil
+		}
+		if err != nil {
+			return "", nil
+		}
+
+		var matches []searchRow
+		for _, row := range rows {
+			if fuzzyMatch(row.model.ID, input) {
+				matches = append(matches, row)
+			}
+		}
+		if len(matches) == 0 {
+			fmt.Printf("No models match '%s'\n", input)
+			continue
+		}
+
+		candidates = matches
+		fmt.Println()
+		for i, row := range candidates {
+			fmt.Printf("%3d  %s\n", i+1, row.model.ID)
+		}
+	}
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in order,
+// ignoring case
+func fuzzyMatch(s, pattern string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(pattern) {
+		idx := strings.IndexRune(s, r)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(string(r)):]
+	}
+	return true
+}
+
+// estimateModelSize estimates the Q4_K_M download size of a model from the
+// parameter count in its ID
+func estimateModelSize(modelID string) string {
+	matches := paramCountPattern.FindStringSubmatch(modelID)
+	if matches == nil {
+		return "-"
+	}
+
+	billions, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil || billions <= 0 {
+		return "-"
+	}
+
+	// Q4_K_M averages about 4.85 bits per weight
+	return "~" + formatSize(int64(billions*1e9*4.85/8))
+}
+
+// fileQuantizations returns the distinct quantization types of GGUF files
+func fileQuantizations(files []registry.FileInfo) []string {
+	var quantizations []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		quant := registry.QuantizationFromFileName(file.RFileName)
+		if quant == "" || seen[quant] {
+			continue
+		}
+		seen[quant] = true
+		quantizations = append(quantizations, quant)
+	}
+	return quantizations
+}
+
+// formatTags joins up to max tags, noting how many were left out
+func formatTags(tags []string, max int) string {
+	if len(tags) <= max {
+		return strings.Join(tags, ", ")
+	}
+	return fmt.Sprintf("%s (+%d)", strings.Join(tags[:max], ", "), len(tags)-max)
+}
+
+// isTerminal reports whether f is connected to a terminal. Other character
+// devices such as /dev/null are not terminals.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
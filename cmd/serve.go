@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,12 +11,15 @@ import (
 	"time"
 
 	"colossus-cli/internal/api"
+	"colossus-cli/internal/buildinfo"
 	"colossus-cli/internal/config"
-	"colossus-cli/internal/model"
+	"colossus-cli/internal/mcp"
+	"colossus-cli/internal/tracing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 )
 
 var serveCmd = &cobra.Command{
@@ -27,23 +31,160 @@ var serveCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	
+	serveCmd.Flags().String("preload", "", "Comma-separated models to load at startup; /ready reports not ready until they are loaded")
+	viper.BindPFlag("preload", serveCmd.Flags().Lookup("preload"))
+	
+	serveCmd.Flags().Bool("no-warmup", false, "Do not evaluate a short prompt after loading a model; by default GPU models are warmed up so that their first request responds sooner")
+	viper.BindPFlag("no_warmup", serveCmd.Flags().Lookup("no-warmup"))
+	
+	serveCmd.Flags().Duration("startup-timeout", 0, "Exit with code 1 unless startup, including --preload, finishes within this duration; /ready reports ready once it has passed, even with no model loaded (0 waits forever)")
+	viper.BindPFlag("startup_timeout", serveCmd.Flags().Lookup("startup-timeout"))
+	
+	serveCmd.Flags().String("prefill-prompt", "", "System prompt to evaluate once when each model loads; requests starting with it reuse its KV cache")
+	viper.BindPFlag("prefill_prompt", serveCmd.Flags().Lookup("prefill-prompt"))
+	
+	serveCmd.Flags().Duration("cache-ttl", 0, "Return the cached response to identical generate requests made within this duration, e.g. 10m (0 disables caching)")
+	serveCmd.Flags().Int("cache-size", 1000, "Most responses kept in the response cache")
+	viper.BindPFlag("cache_ttl", serveCmd.Flags().Lookup("cache-ttl"))
+	viper.BindPFlag("cache_size", serveCmd.Flags().Lookup("cache-size"))
+	
+	serveCmd.Flags().Int("compression-level", 0, "Compress non-streamed API responses with gzip at this level, 1 (fastest) to 9 (smallest), for clients accepting it (0 disables compression)")
+	viper.BindPFlag("compression_level", serveCmd.Flags().Lookup("compression-level"))
+	
+	serveCmd.Flags().Bool("systemd", false, "Notify systemd when the server is ready and send watchdog keepalives (default: when NOTIFY_SOCKET is set)")
+	viper.BindPFlag("systemd", serveCmd.Flags().Lookup("systemd"))
+	
+	serveCmd.Flags().Int("mcp-port", 0, "Serve the Model Context Protocol on this port, with a tool per model and the prompt templates as prompts (0 disables MCP)")
+	viper.BindPFlag("mcp_port", serveCmd.Flags().Lookup("mcp-port"))
+	
+	serveCmd.Flags().Int("grpc-port", 0, "Serve the gRPC API on this port, alongside the HTTP API (0 disables gRPC)")
+	viper.BindPFlag("grpc_port", serveCmd.Flags().Lookup("grpc-port"))
+	
+	serveCmd.Flags().Duration("idle-unload", 0, "Unload models that have received no requests for this long, e.g. 30m (0 keeps them loaded)")
+	viper.BindPFlag("idle_unload", serveCmd.Flags().Lookup("idle-unload"))
+	
+	serveCmd.Flags().String("gpu-split", "", "Comma-separated proportions of each model to place on each GPU, e.g. \"0.5,0.5\" (default: by free VRAM)")
+	viper.BindPFlag("gpu_split", serveCmd.Flags().Lookup("gpu-split"))
+	
+	serveCmd.Flags().Int("queue-depth", 10, "Requests that may wait for each model while it is busy; further requests get 503 queue full")
+	viper.BindPFlag("queue_depth", serveCmd.Flags().Lookup("queue-depth"))
+	
+	serveCmd.Flags().Int("circuit-threshold", 5, "Consecutive inference errors after which requests to a model get 503 circuit open (0 disables the circuit breaker)")
+	serveCmd.Flags().Duration("circuit-recovery-timeout", 60*time.Second, "How long a model's circuit stays open before a trial request is let through")
+	viper.BindPFlag("circuit_threshold", serveCmd.Flags().Lookup("circuit-threshold"))
+	viper.BindPFlag("circuit_recovery_timeout", serveCmd.Flags().Lookup("circuit-recovery-timeout"))
+	
+	serveCmd.Flags().Int("max-batch-prompts", 64, "Most prompts accepted by one /api/batch/generate request (0 for no limit)")
+	viper.BindPFlag("max_batch_prompts", serveCmd.Flags().Lookup("max-batch-prompts"))
+	
+	serveCmd.Flags().Duration("request-timeout", 5*time.Minute, "Maximum duration of an inference request (0 disables the timeout)")
+	viper.BindPFlag("request_timeout", serveCmd.Flags().Lookup("request-timeout"))
+	
+	serveCmd.Flags().Duration("slow-query-threshold", 30*time.Second, "Log generations taking longer than this as slow queries (0 disables slow query logging)")
+	viper.BindPFlag("slow_query_threshold", serveCmd.Flags().Lookup("slow-query-threshold"))
+	
+	serveCmd.Flags().Duration("ws-ping-interval", 30*time.Second, "Interval between keep-alive pings on WebSocket connections (0 disables pings)")
+	viper.BindPFlag("ws_ping_interval", serveCmd.Flags().Lookup("ws-ping-interval"))
+	
+	serveCmd.Flags().Bool("metrics", true, "Expose Prometheus metrics on /metrics")
+	viper.BindPFlag("metrics", serveCmd.Flags().Lookup("metrics"))
+	
+	serveCmd.Flags().String("api-key", "", "Require this API key (or comma-separated keys) as a Bearer token on API routes")
+	serveCmd.Flags().String("api-keys-file", "", "File of accepted API keys, one per line")
+	viper.BindPFlag("api_key", serveCmd.Flags().Lookup("api-key"))
+	viper.BindPFlag("api_keys_file", serveCmd.Flags().Lookup("api-keys-file"))
+	
+	serveCmd.Flags().Float64("rate-limit", 0, "Maximum requests per second per client (0 disables rate limiting)")
+	serveCmd.Flags().Int("rate-limit-burst", 0, "Maximum burst of requests per client (defaults to the rate limit)")
+	serveCmd.Flags().Duration("rate-limit-cleanup-interval", 5*time.Minute, "How often to forget clients that have been idle for this long")
+	viper.BindPFlag("rate_limit", serveCmd.Flags().Lookup("rate-limit"))
+	viper.BindPFlag("rate_limit_burst", serveCmd.Flags().Lookup("rate-limit-burst"))
+	viper.BindPFlag("rate_limit_cleanup_interval", serveCmd.Flags().Lookup("rate-limit-cleanup-interval"))
+	
+	serveCmd.Flags().Bool("ollama-compat", false, "Serve Ollama's model management API (tags, pull, delete, copy, show, version) so the ollama CLI can use the server")
+	viper.BindPFlag("ollama_compat", serveCmd.Flags().Lookup("ollama-compat"))
+	
+	serveCmd.Flags().Bool("api-docs", false, "Serve the OpenAPI specification at /api/openapi.json and Swagger UI at /api/docs")
+	viper.BindPFlag("api_docs", serveCmd.Flags().Lookup("api-docs"))
+	
+	serveCmd.Flags().String("pprof-addr", "", "Serve pprof profiles on this separate HOST:PORT address, e.g. localhost:6060 (exposes internals, keep it private)")
+	viper.BindPFlag("pprof_addr", serveCmd.Flags().Lookup("pprof-addr"))
+	
+	serveCmd.Flags().String("otlp-endpoint", "", "Export traces of requests to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	viper.BindPFlag("otlp_endpoint", serveCmd.Flags().Lookup("otlp-endpoint"))
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
 	// Initialize configuration
 	cfg := config.Load()
+	if err := config.CheckFile(); err != nil {
+		return fmt.Errorf("invalid config file:\n%w", err)
+	}
 	
 	// Setup logging
 	if viper.GetBool("verbose") {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
+	// Refuse to start with an unreadable keys file
+	apiKeys, err := cfg.APIKeys()
+	if err != nil {
+		return err
+	}
+	if len(apiKeys) > 0 {
+		logrus.Infof("API key authentication enabled (%d keys)", len(apiKeys))
+	}
+
+	if _, err := cfg.TensorSplit(); err != nil {
+		return err
+	}
+
+	if cfg.CompressionLevel < 0 || cfg.CompressionLevel > 9 {
+		return fmt.Errorf("--compression-level must be between 0 and 9, got %d", cfg.CompressionLevel)
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logrus.Warnf("Failed to flush traces: %v", err)
+			}
+		}()
+		logrus.Infof("Exporting traces to %s", cfg.OTLPEndpoint)
+	}
+
+	if cfg.PprofAddr != "" {
+		startPprofServer(cfg.PprofAddr)
+	}
+
 	// Initialize model manager
-	modelManager := model.NewManager(cfg.ModelsPath)
+	modelManager, err := newModelManager(cfg)
+	if err != nil {
+		return err
+	}
 
 	// Setup API server
 	server := api.NewServer(cfg, modelManager)
 	
+	// Load the preloaded models while the server starts accepting requests;
+	// startup finishes once they are loaded
+	server.Preload(cfg.PreloadModels()...)
+	
+	// Exit so that e.g. Kubernetes restarts a server that failed to start
+	if cfg.StartupTimeout > 0 {
+		go func() {
+			if err := server.WaitForStartup(cfg.StartupTimeout); err != nil {
+				logrus.Fatalf("Startup failed: %v", err)
+			}
+		}()
+	}
+	
 	// Start server
 	address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	logrus.Infof("Starting Colossus server on %s", address)
@@ -53,19 +194,50 @@ func runServe(cmd *cobra.Command, args []string) error {
 		Handler: server.Router(),
 	}
 
+	// Listen before notifying systemd, so that it only considers the server
+	// ready once it accepts connections
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
 	// Graceful shutdown
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logrus.Fatalf("Server failed to start: %v", err)
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Server failed: %v", err)
 		}
 	}()
 
+	if cfg.MCPPort > 0 {
+		mcpServer, err := startMCPServer(server, fmt.Sprintf("%s:%d", cfg.Host, cfg.MCPPort))
+		if err != nil {
+			return err
+		}
+		defer mcpServer.Close()
+	}
+
+	if cfg.GRPCPort > 0 {
+		grpcServer, err := startGRPCServer(server, fmt.Sprintf("%s:%d", cfg.Host, cfg.GRPCPort))
+		if err != nil {
+			return err
+		}
+		defer grpcServer.GracefulStop()
+	}
+
+	var notifier *systemdNotifier
+	if cfg.Systemd || os.Getenv("NOTIFY_SOCKET") != "" {
+		notifier = startSystemdNotifier()
+		defer notifier.stop()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logrus.Info("Shutting down server...")
+	notifier.stopping()
+	server.CancelDownloads()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -74,6 +246,62 @@ func runServe(cmd *cobra.Command, args []string) error {
 		logrus.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	if err := server.SaveStats(); err != nil {
+		logrus.Warnf("Failed to save model statistics: %v", err)
+	}
+
 	logrus.Info("Server exited")
 	return nil
 }
+
+// startGRPCServer serves the gRPC API on its own address
+func startGRPCServer(server *api.Server, addr string) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	logrus.Infof("Serving gRPC on %s", addr)
+
+	grpcServer := server.GRPCServer()
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			logrus.Errorf("gRPC server failed: %v", err)
+		}
+	}()
+	return grpcServer, nil
+}
+
+// startMCPServer serves the Model Context Protocol on its own address
+func startMCPServer(server *api.Server, addr string) (*mcp.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if !api.IsLoopbackAddr(addr) {
+		logrus.Warnf("MCP is listening on %s, which is not a loopback address: MCP connections are not authenticated", addr)
+	}
+	logrus.Infof("Serving MCP on %s", addr)
+
+	mcpServer := mcp.NewServer(server.MCPBackend(), buildinfo.Version())
+	go func() {
+		if err := mcpServer.Serve(listener); err != nil {
+			logrus.Errorf("MCP server failed: %v", err)
+		}
+	}()
+	return mcpServer, nil
+}
+
+// startPprofServer serves pprof profiles on their own address, so that they are
+// never reachable through the API port
+func startPprofServer(addr string) {
+	if !api.IsLoopbackAddr(addr) {
+		logrus.Warnf("pprof is listening on %s, which is not a loopback address: anyone who can reach it can profile the server", addr)
+	}
+	logrus.Infof("Serving pprof profiles on http://%s/debug/pprof/", addr)
+
+	go func() {
+		if err := http.ListenAndServe(addr, api.PprofHandler()); err != nil {
+			logrus.Errorf("pprof server failed: %v", err)
+		}
+	}()
+}
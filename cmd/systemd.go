@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/sirupsen/logrus"
+)
+
+// systemdNotifier reports the state of the server to systemd through the
+// socket in NOTIFY_SOCKET, and keeps its watchdog from restarting the server
+type systemdNotifier struct {
+	done chan struct{}
+}
+
+// startSystemdNotifier notifies systemd that the server is ready and, when
+// the service has a watchdog (WatchdogSec=), sends keepalives at half its
+// interval until stopped
+func startSystemdNotifier() *systemdNotifier {
+	n := &systemdNotifier{done: make(chan struct{})}
+
+	if !n.notify(daemon.SdNotifyReady) {
+		logrus.Warn("systemd notification requested, but NOTIFY_SOCKET is not set")
+		return n
+	}
+	logrus.Debug("Notified systemd that the server is ready")
+
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		logrus.Warnf("Failed to read the systemd watchdog interval: %v", err)
+		return n
+	}
+	if interval <= 0 {
+		return n
+	}
+
+	logrus.Infof("Sending systemd watchdog keepalives every %s", interval/2)
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n.notify(daemon.SdNotifyWatchdog)
+			case <-n.done:
+				return
+			}
+		}
+	}()
+	return n
+}
+
+// stopping notifies systemd that the server is shutting down. Keepalives
+// continue until the notifier is stopped, so a slow shutdown is not
+// mistaken for a hang.
+func (n *systemdNotifier) stopping() {
+	if n == nil {
+		return
+	}
+	n.notify(daemon.SdNotifyStopping)
+}
+
+// stop stops sending watchdog keepalives
+func (n *systemdNotifier) stop() {
+	if n == nil {
+		return
+	}
+	close(n.done)
+}
+
+// notify sends a state to systemd. It reports whether the state was sent.
+func (n *systemdNotifier) notify(state string) bool {
+	sent, err := daemon.SdNotify(false, state)
+	if err != nil {
+		logrus.Warnf("Failed to notify systemd: %v", err)
+	}
+	return sent
+}
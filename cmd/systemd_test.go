@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotifySocket creates a datagram socket like systemd's notification
+// socket and points NOTIFY_SOCKET at it
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// readNotification reads the next state sent to the socket
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestSystemdNotifier(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	notifier := startSystemdNotifier()
+	if got := readNotification(t, conn); got != "READY=1" {
+		t.Fatalf("first notification = %q, want READY=1", got)
+	}
+	for i := 0; i < 2; i++ {
+		if got := readNotification(t, conn); got != "WATCHDOG=1" {
+			t.Fatalf("notification = %q, want a watchdog keepalive", got)
+		}
+	}
+
+	notifier.stopping()
+	notifier.stop()
+	// Keepalives sent before the notifier stopped may still be queued
+	for {
+		got := readNotification(t, conn)
+		if got == "STOPPING=1" {
+			break
+		}
+		if got != "WATCHDOG=1" {
+			t.Fatalf("notification = %q, want STOPPING=1", got)
+		}
+	}
+
+	// No keepalives are sent once stopped, apart from one already in flight
+	buf := make([]byte, 256)
+	for i := 0; ; i++ {
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		if i > 0 || string(buf[:n]) != "WATCHDOG=1" {
+			t.Fatalf("notification %q after stopping", buf[:n])
+		}
+	}
+}
+
+func TestSystemdNotifierWithoutWatchdog(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "")
+
+	notifier := startSystemdNotifier()
+	defer notifier.stop()
+	if got := readNotification(t, conn); got != "READY=1" {
+		t.Fatalf("first notification = %q, want READY=1", got)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 256)); err == nil {
+		t.Error("keepalive sent without a watchdog")
+	}
+}
+
+func TestSystemdNotifierWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	// Notifying without a socket is a no-op, as is a nil notifier
+	notifier := startSystemdNotifier()
+	notifier.stopping()
+	notifier.stop()
+
+	var disabled *systemdNotifier
+	disabled.stopping()
+	disabled.stop()
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"colossus-cli/internal/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens [MODEL_NAME] [PROMPT]",
+	Short: "Count the tokens in a prompt",
+	Long:  "Tokenize a prompt with a model's tokenizer using a running Colossus server, without running inference",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runTokens,
+}
+
+func init() {
+	rootCmd.AddCommand(tokensCmd)
+	tokensCmd.Flags().Bool("json", false, "Output in JSON format, including token IDs")
+}
+
+func runTokens(cmd *cobra.Command, args []string) error {
+	tokenizeResp, err := tokenizePrompt(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if jsonOutput {
+		output, err := json.MarshalIndent(tokenizeResp, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal response: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	fmt.Printf("%d tokens\n", tokenizeResp.Count)
+	return nil
+}
+
+// tokenizePrompt tokenizes a prompt with a model using the running server
+func tokenizePrompt(modelName, prompt string) (*types.TokenizeResponse, error) {
+	host := viper.GetString("host")
+	port := viper.GetInt("port")
+	url := fmt.Sprintf("http://%s:%d/api/tokenize", host, port)
+
+	req := types.TokenizeRequest{
+		Model:  modelName,
+		Prompt: prompt,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := newAPIRequest(http.MethodGet, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", string(body))
+	}
+
+	var tokenizeResp types.TokenizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenizeResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &tokenizeResp, nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+
+	"colossus-cli/internal/buildinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the version and build of colossus",
+	Long: `Show the version of colossus, how it was built, and the capabilities
+compiled into it: real inference with llama.cpp, and GPU offloading with CUDA,
+ROCm, SYCL or Metal.`,
+	Example: `  colossus version
+  colossus version --json`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().Bool("json", false, "Print the version as JSON")
+}
+
+// versionInfo is the version and build of the binary
+type versionInfo struct {
+	Version      string          `json:"version"`
+	Commit       string          `json:"commit,omitempty"`
+	BuildTime    string          `json:"build_time,omitempty"`
+	GoVersion    string          `json:"go_version"`
+	OS           string          `json:"os"`
+	Arch         string          `json:"arch"`
+	Capabilities map[string]bool `json:"capabilities"`
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	info := versionInfo{
+		Version:      buildinfo.Version(),
+		Commit:       buildinfo.Commit(),
+		BuildTime:    buildinfo.BuildTime(),
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Capabilities: buildinfo.Capabilities(),
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode version: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("colossus %s\n", info.Version)
+	fmt.Printf("  Commit:     %s\n", valueOrUnknown(info.Commit))
+	fmt.Printf("  Built:      %s\n", valueOrUnknown(info.BuildTime))
+	fmt.Printf("  Go version: %s\n", info.GoVersion)
+	fmt.Printf("  OS/Arch:    %s/%s\n", info.OS, info.Arch)
+	fmt.Println()
+	fmt.Println("Capabilities:")
+	for _, name := range buildinfo.CapabilityNames {
+		available := "no"
+		if info.Capabilities[name] {
+			available = "yes"
+		}
+		fmt.Printf("  %s=%s\n", name, available)
+	}
+	return nil
+}
+
+// valueOrUnknown returns value, or "unknown" when it is empty
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
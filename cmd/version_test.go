@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/buildinfo"
+)
+
+func TestVersionCommand(t *testing.T) {
+	output, err := executeCommand(t, "version", "--json")
+	if err != nil {
+		t.Fatalf("version --json: %v", err)
+	}
+
+	var info versionInfo
+	if err := json.Unmarshal([]byte(output), &info); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", output, err)
+	}
+	if info.Version != buildinfo.Version() || info.GoVersion != runtime.Version() {
+		t.Errorf("version %q built with %q, want %q built with %q", info.Version, info.GoVersion, buildinfo.Version(), runtime.Version())
+	}
+	if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Errorf("platform = %s/%s, want %s/%s", info.OS, info.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+	if !reflect.DeepEqual(info.Capabilities, buildinfo.Capabilities()) {
+		t.Errorf("capabilities = %v, want %v", info.Capabilities, buildinfo.Capabilities())
+	}
+
+	output, err = executeCommand(t, "version")
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	for _, name := range buildinfo.CapabilityNames {
+		if !strings.Contains(output, "  "+name+"=") {
+			t.Errorf("output %q does not report capability %s", output, name)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyAuth returns middleware that requires a valid API key in the
+// Authorization: Bearer header
+func apiKeyAuth(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validAPIKey(c.GetHeader("Authorization"), keys) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validAPIKey reports whether an Authorization header carries one of keys.
+// Every key is compared in constant time so timing does not reveal which
+// keys exist.
+func validAPIKey(header string, keys []string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"colossus-cli/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/protected", apiKeyAuth([]string{"key-one", "key-two"}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "no header", header: "", want: http.StatusUnauthorized},
+		{name: "wrong key", header: "Bearer key-three", want: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic key-one", want: http.StatusUnauthorized},
+		{name: "empty token", header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "first key", header: "Bearer key-one", want: http.StatusOK},
+		{name: "second key", header: "Bearer key-two", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerRequiresAPIKey(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keysFile, []byte("# CI\nfile-key\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.APIKey = "flag-key"
+		cfg.APIKeysFile = keysFile
+	})
+	if err := os.MkdirAll(s.config.ModelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{name: "API route without key", method: http.MethodGet, path: "/api/tags", want: http.StatusUnauthorized},
+		{name: "API route with wrong key", method: http.MethodGet, path: "/api/tags", key: "CI", want: http.StatusUnauthorized},
+		{name: "API route with flag key", method: http.MethodGet, path: "/api/tags", key: "flag-key", want: http.StatusOK},
+		{name: "API route with file key", method: http.MethodGet, path: "/api/tags", key: "file-key", want: http.StatusOK},
+		{name: "OpenAI route without key", method: http.MethodPost, path: "/v1/embeddings", want: http.StatusUnauthorized},
+		{name: "root health check", method: http.MethodGet, path: "/", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.key != "" {
+				header.Set("Authorization", "Bearer "+tt.key)
+			}
+
+			if w := serve(s, tt.method, tt.path, "", header); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerRejectsAllWithUnreadableKeysFile(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.APIKeysFile = filepath.Join(t.TempDir(), "missing")
+	})
+
+	header := http.Header{"Authorization": {"Bearer anything"}}
+	if w := serve(s, http.MethodGet, "/api/tags", "", header); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+}
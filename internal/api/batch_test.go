@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// batchEngine is a simulated engine that generates batches itself, answering
+// each prompt with the prompt in upper case
+type batchEngine struct {
+	*inference.SimulatedEngine
+	batches [][]string
+}
+
+func (e *batchEngine) GenerateBatch(ctx context.Context, reqs []*types.GenerateRequest) ([]*types.GenerateResponse, error) {
+	var prompts []string
+	responses := make([]*types.GenerateResponse, len(reqs))
+	for i, req := range reqs {
+		prompts = append(prompts, req.Prompt)
+		responses[i] = &types.GenerateResponse{Model: req.Model, Response: strings.ToUpper(req.Prompt), Done: true}
+	}
+	e.batches = append(e.batches, prompts)
+	return responses, nil
+}
+
+// batchGenerate sends a batch generate request to s and decodes the response
+func batchGenerate(t *testing.T, s *Server, body string) types.BatchGenerateResponse {
+	t.Helper()
+	w := serve(s, http.MethodPost, "/api/batch/generate", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.BatchGenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return resp
+}
+
+func TestBatchGenerateSequentially(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+
+	prompts := []string{"hello", "tell me a story", "why is the sky blue"}
+	resp := batchGenerate(t, s, `{"model": "tinyllama", "prompts": ["hello", "tell me a story", "why is the sky blue"], "options": {"seed": 7}}`)
+	if len(resp.Responses) != len(prompts) {
+		t.Fatalf("got %d responses, want %d", len(resp.Responses), len(prompts))
+	}
+
+	// The simulated engine cannot batch, so each prompt is generated alone
+	seed := int64(7)
+	for i, prompt := range prompts {
+		want, err := s.engine.Generate(context.Background(), &types.GenerateRequest{Model: "tinyllama", Prompt: prompt, Options: &types.Options{Seed: &seed}})
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if got := resp.Responses[i]; got.Response != want.Response || !got.Done || got.TotalDuration <= 0 {
+			t.Errorf("response %d = %+v, want %q with its duration", i, got, want.Response)
+		}
+	}
+	if resp.Model != "tinyllama" || resp.TotalDuration <= 0 {
+		t.Errorf("response = %+v, want the model and total duration", resp)
+	}
+}
+
+func TestBatchGenerateWithBatchGenerator(t *testing.T) {
+	s := newTestServer(t, nil)
+	engine := &batchEngine{SimulatedEngine: inference.NewSimulatedEngine()}
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+
+	resp := batchGenerate(t, s, `{"model": "tinyllama", "prompts": ["one", "two"]}`)
+	if len(engine.batches) != 1 || len(engine.batches[0]) != 2 {
+		t.Fatalf("batches = %q, want both prompts in one batch", engine.batches)
+	}
+	if len(resp.Responses) != 2 || resp.Responses[0].Response != "ONE" || resp.Responses[1].Response != "TWO" {
+		t.Errorf("responses = %+v, want the responses in prompt order", resp.Responses)
+	}
+}
+
+func TestBatchGenerateErrors(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.MaxBatchPrompts = 2
+	})
+	installTestModel(t, s, "tinyllama")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "invalid JSON", body: `{"model": `, want: http.StatusBadRequest},
+		{name: "no prompts", body: `{"model": "tinyllama", "prompts": []}`, want: http.StatusBadRequest},
+		{name: "too many prompts", body: `{"model": "tinyllama", "prompts": ["a", "b", "c"]}`, want: http.StatusBadRequest},
+		{name: "unknown model", body: `{"model": "missing", "prompts": ["a"]}`, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(s, http.MethodPost, "/api/batch/generate", tt.body, nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"colossus-cli/internal/types"
+)
+
+// Thresholds of the token budget of a chat, as fractions of the budget: past
+// the first, responses warn of the tokens remaining, and from the second on,
+// chats are refused
+const (
+	tokenBudgetWarning = 0.8
+	tokenBudgetLimit   = 0.95
+)
+
+// errTokenBudgetExhausted refuses chats whose messages use most of their
+// token budget
+var errTokenBudgetExhausted = errors.New("token budget nearly exhausted")
+
+// checkTokenBudget counts the tokens of a chat's messages against the token
+// budget of its options. It returns the tokens remaining once the messages
+// use more than the warning threshold, 0 before, and errTokenBudgetExhausted
+// from the limit on. Chats without a budget are not counted.
+func (s *Server) checkTokenBudget(req *types.ChatRequest) (int, error) {
+	if req.Options == nil || req.Options.TokenBudget <= 0 {
+		return 0, nil
+	}
+	budget := req.Options.TokenBudget
+
+	tokens, err := s.engine.Tokenize(&types.TokenizeRequest{
+		Model:  req.Model,
+		Prompt: chatPromptText(req.Messages),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+
+	used := tokens.Count
+	switch {
+	case float64(used) >= float64(budget)*tokenBudgetLimit:
+		return 0, fmt.Errorf("%w: %d of %d tokens used", errTokenBudgetExhausted, used, budget)
+	case float64(used) > float64(budget)*tokenBudgetWarning:
+		return budget - used, nil
+	default:
+		return 0, nil
+	}
+}
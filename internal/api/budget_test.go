@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/types"
+)
+
+// wordsChat returns a chat request whose messages are n tokens of the
+// simulated engine, which counts words
+func wordsChat(n, budget int) *types.ChatRequest {
+	words := strings.Fields(strings.Repeat("llama ", n))
+	half := len(words) / 2
+	return &types.ChatRequest{
+		Model: "tinyllama",
+		Messages: []types.Message{
+			{Role: "user", Content: strings.Join(words[:half], " ")},
+			{Role: "user", Content: strings.Join(words[half:], " ")},
+		},
+		Options: &types.Options{TokenBudget: budget},
+	}
+}
+
+func TestCheckTokenBudget(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	tests := []struct {
+		name          string
+		req           *types.ChatRequest
+		wantRemaining int
+		wantErr       bool
+	}{
+		{name: "no options", req: &types.ChatRequest{Model: "tinyllama", Messages: wordsChat(10, 0).Messages}},
+		{name: "no budget", req: wordsChat(10, 0)},
+		{name: "well within budget", req: wordsChat(10, 100)},
+		{name: "at the warning threshold", req: wordsChat(8, 10)},
+		{name: "past the warning threshold", req: wordsChat(9, 10), wantRemaining: 1},
+		{name: "past the warning threshold of a large budget", req: wordsChat(3500, 4096), wantRemaining: 596},
+		{name: "at the limit", req: wordsChat(10, 10), wantErr: true},
+		{name: "over budget", req: wordsChat(20, 10), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, err := s.checkTokenBudget(tt.req)
+			if tt.wantErr {
+				if !errors.Is(err, errTokenBudgetExhausted) {
+					t.Errorf("error = %v, want %v", err, errTokenBudgetExhausted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkTokenBudget: %v", err)
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("tokens remaining = %d, want %d", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestChatTokenBudget(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	// chat sends a chat of n tokens, returning the status and the final
+	// response
+	chat := func(n, budget int, stream bool) (int, map[string]interface{}) {
+		t.Helper()
+		req := wordsChat(n, budget)
+		req.Stream = stream
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := serve(s, http.MethodPost, "/api/chat", string(body), nil)
+		var last map[string]interface{}
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			last = nil
+			if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+				t.Fatalf("invalid response %s: %v", scanner.Text(), err)
+			}
+		}
+		return w.Code, last
+	}
+
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%t", stream), func(t *testing.T) {
+			code, resp := chat(10, 100, stream)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", code, http.StatusOK)
+			}
+			if _, ok := resp["warning_tokens_remaining"]; ok {
+				t.Errorf("response %v warns within the budget", resp)
+			}
+
+			code, resp = chat(9, 10, stream)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", code, http.StatusOK)
+			}
+			if resp["done"] != true || resp["warning_tokens_remaining"] != float64(1) {
+				t.Errorf("final response %v, want a warning of 1 token remaining", resp)
+			}
+
+			code, resp = chat(10, 10, stream)
+			if code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", code, http.StatusBadRequest)
+			}
+			if msg, _ := resp["error"].(string); !strings.Contains(msg, errTokenBudgetExhausted.Error()) {
+				t.Errorf("error = %q, want %q", msg, errTokenBudgetExhausted)
+			}
+		})
+	}
+}
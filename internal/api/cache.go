@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// DefaultCacheSize is the number of responses the response cache holds
+const DefaultCacheSize = 1000
+
+// responseCache keeps the responses to generate requests, returned again for
+// identical requests within the TTL
+type responseCache struct {
+	entries *expirable.LRU[string, *cachedResponse]
+}
+
+// cachedResponse is a generate response with the chunks it was streamed in
+type cachedResponse struct {
+	// response is the final response, with the whole generated text
+	response types.GenerateResponse
+
+	// chunks are the streamed text chunks, the last of which was sent with
+	// the final response, empty for a response that was not streamed
+	chunks []cachedChunk
+}
+
+// cachedChunk is a streamed text chunk and when it was sent, relative to the
+// start of the generation
+type cachedChunk struct {
+	text   string
+	offset time.Duration
+}
+
+// newResponseCache creates a cache of up to size responses kept for ttl. It
+// returns nil, disabling caching, when ttl is not positive.
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &responseCache{entries: expirable.NewLRU[string, *cachedResponse](size, nil, ttl)}
+}
+
+// get returns the cached response for a key
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	return c.entries.Get(key)
+}
+
+// add caches the response for a key
+func (c *responseCache) add(key string, resp *cachedResponse) {
+	c.entries.Add(key, resp)
+}
+
+// responseCacheKey returns the cache key of a generate request, the SHA256 of
+// everything that determines its response, or an empty string for requests
+// that cannot be cached: those continuing a session, whose response depends
+// on earlier calls
+func responseCacheKey(req *types.GenerateRequest) string {
+	if req.SessionID != "" {
+		return ""
+	}
+
+	// Reformat the schema so that its key order and spacing do not matter
+	schema := req.JSONSchema
+	if len(schema) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(schema, &v); err == nil {
+			schema, _ = json.Marshal(v)
+		}
+	}
+
+	// Struct fields are encoded in a fixed order
+	data, err := json.Marshal(struct {
+		Model      string          `json:"model"`
+		System     string          `json:"system"`
+		Prompt     string          `json:"prompt"`
+		Options    *types.Options  `json:"options"`
+		Images     [][]byte        `json:"images"`
+		JSONSchema json.RawMessage `json:"json_schema"`
+		Context    []int           `json:"context"`
+	}{req.Model, req.System, req.Prompt, req.Options, req.Images, schema, req.Context})
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// cachedGenerate answers a generate request from the response cache. It
+// returns the request's cache key, and whether the response was cached.
+func (s *Server) cachedGenerate(c *gin.Context, req *types.GenerateRequest) (string, bool) {
+	if s.cache == nil {
+		return "", false
+	}
+	key := responseCacheKey(req)
+	if key == "" {
+		return "", false
+	}
+	cached, ok := s.cache.get(key)
+	if !ok {
+		return key, false
+	}
+
+	logger.WithContext(c.Request.Context()).Debugf("Serving cached response")
+	c.Header("Cache-Hit", "true")
+
+	resp := cached.response
+	resp.CreatedAt = time.Now()
+	if !req.Stream {
+		c.JSON(http.StatusOK, resp)
+		return key, true
+	}
+
+	// Replay the streamed chunks with their original timing. A response
+	// that was not streamed is sent as one chunk.
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Transfer-Encoding", "chunked")
+	encoder := json.NewEncoder(c.Writer)
+
+	chunks := cached.chunks
+	if len(chunks) == 0 {
+		chunks = []cachedChunk{{text: resp.Response}}
+	}
+	start := time.Now()
+	for i, chunk := range chunks {
+		select {
+		case <-time.After(time.Until(start.Add(chunk.offset))):
+		case <-c.Request.Context().Done():
+			return key, true
+		}
+
+		chunkResp := types.GenerateResponse{Model: resp.Model, Response: chunk.text}
+		if i == len(chunks)-1 {
+			chunkResp = resp
+			chunkResp.Response = chunk.text
+		}
+		chunkResp.CreatedAt = time.Now()
+		if err := encoder.Encode(&chunkResp); err != nil {
+			return key, true
+		}
+		c.Writer.Flush()
+	}
+	return key, true
+}
+
+// cacheResponse caches the response to a generate request
+func (s *Server) cacheResponse(key string, resp *types.GenerateResponse, chunks []cachedChunk) {
+	if s.cache == nil || key == "" {
+		return
+	}
+	s.cache.add(key, &cachedResponse{response: *resp, chunks: chunks})
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// pacedEngine is a simulated engine counting its generations, whose streams
+// send a word every interval
+type pacedEngine struct {
+	*inference.SimulatedEngine
+	interval    time.Duration
+	generations atomic.Int32
+}
+
+func (e *pacedEngine) Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
+	e.generations.Add(1)
+	return e.SimulatedEngine.Generate(ctx, req)
+}
+
+func (e *pacedEngine) GenerateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
+	e.generations.Add(1)
+	words := []string{"one", " two", " three"}
+	for i, word := range words {
+		time.Sleep(e.interval)
+		if err := callback(&types.GenerateResponse{Model: req.Model, Response: word, Done: i == len(words)-1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCachingServer returns a server caching responses for ttl, running a
+// paced engine with tinyllama loaded
+func newCachingServer(t *testing.T, ttl time.Duration) (*Server, *pacedEngine) {
+	t.Helper()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.CacheTTL = ttl
+	})
+	engine := &pacedEngine{SimulatedEngine: inference.NewSimulatedEngine(), interval: 20 * time.Millisecond}
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+	return s, engine
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	base := func() *types.GenerateRequest {
+		return &types.GenerateRequest{
+			Model:      "tinyllama",
+			Prompt:     "hello",
+			Options:    &types.Options{Temperature: 0.7},
+			JSONSchema: json.RawMessage(`{"type": "object", "required": ["a"]}`),
+		}
+	}
+	key := responseCacheKey(base())
+	if key == "" || key != responseCacheKey(base()) {
+		t.Fatalf("responseCacheKey() = %q, want the same key for identical requests", key)
+	}
+
+	tests := []struct {
+		name   string
+		change func(req *types.GenerateRequest)
+		same   bool
+	}{
+		{name: "reformatted schema", change: func(req *types.GenerateRequest) {
+			req.JSONSchema = json.RawMessage(`{"required":["a"],"type":"object"}`)
+		}, same: true},
+		{name: "streaming", change: func(req *types.GenerateRequest) { req.Stream = true }, same: true},
+		{name: "temperature", change: func(req *types.GenerateRequest) { req.Options.Temperature = 0.8 }},
+		{name: "no options", change: func(req *types.GenerateRequest) { req.Options = nil }},
+		{name: "model", change: func(req *types.GenerateRequest) { req.Model = "mistral" }},
+		{name: "prompt", change: func(req *types.GenerateRequest) { req.Prompt = "hello!" }},
+		{name: "system prompt", change: func(req *types.GenerateRequest) { req.System = "Be brief." }},
+		{name: "context", change: func(req *types.GenerateRequest) { req.Context = []int{1, 2} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base()
+			tt.change(req)
+			if got := responseCacheKey(req); (got == key) != tt.same {
+				t.Errorf("responseCacheKey() = %q, base key %q, want same %t", got, key, tt.same)
+			}
+		})
+	}
+
+	session := base()
+	session.SessionID = "chat"
+	if got := responseCacheKey(session); got != "" {
+		t.Errorf("responseCacheKey() of a session request = %q, want none", got)
+	}
+}
+
+func TestGenerateCache(t *testing.T) {
+	s, engine := newCachingServer(t, time.Minute)
+
+	body := `{"model": "tinyllama", "prompt": "hello", "stream": false, "options": {"temperature": 0.5, "seed": 1}}`
+	first := serve(s, http.MethodPost, "/api/generate", body, nil)
+	if first.Code != http.StatusOK || first.Header().Get("Cache-Hit") != "" {
+		t.Fatalf("first request: status %d, Cache-Hit %q, want a generated response", first.Code, first.Header().Get("Cache-Hit"))
+	}
+	second := serve(s, http.MethodPost, "/api/generate", body, nil)
+	if second.Header().Get("Cache-Hit") != "true" {
+		t.Errorf("identical request: Cache-Hit = %q, want true", second.Header().Get("Cache-Hit"))
+	}
+
+	var want, got types.GenerateResponse
+	json.Unmarshal(first.Body.Bytes(), &want)
+	json.Unmarshal(second.Body.Bytes(), &got)
+	if got.Response != want.Response || got.Response == "" {
+		t.Errorf("cached response = %q, want %q", got.Response, want.Response)
+	}
+
+	// A different temperature is a different request
+	hotter := strings.Replace(body, `"temperature": 0.5`, `"temperature": 0.9`, 1)
+	if w := serve(s, http.MethodPost, "/api/generate", hotter, nil); w.Header().Get("Cache-Hit") != "" {
+		t.Error("request with another temperature served from the cache")
+	}
+	if n := engine.generations.Load(); n != 2 {
+		t.Errorf("engine generated %d responses, want 2", n)
+	}
+}
+
+func TestGenerateCacheReplaysStream(t *testing.T) {
+	s, engine := newCachingServer(t, time.Minute)
+
+	// stream reads the chunks of a streamed generation
+	stream := func() ([]string, http.Header, time.Duration) {
+		start := time.Now()
+		w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "count", "stream": true}`, nil)
+		elapsed := time.Since(start)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+
+		var chunks []string
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var resp types.GenerateResponse
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid chunk %q: %v", scanner.Text(), err)
+			}
+			chunks = append(chunks, resp.Response)
+			if resp.Done != (len(chunks) == 3) {
+				t.Errorf("chunk %d done = %t", len(chunks), resp.Done)
+			}
+		}
+		return chunks, w.Header(), elapsed
+	}
+
+	want, header, _ := stream()
+	if header.Get("Cache-Hit") != "" {
+		t.Fatal("first stream served from the cache")
+	}
+	got, header, elapsed := stream()
+	if header.Get("Cache-Hit") != "true" {
+		t.Errorf("identical stream: Cache-Hit = %q, want true", header.Get("Cache-Hit"))
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("replayed chunks = %q, want %q", got, want)
+	}
+	// The words were 20ms apart
+	if elapsed < 50*time.Millisecond {
+		t.Errorf("replay took %v, want the original timing of about 60ms", elapsed)
+	}
+	if n := engine.generations.Load(); n != 1 {
+		t.Errorf("engine generated %d responses, want 1", n)
+	}
+
+	// A non-streamed request is answered from the cached stream
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "count", "stream": false}`, nil)
+	var resp types.GenerateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Header().Get("Cache-Hit") != "true" || resp.Response != "one two three" {
+		t.Errorf("non-streamed request: Cache-Hit %q, response %q, want the cached text", w.Header().Get("Cache-Hit"), resp.Response)
+	}
+}
+
+func TestGenerateCacheExpiry(t *testing.T) {
+	body := `{"model": "tinyllama", "prompt": "hello", "stream": false}`
+
+	// Caching is off without a TTL
+	s, engine := newCachingServer(t, 0)
+	for i := 0; i < 2; i++ {
+		if w := serve(s, http.MethodPost, "/api/generate", body, nil); w.Header().Get("Cache-Hit") != "" {
+			t.Error("response cached without a cache TTL")
+		}
+	}
+	if n := engine.generations.Load(); n != 2 {
+		t.Errorf("engine generated %d responses, want 2", n)
+	}
+
+	s, engine = newCachingServer(t, 50*time.Millisecond)
+	serve(s, http.MethodPost, "/api/generate", body, nil)
+	time.Sleep(100 * time.Millisecond)
+	if w := serve(s, http.MethodPost, "/api/generate", body, nil); w.Header().Get("Cache-Hit") != "" {
+		t.Error("expired response served from the cache")
+	}
+	if n := engine.generations.Load(); n != 2 {
+		t.Errorf("engine generated %d responses, want 2", n)
+	}
+}
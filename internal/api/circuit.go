@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned for requests to a model whose circuit is open
+var errCircuitOpen = errors.New("model circuit open, too many errors")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every request until the recovery timeout has passed
+	CircuitOpen
+	// CircuitHalfOpen lets one trial request through, whose outcome closes or
+	// opens the circuit again
+	CircuitHalfOpen
+)
+
+// String returns the name of the state reported by /api/ps
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops requests to a model after too many consecutive
+// inference errors, so that they fail at once rather than wait for a model
+// that keeps failing. It is safe for concurrent use.
+type CircuitBreaker struct {
+	mutex sync.Mutex
+
+	// Consecutive errors opening the circuit, 0 to never open it
+	threshold       int
+	recoveryTimeout time.Duration
+
+	state    CircuitState
+	failures int
+	// openedAt is when the circuit opened, trialAt when the half-open circuit
+	// let its trial request through
+	openedAt time.Time
+	trialAt  time.Time
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker that opens after
+// threshold consecutive errors and lets a trial request through
+// recoveryTimeout later. A threshold of 0 disables the breaker.
+func NewCircuitBreaker(threshold int, recoveryTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:       threshold,
+		recoveryTimeout: recoveryTimeout,
+		now:             time.Now,
+	}
+}
+
+// Allow reports whether a request may run. Once the recovery timeout has
+// passed, an open circuit turns half-open and allows one trial request. A
+// trial whose outcome is never reported, e.g. because it was canceled, is
+// replaced by another after the recovery timeout.
+func (b *CircuitBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.recoveryTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		if now.Sub(b.trialAt) < b.recoveryTimeout {
+			return false
+		}
+	default:
+		return true
+	}
+
+	b.trialAt = now
+	return true
+}
+
+// Success records a successful request, which closes the circuit
+func (b *CircuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+}
+
+// Failure records a failed request. The circuit opens after threshold
+// consecutive failures, or at once when the trial request of a half-open
+// circuit fails.
+func (b *CircuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the current state of the circuit. An open circuit whose
+// recovery timeout has passed is reported as half-open, as the next request
+// will be let through.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.recoveryTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// loadErrorStatus returns the HTTP status of a request whose model could not
+// be loaded
+func loadErrorStatus(err error) int {
+	if errors.Is(err, errCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusNotFound
+}
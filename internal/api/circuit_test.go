@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// newTestCircuit creates a circuit breaker whose clock is advanced by the
+// returned function
+func newTestCircuit(threshold int, recoveryTimeout time.Duration) (*CircuitBreaker, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(threshold, recoveryTimeout)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+// expectCircuit fails the test unless b is in state and Allow returns allow
+func expectCircuit(t *testing.T, b *CircuitBreaker, state CircuitState, allow bool) {
+	t.Helper()
+	if got := b.State(); got != state {
+		t.Errorf("State = %s, want %s", got, state)
+	}
+	if got := b.Allow(); got != allow {
+		t.Errorf("Allow = %v, want %v", got, allow)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	b, _ := newTestCircuit(3, time.Minute)
+
+	// Successes reset the count of consecutive failures
+	b.Failure()
+	b.Failure()
+	expectCircuit(t, b, CircuitClosed, true)
+	b.Success()
+	b.Failure()
+	b.Failure()
+	expectCircuit(t, b, CircuitClosed, true)
+
+	b.Failure()
+	expectCircuit(t, b, CircuitOpen, false)
+}
+
+func TestCircuitBreakerRecovers(t *testing.T) {
+	tests := []struct {
+		name      string
+		trial     func(b *CircuitBreaker)
+		wantState CircuitState
+		wantAllow bool
+	}{
+		{name: "trial succeeds", trial: (*CircuitBreaker).Success, wantState: CircuitClosed, wantAllow: true},
+		{name: "trial fails", trial: (*CircuitBreaker).Failure, wantState: CircuitOpen, wantAllow: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, advance := newTestCircuit(1, time.Minute)
+			b.Failure()
+
+			advance(59 * time.Second)
+			expectCircuit(t, b, CircuitOpen, false)
+
+			// Only one trial request is let through once the circuit is
+			// half-open
+			advance(time.Second)
+			expectCircuit(t, b, CircuitHalfOpen, true)
+			expectCircuit(t, b, CircuitHalfOpen, false)
+
+			tt.trial(b)
+			expectCircuit(t, b, tt.wantState, tt.wantAllow)
+		})
+	}
+}
+
+func TestCircuitBreakerReopensAfterFailedTrial(t *testing.T) {
+	b, advance := newTestCircuit(5, time.Minute)
+	for i := 0; i < 5; i++ {
+		b.Failure()
+	}
+
+	// A failed trial opens the circuit for another recovery timeout
+	advance(time.Minute)
+	b.Allow()
+	advance(30 * time.Second)
+	b.Failure()
+	advance(59 * time.Second)
+	expectCircuit(t, b, CircuitOpen, false)
+	advance(time.Second)
+	expectCircuit(t, b, CircuitHalfOpen, true)
+}
+
+func TestCircuitBreakerLostTrial(t *testing.T) {
+	b, advance := newTestCircuit(1, time.Minute)
+	b.Failure()
+	advance(time.Minute)
+	expectCircuit(t, b, CircuitHalfOpen, true)
+
+	// The outcome of the trial is never reported, so another is let through
+	// after the recovery timeout
+	advance(59 * time.Second)
+	expectCircuit(t, b, CircuitHalfOpen, false)
+	advance(time.Second)
+	expectCircuit(t, b, CircuitHalfOpen, true)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b, _ := newTestCircuit(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		b.Failure()
+	}
+	expectCircuit(t, b, CircuitClosed, true)
+}
+
+// failingEngine is a simulated engine whose generations fail
+type failingEngine struct {
+	*inference.SimulatedEngine
+}
+
+func (e *failingEngine) Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
+	return nil, errors.New("inference failed")
+}
+
+func TestCircuitOpensOnModelErrors(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.CircuitThreshold = 2
+	})
+	s.engine = &failingEngine{SimulatedEngine: inference.NewSimulatedEngine()}
+	installTestModel(t, s, "tinyllama")
+
+	body := `{"model": "tinyllama", "prompt": "hello"}`
+	for i := 0; i < 2; i++ {
+		if w := serve(s, http.MethodPost, "/api/generate", body, nil); w.Code == http.StatusOK {
+			t.Fatalf("request %d succeeded, want the engine's error", i+1)
+		}
+	}
+
+	w := serve(s, http.MethodPost, "/api/generate", body, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(w.Body.String(), errCircuitOpen.Error()) {
+		t.Errorf("body = %s, want %q", w.Body, errCircuitOpen)
+	}
+
+	var ps types.ProcessResponse
+	if err := json.Unmarshal(serve(s, http.MethodGet, "/api/ps", "", nil).Body.Bytes(), &ps); err != nil {
+		t.Fatal(err)
+	}
+	if len(ps.Models) != 1 || ps.Models[0].CircuitState != "open" {
+		t.Errorf("/api/ps models = %+v, want tinyllama with an open circuit", ps.Models)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompression(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.CompressionLevel = 5
+	})
+	installTestModel(t, s, "tinyllama")
+	loadTestModel(t, s, "tinyllama")
+	acceptGzip := http.Header{"Accept-Encoding": {"gzip"}}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		header       http.Header
+		wantCompress bool
+	}{
+		{name: "generate", method: http.MethodPost, path: "/api/generate", body: `{"model": "tinyllama", "prompt": "hello"}`, header: acceptGzip, wantCompress: true},
+		{name: "list models", method: http.MethodGet, path: "/api/tags", header: acceptGzip, wantCompress: true},
+		{name: "streamed generate", method: http.MethodPost, path: "/api/generate", body: `{"model": "tinyllama", "prompt": "hello", "stream": true}`, header: acceptGzip},
+		{name: "gzip not accepted", method: http.MethodPost, path: "/api/generate", body: `{"model": "tinyllama", "prompt": "hello"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(s, tt.method, tt.path, tt.body, tt.header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+
+			body := io.Reader(w.Body)
+			if encoding := w.Header().Get("Content-Encoding"); (encoding == "gzip") != tt.wantCompress {
+				t.Fatalf("Content-Encoding = %q, want compression %v", encoding, tt.wantCompress)
+			}
+			if tt.wantCompress {
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("response is not gzipped: %v", err)
+				}
+				body = gz
+			}
+
+			// Every line of the body is JSON, one object unless streamed
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				if !json.Valid([]byte(line)) {
+					t.Errorf("response line %q is not JSON", line)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressionLargeStreamedRequest(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.CompressionLevel = 5
+	})
+	loadTestModel(t, s, "tinyllama")
+
+	// The body is too large to look for the stream field, so the response is
+	// compressed, but the handler still reads all of it: a truncated body
+	// would be rejected
+	prompt := strings.Repeat("a", maxStreamSniffSize)
+	body, _ := json.Marshal(types.GenerateRequest{Model: "tinyllama", Prompt: prompt, Stream: true})
+	w := serve(s, http.MethodPost, "/api/generate", string(body), http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", encoding)
+	}
+}
+
+func TestRequestsStreamKeepsBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{name: "stream", body: `{"model": "tinyllama", "stream": true}`, want: true},
+		{name: "no stream", body: `{"model": "tinyllama", "stream": false}`},
+		{name: "invalid JSON", body: `{"model": `},
+		{name: "too large", body: `{"stream": true, "prompt": "` + strings.Repeat("a", maxStreamSniffSize) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(tt.body))
+
+			if got := requestsStream(c); got != tt.want {
+				t.Errorf("requestsStream = %v, want %v", got, tt.want)
+			}
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("request body changed: %d bytes left of %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
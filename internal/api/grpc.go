@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	colossuspb "colossus-cli/api/proto"
+	"colossus-cli/internal/api/middleware"
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcService implements the gRPC API with the engine and models of the
+// HTTP API. Requests wait in the same per-model queues as HTTP requests.
+type grpcService struct {
+	colossuspb.UnimplementedColossusServer
+	server *Server
+}
+
+// GRPCServer returns a gRPC server serving the gRPC API defined in
+// api/proto/colossus.proto. Requests are logged, counted in the metrics and,
+// when API keys are configured, authenticated like HTTP requests.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.grpcStreamInterceptor),
+	)
+	colossuspb.RegisterColossusServer(srv, &grpcService{server: s})
+	return srv
+}
+
+// grpcUnaryInterceptor authenticates, logs and measures unary calls
+func (s *Server) grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.grpcRequestContext(ctx)
+	start := time.Now()
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	s.observeGRPCRequest(ctx, info.FullMethod, err, time.Since(start))
+	return resp, err
+}
+
+// grpcStreamInterceptor authenticates, logs and measures streaming calls
+func (s *Server) grpcStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcRequestContext(stream.Context())
+	start := time.Now()
+	if err == nil {
+		err = handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+	s.observeGRPCRequest(ctx, info.FullMethod, err, time.Since(start))
+	return err
+}
+
+// contextStream is a server stream with a replaced context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcRequestContext adds the request ID sent in the x-request-id metadata,
+// or a new one, to the context of a call, and checks its API key, sent as
+// authorization: Bearer KEY metadata
+func (s *Server) grpcRequestContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstMetadata(md, strings.ToLower(middleware.RequestIDHeader))
+	if id == "" || len(id) > 128 {
+		id = middleware.NewRequestID()
+	}
+	ctx = logging.WithRequestID(ctx, id)
+
+	if s.apiKeys != nil && !validAPIKey(firstMetadata(md, "authorization"), s.apiKeys) {
+		return ctx, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return ctx, nil
+}
+
+// firstMetadata returns the first value of a metadata key
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// observeGRPCRequest logs a finished call and records it in the metrics
+func (s *Server) observeGRPCRequest(ctx context.Context, method string, err error, elapsed time.Duration) {
+	code := status.Code(err)
+	logger.WithContext(ctx).WithFields(logrus.Fields{
+		"method":      method,
+		"code":        code.String(),
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+	}).Info("gRPC request handled")
+
+	if s.metrics != nil {
+		s.metrics.grpcRequests.WithLabelValues(method, code.String()).Inc()
+		s.metrics.grpcRequestDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+	}
+}
+
+// prepareModel waits for a turn to run a request for a model and loads the
+// model. The returned function must be called once the request has finished.
+func (g *grpcService) prepareModel(ctx context.Context, modelName string) (func(), time.Duration, error) {
+	if modelName == "" {
+		return nil, 0, status.Error(codes.InvalidArgument, "model is required")
+	}
+
+	release, err := g.server.acquireModel(ctx, modelName)
+	if err != nil {
+		if errors.Is(err, errQueueFull) {
+			return nil, 0, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, 0, status.FromContextError(err).Err()
+	}
+
+	loadStart := time.Now()
+	if err := g.server.ensureModelLoaded(ctx, modelName); err != nil {
+		release()
+		return nil, 0, loadGRPCError(err)
+	}
+	return release, time.Since(loadStart), nil
+}
+
+// GenerateText generates a response to a prompt
+func (g *grpcService) GenerateText(ctx context.Context, in *colossuspb.GenerateRequest) (*colossuspb.GenerateResponse, error) {
+	ctx = logging.WithModel(ctx, in.Model)
+	release, loadDuration, err := g.prepareModel(ctx, in.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	s := g.server
+	ctx, cancel := s.withRequestTimeout(ctx, in.Model)
+	defer cancel()
+
+	req := generateRequestFromProto(in)
+	start := time.Now()
+	resp, err := s.engine.Generate(ctx, req)
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		return nil, inferenceGRPCError(err)
+	}
+	elapsed := time.Since(start)
+	addLoadDuration(resp, loadDuration, elapsed)
+
+	s.recordGeneration(ctx, req.Model, req.Prompt, resp.Response, elapsed)
+	return generateResponseToProto(resp), nil
+}
+
+// StreamText generates a response to a prompt, sending it as it is generated
+func (g *grpcService) StreamText(in *colossuspb.GenerateRequest, stream colossuspb.Colossus_StreamTextServer) error {
+	ctx := logging.WithModel(stream.Context(), in.Model)
+	release, loadDuration, err := g.prepareModel(ctx, in.Model)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	s := g.server
+	ctx, cancel := s.withRequestTimeout(ctx, in.Model)
+	defer cancel()
+
+	req := generateRequestFromProto(in)
+	start := time.Now()
+	var text strings.Builder
+	err = s.engine.GenerateStream(ctx, req, func(resp *types.GenerateResponse) error {
+		text.WriteString(resp.Response)
+		if resp.Done {
+			addLoadDuration(resp, loadDuration, time.Since(start))
+		}
+		return stream.Send(generateResponseToProto(resp))
+	})
+	s.recordGeneration(ctx, req.Model, req.Prompt, text.String(), time.Since(start))
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		return inferenceGRPCError(err)
+	}
+	return nil
+}
+
+// ChatCompletion generates the next message of a conversation
+func (g *grpcService) ChatCompletion(ctx context.Context, in *colossuspb.ChatRequest) (*colossuspb.ChatResponse, error) {
+	ctx = logging.WithModel(ctx, in.Model)
+	release, _, err := g.prepareModel(ctx, in.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	s := g.server
+	ctx, cancel := s.withRequestTimeout(ctx, in.Model)
+	defer cancel()
+
+	req := chatRequestFromProto(in)
+	start := time.Now()
+	resp, err := s.engine.Chat(ctx, req)
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		return nil, inferenceGRPCError(err)
+	}
+
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), resp.Message.Content, time.Since(start))
+	return chatResponseToProto(resp), nil
+}
+
+// StreamChat generates the next message of a conversation, sending it as it
+// is generated
+func (g *grpcService) StreamChat(in *colossuspb.ChatRequest, stream colossuspb.Colossus_StreamChatServer) error {
+	ctx := logging.WithModel(stream.Context(), in.Model)
+	release, _, err := g.prepareModel(ctx, in.Model)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	s := g.server
+	ctx, cancel := s.withRequestTimeout(ctx, in.Model)
+	defer cancel()
+
+	req := chatRequestFromProto(in)
+	start := time.Now()
+	var text strings.Builder
+	err = s.engine.ChatStream(ctx, req, func(resp *types.ChatResponse) error {
+		text.WriteString(resp.Message.Content)
+		return stream.Send(chatResponseToProto(resp))
+	})
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), text.String(), time.Since(start))
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		return inferenceGRPCError(err)
+	}
+	return nil
+}
+
+// ListModels lists the installed models
+func (g *grpcService) ListModels(ctx context.Context, in *colossuspb.ListModelsRequest) (*colossuspb.ListModelsResponse, error) {
+	models, err := g.server.modelManager.ListModels()
+	if err != nil {
+		logger.Errorf("Failed to list models: %v", err)
+		return nil, status.Error(codes.Internal, "failed to list models")
+	}
+
+	resp := &colossuspb.ListModelsResponse{}
+	for _, m := range models {
+		resp.Models = append(resp.Models, &colossuspb.Model{
+			Name:         m.Name,
+			Size:         m.Size,
+			Digest:       m.Digest,
+			ModifiedAt:   timestamppb.New(m.ModifiedAt),
+			Quantization: m.Quantization,
+			Loaded:       g.server.engine.IsModelLoaded(m.Name),
+		})
+	}
+	return resp, nil
+}
+
+// LoadModel loads an installed model
+func (g *grpcService) LoadModel(ctx context.Context, in *colossuspb.LoadModelRequest) (*colossuspb.LoadModelResponse, error) {
+	if in.Model == "" {
+		return nil, status.Error(codes.InvalidArgument, "model is required")
+	}
+	if err := g.server.ensureModelLoaded(ctx, in.Model); err != nil {
+		return nil, loadGRPCError(err)
+	}
+
+	info, err := g.server.engine.GetModelInfo(in.Model)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &colossuspb.LoadModelResponse{
+		Model:       in.Model,
+		ContextSize: int32(info.ContextSize),
+		GpuLayers:   int32(info.ActualGPULayers),
+	}, nil
+}
+
+// UnloadModel frees a loaded model. It is loaded again by its next request.
+func (g *grpcService) UnloadModel(ctx context.Context, in *colossuspb.UnloadModelRequest) (*colossuspb.UnloadModelResponse, error) {
+	if !g.server.engine.IsModelLoaded(in.Model) {
+		return nil, status.Errorf(codes.NotFound, "model not loaded: %s", in.Model)
+	}
+	g.server.loadedModels.Remove(in.Model)
+	if err := g.server.engine.UnloadModel(in.Model); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	logger.Infof("Unloaded model %s", in.Model)
+	return &colossuspb.UnloadModelResponse{}, nil
+}
+
+// loadGRPCError returns the status of a request whose model could not be
+// loaded
+func loadGRPCError(err error) error {
+	if errors.Is(err, errCircuitOpen) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.NotFound, err.Error())
+}
+
+// inferenceGRPCError returns the status of a failed generation
+func inferenceGRPCError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, errRequestTimeout)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// optionsFromProto converts sampling options, keeping unset options unset
+func optionsFromProto(in *colossuspb.Options) *types.Options {
+	if in == nil {
+		return nil
+	}
+	return &types.Options{
+		Temperature: in.GetTemperature(),
+		TopP:        in.GetTopP(),
+		TopK:        int(in.GetTopK()),
+		NumPredict:  int(in.GetNumPredict()),
+		Stop:        in.Stop,
+		Seed:        in.Seed,
+	}
+}
+
+// generateRequestFromProto converts a gRPC generate request
+func generateRequestFromProto(in *colossuspb.GenerateRequest) *types.GenerateRequest {
+	return &types.GenerateRequest{
+		Model:   in.Model,
+		Prompt:  in.Prompt,
+		System:  in.System,
+		Options: optionsFromProto(in.Options),
+		Images:  in.Images,
+	}
+}
+
+// generateResponseToProto converts a generate response to gRPC
+func generateResponseToProto(resp *types.GenerateResponse) *colossuspb.GenerateResponse {
+	out := &colossuspb.GenerateResponse{
+		Model:     resp.Model,
+		CreatedAt: timestamppb.New(resp.CreatedAt),
+		Response:  resp.Response,
+		Done:      resp.Done,
+	}
+	if m := resp.InferenceMetrics; m != nil {
+		out.Metrics = &colossuspb.Metrics{
+			TotalDuration:      int64(m.TotalDuration),
+			LoadDuration:       int64(m.LoadDuration),
+			PromptEvalCount:    int32(m.PromptEvalCount),
+			PromptEvalDuration: int64(m.PromptEvalDuration),
+			EvalCount:          int32(m.EvalCount),
+			EvalDuration:       int64(m.EvalDuration),
+		}
+	}
+	return out
+}
+
+// chatRequestFromProto converts a gRPC chat request
+func chatRequestFromProto(in *colossuspb.ChatRequest) *types.ChatRequest {
+	req := &types.ChatRequest{
+		Model:   in.Model,
+		Options: optionsFromProto(in.Options),
+	}
+	for _, m := range in.Messages {
+		req.Messages = append(req.Messages, types.Message{
+			Role:    m.Role,
+			Content: m.Content,
+			Images:  m.Images,
+		})
+	}
+	return req
+}
+
+// chatResponseToProto converts a chat response to gRPC
+func chatResponseToProto(resp *types.ChatResponse) *colossuspb.ChatResponse {
+	return &colossuspb.ChatResponse{
+		Model:     resp.Model,
+		CreatedAt: timestamppb.New(resp.CreatedAt),
+		Message: &colossuspb.Message{
+			Role:    resp.Message.Role,
+			Content: resp.Message.Content,
+		},
+		Done: resp.Done,
+	}
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	colossuspb "colossus-cli/api/proto"
+	"colossus-cli/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves the gRPC API of s over an in-memory connection and
+// returns a client of it
+func newGRPCClient(t *testing.T, s *Server) colossuspb.ColossusClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	srv := s.GRPCServer()
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return colossuspb.NewColossusClient(conn)
+}
+
+func TestGRPCGenerate(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+	client := newGRPCClient(t, s)
+	ctx := context.Background()
+
+	req := &colossuspb.GenerateRequest{Model: "tinyllama", Prompt: "hello"}
+	resp, err := client.GenerateText(ctx, req)
+	if err != nil {
+		t.Fatalf("GenerateText: %v", err)
+	}
+	if resp.Model != "tinyllama" || resp.Response == "" || !resp.Done {
+		t.Errorf("response = %v, want a finished response of tinyllama", resp)
+	}
+	if resp.Metrics == nil || resp.Metrics.EvalCount == 0 || resp.Metrics.LoadDuration == 0 {
+		t.Errorf("metrics = %v, want the generation and the model load measured", resp.Metrics)
+	}
+
+	stream, err := client.StreamText(ctx, req)
+	if err != nil {
+		t.Fatalf("StreamText: %v", err)
+	}
+	var text strings.Builder
+	var chunks int
+	var last *colossuspb.GenerateResponse
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("StreamText: %v", err)
+		}
+		chunks++
+		text.WriteString(chunk.Response)
+		last = chunk
+	}
+	if chunks < 2 || text.String() != resp.Response {
+		t.Errorf("streamed %q in %d chunks, want %q in several", text.String(), chunks, resp.Response)
+	}
+	if !last.Done || last.Metrics == nil {
+		t.Errorf("last chunk = %v, want the done chunk with metrics", last)
+	}
+}
+
+func TestGRPCChat(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+	client := newGRPCClient(t, s)
+	ctx := context.Background()
+
+	req := &colossuspb.ChatRequest{
+		Model: "tinyllama",
+		Messages: []*colossuspb.Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "hello"},
+		},
+	}
+	resp, err := client.ChatCompletion(ctx, req)
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Message.GetRole() != "assistant" || resp.Message.GetContent() == "" || !resp.Done {
+		t.Errorf("response = %v, want a finished assistant message", resp)
+	}
+
+	stream, err := client.StreamChat(ctx, req)
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var text strings.Builder
+	var done bool
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("StreamChat: %v", err)
+		}
+		text.WriteString(chunk.Message.GetContent())
+		done = chunk.Done
+	}
+	if text.String() != resp.Message.GetContent() || !done {
+		t.Errorf("streamed %q (done %t), want %q", text.String(), done, resp.Message.GetContent())
+	}
+}
+
+func TestGRPCModels(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+	client := newGRPCClient(t, s)
+	ctx := context.Background()
+
+	loaded := func() bool {
+		t.Helper()
+		resp, err := client.ListModels(ctx, &colossuspb.ListModelsRequest{})
+		if err != nil {
+			t.Fatalf("ListModels: %v", err)
+		}
+		if len(resp.Models) != 1 || resp.Models[0].Name != "tinyllama" {
+			t.Fatalf("models = %v, want tinyllama", resp.Models)
+		}
+		return resp.Models[0].Loaded
+	}
+
+	if loaded() {
+		t.Error("tinyllama loaded before LoadModel")
+	}
+	resp, err := client.LoadModel(ctx, &colossuspb.LoadModelRequest{Model: "tinyllama"})
+	if err != nil {
+		t.Fatalf("LoadModel: %v", err)
+	}
+	if resp.Model != "tinyllama" || resp.ContextSize <= 0 {
+		t.Errorf("response = %v, want tinyllama and its context size", resp)
+	}
+	if !loaded() {
+		t.Error("tinyllama not loaded after LoadModel")
+	}
+
+	if _, err := client.UnloadModel(ctx, &colossuspb.UnloadModelRequest{Model: "tinyllama"}); err != nil {
+		t.Fatalf("UnloadModel: %v", err)
+	}
+	if loaded() {
+		t.Error("tinyllama loaded after UnloadModel")
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+	client := newGRPCClient(t, s)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+	}{
+		{
+			name: "generate without model",
+			call: func() error {
+				_, err := client.GenerateText(ctx, &colossuspb.GenerateRequest{Prompt: "hello"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "generate with unknown model",
+			call: func() error {
+				_, err := client.GenerateText(ctx, &colossuspb.GenerateRequest{Model: "mistral", Prompt: "hello"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "stream with unknown model",
+			call: func() error {
+				stream, err := client.StreamChat(ctx, &colossuspb.ChatRequest{Model: "mistral"})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "load without model",
+			call: func() error {
+				_, err := client.LoadModel(ctx, &colossuspb.LoadModelRequest{})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "unload model not loaded",
+			call: func() error {
+				_, err := client.UnloadModel(ctx, &colossuspb.UnloadModelRequest{Model: "mistral"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != tt.wantCode {
+				t.Errorf("code = %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGRPCTimeout(t *testing.T) {
+	s, _ := newTimeoutServer(t, 50*time.Millisecond)
+	client := newGRPCClient(t, s)
+
+	_, err := client.GenerateText(context.Background(), &colossuspb.GenerateRequest{Model: "tinyllama", Prompt: "hello"})
+	if status.Code(err) != codes.DeadlineExceeded || status.Convert(err).Message() != errRequestTimeout {
+		t.Errorf("error = %v, want the request timeout", err)
+	}
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.APIKey = "secret"
+	})
+	installTestModel(t, s, "tinyllama")
+	client := newGRPCClient(t, s)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      codes.Code
+	}{
+		{"no key", "", codes.Unauthenticated},
+		{"wrong key", "Bearer wrong", codes.Unauthenticated},
+		{"key without scheme", "secret", codes.Unauthenticated},
+		{"valid key", "Bearer secret", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+
+			_, err := client.ListModels(ctx, &colossuspb.ListModelsRequest{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("ListModels: code = %s, want %s", code, tt.wantCode)
+			}
+
+			// Streaming calls are authenticated too
+			stream, err := client.StreamText(ctx, &colossuspb.GenerateRequest{Model: "tinyllama", Prompt: "hello"})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if tt.wantCode == codes.Unauthenticated && status.Code(err) != codes.Unauthenticated {
+				t.Errorf("StreamText: code = %s, want %s", status.Code(err), codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestOptionsFromProto(t *testing.T) {
+	if opts := optionsFromProto(nil); opts != nil {
+		t.Errorf("options without proto options = %+v, want nil", opts)
+	}
+
+	// An unset seed stays unset, so that the sampler picks one
+	if opts := optionsFromProto(&colossuspb.Options{}); opts.Seed != nil {
+		t.Errorf("seed = %d, want unset", *opts.Seed)
+	}
+
+	seed, topK := int64(0), int32(40)
+	opts := optionsFromProto(&colossuspb.Options{Seed: &seed, TopK: &topK, Stop: []string{"\n"}})
+	if opts.Seed == nil || *opts.Seed != 0 {
+		t.Errorf("seed = %v, want 0", opts.Seed)
+	}
+	if opts.TopK != 40 || len(opts.Stop) != 1 || opts.Stop[0] != "\n" {
+		t.Errorf("options = %+v, want top_k 40 and the stop sequence", opts)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startupState records when the server has finished starting, and whether
+// starting failed
+type startupState struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newStartupState() *startupState {
+	return &startupState{done: make(chan struct{})}
+}
+
+// finish marks startup as finished; only the first call has an effect
+func (s *startupState) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// result reports whether startup has finished, and its error if it failed
+func (s *startupState) result() (bool, error) {
+	select {
+	case <-s.done:
+		return true, s.err
+	default:
+		return false, nil
+	}
+}
+
+// health handles GET /health. It always succeeds while the process is
+// serving requests, for use as a liveness probe.
+func (s *Server) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"uptime": time.Since(s.startedAt).Round(time.Second).String(),
+	})
+}
+
+// ready handles GET /ready. It returns 503 until any preload has finished
+// and at least one model is loaded, for use as a readiness probe. Once the
+// startup timeout has passed, a server without models is ready too, so that
+// it can load them on demand.
+func (s *Server) ready(c *gin.Context) {
+	modelsLoaded := len(s.engine.LoadedModels())
+	timeout := s.config.StartupTimeout
+	timedOut := timeout > 0 && time.Since(s.startedAt) >= timeout
+
+	if s.preloading.Load() || (modelsLoaded == 0 && !timedOut) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":        "not ready",
+			"models_loaded": modelsLoaded,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "ready",
+		"models_loaded": modelsLoaded,
+	})
+}
+
+// startupProbe handles GET /startup. It returns 503 until the server has
+// started, including loading the preloaded models, for use as a startup
+// probe. A failed startup stays 503.
+func (s *Server) startupProbe(c *gin.Context) {
+	started, err := s.startup.result()
+	switch {
+	case !started:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "failed", "error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "started"})
+	}
+}
+
+// Preload loads models one after another in the background. The server
+// reports not ready until every load has finished, and startup finishes
+// once they have; it fails if a model could not be loaded. Without models,
+// startup finishes at once.
+func (s *Server) Preload(names ...string) {
+	if len(names) == 0 {
+		s.startup.finish(nil)
+		return
+	}
+
+	s.preloading.Store(true)
+
+	go func() {
+		defer s.preloading.Store(false)
+
+		var failed []string
+		for _, name := range names {
+			start := time.Now()
+			if err := s.ensureModelLoaded(context.Background(), name); err != nil {
+				logger.Errorf("Failed to preload model %s: %v", name, err)
+				failed = append(failed, name)
+				continue
+			}
+			// Start the model's idle clock as if it had just been used
+			s.trackRequest(name)()
+			logger.Infof("Preloaded model %s in %s", name, time.Since(start).Round(time.Millisecond))
+		}
+
+		if len(failed) > 0 {
+			s.startup.finish(fmt.Errorf("failed to preload %s", strings.Join(failed, ", ")))
+			return
+		}
+		s.startup.finish(nil)
+	}()
+}
+
+// WaitForStartup waits until the server has started, and returns an error
+// if starting failed or did not finish within timeout of the server being
+// created
+func (s *Server) WaitForStartup(timeout time.Duration) error {
+	timer := time.NewTimer(time.Until(s.startedAt.Add(timeout)))
+	defer timer.Stop()
+
+	select {
+	case <-s.startup.done:
+		return s.startup.err
+	case <-timer.C:
+		return fmt.Errorf("server did not start within %s", timeout)
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+)
+
+// probeResponse is the body of a health endpoint response
+type probeResponse struct {
+	Status       string `json:"status"`
+	ModelsLoaded int    `json:"models_loaded"`
+	Error        string `json:"error"`
+}
+
+// probe requests a health endpoint of s and returns its status and body
+func probe(t *testing.T, s *Server, path string) (int, probeResponse) {
+	t.Helper()
+	w := serve(s, http.MethodGet, path, "", nil)
+
+	var resp probeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s returned invalid JSON: %v", path, err)
+	}
+	return w.Code, resp
+}
+
+// waitForProbe polls a health endpoint of s until it returns code, and
+// returns its body
+func waitForProbe(t *testing.T, s *Server, path string, code int) probeResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, resp := probe(t, s, path)
+		if got == code {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %d after waiting 5s, want %d", path, got, code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthIsAlwaysOK(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	code, resp := probe(t, s, "/health")
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("/health = %d %q, want 200 ok", code, resp.Status)
+	}
+}
+
+func TestReadyOnceModelLoaded(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	code, resp := probe(t, s, "/ready")
+	if code != http.StatusServiceUnavailable || resp.Status != "not ready" {
+		t.Errorf("/ready without models = %d %q, want 503 not ready", code, resp.Status)
+	}
+
+	loadTestModel(t, s, "tinyllama")
+
+	code, resp = probe(t, s, "/ready")
+	if code != http.StatusOK || resp.Status != "ready" || resp.ModelsLoaded != 1 {
+		t.Errorf("/ready = %d %q with %d models, want 200 ready with 1", code, resp.Status, resp.ModelsLoaded)
+	}
+}
+
+func TestNotReadyWhilePreloading(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	s.preloading.Store(true)
+	if code, _ := probe(t, s, "/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while preloading = %d, want 503", code)
+	}
+
+	s.preloading.Store(false)
+	if code, _ := probe(t, s, "/ready"); code != http.StatusOK {
+		t.Errorf("/ready after preloading = %d, want 200", code)
+	}
+}
+
+func TestReadyAfterPreload(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+
+	s.Preload("tinyllama")
+
+	if resp := waitForProbe(t, s, "/ready", http.StatusOK); resp.ModelsLoaded != 1 {
+		t.Errorf("ready with %d models, want 1", resp.ModelsLoaded)
+	}
+}
+
+func TestStartupProbeLifecycle(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+
+	// Before the preloaded models load, the server is alive but neither
+	// started nor ready
+	if code, resp := probe(t, s, "/startup"); code != http.StatusServiceUnavailable || resp.Status != "starting" {
+		t.Errorf("/startup before preloading = %d %q, want 503 starting", code, resp.Status)
+	}
+	if code, _ := probe(t, s, "/health"); code != http.StatusOK {
+		t.Errorf("/health while starting = %d, want 200", code)
+	}
+
+	s.Preload("tinyllama")
+
+	if resp := waitForProbe(t, s, "/startup", http.StatusOK); resp.Status != "started" {
+		t.Errorf("/startup after preloading = %q, want started", resp.Status)
+	}
+	if code, _ := probe(t, s, "/ready"); code != http.StatusOK {
+		t.Errorf("/ready once started = %d, want 200", code)
+	}
+	if err := s.WaitForStartup(time.Second); err != nil {
+		t.Errorf("WaitForStartup: %v", err)
+	}
+}
+
+func TestStartupWithoutPreload(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	s.Preload()
+
+	if code, _ := probe(t, s, "/startup"); code != http.StatusOK {
+		t.Errorf("/startup without models to preload = %d, want 200", code)
+	}
+	if code, _ := probe(t, s, "/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready without models = %d, want 503", code)
+	}
+}
+
+func TestStartupFailsWhenPreloadFails(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	s.Preload("mistral")
+
+	if err := s.WaitForStartup(5 * time.Second); err == nil || !strings.Contains(err.Error(), "mistral") {
+		t.Errorf("WaitForStartup = %v, want the preload of mistral failed", err)
+	}
+	code, resp := probe(t, s, "/startup")
+	if code != http.StatusServiceUnavailable || resp.Status != "failed" || !strings.Contains(resp.Error, "mistral") {
+		t.Errorf("/startup = %d %q %q, want 503 failed for mistral", code, resp.Status, resp.Error)
+	}
+}
+
+func TestStartupTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.StartupTimeout = timeout
+	})
+
+	if code, _ := probe(t, s, "/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready without models = %d, want 503", code)
+	}
+
+	// Startup never finishes, as nothing is preloaded
+	if err := s.WaitForStartup(timeout); err == nil || !strings.Contains(err.Error(), "did not start") {
+		t.Errorf("WaitForStartup = %v, want a timeout", err)
+	}
+
+	// Past the startup timeout, a server without models can load them on demand
+	if code, _ := probe(t, s, "/ready"); code != http.StatusOK {
+		t.Errorf("/ready after the startup timeout = %d, want 200", code)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"time"
+)
+
+// idleCheckInterval is how often models are checked for idleness
+const idleCheckInterval = time.Minute
+
+// trackRequest records that a request for a model has started. The returned
+// function must be called once the request has finished.
+func (s *Server) trackRequest(modelName string) func() {
+	return s.loadedModels.Track(modelName)
+}
+
+// unloadIdleModels unloads the models that have had no requests in progress
+// since the given time. They are loaded again by their next request.
+func (s *Server) unloadIdleModels(cutoff time.Time) {
+	for _, name := range s.loadedModels.Idle(cutoff) {
+		s.loadedModels.Remove(name)
+		if !s.engine.IsModelLoaded(name) {
+			continue
+		}
+
+		if err := s.engine.UnloadModel(name); err != nil {
+			logger.Warnf("Failed to unload idle model %s: %v", name, err)
+			continue
+		}
+		logger.Infof("Unloaded idle model %s", name)
+	}
+}
+
+// idleUnloadLoop periodically unloads models that have been idle for longer
+// than idleTimeout
+func (s *Server) idleUnloadLoop(idleTimeout time.Duration) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.unloadIdleModels(now.Add(-idleTimeout))
+	}
+}
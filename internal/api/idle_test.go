@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+)
+
+func TestUnloadIdleModels(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.IdleUnload = time.Hour
+	})
+	installTestModel(t, s, "tinyllama")
+
+	generate := func() {
+		t.Helper()
+		w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("generate = %d: %s", w.Code, w.Body)
+		}
+	}
+
+	// The first request loads the model
+	generate()
+	if !s.engine.IsModelLoaded("tinyllama") {
+		t.Fatal("model not loaded by its first request")
+	}
+
+	// Idleness is judged against the cutoff, so moving the cutoff stands in
+	// for the clock moving on
+	tests := []struct {
+		name       string
+		cutoff     time.Time
+		wantLoaded bool
+	}{
+		{name: "used since the cutoff", cutoff: time.Now().Add(-30 * time.Minute), wantLoaded: true},
+		{name: "idle since the cutoff", cutoff: time.Now().Add(time.Minute), wantLoaded: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.unloadIdleModels(tt.cutoff)
+			if loaded := s.engine.IsModelLoaded("tinyllama"); loaded != tt.wantLoaded {
+				t.Errorf("loaded = %t, want %t", loaded, tt.wantLoaded)
+			}
+		})
+	}
+
+	// The next request loads the model again
+	generate()
+	if !s.engine.IsModelLoaded("tinyllama") {
+		t.Error("model not reloaded by the next request")
+	}
+	if models := s.loadedModels.List(); len(models) != 1 || models[0].Name != "tinyllama" {
+		t.Errorf("running models = %v, want tinyllama", models)
+	}
+}
+
+func TestUnloadIdleModelsKeepsBusyModels(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.IdleUnload = time.Hour
+	})
+	loadTestModel(t, s, "tinyllama")
+
+	// A request in progress keeps the model however long it runs
+	done := s.trackRequest("tinyllama")
+	s.unloadIdleModels(time.Now().Add(time.Hour))
+	if !s.engine.IsModelLoaded("tinyllama") {
+		t.Fatal("model unloaded while a request was in progress")
+	}
+
+	done()
+	s.unloadIdleModels(time.Now().Add(time.Hour))
+	if s.engine.IsModelLoaded("tinyllama") {
+		t.Error("model still loaded after its request finished")
+	}
+}
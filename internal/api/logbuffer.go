@@ -0,0 +1,210 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// LogBufferSize is the number of log lines the server keeps for clients of
+// GET /api/logs
+const LogBufferSize = 1000
+
+// logSubscriberBuffer is how many lines a slow subscriber may fall behind
+// before lines are dropped for it
+const logSubscriberBuffer = 256
+
+// LogBuffer is a logrus hook keeping the last log lines in a ring buffer and
+// passing new lines on to subscribers
+type LogBuffer struct {
+	// formatter formats lines like the server log, without colors
+	formatter logrus.Formatter
+
+	mutex sync.Mutex
+	lines []logLine
+	next  int
+	full  bool
+
+	subscribers map[chan types.LogEntry]logrus.Level
+}
+
+// logLine is a formatted log line with the level it was logged at
+type logLine struct {
+	level logrus.Level
+	text  string
+}
+
+// NewLogBuffer creates a log buffer keeping the last capacity lines,
+// formatted as JSON when the standard logger logs JSON
+func NewLogBuffer(capacity int) *LogBuffer {
+	var formatter logrus.Formatter = &logrus.TextFormatter{DisableColors: true}
+	if f, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter); ok {
+		formatter = f
+	}
+
+	return &LogBuffer{
+		formatter:   formatter,
+		lines:       make([]logLine, capacity),
+		subscribers: make(map[chan types.LogEntry]logrus.Level),
+	}
+}
+
+// Levels returns the levels the hook is fired for
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire formats an entry and adds it to the buffer
+func (b *LogBuffer) Fire(entry *logrus.Entry) error {
+	data, err := b.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	b.add(logLine{level: entry.Level, text: strings.TrimRight(string(data), "\n")})
+	return nil
+}
+
+// add appends a line, overwriting the oldest one once the buffer is full
+func (b *LogBuffer) add(line logLine) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.lines) > 0 {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+
+	for ch, level := range b.subscribers {
+		if line.level > level {
+			continue
+		}
+		// Subscribers that fall behind miss lines rather than block logging
+		select {
+		case ch <- logEntry(line):
+		default:
+		}
+	}
+}
+
+// Lines returns the last n buffered lines logged at level or a more severe
+// level, oldest first. All matching lines are returned when n is not positive.
+func (b *LogBuffer) Lines(level logrus.Level, n int) []types.LogEntry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.linesLocked(level, n)
+}
+
+// linesLocked implements Lines. The mutex must be held.
+func (b *LogBuffer) linesLocked(level logrus.Level, n int) []types.LogEntry {
+	ordered := b.lines[:b.next]
+	if b.full {
+		ordered = append(append([]logLine(nil), b.lines[b.next:]...), b.lines[:b.next]...)
+	}
+
+	var entries []types.LogEntry
+	for _, line := range ordered {
+		if line.level <= level {
+			entries = append(entries, logEntry(line))
+		}
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// Subscribe returns the last n buffered lines at level or a more severe
+// level, like Lines, and a channel receiving the lines logged after them. The
+// returned function unsubscribes and closes the channel.
+func (b *LogBuffer) Subscribe(level logrus.Level, n int) ([]types.LogEntry, <-chan types.LogEntry, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	ch := make(chan types.LogEntry, logSubscriberBuffer)
+	b.subscribers[ch] = level
+	unsubscribe := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return b.linesLocked(level, n), ch, unsubscribe
+}
+
+// logEntry converts a buffered line to its API representation
+func logEntry(line logLine) types.LogEntry {
+	return types.LogEntry{Level: line.level.String(), Line: line.text}
+}
+
+// streamLogs handles GET /api/logs, sending the buffered log lines as
+// server-sent events. The level query parameter (debug, info, warn or error,
+// default info) filters the lines, lines limits how many buffered lines are
+// sent, and with follow=true new lines are streamed until the client
+// disconnects.
+func (s *Server) streamLogs(c *gin.Context) {
+	level := logrus.InfoLevel
+	if name := c.Query("level"); name != "" {
+		var err error
+		if level, err = logrus.ParseLevel(name); err != nil {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error: fmt.Sprintf("invalid level %q: must be debug, info, warn or error", name),
+			})
+			return
+		}
+	}
+	lines, err := strconv.Atoi(c.DefaultQuery("lines", "0"))
+	if err != nil || lines < 0 {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "lines must be a non-negative number",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	if c.Query("follow") != "true" {
+		for _, entry := range s.logs.Lines(level, lines) {
+			if err := writeSSEData(c, entry); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+		return
+	}
+
+	buffered, entries, unsubscribe := s.logs.Subscribe(level, lines)
+	defer unsubscribe()
+
+	for _, entry := range buffered {
+		if err := writeSSEData(c, entry); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	for {
+		select {
+		case entry := <-entries:
+			if err := writeSSEData(c, entry); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lineTexts returns the text of log entries
+func lineTexts(entries []types.LogEntry) []string {
+	var texts []string
+	for _, entry := range entries {
+		texts = append(texts, entry.Line)
+	}
+	return texts
+}
+
+func TestLogBufferWraps(t *testing.T) {
+	tests := []struct {
+		name  string
+		added int
+		level logrus.Level
+		n     int
+		want  []string
+	}{
+		{name: "not full", added: 2, level: logrus.DebugLevel, want: []string{"line 1", "line 2"}},
+		{name: "full", added: 3, level: logrus.DebugLevel, want: []string{"line 1", "line 2", "line 3"}},
+		{name: "wrapped", added: 5, level: logrus.DebugLevel, want: []string{"line 3", "line 4", "line 5"}},
+		{name: "wrapped twice", added: 7, level: logrus.DebugLevel, want: []string{"line 5", "line 6", "line 7"}},
+		{name: "last lines", added: 5, level: logrus.DebugLevel, n: 2, want: []string{"line 4", "line 5"}},
+		{name: "level", added: 5, level: logrus.WarnLevel, want: []string{"line 4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every fourth line is a warning, the others debug lines
+			b := NewLogBuffer(3)
+			for i := 1; i <= tt.added; i++ {
+				level := logrus.DebugLevel
+				if i%4 == 0 {
+					level = logrus.WarnLevel
+				}
+				b.add(logLine{level: level, text: fmt.Sprintf("line %d", i)})
+			}
+
+			if got := lineTexts(b.Lines(tt.level, tt.n)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogBufferSubscribe(t *testing.T) {
+	b := NewLogBuffer(10)
+	b.add(logLine{level: logrus.InfoLevel, text: "before"})
+
+	buffered, entries, unsubscribe := b.Subscribe(logrus.InfoLevel, 0)
+	if got := lineTexts(buffered); !reflect.DeepEqual(got, []string{"before"}) {
+		t.Errorf("buffered lines = %q, want [before]", got)
+	}
+
+	b.add(logLine{level: logrus.DebugLevel, text: "filtered"})
+	b.add(logLine{level: logrus.ErrorLevel, text: "after"})
+	select {
+	case entry := <-entries:
+		if entry.Line != "after" || entry.Level != "error" {
+			t.Errorf("received %+v, want the error line logged after subscribing", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no line received")
+	}
+
+	unsubscribe()
+	if _, ok := <-entries; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	b.add(logLine{level: logrus.ErrorLevel, text: "unsubscribed"})
+}
+
+func TestStreamLogsFollow(t *testing.T) {
+	s := newTestServer(t, nil)
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+
+	s.logs.add(logLine{level: logrus.WarnLevel, text: "old warning"})
+
+	resp, err := http.Get(srv.URL + "/api/logs?follow=true&level=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Events are read in the background, as the stream does not end
+	events := make(chan types.LogEntry)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var entry types.LogEntry
+			if err := json.Unmarshal([]byte(data), &entry); err == nil {
+				events <- entry
+			}
+		}
+	}()
+
+	// The buffered warning is replayed, then lines logged after connecting
+	// are streamed
+	s.logs.add(logLine{level: logrus.InfoLevel, text: "below the level"})
+	s.logs.add(logLine{level: logrus.WarnLevel, text: "new warning"})
+	for _, want := range []string{"old warning", "new warning"} {
+		select {
+		case entry := <-events:
+			if entry.Line != want || entry.Level != "warning" {
+				t.Errorf("received %+v, want %q", entry, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q not streamed after 5s", want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"colossus-cli/internal/mcp"
+	"colossus-cli/internal/types"
+)
+
+// mcpBackend serves the models and prompt templates of the server to MCP
+// clients. Generations are queued and recorded like those of /api/chat.
+type mcpBackend struct {
+	server *Server
+}
+
+// MCPBackend returns the backend of an MCP server running alongside the API
+func (s *Server) MCPBackend() mcp.Backend {
+	return &mcpBackend{server: s}
+}
+
+// ListModels returns the installed models
+func (b *mcpBackend) ListModels() ([]types.ModelInfo, error) {
+	return b.server.modelManager.ListModels()
+}
+
+// ListPrompts returns the named prompt templates
+func (b *mcpBackend) ListPrompts() ([]types.PromptTemplate, error) {
+	return b.server.templates.List()
+}
+
+// RenderPrompt renders a named prompt template
+func (b *mcpBackend) RenderPrompt(name string, vars map[string]string) (string, error) {
+	return b.server.templates.Render(name, vars)
+}
+
+// Chat generates the next message of a conversation, waiting for a slot of
+// the model and loading it if needed
+func (b *mcpBackend) Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error) {
+	s := b.server
+
+	release, err := s.acquireModel(ctx, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.ensureModelLoaded(ctx, req.Model); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.withRequestTimeout(ctx, req.Model)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.engine.Chat(ctx, req)
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		return nil, errors.New(inferenceError(err))
+	}
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), resp.Message.Content, time.Since(start))
+	return resp, nil
+}
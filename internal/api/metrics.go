@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serverMetrics holds the Prometheus metrics exported on /metrics
+type serverMetrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	tokensGenerated *prometheus.CounterVec
+	tokensPerSecond *prometheus.GaugeVec
+
+	grpcRequests        *prometheus.CounterVec
+	grpcRequestDuration *prometheus.HistogramVec
+}
+
+// newServerMetrics creates the server metrics in their own registry, along
+// with the standard Go runtime and process metrics
+func newServerMetrics(engine inference.InferenceEngine) *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "colossus_requests_total",
+			Help: "Total number of HTTP requests by endpoint and status code.",
+		}, []string{"endpoint", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "colossus_request_duration_seconds",
+			Help:    "HTTP request duration in seconds by endpoint.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"endpoint"}),
+		tokensGenerated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "colossus_tokens_generated_total",
+			Help: "Total number of tokens generated by model.",
+		}, []string{"model"}),
+		tokensPerSecond: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "colossus_tokens_per_second",
+			Help: "Generation speed of the most recent request by model.",
+		}, []string{"model"}),
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "colossus_grpc_requests_total",
+			Help: "Total number of gRPC requests by method and status code.",
+		}, []string{"method", "code"}),
+		grpcRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "colossus_grpc_request_duration_seconds",
+			Help:    "gRPC request duration in seconds by method.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.tokensGenerated,
+		m.tokensPerSecond,
+		m.grpcRequests,
+		m.grpcRequestDuration,
+		newModelCollector(engine),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// middleware records the count and duration of every request. Endpoints are
+// labelled with their route pattern to keep the label set bounded.
+func (m *serverMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+
+		m.requests.WithLabelValues(endpoint, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+// handler serves the metrics in the Prometheus text format
+func (m *serverMetrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// observeGeneration records the tokens generated by one request
+func (m *serverMetrics) observeGeneration(model string, tokens int, elapsed time.Duration) {
+	m.tokensGenerated.WithLabelValues(model).Add(float64(tokens))
+	if elapsed > 0 {
+		m.tokensPerSecond.WithLabelValues(model).Set(float64(tokens) / elapsed.Seconds())
+	}
+}
+
+// recordGeneration updates the model statistics and token metrics after a
+// generation request and records it if it was slow. Token counts are
+// obtained by tokenizing the prompt and the generated text.
+func (s *Server) recordGeneration(ctx context.Context, model, prompt, text string, elapsed time.Duration) {
+	promptTokens := s.countTokens(model, prompt)
+	tokens := s.countTokens(model, text)
+	s.recordStats(model, promptTokens, tokens, elapsed)
+
+	if s.metrics != nil && tokens > 0 {
+		s.metrics.observeGeneration(model, tokens, elapsed)
+	}
+	if s.config.SlowQueryThreshold > 0 && elapsed > s.config.SlowQueryThreshold {
+		s.recordSlowQuery(ctx, model, promptTokens, tokens, elapsed)
+	}
+}
+
+// countTokens returns the number of tokens in a text, or 0 if it cannot be
+// tokenized
+func (s *Server) countTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	tokens, err := s.engine.Tokenize(&types.TokenizeRequest{Model: model, Prompt: text})
+	if err != nil {
+		return 0
+	}
+	return tokens.Count
+}
+
+// modelCollector reports the loaded models at scrape time, so the metrics
+// always match the engine's state
+type modelCollector struct {
+	engine inference.InferenceEngine
+	loaded *prometheus.Desc
+	memory *prometheus.Desc
+}
+
+func newModelCollector(engine inference.InferenceEngine) *modelCollector {
+	return &modelCollector{
+		engine: engine,
+		loaded: prometheus.NewDesc("colossus_models_loaded",
+			"Number of models currently loaded.", nil, nil),
+		memory: prometheus.NewDesc("colossus_model_memory_bytes",
+			"Estimated memory used by a loaded model.", []string{"model"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *modelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.loaded
+	ch <- c.memory
+}
+
+// Collect implements prometheus.Collector
+func (c *modelCollector) Collect(ch chan<- prometheus.Metric) {
+	models := c.engine.LoadedModels()
+	ch <- prometheus.MustNewConstMetric(c.loaded, prometheus.GaugeValue, float64(len(models)))
+	for _, info := range models {
+		ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(info.MemoryUsed), info.Name)
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/config"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Metrics = true
+	})
+	loadTestModel(t, s, "tinyllama")
+
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("generate status = %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(s, http.MethodGet, "/metrics", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics status = %d", w.Code)
+	}
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`colossus_requests_total{endpoint="/api/generate",status="200"} 1`,
+		`colossus_request_duration_seconds_count{endpoint="/api/generate"} 1`,
+		`colossus_tokens_generated_total{model="tinyllama"}`,
+		`colossus_tokens_per_second{model="tinyllama"}`,
+		`colossus_models_loaded 1`,
+		`colossus_model_memory_bytes{model="tinyllama"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	if w := serve(s, http.MethodGet, "/metrics", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 with metrics disabled", w.Code)
+	}
+}
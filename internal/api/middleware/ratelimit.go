@@ -0,0 +1,137 @@
+// Package middleware provides Gin middleware for the Colossus API server
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterEntry is the token bucket of one client
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds
+}
+
+// RateLimiter limits the request rate of each client with a token bucket.
+// Clients are identified by their API key when KeyByAPIKey is set and a
+// Bearer token is present, and by IP address otherwise.
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	// KeyByAPIKey identifies clients by API key. It should only be set when
+	// the keys are authenticated before this middleware runs.
+	KeyByAPIKey bool
+
+	limiters sync.Map // client key -> *limiterEntry
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRateLimiter creates a rate limiter allowing requestsPerSecond per client
+// with bursts of up to burst requests. A burst below 1 defaults to the rate
+// rounded up.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(requestsPerSecond)))
+	}
+
+	return &RateLimiter{
+		limit: rate.Limit(requestsPerSecond),
+		burst: burst,
+		stop:  make(chan struct{}),
+	}
+}
+
+// Handler returns the middleware. Requests over the limit are rejected with
+// HTTP 429 and a Retry-After header giving the seconds until the next token.
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservation := l.limiterFor(l.clientKey(c)).Reserve()
+		if !reservation.OK() {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		if delay := reservation.Delay(); delay > 0 {
+			// Give the token back: the request is rejected, not delayed
+			reservation.Cancel()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// StartCleanup removes the limiters of clients idle for longer than interval,
+// checking every interval until Stop is called
+func (l *RateLimiter) StartCleanup(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				l.Cleanup(interval)
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Cleanup removes the limiters of clients idle for longer than maxIdle
+func (l *RateLimiter) Cleanup(maxIdle time.Duration) {
+	cutoff := time.Now().Add(-maxIdle).UnixNano()
+	l.limiters.Range(func(key, value any) bool {
+		if value.(*limiterEntry).lastSeen.Load() < cutoff {
+			l.limiters.Delete(key)
+		}
+		return true
+	})
+}
+
+// Stop stops the cleanup goroutine
+func (l *RateLimiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+// limiterFor returns the limiter of a client, creating it on first use
+func (l *RateLimiter) limiterFor(key string) *rate.Limiter {
+	value, ok := l.limiters.Load(key)
+	if !ok {
+		value, _ = l.limiters.LoadOrStore(key, &limiterEntry{
+			limiter: rate.NewLimiter(l.limit, l.burst),
+		})
+	}
+
+	entry := value.(*limiterEntry)
+	entry.lastSeen.Store(time.Now().UnixNano())
+	return entry.limiter
+}
+
+// clientKey identifies the client making a request
+func (l *RateLimiter) clientKey(c *gin.Context) string {
+	if l.KeyByAPIKey {
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && token != "" {
+			return "key:" + token
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
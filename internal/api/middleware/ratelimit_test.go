@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLimitedRouter returns a router serving GET / behind limiter
+func newLimitedRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(limiter.Handler())
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+// get sends GET / to r from remoteAddr with an optional API key
+func get(r http.Handler, remoteAddr, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiterRejectsBurstWithRetryAfter(t *testing.T) {
+	const burst = 5
+	r := newLimitedRouter(NewRateLimiter(0.5, burst))
+
+	// Fire twice the burst at once: the burst passes, the rest is rejected
+	const requests = 2 * burst
+	codes := make(chan *httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- get(r, "192.0.2.1:1234", "")
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok, limited := 0, 0
+	for w := range codes {
+		switch w.Code {
+		case http.StatusOK:
+			ok++
+		case http.StatusTooManyRequests:
+			limited++
+			// One token every 2 seconds
+			retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			if err != nil || retryAfter < 1 || retryAfter > 2 {
+				t.Errorf("Retry-After = %q, want 1 or 2 seconds", w.Header().Get("Retry-After"))
+			}
+		default:
+			t.Errorf("unexpected status %d", w.Code)
+		}
+	}
+	if ok != burst || limited != requests-burst {
+		t.Errorf("%d allowed and %d limited, want %d and %d", ok, limited, burst, requests-burst)
+	}
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1)
+	limiter.KeyByAPIKey = true
+	r := newLimitedRouter(limiter)
+
+	steps := []struct {
+		name       string
+		remoteAddr string
+		apiKey     string
+		want       int
+	}{
+		{name: "first IP", remoteAddr: "192.0.2.1:1234", want: http.StatusOK},
+		{name: "first IP again", remoteAddr: "192.0.2.1:5678", want: http.StatusTooManyRequests},
+		{name: "second IP", remoteAddr: "192.0.2.2:1234", want: http.StatusOK},
+		{name: "key from limited IP", remoteAddr: "192.0.2.1:1234", apiKey: "alpha", want: http.StatusOK},
+		{name: "same key from another IP", remoteAddr: "192.0.2.3:1234", apiKey: "alpha", want: http.StatusTooManyRequests},
+		{name: "other key", remoteAddr: "192.0.2.3:1234", apiKey: "beta", want: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		if w := get(r, step.remoteAddr, step.apiKey); w.Code != step.want {
+			t.Errorf("%s: status = %d, want %d", step.name, w.Code, step.want)
+		}
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1)
+	r := newLimitedRouter(limiter)
+
+	if w := get(r, "192.0.2.1:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w := get(r, "192.0.2.1:1234", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+
+	// Pruning the idle client's limiter gives it a fresh bucket
+	time.Sleep(10 * time.Millisecond)
+	limiter.Cleanup(time.Millisecond)
+	if w := get(r, "192.0.2.1:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("status after cleanup = %d, want 200", w.Code)
+	}
+}
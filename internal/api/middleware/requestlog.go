@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"colossus-cli/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID of a request. An ID sent by the client is
+// kept, so that its logs can be matched with the server's.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestLog logs every request with its method, path, status and duration.
+// Each request is given an ID, returned in the X-Request-Id header and added
+// to the entries logged with the request's context.
+func RequestLog(logger *logrus.Entry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = NewRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		fields := logrus.Fields{
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"status":      c.Writer.Status(),
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":   c.ClientIP(),
+		}
+		// Handlers replace the request with one carrying the model, if any
+		logger.WithContext(c.Request.Context()).WithFields(fields).Info("Request handled")
+	}
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
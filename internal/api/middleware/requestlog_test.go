@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// newLoggedRouter returns a router serving POST /api/generate behind the
+// request log, and the hook recording the entries of the standard logger
+// until the test ends. The handler sets the model of the request like the API
+// handlers do.
+func newLoggedRouter(t *testing.T) (*gin.Engine, *test.Hook) {
+	gin.SetMode(gin.TestMode)
+	if err := logging.Setup(logging.FormatText); err != nil {
+		t.Fatal(err)
+	}
+	hook := test.NewLocal(logrus.StandardLogger())
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks)) })
+
+	r := gin.New()
+	r.Use(RequestLog(logging.Component("api")))
+	r.POST("/api/generate", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.WithModel(c.Request.Context(), "llama3"))
+		c.Status(http.StatusNotFound)
+	})
+	return r, hook
+}
+
+func TestRequestLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		wantID    string
+	}{
+		{name: "generated ID"},
+		{name: "client ID", requestID: "client-42", wantID: "client-42"},
+		{name: "overlong client ID", requestID: strings.Repeat("x", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, hook := newLoggedRouter(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/generate", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.wantID != "" && id != tt.wantID {
+				t.Errorf("request ID = %q, want %q", id, tt.wantID)
+			}
+			if tt.wantID == "" && len(id) != 16 {
+				t.Errorf("request ID = %q, want a generated ID", id)
+			}
+
+			entries := hook.AllEntries()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			want := logrus.Fields{
+				"component":  "api",
+				"request_id": id,
+				"model":      "llama3",
+				"method":     http.MethodPost,
+				"path":       "/api/generate",
+				"status":     http.StatusNotFound,
+			}
+			for key, value := range want {
+				if entry.Data[key] != value {
+					t.Errorf("%s = %v, want %v", key, entry.Data[key], value)
+				}
+			}
+			if _, ok := entry.Data["duration_ms"].(float64); !ok {
+				t.Errorf("duration_ms = %v, want a duration", entry.Data["duration_ms"])
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ollamaVersion is the Ollama version whose API the compatibility routes
+// follow, reported by /api/version
+const ollamaVersion = "0.5.0"
+
+// ollamaListModels handles GET /api/tags in the Ollama format
+func (s *Server) ollamaListModels(c *gin.Context) {
+	models, err := s.modelManager.ListModels()
+	if err != nil {
+		logger.Errorf("Failed to list models: %v", err)
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: "failed to list models"})
+		return
+	}
+
+	result := make([]types.OllamaModel, 0, len(models))
+	for _, m := range models {
+		result = append(result, types.OllamaModel{
+			Name:       m.Name,
+			Model:      m.Name,
+			ModifiedAt: m.ModifiedAt,
+			Size:       m.Size,
+			Digest:     ollamaDigest(m),
+			Details: types.OllamaModelDetails{
+				Format:            "gguf",
+				QuantizationLevel: m.Quantization,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, types.OllamaTagsResponse{Models: result})
+}
+
+// ollamaPullModel handles POST /api/pull in the Ollama format, streaming the
+// download progress unless stream is false
+func (s *Server) ollamaPullModel(c *gin.Context) {
+	var req types.OllamaModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ModelName() == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "model is required"})
+		return
+	}
+	name := req.ModelName()
+	ctx, cancel := s.downloadContext(c)
+	defer cancel()
+
+	if req.Stream != nil && !*req.Stream {
+		if err := s.modelManager.PullModel(ctx, name); err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, types.PullResponse{Status: "success"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(c.Writer)
+	send := func(resp interface{}) {
+		encoder.Encode(resp)
+		c.Writer.Flush()
+	}
+
+	send(types.PullResponse{Status: "pulling manifest"})
+	err := s.modelManager.PullModelWithProgress(ctx, name, func(progress model.DownloadProgress) error {
+		send(types.PullResponse{
+			Status:    "pulling " + progress.FileName,
+			Digest:    progress.FileName,
+			Total:     progress.Total,
+			Completed: progress.Downloaded,
+		})
+		return nil
+	})
+	if err != nil {
+		send(types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	send(types.PullResponse{Status: "verifying sha256 digest"})
+	send(types.PullResponse{Status: "writing manifest"})
+	send(types.PullResponse{Status: "success"})
+}
+
+// ollamaDeleteModel handles DELETE /api/delete in the Ollama format
+func (s *Server) ollamaDeleteModel(c *gin.Context) {
+	var req types.OllamaModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ModelName() == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "model is required"})
+		return
+	}
+	name := strings.TrimSuffix(req.ModelName(), ":latest")
+
+	if err := s.modelManager.RemoveModel(name); err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: fmt.Sprintf("model '%s' not found", req.ModelName())})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ollamaCopyModel handles POST /api/copy
+func (s *Server) ollamaCopyModel(c *gin.Context) {
+	var req types.OllamaCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Source == "" || req.Destination == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "source and destination are required"})
+		return
+	}
+	source := strings.TrimSuffix(req.Source, ":latest")
+
+	if !strings.Contains(source, ":") {
+		if _, err := s.modelManager.GetModelPath(source); err != nil {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{Error: fmt.Sprintf("model '%s' not found", req.Source)})
+			return
+		}
+	}
+
+	if err := s.modelManager.CopyModel(source, strings.TrimSuffix(req.Destination, ":latest")); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ollamaShowModel handles POST /api/show, describing a model from its GGUF
+// header and options file
+func (s *Server) ollamaShowModel(c *gin.Context) {
+	var req types.OllamaModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ModelName() == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{Error: "model is required"})
+		return
+	}
+
+	path, err := s.modelManager.GetModelPath(req.ModelName())
+	if err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{Error: fmt.Sprintf("model '%s' not found", req.ModelName())})
+		return
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	info, err := model.ValidateModel(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	options, err := s.modelManager.GetModelOptions(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := types.OllamaShowResponse{
+		Details: types.OllamaModelDetails{
+			Format:            strings.ToLower(info.Format.String()),
+			Family:            info.Architecture,
+			ParameterSize:     formatParameterSize(info.Parameters),
+			QuantizationLevel: info.Quantization,
+		},
+		ModelInfo:  ollamaModelInfo(info, req.Verbose),
+		ModifiedAt: fileInfo.ModTime(),
+	}
+	if info.Architecture != "" {
+		resp.Details.Families = []string{info.Architecture}
+	}
+	if template, ok := info.Metadata["tokenizer.chat_template"].(string); ok {
+		resp.Template = template
+	}
+
+	var parameters []string
+	if options != nil {
+		resp.System = options.SystemPrompt
+		if options.ChatTemplate != "" {
+			resp.Template = options.ChatTemplate
+		}
+		if options.ContextSize > 0 {
+			parameters = append(parameters, fmt.Sprintf("num_ctx %d", options.ContextSize))
+		}
+		for _, stop := range options.StopSequences {
+			parameters = append(parameters, fmt.Sprintf("stop %q", stop))
+		}
+	}
+	resp.Parameters = strings.Join(parameters, "\n")
+	resp.Modelfile = ollamaModelfile(path, &resp, parameters)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ollamaVersion handles GET /api/version
+func (s *Server) ollamaVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, types.OllamaVersionResponse{Version: ollamaVersion})
+}
+
+// ollamaDigest returns the digest of a pulled model, or a stable identifier
+// in its place for models that were not pulled
+func ollamaDigest(m types.ModelInfo) string {
+	if m.Digest != "" {
+		return strings.TrimPrefix(m.Digest, "sha256:")
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", m.Name, m.Size, m.ModifiedAt.UnixNano())))
+	return hex.EncodeToString(sum[:])
+}
+
+// ollamaModelInfo returns the GGUF metadata of a model. Arrays, such as the
+// tokenizer vocabulary, are only included when verbose is set.
+func ollamaModelInfo(info *model.ModelInfo, verbose bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(info.Metadata)+1)
+	for key, value := range info.Metadata {
+		if array, ok := value.(model.GGUFArray); ok {
+			if !verbose {
+				continue
+			}
+			value = array.Values
+		}
+		result[key] = value
+	}
+	if _, ok := result["general.parameter_count"]; !ok && info.Parameters > 0 {
+		result["general.parameter_count"] = info.Parameters
+	}
+	return result
+}
+
+// ollamaModelfile describes a model as an Ollama Modelfile
+func ollamaModelfile(path string, resp *types.OllamaShowResponse, parameters []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Modelfile generated by colossus\nFROM %s\n", filepath.ToSlash(path))
+	if resp.Template != "" {
+		fmt.Fprintf(&b, "TEMPLATE %q\n", resp.Template)
+	}
+	if resp.System != "" {
+		fmt.Fprintf(&b, "SYSTEM %q\n", resp.System)
+	}
+	sort.Strings(parameters)
+	for _, parameter := range parameters {
+		fmt.Fprintf(&b, "PARAMETER %s\n", parameter)
+	}
+	return b.String()
+}
+
+// formatParameterSize formats a parameter count the way Ollama does, e.g. "7.2B"
+func formatParameterSize(parameters int64) string {
+	switch {
+	case parameters <= 0:
+		return ""
+	case parameters >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(parameters)/1e9)
+	case parameters >= 1e6:
+		return fmt.Sprintf("%.0fM", float64(parameters)/1e6)
+	default:
+		return fmt.Sprintf("%.0fK", float64(parameters)/1e3)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/types"
+)
+
+// newOllamaServer creates a test server in Ollama compatibility mode
+func newOllamaServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServer(t, func(cfg *config.Config) { cfg.OllamaCompat = true })
+}
+
+// ollamaTags returns the models listed by GET /api/tags
+func ollamaTags(t *testing.T, s *Server) []types.OllamaModel {
+	t.Helper()
+	w := serve(s, http.MethodGet, "/api/tags", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.OllamaTagsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return resp.Models
+}
+
+func TestOllamaCompatRoutes(t *testing.T) {
+	tests := []struct {
+		compat bool
+		want   int
+	}{
+		{compat: true, want: http.StatusOK},
+		{compat: false, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		s := newTestServer(t, func(cfg *config.Config) { cfg.OllamaCompat = tt.compat })
+		for _, route := range []struct{ method, path string }{{http.MethodHead, "/"}, {http.MethodGet, "/api/version"}} {
+			if w := serve(s, route.method, route.path, "", nil); w.Code != tt.want {
+				t.Errorf("ollama_compat %t: %s %s status = %d, want %d", tt.compat, route.method, route.path, w.Code, tt.want)
+			}
+		}
+	}
+
+	s := newOllamaServer(t)
+	w := serve(s, http.MethodGet, "/api/version", "", nil)
+	var version types.OllamaVersionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &version); err != nil || version.Version != ollamaVersion {
+		t.Errorf("version = %s, %v, want %s", w.Body, err, ollamaVersion)
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	s := newOllamaServer(t)
+	installTestModel(t, s, "llama3", ggufKV{"general.architecture", "llama"}, ggufKV{"general.file_type", uint32(15)})
+
+	models := ollamaTags(t, s)
+	if len(models) != 1 {
+		t.Fatalf("listed %d models, want 1", len(models))
+	}
+	m := models[0]
+	if m.Name != "llama3" || m.Model != "llama3" || m.Size == 0 || m.ModifiedAt.IsZero() {
+		t.Errorf("model = %+v, want llama3 with its size and time", m)
+	}
+	if len(m.Digest) != 64 || m.Details.Format != "gguf" || m.Details.QuantizationLevel != "Q4_K_M" {
+		t.Errorf("model = %+v, want a digest and the GGUF details", m)
+	}
+
+	// The digest is stable across listings
+	if again := ollamaTags(t, s)[0].Digest; again != m.Digest {
+		t.Errorf("digest changed from %s to %s", m.Digest, again)
+	}
+}
+
+func TestOllamaShowModel(t *testing.T) {
+	s := newOllamaServer(t)
+	path := installTestModel(t, s, "llama3",
+		ggufKV{"general.architecture", "llama"},
+		ggufKV{"general.file_type", uint32(15)},
+		ggufKV{"llama.context_length", uint32(8192)},
+		ggufKV{"tokenizer.chat_template", "{{ messages }}"},
+	)
+	options := "system_prompt: Be brief.\ncontext_size: 4096\nstop_sequences: [\"<|eot_id|>\"]\n"
+	if err := os.WriteFile(model.ModelOptionsPath(path), []byte(options), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Older clients name the model with "name", and ":latest" is the model itself
+	w := serve(s, http.MethodPost, "/api/show", `{"name": "llama3:latest"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.OllamaShowResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	if resp.Details.Format != "gguf" || resp.Details.Family != "llama" || resp.Details.QuantizationLevel != "Q4_K_M" {
+		t.Errorf("details = %+v, want a Q4_K_M llama GGUF model", resp.Details)
+	}
+	if resp.Template != "{{ messages }}" || resp.System != "Be brief." {
+		t.Errorf("template %q and system %q, want those of the model", resp.Template, resp.System)
+	}
+	if resp.Parameters != "num_ctx 4096\nstop \"<|eot_id|>\"" {
+		t.Errorf("parameters = %q", resp.Parameters)
+	}
+	if resp.ModelInfo["llama.context_length"] != float64(8192) {
+		t.Errorf("model_info = %v, want the GGUF metadata", resp.ModelInfo)
+	}
+	for _, want := range []string{"FROM ", "TEMPLATE \"{{ messages }}\"", "SYSTEM \"Be brief.\"", "PARAMETER num_ctx 4096"} {
+		if !strings.Contains(resp.Modelfile, want) {
+			t.Errorf("Modelfile lacks %q:\n%s", want, resp.Modelfile)
+		}
+	}
+
+	if w := serve(s, http.MethodPost, "/api/show", `{"model": "mistral"}`, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown model: status = %d, want 404", w.Code)
+	}
+	if w := serve(s, http.MethodPost, "/api/show", `{}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no model: status = %d, want 400", w.Code)
+	}
+}
+
+func TestOllamaCopyAndDeleteModel(t *testing.T) {
+	s := newOllamaServer(t)
+	installTestModel(t, s, "llama3")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "copy", method: http.MethodPost, path: "/api/copy", body: `{"source": "llama3:latest", "destination": "llama3-backup"}`, want: http.StatusOK},
+		{name: "copy unknown model", method: http.MethodPost, path: "/api/copy", body: `{"source": "mistral", "destination": "mistral-backup"}`, want: http.StatusNotFound},
+		{name: "copy without destination", method: http.MethodPost, path: "/api/copy", body: `{"source": "llama3"}`, want: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, path: "/api/delete", body: `{"model": "llama3-backup:latest"}`, want: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/api/delete", body: `{"model": "llama3-backup"}`, want: http.StatusNotFound},
+		{name: "delete without model", method: http.MethodDelete, path: "/api/delete", body: `{}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := serve(s, tt.method, tt.path, tt.body, nil)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if tt.name == "copy" {
+			if models := ollamaTags(t, s); len(models) != 2 {
+				t.Errorf("listed %d models after copying, want 2", len(models))
+			}
+		}
+	}
+
+	models := ollamaTags(t, s)
+	if len(models) != 1 || models[0].Name != "llama3" {
+		t.Errorf("models = %+v, want only llama3", models)
+	}
+}
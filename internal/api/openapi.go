@@ -0,0 +1,447 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"colossus-cli/internal/buildinfo"
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiOperation documents a route in the OpenAPI specification. Request and
+// response are values of the body types, whose schemas are derived from
+// their JSON encoding.
+type apiOperation struct {
+	method      string
+	path        string
+	tag         string
+	summary     string
+	description string
+	request     interface{}
+	response    interface{}
+	example     interface{}
+
+	// contentType of the response, JSON when empty
+	contentType string
+
+	// streams marks operations that send NDJSON chunks of the response when
+	// the request sets "stream"
+	streams bool
+}
+
+// messageResponse is the body of responses that only confirm an action
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// healthResponse is the body of /health, /ready and /startup responses
+type healthResponse struct {
+	Status       string `json:"status"`
+	Uptime       string `json:"uptime,omitempty"`
+	ModelsLoaded int    `json:"models_loaded,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// apiOperations returns the routes the router registers for cfg, in the
+// order they are documented
+func apiOperations(cfg *config.Config) []apiOperation {
+	ops := []apiOperation{
+		{method: http.MethodGet, path: "/", tag: "health", summary: "Check that the server is running",
+			response: struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			}{}},
+		{method: http.MethodGet, path: "/health", tag: "health", summary: "Liveness probe", response: healthResponse{}},
+		{method: http.MethodGet, path: "/ready", tag: "health", summary: "Readiness probe",
+			description: "Returns 503 until any preload has finished and a model is loaded or the startup timeout has passed.", response: healthResponse{}},
+		{method: http.MethodGet, path: "/startup", tag: "health", summary: "Startup probe",
+			description: "Returns 503 until the server has started, including loading the preloaded models.", response: healthResponse{}},
+	}
+	if cfg.Metrics {
+		ops = append(ops, apiOperation{method: http.MethodGet, path: "/metrics", tag: "health",
+			summary: "Prometheus metrics", contentType: "text/plain"})
+	}
+
+	if cfg.OllamaCompat {
+		ops = append(ops,
+			apiOperation{method: http.MethodHead, path: "/", tag: "health", summary: "Check that the server is running"},
+			apiOperation{method: http.MethodGet, path: "/api/tags", tag: "models", summary: "List installed models",
+				response: types.OllamaTagsResponse{}},
+			apiOperation{method: http.MethodPost, path: "/api/pull", tag: "models", summary: "Pull a model",
+				request: types.OllamaModelRequest{}, response: types.PullResponse{}, streams: true,
+				example: map[string]interface{}{"model": "tinyllama"}},
+			apiOperation{method: http.MethodDelete, path: "/api/delete", tag: "models", summary: "Remove a model",
+				request: types.OllamaModelRequest{}, example: map[string]interface{}{"model": "tinyllama"}},
+			apiOperation{method: http.MethodPost, path: "/api/copy", tag: "models", summary: "Copy a model under a new name",
+				request: types.OllamaCopyRequest{},
+				example: map[string]interface{}{"source": "tinyllama", "destination": "my-tinyllama"}},
+			apiOperation{method: http.MethodPost, path: "/api/show", tag: "models", summary: "Describe a model",
+				request: types.OllamaModelRequest{}, response: types.OllamaShowResponse{},
+				example: map[string]interface{}{"model": "tinyllama"}},
+			apiOperation{method: http.MethodGet, path: "/api/version", tag: "health", summary: "Ollama API version",
+				response: types.OllamaVersionResponse{}},
+		)
+	} else {
+		ops = append(ops,
+			apiOperation{method: http.MethodGet, path: "/api/tags", tag: "models", summary: "List installed models",
+				response: types.ModelsResponse{}},
+			apiOperation{method: http.MethodPost, path: "/api/pull", tag: "models", summary: "Pull a model",
+				description: "Streams the progress of the download as NDJSON.",
+				request:     types.PullRequest{}, response: types.PullResponse{}, contentType: "application/x-ndjson",
+				example: map[string]interface{}{"name": "tinyllama"}},
+			apiOperation{method: http.MethodDelete, path: "/api/delete", tag: "models", summary: "Remove a model",
+				request: struct {
+					Name string `json:"name"`
+				}{}, response: messageResponse{}, example: map[string]interface{}{"name": "tinyllama"}},
+		)
+	}
+
+	generateExample := map[string]interface{}{"model": "tinyllama", "prompt": "The capital of France is", "stream": false}
+	tokenizeExample := map[string]interface{}{"model": "tinyllama", "prompt": "Hello, world"}
+	ops = append(ops,
+		apiOperation{method: http.MethodPost, path: "/api/generate", tag: "generation", summary: "Generate a response to a prompt",
+			request: types.GenerateRequest{}, response: types.GenerateResponse{}, streams: true, example: generateExample},
+		apiOperation{method: http.MethodPost, path: "/api/batch/generate", tag: "generation", summary: "Generate responses to several prompts",
+			description: "The prompts are decoded together when the engine supports batching.",
+			request:     types.BatchGenerateRequest{}, response: types.BatchGenerateResponse{},
+			example: map[string]interface{}{"model": "tinyllama", "prompts": []string{"The capital of France is", "The capital of Italy is"}}},
+		apiOperation{method: http.MethodPost, path: "/api/chat", tag: "generation", summary: "Generate the next message of a chat",
+			request: types.ChatRequest{}, response: types.ChatResponse{}, streams: true,
+			example: map[string]interface{}{
+				"model":    "tinyllama",
+				"messages": []map[string]string{{"role": "user", "content": "Why is the sky blue?"}},
+				"stream":   false,
+			}},
+		apiOperation{method: http.MethodGet, path: "/api/tokenize", tag: "generation", summary: "Tokenize a prompt",
+			description: "Same as POST /api/tokenize, with the request sent as a JSON body.",
+			request:     types.TokenizeRequest{}, response: types.TokenizeResponse{}, example: tokenizeExample},
+		apiOperation{method: http.MethodPost, path: "/api/tokenize", tag: "generation", summary: "Tokenize a prompt",
+			request: types.TokenizeRequest{}, response: types.TokenizeResponse{}, example: tokenizeExample},
+		apiOperation{method: http.MethodDelete, path: "/api/session/delete", tag: "generation", summary: "Delete a KV cache session",
+			request: types.SessionDeleteRequest{}, response: messageResponse{},
+			example: map[string]interface{}{"session_id": "my-session"}},
+		apiOperation{method: http.MethodGet, path: "/api/ps", tag: "models", summary: "List loaded models",
+			response: types.ProcessResponse{}},
+		apiOperation{method: http.MethodPost, path: "/api/load", tag: "models", summary: "Load a model",
+			description: "A model already loaded with another kv_cache_type is loaded again, or 409 is returned while it serves requests.",
+			request:     types.LoadRequest{}, response: types.LoadResponse{},
+			example: map[string]interface{}{"model": "tinyllama", "kv_cache_type": "q8_0"}},
+		apiOperation{method: http.MethodGet, path: "/api/slow-queries", tag: "health", summary: "List recent slow generations",
+			response: types.SlowQueriesResponse{}},
+		apiOperation{method: http.MethodGet, path: "/api/stats", tag: "models", summary: "List model performance statistics",
+			description: "Statistics are kept for every model that served a generation and are saved to stats.json next to the models directory at shutdown.",
+			response: types.StatsResponse{}},
+		apiOperation{method: http.MethodGet, path: "/api/stats/{model}", tag: "models", summary: "Get the performance statistics of a model",
+			response: types.ModelStats{}},
+		apiOperation{method: http.MethodGet, path: "/api/logs", tag: "health", summary: "Stream the server log",
+			description: "Sends the last log lines, then with follow=true the new ones, as server-sent events with a LogEntry each. " +
+				"The level query parameter (debug, info, warn or error, default info) filters the lines and lines limits how many buffered lines are sent.",
+			response: types.LogEntry{}, contentType: "text/event-stream"},
+		apiOperation{method: http.MethodPost, path: "/api/templates", tag: "templates", summary: "Create a prompt template",
+			description: "Variables are written {{name}} and are given values by the template_vars of generate requests naming the template.",
+			request:     types.PromptTemplateRequest{}, response: types.PromptTemplate{},
+			example: map[string]interface{}{"name": "summarize", "template": "Summarize the following text:\n\n{{user_input}}"}},
+		apiOperation{method: http.MethodGet, path: "/api/templates", tag: "templates", summary: "List prompt templates",
+			response: types.PromptTemplatesResponse{}},
+		apiOperation{method: http.MethodDelete, path: "/api/templates/{name}", tag: "templates", summary: "Delete a prompt template",
+			response: messageResponse{}},
+		apiOperation{method: http.MethodGet, path: "/ws/generate", tag: "generation", summary: "Generate over a WebSocket",
+			description: "Each text message sent is a GenerateRequest; the responses are streamed back as one GenerateResponse frame per chunk."},
+		apiOperation{method: http.MethodPost, path: "/v1/chat/completions", tag: "openai", summary: "OpenAI-compatible chat completion",
+			request: types.OpenAIChatCompletionRequest{}, response: types.OpenAIChatCompletionResponse{}, streams: true,
+			example: map[string]interface{}{
+				"model":    "tinyllama",
+				"messages": []map[string]string{{"role": "user", "content": "Why is the sky blue?"}},
+			}},
+		apiOperation{method: http.MethodPost, path: "/v1/embeddings", tag: "openai", summary: "OpenAI-compatible embeddings",
+			request: types.OpenAIEmbeddingRequest{}, response: types.OpenAIEmbeddingResponse{},
+			example: map[string]interface{}{"model": "tinyllama", "input": "Hello, world"}},
+	)
+
+	if cfg.APIDocs {
+		ops = append(ops,
+			apiOperation{method: http.MethodGet, path: "/api/openapi.json", tag: "health", summary: "This OpenAPI specification"},
+			apiOperation{method: http.MethodGet, path: "/api/docs", tag: "health", summary: "Swagger UI", contentType: "text/html"},
+		)
+	}
+	return ops
+}
+
+// OpenAPISpec returns the OpenAPI 3.0 specification of the routes the server
+// registers for cfg
+func OpenAPISpec(cfg *config.Config) map[string]interface{} {
+	builder := &schemaBuilder{schemas: make(map[string]interface{})}
+	authenticated := cfg.APIKey != "" || cfg.APIKeysFile != ""
+
+	paths := make(map[string]map[string]interface{})
+	for _, op := range apiOperations(cfg) {
+		operation := map[string]interface{}{
+			"tags":        []string{op.tag},
+			"summary":     op.summary,
+			"operationId": operationID(op.method, op.path),
+			"responses":   builder.responses(op),
+		}
+		if op.description != "" {
+			operation["description"] = op.description
+		}
+		if params := pathParameters(op.path); len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.request != nil {
+			media := map[string]interface{}{"schema": builder.schema(reflect.TypeOf(op.request))}
+			if op.example != nil {
+				media["example"] = op.example
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": media},
+			}
+		}
+		// API keys guard every route but the health checks and the docs
+		if authenticated && isGuardedPath(op.path) {
+			operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]interface{})
+		}
+		paths[op.path][strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Colossus API",
+			"description": "Local LLM inference server with Ollama- and OpenAI-compatible endpoints",
+			"version":     buildinfo.Version(),
+		},
+		"servers": []map[string]string{{"url": fmt.Sprintf("http://%s:%d", cfg.Host, cfg.Port)}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": builder.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// isGuardedPath reports whether API key authentication applies to a path
+func isGuardedPath(path string) bool {
+	if path == "/api/openapi.json" || path == "/api/docs" {
+		return false
+	}
+	return strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/ws/") || strings.HasPrefix(path, "/v1/")
+}
+
+// pathParameters returns the parameters of the {name} segments of a path
+func pathParameters(path string) []map[string]interface{} {
+	var params []map[string]interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, map[string]interface{}{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]string{"type": "string"},
+			})
+		}
+	}
+	return params
+}
+
+// operationID derives a unique operation ID such as "postApiBatchGenerate"
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '_' || r == '{' || r == '}'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	if path == "/" {
+		b.WriteString("Root")
+	}
+	return b.String()
+}
+
+// schemaBuilder derives JSON schemas from Go types, collecting named structs
+// as components
+type schemaBuilder struct {
+	schemas map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	stringListType = reflect.TypeOf(types.OpenAIStringList{})
+)
+
+// responses returns the responses of an operation: its success response and
+// the error response of its API
+func (b *schemaBuilder) responses(op apiOperation) map[string]interface{} {
+	success := map[string]interface{}{"description": "Success"}
+	switch {
+	case op.response != nil:
+		schema := b.schema(reflect.TypeOf(op.response))
+		contentType := op.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		content := map[string]interface{}{contentType: map[string]interface{}{"schema": schema}}
+		if op.streams {
+			content["application/x-ndjson"] = map[string]interface{}{"schema": schema}
+		}
+		success["content"] = content
+	case op.contentType != "":
+		success["content"] = map[string]interface{}{op.contentType: map[string]interface{}{"schema": map[string]string{"type": "string"}}}
+	}
+
+	errorType := reflect.TypeOf(types.ErrorResponse{})
+	if strings.HasPrefix(op.path, "/v1/") {
+		errorType = reflect.TypeOf(types.OpenAIErrorResponse{})
+	}
+	return map[string]interface{}{
+		"200": success,
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": b.schema(errorType)},
+			},
+		},
+	}
+}
+
+// schema returns the schema of the JSON encoding of t. Named structs are
+// referenced from the components.
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	case stringListType:
+		// Accepted as a single string or a list of strings
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]string{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		// Unexported types of this package are documented as exported
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := b.schemas[name]; !ok {
+			// Registered before the fields, for recursive types
+			b.schemas[name] = nil
+			b.schemas[name] = b.structSchema(t)
+		}
+		return ref
+	default:
+		// Interfaces hold any JSON value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema of a struct, with the fields of
+// embedded structs inlined as encoding/json does
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	b.addProperties(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (b *schemaBuilder) addProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addProperties(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+// openAPISpec handles GET /api/openapi.json
+func (s *Server) openAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPISpec(s.config))
+}
+
+// swaggerUIPage loads Swagger UI from a CDN, pointed at the specification
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Colossus API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// apiDocs handles GET /api/docs
+func (s *Server) apiDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
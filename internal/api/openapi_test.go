@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/config"
+)
+
+func TestOpenAPISpecDocumentsRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+	}{
+		{name: "default"},
+		{name: "all features", configure: func(cfg *config.Config) {
+			cfg.Metrics = true
+			cfg.APIDocs = true
+			cfg.APIKey = "secret"
+		}},
+		{name: "ollama", configure: func(cfg *config.Config) {
+			cfg.OllamaCompat = true
+			cfg.Metrics = true
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.configure)
+
+			registered := make(map[string]bool)
+			for _, route := range s.Router().Routes() {
+				registered[route.Method+" "+openAPIPath(route.Path)] = true
+			}
+			documented := make(map[string]bool)
+			for _, op := range apiOperations(s.config) {
+				documented[op.method+" "+op.path] = true
+			}
+
+			for _, route := range sortedKeys(registered) {
+				if !documented[route] {
+					t.Errorf("route %s is not documented", route)
+				}
+			}
+			for _, route := range sortedKeys(documented) {
+				if !registered[route] {
+					t.Errorf("documented route %s is not registered", route)
+				}
+			}
+		})
+	}
+}
+
+// openAPIPath writes the :name parameters of a gin route as {name}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOpenAPISpecIsValidJSON(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.APIDocs = true
+		cfg.APIKey = "secret"
+	})
+
+	w := serve(s, http.MethodGet, "/api/openapi.json", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 without an API key: %s", w.Code, w.Body)
+	}
+
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", spec.OpenAPI)
+	}
+
+	generate := spec.Paths["/api/generate"]["post"]
+	if generate == nil || generate["requestBody"] == nil || generate["security"] == nil {
+		t.Errorf("POST /api/generate = %v, want a guarded operation with a request body", generate)
+	}
+	if health := spec.Paths["/health"]["get"]; health == nil || health["security"] != nil {
+		t.Errorf("GET /health = %v, want a public operation", health)
+	}
+
+	// Every referenced schema is defined
+	for _, ref := range strings.Split(w.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if spec.Components.Schemas[name] == nil {
+			t.Errorf("schema %s is referenced but not defined", name)
+		}
+	}
+	if _, ok := spec.Components.Schemas["GenerateRequest"]; !ok {
+		t.Error("GenerateRequest schema missing")
+	}
+
+	if w := serve(s, http.MethodGet, "/api/docs", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "swagger-ui") {
+		t.Errorf("GET /api/docs = %d, want the Swagger UI page", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofHandler serves the net/http/pprof profiles under /debug/pprof/. It is
+// meant for a separate listener, as profiles reveal the server's internals and
+// collecting them slows it down.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// IsLoopbackAddr reports whether a HOST:PORT address only accepts local
+// connections
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
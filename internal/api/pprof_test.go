@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPprofHandler(t *testing.T) {
+	handler := PprofHandler()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/debug/pprof/", want: "goroutine"},
+		{path: "/debug/pprof/goroutine?debug=1", want: "goroutine profile:"},
+		{path: "/debug/pprof/heap?debug=1", want: "heap profile:"},
+		{path: "/debug/pprof/cmdline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body lacks %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestPprofNotOnAPIPort(t *testing.T) {
+	s := newTestServer(t, nil)
+	if w := serve(s, http.MethodGet, "/debug/pprof/", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "localhost:6060", want: true},
+		{addr: "127.0.0.1:6060", want: true},
+		{addr: "[::1]:6060", want: true},
+		{addr: "0.0.0.0:6060", want: false},
+		{addr: ":6060", want: false},
+		{addr: "192.0.2.10:6060", want: false},
+		{addr: "example.com:6060", want: false},
+		{addr: "localhost", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLoopbackAddr(tt.addr); got != tt.want {
+			t.Errorf("IsLoopbackAddr(%q) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errQueueFull is returned when a model already has as many requests waiting
+// as its queue holds
+var errQueueFull = errors.New("queue full")
+
+// modelQueue limits the requests running for one model. Requests beyond the
+// running slots wait in the queue, in arrival order, until a slot frees up.
+// Requests for different models have separate queues and run concurrently.
+type modelQueue struct {
+	mutex    sync.Mutex
+	parallel int
+	depth    int
+	running  int
+	// waiting holds one channel per queued request, closed when the request
+	// is handed a slot
+	waiting []chan struct{}
+}
+
+// newModelQueue creates a queue that runs up to parallel requests at a time
+// and holds up to depth waiting requests
+func newModelQueue(parallel, depth int) *modelQueue {
+	if parallel < 1 {
+		parallel = 1
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	return &modelQueue{parallel: parallel, depth: depth}
+}
+
+// acquire waits for a running slot. It fails with errQueueFull without
+// waiting when the queue is full, or with ctx.Err() when ctx is done first.
+// The returned function frees the slot.
+func (q *modelQueue) acquire(ctx context.Context) (func(), error) {
+	q.mutex.Lock()
+	if q.running < q.parallel && len(q.waiting) == 0 {
+		q.running++
+		q.mutex.Unlock()
+		return q.release, nil
+	}
+	if len(q.waiting) >= q.depth {
+		q.mutex.Unlock()
+		return nil, errQueueFull
+	}
+	ready := make(chan struct{})
+	q.waiting = append(q.waiting, ready)
+	q.mutex.Unlock()
+
+	select {
+	case <-ready:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+
+	q.mutex.Lock()
+	for i, waiting := range q.waiting {
+		if waiting == ready {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.mutex.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	q.mutex.Unlock()
+
+	// A slot was handed over just as ctx was done, so pass it on
+	q.release()
+	return nil, ctx.Err()
+}
+
+// release frees a running slot, handing it to the longest waiting request
+func (q *modelQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.waiting) > 0 {
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+		return
+	}
+	q.running--
+}
+
+// queued returns the number of requests waiting in the queue
+func (q *modelQueue) queued() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.waiting)
+}
+
+// acquireModel waits for a turn to run a request for a model and tracks the
+// request while it runs. The returned function must be called once the
+// request has finished.
+func (s *Server) acquireModel(ctx context.Context, modelName string) (func(), error) {
+	done := s.loadedModels.Track(modelName)
+
+	release, err := s.loadedModels.Queue(modelName).acquire(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	return func() {
+		release()
+		done()
+	}, nil
+}
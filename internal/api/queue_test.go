@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/inference"
+)
+
+// waitQueued waits until n requests are waiting in q
+func waitQueued(t *testing.T, q *modelQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.queued() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued after 5s, want %d", q.queued(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestModelQueueFull(t *testing.T) {
+	q := newModelQueue(1, 1)
+
+	release, err := q.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		release, err := q.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	waitQueued(t, q, 1)
+
+	if _, err := q.acquire(context.Background()); !errors.Is(err, errQueueFull) {
+		t.Errorf("acquire with a full queue = %v, want %v", err, errQueueFull)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued acquire: %v", err)
+	}
+}
+
+func TestModelQueueOrder(t *testing.T) {
+	q := newModelQueue(1, 4)
+	release, err := q.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			release, err := q.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire %d: %v", i, err)
+				return
+			}
+			mutex.Lock()
+			order = append(order, i)
+			mutex.Unlock()
+			release()
+		}(i)
+		// Queue the requests one at a time so their arrival order is known
+		waitQueued(t, q, i)
+	}
+
+	release()
+	wg.Wait()
+	for i, n := range order {
+		if n != i+1 {
+			t.Fatalf("requests ran in order %v, want the order they arrived in", order)
+		}
+	}
+}
+
+func TestModelQueueCancel(t *testing.T) {
+	q := newModelQueue(1, 1)
+	release, err := q.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error)
+	go func() {
+		_, err := q.acquire(ctx)
+		acquired <- err
+	}()
+	waitQueued(t, q, 1)
+
+	cancel()
+	if err := <-acquired; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled acquire = %v, want %v", err, context.Canceled)
+	}
+	if n := q.queued(); n != 0 {
+		t.Errorf("%d requests queued after cancelling, want 0", n)
+	}
+
+	// The slot is still free for the next request once released
+	release()
+	if release, err := q.acquire(context.Background()); err != nil {
+		t.Errorf("acquire after cancelling: %v", err)
+	} else {
+		release()
+	}
+}
+
+// holdModel takes every running slot of a model. The returned function
+// frees them.
+func holdModel(t *testing.T, s *Server, name string) func() {
+	t.Helper()
+	var releases []func()
+	for i := 0; i < inference.DefaultModelOptions().Parallel; i++ {
+		release, err := s.acquireModel(context.Background(), name)
+		if err != nil {
+			t.Fatalf("acquireModel(%s): %v", name, err)
+		}
+		releases = append(releases, release)
+	}
+
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+func TestQueueFullStatus(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+	defer holdModel(t, s, "tinyllama")()
+
+	for _, path := range []string{"/api/generate", "/api/tokenize"} {
+		w := serve(s, http.MethodPost, path, `{"model": "tinyllama", "prompt": "hello"}`, nil)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s status = %d, want %d: %s", path, w.Code, http.StatusServiceUnavailable, w.Body)
+		}
+	}
+}
+
+func TestModelsServedInParallel(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.QueueDepth = 1
+	})
+	installTestModel(t, s, "tinyllama")
+	installTestModel(t, s, "llama2")
+	release := holdModel(t, s, "tinyllama")
+
+	// A request for the busy model waits in its queue
+	queued := make(chan int)
+	go func() {
+		w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+		queued <- w.Code
+	}()
+	waitQueued(t, s.loadedModels.Queue("tinyllama"), 1)
+
+	// Meanwhile another model serves requests
+	if w := serve(s, http.MethodPost, "/api/generate", `{"model": "llama2", "prompt": "hello"}`, nil); w.Code != http.StatusOK {
+		t.Errorf("llama2 status = %d while tinyllama is busy, want 200: %s", w.Code, w.Body)
+	}
+
+	// The queued request runs once the busy model frees up
+	release()
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("queued tinyllama status = %d, want 200", code)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// modelActivity tracks the requests made to a model
+type modelActivity struct {
+	// lastRequest is when a request last started or finished, in Unix nanoseconds
+	lastRequest atomic.Int64
+	active      atomic.Int32
+}
+
+// registeredModel is a model known to the LoadedModelRegistry
+type registeredModel struct {
+	modelActivity
+	queue   *modelQueue
+	circuit *CircuitBreaker
+
+	// info and loadedAt are set once the model has been loaded
+	info     *inference.ModelInfo
+	loadedAt time.Time
+}
+
+// LoadedModelRegistry keeps track of the models loaded by the server and the
+// requests in progress for each of them
+type LoadedModelRegistry struct {
+	mutex  sync.RWMutex
+	models map[string]*registeredModel
+
+	// Size of the request queue created for each model
+	parallel   int
+	queueDepth int
+
+	// Settings of the circuit breaker created for each model
+	circuitThreshold int
+	circuitRecovery  time.Duration
+}
+
+// NewLoadedModelRegistry creates an empty registry. Each model's queue runs up
+// to parallel requests at a time and holds up to queueDepth waiting requests.
+// Each model's circuit opens after circuitThreshold consecutive errors and
+// lets a trial request through circuitRecovery later.
+func NewLoadedModelRegistry(parallel, queueDepth, circuitThreshold int, circuitRecovery time.Duration) *LoadedModelRegistry {
+	return &LoadedModelRegistry{
+		models:           make(map[string]*registeredModel),
+		parallel:         parallel,
+		queueDepth:       queueDepth,
+		circuitThreshold: circuitThreshold,
+		circuitRecovery:  circuitRecovery,
+	}
+}
+
+// Track records that a request for a model has started. The returned
+// function must be called once the request has finished.
+func (r *LoadedModelRegistry) Track(name string) func() {
+	model := r.entry(name)
+	model.active.Add(1)
+	model.lastRequest.Store(time.Now().UnixNano())
+
+	return func() {
+		model.lastRequest.Store(time.Now().UnixNano())
+		model.active.Add(-1)
+	}
+}
+
+// Queue returns the request queue of a model
+func (r *LoadedModelRegistry) Queue(name string) *modelQueue {
+	return r.entry(name).queue
+}
+
+// Active returns the number of requests running or queued for a model
+func (r *LoadedModelRegistry) Active(name string) int {
+	return int(r.entry(name).active.Load())
+}
+
+// Circuit returns the circuit breaker of a model
+func (r *LoadedModelRegistry) Circuit(name string) *CircuitBreaker {
+	return r.entry(name).circuit
+}
+
+// Loaded records that a model has been loaded
+func (r *LoadedModelRegistry) Loaded(info *inference.ModelInfo) {
+	model := r.entry(info.Name)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	model.info = info
+	model.loadedAt = time.Now()
+	if model.lastRequest.Load() == 0 {
+		model.lastRequest.Store(model.loadedAt.UnixNano())
+	}
+}
+
+// Remove forgets a model, e.g. after it has been unloaded
+func (r *LoadedModelRegistry) Remove(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.models, name)
+}
+
+// Idle returns the names of the models that have had no requests in progress
+// since the given time
+func (r *LoadedModelRegistry) Idle(cutoff time.Time) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var names []string
+	for name, model := range r.models {
+		if model.active.Load() == 0 && model.lastRequest.Load() <= cutoff.UnixNano() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// List returns the loaded models sorted by name
+func (r *LoadedModelRegistry) List() []types.RunningModel {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := time.Now()
+	models := make([]types.RunningModel, 0, len(r.models))
+	for name, model := range r.models {
+		if model.info == nil {
+			continue
+		}
+
+		// Requests waiting in the queue are tracked as active too
+		queued := model.queue.queued()
+		running := types.RunningModel{
+			Name:           name,
+			Size:           model.info.MemoryUsed,
+			ContextSize:    model.info.ContextSize,
+			ActiveRequests: max(int(model.active.Load())-queued, 0),
+			QueuedRequests: queued,
+			LoadedAt:       model.loadedAt,
+			LastRequest:    time.Unix(0, model.lastRequest.Load()),
+			KVCacheType:    model.info.KVCacheType,
+			CircuitState:   model.circuit.State().String(),
+		}
+		if running.ActiveRequests == 0 && queued == 0 {
+			running.IdleSeconds = int64(now.Sub(running.LastRequest).Seconds())
+		}
+		models = append(models, running)
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+	return models
+}
+
+// entry returns the registry entry of a model, creating it if needed
+func (r *LoadedModelRegistry) entry(name string) *registeredModel {
+	r.mutex.RLock()
+	model, ok := r.models[name]
+	r.mutex.RUnlock()
+	if ok {
+		return model
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if model, ok = r.models[name]; !ok {
+		model = &registeredModel{
+			queue:   newModelQueue(r.parallel, r.queueDepth),
+			circuit: NewCircuitBreaker(r.circuitThreshold, r.circuitRecovery),
+		}
+		r.models[name] = model
+	}
+	return model
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/inference"
+)
+
+func TestLoadedModelRegistry(t *testing.T) {
+	r := NewLoadedModelRegistry(1, 0, 0, 0)
+	start := time.Now()
+
+	// Requests are tracked before their model has finished loading
+	done := r.Track("tinyllama")
+	if models := r.List(); len(models) != 0 {
+		t.Fatalf("List() = %v before loading, want no models", models)
+	}
+
+	r.Loaded(&inference.ModelInfo{Name: "tinyllama", MemoryUsed: 1 << 20, ContextSize: 2048})
+	r.Loaded(&inference.ModelInfo{Name: "llama2", MemoryUsed: 1 << 30, ContextSize: 4096})
+
+	models := r.List()
+	if len(models) != 2 || models[0].Name != "llama2" || models[1].Name != "tinyllama" {
+		t.Fatalf("List() = %v, want llama2 and tinyllama sorted by name", models)
+	}
+	if m := models[1]; m.Size != 1<<20 || m.ContextSize != 2048 || m.ActiveRequests != 1 {
+		t.Errorf("tinyllama = %+v, want its size, context and one active request", m)
+	}
+
+	// Only models without requests since the cutoff are idle
+	if idle := r.Idle(time.Now()); !reflect.DeepEqual(idle, []string{"llama2"}) {
+		t.Errorf("Idle() with a request in progress = %v, want [llama2]", idle)
+	}
+	done()
+	if idle := r.Idle(start); len(idle) != 0 {
+		t.Errorf("Idle() before the last request = %v, want none", idle)
+	}
+	if idle := r.Idle(time.Now()); len(idle) != 2 {
+		t.Errorf("Idle() after the request = %v, want both models", idle)
+	}
+
+	r.Remove("llama2")
+	if models := r.List(); len(models) != 1 || models[0].Name != "tinyllama" || models[0].ActiveRequests != 0 {
+		t.Errorf("List() after removal = %v, want only an idle tinyllama", models)
+	}
+}
@@ -1,35 +1,171 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"colossus-cli/internal/api/middleware"
 	"colossus-cli/internal/config"
+	"colossus-cli/internal/grammar"
 	"colossus-cli/internal/inference"
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/logging"
 	"colossus-cli/internal/model"
+	"colossus-cli/internal/template"
 	"colossus-cli/internal/types"
 
+	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// logger logs with the api component
+var logger = logging.Component("api")
+
 // Server represents the API server
 type Server struct {
 	config        *config.Config
 	modelManager  *model.Manager
 	engine        inference.InferenceEngine
 	engineType    inference.EngineType
+	sessions      *inference.SessionStore
+	
+	// API keys required on API routes; authentication is disabled when nil
+	apiKeys       []string
+	rateLimiter   *middleware.RateLimiter
+	metrics       *serverMetrics
+	
+	// Readiness state reported by /health, /ready and /startup
+	startedAt     time.Time
+	preloading    atomic.Bool
+	startup       *startupState
+	
+	// Loaded models and their request activity, reported by /api/ps and
+	// used to unload idle models
+	loadedModels  *LoadedModelRegistry
+	
+	// Generations slower than the configured threshold, for /api/slow-queries
+	slowQueries   *slowQueryLog
+	
+	// Named prompt templates that generate requests may render
+	templates     *template.Store
+	
+	// Responses returned again for identical generate requests, nil when
+	// caching is disabled
+	cache         *responseCache
+	
+	// The last log lines, streamed by GET /api/logs
+	logs          *LogBuffer
+	
+	// Performance of each model, keyed by name, for /api/stats
+	stats         sync.Map
+	
+	// Canceled by CancelDownloads to stop model pulls at shutdown
+	downloads       context.Context
+	cancelDownloads context.CancelFunc
 }
 
 // NewServer creates a new API server
 func NewServer(cfg *config.Config, modelManager *model.Manager) *Server {
 	engineType := inference.GetEngineTypeFromEnv()
+	engine := inference.NewEngine(engineType)
 	
-	return &Server{
+	sessions, err := inference.NewSessionStore(cfg.SessionsPath, cfg.SessionIdleTimeout)
+	if err != nil {
+		logger.Warnf("Sessions disabled: %v", err)
+	} else {
+		engine.SetSessionStore(sessions)
+	}
+	engine.SetPrefillDir(cfg.PrefillPath)
+	
+	// Fail closed: a keys file that cannot be read rejects every request
+	// rather than silently disabling authentication
+	apiKeys, err := cfg.APIKeys()
+	if err != nil {
+		logger.Errorf("Rejecting all API requests: %v", err)
+		apiKeys = []string{}
+	}
+	
+	// Rate limit per API key when keys are authenticated, per IP otherwise
+	var rateLimiter *middleware.RateLimiter
+	if cfg.RateLimit > 0 {
+		rateLimiter = middleware.NewRateLimiter(cfg.RateLimit, cfg.RateLimitBurst)
+		rateLimiter.KeyByAPIKey = apiKeys != nil
+		rateLimiter.StartCleanup(cfg.RateLimitCleanupInterval)
+	}
+	
+	var metrics *serverMetrics
+	if cfg.Metrics {
+		metrics = newServerMetrics(engine)
+	}
+	
+	// Log lines are buffered from here on for GET /api/logs
+	logs := NewLogBuffer(LogBufferSize)
+	logrus.AddHook(logs)
+	
+	downloads, cancelDownloads := context.WithCancel(context.Background())
+	
+	server := &Server{
 		config:       cfg,
 		modelManager: modelManager,
-		engine:       inference.NewEngine(engineType),
+		engine:       engine,
 		engineType:   engineType,
+		sessions:     sessions,
+		apiKeys:      apiKeys,
+		rateLimiter:  rateLimiter,
+		metrics:      metrics,
+		startedAt:    time.Now(),
+		startup:      newStartupState(),
+		loadedModels: NewLoadedModelRegistry(inference.DefaultModelOptions().Parallel, cfg.QueueDepth,
+			cfg.CircuitThreshold, cfg.CircuitRecoveryTimeout),
+		slowQueries:  &slowQueryLog{},
+		templates:    template.NewStore(filepath.Join(filepath.Dir(cfg.ModelsPath), template.StoreFileName)),
+		cache:        newResponseCache(cfg.CacheSize, cfg.CacheTTL),
+		logs:         logs,
+		
+		downloads:       downloads,
+		cancelDownloads: cancelDownloads,
+	}
+	
+	if err := server.loadStats(); err != nil {
+		logger.Warnf("Model statistics reset: %v", err)
+	}
+	
+	if cfg.IdleUnload > 0 {
+		go server.idleUnloadLoop(cfg.IdleUnload)
+	}
+	
+	return server
+}
+
+// CancelDownloads stops the model pulls in progress, which remove their
+// partial files and fail. It is called when the server shuts down, as pulls
+// could otherwise outlast the shutdown timeout.
+func (s *Server) CancelDownloads() {
+	s.cancelDownloads()
+}
+
+// downloadContext returns the context of a pull, canceled with the request
+// or by CancelDownloads
+func (s *Server) downloadContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	stop := context.AfterFunc(s.downloads, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
 	}
 }
 
@@ -39,7 +175,15 @@ func (s *Server) Router() *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	
-	r := gin.Default()
+	// Requests are logged through logrus, so that they follow the log format
+	r := gin.New()
+	r.Use(gin.Recovery(), middleware.RequestLog(logger))
+	r.Use(tracingMiddleware())
+	
+	if s.metrics != nil {
+		r.Use(s.metrics.middleware())
+		r.GET("/metrics", s.metrics.handler())
+	}
 	
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -55,14 +199,64 @@ func (s *Server) Router() *gin.Engine {
 		c.Next()
 	})
 	
+	if s.config.CompressionLevel > 0 {
+		r.Use(compression(s.config.CompressionLevel))
+	}
+	
+	// API key authentication and rate limiting, applied to every route group
+	// except the health check
+	var guards []gin.HandlerFunc
+	if s.apiKeys != nil {
+		guards = append(guards, apiKeyAuth(s.apiKeys))
+	}
+	if s.rateLimiter != nil {
+		guards = append(guards, s.rateLimiter.Handler())
+	}
+	
 	// API routes
-	api := r.Group("/api")
+	api := r.Group("/api", guards...)
 	{
-		api.GET("/tags", s.listModels)
-		api.POST("/pull", s.pullModel)
-		api.DELETE("/delete", s.deleteModel)
+		if s.config.OllamaCompat {
+			// Ollama's model management API replaces the routes of the same path
+			api.GET("/tags", s.ollamaListModels)
+			api.POST("/pull", s.ollamaPullModel)
+			api.DELETE("/delete", s.ollamaDeleteModel)
+			api.POST("/copy", s.ollamaCopyModel)
+			api.POST("/show", s.ollamaShowModel)
+			api.GET("/version", s.ollamaVersion)
+		} else {
+			api.GET("/tags", s.listModels)
+			api.POST("/pull", s.pullModel)
+			api.DELETE("/delete", s.deleteModel)
+		}
 		api.POST("/generate", s.generate)
+		api.POST("/batch/generate", s.batchGenerate)
 		api.POST("/chat", s.chat)
+		api.GET("/tokenize", s.tokenize)
+		api.POST("/tokenize", s.tokenize)
+		api.DELETE("/session/delete", s.deleteSession)
+		api.GET("/ps", s.listLoadedModels)
+		api.POST("/load", s.loadModel)
+		api.GET("/slow-queries", s.listSlowQueries)
+		api.GET("/stats", s.listStats)
+		api.GET("/stats/:model", s.getStats)
+		api.POST("/templates", s.createTemplate)
+		api.GET("/templates", s.listTemplates)
+		api.DELETE("/templates/:name", s.deleteTemplate)
+		api.GET("/logs", s.streamLogs)
+	}
+	
+	// WebSocket streaming
+	ws := r.Group("/ws", guards...)
+	{
+		ws.GET("/generate", s.wsGenerate)
+	}
+	
+	// OpenAI-compatible routes
+	v1 := r.Group("/v1", guards...)
+	{
+		v1.POST("/chat/completions", s.chatCompletions)
+		v1.POST("/embeddings", s.embeddings)
 	}
 	
 	// Health check
@@ -73,14 +267,77 @@ func (s *Server) Router() *gin.Engine {
 		})
 	})
 	
+	// The ollama CLI checks that the server is up with HEAD /
+	if s.config.OllamaCompat {
+		r.HEAD("/", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+	}
+	
+	// Liveness and readiness probes
+	r.GET("/health", s.health)
+	r.GET("/ready", s.ready)
+	r.GET("/startup", s.startupProbe)
+	
+	// API documentation, public like the health checks
+	if s.config.APIDocs {
+		r.GET("/api/openapi.json", s.openAPISpec)
+		r.GET("/api/docs", s.apiDocs)
+	}
+	
 	return r
 }
 
+// streamedPaths are the routes whose responses are always streamed
+var streamedPaths = []string{"/api/pull", "/api/logs", "/ws/"}
+
+// compression returns a middleware compressing responses with gzip at the
+// given level for clients accepting it. Streamed responses are sent as they
+// are, so that clients receive every chunk as soon as it is written.
+func compression(level int) gin.HandlerFunc {
+	compress := gzip.Gzip(level, gzip.WithExcludedPaths(streamedPaths))
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") || requestsStream(c) {
+			return
+		}
+		compress(c)
+	}
+}
+
+// maxStreamSniffSize is the most of a request body read to find whether the
+// request asks for a streamed response. Responses to larger requests are
+// compressed.
+const maxStreamSniffSize = 1 << 20
+
+// requestsStream reports whether a generate or chat request asks for a
+// streamed response, leaving the request body to be read again
+func requestsStream(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return false
+	}
+	
+	// Only the start of the body is buffered, the rest is read after it
+	body := c.Request.Body
+	head, err := io.ReadAll(io.LimitReader(body, maxStreamSniffSize+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}
+	if err != nil || len(head) > maxStreamSniffSize {
+		return false
+	}
+	
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(head, &req) == nil && req.Stream
+}
+
 // listModels handles GET /api/tags
 func (s *Server) listModels(c *gin.Context) {
 	models, err := s.modelManager.ListModels()
 	if err != nil {
-		logrus.Errorf("Failed to list models: %v", err)
+		logger.Errorf("Failed to list models: %v", err)
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error: "Failed to list models",
 		})
@@ -116,7 +373,9 @@ func (s *Server) pullModel(c *gin.Context) {
 	c.Writer.Flush()
 	
 	// Pull the model
-	if err := s.modelManager.PullModel(req.Name); err != nil {
+	ctx, cancel := s.downloadContext(c)
+	defer cancel()
+	if err := s.modelManager.PullModel(ctx, req.Name); err != nil {
 		encoder.Encode(types.PullResponse{
 			Status: "error: " + err.Error(),
 		})
@@ -152,6 +411,173 @@ func (s *Server) deleteModel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Model deleted successfully"})
 }
 
+// listLoadedModels handles GET /api/ps
+func (s *Server) listLoadedModels(c *gin.Context) {
+	c.JSON(http.StatusOK, types.ProcessResponse{Models: s.loadedModels.List()})
+}
+
+// loadModel handles POST /api/load. A model loaded with another KV cache type
+// than requested is loaded again, unless it is serving requests.
+func (s *Server) loadModel(c *gin.Context) {
+	var req types.LoadRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Model == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "Invalid request",
+		})
+		return
+	}
+	if req.KVCacheType != "" {
+		if _, err := llama.ParseKVCacheType(req.KVCacheType); err != nil {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+	
+	setRequestModel(c, req.Model)
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer release()
+	
+	if s.engine.IsModelLoaded(req.Model) {
+		info, err := s.engine.GetModelInfo(req.Model)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		if req.KVCacheType == "" || req.KVCacheType == info.KVCacheType {
+			c.JSON(http.StatusOK, loadResponse(info))
+			return
+		}
+		
+		// This request is the only one allowed to run
+		if s.loadedModels.Active(req.Model) > 1 {
+			c.JSON(http.StatusConflict, types.ErrorResponse{
+				Error: fmt.Sprintf("model %s is serving requests with a %s KV cache, retry once they have finished", req.Model, info.KVCacheType),
+			})
+			return
+		}
+		if err := s.engine.UnloadModel(req.Model); err != nil {
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		logger.Infof("Reloading model %s with a %s KV cache", req.Model, req.KVCacheType)
+	}
+	
+	if err := s.loadIntoEngine(req.Model, req.KVCacheType); err != nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	info, err := s.engine.GetModelInfo(req.Model)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, loadResponse(info))
+}
+
+// loadResponse describes a loaded model
+func loadResponse(info *inference.ModelInfo) types.LoadResponse {
+	return types.LoadResponse{
+		Model:       info.Name,
+		ContextSize: info.ContextSize,
+		GPULayers:   info.ActualGPULayers,
+		KVCacheType: info.KVCacheType,
+	}
+}
+
+// tokenize handles GET /api/tokenize
+func (s *Server) tokenize(c *gin.Context) {
+	var req types.TokenizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "Invalid request",
+		})
+		return
+	}
+	
+	// Ensure model is loaded
+	setRequestModel(c, req.Model)
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer release()
+	
+	if err := s.ensureModelLoaded(c.Request.Context(), req.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	resp, err := s.engine.Tokenize(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteSession handles DELETE /api/session/delete
+func (s *Server) deleteSession(c *gin.Context) {
+	var req types.SessionDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "Invalid request",
+		})
+		return
+	}
+	
+	if s.sessions == nil {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error: inference.ErrSessionNotFound.Error(),
+		})
+		return
+	}
+	
+	if _, err := s.sessions.Path(req.SessionID); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	if err := s.sessions.Delete(req.SessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, inference.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
+}
+
 // generate handles POST /api/generate
 func (s *Server) generate(c *gin.Context) {
 	var req types.GenerateRequest
@@ -162,18 +588,50 @@ func (s *Server) generate(c *gin.Context) {
 		return
 	}
 	
+	if err := validateJSONSchema(req.JSONSchema); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	if err := s.renderTemplate(&req); err != nil {
+		c.JSON(templateErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	// Identical requests within the cache TTL skip generation
+	setRequestModel(c, req.Model)
+	cacheKey, cached := s.cachedGenerate(c, &req)
+	if cached {
+		return
+	}
+	
 	// Ensure model is loaded
-	if err := s.ensureModelLoaded(req.Model); err != nil {
-		c.JSON(http.StatusNotFound, types.ErrorResponse{
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
+	defer release()
+	
+	loadStart := time.Now()
+	if err := s.ensureModelLoaded(c.Request.Context(), req.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	loadDuration := time.Since(loadStart)
 	
 	if req.Stream {
-		s.streamGenerate(c, &req)
+		s.streamGenerate(c, &req, loadDuration, cacheKey)
 	} else {
-		s.simpleGenerate(c, &req)
+		s.simpleGenerate(c, &req, loadDuration, cacheKey)
 	}
 }
 
@@ -187,60 +645,307 @@ func (s *Server) chat(c *gin.Context) {
 		return
 	}
 	
+	if err := validateJSONSchema(req.JSONSchema); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	if err := applyTools(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
 	// Ensure model is loaded
-	if err := s.ensureModelLoaded(req.Model); err != nil {
-		c.JSON(http.StatusNotFound, types.ErrorResponse{
+	setRequestModel(c, req.Model)
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer release()
+	
+	if err := s.ensureModelLoaded(c.Request.Context(), req.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	
+	tokensRemaining, err := s.checkTokenBudget(&req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTokenBudgetExhausted) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, types.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 	
 	if req.Stream {
-		s.streamChat(c, &req)
+		s.streamChat(c, &req, tokensRemaining)
 	} else {
-		s.simpleChat(c, &req)
+		s.simpleChat(c, &req, tokensRemaining)
 	}
 }
 
 // ensureModelLoaded loads a model if it's not already loaded
-func (s *Server) ensureModelLoaded(modelName string) error {
+func (s *Server) ensureModelLoaded(ctx context.Context, modelName string) (err error) {
+	_, endSpan := startSpan(ctx, "ensureModelLoaded", attribute.String("model", modelName))
+	defer func() { endSpan(err) }()
+	
+	// Models that keep failing are not run until their circuit recovers
+	if !s.loadedModels.Circuit(modelName).Allow() {
+		return errCircuitOpen
+	}
+	
 	if s.engine.IsModelLoaded(modelName) {
 		return nil
 	}
-	
+	return s.loadIntoEngine(modelName, "")
+}
+
+// loadIntoEngine loads a model with the options of its options file. A
+// kvCacheType other than empty overrides the KV cache type they set.
+func (s *Server) loadIntoEngine(modelName, kvCacheType string) error {
 	modelPath, err := s.modelManager.GetModelPath(modelName)
 	if err != nil {
 		return err
 	}
 	
-	// Get appropriate options for the engine type
+	// Options shipped next to the model override the defaults for the engine type
+	modelOptions, err := s.modelManager.GetModelOptions(modelPath)
+	if err != nil {
+		return err
+	}
 	options := inference.GetDefaultModelOptions(s.engineType)
+	inference.ApplyModelOptions(options, modelOptions)
+	if kvCacheType != "" {
+		options.KVCacheType = kvCacheType
+	}
+	if s.config.NoWarmup {
+		options.WarmUp = false
+	}
 	
-	return s.engine.LoadModel(modelName, modelPath, options)
+	// Installed adapters are applied unless the options file lists adapters
+	if len(options.LoRAAdapters) == 0 {
+		adapters, err := s.modelManager.ListAdapters(modelName)
+		if err != nil {
+			return err
+		}
+		for _, path := range adapters {
+			options.LoRAAdapters = append(options.LoRAAdapters, inference.LoRAAdapter{Path: path, Scale: 1})
+		}
+	}
+	if split, err := s.config.TensorSplit(); err == nil && split != nil {
+		options.TensorSplit = split
+	}
+	
+	// The draft model for speculative decoding is named like any other model
+	if modelOptions != nil && modelOptions.DraftModel != "" {
+		draftPath, err := s.modelManager.GetModelPath(modelOptions.DraftModel)
+		if err != nil {
+			return fmt.Errorf("failed to find draft model: %w", err)
+		}
+		options.DraftModel = draftPath
+	}
+
+	if err := s.engine.LoadModel(modelName, modelPath, options); err != nil {
+		return err
+	}
+	
+	// Loaded models are kept by colossus models gc
+	for _, path := range []string{modelPath, options.DraftModel} {
+		if path == "" {
+			continue
+		}
+		if err := s.modelManager.RecordAccess(path); err != nil {
+			logger.Warnf("Failed to record access to %s: %v", path, err)
+		}
+	}
+	
+	// Requests can still be served without the prefilled system prompt
+	if s.config.PrefillPrompt != "" {
+		if err := s.engine.PrefillContext(modelName, s.config.PrefillPrompt); err != nil {
+			logger.Warnf("Failed to prefill the system prompt of model %s: %v", modelName, err)
+		}
+	}
+	
+	info, err := s.engine.GetModelInfo(modelName)
+	if err != nil {
+		return err
+	}
+	s.loadedModels.Loaded(info)
+	
+	return nil
 }
 
-// simpleGenerate handles non-streaming generation
-func (s *Server) simpleGenerate(c *gin.Context, req *types.GenerateRequest) {
-	resp, err := s.engine.Generate(req)
+// simpleGenerate handles non-streaming generation. The time spent loading the
+// model is added to the response's metrics, and the response is cached under
+// cacheKey when it is set.
+func (s *Server) simpleGenerate(c *gin.Context, req *types.GenerateRequest, loadDuration time.Duration, cacheKey string) {
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
+	start := time.Now()
+	resp, err := s.engine.Generate(ctx, req)
+	s.recordGenerationResult(req.Model, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error: err.Error(),
+		c.JSON(inferenceStatus(err), types.ErrorResponse{
+			Error: inferenceError(err),
 		})
 		return
 	}
+	elapsed := time.Since(start)
+	addLoadDuration(resp, loadDuration, elapsed)
+	s.cacheResponse(cacheKey, resp, nil)
 	
 	c.JSON(http.StatusOK, resp)
+	s.recordGeneration(ctx, req.Model, req.Prompt, resp.Response, elapsed)
+}
+
+// batchGenerate handles POST /api/batch/generate, generating a response to
+// every prompt of the request
+func (s *Server) batchGenerate(c *gin.Context) {
+	var req types.BatchGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "Invalid request",
+		})
+		return
+	}
+	
+	if len(req.Prompts) == 0 {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "prompts must not be empty",
+		})
+		return
+	}
+	if limit := s.config.MaxBatchPrompts; limit > 0 && len(req.Prompts) > limit {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: fmt.Sprintf("too many prompts: %d, at most %d are accepted", len(req.Prompts), limit),
+		})
+		return
+	}
+	
+	// Ensure model is loaded
+	setRequestModel(c, req.Model)
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer release()
+	
+	loadStart := time.Now()
+	if err := s.ensureModelLoaded(c.Request.Context(), req.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	loadDuration := time.Since(loadStart)
+	
+	reqs := make([]*types.GenerateRequest, len(req.Prompts))
+	for i, prompt := range req.Prompts {
+		reqs[i] = &types.GenerateRequest{
+			Model:   req.Model,
+			Prompt:  prompt,
+			System:  req.System,
+			Options: req.Options,
+		}
+	}
+	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
+	start := time.Now()
+	responses, err := s.generateBatch(ctx, reqs)
+	s.recordGenerationResult(req.Model, err)
+	if err != nil {
+		c.JSON(inferenceStatus(err), types.ErrorResponse{
+			Error: inferenceError(err),
+		})
+		return
+	}
+	elapsed := time.Since(start)
+	
+	for i, resp := range responses {
+		generated := elapsed
+		if resp.InferenceMetrics != nil && resp.TotalDuration > 0 {
+			generated = resp.TotalDuration
+		}
+		addLoadDuration(resp, loadDuration, generated)
+		s.recordGeneration(ctx, req.Model, reqs[i].Prompt, resp.Response, elapsed)
+	}
+	
+	c.JSON(http.StatusOK, types.BatchGenerateResponse{
+		Model:         req.Model,
+		CreatedAt:     time.Now(),
+		Responses:     responses,
+		TotalDuration: loadDuration + elapsed,
+	})
 }
 
-// streamGenerate handles streaming generation
-func (s *Server) streamGenerate(c *gin.Context, req *types.GenerateRequest) {
+// generateBatch generates the responses to requests together when the engine
+// supports batching, and one after the other otherwise
+func (s *Server) generateBatch(ctx context.Context, reqs []*types.GenerateRequest) ([]*types.GenerateResponse, error) {
+	if batcher, ok := s.engine.(inference.BatchGenerator); ok {
+		return batcher.GenerateBatch(ctx, reqs)
+	}
+	
+	responses := make([]*types.GenerateResponse, len(reqs))
+	for i, req := range reqs {
+		start := time.Now()
+		resp, err := s.engine.Generate(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		if resp.InferenceMetrics == nil {
+			resp.InferenceMetrics = &types.InferenceMetrics{}
+		}
+		resp.TotalDuration = time.Since(start)
+		responses[i] = resp
+	}
+	return responses, nil
+}
+
+// streamGenerate handles streaming generation. The time spent loading the
+// model is added to the metrics of the final response, and the chunks and
+// final response are cached under cacheKey when it is set.
+func (s *Server) streamGenerate(c *gin.Context, req *types.GenerateRequest, loadDuration time.Duration, cacheKey string) {
 	c.Header("Content-Type", "application/x-ndjson")
 	c.Header("Transfer-Encoding", "chunked")
 	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
 	encoder := json.NewEncoder(c.Writer)
+	start := time.Now()
+	var text strings.Builder
+	var chunks []cachedChunk
 	
 	// Use the engine's streaming capability
-	err := s.engine.GenerateStream(req, func(resp *types.GenerateResponse) error {
+	err := s.engine.GenerateStream(ctx, req, func(resp *types.GenerateResponse) error {
+		text.WriteString(resp.Response)
+		if resp.Response != "" || resp.Done {
+			chunks = append(chunks, cachedChunk{text: resp.Response, offset: time.Since(start)})
+		}
+		if resp.Done {
+			addLoadDuration(resp, loadDuration, time.Since(start))
+			final := *resp
+			final.Response = text.String()
+			s.cacheResponse(cacheKey, &final, chunks)
+		}
 		if err := encoder.Encode(resp); err != nil {
 			return err
 		}
@@ -248,33 +953,96 @@ func (s *Server) streamGenerate(c *gin.Context, req *types.GenerateRequest) {
 		return nil
 	})
 	
-	if err != nil {
-		encoder.Encode(types.ErrorResponse{Error: err.Error()})
+	if errors.Is(err, context.Canceled) {
+		logger.WithContext(ctx).Debugf("Client disconnected, generation stopped")
+	} else if err != nil {
+		// The error is the final chunk of the stream
+		encoder.Encode(types.ErrorResponse{Error: inferenceError(err), Done: true})
+	}
+	s.recordGenerationResult(req.Model, err)
+	s.recordGeneration(ctx, req.Model, req.Prompt, text.String(), time.Since(start))
+}
+
+// chatPromptText joins the contents of chat messages, for counting the
+// tokens of a chat's prompt
+func chatPromptText(messages []types.Message) string {
+	contents := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		contents = append(contents, msg.Content)
+	}
+	return strings.Join(contents, "\n")
+}
+
+// setRequestModel adds the model a request is for to the entries logged with
+// the request's context
+func setRequestModel(c *gin.Context, name string) {
+	c.Request = c.Request.WithContext(logging.WithModel(c.Request.Context(), name))
+}
+
+// addLoadDuration completes the metrics of a final generate response with the
+// time spent loading the model, making the total cover the whole request
+func addLoadDuration(resp *types.GenerateResponse, loadDuration, elapsed time.Duration) {
+	if resp.InferenceMetrics == nil {
+		resp.InferenceMetrics = &types.InferenceMetrics{}
 	}
+	resp.LoadDuration = loadDuration
+	resp.TotalDuration = loadDuration + elapsed
 }
 
-// simpleChat handles non-streaming chat
-func (s *Server) simpleChat(c *gin.Context, req *types.ChatRequest) {
-	resp, err := s.engine.Chat(req)
+// simpleChat handles non-streaming chat. A positive tokensRemaining warns
+// that the messages use most of the request's token budget.
+func (s *Server) simpleChat(c *gin.Context, req *types.ChatRequest, tokensRemaining int) {
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
+	start := time.Now()
+	resp, err := s.engine.Chat(ctx, req)
+	s.recordGenerationResult(req.Model, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-			Error: err.Error(),
+		c.JSON(inferenceStatus(err), types.ErrorResponse{
+			Error: inferenceError(err),
 		})
 		return
 	}
+	elapsed := time.Since(start)
+	
+	reply := resp.Message.Content
+	if len(req.Tools) > 0 {
+		resp.Message.Content, resp.ToolCalls = parseToolCalls(req.Tools, reply)
+	}
+	resp.WarningTokensRemaining = tokensRemaining
 	
 	c.JSON(http.StatusOK, resp)
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), reply, elapsed)
 }
 
-// streamChat handles streaming chat
-func (s *Server) streamChat(c *gin.Context, req *types.ChatRequest) {
+// streamChat handles streaming chat. A positive tokensRemaining is set on
+// the final chunk, like in simpleChat.
+func (s *Server) streamChat(c *gin.Context, req *types.ChatRequest, tokensRemaining int) {
 	c.Header("Content-Type", "application/x-ndjson")
 	c.Header("Transfer-Encoding", "chunked")
 	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
 	encoder := json.NewEncoder(c.Writer)
+	start := time.Now()
+	var text strings.Builder
 	
 	// Use the engine's streaming capability
-	err := s.engine.ChatStream(req, func(resp *types.ChatResponse) error {
+	err := s.engine.ChatStream(ctx, req, func(resp *types.ChatResponse) error {
+		text.WriteString(resp.Message.Content)
+		if len(req.Tools) > 0 {
+			// Tool calls can only be parsed from the whole reply
+			if !resp.Done {
+				return nil
+			}
+			resp.Message.Content, resp.ToolCalls = parseToolCalls(req.Tools, text.String())
+		}
+		if resp.Done {
+			resp.WarningTokensRemaining = tokensRemaining
+		}
+		
 		if err := encoder.Encode(resp); err != nil {
 			return err
 		}
@@ -282,9 +1050,370 @@ func (s *Server) streamChat(c *gin.Context, req *types.ChatRequest) {
 		return nil
 	})
 	
+	if errors.Is(err, context.Canceled) {
+		logger.WithContext(ctx).Debugf("Client disconnected, generation stopped")
+	} else if err != nil {
+		// The error is the final chunk of the stream
+		encoder.Encode(types.ErrorResponse{Error: inferenceError(err), Done: true})
+	}
+	s.recordGenerationResult(req.Model, err)
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), text.String(), time.Since(start))
+}
+
+// chatCompletions handles POST /v1/chat/completions
+func (s *Server) chatCompletions(c *gin.Context) {
+	var req types.OpenAIChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: "Invalid request", Type: "invalid_request_error"},
+		})
+		return
+	}
+	
+	chatReq, err := mapToInternalChatRequest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	
+	// Ensure model is loaded
+	setRequestModel(c, chatReq.Model)
+	release, err := s.acquireModel(c.Request.Context(), chatReq.Model)
 	if err != nil {
-		encoder.Encode(types.ErrorResponse{Error: err.Error()})
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
 	}
+	defer release()
+	
+	if err := s.ensureModelLoaded(c.Request.Context(), chatReq.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	
+	id := newCompletionID()
+	
+	if req.Stream {
+		s.streamChatCompletions(c, chatReq, id)
+		return
+	}
+	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), chatReq.Model)
+	defer cancel()
+	
+	start := time.Now()
+	resp, err := s.engine.Chat(ctx, chatReq)
+	s.recordGenerationResult(chatReq.Model, err)
+	if err != nil {
+		c.JSON(inferenceStatus(err), types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: inferenceError(err), Type: "server_error"},
+		})
+		return
+	}
+	elapsed := time.Since(start)
+	
+	reply := resp.Message.Content
+	if len(chatReq.Tools) > 0 {
+		resp.Message.Content, resp.ToolCalls = parseToolCalls(chatReq.Tools, reply)
+	}
+	
+	c.JSON(http.StatusOK, mapFromInternalChatResponse(resp, id, req.TopLogprobs))
+	s.recordGeneration(ctx, chatReq.Model, chatPromptText(chatReq.Messages), reply, elapsed)
 }
 
+// streamChatCompletions streams chat completion chunks as server-sent events
+func (s *Server) streamChatCompletions(c *gin.Context, req *types.ChatRequest, id string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
+	start := time.Now()
+	created := start.Unix()
+	first := true
+	var text strings.Builder
+	
+	err := s.engine.ChatStream(ctx, req, func(resp *types.ChatResponse) error {
+		text.WriteString(resp.Message.Content)
+		delta := types.OpenAIDelta{Content: resp.Message.Content}
+		finishReason := "stop"
+		if len(req.Tools) > 0 {
+			// Tool calls can only be parsed from the whole reply
+			if !resp.Done {
+				return nil
+			}
+			content, calls := parseToolCalls(req.Tools, text.String())
+			delta = types.OpenAIDelta{Content: content, ToolCalls: mapToolCalls(calls, true)}
+			if len(calls) > 0 {
+				finishReason = "tool_calls"
+			}
+		}
+		if first {
+			delta.Role = "assistant"
+			first = false
+		}
+		
+		chunk := types.OpenAIChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   resp.Model,
+			Choices: []types.OpenAIChatCompletionChunkChoice{
+				{Index: 0, Delta: delta},
+			},
+		}
+		if resp.Done {
+			chunk.Choices[0].FinishReason = &finishReason
+		}
+		
+		return writeSSEData(c, chunk)
+	})
+	
+	if errors.Is(err, context.Canceled) {
+		logger.WithContext(ctx).Debugf("Client disconnected, generation stopped")
+	} else if err != nil {
+		writeSSEData(c, types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: inferenceError(err), Type: "server_error"},
+		})
+	}
+	
+	fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+	s.recordGenerationResult(req.Model, err)
+	s.recordGeneration(ctx, req.Model, chatPromptText(req.Messages), text.String(), time.Since(start))
+}
+
+// writeSSEData writes a JSON payload as a single server-sent event
+func writeSSEData(c *gin.Context, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
 
+// mapToInternalChatRequest converts an OpenAI chat completion request to a ChatRequest
+func mapToInternalChatRequest(req *types.OpenAIChatCompletionRequest) (*types.ChatRequest, error) {
+	messages := make([]types.Message, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		content := msg.Content
+		if len(msg.ToolCalls) > 0 && content == "" {
+			content = formatToolCalls(msg.ToolCalls)
+		}
+		messages = append(messages, types.Message{
+			Role:    msg.Role,
+			Content: content,
+		})
+	}
+	
+	options := &types.Options{
+		NumPredict:       req.MaxTokens,
+		Stop:             req.Stop,
+		FrequencyPenalty: float32(req.FrequencyPenalty),
+		PresencePenalty:  float32(req.PresencePenalty),
+	}
+	if req.Temperature != nil {
+		options.Temperature = *req.Temperature
+	}
+	if req.TopLogprobs < 0 || req.TopLogprobs > 20 {
+		return nil, fmt.Errorf("top_logprobs must be between 0 and 20")
+	}
+	if req.TopLogprobs > 0 && !req.Logprobs {
+		return nil, fmt.Errorf("top_logprobs requires logprobs to be true")
+	}
+	if req.Logprobs {
+		// Always compute at least one candidate; extras are trimmed from the response
+		options.Logprobs = req.TopLogprobs
+		if options.Logprobs == 0 {
+			options.Logprobs = 1
+		}
+	}
+	if req.TopP != nil {
+		options.TopP = *req.TopP
+	}
+	
+	chatReq := &types.ChatRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Stream:   req.Stream,
+		Options:  options,
+	}
+	
+	if req.ResponseFormat != nil {
+		switch req.ResponseFormat.Type {
+		case "", "text":
+		case "json_object":
+			options.Format = "json"
+		case "json_schema":
+			if req.ResponseFormat.JSONSchema == nil || len(req.ResponseFormat.JSONSchema.Schema) == 0 {
+				return nil, fmt.Errorf("response_format json_schema requires a schema")
+			}
+			chatReq.JSONSchema = req.ResponseFormat.JSONSchema.Schema
+			if err := validateJSONSchema(chatReq.JSONSchema); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported response_format type: %s", req.ResponseFormat.Type)
+		}
+	}
+	
+	for _, tool := range req.Tools {
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type: %s", tool.Type)
+		}
+		chatReq.Tools = append(chatReq.Tools, types.ToolDefinition{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	chatReq.ToolChoice = string(req.ToolChoice)
+	if err := applyTools(chatReq); err != nil {
+		return nil, err
+	}
+	
+	return chatReq, nil
+}
+
+// validateJSONSchema checks that a request's JSON schema can be compiled to a grammar
+func validateJSONSchema(schema json.RawMessage) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if _, err := grammar.FromJSONSchema(schema); err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+	return nil
+}
+
+// mapFromInternalChatResponse converts a ChatResponse to an OpenAI chat completion response
+func mapFromInternalChatResponse(resp *types.ChatResponse, id string, topLogprobs int) *types.OpenAIChatCompletionResponse {
+	result := &types.OpenAIChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []types.OpenAIChatCompletionChoice{
+			{
+				Index: 0,
+				Message: types.OpenAIMessage{
+					Role:    resp.Message.Role,
+					Content: resp.Message.Content,
+				},
+				FinishReason: "stop",
+			},
+		},
+	}
+	
+	if len(resp.ToolCalls) > 0 {
+		result.Choices[0].Message.ToolCalls = mapToolCalls(resp.ToolCalls, false)
+		result.Choices[0].FinishReason = "tool_calls"
+	}
+	
+	if len(resp.Logprobs) > 0 {
+		result.Choices[0].Logprobs = mapLogprobs(resp.Logprobs, topLogprobs)
+	}
+	
+	return result
+}
+
+// mapLogprobs converts token log probabilities to the OpenAI format, keeping
+// at most topLogprobs alternatives per token
+func mapLogprobs(logprobs []types.TokenLogprob, topLogprobs int) *types.OpenAILogprobs {
+	content := make([]types.OpenAITokenLogprob, 0, len(logprobs))
+	for _, lp := range logprobs {
+		top := make([]types.OpenAITopLogprob, 0, topLogprobs)
+		for i, alt := range lp.TopLogprobs {
+			if i >= topLogprobs {
+				break
+			}
+			top = append(top, types.OpenAITopLogprob{Token: alt.Token, Logprob: alt.Logprob})
+		}
+		content = append(content, types.OpenAITokenLogprob{
+			Token:       lp.Token,
+			Logprob:     lp.Logprob,
+			TopLogprobs: top,
+		})
+	}
+	return &types.OpenAILogprobs{Content: content}
+}
+
+// newCompletionID generates a unique identifier for an OpenAI-style completion
+func newCompletionID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	return "chatcmpl-" + hex.EncodeToString(buf)
+}
+
+// embeddings handles POST /v1/embeddings
+func (s *Server) embeddings(c *gin.Context) {
+	var req types.OpenAIEmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Input) == 0 {
+		c.JSON(http.StatusBadRequest, types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: "Invalid request", Type: "invalid_request_error"},
+		})
+		return
+	}
+	
+	// Ensure model is loaded
+	setRequestModel(c, req.Model)
+	release, err := s.acquireModel(c.Request.Context(), req.Model)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer release()
+	
+	if err := s.ensureModelLoaded(c.Request.Context(), req.Model); err != nil {
+		c.JSON(loadErrorStatus(err), types.OpenAIErrorResponse{
+			Error: types.OpenAIError{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	
+	ctx, cancel := s.withRequestTimeout(c.Request.Context(), req.Model)
+	defer cancel()
+	
+	resp := types.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]types.OpenAIEmbedding, 0, len(req.Input)),
+		Model:  req.Model,
+	}
+	
+	for i, input := range req.Input {
+		embedResp, err := s.engine.Embed(ctx, &types.EmbedRequest{
+			Model: req.Model,
+			Input: input,
+		})
+		if err != nil {
+			c.JSON(inferenceStatus(err), types.OpenAIErrorResponse{
+				Error: types.OpenAIError{Message: inferenceError(err), Type: "server_error"},
+			})
+			return
+		}
+		
+		resp.Data = append(resp.Data, types.OpenAIEmbedding{
+			Object:    "embedding",
+			Embedding: embedResp.Embedding,
+			Index:     i,
+		})
+	}
+	
+	c.JSON(http.StatusOK, resp)
+}
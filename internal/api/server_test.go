@@ -0,0 +1,765 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/api/middleware"
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestServer creates a server running the simulated engine, with its
+// models and state in a temporary directory. configure may change the
+// configuration before the server is created.
+func newTestServer(t *testing.T, configure func(cfg *config.Config)) *Server {
+	t.Helper()
+	t.Setenv("COLOSSUS_INFERENCE_ENGINE", "simulated")
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		ModelsPath:             filepath.Join(dir, "models"),
+		SessionsPath:           filepath.Join(dir, "sessions"),
+		CircuitRecoveryTimeout: time.Minute,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	return NewServer(cfg, model.NewManager(cfg.ModelsPath))
+}
+
+// loadTestModel loads a model into the simulated engine of s
+func loadTestModel(t *testing.T, s *Server, name string) {
+	t.Helper()
+	if err := s.engine.LoadModel(name, filepath.Join(s.config.ModelsPath, name+".gguf"), nil); err != nil {
+		t.Fatalf("LoadModel(%s): %v", name, err)
+	}
+}
+
+// ggufKV is a metadata key-value pair of a test model file
+type ggufKV struct {
+	key   string
+	value interface{}
+}
+
+// installTestModel writes a model file for name, so that the server can load
+// the model by itself. The file is a GGUF file without tensors holding the
+// given metadata, whose values may be strings or uint32.
+func installTestModel(t *testing.T, s *Server, name string, metadata ...ggufKV) string {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)), []byte(s))
+	}
+
+	write(uint32(model.GGUFMagic), uint32(model.GGUFVersion3), uint64(0), uint64(len(metadata)))
+	for _, kv := range metadata {
+		writeString(kv.key)
+		switch v := kv.value.(type) {
+		case string:
+			write(uint32(model.GGUFTypeString))
+			writeString(v)
+		case uint32:
+			write(uint32(model.GGUFTypeUint32), v)
+		default:
+			t.Fatalf("unsupported GGUF value %T", v)
+		}
+	}
+
+	path := filepath.Join(s.config.ModelsPath, name+".gguf")
+	if err := os.MkdirAll(s.config.ModelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// slowEngine is a simulated engine whose generations run until their context
+// ends, streaming a chunk every interval. The error each generation stopped
+// with is sent on stopped.
+type slowEngine struct {
+	*inference.SimulatedEngine
+	interval time.Duration
+	stopped  chan error
+}
+
+func newSlowEngine(interval time.Duration) *slowEngine {
+	return &slowEngine{
+		SimulatedEngine: inference.NewSimulatedEngine(),
+		interval:        interval,
+		stopped:         make(chan error, 1),
+	}
+}
+
+// Generate waits for the context to end
+func (e *slowEngine) Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
+	<-ctx.Done()
+	e.stopped <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+// GenerateStream streams chunks until the context ends or the callback fails
+func (e *slowEngine) GenerateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.stopped <- ctx.Err()
+			return ctx.Err()
+		case <-ticker.C:
+			if err := callback(&types.GenerateResponse{Model: req.Model, CreatedAt: time.Now(), Response: "more "}); err != nil {
+				e.stopped <- err
+				return err
+			}
+		}
+	}
+}
+
+// waitStopped returns the error the generation of e stopped with
+func waitStopped(t *testing.T, e *slowEngine) error {
+	t.Helper()
+	select {
+	case err := <-e.stopped:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("generation still running after 5s")
+		return nil
+	}
+}
+
+// waitIdle waits until no request for a model is in progress
+func waitIdle(t *testing.T, s *Server, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, idle := range s.loadedModels.Idle(time.Now()) {
+			if idle == name {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("request for %s still in progress after 5s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// serve sends a request to the router of s and returns the recorded response
+func serve(s *Server, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, req)
+	return w
+}
+
+func TestMapToInternalChatRequest(t *testing.T) {
+	temperature, topP := 0.2, 0.9
+
+	tests := []struct {
+		name    string
+		req     types.OpenAIChatCompletionRequest
+		want    *types.ChatRequest
+		wantErr string
+	}{
+		{
+			name: "sampling options",
+			req: types.OpenAIChatCompletionRequest{
+				Model: "tinyllama",
+				Messages: []types.OpenAIMessage{
+					{Role: "system", Content: "Be brief."},
+					{Role: "user", Content: "Hello"},
+				},
+				Temperature:      &temperature,
+				TopP:             &topP,
+				MaxTokens:        64,
+				Stop:             types.OpenAIStringList{"\n"},
+				FrequencyPenalty: 0.5,
+				PresencePenalty:  0.25,
+			},
+			want: &types.ChatRequest{
+				Model: "tinyllama",
+				Messages: []types.Message{
+					{Role: "system", Content: "Be brief."},
+					{Role: "user", Content: "Hello"},
+				},
+				Options: &types.Options{
+					Temperature:      0.2,
+					TopP:             0.9,
+					NumPredict:       64,
+					Stop:             []string{"\n"},
+					FrequencyPenalty: 0.5,
+					PresencePenalty:  0.25,
+				},
+			},
+		},
+		{
+			name: "streaming",
+			req: types.OpenAIChatCompletionRequest{
+				Model:    "tinyllama",
+				Messages: []types.OpenAIMessage{{Role: "user", Content: "Hello"}},
+				Stream:   true,
+			},
+			want: &types.ChatRequest{
+				Model:    "tinyllama",
+				Messages: []types.Message{{Role: "user", Content: "Hello"}},
+				Stream:   true,
+				Options:  &types.Options{},
+			},
+		},
+		{
+			name: "logprobs without top_logprobs compute one candidate",
+			req: types.OpenAIChatCompletionRequest{
+				Model:    "tinyllama",
+				Messages: []types.OpenAIMessage{{Role: "user", Content: "Hello"}},
+				Logprobs: true,
+			},
+			want: &types.ChatRequest{
+				Model:    "tinyllama",
+				Messages: []types.Message{{Role: "user", Content: "Hello"}},
+				Options:  &types.Options{Logprobs: 1},
+			},
+		},
+		{
+			name: "json_object response format",
+			req: types.OpenAIChatCompletionRequest{
+				Model:          "tinyllama",
+				Messages:       []types.OpenAIMessage{{Role: "user", Content: "Hello"}},
+				ResponseFormat: &types.OpenAIResponseFormat{Type: "json_object"},
+			},
+			want: &types.ChatRequest{
+				Model:    "tinyllama",
+				Messages: []types.Message{{Role: "user", Content: "Hello"}},
+				Options:  &types.Options{Format: "json"},
+			},
+		},
+		{
+			name: "top_logprobs without logprobs",
+			req: types.OpenAIChatCompletionRequest{
+				Model:       "tinyllama",
+				TopLogprobs: 3,
+			},
+			wantErr: "top_logprobs requires logprobs to be true",
+		},
+		{
+			name: "top_logprobs out of range",
+			req: types.OpenAIChatCompletionRequest{
+				Model:       "tinyllama",
+				Logprobs:    true,
+				TopLogprobs: 21,
+			},
+			wantErr: "top_logprobs must be between 0 and 20",
+		},
+		{
+			name: "json_schema without a schema",
+			req: types.OpenAIChatCompletionRequest{
+				Model:          "tinyllama",
+				ResponseFormat: &types.OpenAIResponseFormat{Type: "json_schema"},
+			},
+			wantErr: "response_format json_schema requires a schema",
+		},
+		{
+			name: "unsupported response format",
+			req: types.OpenAIChatCompletionRequest{
+				Model:          "tinyllama",
+				ResponseFormat: &types.OpenAIResponseFormat{Type: "xml"},
+			},
+			wantErr: "unsupported response_format type: xml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapToInternalChatRequest(&tt.req)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapFromInternalChatResponse(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		resp        types.ChatResponse
+		topLogprobs int
+		want        types.OpenAIChatCompletionChoice
+	}{
+		{
+			name: "reply",
+			resp: types.ChatResponse{
+				Message: types.Message{Role: "assistant", Content: "Hello!"},
+			},
+			want: types.OpenAIChatCompletionChoice{
+				Message:      types.OpenAIMessage{Role: "assistant", Content: "Hello!"},
+				FinishReason: "stop",
+			},
+		},
+		{
+			name: "logprobs trimmed to top_logprobs",
+			resp: types.ChatResponse{
+				Message: types.Message{Role: "assistant", Content: "Hi"},
+				Logprobs: []types.TokenLogprob{{
+					Token:   "Hi",
+					Logprob: -0.1,
+					TopLogprobs: []types.TokenLogprob{
+						{Token: "Hi", Logprob: -0.1},
+						{Token: "Hello", Logprob: -2.5},
+					},
+				}},
+			},
+			topLogprobs: 1,
+			want: types.OpenAIChatCompletionChoice{
+				Message: types.OpenAIMessage{Role: "assistant", Content: "Hi"},
+				Logprobs: &types.OpenAILogprobs{Content: []types.OpenAITokenLogprob{{
+					Token:       "Hi",
+					Logprob:     -0.1,
+					TopLogprobs: []types.OpenAITopLogprob{{Token: "Hi", Logprob: -0.1}},
+				}}},
+				FinishReason: "stop",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.Model = "tinyllama"
+			tt.resp.CreatedAt = created
+
+			got := mapFromInternalChatResponse(&tt.resp, "chatcmpl-test", tt.topLogprobs)
+			want := &types.OpenAIChatCompletionResponse{
+				ID:      "chatcmpl-test",
+				Object:  "chat.completion",
+				Created: created.Unix(),
+				Model:   "tinyllama",
+				Choices: []types.OpenAIChatCompletionChoice{tt.want},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestChatCompletions(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	request := `{"model": "tinyllama", "messages": [{"role": "user", "content": "hello"}], "stream": %t}`
+
+	w := serve(s, http.MethodPost, "/v1/chat/completions", fmt.Sprintf(request, false), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.OpenAIChatCompletionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Object != "chat.completion" || len(resp.Choices) != 1 || resp.Choices[0].FinishReason != "stop" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	reply := resp.Choices[0].Message.Content
+
+	w = serve(s, http.MethodPost, "/v1/chat/completions", fmt.Sprintf(request, true), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	var chunks []types.OpenAIChatCompletionChunk
+	done := false
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if done {
+			t.Fatalf("event after [DONE]: %s", data)
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk types.OpenAIChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %s: %v", data, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if !done {
+		t.Fatal("stream not terminated by [DONE]")
+	}
+	if len(chunks) == 0 {
+		t.Fatal("no chunks streamed")
+	}
+
+	var streamed strings.Builder
+	for i, chunk := range chunks {
+		if chunk.Object != "chat.completion.chunk" || chunk.ID != chunks[0].ID {
+			t.Errorf("chunk %d: object %q, id %q", i, chunk.Object, chunk.ID)
+		}
+		delta := chunk.Choices[0].Delta
+		if (delta.Role == "assistant") != (i == 0) {
+			t.Errorf("chunk %d: role %q", i, delta.Role)
+		}
+		last := i == len(chunks)-1
+		if finish := chunk.Choices[0].FinishReason; (finish != nil) != last || (last && *finish != "stop") {
+			t.Errorf("chunk %d: finish_reason %v", i, finish)
+		}
+		streamed.WriteString(delta.Content)
+	}
+	if streamed.String() != reply {
+		t.Errorf("streamed %q, want %q", streamed.String(), reply)
+	}
+}
+
+func TestLogprobs(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	checkLogprobs := func(t *testing.T, content string, tokens []string, logprobs []float32, top [][]string) {
+		t.Helper()
+		if len(tokens) == 0 {
+			t.Fatal("no logprobs returned")
+		}
+		if got := strings.Join(tokens, ""); got != content {
+			t.Errorf("logprob tokens %q, want the content %q", got, content)
+		}
+		for i := range tokens {
+			if logprobs[i] > 0 {
+				t.Errorf("token %d: logprob %v is positive", i, logprobs[i])
+			}
+			if len(top[i]) != 2 {
+				t.Errorf("token %d: %d top logprobs, want 2", i, len(top[i]))
+			}
+		}
+	}
+
+	t.Run("generate", func(t *testing.T) {
+		w := serve(s, http.MethodPost, "/api/generate",
+			`{"model": "tinyllama", "prompt": "hello", "options": {"logprobs": 2}}`, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		var resp types.GenerateResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+
+		var tokens []string
+		var logprobs []float32
+		var top [][]string
+		for _, lp := range resp.Logprobs {
+			tokens = append(tokens, lp.Token)
+			logprobs = append(logprobs, lp.Logprob)
+			var alternatives []string
+			for _, alt := range lp.TopLogprobs {
+				alternatives = append(alternatives, alt.Token)
+			}
+			top = append(top, alternatives)
+		}
+		checkLogprobs(t, resp.Response, tokens, logprobs, top)
+	})
+
+	t.Run("chat completions", func(t *testing.T) {
+		w := serve(s, http.MethodPost, "/v1/chat/completions",
+			`{"model": "tinyllama", "messages": [{"role": "user", "content": "hello"}], "logprobs": true, "top_logprobs": 2}`, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		var resp types.OpenAIChatCompletionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		choice := resp.Choices[0]
+		if choice.Logprobs == nil {
+			t.Fatal("no logprobs in the choice")
+		}
+
+		var tokens []string
+		var logprobs []float32
+		var top [][]string
+		for _, lp := range choice.Logprobs.Content {
+			tokens = append(tokens, lp.Token)
+			logprobs = append(logprobs, lp.Logprob)
+			var alternatives []string
+			for _, alt := range lp.TopLogprobs {
+				alternatives = append(alternatives, alt.Token)
+			}
+			top = append(top, alternatives)
+		}
+		checkLogprobs(t, choice.Message.Content, tokens, logprobs, top)
+	})
+}
+
+func TestDeleteSession(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	path, err := s.sessions.Path("chat")
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("kv"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "existing session", body: `{"session_id": "chat"}`, wantStatus: http.StatusOK},
+		{name: "already deleted", body: `{"session_id": "chat"}`, wantStatus: http.StatusNotFound},
+		{name: "invalid id", body: `{"session_id": "../chat"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing id", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(s, http.MethodDelete, "/api/session/delete", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("session file still exists: %v", err)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	w := serve(s, http.MethodPost, "/api/tokenize", `{"model": "tinyllama", "prompt": "hello big world"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.TokenizeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	want, err := s.engine.Tokenize(&types.TokenizeRequest{Model: "tinyllama", Prompt: "hello big world"})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if resp.Count != 3 || !reflect.DeepEqual(resp.Tokens, want.Tokens) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+
+	w = serve(s, http.MethodPost, "/api/tokenize", `{"model": "missing", "prompt": "hello"}`, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown model: status = %d, want 404: %s", w.Code, w.Body)
+	}
+}
+
+func TestGenerateStreamStopsWhenClientDisconnects(t *testing.T) {
+	s := newTestServer(t, nil)
+	engine := newSlowEngine(10 * time.Millisecond)
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/api/generate",
+		strings.NewReader(`{"model": "tinyllama", "prompt": "hello", "stream": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The client goes away after 3 chunks
+	scanner := bufio.NewScanner(resp.Body)
+	for i := 0; i < 3; i++ {
+		if !scanner.Scan() {
+			t.Fatalf("stream ended after %d chunks: %v", i, scanner.Err())
+		}
+	}
+	cancel()
+
+	if err := waitStopped(t, engine); !errors.Is(err, context.Canceled) {
+		t.Errorf("generation stopped with %v, want %v", err, context.Canceled)
+	}
+
+	// The request no longer holds the model
+	waitIdle(t, s, "tinyllama")
+}
+
+func TestListLoadedModels(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+
+	list := func() []types.RunningModel {
+		t.Helper()
+		w := serve(s, http.MethodGet, "/api/ps", "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		var resp types.ProcessResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return resp.Models
+	}
+
+	if models := list(); len(models) != 0 {
+		t.Fatalf("models before any request = %v, want none", models)
+	}
+
+	if w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("generate = %d: %s", w.Code, w.Body)
+	}
+
+	models := list()
+	if len(models) != 1 || models[0].Name != "tinyllama" {
+		t.Fatalf("models = %v, want tinyllama", models)
+	}
+	if m := models[0]; m.ActiveRequests != 0 || m.LoadedAt.IsZero() || m.LastRequest.Before(m.LoadedAt) {
+		t.Errorf("tinyllama = %+v, want an idle model used since it was loaded", m)
+	}
+}
+
+func TestRequestLogCarriesModel(t *testing.T) {
+	logger := logrus.StandardLogger()
+	out, formatter := logger.Out, logger.Formatter
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.ReplaceHooks(make(logrus.LevelHooks))
+	})
+	if err := logging.Setup(logging.FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	header := http.Header{middleware.RequestIDHeader: {"req-7"}}
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello", "stream": false}`, header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// Every line is a JSON entry, and the request's entry has its model
+	var handled map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if entry["msg"] == "Request handled" {
+			handled = entry
+		}
+	}
+	if handled == nil {
+		t.Fatalf("request not logged: %s", buf.String())
+	}
+	want := map[string]interface{}{
+		"request_id": "req-7",
+		"model":      "tinyllama",
+		"path":       "/api/generate",
+		"status":     float64(http.StatusOK),
+	}
+	for key, value := range want {
+		if handled[key] != value {
+			t.Errorf("%s = %v, want %v", key, handled[key], value)
+		}
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	s := newTestServer(t, nil)
+	installTestModel(t, s, "tinyllama")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"missing model", `{}`, http.StatusBadRequest},
+		{"invalid KV cache type", `{"model": "tinyllama", "kv_cache_type": "q5_1"}`, http.StatusBadRequest},
+		{"unknown model", `{"model": "mistral"}`, http.StatusNotFound},
+		{"load", `{"model": "tinyllama"}`, http.StatusOK},
+		{"already loaded", `{"model": "tinyllama"}`, http.StatusOK},
+		{"reload with a quantized KV cache", `{"model": "tinyllama", "kv_cache_type": "q8_0"}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(s, http.MethodPost, "/api/load", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp types.LoadResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Model != "tinyllama" || resp.ContextSize <= 0 {
+				t.Errorf("response = %+v, want tinyllama and its context size", resp)
+			}
+			if !s.engine.IsModelLoaded("tinyllama") {
+				t.Error("tinyllama not loaded")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// slowQueryLogSize is the number of slow queries kept for /api/slow-queries
+const slowQueryLogSize = 100
+
+// slowQueryLog is a ring buffer of the most recent slow queries
+type slowQueryLog struct {
+	mutex   sync.Mutex
+	entries [slowQueryLogSize]types.SlowQuery
+	next    int
+	count   int
+}
+
+// add records a slow query, replacing the oldest one when the log is full
+func (l *slowQueryLog) add(query types.SlowQuery) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries[l.next] = query
+	l.next = (l.next + 1) % slowQueryLogSize
+	if l.count < slowQueryLogSize {
+		l.count++
+	}
+}
+
+// list returns the recorded slow queries, newest first
+func (l *slowQueryLog) list() []types.SlowQuery {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	queries := make([]types.SlowQuery, 0, l.count)
+	for i := 1; i <= l.count; i++ {
+		queries = append(queries, l.entries[(l.next-i+slowQueryLogSize)%slowQueryLogSize])
+	}
+	return queries
+}
+
+// recordSlowQuery logs a generation that took longer than the slow query
+// threshold and keeps it for /api/slow-queries
+func (s *Server) recordSlowQuery(ctx context.Context, model string, promptTokens, responseTokens int, elapsed time.Duration) {
+	query := types.SlowQuery{
+		Time:           time.Now(),
+		RequestID:      logging.RequestID(ctx),
+		Model:          model,
+		PromptTokens:   promptTokens,
+		ResponseTokens: responseTokens,
+		Threshold:      s.config.SlowQueryThreshold,
+		Duration:       elapsed,
+	}
+	if elapsed > 0 {
+		query.TokensPerSecond = float64(responseTokens) / elapsed.Seconds()
+	}
+	s.slowQueries.add(query)
+
+	logger.WithContext(ctx).WithFields(logrus.Fields{
+		"model":             model,
+		"prompt_tokens":     promptTokens,
+		"response_tokens":   responseTokens,
+		"tokens_per_second": query.TokensPerSecond,
+		"threshold":         query.Threshold.String(),
+		"duration":          elapsed.String(),
+	}).Warn("Slow query")
+}
+
+// listSlowQueries handles GET /api/slow-queries
+func (s *Server) listSlowQueries(c *gin.Context) {
+	c.JSON(http.StatusOK, types.SlowQueriesResponse{
+		Queries: s.slowQueries.list(),
+	})
+}
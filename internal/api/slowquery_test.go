@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/api/middleware"
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestSlowQueryLogKeepsNewest(t *testing.T) {
+	var log slowQueryLog
+	if queries := log.list(); len(queries) != 0 {
+		t.Fatalf("list() = %v, want none", queries)
+	}
+
+	for i := 0; i < slowQueryLogSize+5; i++ {
+		log.add(types.SlowQuery{Model: fmt.Sprintf("model-%d", i)})
+	}
+
+	queries := log.list()
+	if len(queries) != slowQueryLogSize {
+		t.Fatalf("kept %d queries, want %d", len(queries), slowQueryLogSize)
+	}
+	if first, last := queries[0].Model, queries[len(queries)-1].Model; first != "model-104" || last != "model-5" {
+		t.Errorf("queries run from %s to %s, want model-104 to model-5", first, last)
+	}
+}
+
+// listSlowQueries returns the queries listed by /api/slow-queries
+func listSlowQueries(t *testing.T, s *Server) []types.SlowQuery {
+	t.Helper()
+	w := serve(s, http.MethodGet, "/api/slow-queries", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.SlowQueriesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return resp.Queries
+}
+
+func TestSlowQueries(t *testing.T) {
+	if err := logging.Setup(logging.FormatText); err != nil {
+		t.Fatal(err)
+	}
+	hook := test.NewLocal(logrus.StandardLogger())
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks)) })
+
+	// Every generation is slower than a nanosecond
+	s := newTestServer(t, func(cfg *config.Config) { cfg.SlowQueryThreshold = time.Nanosecond })
+	loadTestModel(t, s, "tinyllama")
+
+	header := http.Header{middleware.RequestIDHeader: {"req-slow"}}
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "tell me a story", "stream": false}`, header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	w = serve(s, http.MethodPost, "/api/chat", `{"model": "tinyllama", "messages": [{"role": "user", "content": "hi"}], "stream": true}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	queries := listSlowQueries(t, s)
+	if len(queries) != 2 {
+		t.Fatalf("listed %d slow queries, want 2", len(queries))
+	}
+	generate := queries[1]
+	if generate.RequestID != "req-slow" || generate.Model != "tinyllama" || generate.Threshold != time.Nanosecond {
+		t.Errorf("slow query = %+v, want the generate request", generate)
+	}
+	if generate.PromptTokens == 0 || generate.ResponseTokens == 0 || generate.Duration <= 0 || generate.TokensPerSecond <= 0 {
+		t.Errorf("slow query = %+v, want its token counts and speed", generate)
+	}
+
+	var logged []*logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Slow query" {
+			logged = append(logged, entry)
+		}
+	}
+	if len(logged) != 2 || logged[0].Level != logrus.WarnLevel || logged[0].Data["request_id"] != "req-slow" {
+		t.Errorf("logged %d slow queries, want 2 warnings with request IDs", len(logged))
+	}
+}
+
+func TestSlowQueriesBelowThreshold(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) { cfg.SlowQueryThreshold = time.Hour })
+	loadTestModel(t, s, "tinyllama")
+
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello", "stream": false}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if queries := listSlowQueries(t, s); len(queries) != 0 {
+		t.Errorf("listed %v, want no slow queries", queries)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsFileName is the file the model statistics are kept in across
+// restarts, next to the models directory, e.g. ~/.colossus/stats.json
+const StatsFileName = "stats.json"
+
+// modelStats accumulates the statistics of a model
+type modelStats struct {
+	mutex sync.Mutex
+	stats types.ModelStats
+}
+
+// statsFor returns the statistics of a model, creating them on its first
+// generation
+func (s *Server) statsFor(model string) *modelStats {
+	if stats, ok := s.stats.Load(model); ok {
+		return stats.(*modelStats)
+	}
+	stats, _ := s.stats.LoadOrStore(model, &modelStats{stats: types.ModelStats{Model: model}})
+	return stats.(*modelStats)
+}
+
+// recordStats adds a generation to the statistics of a model
+func (s *Server) recordStats(model string, promptTokens, tokens int, elapsed time.Duration) {
+	m := s.statsFor(model)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.stats.RequestCount++
+	m.stats.TotalPromptTokens += int64(promptTokens)
+	m.stats.TotalTokensGenerated += int64(tokens)
+	m.stats.TotalDuration += elapsed
+	if m.stats.TotalDuration > 0 {
+		m.stats.AverageTokensPerSecond = float64(m.stats.TotalTokensGenerated) / m.stats.TotalDuration.Seconds()
+	}
+}
+
+// recordGenerationResult reports the outcome of a generation to the circuit
+// breaker of its model and adds failed generations to the model's
+// statistics. Generations canceled by the client are neither successes nor
+// failures.
+func (s *Server) recordGenerationResult(model string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	circuit := s.loadedModels.Circuit(model)
+	if err == nil {
+		circuit.Success()
+		return
+	}
+	circuit.Failure()
+
+	m := s.statsFor(model)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.stats.ErrorCount++
+}
+
+// modelStatsList returns a copy of the statistics of every model, sorted by
+// name
+func (s *Server) modelStatsList() []types.ModelStats {
+	list := []types.ModelStats{}
+	s.stats.Range(func(_, value interface{}) bool {
+		m := value.(*modelStats)
+		m.mutex.Lock()
+		list = append(list, m.stats)
+		m.mutex.Unlock()
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Model < list[j].Model })
+	return list
+}
+
+// statsPath returns the file the statistics are persisted to
+func (s *Server) statsPath() string {
+	return filepath.Join(filepath.Dir(s.config.ModelsPath), StatsFileName)
+}
+
+// loadStats reads the statistics saved by the last run of the server, if any
+func (s *Server) loadStats() error {
+	data, err := os.ReadFile(s.statsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read model statistics: %w", err)
+	}
+
+	var list []types.ModelStats
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.statsPath(), err)
+	}
+	for _, stats := range list {
+		if stats.Model != "" {
+			s.stats.Store(stats.Model, &modelStats{stats: stats})
+		}
+	}
+	return nil
+}
+
+// SaveStats writes the statistics of every model, replacing the previous file
+// atomically. It is called when the server shuts down, so that the
+// statistics survive restarts.
+func (s *Server) SaveStats() error {
+	path := s.statsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s.modelStatsList(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode model statistics: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model statistics: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write model statistics: %w", err)
+	}
+	return nil
+}
+
+// listStats handles GET /api/stats
+func (s *Server) listStats(c *gin.Context) {
+	c.JSON(http.StatusOK, types.StatsResponse{
+		Models: s.modelStatsList(),
+	})
+}
+
+// getStats handles GET /api/stats/:model
+func (s *Server) getStats(c *gin.Context) {
+	name := c.Param("model")
+	stats, ok := s.stats.Load(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error: fmt.Sprintf("no statistics for model %s", name),
+		})
+		return
+	}
+
+	m := stats.(*modelStats)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	c.JSON(http.StatusOK, m.stats)
+}
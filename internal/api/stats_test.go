@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/types"
+)
+
+// getModelStats returns the statistics the server reports for a model
+func getModelStats(t *testing.T, s *Server, name string) types.ModelStats {
+	t.Helper()
+	w := serve(s, http.MethodGet, "/api/stats/"+name, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/stats/%s: status = %d, body = %s", name, w.Code, w.Body.String())
+	}
+	var stats types.ModelStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestStatsCountRequests(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+	loadTestModel(t, s, "llama3")
+
+	requests := []struct {
+		path string
+		body string
+	}{
+		{"/api/generate", `{"model": "tinyllama", "prompt": "hello there"}`},
+		{"/api/generate", `{"model": "tinyllama", "prompt": "tell me about llamas", "stream": true}`},
+		{"/api/chat", `{"model": "tinyllama", "messages": [{"role": "user", "content": "hello"}]}`},
+		{"/api/chat", `{"model": "llama3", "messages": [{"role": "user", "content": "hello"}], "stream": true}`},
+	}
+
+	var last types.ModelStats
+	for i, r := range requests[:3] {
+		if w := serve(s, http.MethodPost, r.path, r.body, nil); w.Code != http.StatusOK {
+			t.Fatalf("POST %s: status = %d, body = %s", r.path, w.Code, w.Body.String())
+		}
+
+		// Every request adds to the counters of its model
+		stats := getModelStats(t, s, "tinyllama")
+		if stats.RequestCount != int64(i+1) {
+			t.Errorf("after request %d: request count = %d, want %d", i+1, stats.RequestCount, i+1)
+		}
+		if stats.TotalTokensGenerated <= last.TotalTokensGenerated || stats.TotalPromptTokens <= last.TotalPromptTokens || stats.TotalDuration <= last.TotalDuration {
+			t.Errorf("after request %d: stats = %+v, want more tokens and time than %+v", i+1, stats, last)
+		}
+		last = stats
+	}
+
+	// The simulated engine counts words: 2 + 4 + 1 prompt tokens
+	if last.TotalPromptTokens != 7 {
+		t.Errorf("prompt tokens = %d, want 7", last.TotalPromptTokens)
+	}
+	want := float64(last.TotalTokensGenerated) / last.TotalDuration.Seconds()
+	if last.AverageTokensPerSecond != want {
+		t.Errorf("average speed = %f tokens/s, want %f", last.AverageTokensPerSecond, want)
+	}
+	if last.ErrorCount != 0 {
+		t.Errorf("error count = %d, want 0", last.ErrorCount)
+	}
+
+	r := requests[3]
+	if w := serve(s, http.MethodPost, r.path, r.body, nil); w.Code != http.StatusOK {
+		t.Fatalf("POST %s: status = %d, body = %s", r.path, w.Code, w.Body.String())
+	}
+
+	w := serve(s, http.MethodGet, "/api/stats", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/stats: status = %d", w.Code)
+	}
+	var list types.StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, stats := range list.Models {
+		names = append(names, stats.Model)
+	}
+	if want := []string{"llama3", "tinyllama"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("models = %v, want %v", names, want)
+	}
+	if list.Models[0].RequestCount != 1 || !reflect.DeepEqual(list.Models[1], last) {
+		t.Errorf("stats = %+v, want one llama3 request and %+v", list.Models, last)
+	}
+}
+
+func TestStatsCountErrors(t *testing.T) {
+	s, engine := newTimeoutServer(t, 20*time.Millisecond)
+
+	if w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil); w.Code == http.StatusOK {
+		t.Fatalf("status = %d, want the request to time out", w.Code)
+	}
+	waitStopped(t, engine)
+	serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello", "stream": true}`, nil)
+	waitStopped(t, engine)
+
+	stats := getModelStats(t, s, "tinyllama")
+	if stats.ErrorCount != 2 {
+		t.Errorf("error count = %d, want 2", stats.ErrorCount)
+	}
+	// The failed stream produced part of a response
+	if stats.RequestCount != 1 {
+		t.Errorf("request count = %d, want the failed stream", stats.RequestCount)
+	}
+}
+
+func TestStatsUnknownModel(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	if w := serve(s, http.MethodGet, "/api/stats/tinyllama", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w := serve(s, http.MethodGet, "/api/stats", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != `{"models":[]}` {
+		t.Errorf("GET /api/stats = %d %s, want no models", w.Code, w.Body.String())
+	}
+}
+
+func TestStatsPersist(t *testing.T) {
+	modelsPath := filepath.Join(t.TempDir(), "models")
+	configure := func(cfg *config.Config) {
+		cfg.ModelsPath = modelsPath
+	}
+
+	s := newTestServer(t, configure)
+	loadTestModel(t, s, "tinyllama")
+	if w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	saved := getModelStats(t, s, "tinyllama")
+	if err := s.SaveStats(); err != nil {
+		t.Fatalf("SaveStats: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(modelsPath), StatsFileName)); err != nil {
+		t.Fatalf("statistics not saved next to the models: %v", err)
+	}
+
+	// A restarted server keeps counting from the saved statistics
+	s = newTestServer(t, configure)
+	if got := getModelStats(t, s, "tinyllama"); !reflect.DeepEqual(got, saved) {
+		t.Errorf("stats after restart = %+v, want %+v", got, saved)
+	}
+	loadTestModel(t, s, "tinyllama")
+	serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+	if got := getModelStats(t, s, "tinyllama"); got.RequestCount != 2 {
+		t.Errorf("request count = %d, want 2", got.RequestCount)
+	}
+
+	// A corrupt file resets the statistics
+	if err := os.WriteFile(filepath.Join(filepath.Dir(modelsPath), StatsFileName), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s = newTestServer(t, configure)
+	if list := s.modelStatsList(); len(list) != 0 {
+		t.Errorf("stats = %+v, want none after a corrupt file", list)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"colossus-cli/internal/template"
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createTemplate handles POST /api/templates
+func (s *Server) createTemplate(c *gin.Context) {
+	var req types.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" || req.Template == "" {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: "Invalid request",
+		})
+		return
+	}
+
+	t, err := s.templates.Create(req.Name, req.Template)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("Created prompt template %s", t.Name)
+	c.JSON(http.StatusOK, t)
+}
+
+// listTemplates handles GET /api/templates
+func (s *Server) listTemplates(c *gin.Context) {
+	templates, err := s.templates.List()
+	if err != nil {
+		logger.Errorf("Failed to list prompt templates: %v", err)
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error: "Failed to list prompt templates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.PromptTemplatesResponse{Templates: templates})
+}
+
+// deleteTemplate handles DELETE /api/templates/:name
+func (s *Server) deleteTemplate(c *gin.Context) {
+	if err := s.templates.Delete(c.Param("name")); err != nil {
+		c.JSON(templateErrorStatus(err), types.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// renderTemplate replaces the prompt of a request naming a prompt template
+// with the rendered template
+func (s *Server) renderTemplate(req *types.GenerateRequest) error {
+	if req.Template == "" {
+		return nil
+	}
+	if req.Prompt != "" {
+		return fmt.Errorf("prompt and template are mutually exclusive")
+	}
+
+	prompt, err := s.templates.Render(req.Template, req.TemplateVars)
+	if err != nil {
+		return err
+	}
+	req.Prompt = prompt
+	return nil
+}
+
+// templateErrorStatus returns the HTTP status of a prompt template error
+func templateErrorStatus(err error) int {
+	if errors.Is(err, template.ErrTemplateNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWithTemplate(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	w := serve(s, http.MethodPost, "/api/templates", `{"name": "translate", "template": "Translate {{text}} to {{language}}"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "all variables",
+			body:       `{"model": "tinyllama", "template": "translate", "template_vars": {"text": "hola", "language": "English"}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing variable",
+			body:       `{"model": "tinyllama", "template": "translate", "template_vars": {"text": "hola"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "missing template variables: language",
+		},
+		{
+			name:       "unknown template",
+			body:       `{"model": "tinyllama", "template": "summarize"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "prompt and template",
+			body:       `{"model": "tinyllama", "prompt": "hola", "template": "translate"}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "mutually exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(s, http.MethodPost, "/api/generate", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantError != "" && !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want error %q", w.Body, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// errRequestTimeout is reported to clients whose request ran out of time
+const errRequestTimeout = "request timeout"
+
+// requestTimeout returns how long a request for a model may run, using the
+// model's configured override when there is one. Zero means no limit.
+func (s *Server) requestTimeout(modelName string) time.Duration {
+	modelConfig, err := s.modelManager.GetModelConfig(modelName)
+	if err != nil {
+		logger.Warnf("Using the default request timeout: %v", err)
+		return s.config.RequestTimeout
+	}
+	if modelConfig.RequestTimeout > 0 {
+		return modelConfig.RequestTimeout
+	}
+	return s.config.RequestTimeout
+}
+
+// withRequestTimeout derives the context an inference request runs with. It
+// is cancelled when ctx is, or once the request timeout for the model expires.
+func (s *Server) withRequestTimeout(ctx context.Context, modelName string) (context.Context, context.CancelFunc) {
+	timeout := s.requestTimeout(modelName)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// inferenceError returns the message reported to the client for a failed
+// inference request
+func inferenceError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errRequestTimeout
+	}
+	return err.Error()
+}
+
+// inferenceStatus returns the HTTP status for a failed inference request
+func inferenceStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
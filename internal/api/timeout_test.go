@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/config"
+	"colossus-cli/internal/types"
+)
+
+// newTimeoutServer creates a server whose engine generates until the request
+// times out
+func newTimeoutServer(t *testing.T, timeout time.Duration) (*Server, *slowEngine) {
+	t.Helper()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.RequestTimeout = timeout
+	})
+	engine := newSlowEngine(10 * time.Millisecond)
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+	return s, engine
+}
+
+func TestStreamTimeoutDeliversError(t *testing.T) {
+	s, engine := newTimeoutServer(t, 50*time.Millisecond)
+
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello", "stream": true}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// The generation streams until the timeout, then the error ends the stream
+	var chunks int
+	var last string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		chunks++
+		last = scanner.Text()
+	}
+	if chunks < 2 {
+		t.Errorf("got %d chunks, want the generation streamed before the timeout", chunks)
+	}
+
+	var final types.ErrorResponse
+	if err := json.Unmarshal([]byte(last), &final); err != nil {
+		t.Fatalf("final chunk %q: %v", last, err)
+	}
+	if final.Error != errRequestTimeout || !final.Done {
+		t.Errorf("final chunk = %s, want {\"error\":%q,\"done\":true}", last, errRequestTimeout)
+	}
+
+	if err := waitStopped(t, engine); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("generation stopped with %v, want %v", err, context.DeadlineExceeded)
+	}
+	waitIdle(t, s, "tinyllama")
+}
+
+func TestTimeoutStatus(t *testing.T) {
+	s, engine := newTimeoutServer(t, 50*time.Millisecond)
+
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	if !strings.Contains(w.Body.String(), errRequestTimeout) {
+		t.Errorf("body = %s, want the timeout error", w.Body)
+	}
+	if err := waitStopped(t, engine); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("generation stopped with %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestModelTimeoutOverride(t *testing.T) {
+	// The server default would let the request run for an hour
+	s, engine := newTimeoutServer(t, time.Hour)
+	configPath := filepath.Join(filepath.Dir(s.config.ModelsPath), "models.json")
+	if err := os.WriteFile(configPath, []byte(`{"tinyllama": {"request_timeout": "50ms"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello"}`, nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v, want the model's 50ms timeout", elapsed)
+	}
+	waitStopped(t, engine)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"colossus-cli/internal/types"
+)
+
+// toolCallResponse is the JSON object a model is asked to reply with when it
+// has tools: either the tools to call or a plain reply
+type toolCallResponse struct {
+	ToolCalls []toolCallRequest `json:"tool_calls,omitempty"`
+	Content   *string           `json:"content,omitempty"`
+}
+
+// toolCallRequest is a tool call in a toolCallResponse
+type toolCallRequest struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// applyTools prepares a chat request with tools for generation. The tools are
+// described in the system prompt and the response is constrained to JSON, so
+// that tool calls can be parsed from it. With the "none" tool choice the tools
+// are dropped instead.
+func applyTools(req *types.ChatRequest) error {
+	if len(req.Tools) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(req.Tools))
+	for _, tool := range req.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tools must have a name")
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("duplicate tool: %s", tool.Name)
+		}
+		names[tool.Name] = true
+	}
+
+	var instruction string
+	switch req.ToolChoice {
+	case "", "auto":
+		instruction = `To call tools, reply only with {"tool_calls": [{"name": <tool name>, "arguments": <arguments object>}]}. ` +
+			`Otherwise reply with {"content": <your reply>}.`
+	case "none":
+		req.Tools = nil
+		return nil
+	case "required":
+		instruction = `You must call at least one tool. Reply only with {"tool_calls": [{"name": <tool name>, "arguments": <arguments object>}]}.`
+	default:
+		if !names[req.ToolChoice] {
+			return fmt.Errorf("tool_choice refers to an unknown tool: %s", req.ToolChoice)
+		}
+		instruction = fmt.Sprintf(`You must call the %s tool. Reply only with {"tool_calls": [{"name": %q, "arguments": <arguments object>}]}.`,
+			req.ToolChoice, req.ToolChoice)
+	}
+
+	if len(req.JSONSchema) > 0 {
+		return fmt.Errorf("tools cannot be combined with a JSON schema")
+	}
+
+	tools, err := json.Marshal(req.Tools)
+	if err != nil {
+		return err
+	}
+	prompt := fmt.Sprintf("You have access to the following tools:\n%s\n%s", tools, instruction)
+
+	// Add the tools to the system prompt, or start the chat with them
+	messages := make([]types.Message, 0, len(req.Messages)+1)
+	if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+		system := req.Messages[0]
+		system.Content += "\n\n" + prompt
+		messages = append(messages, system)
+		messages = append(messages, req.Messages[1:]...)
+	} else {
+		messages = append(messages, types.Message{Role: "system", Content: prompt})
+		messages = append(messages, req.Messages...)
+	}
+	req.Messages = messages
+
+	options := types.Options{}
+	if req.Options != nil {
+		options = *req.Options
+	}
+	options.Format = "json"
+	options.Grammar = ""
+	req.Options = &options
+
+	return nil
+}
+
+// parseToolCalls parses the tool calls from the reply of a model given tools.
+// Replies that are not tool calls of known tools are returned as content.
+func parseToolCalls(tools []types.ToolDefinition, reply string) (string, []types.ToolCall) {
+	var parsed toolCallResponse
+	if err := json.Unmarshal([]byte(strings.TrimSpace(reply)), &parsed); err != nil {
+		return reply, nil
+	}
+
+	if len(parsed.ToolCalls) == 0 {
+		if parsed.Content != nil {
+			return *parsed.Content, nil
+		}
+		return reply, nil
+	}
+
+	calls := make([]types.ToolCall, 0, len(parsed.ToolCalls))
+	for _, call := range parsed.ToolCalls {
+		if !hasTool(tools, call.Name) {
+			return reply, nil
+		}
+		arguments := call.Arguments
+		if len(arguments) == 0 || string(arguments) == "null" {
+			arguments = json.RawMessage("{}")
+		}
+
+		// Some models encode the arguments as a string, as OpenAI does
+		var encoded string
+		if json.Unmarshal(arguments, &encoded) == nil && json.Valid([]byte(encoded)) {
+			arguments = json.RawMessage(encoded)
+		}
+		calls = append(calls, types.ToolCall{
+			ID:        newToolCallID(),
+			Name:      call.Name,
+			Arguments: arguments,
+		})
+	}
+	return "", calls
+}
+
+// hasTool reports whether a tool is defined
+func hasTool(tools []types.ToolDefinition, name string) bool {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatToolCalls formats tool calls made earlier in a chat the way the model
+// is asked to make them
+func formatToolCalls(calls []types.OpenAIToolCall) string {
+	var reply toolCallResponse
+	for _, call := range calls {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+		reply.ToolCalls = append(reply.ToolCalls, toolCallRequest{Name: call.Function.Name, Arguments: arguments})
+	}
+
+	data, _ := json.Marshal(reply)
+	return string(data)
+}
+
+// mapToolCalls converts tool calls to the OpenAI format. Streaming chunks
+// number the calls.
+func mapToolCalls(calls []types.ToolCall, streaming bool) []types.OpenAIToolCall {
+	result := make([]types.OpenAIToolCall, 0, len(calls))
+	for i, call := range calls {
+		mapped := types.OpenAIToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: types.OpenAIFunctionCall{
+				Name:      call.Name,
+				Arguments: string(call.Arguments),
+			},
+		}
+		if streaming {
+			index := i
+			mapped.Index = &index
+		}
+		result = append(result, mapped)
+	}
+	return result
+}
+
+// newToolCallID generates a unique identifier for a tool call
+func newToolCallID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "call_" + hex.EncodeToString(buf)
+}
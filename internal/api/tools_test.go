@@ -0,0 +1,358 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// replyEngine is a simulated engine whose chats reply with a fixed text,
+// streamed a few characters at a time. It records the last chat request.
+type replyEngine struct {
+	*inference.SimulatedEngine
+	reply   string
+	request *types.ChatRequest
+}
+
+// Chat replies with the fixed text
+func (e *replyEngine) Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error) {
+	e.request = req
+	return &types.ChatResponse{
+		Model:     req.Model,
+		CreatedAt: time.Now(),
+		Message:   types.Message{Role: "assistant", Content: e.reply},
+		Done:      true,
+	}, nil
+}
+
+// ChatStream streams the fixed text in chunks of up to 8 characters
+func (e *replyEngine) ChatStream(ctx context.Context, req *types.ChatRequest, callback func(*types.ChatResponse) error) error {
+	e.request = req
+	for rest := e.reply; rest != ""; {
+		chunk := rest[:min(8, len(rest))]
+		rest = rest[len(chunk):]
+		if err := callback(&types.ChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now(),
+			Message:   types.Message{Role: "assistant", Content: chunk},
+			Done:      rest == "",
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newToolServer returns a test server whose model replies with reply
+func newToolServer(t *testing.T, reply string) (*Server, *replyEngine) {
+	t.Helper()
+	s := newTestServer(t, nil)
+	engine := &replyEngine{SimulatedEngine: inference.NewSimulatedEngine(), reply: reply}
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+	return s, engine
+}
+
+var weatherTool = types.ToolDefinition{
+	Name:        "get_weather",
+	Description: "Get the current weather",
+	Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+}
+
+func TestApplyTools(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        types.ChatRequest
+		wantErr    bool
+		wantTools  bool
+		wantSystem string
+	}{
+		{
+			name:       "auto",
+			req:        types.ChatRequest{Messages: []types.Message{{Role: "user", Content: "weather?"}}, Tools: []types.ToolDefinition{weatherTool}},
+			wantTools:  true,
+			wantSystem: `Otherwise reply with {"content": <your reply>}.`,
+		},
+		{
+			name: "appended to the system prompt",
+			req: types.ChatRequest{
+				Messages: []types.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "weather?"}},
+				Tools:    []types.ToolDefinition{weatherTool},
+			},
+			wantTools:  true,
+			wantSystem: "Be brief.\n\nYou have access to the following tools:",
+		},
+		{
+			name:       "required",
+			req:        types.ChatRequest{Messages: []types.Message{{Role: "user", Content: "weather?"}}, Tools: []types.ToolDefinition{weatherTool}, ToolChoice: "required"},
+			wantTools:  true,
+			wantSystem: "You must call at least one tool.",
+		},
+		{
+			name:       "named",
+			req:        types.ChatRequest{Messages: []types.Message{{Role: "user", Content: "weather?"}}, Tools: []types.ToolDefinition{weatherTool}, ToolChoice: "get_weather"},
+			wantTools:  true,
+			wantSystem: "You must call the get_weather tool.",
+		},
+		{
+			name: "none",
+			req:  types.ChatRequest{Messages: []types.Message{{Role: "user", Content: "weather?"}}, Tools: []types.ToolDefinition{weatherTool}, ToolChoice: "none"},
+		},
+		{
+			name:    "unknown tool choice",
+			req:     types.ChatRequest{Tools: []types.ToolDefinition{weatherTool}, ToolChoice: "get_time"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate tool",
+			req:     types.ChatRequest{Tools: []types.ToolDefinition{weatherTool, weatherTool}},
+			wantErr: true,
+		},
+		{
+			name:    "unnamed tool",
+			req:     types.ChatRequest{Tools: []types.ToolDefinition{{Description: "nothing"}}},
+			wantErr: true,
+		},
+		{
+			name:    "JSON schema",
+			req:     types.ChatRequest{Tools: []types.ToolDefinition{weatherTool}, JSONSchema: json.RawMessage(`{"type":"object"}`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := applyTools(&req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !tt.wantTools {
+				if req.Tools != nil || !reflect.DeepEqual(req.Messages, tt.req.Messages) || req.Options != nil {
+					t.Errorf("request = %+v, want the tools dropped", req)
+				}
+				return
+			}
+
+			if len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+				t.Fatalf("messages = %+v, want a system prompt and the user message", req.Messages)
+			}
+			system := req.Messages[0].Content
+			if !strings.Contains(system, tt.wantSystem) || !strings.Contains(system, `"name":"get_weather"`) {
+				t.Errorf("system prompt = %q, want the tools and %q", system, tt.wantSystem)
+			}
+			if req.Options == nil || req.Options.Format != "json" {
+				t.Errorf("options = %+v, want JSON output", req.Options)
+			}
+		})
+	}
+}
+
+func TestParseToolCalls(t *testing.T) {
+	tools := []types.ToolDefinition{weatherTool}
+
+	tests := []struct {
+		name        string
+		reply       string
+		wantContent string
+		wantCalls   []types.ToolCall
+	}{
+		{
+			name:      "tool call",
+			reply:     ` {"tool_calls": [{"name": "get_weather", "arguments": {"city": "Paris"}}]} `,
+			wantCalls: []types.ToolCall{{Name: "get_weather", Arguments: json.RawMessage(`{"city": "Paris"}`)}},
+		},
+		{
+			name:      "string encoded arguments",
+			reply:     `{"tool_calls": [{"name": "get_weather", "arguments": "{\"city\":\"Oslo\"}"}]}`,
+			wantCalls: []types.ToolCall{{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Oslo"}`)}},
+		},
+		{
+			name:      "no arguments",
+			reply:     `{"tool_calls": [{"name": "get_weather", "arguments": null}]}`,
+			wantCalls: []types.ToolCall{{Name: "get_weather", Arguments: json.RawMessage(`{}`)}},
+		},
+		{
+			name:        "content",
+			reply:       `{"content": "It is sunny."}`,
+			wantContent: "It is sunny.",
+		},
+		{
+			name:        "unknown tool",
+			reply:       `{"tool_calls": [{"name": "get_time", "arguments": {}}]}`,
+			wantContent: `{"tool_calls": [{"name": "get_time", "arguments": {}}]}`,
+		},
+		{
+			name:        "plain text",
+			reply:       "It is sunny.",
+			wantContent: "It is sunny.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, calls := parseToolCalls(tools, tt.reply)
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("calls = %+v, want %+v", calls, tt.wantCalls)
+			}
+			for i, call := range calls {
+				want := tt.wantCalls[i]
+				if !strings.HasPrefix(call.ID, "call_") || call.Name != want.Name || string(call.Arguments) != string(want.Arguments) {
+					t.Errorf("call %d = %+v, want %+v", i, call, want)
+				}
+			}
+		})
+	}
+}
+
+func TestChatToolCalls(t *testing.T) {
+	s, engine := newToolServer(t, `{"tool_calls": [{"name": "get_weather", "arguments": {"city": "Paris"}}]}`)
+
+	request := `{"model": "tinyllama", "messages": [{"role": "user", "content": "Weather in Paris?"}], "stream": %s,
+		"tools": [{"name": "get_weather", "parameters": {"type": "object"}}]}`
+
+	w := serve(s, http.MethodPost, "/api/chat", strings.Replace(request, "%s", "false", 1), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Message.Content != "" || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" ||
+		string(resp.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Errorf("response = %+v, want a call to get_weather for Paris", resp)
+	}
+	if engine.request.Messages[0].Role != "system" || engine.request.Options.Format != "json" {
+		t.Errorf("engine request = %+v, want the tools in a system prompt and JSON output", engine.request)
+	}
+
+	// Streamed replies are sent once they can be parsed
+	w = serve(s, http.MethodPost, "/api/chat", strings.Replace(request, "%s", "true", 1), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("streamed %d responses, want 1: %s", len(lines), w.Body)
+	}
+	resp = types.ChatResponse{}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !resp.Done || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" {
+		t.Errorf("response = %+v, want a final call to get_weather", resp)
+	}
+
+	// Unknown tool choices are rejected
+	w = serve(s, http.MethodPost, "/api/chat", `{"model": "tinyllama", "messages": [{"role": "user", "content": "hi"}],
+		"tools": [{"name": "get_weather"}], "tool_choice": "get_time"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+	}
+}
+
+func TestChatCompletionsToolCalls(t *testing.T) {
+	s, engine := newToolServer(t, `{"tool_calls": [{"name": "get_weather", "arguments": {"city": "Paris"}}]}`)
+
+	request := `{"model": "tinyllama", "stream": %s,
+		"messages": [{"role": "user", "content": "Weather in Paris?"}],
+		"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+		"tool_choice": {"type": "function", "function": {"name": "get_weather"}}}`
+
+	w := serve(s, http.MethodPost, "/v1/chat/completions", strings.Replace(request, "%s", "false", 1), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.OpenAIChatCompletionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v, want a tool call", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.Type != "function" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city": "Paris"}` || call.Index != nil {
+		t.Errorf("tool call = %+v, want an unindexed call to get_weather for Paris", call)
+	}
+	if system := engine.request.Messages[0].Content; !strings.Contains(system, "You must call the get_weather tool.") {
+		t.Errorf("system prompt = %q, want get_weather required", system)
+	}
+
+	w = serve(s, http.MethodPost, "/v1/chat/completions", strings.Replace(request, "%s", "true", 1), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var chunks []types.OpenAIChatCompletionChunk
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk types.OpenAIChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %s: %v", data, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 {
+		t.Fatalf("streamed %d chunks, want 1", len(chunks))
+	}
+	delta := chunks[0].Choices[0].Delta
+	if finish := chunks[0].Choices[0].FinishReason; finish == nil || *finish != "tool_calls" {
+		t.Errorf("finish_reason = %v, want tool_calls", finish)
+	}
+	if delta.Role != "assistant" || len(delta.ToolCalls) != 1 || delta.ToolCalls[0].Index == nil || *delta.ToolCalls[0].Index != 0 {
+		t.Errorf("delta = %+v, want the indexed tool call", delta)
+	}
+}
+
+func TestChatCompletionsToolResults(t *testing.T) {
+	s, engine := newToolServer(t, `{"content": "It is sunny in Paris."}`)
+
+	w := serve(s, http.MethodPost, "/v1/chat/completions", `{"model": "tinyllama",
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": "", "tool_calls": [{"id": "call_1", "type": "function",
+				"function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}
+		],
+		"tools": [{"type": "function", "function": {"name": "get_weather"}}]}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp types.OpenAIChatCompletionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if choice := resp.Choices[0]; choice.FinishReason != "stop" || choice.Message.Content != "It is sunny in Paris." {
+		t.Errorf("choice = %+v, want the content of the reply", choice)
+	}
+
+	// The earlier call is shown to the model the way it is asked to make calls
+	messages := engine.request.Messages
+	want := []types.Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", Content: `{"tool_calls":[{"name":"get_weather","arguments":{"city":"Paris"}}]}`},
+		{Role: "tool", Content: "sunny"},
+	}
+	if len(messages) != 4 || !reflect.DeepEqual(messages[1:], want) {
+		t.Errorf("messages = %+v, want the system prompt and %+v", messages, want)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a tracer provider recording the spans of all tests.
+// The package tracer binds to the first provider installed, so it is shared.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+// tracedSpans returns the ended spans of a trace by name
+func tracedSpans(recorder *tracetest.SpanRecorder, traceID trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+// spanAttribute returns the value of a span attribute
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingContinuesCallerTrace(t *testing.T) {
+	recorder := recordSpans()
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := http.Header{"Traceparent": {traceparent}}
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "tinyllama", "prompt": "hello", "stream": false}`, header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if got := w.Header().Get(traceIDHeader); got != traceID.String() {
+		t.Errorf("%s = %q, want the caller's trace %s", traceIDHeader, got, traceID)
+	}
+
+	spans := tracedSpans(recorder, traceID)
+	server, ok := spans["POST /api/generate"]
+	if !ok {
+		t.Fatalf("no request span in %v", spans)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("request span parent = %s, want the caller's span", got)
+	}
+	if server.SpanKind() != trace.SpanKindServer || spanAttribute(server, "http.route").AsString() != "/api/generate" ||
+		spanAttribute(server, "http.status_code").AsInt64() != http.StatusOK {
+		t.Errorf("request span = %s %v, want a server span for the route with status 200", server.SpanKind(), server.Attributes())
+	}
+
+	load, ok := spans["ensureModelLoaded"]
+	if !ok {
+		t.Fatalf("no model loading span in %v", spans)
+	}
+	if load.Parent().SpanID() != server.SpanContext().SpanID() || spanAttribute(load, "model").AsString() != "tinyllama" {
+		t.Errorf("model loading span = %v under %s, want a child of the request span", load.Attributes(), load.Parent().SpanID())
+	}
+}
+
+func TestTracingRecordsErrors(t *testing.T) {
+	recorder := recordSpans()
+	s := newTestServer(t, nil)
+
+	w := serve(s, http.MethodPost, "/api/generate", `{"model": "missing", "prompt": "hello", "stream": false}`, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+	}
+
+	// Without trace context a new trace is started
+	traceID, err := trace.TraceIDFromHex(w.Header().Get(traceIDHeader))
+	if err != nil {
+		t.Fatalf("invalid %s header: %v", traceIDHeader, err)
+	}
+	spans := tracedSpans(recorder, traceID)
+	if server := spans["POST /api/generate"]; server == nil || server.Parent().IsValid() {
+		t.Errorf("request span = %v, want a root span", server)
+	}
+	load := spans["ensureModelLoaded"]
+	if load == nil || load.Status().Code != codes.Error || len(load.Events()) == 0 {
+		t.Errorf("model loading span = %v, want the error recorded", load)
+	}
+
+	w = serve(s, http.MethodGet, "/no/such/route", "", nil)
+	traceID, _ = trace.TraceIDFromHex(w.Header().Get(traceIDHeader))
+	if _, ok := tracedSpans(recorder, traceID)["GET unmatched"]; !ok {
+		t.Errorf("unmatched route not traced as GET unmatched")
+	}
+}
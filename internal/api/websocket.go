@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// wsWriteTimeout bounds how long a single WebSocket write may block
+const wsWriteTimeout = 10 * time.Second
+
+var wsUpgrader = websocket.Upgrader{
+	// Cross-origin requests are already allowed for every HTTP route
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsGenerate handles GET /ws/generate. Each text message received is a
+// GenerateRequest; the responses are streamed back as one GenerateResponse
+// frame per chunk. Several requests can be sent over the same connection,
+// and are processed one at a time.
+func (s *Server) wsGenerate(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		logger.Debugf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Cancelling ctx stops any generation in progress once the client is gone
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	pingInterval := s.config.WSPingInterval
+	if pingInterval > 0 {
+		// A client that misses a ping's pong for a whole interval is gone
+		readTimeout := 2 * pingInterval
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(readTimeout))
+		})
+	}
+
+	// The reader goroutine owns all reads, so disconnects are noticed even
+	// while a response is being generated
+	requests := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer cancel()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType != websocket.TextMessage {
+				continue
+			}
+			select {
+			case requests <- data:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Pings run in their own goroutine so they continue during long generations
+	if pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					// WriteControl may be called concurrently with other writes
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case data := <-requests:
+			if err := s.wsHandleRequest(ctx, conn, data); err != nil {
+				logger.Debugf("WebSocket closed: %v", err)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wsHandleRequest runs one generate request received over a WebSocket. It
+// only returns an error when the connection can no longer be used.
+func (s *Server) wsHandleRequest(ctx context.Context, conn *websocket.Conn, data []byte) error {
+	var req types.GenerateRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: "Invalid request"})
+	}
+
+	if err := validateJSONSchema(req.JSONSchema); err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: err.Error()})
+	}
+	if err := s.renderTemplate(&req); err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: err.Error()})
+	}
+
+	release, err := s.acquireModel(ctx, req.Model)
+	if err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: err.Error()})
+	}
+	defer release()
+
+	if err := s.ensureModelLoaded(ctx, req.Model); err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: err.Error()})
+	}
+
+	ctx, cancel := s.withRequestTimeout(ctx, req.Model)
+	defer cancel()
+
+	start := time.Now()
+	var text strings.Builder
+
+	err = s.engine.GenerateStream(ctx, &req, func(resp *types.GenerateResponse) error {
+		text.WriteString(resp.Response)
+		return wsWriteJSON(conn, resp)
+	})
+	s.recordGeneration(ctx, req.Model, req.Prompt, text.String(), time.Since(start))
+	s.recordGenerationResult(req.Model, err)
+
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	if err != nil {
+		return wsWriteJSON(conn, types.ErrorResponse{Error: inferenceError(err), Done: true})
+	}
+	return nil
+}
+
+// wsWriteJSON writes one JSON frame
+func wsWriteJSON(conn *websocket.Conn, payload interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(payload)
+}
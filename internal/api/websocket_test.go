@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialGenerate connects to the WebSocket generate endpoint of srv
+func dialGenerate(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/generate", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return conn
+}
+
+func TestWebSocketGenerateOrder(t *testing.T) {
+	s := newTestServer(t, nil)
+	loadTestModel(t, s, "tinyllama")
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+
+	conn := dialGenerate(t, srv)
+	defer conn.Close()
+
+	// Requests sent back to back are answered one after another
+	prompts := []string{"hello", "tell me about llamas"}
+	for _, prompt := range prompts {
+		if err := conn.WriteJSON(types.GenerateRequest{Model: "tinyllama", Prompt: prompt}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	for _, prompt := range prompts {
+		want, err := s.engine.Generate(context.Background(), &types.GenerateRequest{Model: "tinyllama", Prompt: prompt})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var text strings.Builder
+		for frames := 0; ; frames++ {
+			var resp types.GenerateResponse
+			if err := conn.ReadJSON(&resp); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			text.WriteString(resp.Response)
+			if resp.Done {
+				if frames == 0 {
+					t.Errorf("response to %q sent in one frame, want it streamed", prompt)
+				}
+				break
+			}
+		}
+		if text.String() != want.Response {
+			t.Errorf("streamed %q for %q, want %q", text.String(), prompt, want.Response)
+		}
+	}
+}
+
+func TestWebSocketDisconnectCancelsGeneration(t *testing.T) {
+	s := newTestServer(t, nil)
+	engine := newSlowEngine(5 * time.Millisecond)
+	s.engine = engine
+	loadTestModel(t, s, "tinyllama")
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+
+	conn := dialGenerate(t, srv)
+	if err := conn.WriteJSON(types.GenerateRequest{Model: "tinyllama", Prompt: "hello"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var resp types.GenerateResponse
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// Closing the connection mid-generation stops the generation
+	conn.Close()
+	if err := waitStopped(t, engine); !errors.Is(err, context.Canceled) {
+		t.Errorf("generation stopped with %v, want context.Canceled", err)
+	}
+}
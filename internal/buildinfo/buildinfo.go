@@ -0,0 +1,57 @@
+// Package buildinfo describes the build of the binary: its version, the
+// commit and time it was built from, and the capabilities selected by build
+// tags
+package buildinfo
+
+import "runtime/debug"
+
+// Set at build time with -ldflags "-X colossus-cli/internal/buildinfo.version=..."
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// Version returns the version the binary was built as, the module version
+// when it was not set at build time, or "dev"
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// Commit returns the commit SHA the binary was built from, or an empty
+// string when it is unknown
+func Commit() string {
+	if commit != "" {
+		return commit
+	}
+	return vcsSetting("vcs.revision")
+}
+
+// BuildTime returns when the binary was built, or an empty string when it is
+// unknown. Without a time set at build time, the time of the commit is used.
+func BuildTime() string {
+	if buildTime != "" {
+		return buildTime
+	}
+	return vcsSetting("vcs.time")
+}
+
+// vcsSetting returns a version control setting recorded by the Go toolchain
+func vcsSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
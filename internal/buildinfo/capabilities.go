@@ -0,0 +1,33 @@
+package buildinfo
+
+// Capabilities compiled into the binary, set by the files built with the
+// matching build tags
+var (
+	// LlamaCpp reports real inference with the llama.cpp bindings, built
+	// with cgo and the llamacpp_cgo tag
+	LlamaCpp bool
+
+	// CUDA, ROCm and SYCL report GPU offloading to NVIDIA, AMD and Intel
+	// GPUs, built with the cuda, rocm and sycl tags
+	CUDA bool
+	ROCm bool
+	SYCL bool
+
+	// Metal reports GPU offloading on Apple silicon, which the llama.cpp
+	// bindings link on macOS
+	Metal bool
+)
+
+// Capabilities returns the capabilities by name, e.g. "cuda"
+func Capabilities() map[string]bool {
+	return map[string]bool{
+		"llamacpp": LlamaCpp,
+		"cuda":     CUDA,
+		"rocm":     ROCm,
+		"sycl":     SYCL,
+		"metal":    Metal,
+	}
+}
+
+// CapabilityNames lists the capabilities in the order they are reported
+var CapabilityNames = []string{"llamacpp", "cuda", "rocm", "sycl", "metal"}
@@ -0,0 +1,7 @@
+//go:build cuda
+
+package buildinfo
+
+func init() {
+	CUDA = true
+}
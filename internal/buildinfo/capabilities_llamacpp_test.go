@@ -0,0 +1,7 @@
+//go:build cgo && llamacpp_cgo
+
+package buildinfo
+
+func init() {
+	wantCapabilities["llamacpp"] = true
+}
@@ -0,0 +1,7 @@
+//go:build darwin && cgo && llamacpp_cgo
+
+package buildinfo
+
+func init() {
+	wantCapabilities["metal"] = true
+}
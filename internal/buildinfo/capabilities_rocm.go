@@ -0,0 +1,7 @@
+//go:build rocm
+
+package buildinfo
+
+func init() {
+	ROCm = true
+}
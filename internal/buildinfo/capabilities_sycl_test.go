@@ -0,0 +1,7 @@
+//go:build sycl
+
+package buildinfo
+
+func init() {
+	wantCapabilities["sycl"] = true
+}
@@ -0,0 +1,49 @@
+package buildinfo
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// wantCapabilities are the capabilities selected by the build tags the tests
+// run with. The test files built with each tag set theirs, so that
+// "go test -tags cuda" expects CUDA and nothing else.
+var wantCapabilities = map[string]bool{
+	"llamacpp": false,
+	"cuda":     false,
+	"rocm":     false,
+	"sycl":     false,
+	"metal":    false,
+}
+
+func TestCapabilities(t *testing.T) {
+	if got := Capabilities(); !reflect.DeepEqual(got, wantCapabilities) {
+		t.Errorf("Capabilities() = %v, want %v", got, wantCapabilities)
+	}
+
+	names := append([]string(nil), CapabilityNames...)
+	sort.Strings(names)
+	var keys []string
+	for name := range Capabilities() {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(names, keys) {
+		t.Errorf("CapabilityNames = %v, want the capabilities %v", CapabilityNames, keys)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	saved := version
+	defer func() { version = saved }()
+
+	version = ""
+	if got := Version(); got == "" {
+		t.Error("Version() is empty without a version set at build time")
+	}
+	version = "v1.2.3"
+	if got := Version(); got != "v1.2.3" {
+		t.Errorf("Version() = %q, want the version set at build time", got)
+	}
+}
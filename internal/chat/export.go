@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// Conversation export formats
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// TimestampedMessage is a chat message with the time it was sent or
+// received, and the model that replied for assistant messages
+type TimestampedMessage struct {
+	types.Message
+	Timestamp time.Time `json:"timestamp"`
+	Model     string    `json:"model,omitempty"`
+}
+
+// ValidateExportFormat checks that a conversation can be exported in format
+func ValidateExportFormat(format string) error {
+	switch format {
+	case FormatMarkdown, FormatJSON, FormatHTML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported export format %q (expected %s, %s or %s)", format, FormatMarkdown, FormatJSON, FormatHTML)
+	}
+}
+
+// ExportConversation writes a conversation to path in format: a markdown
+// log, a JSON array of messages or a standalone HTML page
+func ExportConversation(messages []TimestampedMessage, format string, path string) error {
+	if err := ValidateExportFormat(format); err != nil {
+		return err
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case FormatMarkdown:
+		data = []byte(formatMarkdown(messages))
+	case FormatJSON:
+		if messages == nil {
+			messages = []TimestampedMessage{}
+		}
+		data, err = json.MarshalIndent(messages, "", "  ")
+		data = append(data, '\n')
+	case FormatHTML:
+		data, err = formatHTML(messages)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to format conversation: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write conversation: %w", err)
+	}
+	return nil
+}
+
+// conversationModel returns the model that replied in a conversation, taken
+// from its first assistant message
+func conversationModel(messages []TimestampedMessage) string {
+	for _, msg := range messages {
+		if msg.Model != "" {
+			return msg.Model
+		}
+	}
+	return ""
+}
+
+// roleTitle returns a message role as a heading, e.g. "User" for "user"
+func roleTitle(role string) string {
+	if role == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// formatTimestamp formats the time of a message, empty when it is unknown,
+// e.g. for messages loaded from a history file
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// formatMarkdown formats a conversation as a readable markdown log
+func formatMarkdown(messages []TimestampedMessage) string {
+	var b strings.Builder
+	b.WriteString("# Conversation\n\n")
+	if model := conversationModel(messages); model != "" {
+		fmt.Fprintf(&b, "Model: `%s`\n\n", model)
+	}
+
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "**%s:**", roleTitle(msg.Role))
+		if ts := formatTimestamp(msg.Timestamp); ts != "" {
+			fmt.Fprintf(&b, " _%s_", ts)
+		}
+		fmt.Fprintf(&b, "\n\n%s\n\n", strings.TrimSpace(msg.Content))
+	}
+	return b.String()
+}
+
+// htmlTemplate renders a conversation as a standalone page. Message contents
+// are escaped and shown with their line breaks.
+var htmlTemplate = template.Must(template.New("conversation").Funcs(template.FuncMap{
+	"role":      roleTitle,
+	"timestamp": formatTimestamp,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Conversation{{if .Model}} with {{.Model}}{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; }
+.message { margin: 1rem 0; padding: 0.75rem 1rem; border-radius: 0.5rem; background: #f4f4f4; }
+.user { background: #e3efff; }
+.system { background: #fff6dd; }
+.role { font-weight: bold; }
+.time { color: #777; font-size: 0.85em; margin-left: 0.5em; }
+.content { white-space: pre-wrap; margin-top: 0.5rem; }
+</style>
+</head>
+<body>
+<h1>Conversation</h1>
+{{if .Model}}<p>Model: <code>{{.Model}}</code></p>
+{{end}}{{range .Messages}}<div class="message {{.Role}}">
+<span class="role">{{role .Role}}</span>{{with timestamp .Timestamp}}<span class="time">{{.}}</span>{{end}}
+<div class="content">{{.Content}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// formatHTML formats a conversation as a standalone HTML page
+func formatHTML(messages []TimestampedMessage) ([]byte, error) {
+	var b strings.Builder
+	err := htmlTemplate.Execute(&b, struct {
+		Model    string
+		Messages []TimestampedMessage
+	}{conversationModel(messages), messages})
+	return []byte(b.String()), err
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// testConversation is a conversation whose first message, like those loaded
+// from a history file, has no timestamp
+func testConversation() []TimestampedMessage {
+	sent := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	return []TimestampedMessage{
+		{Message: types.Message{Role: "system", Content: "Be brief."}},
+		{Message: types.Message{Role: "user", Content: "Is 1 < 2?"}, Timestamp: sent},
+		{Message: types.Message{Role: "assistant", Content: "Yes.\n<b>Always.</b>\n"}, Timestamp: sent.Add(2 * time.Second), Model: "llama3"},
+	}
+}
+
+// exportConversation exports messages to a file in format and returns its
+// contents
+func exportConversation(t *testing.T, messages []TimestampedMessage, format string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "conversation")
+	if err := ExportConversation(messages, format, path); err != nil {
+		t.Fatalf("ExportConversation(%s): %v", format, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExportMarkdown(t *testing.T) {
+	got := exportConversation(t, testConversation(), FormatMarkdown)
+	want := "# Conversation\n\n" +
+		"Model: `llama3`\n\n" +
+		"**System:**\n\nBe brief.\n\n" +
+		"**User:** _2024-05-01 09:30:00_\n\nIs 1 < 2?\n\n" +
+		"**Assistant:** _2024-05-01 09:30:02_\n\nYes.\n<b>Always.</b>\n\n"
+	if got != want {
+		t.Errorf("markdown =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	messages := testConversation()
+	var got []TimestampedMessage
+	if err := json.Unmarshal([]byte(exportConversation(t, messages, FormatJSON)), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("exported %+v, want %+v", got, messages)
+	}
+
+	if got := exportConversation(t, nil, FormatJSON); got != "[]\n" {
+		t.Errorf("empty conversation = %q, want an empty array", got)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	got := exportConversation(t, testConversation(), FormatHTML)
+
+	for _, want := range []string{
+		"<title>Conversation with llama3</title>",
+		`<div class="message user">`,
+		`<span class="role">Assistant</span><span class="time">2024-05-01 09:30:02</span>`,
+		"Is 1 &lt; 2?",
+		"&lt;b&gt;Always.&lt;/b&gt;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<b>") {
+		t.Error("HTML contains unescaped message content")
+	}
+}
+
+func TestExportConversationRejectsUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversation.pdf")
+	if err := ExportConversation(testConversation(), "pdf", path); err == nil {
+		t.Fatal("ExportConversation(pdf) succeeded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file written for an unknown format: %v", err)
+	}
+}
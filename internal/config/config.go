@@ -1,8 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"colossus-cli/internal/gpu"
 
 	"github.com/spf13/viper"
 )
@@ -13,22 +18,136 @@ type Config struct {
 	Port       int    `mapstructure:"port"`
 	ModelsPath string `mapstructure:"models_path"`
 	Verbose    bool   `mapstructure:"verbose"`
+	Metrics    bool   `mapstructure:"metrics"`
+
+	// Serve Ollama's model management API in place of Colossus's own, so
+	// that the ollama CLI can use the server
+	OllamaCompat bool `mapstructure:"ollama_compat"`
+
+	// Serve the OpenAPI specification at /api/openapi.json and Swagger UI at
+	// /api/docs
+	APIDocs bool `mapstructure:"api_docs"`
+
+	// Format of the log output, "text" or "json"
+	LogFormat string `mapstructure:"log_format"`
+
+	// Address of a separate listener serving pprof profiles, disabled when empty
+	PprofAddr string `mapstructure:"pprof_addr"`
+
+	// Comma-separated models loaded when the server starts
+	Preload string `mapstructure:"preload"`
+
+	// Skip the warm-up run of models after they load
+	NoWarmup bool `mapstructure:"no_warmup"`
+
+	// How long the server may take to start, including preloading, before it
+	// exits; /ready also reports ready once it has passed. 0 waits forever.
+	StartupTimeout time.Duration `mapstructure:"startup_timeout"`
+
+	// Models that receive no requests for this long are unloaded, 0 to keep them loaded
+	IdleUnload time.Duration `mapstructure:"idle_unload"`
+
+	// Comma-separated proportions of a model placed on each GPU, overriding the
+	// split computed from their free memory
+	GPUSplit string `mapstructure:"gpu_split"`
+
+	// Requests that may wait for each model while it is busy; more are rejected
+	QueueDepth int `mapstructure:"queue_depth"`
+
+	// Consecutive inference errors after which requests to a model fail at
+	// once, 0 to disable, and how long until a trial request is let through
+	CircuitThreshold       int           `mapstructure:"circuit_threshold"`
+	CircuitRecoveryTimeout time.Duration `mapstructure:"circuit_recovery_timeout"`
+
+	// Most prompts accepted by a single batch generate request, 0 for no limit
+	MaxBatchPrompts int `mapstructure:"max_batch_prompts"`
+
+	// Longest time an inference request may run, 0 for no limit. Models can
+	// override it in the model config file.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+
+	// Generations taking longer than this are logged as slow queries, 0 to disable
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+
+	// Interval between pings on WebSocket connections, 0 to disable
+	WSPingInterval time.Duration `mapstructure:"ws_ping_interval"`
+
+	// KV cache sessions persisted between generate calls
+	SessionsPath       string        `mapstructure:"sessions_path"`
+	SessionIdleTimeout time.Duration `mapstructure:"session_idle_timeout"`
+
+	// System prompt evaluated once for every loaded model, and the directory
+	// its KV cache snapshots are saved in
+	PrefillPrompt string `mapstructure:"prefill_prompt"`
+	PrefillPath   string `mapstructure:"prefill_path"`
+
+	// Responses cached for identical generate requests, disabled when
+	// CacheTTL is 0
+	CacheTTL  time.Duration `mapstructure:"cache_ttl"`
+	CacheSize int           `mapstructure:"cache_size"`
+
+	// gzip level of API responses, 1 to 9, or 0 to disable compression
+	CompressionLevel int `mapstructure:"compression_level"`
+
+	// Notify systemd of readiness and send watchdog keepalives. Also enabled
+	// when systemd sets NOTIFY_SOCKET.
+	Systemd bool `mapstructure:"systemd"`
+
+	// Port of the Model Context Protocol server, on the same host as the
+	// API, or 0 to not serve MCP
+	MCPPort int `mapstructure:"mcp_port"`
+
+	// Port of the gRPC API, on the same host as the HTTP API, or 0 to not
+	// serve gRPC
+	GRPCPort int `mapstructure:"grpc_port"`
+
+	// API keys accepted by the server, as a comma-separated list and/or a
+	// file with one key per line. Authentication is disabled when neither is set.
+	APIKey      string `mapstructure:"api_key"`
+	APIKeysFile string `mapstructure:"api_keys_file"`
+
+	// Per-client rate limiting of API routes, disabled when RateLimit is 0
+	RateLimit                float64       `mapstructure:"rate_limit"`
+	RateLimitBurst           int           `mapstructure:"rate_limit_burst"`
+	RateLimitCleanupInterval time.Duration `mapstructure:"rate_limit_cleanup_interval"`
+
+	// OTLP/HTTP collector receiving traces of requests, tracing is disabled when empty
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
+
+	// Where models are listed, pulled into and removed from: "local" for the
+	// models directory or "s3" for the S3 bucket below
+	ModelsBackend string `mapstructure:"models_backend"`
+	S3Bucket      string `mapstructure:"s3_bucket"`
+	S3Prefix      string `mapstructure:"s3_prefix"`
+	S3Region      string `mapstructure:"s3_region"`
+	S3Endpoint    string `mapstructure:"s3_endpoint"`
+
+	// Proxy of Hugging Face requests and model downloads, and the hosts, as a
+	// comma-separated list, reached without it. When Proxy is empty, the
+	// HTTP_PROXY and HTTPS_PROXY environment variables apply.
+	Proxy   string `mapstructure:"proxy"`
+	NoProxy string `mapstructure:"no_proxy"`
 }
 
-// Load loads the configuration from various sources
+// Load loads the configuration from various sources and creates the models
+// directory
 func Load() *Config {
-	// Set defaults
-	viper.SetDefault("host", "127.0.0.1")
-	viper.SetDefault("port", 11434)
-	viper.SetDefault("verbose", false)
+	cfg := Read()
 	
-	// Set default models path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		homeDir = "."
+	// Ensure models directory exists
+	if err := os.MkdirAll(cfg.ModelsPath, 0755); err != nil {
+		// If we can't create the directory, use current directory
+		cfg.ModelsPath = "./models"
+		os.MkdirAll(cfg.ModelsPath, 0755)
 	}
-	defaultModelsPath := filepath.Join(homeDir, ".colossus", "models")
-	viper.SetDefault("models_path", defaultModelsPath)
+	
+	return cfg
+}
+
+// Read returns the configuration from the defaults, config file, environment
+// and bound flags, without touching the filesystem
+func Read() *Config {
+	SetDefaults()
 	
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
@@ -38,15 +157,158 @@ func Load() *Config {
 			Port:       viper.GetInt("port"),
 			ModelsPath: viper.GetString("models_path"),
 			Verbose:    viper.GetBool("verbose"),
+			Metrics:    viper.GetBool("metrics"),
+			LogFormat:  viper.GetString("log_format"),
+			PprofAddr:  viper.GetString("pprof_addr"),
+			Preload:    viper.GetString("preload"),
+			NoWarmup:   viper.GetBool("no_warmup"),
+			IdleUnload: viper.GetDuration("idle_unload"),
+			QueueDepth: viper.GetInt("queue_depth"),
+			GPUSplit:   viper.GetString("gpu_split"),
+
+			StartupTimeout: viper.GetDuration("startup_timeout"),
+
+			CircuitThreshold:       viper.GetInt("circuit_threshold"),
+			CircuitRecoveryTimeout: viper.GetDuration("circuit_recovery_timeout"),
+
+			MaxBatchPrompts: viper.GetInt("max_batch_prompts"),
+
+			RequestTimeout: viper.GetDuration("request_timeout"),
+
+			SlowQueryThreshold: viper.GetDuration("slow_query_threshold"),
+			WSPingInterval: viper.GetDuration("ws_ping_interval"),
+
+			SessionsPath:       viper.GetString("sessions_path"),
+			SessionIdleTimeout: viper.GetDuration("session_idle_timeout"),
+
+			PrefillPrompt: viper.GetString("prefill_prompt"),
+			PrefillPath:   viper.GetString("prefill_path"),
+
+			CacheTTL:  viper.GetDuration("cache_ttl"),
+			CacheSize: viper.GetInt("cache_size"),
+
+			CompressionLevel: viper.GetInt("compression_level"),
+
+			Systemd: viper.GetBool("systemd"),
+			MCPPort: viper.GetInt("mcp_port"),
+
+			GRPCPort: viper.GetInt("grpc_port"),
+
+			APIKey:      viper.GetString("api_key"),
+			APIKeysFile: viper.GetString("api_keys_file"),
+
+			RateLimit:                viper.GetFloat64("rate_limit"),
+			RateLimitBurst:           viper.GetInt("rate_limit_burst"),
+			RateLimitCleanupInterval: viper.GetDuration("rate_limit_cleanup_interval"),
+
+			OTLPEndpoint: viper.GetString("otlp_endpoint"),
+
+			ModelsBackend: viper.GetString("models_backend"),
+			S3Bucket:      viper.GetString("s3_bucket"),
+			S3Prefix:      viper.GetString("s3_prefix"),
+			S3Region:      viper.GetString("s3_region"),
+			S3Endpoint:    viper.GetString("s3_endpoint"),
+
+			Proxy:   viper.GetString("proxy"),
+			NoProxy: viper.GetString("no_proxy"),
+
+			OllamaCompat: viper.GetBool("ollama_compat"),
+			APIDocs:      viper.GetBool("api_docs"),
 		}
 	}
 	
-	// Ensure models directory exists
-	if err := os.MkdirAll(cfg.ModelsPath, 0755); err != nil {
-		// If we can't create the directory, use current directory
-		cfg.ModelsPath = "./models"
-		os.MkdirAll(cfg.ModelsPath, 0755)
-	}
-	
 	return &cfg
 }
+
+// SetDefaults registers the default configuration values with viper
+func SetDefaults() {
+	viper.SetDefault("host", "127.0.0.1")
+	viper.SetDefault("port", 11434)
+	viper.SetDefault("verbose", false)
+	viper.SetDefault("metrics", true)
+	viper.SetDefault("ollama_compat", false)
+	viper.SetDefault("api_docs", false)
+	viper.SetDefault("models_backend", "local")
+	viper.SetDefault("log_format", "text")
+	
+	// Set default models path
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "."
+	}
+	defaultModelsPath := filepath.Join(homeDir, ".colossus", "models")
+	viper.SetDefault("models_path", defaultModelsPath)
+	viper.SetDefault("sessions_path", filepath.Join(homeDir, ".colossus", "sessions"))
+	viper.SetDefault("session_idle_timeout", 30*time.Minute)
+	viper.SetDefault("prefill_path", filepath.Join(homeDir, ".colossus", "prefill"))
+	viper.SetDefault("cache_size", 1000)
+	viper.SetDefault("rate_limit_cleanup_interval", 5*time.Minute)
+	viper.SetDefault("ws_ping_interval", 30*time.Second)
+	viper.SetDefault("request_timeout", 5*time.Minute)
+	viper.SetDefault("slow_query_threshold", 30*time.Second)
+	viper.SetDefault("queue_depth", 10)
+	viper.SetDefault("circuit_threshold", 5)
+	viper.SetDefault("circuit_recovery_timeout", 60*time.Second)
+	viper.SetDefault("max_batch_prompts", 64)
+}
+
+// PreloadModels returns the names of the models to load at startup
+func (c *Config) PreloadModels() []string {
+	var models []string
+	for _, name := range strings.Split(c.Preload, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			models = append(models, name)
+		}
+	}
+	return models
+}
+
+// NoProxyHosts returns the hosts reached without the proxy
+func (c *Config) NoProxyHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.NoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// TensorSplit returns the GPU split set in the configuration, or nil when the
+// split is computed automatically
+func (c *Config) TensorSplit() ([]float32, error) {
+	if strings.TrimSpace(c.GPUSplit) == "" {
+		return nil, nil
+	}
+	return gpu.ParseTensorSplit(c.GPUSplit)
+}
+
+// APIKeys returns the API keys accepted by the server. Blank lines and lines
+// starting with # in the keys file are ignored.
+func (c *Config) APIKeys() ([]string, error) {
+	var keys []string
+	for _, key := range strings.Split(c.APIKey, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if c.APIKeysFile != "" {
+		data, err := os.ReadFile(c.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			keys = append(keys, line)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("API keys file %s contains no keys", c.APIKeysFile)
+		}
+	}
+
+	return keys, nil
+}
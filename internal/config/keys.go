@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Value sources reported by Source
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Keys returns the configuration keys, in the order of the Config fields
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ParseValue converts a value given as text to the type of a key's field
+func ParseValue(key, value string) (interface{}, error) {
+	field, ok := keyField(key)
+	if !ok {
+		return nil, fmt.Errorf("unknown config key: %s", key)
+	}
+
+	if field.Type == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %q", key, value)
+		}
+		// Durations are stored in their text form, e.g. "5m0s"
+		return d.String(), nil
+	}
+
+	switch field.Type.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean for %s: %q", key, value)
+		}
+		return b, nil
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer for %s: %q", key, value)
+		}
+		return n, nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number for %s: %q", key, value)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("config key %s cannot be set", key)
+	}
+}
+
+// Source reports where the current value of a key comes from. Environment
+// variables take precedence over the config file.
+func Source(key string) string {
+	if env := os.Getenv(envName(key)); env != "" {
+		return SourceEnv
+	}
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// Validate checks the config file in use against the schema and that the
+// configuration values are in range. The values of a config file that does
+// not match the schema are not checked further.
+func (c *Config) Validate() error {
+	if err := CheckFile(); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	if c.Host == "" {
+		errs = append(errs, errors.New("host must not be empty"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format must be text or json, got %q", c.LogFormat))
+	}
+	if c.PprofAddr != "" {
+		if _, _, err := net.SplitHostPort(c.PprofAddr); err != nil {
+			errs = append(errs, fmt.Errorf("pprof_addr must be HOST:PORT, got %q", c.PprofAddr))
+		}
+	}
+	switch c.ModelsBackend {
+	case "local":
+	case "s3":
+		if c.S3Bucket == "" {
+			errs = append(errs, errors.New("s3_bucket must be set for the s3 models_backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("models_backend must be local or s3, got %q", c.ModelsBackend))
+	}
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("proxy must be a URL such as http://proxy:3128, got %q", c.Proxy))
+		}
+	}
+	if err := checkWritableDir(c.ModelsPath); err != nil {
+		errs = append(errs, fmt.Errorf("models_path: %w", err))
+	}
+
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"idle_unload", c.IdleUnload},
+		{"request_timeout", c.RequestTimeout},
+		{"slow_query_threshold", c.SlowQueryThreshold},
+		{"ws_ping_interval", c.WSPingInterval},
+		{"session_idle_timeout", c.SessionIdleTimeout},
+		{"rate_limit_cleanup_interval", c.RateLimitCleanupInterval},
+		{"cache_ttl", c.CacheTTL},
+		{"circuit_recovery_timeout", c.CircuitRecoveryTimeout},
+		{"startup_timeout", c.StartupTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.key, d.value))
+		}
+	}
+
+	if c.QueueDepth < 0 {
+		errs = append(errs, fmt.Errorf("queue_depth must not be negative, got %d", c.QueueDepth))
+	}
+	if c.CircuitThreshold < 0 {
+		errs = append(errs, fmt.Errorf("circuit_threshold must not be negative, got %d", c.CircuitThreshold))
+	}
+	if c.CompressionLevel < 0 || c.CompressionLevel > 9 {
+		errs = append(errs, fmt.Errorf("compression_level must be between 0 and 9, got %d", c.CompressionLevel))
+	}
+	if c.MCPPort < 0 || c.MCPPort > 65535 {
+		errs = append(errs, fmt.Errorf("mcp_port must be between 0 and 65535, got %d", c.MCPPort))
+	}
+	if c.GRPCPort < 0 || c.GRPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("grpc_port must be between 0 and 65535, got %d", c.GRPCPort))
+	}
+	if c.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("cache_size must not be negative, got %d", c.CacheSize))
+	}
+	if c.MaxBatchPrompts < 0 {
+		errs = append(errs, fmt.Errorf("max_batch_prompts must not be negative, got %d", c.MaxBatchPrompts))
+	}
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit must not be negative, got %g", c.RateLimit))
+	}
+	if c.RateLimitBurst < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit_burst must not be negative, got %d", c.RateLimitBurst))
+	}
+	if _, err := c.TensorSplit(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.APIKeys(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkWritableDir reports an error unless path is a directory files can be
+// created in
+func checkWritableDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s does not exist", path)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	file, err := os.CreateTemp(path, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable", path)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
+// fileExists reports whether path is an existing regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// keyField returns the Config field of a key
+func keyField(key string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// envName returns the environment variable that overrides a key, as set up by
+// viper.AutomaticEnv
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
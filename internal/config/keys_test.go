@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *Config)
+		wantErr   bool
+	}{
+		{name: "defaults", configure: func(cfg *Config) {}},
+		{name: "lowest port", configure: func(cfg *Config) { cfg.Port = 1 }},
+		{name: "highest port", configure: func(cfg *Config) { cfg.Port = 65535 }},
+		{name: "port zero", configure: func(cfg *Config) { cfg.Port = 0 }, wantErr: true},
+		{name: "port above range", configure: func(cfg *Config) { cfg.Port = 65536 }, wantErr: true},
+		{name: "empty host", configure: func(cfg *Config) { cfg.Host = "" }, wantErr: true},
+		{name: "missing models path", configure: func(cfg *Config) { cfg.ModelsPath = filepath.Join(dir, "missing") }, wantErr: true},
+		{name: "models path is a file", configure: func(cfg *Config) { cfg.ModelsPath = file }, wantErr: true},
+		{name: "zero durations", configure: func(cfg *Config) { cfg.IdleUnload, cfg.RequestTimeout = 0, 0 }},
+		{name: "negative idle unload", configure: func(cfg *Config) { cfg.IdleUnload = -time.Nanosecond }, wantErr: true},
+		{name: "negative request timeout", configure: func(cfg *Config) { cfg.RequestTimeout = -time.Second }, wantErr: true},
+		{name: "zero rate limit", configure: func(cfg *Config) { cfg.RateLimit, cfg.RateLimitBurst = 0, 0 }},
+		{name: "negative rate limit", configure: func(cfg *Config) { cfg.RateLimit = -0.1 }, wantErr: true},
+		{name: "negative burst", configure: func(cfg *Config) { cfg.RateLimitBurst = -1 }, wantErr: true},
+		{name: "JSON logs", configure: func(cfg *Config) { cfg.LogFormat = "json" }},
+		{name: "unknown log format", configure: func(cfg *Config) { cfg.LogFormat = "xml" }, wantErr: true},
+		{name: "pprof address", configure: func(cfg *Config) { cfg.PprofAddr = "localhost:6060" }},
+		{name: "pprof address without port", configure: func(cfg *Config) { cfg.PprofAddr = "localhost" }, wantErr: true},
+		{name: "S3 backend", configure: func(cfg *Config) { cfg.ModelsBackend, cfg.S3Bucket = "s3", "models" }},
+		{name: "S3 backend without bucket", configure: func(cfg *Config) { cfg.ModelsBackend = "s3" }, wantErr: true},
+		{name: "unknown models backend", configure: func(cfg *Config) { cfg.ModelsBackend = "gcs" }, wantErr: true},
+		{name: "negative cache size", configure: func(cfg *Config) { cfg.CacheSize = -1 }, wantErr: true},
+		{name: "MCP port", configure: func(cfg *Config) { cfg.MCPPort = 3000 }},
+		{name: "MCP port above range", configure: func(cfg *Config) { cfg.MCPPort = 65536 }, wantErr: true},
+		{name: "gRPC port", configure: func(cfg *Config) { cfg.GRPCPort = 50051 }},
+		{name: "negative gRPC port", configure: func(cfg *Config) { cfg.GRPCPort = -1 }, wantErr: true},
+		{name: "proxy", configure: func(cfg *Config) { cfg.Proxy = "http://proxy:3128" }},
+		{name: "proxy without scheme", configure: func(cfg *Config) { cfg.Proxy = "proxy:3128" }, wantErr: true},
+		{name: "missing API keys file", configure: func(cfg *Config) { cfg.APIKeysFile = filepath.Join(dir, "missing") }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Host:           "127.0.0.1",
+				Port:           11434,
+				ModelsPath:     dir,
+				LogFormat:      "text",
+				ModelsBackend:  "local",
+				RequestTimeout: 5 * time.Minute,
+				RateLimit:      10,
+				RateLimitBurst: 20,
+			}
+			tt.configure(cfg)
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", key: "host", value: "0.0.0.0", want: "0.0.0.0"},
+		{name: "integer", key: "port", value: "8080", want: 8080},
+		{name: "boolean", key: "metrics", value: "false", want: false},
+		{name: "number", key: "rate_limit", value: "2.5", want: 2.5},
+		{name: "duration", key: "idle_unload", value: "90s", want: "1m30s"},
+		{name: "invalid integer", key: "port", value: "http", wantErr: true},
+		{name: "invalid duration", key: "idle_unload", value: "5", wantErr: true},
+		{name: "unknown key", key: "colour", value: "blue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseValue(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseValue() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/spf13/viper"
+)
+
+// schemaJSON is the JSON Schema of config files. Its properties are the
+// configuration keys.
+//
+//go:embed schema.json
+var schemaJSON string
+
+// schemaProperty is the part of a property of the schema used to describe
+// the errors of its values
+type schemaProperty struct {
+	Type    string        `json:"type"`
+	Minimum *float64      `json:"minimum"`
+	Maximum *float64      `json:"maximum"`
+	Enum    []interface{} `json:"enum"`
+}
+
+var (
+	loadSchemaOnce   sync.Once
+	configSchema     *jsonschema.Schema
+	schemaProperties map[string]schemaProperty
+	schemaErr        error
+)
+
+// loadSchema compiles the config file schema
+func loadSchema() (*jsonschema.Schema, error) {
+	loadSchemaOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiler.AssertFormat = true
+		compiler.Formats["go-duration"] = isDuration
+		if schemaErr = compiler.AddResource("schema.json", strings.NewReader(schemaJSON)); schemaErr != nil {
+			return
+		}
+		if configSchema, schemaErr = compiler.Compile("schema.json"); schemaErr != nil {
+			return
+		}
+
+		var doc struct {
+			Properties map[string]schemaProperty `json:"properties"`
+		}
+		schemaErr = json.Unmarshal([]byte(schemaJSON), &doc)
+		schemaProperties = doc.Properties
+	})
+	return configSchema, schemaErr
+}
+
+// isDuration reports whether v is a non-negative duration such as "1h30m"
+func isDuration(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	d, err := time.ParseDuration(s)
+	return err == nil && d >= 0
+}
+
+// CheckFile validates the config file in use, if any, against the
+// schema
+func CheckFile() error {
+	file := viper.ConfigFileUsed()
+	if file == "" || !fileExists(file) {
+		return nil
+	}
+
+	// Read the file on its own to tell its keys from defaults and flags
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+	return validateSettings(file, v.AllSettings())
+}
+
+// validateSettings checks the settings read from a config file against the
+// schema, returning an error for every invalid or unknown key
+func validateSettings(file string, settings map[string]interface{}) error {
+	schema, err := loadSchema()
+	if err != nil {
+		return fmt.Errorf("invalid config schema: %w", err)
+	}
+
+	// The schema validates JSON values, so settings read from YAML or TOML
+	// are converted first
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+
+	err = schema.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	leaves := leafErrors(validationErr)
+	sort.Slice(leaves, func(i, j int) bool {
+		return leaves[i].InstanceLocation < leaves[j].InstanceLocation
+	})
+
+	var errs []error
+	for _, leaf := range leaves {
+		errs = append(errs, describeSchemaError(file, leaf, doc)...)
+	}
+	return errors.Join(errs...)
+}
+
+// leafErrors returns the errors at the end of the causes of err, which are
+// the failed checks
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// describeSchemaError turns a failed schema check into errors phrased like
+// those of Validate, e.g. "port must be between 1 and 65535, got 70000"
+func describeSchemaError(file string, err *jsonschema.ValidationError, doc map[string]interface{}) []error {
+	key := strings.TrimPrefix(err.InstanceLocation, "/")
+	property := schemaProperties[key]
+	value := doc[key]
+
+	switch path.Base(err.KeywordLocation) {
+	case "additionalProperties":
+		var unknown []string
+		for key := range doc {
+			if _, ok := schemaProperties[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+
+		errs := make([]error, len(unknown))
+		for i, key := range unknown {
+			errs[i] = fmt.Errorf("unknown config key in %s: %s", file, key)
+		}
+		return errs
+	case "minimum", "maximum":
+		switch {
+		case property.Minimum != nil && property.Maximum != nil:
+			return []error{fmt.Errorf("%s must be between %v and %v, got %v", key, *property.Minimum, *property.Maximum, value)}
+		case property.Minimum != nil && *property.Minimum == 0:
+			return []error{fmt.Errorf("%s must not be negative, got %v", key, value)}
+		}
+	case "format":
+		return []error{fmt.Errorf("%s must be a duration such as 30s or 5m, got %v", key, value)}
+	case "enum":
+		values := make([]string, len(property.Enum))
+		for i, v := range property.Enum {
+			values[i] = fmt.Sprint(v)
+		}
+		return []error{fmt.Errorf("%s must be %s, got %q", key, strings.Join(values, " or "), fmt.Sprint(value))}
+	case "type":
+		return []error{fmt.Errorf("%s must be of type %s, got %v", key, property.Type, value)}
+	case "minLength":
+		return []error{fmt.Errorf("%s must not be empty", key)}
+	}
+	return []error{fmt.Errorf("%s: %s", key, err.Message)}
+}
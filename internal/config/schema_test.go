@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// validateConfigFile validates the configuration read from a config file with
+// the given name and contents
+func validateConfigFile(t *testing.T, name, content string) error {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig: %v", err)
+	}
+	return Read().Validate()
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	modelsPath := t.TempDir()
+	notDir := filepath.Join(modelsPath, "model.gguf")
+	if err := os.WriteFile(notDir, []byte("GGUF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr []string
+	}{
+		{
+			name:    "valid",
+			content: "port: 8080\nrequest_timeout: 10m\nlog_format: json\nrate_limit: 2.5\n",
+		},
+		{
+			name:    "valid TOML",
+			file:    "config.toml",
+			content: "port = 8080\nrequest_timeout = \"10m\"\n",
+		},
+		{
+			name:    "port too large",
+			content: "port: 70000\n",
+			wantErr: []string{"port must be between 1 and 65535, got 70000"},
+		},
+		{
+			name:    "port zero",
+			content: "port: 0\n",
+			wantErr: []string{"port must be between 1 and 65535, got 0"},
+		},
+		{
+			name:    "port not a number",
+			content: "port: http\n",
+			wantErr: []string{"port must be of type integer, got http"},
+		},
+		{
+			name:    "negative count",
+			content: "queue_depth: -1\n",
+			wantErr: []string{"queue_depth must not be negative, got -1"},
+		},
+		{
+			name:    "invalid duration",
+			content: "request_timeout: 5 minutes\n",
+			wantErr: []string{"request_timeout must be a duration such as 30s or 5m, got 5 minutes"},
+		},
+		{
+			name:    "invalid choice",
+			content: "log_format: xml\n",
+			wantErr: []string{`log_format must be text or json, got "xml"`},
+		},
+		{
+			name:    "unknown keys",
+			content: "portt: 8080\ngpu_layers: 20\n",
+			wantErr: []string{"unknown config key in", ": gpu_layers", ": portt"},
+		},
+		{
+			name:    "unknown keys in TOML",
+			file:    "config.toml",
+			content: "[server]\nport = 8080\n",
+			wantErr: []string{"unknown config key in", ": server"},
+		},
+		{
+			name:    "several errors",
+			content: "port: 70000\nqueue_depth: -2\n",
+			wantErr: []string{"port must be between 1 and 65535", "queue_depth must not be negative, got -2"},
+		},
+		{
+			name:    "non-existent models path",
+			content: "models_path: " + filepath.Join(modelsPath, "missing") + "\n",
+			wantErr: []string{"models_path: " + filepath.Join(modelsPath, "missing") + " does not exist"},
+		},
+		{
+			name:    "models path is a file",
+			content: "models_path: " + notDir + "\n",
+			wantErr: []string{"models_path: " + notDir + " is not a directory"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "config.yaml"
+			}
+			content := tt.content
+			if !strings.Contains(content, "models_path") {
+				if strings.HasSuffix(file, ".toml") {
+					content = "models_path = \"" + modelsPath + "\"\n" + content
+				} else {
+					content = "models_path: " + modelsPath + "\n" + content
+				}
+			}
+
+			err := validateConfigFile(t, file, content)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate succeeded, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q lacks %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	// Values that do not come from the config file are range checked too
+	t.Setenv("PORT", "70000")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.AutomaticEnv()
+	viper.Set("models_path", t.TempDir())
+
+	err := Read().Validate()
+	if err == nil || !strings.Contains(err.Error(), "port must be between 1 and 65535, got 70000") {
+		t.Errorf("error = %v, want the port out of range", err)
+	}
+}
+
+func TestSchemaMatchesConfig(t *testing.T) {
+	if _, err := loadSchema(); err != nil {
+		t.Fatalf("loadSchema: %v", err)
+	}
+
+	var properties []string
+	for key := range schemaProperties {
+		properties = append(properties, key)
+	}
+	keys := Keys()
+	sort.Strings(properties)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(properties, keys) {
+		t.Fatalf("schema properties = %v, want the config keys %v", properties, keys)
+	}
+
+	// The type of every property matches its field
+	types := map[reflect.Kind]string{
+		reflect.String:  "string",
+		reflect.Bool:    "boolean",
+		reflect.Int:     "integer",
+		reflect.Int64:   "string", // durations
+		reflect.Float64: "number",
+	}
+	for _, key := range keys {
+		field, _ := keyField(key)
+		property := schemaProperties[key]
+		if property.Type == "" && len(property.Enum) > 0 {
+			property.Type = "string"
+		}
+		if want := types[field.Type.Kind()]; property.Type != want {
+			t.Errorf("%s has type %q in the schema, want %q", key, property.Type, want)
+		}
+	}
+}
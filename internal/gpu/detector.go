@@ -3,6 +3,7 @@ package gpu
 import (
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ const (
 	GPUTypeNone   GPUType = "none"
 	GPUTypeCUDA   GPUType = "cuda"
 	GPUTypeROCm   GPUType = "rocm"
+	GPUTypeSYCL   GPUType = "sycl"
 	GPUTypeMetal  GPUType = "metal"
 	GPUTypeOpenCL GPUType = "opencl"
 )
@@ -61,6 +63,12 @@ func DetectGPUs() *GPUInfo {
 		return info
 	}
 
+	// Check SYCL (Intel oneAPI)
+	if syclInfo := detectSYCL(); syclInfo.Available {
+		*info = *syclInfo
+		return info
+	}
+
 	// Check Metal (Apple Silicon)
 	if runtime.GOOS == "darwin" {
 		if metalInfo := detectMetal(); metalInfo.Available {
@@ -151,6 +159,24 @@ func detectCUDA() *GPUInfo {
 	return info
 }
 
+// GetFreeVRAM returns the free memory of a CUDA device in MB, or 0 when it
+// cannot be queried
+func GetFreeVRAM(deviceID int) int64 {
+	cmd := exec.Command("nvidia-smi", "--query-gpu=memory.free", "--id="+strconv.Itoa(deviceID), "--format=csv,noheader,nounits")
+	output, err := cmd.Output()
+	if err != nil {
+		logrus.Debugf("nvidia-smi not available: %v", err)
+		return 0
+	}
+
+	free, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		logrus.Debugf("Unexpected nvidia-smi output for device %d: %q", deviceID, output)
+		return 0
+	}
+	return free
+}
+
 // detectROCm detects AMD ROCm support
 func detectROCm() *GPUInfo {
 	info := &GPUInfo{
@@ -200,6 +226,104 @@ func detectROCm() *GPUInfo {
 	return info
 }
 
+// detectSYCL detects Intel GPUs through oneAPI SYCL
+func detectSYCL() *GPUInfo {
+	info := &GPUInfo{
+		Type:      GPUTypeSYCL,
+		Available: false,
+	}
+
+	// Check for the oneAPI environment, set up by its setvars.sh
+	if os.Getenv("SYCL_DEVICE_FILTER") == "" && os.Getenv("ONEAPI_ROOT") == "" {
+		return info
+	}
+
+	cmd := exec.Command("sycl-ls")
+	output, err := cmd.Output()
+	if err != nil {
+		logrus.Debugf("sycl-ls not available: %v", err)
+		return info
+	}
+
+	info.Devices = parseSyclLs(string(output))
+	if len(info.Devices) > 0 {
+		info.Available = true
+		info.DeviceCount = len(info.Devices)
+		logrus.Infof("Detected %d SYCL GPU(s)", info.DeviceCount)
+	}
+
+	return info
+}
+
+// syclDevicePattern matches a device line of sycl-ls, e.g.
+// "[ext_oneapi_level_zero:gpu:0] Intel(R) Level-Zero, Intel(R) Arc(TM) A770 Graphics 1.3 [1.3.26241]"
+var syclDevicePattern = regexp.MustCompile(`^\[([a-z_]+):gpu:(\d+)\]\s+[^,]*,\s+(.+?)\s+[\d.]+\s+\[.*\]\s*$`)
+
+// parseSyclLs returns the Intel GPUs listed by sycl-ls. Each GPU is usually
+// listed by both the Level Zero and the OpenCL backend; llama.cpp uses Level
+// Zero, so OpenCL devices are only used when no Level Zero device is listed.
+func parseSyclLs(output string) []GPU {
+	devices := make(map[string][]GPU)
+	for _, line := range strings.Split(output, "\n") {
+		match := syclDevicePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		backend, name := match[1], match[3]
+		if !strings.Contains(name, "Intel") {
+			continue
+		}
+		id, _ := strconv.Atoi(match[2])
+
+		devices[backend] = append(devices[backend], GPU{
+			ID:        id,
+			Name:      name,
+			Memory:    intelGPUMemory(name),
+			Available: true,
+		})
+	}
+
+	if levelZero := devices["ext_oneapi_level_zero"]; len(levelZero) > 0 {
+		return levelZero
+	}
+	return devices["opencl"]
+}
+
+// intelGPUMemory returns the dedicated memory in MB of an Intel GPU, which
+// sycl-ls does not report. Integrated GPUs such as Iris Xe share system memory
+// and have none.
+func intelGPUMemory(name string) int64 {
+	arcMemory := []struct {
+		model  string
+		memory int64
+	}{
+		{"A770", 16384},
+		{"A750", 8192},
+		{"A580", 8192},
+		{"A380", 6144},
+		{"A310", 4096},
+		{"A770M", 16384},
+		{"A730M", 12288},
+		{"A550M", 8192},
+		{"A370M", 4096},
+		{"A350M", 4096},
+	}
+
+	if !strings.Contains(name, "Arc") {
+		return 0
+	}
+	fields := strings.Fields(name)
+	for _, entry := range arcMemory {
+		for _, field := range fields {
+			if field == entry.model {
+				return entry.memory
+			}
+		}
+	}
+	return 0
+}
+
 // detectMetal detects Apple Metal support
 func detectMetal() *GPUInfo {
 	info := &GPUInfo{
@@ -256,6 +380,12 @@ func GetOptimalGPULayers(gpuInfo *GPUInfo, modelSize int64) int {
 		}
 	}
 
+	// Integrated Intel GPUs have no dedicated memory to offload layers to
+	if gpuInfo.Type == GPUTypeSYCL && totalGPUMemory == 0 {
+		logrus.Info("No dedicated SYCL GPU memory, keeping all layers on the CPU")
+		return 0
+	}
+
 	// Rough estimation: each layer needs about 100MB for a 7B model
 	layerMemory := int64(100 * 1024 * 1024)
 	if modelSize > 7000000000 { // 13B+ models
@@ -0,0 +1,71 @@
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readFixture returns the contents of a file in testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseSyclLs(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []GPU
+	}{
+		{
+			// Level Zero devices are preferred over the OpenCL listing of the same GPUs
+			fixture: "sycl-ls.txt",
+			want: []GPU{
+				{ID: 0, Name: "Intel(R) Arc(TM) A770 Graphics", Memory: 16384, Available: true},
+				{ID: 1, Name: "Intel(R) UHD Graphics 770", Memory: 0, Available: true},
+			},
+		},
+		{
+			fixture: "sycl-ls-opencl.txt",
+			want: []GPU{
+				{ID: 1, Name: "Intel(R) Iris(R) Xe Graphics", Memory: 0, Available: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			if got := parseSyclLs(readFixture(t, tt.fixture)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSyclLs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := parseSyclLs(""); len(got) != 0 {
+		t.Errorf("parseSyclLs of no output = %+v, want no devices", got)
+	}
+}
+
+func TestIntelGPUMemory(t *testing.T) {
+	tests := []struct {
+		name string
+		want int64
+	}{
+		{name: "Intel(R) Arc(TM) A770 Graphics", want: 16384},
+		{name: "Intel(R) Arc(TM) A770M Graphics", want: 16384},
+		{name: "Intel(R) Arc(TM) A380 Graphics", want: 6144},
+		{name: "Intel(R) Arc(TM) B580 Graphics", want: 0},
+		{name: "Intel(R) Iris(R) Xe Graphics", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := intelGPUMemory(tt.name); got != tt.want {
+			t.Errorf("intelGPUMemory(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceMetrics is a sample of the usage of one GPU
+type DeviceMetrics struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Utilization int     `json:"utilization_percent"`
+	MemoryUsed  int64   `json:"memory_used_mb"`
+	MemoryTotal int64   `json:"memory_total_mb"`
+	Temperature int     `json:"temperature_c"`
+	PowerDraw   float64 `json:"power_draw_w"`
+}
+
+// GPUMetrics is a sample of the usage of all GPUs. Error is set when the
+// sample could not be taken.
+type GPUMetrics struct {
+	Type      GPUType         `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Devices   []DeviceMetrics `json:"devices"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Monitor samples the usage of the GPUs every interval until ctx is done,
+// then closes the returned channel. The first sample is taken immediately.
+// Usage can be sampled for CUDA and ROCm devices.
+func Monitor(ctx context.Context, interval time.Duration) <-chan GPUMetrics {
+	metrics := make(chan GPUMetrics, 1)
+
+	go func() {
+		defer close(metrics)
+
+		gpuInfo := DetectGPUs()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case metrics <- sampleMetrics(gpuInfo):
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return metrics
+}
+
+// sampleMetrics takes one sample of the usage of the detected GPUs
+func sampleMetrics(gpuInfo *GPUInfo) GPUMetrics {
+	sample := GPUMetrics{
+		Type:      gpuInfo.Type,
+		Timestamp: time.Now(),
+	}
+
+	var devices []DeviceMetrics
+	var err error
+	switch gpuInfo.Type {
+	case GPUTypeCUDA:
+		devices, err = sampleCUDA()
+	case GPUTypeROCm:
+		devices, err = sampleROCm()
+	case GPUTypeNone:
+		err = fmt.Errorf("no GPU detected")
+	default:
+		err = fmt.Errorf("usage monitoring is not supported for %s GPUs", gpuInfo.Type)
+	}
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+
+	// Fill in what the monitoring tools do not report from the detected devices
+	for i := range devices {
+		for _, device := range gpuInfo.Devices {
+			if device.ID != devices[i].ID {
+				continue
+			}
+			if devices[i].Name == "" {
+				devices[i].Name = device.Name
+			}
+			if devices[i].MemoryTotal == 0 {
+				devices[i].MemoryTotal = device.Memory
+			}
+		}
+	}
+
+	sample.Devices = devices
+	return sample
+}
+
+// sampleCUDA samples the NVIDIA GPUs with nvidia-smi dmon
+func sampleCUDA() ([]DeviceMetrics, error) {
+	// p: power and temperature, u: utilization, m: memory used
+	cmd := exec.Command("nvidia-smi", "dmon", "-c", "1", "-s", "pum")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi dmon failed: %w", err)
+	}
+	return parseNvidiaDmon(string(output))
+}
+
+// sampleROCm samples the AMD GPUs with rocm-smi
+func sampleROCm() ([]DeviceMetrics, error) {
+	cmd := exec.Command("rocm-smi", "--showuse", "--showtemp", "--showpower", "--showmeminfo", "vram", "--json")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rocm-smi failed: %w", err)
+	}
+	return parseRocmSmiJSON(output)
+}
+
+// parseNvidiaDmon parses the output of nvidia-smi dmon. Its columns depend on
+// the driver version and the selected metrics, so they are found by the names
+// in the header, e.g.
+//
+//	# gpu    pwr  gtemp  mtemp     sm    mem    enc    dec     fb   bar1
+//	# Idx      W      C      C      %      %      %      %     MB     MB
+//	    0     25     38      -      3      1      0      0    512      5
+func parseNvidiaDmon(output string) ([]DeviceMetrics, error) {
+	var columns map[string]int
+	var devices []DeviceMetrics
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			// The first header line names the columns, the second their units
+			if columns == nil {
+				columns = make(map[string]int)
+				for i, name := range strings.Fields(strings.TrimPrefix(line, "#")) {
+					columns[name] = i
+				}
+			}
+			continue
+		}
+		if columns == nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi dmon output: missing header")
+		}
+
+		fields := strings.Fields(line)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return fields[i]
+			}
+			return "-"
+		}
+
+		id, err := strconv.Atoi(field("gpu"))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi dmon output: %q", line)
+		}
+
+		devices = append(devices, DeviceMetrics{
+			ID:          id,
+			Utilization: parseMetricInt(field("sm")),
+			MemoryUsed:  int64(parseMetricInt(field("fb"))),
+			Temperature: parseMetricInt(field("gtemp")),
+			PowerDraw:   parseMetricFloat(field("pwr")),
+		})
+	}
+
+	return devices, nil
+}
+
+// parseRocmSmiJSON parses the output of rocm-smi --json, which maps each card,
+// e.g. "card0", to its metrics. The metric names vary between ROCm versions,
+// so they are matched loosely.
+func parseRocmSmiJSON(data []byte) ([]DeviceMetrics, error) {
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return nil, fmt.Errorf("unexpected rocm-smi output: %w", err)
+	}
+
+	var devices []DeviceMetrics
+	for card, values := range cards {
+		id, err := strconv.Atoi(strings.TrimPrefix(card, "card"))
+		if err != nil {
+			// Not a card, e.g. the "system" entry
+			continue
+		}
+
+		device := DeviceMetrics{ID: id}
+		for key, value := range values {
+			switch {
+			case key == "GPU use (%)":
+				device.Utilization = parseMetricInt(value)
+			case key == "VRAM Total Memory (B)":
+				device.MemoryTotal = int64(parseMetricFloat(value)) / (1024 * 1024)
+			case key == "VRAM Total Used Memory (B)":
+				device.MemoryUsed = int64(parseMetricFloat(value)) / (1024 * 1024)
+			case strings.HasPrefix(key, "Temperature") && strings.Contains(key, "edge"):
+				device.Temperature = parseMetricInt(value)
+			case strings.HasSuffix(key, "Graphics Package Power (W)"):
+				device.PowerDraw = parseMetricFloat(value)
+			}
+		}
+		devices = append(devices, device)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].ID < devices[j].ID
+	})
+	return devices, nil
+}
+
+// parseMetricInt parses a reported value, which is 0 when it is unavailable,
+// e.g. "-" or "N/A"
+func parseMetricInt(value string) int {
+	return int(parseMetricFloat(value))
+}
+
+// parseMetricFloat parses a reported value, which is 0 when it is unavailable
+func parseMetricFloat(value string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
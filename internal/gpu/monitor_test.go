@@ -0,0 +1,78 @@
+package gpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNvidiaDmon(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []DeviceMetrics
+	}{
+		{
+			fixture: "nvidia-smi-dmon.txt",
+			want: []DeviceMetrics{
+				{ID: 0, Utilization: 3, MemoryUsed: 512, Temperature: 38, PowerDraw: 25},
+				{ID: 1, Utilization: 98, MemoryUsed: 22860, Temperature: 71, PowerDraw: 287},
+			},
+		},
+		{
+			// Newer drivers add columns, which are found by name
+			fixture: "nvidia-smi-dmon-jpg.txt",
+			want: []DeviceMetrics{
+				{ID: 0, Utilization: 47, MemoryUsed: 40213, Temperature: 55, PowerDraw: 112},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := parseNvidiaDmon(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("parseNvidiaDmon: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNvidiaDmon = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNvidiaDmonInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "missing header", output: "    0     25     38      -      3\n"},
+		{name: "not a device line", output: "# gpu    pwr\n# Idx      W\nNo devices were found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseNvidiaDmon(tt.output); err == nil {
+				t.Error("parseNvidiaDmon succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseRocmSmiJSON(t *testing.T) {
+	got, err := parseRocmSmiJSON([]byte(readFixture(t, "rocm-smi.json")))
+	if err != nil {
+		t.Fatalf("parseRocmSmiJSON: %v", err)
+	}
+
+	// Unavailable values are reported as 0
+	want := []DeviceMetrics{
+		{ID: 0, Utilization: 12, MemoryUsed: 1024, MemoryTotal: 16368, Temperature: 45, PowerDraw: 35},
+		{ID: 1, Utilization: 100, MemoryUsed: 19456, MemoryTotal: 24560, Temperature: 0, PowerDraw: 210.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRocmSmiJSON = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseRocmSmiJSON([]byte("ERROR: GPU not found")); err == nil {
+		t.Error("parseRocmSmiJSON of non-JSON output succeeded, want an error")
+	}
+}
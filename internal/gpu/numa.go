@@ -0,0 +1,104 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numaNodesDir is where Linux lists the NUMA nodes of the machine
+const numaNodesDir = "/sys/devices/system/node"
+
+// NUMANode is a NUMA node: a set of CPUs and the memory local to them
+type NUMANode struct {
+	ID       int   `json:"id"`
+	CPUs     []int `json:"cpus"`
+	MemoryMB int64 `json:"memory_mb"`
+}
+
+// DetectNUMATopology returns the NUMA nodes of the machine sorted by ID, or
+// nil when they cannot be read, e.g. on other systems than Linux. A machine
+// with more than one node benefits from the NUMA mode of the model options.
+func DetectNUMATopology() []NUMANode {
+	nodes, err := readNUMATopology(numaNodesDir)
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// readNUMATopology reads the node* directories of a sysfs node directory
+func readNUMATopology(dir string) ([]NUMANode, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []NUMANode
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "node"))
+		if !strings.HasPrefix(entry.Name(), "node") || err != nil {
+			continue
+		}
+
+		node := NUMANode{ID: id}
+		nodeDir := filepath.Join(dir, entry.Name())
+		if data, err := os.ReadFile(filepath.Join(nodeDir, "cpulist")); err == nil {
+			if node.CPUs, err = parseCPUList(string(data)); err != nil {
+				return nil, fmt.Errorf("node %d: %w", id, err)
+			}
+		}
+		if data, err := os.ReadFile(filepath.Join(nodeDir, "meminfo")); err == nil {
+			node.MemoryMB = parseNodeMemTotal(string(data))
+		}
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// parseCPUList parses a kernel CPU list, e.g. "0-3,8-11,16"
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// parseNodeMemTotal returns the MemTotal of a node's meminfo in MB, e.g.
+// from "Node 0 MemTotal:       65536000 kB"
+func parseNodeMemTotal(meminfo string) int64 {
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[2] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb / 1024
+		}
+	}
+	return 0
+}
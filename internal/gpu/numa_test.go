@@ -0,0 +1,86 @@
+package gpu
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// cpuRange returns the CPUs first to last
+func cpuRange(first, last int) []int {
+	var cpus []int
+	for cpu := first; cpu <= last; cpu++ {
+		cpus = append(cpus, cpu)
+	}
+	return cpus
+}
+
+func TestReadNUMATopology(t *testing.T) {
+	// A two-socket machine with hyperthreading and a CXL memory expander,
+	// a node without CPUs
+	nodes, err := readNUMATopology(filepath.Join("testdata", "sys", "devices", "system", "node"))
+	if err != nil {
+		t.Fatalf("readNUMATopology: %v", err)
+	}
+
+	want := []NUMANode{
+		{ID: 0, CPUs: append(cpuRange(0, 15), cpuRange(32, 47)...), MemoryMB: 128807},
+		{ID: 1, CPUs: append(cpuRange(16, 31), cpuRange(48, 63)...), MemoryMB: 129024},
+		{ID: 2, MemoryMB: 65536},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("nodes = %+v, want %+v", nodes, want)
+	}
+}
+
+func TestReadNUMATopologyMissing(t *testing.T) {
+	if _, err := readNUMATopology(filepath.Join("testdata", "no-such-dir")); err == nil {
+		t.Error("readNUMATopology succeeded without a node directory")
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "0", want: []int{0}},
+		{list: "0-3\n", want: []int{0, 1, 2, 3}},
+		{list: "0-1,8-9,16", want: []int{0, 1, 8, 9, 16}},
+		{list: "\n"},
+		{list: "3-1", wantErr: true},
+		{list: "0-x", wantErr: true},
+		{list: "cpu0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCPUList(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCPUList(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestParseNodeMemTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo string
+		want    int64
+	}{
+		{name: "meminfo", meminfo: readFixture(t, "sys/devices/system/node/node1/meminfo"), want: 129024},
+		{name: "no MemTotal", meminfo: "Node 0 MemFree:        1024 kB\n"},
+		{name: "invalid", meminfo: "Node 0 MemTotal:       lots kB\n"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		if got := parseNodeMemTotal(tt.meminfo); got != tt.want {
+			t.Errorf("%s: parseNodeMemTotal = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GetTensorSplit returns the proportion of a model to place on each usable
+// device, weighted by the device's free VRAM. It returns nil unless there are
+// several CUDA or ROCm devices to split across.
+func GetTensorSplit(gpuInfo *GPUInfo) []float32 {
+	if gpuInfo.Type != GPUTypeCUDA && gpuInfo.Type != GPUTypeROCm {
+		return nil
+	}
+
+	var memory []int64
+	for _, device := range gpuInfo.Devices {
+		if !device.Available {
+			continue
+		}
+
+		// Free VRAM is only known for CUDA devices; fall back to the total
+		free := int64(0)
+		if gpuInfo.Type == GPUTypeCUDA {
+			free = GetFreeVRAM(device.ID)
+		}
+		if free <= 0 {
+			free = device.Memory
+		}
+		memory = append(memory, free)
+	}
+
+	if len(memory) < 2 {
+		return nil
+	}
+	return proportionalSplit(memory)
+}
+
+// ParseTensorSplit parses a comma-separated list of proportions, e.g.
+// "0.5,0.5" or "3,1". The proportions are normalized to sum to 1.
+func ParseTensorSplit(value string) ([]float32, error) {
+	var weights []float64
+	for _, part := range strings.Split(value, ",") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid GPU split %q: proportions must be non-negative numbers", value)
+		}
+		weights = append(weights, weight)
+	}
+
+	split := normalize(weights)
+	if split == nil {
+		return nil, fmt.Errorf("invalid GPU split %q: at least one proportion must be positive", value)
+	}
+	return split, nil
+}
+
+// proportionalSplit returns each device's share of the total memory
+func proportionalSplit(memory []int64) []float32 {
+	weights := make([]float64, len(memory))
+	for i, m := range memory {
+		if m > 0 {
+			weights[i] = float64(m)
+		}
+	}
+
+	split := normalize(weights)
+	if split == nil {
+		// Nothing is known about the devices, so split evenly
+		split = make([]float32, len(memory))
+		for i := range split {
+			split[i] = 1 / float32(len(memory))
+		}
+	}
+	return split
+}
+
+// normalize scales weights to sum to 1, or returns nil when they sum to 0
+func normalize(weights []float64) []float32 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	split := make([]float32, len(weights))
+	for i, w := range weights {
+		split[i] = float32(w / total)
+	}
+	return split
+}
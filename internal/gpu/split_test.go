@@ -0,0 +1,119 @@
+package gpu
+
+import (
+	"math"
+	"testing"
+)
+
+// equalSplit reports whether two splits match to within rounding
+func equalSplit(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProportionalSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory []int64
+		want   []float32
+	}{
+		{name: "equal devices", memory: []int64{24576, 24576}, want: []float32{0.5, 0.5}},
+		{name: "unequal devices", memory: []int64{24576, 8192}, want: []float32{0.75, 0.25}},
+		{name: "three devices", memory: []int64{16384, 8192, 8192}, want: []float32{0.5, 0.25, 0.25}},
+		{name: "unknown memory is skipped", memory: []int64{12288, 0, 4096}, want: []float32{0.75, 0, 0.25}},
+		{name: "nothing known splits evenly", memory: []int64{0, 0, 0, 0}, want: []float32{0.25, 0.25, 0.25, 0.25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := proportionalSplit(tt.memory); !equalSplit(got, tt.want) {
+				t.Errorf("proportionalSplit(%v) = %v, want %v", tt.memory, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTensorSplit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []float32
+		wantErr bool
+	}{
+		{value: "0.5,0.5", want: []float32{0.5, 0.5}},
+		{value: "3,1", want: []float32{0.75, 0.25}},
+		{value: " 1 , 1 , 2 ", want: []float32{0.25, 0.25, 0.5}},
+		{value: "1,0", want: []float32{1, 0}},
+		{value: "0,0", wantErr: true},
+		{value: "1,-1", wantErr: true},
+		{value: "half,half", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTensorSplit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseTensorSplit(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTensorSplit(%q): %v", tt.value, err)
+			}
+			if !equalSplit(got, tt.want) {
+				t.Errorf("ParseTensorSplit(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTensorSplitNeedsSeveralDevices(t *testing.T) {
+	tests := []struct {
+		name string
+		info *GPUInfo
+	}{
+		{
+			name: "one device",
+			info: &GPUInfo{Type: GPUTypeROCm, Devices: []GPU{{ID: 0, Memory: 16384, Available: true}}},
+		},
+		{
+			name: "one available device",
+			info: &GPUInfo{Type: GPUTypeROCm, Devices: []GPU{
+				{ID: 0, Memory: 16384, Available: true},
+				{ID: 1, Memory: 16384},
+			}},
+		},
+		{
+			name: "no split for Metal",
+			info: &GPUInfo{Type: GPUTypeMetal, Devices: []GPU{
+				{ID: 0, Memory: 16384, Available: true},
+				{ID: 1, Memory: 16384, Available: true},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetTensorSplit(tt.info); got != nil {
+				t.Errorf("GetTensorSplit = %v, want nil", got)
+			}
+		})
+	}
+
+	// ROCm devices are weighted by their total memory, in MB
+	info := &GPUInfo{Type: GPUTypeROCm, Devices: []GPU{
+		{ID: 0, Memory: 24576, Available: true},
+		{ID: 1, Memory: 8192, Available: true},
+	}}
+	if got, want := GetTensorSplit(info), []float32{0.75, 0.25}; !equalSplit(got, want) {
+		t.Errorf("GetTensorSplit = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,375 @@
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ElementType identifies the kind of a grammar element. The values match
+// llama.cpp's llama_gretype enum so rules can be passed to it unchanged.
+type ElementType uint32
+
+const (
+	// End marks the end of a rule definition
+	End ElementType = iota
+	// Alt starts an alternate definition of a rule
+	Alt
+	// RuleRef references another rule by its ID
+	RuleRef
+	// Char matches a single code point (or starts a character range)
+	Char
+	// CharNot matches any code point not in the following range
+	CharNot
+	// CharRangeUpper modifies a preceding Char or CharAlt to an inclusive range
+	CharRangeUpper
+	// CharAlt adds an alternate code point to a character range
+	CharAlt
+)
+
+// Element is a single element of a grammar rule
+type Element struct {
+	Type  ElementType
+	Value uint32
+}
+
+// Grammar is a parsed GBNF grammar
+type Grammar struct {
+	Rules     [][]Element
+	SymbolIDs map[string]uint32
+}
+
+// RootIndex returns the index of the "root" rule
+func (g *Grammar) RootIndex() int {
+	return int(g.SymbolIDs["root"])
+}
+
+// parser holds the state of a GBNF parse
+type parser struct {
+	src       string
+	symbolIDs map[string]uint32
+	rules     [][]Element
+}
+
+// Parse parses a grammar written in GBNF (the grammar format used by llama.cpp)
+func Parse(src string) (*Grammar, error) {
+	p := &parser{
+		src:       src,
+		symbolIDs: make(map[string]uint32),
+	}
+
+	pos := p.parseSpace(0, true)
+	for pos < len(src) {
+		var err error
+		if pos, err = p.parseRule(pos); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := p.symbolIDs["root"]; !ok {
+		return nil, fmt.Errorf("grammar does not define a root rule")
+	}
+
+	// Ensure every referenced rule is defined
+	for name, id := range p.symbolIDs {
+		if int(id) >= len(p.rules) || len(p.rules[id]) == 0 {
+			return nil, fmt.Errorf("undefined rule identifier '%s'", name)
+		}
+	}
+
+	return &Grammar{
+		Rules:     p.rules,
+		SymbolIDs: p.symbolIDs,
+	}, nil
+}
+
+// at returns the byte at pos, or 0 past the end of the source
+func (p *parser) at(pos int) byte {
+	if pos < len(p.src) {
+		return p.src[pos]
+	}
+	return 0
+}
+
+// getSymbolID returns the ID for a named rule, allocating one if needed
+func (p *parser) getSymbolID(name string) uint32 {
+	if id, ok := p.symbolIDs[name]; ok {
+		return id
+	}
+	id := uint32(len(p.symbolIDs))
+	p.symbolIDs[name] = id
+	return id
+}
+
+// generateSymbolID allocates an ID for an anonymous helper rule
+func (p *parser) generateSymbolID(baseName string) uint32 {
+	id := uint32(len(p.symbolIDs))
+	p.symbolIDs[baseName+"_"+strconv.Itoa(int(id))] = id
+	return id
+}
+
+// addRule stores a rule definition at the given ID
+func (p *parser) addRule(id uint32, rule []Element) {
+	for uint32(len(p.rules)) <= id {
+		p.rules = append(p.rules, nil)
+	}
+	p.rules[id] = rule
+}
+
+// parseSpace skips whitespace and comments
+func (p *parser) parseSpace(pos int, newlineOK bool) int {
+	for {
+		c := p.at(pos)
+		switch {
+		case c == ' ' || c == '\t':
+			pos++
+		case c == '#':
+			for pos < len(p.src) && p.src[pos] != '\r' && p.src[pos] != '\n' {
+				pos++
+			}
+		case newlineOK && (c == '\r' || c == '\n'):
+			pos++
+		default:
+			return pos
+		}
+	}
+}
+
+func isWordChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '-' || (c >= '0' && c <= '9')
+}
+
+// parseName returns the end position of the rule name starting at pos
+func (p *parser) parseName(pos int) (int, error) {
+	end := pos
+	for isWordChar(p.at(end)) {
+		end++
+	}
+	if end == pos {
+		return 0, fmt.Errorf("expecting name at %s", p.context(pos))
+	}
+	return end, nil
+}
+
+// parseHex decodes size hex digits starting at pos
+func (p *parser) parseHex(pos, size int) (uint32, int, error) {
+	if pos+size > len(p.src) {
+		return 0, 0, fmt.Errorf("expecting %d hex chars at %s", size, p.context(pos))
+	}
+	value, err := strconv.ParseUint(p.src[pos:pos+size], 16, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("expecting %d hex chars at %s", size, p.context(pos))
+	}
+	return uint32(value), pos + size, nil
+}
+
+// parseChar decodes a single, possibly escaped, character
+func (p *parser) parseChar(pos int) (uint32, int, error) {
+	if pos >= len(p.src) {
+		return 0, 0, fmt.Errorf("unexpected end of input")
+	}
+
+	if p.src[pos] == '\\' {
+		switch p.at(pos + 1) {
+		case 'x':
+			return p.parseHex(pos+2, 2)
+		case 'u':
+			return p.parseHex(pos+2, 4)
+		case 'U':
+			return p.parseHex(pos+2, 8)
+		case 't':
+			return '\t', pos + 2, nil
+		case 'r':
+			return '\r', pos + 2, nil
+		case 'n':
+			return '\n', pos + 2, nil
+		case '\\', '"', '[', ']':
+			return uint32(p.src[pos+1]), pos + 2, nil
+		default:
+			return 0, 0, fmt.Errorf("unknown escape at %s", p.context(pos))
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[pos:])
+	return uint32(r), pos + size, nil
+}
+
+// parseSequence parses a sequence of symbols, appending them to elements
+func (p *parser) parseSequence(pos int, ruleName string, elements []Element, nested bool) (int, []Element, error) {
+	lastSymStart := len(elements)
+
+	for pos < len(p.src) {
+		c := p.src[pos]
+		switch {
+		case c == '"':
+			// Literal string
+			pos++
+			lastSymStart = len(elements)
+			for p.at(pos) != '"' {
+				char, next, err := p.parseChar(pos)
+				if err != nil {
+					return 0, nil, err
+				}
+				pos = next
+				elements = append(elements, Element{Char, char})
+			}
+			pos = p.parseSpace(pos+1, nested)
+
+		case c == '[':
+			// Character range(s)
+			pos++
+			startType := Char
+			if p.at(pos) == '^' {
+				pos++
+				startType = CharNot
+			}
+			lastSymStart = len(elements)
+			for p.at(pos) != ']' {
+				char, next, err := p.parseChar(pos)
+				if err != nil {
+					return 0, nil, err
+				}
+				pos = next
+
+				elementType := startType
+				if lastSymStart < len(elements) {
+					elementType = CharAlt
+				}
+				elements = append(elements, Element{elementType, char})
+
+				if p.at(pos) == '-' && p.at(pos+1) != ']' {
+					upper, next, err := p.parseChar(pos + 1)
+					if err != nil {
+						return 0, nil, err
+					}
+					pos = next
+					elements = append(elements, Element{CharRangeUpper, upper})
+				}
+			}
+			pos = p.parseSpace(pos+1, nested)
+
+		case isWordChar(c):
+			// Rule reference
+			end, err := p.parseName(pos)
+			if err != nil {
+				return 0, nil, err
+			}
+			refID := p.getSymbolID(p.src[pos:end])
+			pos = p.parseSpace(end, nested)
+			lastSymStart = len(elements)
+			elements = append(elements, Element{RuleRef, refID})
+
+		case c == '(':
+			// Grouping
+			pos = p.parseSpace(pos+1, true)
+			subRuleID := p.generateSymbolID(ruleName)
+			next, err := p.parseAlternates(pos, ruleName, subRuleID, true)
+			if err != nil {
+				return 0, nil, err
+			}
+			pos = next
+			lastSymStart = len(elements)
+			elements = append(elements, Element{RuleRef, subRuleID})
+			if p.at(pos) != ')' {
+				return 0, nil, fmt.Errorf("expecting ')' at %s", p.context(pos))
+			}
+			pos = p.parseSpace(pos+1, nested)
+
+		case c == '*' || c == '+' || c == '?':
+			// Repetition operator: rewrite the preceding symbol as a helper rule
+			if lastSymStart == len(elements) {
+				return 0, nil, fmt.Errorf("expecting preceding item to */+/? at %s", p.context(pos))
+			}
+
+			subRuleID := p.generateSymbolID(ruleName)
+			preceding := append([]Element(nil), elements[lastSymStart:]...)
+
+			subRule := append([]Element(nil), preceding...)
+			if c == '*' || c == '+' {
+				subRule = append(subRule, Element{RuleRef, subRuleID})
+			}
+			subRule = append(subRule, Element{Alt, 0})
+			if c == '+' {
+				subRule = append(subRule, preceding...)
+			}
+			subRule = append(subRule, Element{End, 0})
+			p.addRule(subRuleID, subRule)
+
+			elements = append(elements[:lastSymStart], Element{RuleRef, subRuleID})
+			pos = p.parseSpace(pos+1, nested)
+
+		default:
+			return pos, elements, nil
+		}
+	}
+
+	return pos, elements, nil
+}
+
+// parseAlternates parses alternates separated by '|' into the rule with the given ID
+func (p *parser) parseAlternates(pos int, ruleName string, ruleID uint32, nested bool) (int, error) {
+	pos, rule, err := p.parseSequence(pos, ruleName, nil, nested)
+	if err != nil {
+		return 0, err
+	}
+
+	for p.at(pos) == '|' {
+		rule = append(rule, Element{Alt, 0})
+		pos = p.parseSpace(pos+1, true)
+		if pos, rule, err = p.parseSequence(pos, ruleName, rule, nested); err != nil {
+			return 0, err
+		}
+	}
+
+	rule = append(rule, Element{End, 0})
+	p.addRule(ruleID, rule)
+	return pos, nil
+}
+
+// parseRule parses a single "name ::= alternates" rule definition
+func (p *parser) parseRule(pos int) (int, error) {
+	nameEnd, err := p.parseName(pos)
+	if err != nil {
+		return 0, err
+	}
+	name := p.src[pos:nameEnd]
+	ruleID := p.getSymbolID(name)
+
+	pos = p.parseSpace(nameEnd, false)
+	if !strings.HasPrefix(p.src[pos:], "::=") {
+		return 0, fmt.Errorf("expecting ::= at %s", p.context(pos))
+	}
+	pos = p.parseSpace(pos+3, true)
+
+	if pos, err = p.parseAlternates(pos, name, ruleID, false); err != nil {
+		return 0, err
+	}
+
+	switch p.at(pos) {
+	case '\r':
+		pos++
+		if p.at(pos) == '\n' {
+			pos++
+		}
+	case '\n':
+		pos++
+	case 0:
+	default:
+		return 0, fmt.Errorf("expecting newline or end at %s", p.context(pos))
+	}
+
+	return p.parseSpace(pos, true), nil
+}
+
+// context returns a short excerpt of the source at pos for error messages
+func (p *parser) context(pos int) string {
+	if pos >= len(p.src) {
+		return "end of input"
+	}
+	end := pos + 20
+	if end > len(p.src) {
+		end = len(p.src)
+	}
+	return strconv.Quote(p.src[pos:end])
+}
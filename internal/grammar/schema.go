@@ -0,0 +1,282 @@
+package grammar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// primitiveRules are the GBNF rules for JSON primitives shared by all schemas
+var primitiveRules = map[string]string{
+	"ws":      `([ \t\n] ws)?`,
+	"boolean": `("true" | "false") ws`,
+	"null":    `"null" ws`,
+	"integer": `("-"? ([0-9] | [1-9] [0-9]*)) ws`,
+	"number":  `("-"? ([0-9] | [1-9] [0-9]*)) ("." [0-9]+)? ([eE] [-+]? [0-9]+)? ws`,
+	"string": `"\"" (
+    [^"\\\x7F\x00-\x1F] |
+    "\\" (["\\/bfnrt] | "u" [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F])
+  )* "\"" ws`,
+
+	// Used for schemas that do not restrict the type of a value
+	"value":  `object | array | string | number | ("true" | "false" | "null") ws`,
+	"object": `"{" ws ( string ":" ws value ("," ws string ":" ws value)* )? "}" ws`,
+	"array":  `"[" ws ( value ("," ws value)* )? "]" ws`,
+}
+
+// primitiveDeps lists the rules each primitive rule refers to
+var primitiveDeps = map[string][]string{
+	"boolean": {"ws"},
+	"null":    {"ws"},
+	"integer": {"ws"},
+	"number":  {"ws"},
+	"string":  {"ws"},
+	"value":   {"object", "array", "string", "number", "ws"},
+	"object":  {"string", "value", "ws"},
+	"array":   {"value", "ws"},
+}
+
+var invalidRuleChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// jsonSchema is the subset of JSON Schema supported for constrained generation
+type jsonSchema struct {
+	Type       string            `json:"type"`
+	Properties json.RawMessage   `json:"properties"`
+	Required   []string          `json:"required"`
+	Items      json.RawMessage   `json:"items"`
+	Enum       []json.RawMessage `json:"enum"`
+	Const      json.RawMessage   `json:"const"`
+}
+
+// schemaConverter compiles a JSON Schema into GBNF rules
+type schemaConverter struct {
+	rules map[string]string
+	order []string
+}
+
+// FromJSONSchema compiles a JSON Schema into a GBNF grammar. Supported
+// keywords are type (object, array, string, number, integer, boolean, null),
+// properties, required, items, enum and const.
+func FromJSONSchema(schema []byte) (string, error) {
+	c := &schemaConverter{rules: make(map[string]string)}
+	rule, err := c.visit(schema, "root")
+	if err != nil {
+		return "", err
+	}
+	if rule != "root" {
+		c.addRule("root", rule)
+	}
+
+	var b strings.Builder
+	for _, name := range c.order {
+		fmt.Fprintf(&b, "%s ::= %s\n", name, c.rules[name])
+	}
+	return b.String(), nil
+}
+
+// addRule registers a rule under a unique name derived from name and returns that name
+func (c *schemaConverter) addRule(name, rule string) string {
+	key := invalidRuleChars.ReplaceAllString(name, "-")
+	if existing, ok := c.rules[key]; ok && existing != rule {
+		i := 0
+		for {
+			candidate := fmt.Sprintf("%s%d", key, i)
+			if existing, ok := c.rules[candidate]; !ok || existing == rule {
+				key = candidate
+				break
+			}
+			i++
+		}
+	}
+
+	if _, ok := c.rules[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.rules[key] = rule
+	return key
+}
+
+// addPrimitive registers a primitive rule and its dependencies
+func (c *schemaConverter) addPrimitive(name string) string {
+	if _, ok := c.rules[name]; ok {
+		return name
+	}
+	c.rules[name] = primitiveRules[name]
+	c.order = append(c.order, name)
+	for _, dep := range primitiveDeps[name] {
+		c.addPrimitive(dep)
+	}
+	return name
+}
+
+// visit compiles a schema and returns the name of the rule that matches it
+func (c *schemaConverter) visit(raw json.RawMessage, name string) (string, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return "", fmt.Errorf("invalid schema at %s: %w", name, err)
+	}
+
+	if schema.Const != nil {
+		c.addPrimitive("ws")
+		return c.addRule(name, literal(schema.Const)+" ws"), nil
+	}
+
+	if len(schema.Enum) > 0 {
+		c.addPrimitive("ws")
+		alternatives := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			alternatives[i] = literal(value)
+		}
+		return c.addRule(name, "("+strings.Join(alternatives, " | ")+") ws"), nil
+	}
+
+	schemaType := schema.Type
+	if schemaType == "" && schema.Properties != nil {
+		schemaType = "object"
+	}
+
+	switch schemaType {
+	case "object":
+		return c.visitObject(&schema, name)
+	case "array":
+		return c.visitArray(&schema, name)
+	case "string", "number", "integer", "boolean", "null":
+		return c.addPrimitive(schemaType), nil
+	case "":
+		return c.addPrimitive("value"), nil
+	default:
+		return "", fmt.Errorf("unsupported schema type %q at %s", schemaType, name)
+	}
+}
+
+// visitObject compiles an object schema. Required properties are emitted in
+// declaration order followed by any optional properties.
+func (c *schemaConverter) visitObject(schema *jsonSchema, name string) (string, error) {
+	c.addPrimitive("ws")
+
+	if schema.Properties == nil {
+		return c.addPrimitive("object"), nil
+	}
+
+	propNames, err := objectKeys(schema.Properties)
+	if err != nil {
+		return "", fmt.Errorf("invalid properties at %s: %w", name, err)
+	}
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(schema.Properties, &props); err != nil {
+		return "", fmt.Errorf("invalid properties at %s: %w", name, err)
+	}
+
+	required := make(map[string]bool)
+	for _, prop := range schema.Required {
+		if _, ok := props[prop]; !ok {
+			return "", fmt.Errorf("required property %q is not defined at %s", prop, name)
+		}
+		required[prop] = true
+	}
+
+	// Compile each property to a "key": value rule
+	kvRules := make(map[string]string)
+	for _, prop := range propNames {
+		valueRule, err := c.visit(props[prop], name+"-"+prop)
+		if err != nil {
+			return "", err
+		}
+		key, _ := json.Marshal(prop)
+		kvRules[prop] = c.addRule(name+"-"+prop+"-kv", literal(key)+` ws ":" ws `+valueRule)
+	}
+
+	var requiredRules, optionalRules []string
+	for _, prop := range propNames {
+		if required[prop] {
+			requiredRules = append(requiredRules, kvRules[prop])
+		} else {
+			optionalRules = append(optionalRules, kvRules[prop])
+		}
+	}
+
+	rule := `"{" ws `
+	if len(requiredRules) > 0 {
+		rule += strings.Join(requiredRules, ` "," ws `)
+		for _, opt := range optionalRules {
+			rule += ` ( "," ws ` + opt + ` )?`
+		}
+	} else if len(optionalRules) > 0 {
+		rule += "( " + optionalSequence(optionalRules) + " )?"
+	}
+	rule += ` "}" ws`
+
+	return c.addRule(name, rule), nil
+}
+
+// visitArray compiles an array schema
+func (c *schemaConverter) visitArray(schema *jsonSchema, name string) (string, error) {
+	c.addPrimitive("ws")
+
+	if schema.Items == nil {
+		return c.addPrimitive("array"), nil
+	}
+
+	itemRule, err := c.visit(schema.Items, name+"-item")
+	if err != nil {
+		return "", err
+	}
+	return c.addRule(name, `"[" ws ( `+itemRule+` ( "," ws `+itemRule+` )* )? "]" ws`), nil
+}
+
+// optionalSequence builds alternatives that match any non-empty, ordered
+// subset of rules separated by commas
+func optionalSequence(rules []string) string {
+	alternatives := make([]string, len(rules))
+	for i := range rules {
+		alt := rules[i]
+		for _, rest := range rules[i+1:] {
+			alt += ` ( "," ws ` + rest + ` )?`
+		}
+		alternatives[i] = alt
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return "( " + strings.Join(alternatives, " ) | ( ") + " )"
+}
+
+// literal returns a GBNF string literal matching the compact JSON encoding of value
+func literal(value json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		compact.Write(value)
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(compact.String()) + `"`
+}
+
+// objectKeys returns the keys of a JSON object in declaration order
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
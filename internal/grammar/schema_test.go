@@ -0,0 +1,82 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromJSONSchemaPerson(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer"},
+			"email": {"type": "string"},
+			"role": {"enum": ["admin", "user"]}
+		},
+		"required": ["name", "age"]
+	}`
+
+	src, err := FromJSONSchema([]byte(schema))
+	if err != nil {
+		t.Fatalf("FromJSONSchema: %v", err)
+	}
+	if _, err := Parse(src); err != nil {
+		t.Fatalf("generated grammar does not parse: %v\n%s", err, src)
+	}
+
+	// Required properties come first, in order, and the optional ones follow
+	for _, want := range []string{
+		`root ::= "{" ws root-name-kv "," ws root-age-kv ( "," ws root-email-kv )? ( "," ws root-role-kv )? "}" ws`,
+		`root-name-kv ::= "\"name\"" ws ":" ws string`,
+		`root-age-kv ::= "\"age\"" ws ":" ws integer`,
+		`root-role ::= ("\"admin\"" | "\"user\"") ws`,
+	} {
+		if !strings.Contains(src, want+"\n") {
+			t.Errorf("grammar lacks rule %s\n%s", want, src)
+		}
+	}
+}
+
+func TestFromJSONSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:    "not JSON",
+			schema:  `{"type": `,
+			wantErr: "invalid schema at root",
+		},
+		{
+			name:    "unsupported type",
+			schema:  `{"type": "date"}`,
+			wantErr: `unsupported schema type "date" at root`,
+		},
+		{
+			name:    "unsupported nested type",
+			schema:  `{"type": "array", "items": {"type": "tuple"}}`,
+			wantErr: `unsupported schema type "tuple" at root-item`,
+		},
+		{
+			name:    "undefined required property",
+			schema:  `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["age"]}`,
+			wantErr: `required property "age" is not defined at root`,
+		},
+		{
+			name:    "properties not an object",
+			schema:  `{"type": "object", "properties": ["name"]}`,
+			wantErr: "invalid properties at root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromJSONSchema([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
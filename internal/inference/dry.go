@@ -0,0 +1,90 @@
+package inference
+
+import (
+	"math"
+
+	"colossus-cli/internal/llama"
+)
+
+// Default DRY ("Don't Repeat Yourself") penalty parameters. The penalty is
+// off unless a request sets dry_multiplier.
+const (
+	defaultDRYBase          = float32(1.75)
+	defaultDRYAllowedLength = 2
+)
+
+// dryPenalties returns the amount to subtract from the logit of each token
+// that would continue a sequence already in history. A token that would make
+// the last n tokens of history, followed by itself, repeat an earlier
+// sequence is penalized by multiplier * base^(n - allowedLength), for the
+// longest such n of at least allowedLength.
+//
+// Unlike the repetition penalties, which penalize single tokens, this stops
+// the model from repeating whole phrases and paragraphs while leaving short
+// common sequences alone.
+func dryPenalties(history []llama.Token, multiplier, base float32, allowedLength int) map[llama.Token]float32 {
+	n := len(history)
+	if multiplier <= 0 || n < 2 {
+		return nil
+	}
+
+	// match[i] is the length of the longest common suffix of history and
+	// history[:n-1-i], found with the Z-function of the reversed history.
+	// The token following that earlier suffix, history[n-1-i], continues it.
+	reversed := make([]llama.Token, n)
+	for i, token := range history {
+		reversed[n-1-i] = token
+	}
+	match := zFunction(reversed)
+
+	longest := make(map[llama.Token]int)
+	for i := 1; i < n; i++ {
+		length := match[i]
+		if length < allowedLength {
+			continue
+		}
+		next := history[n-i]
+		if length > longest[next] {
+			longest[next] = length
+		}
+	}
+	if len(longest) == 0 {
+		return nil
+	}
+
+	penalties := make(map[llama.Token]float32, len(longest))
+	for token, length := range longest {
+		penalty := float64(multiplier) * math.Pow(float64(base), float64(length-allowedLength))
+		// Long repetitions would overflow the logits otherwise
+		penalties[token] = float32(math.Min(penalty, math.MaxFloat32/2))
+	}
+	return penalties
+}
+
+// zFunction returns, for every position i of s, the length of the longest
+// common prefix of s and s[i:]. z[0] is 0.
+func zFunction(s []llama.Token) []int {
+	z := make([]int, len(s))
+	for i, l, r := 1, 0, 0; i < len(s); i++ {
+		if i < r {
+			z[i] = min(r-i, z[i-l])
+		}
+		for i+z[i] < len(s) && s[z[i]] == s[i+z[i]] {
+			z[i]++
+		}
+		if i+z[i] > r {
+			l, r = i, i+z[i]
+		}
+	}
+	return z
+}
+
+// applyDRYPenalties subtracts the DRY penalties for history from logits. It
+// is used where sampling happens in Go rather than in llama.cpp.
+func applyDRYPenalties(logits []float32, history []llama.Token, multiplier, base float32, allowedLength int) {
+	for token, penalty := range dryPenalties(history, multiplier, base, allowedLength) {
+		if int(token) >= 0 && int(token) < len(logits) {
+			logits[token] -= penalty
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package inference
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/types"
+)
+
+func TestDRYPenalties(t *testing.T) {
+	tests := []struct {
+		name          string
+		history       []llama.Token
+		multiplier    float32
+		base          float32
+		allowedLength int
+		want          map[llama.Token]float32
+	}{
+		{
+			name:    "repeated bigram at the allowed length",
+			history: []llama.Token{1, 2, 3, 1, 2}, multiplier: 0.8, base: 1.75, allowedLength: 2,
+			want: map[llama.Token]float32{3: 0.8},
+		},
+		{
+			name:    "longer repetition grows exponentially",
+			history: []llama.Token{1, 2, 3, 4, 9, 1, 2, 3}, multiplier: 0.8, base: 1.75, allowedLength: 2,
+			want: map[llama.Token]float32{4: 0.8 * 1.75},
+		},
+		{
+			name:    "longest match per token",
+			history: []llama.Token{5, 1, 2, 7, 8, 1, 2, 6, 5, 1, 2}, multiplier: 1, base: 2, allowedLength: 2,
+			want: map[llama.Token]float32{7: 2, 6: 1},
+		},
+		{
+			name:    "repetitions shorter than the allowed length",
+			history: []llama.Token{1, 2, 3, 1, 2}, multiplier: 1, base: 2, allowedLength: 3,
+			want: nil,
+		},
+		{
+			name:    "disabled",
+			history: []llama.Token{1, 2, 3, 1, 2}, multiplier: 0, base: 2, allowedLength: 2,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dryPenalties(tt.history, tt.multiplier, tt.base, tt.allowedLength)
+			if len(got) != len(tt.want) {
+				t.Fatalf("dryPenalties() = %v, want %v", got, tt.want)
+			}
+			for token, want := range tt.want {
+				if math.Abs(float64(got[token]-want)) > 1e-6 {
+					t.Errorf("penalty of %d = %v, want %v", token, got[token], want)
+				}
+			}
+		})
+	}
+}
+
+func TestZFunction(t *testing.T) {
+	got := zFunction([]llama.Token{1, 1, 2, 1, 1, 2, 1})
+	if want := []int{0, 1, 0, 4, 1, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("zFunction() = %v, want %v", got, want)
+	}
+}
+
+// longestRepeat returns the length of the longest sequence of words that
+// occurs more than once in words
+func longestRepeat(words []string) int {
+	longest := 0
+	for i := range words {
+		for j := i + 1; j < len(words); j++ {
+			n := 0
+			for j+n < len(words) && words[i+n] == words[j+n] {
+				n++
+			}
+			longest = max(longest, n)
+		}
+	}
+	return longest
+}
+
+func TestSimulateWordsDRY(t *testing.T) {
+	prompt := "hello"
+	// A negative presence penalty makes the simulated model repeat itself
+	looping := &types.Options{PresencePenalty: -5}
+
+	words, _, err := simulateWords(prompt, looping)
+	if err != nil {
+		t.Fatalf("simulateWords: %v", err)
+	}
+	without := longestRepeat(words)
+	if without < len(words)/2 {
+		t.Fatalf("the looping model does not repeat itself: %q", strings.Join(words, ""))
+	}
+
+	dry := *looping
+	dry.DRYMultiplier, dry.DRYBase, dry.DRYAllowedLength = 1, 2, 2
+	words, _, err = simulateWords(prompt, &dry)
+	if err != nil {
+		t.Fatalf("simulateWords: %v", err)
+	}
+	if with := longestRepeat(words); with >= without || with > 4 {
+		t.Errorf("longest repetition = %d words with DRY, %d without: %q", with, without, strings.Join(words, ""))
+	}
+
+	// The scripted response repeats no phrase, so DRY leaves it as it is
+	words, _, err = simulateWords(prompt, &types.Options{DRYMultiplier: 2})
+	if err != nil {
+		t.Fatalf("simulateWords: %v", err)
+	}
+	if got, want := strings.Join(words, ""), simulateResponse(prompt); got != want {
+		t.Errorf("DRY changed a response without repetitions: %q, want %q", got, want)
+	}
+
+	if _, _, err := simulateWords(prompt, &types.Options{DRYMultiplier: 1, DRYBase: 0.5}); err == nil {
+		t.Error("simulateWords accepted a dry_base below 1")
+	}
+}
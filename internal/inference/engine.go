@@ -1,18 +1,40 @@
 package inference
 
 import (
+	"context"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
+	"colossus-cli/internal/llama"
 	"colossus-cli/internal/types"
+)
+
+// simulatedEmbeddingSize is the dimension of embeddings returned by the simulated engine
+const simulatedEmbeddingSize = 4096
 
-	"github.com/sirupsen/logrus"
+// Logits the simulated engine gives the next word of its scripted response
+// and every other word of the response. The gap is small enough for the
+// sampling options to change which word is chosen.
+const (
+	simulatedScriptLogit = float32(3)
+	simulatedOtherLogit  = float32(1)
 )
 
+// simulatedFillerWords extend the vocabulary of the simulated engine beyond
+// its scripted response, so penalised words can be replaced
+var simulatedFillerWords = []string{"also ", "indeed ", "really ", "just ", "still ", "then ", "so ", "well "}
+
+// simulatedVocabSize bounds the token IDs returned by the simulated engine
+const simulatedVocabSize = 32000
+
 // SimulatedEngine handles simulated model inference (for demo/testing)
 type SimulatedEngine struct {
 	models map[string]*LoadedModel
+	mutex  sync.RWMutex
 }
 
 // LoadedModel represents a model loaded in memory
@@ -34,12 +56,15 @@ func NewSimulatedEngine() *SimulatedEngine {
 
 // LoadModel loads a model into memory with options
 func (e *SimulatedEngine) LoadModel(name, path string, options *ModelOptions) error {
-	logrus.Infof("Loading model: %s from %s", name, path)
+	logger.Infof("Loading model: %s from %s", name, path)
 	
 	if options == nil {
 		options = DefaultModelOptions()
 	}
 	
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
 	// For demo purposes, we simulate loading
 	e.models[name] = &LoadedModel{
 		Name:     name,
@@ -53,49 +78,64 @@ func (e *SimulatedEngine) LoadModel(name, path string, options *ModelOptions) er
 			Parameters:  7000000000, // 7B parameters
 			GPULayers:   options.GPULayers,
 			MemoryUsed:  4000000000, // 4GB simulated
+			
+			ActualGPULayers: options.GPULayers,
 		},
 	}
 	
-	logrus.Infof("Model %s loaded successfully", name)
+	logger.Infof("Model %s loaded successfully", name)
 	return nil
 }
 
 // UnloadModel removes a model from memory
 func (e *SimulatedEngine) UnloadModel(name string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
 	if _, exists := e.models[name]; !exists {
 		return fmt.Errorf("model not loaded: %s", name)
 	}
 	
 	delete(e.models, name)
-	logrus.Infof("Model %s unloaded", name)
+	logger.Infof("Model %s unloaded", name)
 	return nil
 }
 
 // IsModelLoaded checks if a model is loaded
 func (e *SimulatedEngine) IsModelLoaded(name string) bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
 	_, exists := e.models[name]
 	return exists
 }
 
 // Generate generates text using a loaded model
-func (e *SimulatedEngine) Generate(req *types.GenerateRequest) (*types.GenerateResponse, error) {
+func (e *SimulatedEngine) Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
 	if !e.IsModelLoaded(req.Model) {
 		return nil, fmt.Errorf("model not loaded: %s", req.Model)
 	}
 	
 	// For demo purposes, we simulate a response
-	response := simulateResponse(req.Prompt)
+	start := time.Now()
+	words, logprobs, err := simulateWords(req.Prompt, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	response := strings.Join(words, "")
 	
 	return &types.GenerateResponse{
-		Model:     req.Model,
-		CreatedAt: time.Now(),
-		Response:  response,
-		Done:      true,
+		Model:            req.Model,
+		CreatedAt:        time.Now(),
+		Response:         response,
+		Done:             true,
+		Logprobs:         logprobs,
+		InferenceMetrics: simulatedMetrics(req.Prompt, response, start),
 	}, nil
 }
 
 // Chat handles chat completion using a loaded model
-func (e *SimulatedEngine) Chat(req *types.ChatRequest) (*types.ChatResponse, error) {
+func (e *SimulatedEngine) Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error) {
 	if !e.IsModelLoaded(req.Model) {
 		return nil, fmt.Errorf("model not loaded: %s", req.Model)
 	}
@@ -104,7 +144,11 @@ func (e *SimulatedEngine) Chat(req *types.ChatRequest) (*types.ChatResponse, err
 	prompt := e.formatChatPrompt(req.Messages)
 	
 	// Generate response
-	response := simulateResponse(prompt)
+	words, logprobs, err := simulateWords(prompt, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	response := strings.Join(words, "")
 	
 	return &types.ChatResponse{
 		Model:     req.Model,
@@ -113,10 +157,22 @@ func (e *SimulatedEngine) Chat(req *types.ChatRequest) (*types.ChatResponse, err
 			Role:    "assistant",
 			Content: response,
 		},
-		Done: true,
+		Done:     true,
+		Logprobs: logprobs,
 	}, nil
 }
 
+// simulatedMetrics reports a simulated generation, counting words as tokens
+func simulatedMetrics(prompt, response string, start time.Time) *types.InferenceMetrics {
+	elapsed := time.Since(start)
+	return &types.InferenceMetrics{
+		TotalDuration:   elapsed,
+		PromptEvalCount: len(strings.Fields(prompt)),
+		EvalCount:       len(strings.Fields(response)),
+		EvalDuration:    elapsed,
+	}
+}
+
 // simulateResponse generates a simulated response (for demo purposes)
 func simulateResponse(prompt string) string {
 	// Enhanced simulation with more realistic responses
@@ -188,22 +244,110 @@ func simulateResponse(prompt string) string {
 	return fallbacks[hash%len(fallbacks)]
 }
 
+// simulateWords generates the simulated response to prompt one word at a
+// time. Each word is a token chosen from logits that favour the next word of
+// the scripted response, after applying the sampling options, so penalties
+// and seeds change the output as they would with a real model. It also
+// returns the log probabilities of the words when the options request them.
+func simulateWords(prompt string, options *types.Options) ([]string, []types.TokenLogprob, error) {
+	params, err := resolveSamplingParams(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	// The vocabulary is the filler words followed by the distinct words of
+	// the script, so ties between unscripted words go to the fillers
+	script := splitIntoWords(simulateResponse(prompt))
+	vocab := append([]string(nil), simulatedFillerWords...)
+	ids := make(map[string]llama.Token)
+	for i, word := range vocab {
+		ids[word] = llama.Token(i)
+	}
+	for _, word := range script {
+		if _, exists := ids[word]; !exists {
+			ids[word] = llama.Token(len(vocab))
+			vocab = append(vocab, word)
+		}
+	}
+	
+	// Sample with a seeded RNG when the request sets a seed, and otherwise
+	// pick the most likely word so that unseeded responses are predictable
+	var rng *rand.Rand
+	if params.Seed != -1 {
+		rng = rand.New(rand.NewSource(params.Seed))
+	}
+	
+	words := make([]string, 0, len(script))
+	var logprobs []types.TokenLogprob
+	var lastTokens, dryTokens []llama.Token
+	for _, word := range script {
+		logits := make([]float32, len(vocab))
+		for i := range logits {
+			logits[i] = simulatedOtherLogit
+		}
+		logits[ids[word]] = simulatedScriptLogit
+		// Log probabilities are reported from the logits before penalties,
+		// like those of the llama.cpp engine
+		raw := append([]float32(nil), logits...)
+		
+		applyRepetitionPenalties(logits, lastTokens, params.RepeatPenalty, params.FrequencyPenalty, params.PresencePenalty)
+		applyDRYPenalties(logits, dryTokens, params.DRYMultiplier, params.DRYBase, params.DRYAllowedLength)
+		token := greedyToken(logits)
+		if rng != nil {
+			token = sampleLogits(logits, params.Temperature, rng)
+		}
+		
+		if params.Logprobs > 0 {
+			logprob, err := logitLogprobs(raw, token, params.Logprobs, func(t llama.Token) string { return vocab[t] })
+			if err != nil {
+				return nil, nil, err
+			}
+			logprobs = append(logprobs, logprob)
+		}
+		
+		words = append(words, vocab[token])
+		lastTokens = append(lastTokens, token)
+		if len(lastTokens) > params.RepeatLastN {
+			lastTokens = lastTokens[1:]
+		}
+		if params.DRYMultiplier > 0 {
+			dryTokens = append(dryTokens, token)
+			if n := params.DRYPenaltyLastN; n > 0 && len(dryTokens) > n {
+				dryTokens = dryTokens[1:]
+			}
+		}
+	}
+	
+	return words, logprobs, nil
+}
+
 // GenerateStream generates text with streaming support
-func (e *SimulatedEngine) GenerateStream(req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
+func (e *SimulatedEngine) GenerateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
 	if !e.IsModelLoaded(req.Model) {
 		return fmt.Errorf("model not loaded: %s", req.Model)
 	}
 	
-	response := simulateResponse(req.Prompt)
-	words := splitIntoWords(response)
+	start := time.Now()
+	words, _, err := simulateWords(req.Prompt, req.Options)
+	if err != nil {
+		return err
+	}
+	response := strings.Join(words, "")
 	
 	for i, word := range words {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
 		resp := &types.GenerateResponse{
 			Model:     req.Model,
 			CreatedAt: time.Now(),
 			Response:  word,
 			Done:      i == len(words)-1,
 		}
+		if resp.Done {
+			resp.InferenceMetrics = simulatedMetrics(req.Prompt, response, start)
+		}
 		
 		if err := callback(resp); err != nil {
 			return err
@@ -214,16 +358,22 @@ func (e *SimulatedEngine) GenerateStream(req *types.GenerateRequest, callback fu
 }
 
 // ChatStream handles chat completion with streaming support
-func (e *SimulatedEngine) ChatStream(req *types.ChatRequest, callback func(*types.ChatResponse) error) error {
+func (e *SimulatedEngine) ChatStream(ctx context.Context, req *types.ChatRequest, callback func(*types.ChatResponse) error) error {
 	if !e.IsModelLoaded(req.Model) {
 		return fmt.Errorf("model not loaded: %s", req.Model)
 	}
 	
 	prompt := e.formatChatPrompt(req.Messages)
-	response := simulateResponse(prompt)
-	words := splitIntoWords(response)
+	words, _, err := simulateWords(prompt, req.Options)
+	if err != nil {
+		return err
+	}
 	
 	for i, word := range words {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
 		resp := &types.ChatResponse{
 			Model:     req.Model,
 			CreatedAt: time.Now(),
@@ -242,8 +392,58 @@ func (e *SimulatedEngine) ChatStream(req *types.ChatRequest, callback func(*type
 	return nil
 }
 
+// Embed returns a zero vector of a fixed dimension
+func (e *SimulatedEngine) Embed(ctx context.Context, req *types.EmbedRequest) (*types.EmbedResponse, error) {
+	if !e.IsModelLoaded(req.Model) {
+		return nil, fmt.Errorf("model not loaded: %s", req.Model)
+	}
+	
+	return &types.EmbedResponse{
+		Model:     req.Model,
+		Embedding: make([]float32, simulatedEmbeddingSize),
+	}, nil
+}
+
+// Tokenize approximates tokenization by splitting the prompt on whitespace
+func (e *SimulatedEngine) Tokenize(req *types.TokenizeRequest) (*types.TokenizeResponse, error) {
+	if !e.IsModelLoaded(req.Model) {
+		return nil, fmt.Errorf("model not loaded: %s", req.Model)
+	}
+	
+	words := strings.Fields(req.Prompt)
+	ids := make([]int, len(words))
+	for i, word := range words {
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		ids[i] = int(hash.Sum32() % simulatedVocabSize)
+	}
+	
+	return &types.TokenizeResponse{
+		Tokens: ids,
+		Count:  len(ids),
+	}, nil
+}
+
+// SetSessionStore is a no-op: the simulated engine has no KV cache to persist
+func (e *SimulatedEngine) SetSessionStore(store *SessionStore) {}
+
+// SetPrefillDir is a no-op: the simulated engine has no KV cache to save
+func (e *SimulatedEngine) SetPrefillDir(dir string) {}
+
+// PrefillContext only checks that the model is loaded: the simulated engine
+// has no KV cache to prefill
+func (e *SimulatedEngine) PrefillContext(modelName, systemPrompt string) error {
+	if !e.IsModelLoaded(modelName) {
+		return fmt.Errorf("model not loaded: %s", modelName)
+	}
+	return nil
+}
+
 // GetModelInfo returns information about a loaded model
 func (e *SimulatedEngine) GetModelInfo(name string) (*ModelInfo, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
 	model, exists := e.models[name]
 	if !exists {
 		return nil, fmt.Errorf("model not loaded: %s", name)
@@ -252,13 +452,29 @@ func (e *SimulatedEngine) GetModelInfo(name string) (*ModelInfo, error) {
 	return model.Info, nil
 }
 
+// LoadedModels returns information about all loaded models
+func (e *SimulatedEngine) LoadedModels() []*ModelInfo {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	infos := make([]*ModelInfo, 0, len(e.models))
+	for _, model := range e.models {
+		infos = append(infos, model.Info)
+	}
+	return infos
+}
+
 // Shutdown gracefully shuts down the inference engine
 func (e *SimulatedEngine) Shutdown() error {
-	logrus.Info("Shutting down simulated inference engine")
+	logger.Info("Shutting down simulated inference engine")
+	
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 	
 	// Unload all models
 	for name := range e.models {
-		e.UnloadModel(name)
+		delete(e.models, name)
+		logger.Infof("Model %s unloaded", name)
 	}
 	
 	return nil
@@ -298,6 +514,8 @@ func (e *SimulatedEngine) formatChatPrompt(messages []types.Message) string {
 			parts = append(parts, "Assistant: "+msg.Content)
 		case "system":
 			parts = append(parts, "System: "+msg.Content)
+		case "tool":
+			parts = append(parts, "Tool: "+msg.Content)
 		}
 	}
 	
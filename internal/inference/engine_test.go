@@ -0,0 +1,70 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"colossus-cli/internal/types"
+)
+
+func TestStreamStopsWhenCancelled(t *testing.T) {
+	engine := NewSimulatedEngine()
+	if err := engine.LoadModel("tinyllama", "tinyllama.gguf", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := "tell me about llamas"
+	if n := len(splitIntoWords(simulateResponse(prompt))); n <= 3 {
+		t.Fatalf("response has %d tokens, want more than 3", n)
+	}
+
+	tests := []struct {
+		name   string
+		stream func(ctx context.Context, token func()) error
+	}{
+		{
+			name: "generate",
+			stream: func(ctx context.Context, token func()) error {
+				req := &types.GenerateRequest{Model: "tinyllama", Prompt: prompt}
+				return engine.GenerateStream(ctx, req, func(*types.GenerateResponse) error {
+					token()
+					return nil
+				})
+			},
+		},
+		{
+			name: "chat",
+			stream: func(ctx context.Context, token func()) error {
+				req := &types.ChatRequest{Model: "tinyllama", Messages: []types.Message{{Role: "user", Content: prompt}}}
+				return engine.ChatStream(ctx, req, func(*types.ChatResponse) error {
+					token()
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// The request is cancelled once 3 tokens have been generated
+			tokens := 0
+			err := tt.stream(ctx, func() {
+				tokens++
+				if tokens == 3 {
+					cancel()
+				}
+			})
+
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want %v", err, context.Canceled)
+			}
+			if tokens != 3 {
+				t.Errorf("generated %d tokens, want 3", tokens)
+			}
+		})
+	}
+}
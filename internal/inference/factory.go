@@ -7,8 +7,6 @@ import (
 
 	"colossus-cli/internal/gpu"
 	"colossus-cli/internal/llama"
-
-	"github.com/sirupsen/logrus"
 )
 
 // EngineType represents the type of inference engine
@@ -23,11 +21,11 @@ const (
 func NewEngine(engineType EngineType) InferenceEngine {
 	switch engineType {
 	case EngineTypeSimulated:
-		logrus.Warn("Using simulated inference engine - for testing only")
+		logger.Warn("Using simulated inference engine - for testing only")
 		return NewSimulatedEngine()
 	default:
 		// Always try llama.cpp for real inference
-		logrus.Info("Creating llama.cpp inference engine")
+		logger.Info("Creating llama.cpp inference engine")
 		return NewLlamaCppEngine()
 	}
 }
@@ -38,7 +36,7 @@ func GetEngineTypeFromEnv() EngineType {
 	
 	switch engineType {
 	case "simulated", "demo", "test":
-		logrus.Warn("Simulated engine explicitly requested - this is for testing only")
+		logger.Warn("Simulated engine explicitly requested - this is for testing only")
 		return EngineTypeSimulated
 	default:
 		// Default to llama.cpp for real inference
@@ -57,13 +55,13 @@ func isLlamaCppAvailable() bool {
 	if _, err := os.Stat("third_party/llama.cpp"); err == nil {
 		// For Windows development, assume available if directory exists
 		// In production, this would check for compiled library
-		logrus.Info("llama.cpp source available, enabling llamacpp engine")
+		logger.Info("llama.cpp source available, enabling llamacpp engine")
 		return true
 	}
 	
 	// Try to initialize llama.cpp to check if it's available
 	if err := llama.Initialize(); err != nil {
-		logrus.Debugf("llama.cpp not available: %v", err)
+		logger.Debugf("llama.cpp not available: %v", err)
 		return false
 	}
 	
@@ -89,37 +87,65 @@ func GetDefaultModelOptions(engineType EngineType) *ModelOptions {
 			case gpu.GPUTypeCUDA:
 				options.UseCUDA = true
 				options.GPULayers = gpu.GetOptimalGPULayers(gpuInfo, 7000000000) // Assume 7B model
-				logrus.Infof("Configured CUDA acceleration with %d GPU layers", options.GPULayers)
+				logger.Infof("Configured CUDA acceleration with %d GPU layers", options.GPULayers)
 				
 			case gpu.GPUTypeROCm:
 				options.UseROCm = true
 				options.GPULayers = gpu.GetOptimalGPULayers(gpuInfo, 7000000000)
-				logrus.Infof("Configured ROCm acceleration with %d GPU layers", options.GPULayers)
+				logger.Infof("Configured ROCm acceleration with %d GPU layers", options.GPULayers)
+				
+			case gpu.GPUTypeSYCL:
+				// Requires a llama.cpp build with GGML_USE_SYCL
+				options.UseSYCL = true
+				options.GPULayers = gpu.GetOptimalGPULayers(gpuInfo, 7000000000)
+				logger.Infof("Configured SYCL acceleration with %d GPU layers", options.GPULayers)
 				
 			case gpu.GPUTypeMetal:
 				// Metal support would be implemented here
-				logrus.Info("Metal GPU detected but not yet supported")
+				logger.Info("Metal GPU detected but not yet supported")
 				
 			default:
-				logrus.Info("GPU detected but not supported for acceleration")
+				logger.Info("GPU detected but not supported for acceleration")
+			}
+			
+			// The first request would otherwise wait for the GPU to be set up
+			options.WarmUp = options.UseCUDA || options.UseROCm || options.UseSYCL
+			
+			// Spread the model over multiple GPUs in proportion to their free memory
+			if split := gpu.GetTensorSplit(gpuInfo); split != nil {
+				options.TensorSplit = split
+				logger.Infof("Splitting model across %d GPUs: %v", len(split), split)
 			}
 		} else {
-			logrus.Info("No GPU acceleration available, using CPU only")
+			logger.Info("No GPU acceleration available, using CPU only")
 		}
 		
 		// Allow environment variable overrides
 		if envLayers := os.Getenv("COLOSSUS_GPU_LAYERS"); envLayers != "" {
 			if layers, err := parseInt(envLayers); err == nil {
 				options.GPULayers = layers
-				logrus.Infof("GPU layers overridden by environment: %d", layers)
+				logger.Infof("GPU layers overridden by environment: %d", layers)
 			}
 		}
+		if numaMode := os.Getenv("COLOSSUS_NUMA_MODE"); numaMode != "" {
+			options.NUMAMode = numaMode
+			logger.Infof("NUMA mode set by environment: %s", numaMode)
+		}
 		
 	case EngineTypeSimulated:
 		// Keep defaults for simulated engine
 		break
 	}
 	
+	if envStrategy := os.Getenv("COLOSSUS_CONTEXT_OVERFLOW"); envStrategy != "" {
+		switch strategy := ContextOverflowStrategy(strings.ToLower(envStrategy)); strategy {
+		case ErrorOnOverflow, TruncateLeft, TruncateRight:
+			options.ContextOverflowStrategy = strategy
+		default:
+			logger.Warnf("Ignoring unknown context overflow strategy: %s", envStrategy)
+		}
+	}
+	
 	return options
 }
 
@@ -1,6 +1,16 @@
 package inference
 
-import "colossus-cli/internal/types"
+import (
+	"context"
+	"time"
+
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/types"
+)
+
+// logger logs with the inference component
+var logger = logging.Component("inference")
 
 // InferenceEngine defines the interface for model inference
 type InferenceEngine interface {
@@ -14,24 +24,53 @@ type InferenceEngine interface {
 	IsModelLoaded(name string) bool
 	
 	// Generate generates text using a loaded model
-	Generate(req *types.GenerateRequest) (*types.GenerateResponse, error)
+	Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error)
 	
-	// GenerateStream generates text with streaming support
-	GenerateStream(req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error
+	// GenerateStream generates text with streaming support. Generation stops
+	// with ctx.Err() when ctx is cancelled.
+	GenerateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error
 	
 	// Chat handles chat completion using a loaded model
-	Chat(req *types.ChatRequest) (*types.ChatResponse, error)
+	Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error)
 	
 	// ChatStream handles chat completion with streaming support
-	ChatStream(req *types.ChatRequest, callback func(*types.ChatResponse) error) error
+	ChatStream(ctx context.Context, req *types.ChatRequest, callback func(*types.ChatResponse) error) error
+	
+	// Embed computes an embedding vector for the input text
+	Embed(ctx context.Context, req *types.EmbedRequest) (*types.EmbedResponse, error)
+	
+	// Tokenize converts a prompt to token IDs without running inference
+	Tokenize(req *types.TokenizeRequest) (*types.TokenizeResponse, error)
+	
+	// SetSessionStore sets where KV cache sessions are persisted between requests
+	SetSessionStore(store *SessionStore)
+	
+	// SetPrefillDir sets where the KV cache snapshots of prefilled system
+	// prompts are saved
+	SetPrefillDir(dir string)
+	
+	// PrefillContext evaluates a system prompt for a loaded model once, so
+	// that requests starting with it skip evaluating it again
+	PrefillContext(modelName, systemPrompt string) error
 	
 	// GetModelInfo returns information about a loaded model
 	GetModelInfo(name string) (*ModelInfo, error)
 	
+	// LoadedModels returns information about all loaded models
+	LoadedModels() []*ModelInfo
+	
 	// Shutdown gracefully shuts down the inference engine
 	Shutdown() error
 }
 
+// BatchGenerator is implemented by engines that generate responses to several
+// requests for the same model together, with higher throughput than one
+// request at a time. Other engines serve batches sequentially.
+type BatchGenerator interface {
+	// GenerateBatch returns the responses to reqs, in order
+	GenerateBatch(ctx context.Context, reqs []*types.GenerateRequest) ([]*types.GenerateResponse, error)
+}
+
 // ModelOptions represents options for loading a model
 type ModelOptions struct {
 	// Context size
@@ -43,6 +82,10 @@ type ModelOptions struct {
 	// Number of threads
 	Threads int `json:"threads"`
 	
+	// Placement of the threads on the NUMA nodes of multi-socket machines:
+	// "distribute", "isolate", "numactl", or empty to leave it to the OS
+	NUMAMode string `json:"numa_mode,omitempty"`
+	
 	// Batch size
 	BatchSize int `json:"batch_size"`
 	
@@ -55,14 +98,85 @@ type ModelOptions struct {
 	// Low VRAM mode
 	LowVRAM bool `json:"low_vram"`
 	
+	// Compute attention with flash attention, which roughly halves the VRAM
+	// used by long contexts
+	UseFlashAttention bool `json:"use_flash_attention"`
+	
+	// Store the KV cache in F16 rather than F32
+	F16KVCache bool `json:"f16_kv_cache"`
+	
+	// Type of the KV cache, "f16", "q8_0" or "q4_0", overriding F16KVCache.
+	// Quantized caches need flash attention, which they turn on.
+	KVCacheType string `json:"kv_cache_type,omitempty"`
+	
+	// RoPE scaling extending the context beyond the training length: "none",
+	// "linear" or "yarn", or empty for the scaling of the model metadata.
+	// RopeScale is the factor the context is extended by and YarnOrigCtxLen
+	// the training length; the YaRN betas are 0 for the llama.cpp defaults.
+	RopeScalingType string  `json:"rope_scaling_type,omitempty"`
+	RopeScale       float32 `json:"rope_scale,omitempty"`
+	YarnOrigCtxLen  int     `json:"yarn_orig_ctx_len,omitempty"`
+	YarnBetaFast    float32 `json:"yarn_beta_fast,omitempty"`
+	YarnBetaSlow    float32 `json:"yarn_beta_slow,omitempty"`
+	
+	// Evaluate a short prompt after loading the model, so that the first
+	// request does not wait for GPU kernels and buffers to be set up
+	WarmUp bool `json:"warm_up"`
+	
+	// Fragmentation of the KV cache, between 0 and 1, above which it is
+	// defragmented; 0 never defragments it
+	DefragKVThreshold float32 `json:"defrag_kv_threshold,omitempty"`
+	
 	// Tensor split for multi-GPU
 	TensorSplit []float32 `json:"tensor_split"`
 	
-	// CUDA/ROCm specific options
+	// CUDA/ROCm/SYCL specific options
 	UseCUDA bool `json:"use_cuda"`
 	UseROCm bool `json:"use_rocm"`
+	UseSYCL bool `json:"use_sycl"`
+	
+	// Maximum number of requests decoded together by continuous batching
+	Parallel int `json:"parallel"`
+	
+	// LoRA adapters applied to the model weights, in order
+	LoRAAdapters []LoRAAdapter `json:"lora_adapters,omitempty"`
+	
+	// How prompts longer than the context are handled
+	ContextOverflowStrategy ContextOverflowStrategy `json:"context_overflow_strategy"`
+	
+	// Path of a smaller model with the same vocabulary that proposes tokens
+	// for speculative decoding, and how many it proposes per step
+	DraftModel  string `json:"draft_model,omitempty"`
+	DraftTokens int    `json:"draft_tokens,omitempty"`
+
+	// Path of the vision encoder of a multimodal model. Without one, it is
+	// looked for next to models with a vision architecture.
+	Projector string `json:"projector,omitempty"`
+
+	// Defaults for requests to the model, set by its options file
+	SystemPrompt  string   `json:"system_prompt,omitempty"`
+	ChatTemplate  string   `json:"chat_template,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// LoRAAdapter is a LoRA adapter file and the scale its changes are applied with
+type LoRAAdapter struct {
+	Path  string  `json:"path"`
+	Scale float32 `json:"scale"`
 }
 
+// ContextOverflowStrategy controls what happens when a prompt does not fit in the context
+type ContextOverflowStrategy string
+
+const (
+	// ErrorOnOverflow rejects prompts that do not fit in the context
+	ErrorOnOverflow ContextOverflowStrategy = "error"
+	// TruncateLeft drops the oldest prompt tokens
+	TruncateLeft ContextOverflowStrategy = "truncate_left"
+	// TruncateRight drops the newest prompt tokens
+	TruncateRight ContextOverflowStrategy = "truncate_right"
+)
+
 // ModelInfo represents information about a loaded model
 type ModelInfo struct {
 	Name        string `json:"name"`
@@ -72,6 +186,16 @@ type ModelInfo struct {
 	Parameters  int64  `json:"parameters"`
 	GPULayers   int    `json:"gpu_layers"`
 	MemoryUsed  int64  `json:"memory_used"`
+	
+	// ActualGPULayers is the number of layers offloaded to the GPU, which is
+	// less than GPULayers when the model did not fit in GPU memory
+	ActualGPULayers int `json:"actual_gpu_layers"`
+	
+	// KVCacheType is the type the KV cache is stored in, e.g. "f16" or "q8_0"
+	KVCacheType string `json:"kv_cache_type,omitempty"`
+	
+	// WarmUpDuration is how long the warm-up run took, 0 without one
+	WarmUpDuration time.Duration `json:"warm_up_duration,omitempty"`
 }
 
 // DefaultModelOptions returns default options for model loading
@@ -84,7 +208,89 @@ func DefaultModelOptions() *ModelOptions {
 		UseMemoryMap:  true,
 		UseMemoryLock: false,
 		LowVRAM:       false,
+		F16KVCache:    true,
 		UseCUDA:       false,
 		UseROCm:       false,
+		UseSYCL:       false,
+		Parallel:      4,
+		
+		ContextOverflowStrategy: ErrorOnOverflow,
+	}
+}
+
+// ApplyModelOptions overrides options with those set in a model's options file
+func ApplyModelOptions(options *ModelOptions, overrides *model.ModelOptions) {
+	if overrides == nil {
+		return
+	}
+	
+	if overrides.ContextSize > 0 {
+		options.ContextSize = overrides.ContextSize
+	}
+	if overrides.GPULayers != nil {
+		options.GPULayers = *overrides.GPULayers
+	}
+	if overrides.Threads > 0 {
+		options.Threads = overrides.Threads
+	}
+	if overrides.BatchSize > 0 {
+		options.BatchSize = overrides.BatchSize
+	}
+	if overrides.NUMAMode != "" {
+		options.NUMAMode = overrides.NUMAMode
+	}
+	if overrides.FlashAttention != nil {
+		options.UseFlashAttention = *overrides.FlashAttention
+	}
+	if overrides.F16KVCache != nil {
+		options.F16KVCache = *overrides.F16KVCache
+	}
+	if overrides.KVCacheType != "" {
+		options.KVCacheType = overrides.KVCacheType
+	}
+	if overrides.RopeScalingType != "" {
+		options.RopeScalingType = overrides.RopeScalingType
+	}
+	if overrides.RopeScale > 0 {
+		options.RopeScale = overrides.RopeScale
+	}
+	if overrides.YarnOrigCtxLen > 0 {
+		options.YarnOrigCtxLen = overrides.YarnOrigCtxLen
+	}
+	if overrides.YarnBetaFast > 0 {
+		options.YarnBetaFast = overrides.YarnBetaFast
+	}
+	if overrides.YarnBetaSlow > 0 {
+		options.YarnBetaSlow = overrides.YarnBetaSlow
+	}
+	if overrides.WarmUp != nil {
+		options.WarmUp = *overrides.WarmUp
+	}
+	if overrides.DefragKVThreshold > 0 {
+		options.DefragKVThreshold = overrides.DefragKVThreshold
+	}
+	if overrides.Parallel > 0 {
+		options.Parallel = overrides.Parallel
+	}
+	if overrides.SystemPrompt != "" {
+		options.SystemPrompt = overrides.SystemPrompt
+	}
+	if overrides.ChatTemplate != "" {
+		options.ChatTemplate = overrides.ChatTemplate
+	}
+	if len(overrides.StopSequences) > 0 {
+		options.StopSequences = overrides.StopSequences
+	}
+	if overrides.DraftTokens > 0 {
+		options.DraftTokens = overrides.DraftTokens
+	}
+	if overrides.Projector != "" {
+		options.Projector = overrides.Projector
+	}
+	for _, adapter := range overrides.LoRAAdapters {
+		options.LoRAAdapters = append(options.LoRAAdapters, LoRAAdapter{
+			Path:  adapter.Path,
+			Scale: adapter.Scale,
+		})
 	}
 }
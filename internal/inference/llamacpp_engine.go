@@ -1,22 +1,36 @@
 package inference
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 
+	"colossus-cli/internal/gpu"
 	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+	"colossus-cli/internal/template"
 	"colossus-cli/internal/types"
 
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// maxOOMRetries is how many times loading a model is retried with fewer GPU
+// layers when it runs out of memory
+const maxOOMRetries = 3
+
 // LlamaCppEngine handles real model inference using llama.cpp
 type LlamaCppEngine struct {
-	models map[string]*LlamaCppModel
-	mutex  sync.RWMutex
+	models   map[string]*LlamaCppModel
+	mutex    sync.RWMutex
+	sessions *SessionStore
+	
+	// Directory of the KV cache snapshots of prefilled system prompts
+	prefillDir string
 }
 
 // LlamaCppModel represents a model loaded using llama.cpp
@@ -28,7 +42,29 @@ type LlamaCppModel struct {
 	Options    *ModelOptions
 	model      *llama.Model
 	context    *llama.Context
+	scheduler  *batchScheduler
 	mutex      sync.Mutex
+	
+	// chatTemplate formats chat prompts, nil for the default format
+	chatTemplate *template.Template
+
+	// draft proposes tokens for speculative decoding, nil when disabled
+	draft *LlamaCppModel
+
+	// vision encodes images for multimodal models, nil for text models
+	vision *llama.VisionEncoder
+	
+	// prefill is the saved KV cache of the system prompt, nil until
+	// PrefillContext is called
+	prefill *prefillSnapshot
+}
+
+// batchSize returns the maximum number of tokens decoded in one batch
+func (m *LlamaCppModel) batchSize() int {
+	if m.Options.BatchSize > 0 {
+		return m.Options.BatchSize
+	}
+	return 512
 }
 
 // NewLlamaCppEngine creates a new llama.cpp inference engine
@@ -38,12 +74,11 @@ func NewLlamaCppEngine() *LlamaCppEngine {
 	}
 }
 
-// LoadModel loads a model into memory using llama.cpp
+// LoadModel loads a model into memory using llama.cpp. The engine mutex is
+// only held to store the model, so other models keep serving requests while
+// it loads.
 func (e *LlamaCppEngine) LoadModel(name, path string, options *ModelOptions) error {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
-	
-	logrus.Infof("Loading model with llama.cpp: %s from %s", name, path)
+	logger.Infof("Loading model with llama.cpp: %s from %s", name, path)
 	
 	if options == nil {
 		options = DefaultModelOptions()
@@ -54,41 +89,104 @@ func (e *LlamaCppEngine) LoadModel(name, path string, options *ModelOptions) err
 		options.Threads = runtime.NumCPU()
 	}
 	
-	// Create model parameters
-	modelParams := llama.ModelParams{
-		UseMemoryMap:  options.UseMemoryMap,
-		UseMemoryLock: options.UseMemoryLock,
-		VocabOnly:     false,
-		GPULayers:     options.GPULayers,
-		MainGPU:       0,
-		TensorSplit:   options.TensorSplit,
+	// NUMA placement applies to the whole process, so a model asking for
+	// another mode than the one already set keeps running with it
+	if options.NUMAMode != "" {
+		strategy, err := llama.ParseNUMAStrategy(options.NUMAMode)
+		if err != nil {
+			return err
+		}
+		if err := llama.InitNUMA(strategy); err != nil {
+			logger.Warnf("Ignoring the NUMA mode of model %s: %v", name, err)
+		} else if nodes := gpu.DetectNUMATopology(); len(nodes) > 1 {
+			logger.Infof("Placing threads on %d NUMA nodes with mode %s", len(nodes), strategy)
+		}
+	}
+	
+	// Check the chat template before spending time on loading the model
+	chatTemplate, err := resolveChatTemplate(path, options.ChatTemplate)
+	if err != nil {
+		return err
+	}
+	
+	// llama.cpp finds the remaining parts of a split model from the first
+	// part's metadata, so only check that they are all present
+	if _, _, count, ok := model.ParseSplitName(filepath.Base(path)); ok {
+		parts, err := model.FindSplitParts(path)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Loading split model %s from %d parts", name, count)
+		path = parts[0]
 	}
 	
-	// Load the model
-	model, err := llama.LoadModel(path, modelParams)
+	// Quantizing the V cache needs flash attention in llama.cpp
+	kvCacheType, err := resolveKVCacheType(options)
 	if err != nil {
-		return fmt.Errorf("failed to load model from %s: %w", path, err)
+		return err
+	}
+	options.KVCacheType = kvCacheType.String()
+	if kvCacheType.Quantized() && !options.UseFlashAttention {
+		logger.Infof("Enabling flash attention for the %s KV cache of model %s", kvCacheType, name)
+		options.UseFlashAttention = true
 	}
 	
+	// RoPE scaling can extend the context size, so it is set first
+	applyRopeScaling(path, options)
+	
 	// Create context parameters
-	contextParams := llama.ContextParams{
-		ContextSize:   options.ContextSize,
-		BatchSize:     options.BatchSize,
-		Threads:       options.Threads,
-		RopeFreqBase:  10000.0,
-		RopeFreqScale: 1.0,
+	contextParams := newContextParams(options, kvCacheType)
+	if err := ropeContextParams(&contextParams, options); err != nil {
+		return err
+	}
+	if options.RopeScalingType != "" && options.RopeScalingType != "none" {
+		logger.Infof("Extending the context of model %s to %d tokens with %s RoPE scaling", name, options.ContextSize, options.RopeScalingType)
 	}
 	
-	// Create context
-	context, err := model.NewContext(contextParams)
+	// Load the model and create its context, offloading fewer layers to the
+	// GPU if it runs out of memory
+	var model *llama.Model
+	var llamaCtx *llama.Context
+	gpuLayers, err := loadWithGPUFallback(options.GPULayers, func(gpuLayers int) error {
+		modelParams := llama.ModelParams{
+			// LoRA adapters modify the weights, which a memory map would share with the file
+			UseMemoryMap:  options.UseMemoryMap && len(options.LoRAAdapters) == 0,
+			UseMemoryLock: options.UseMemoryLock,
+			VocabOnly:     false,
+			GPULayers:     gpuLayers,
+			MainGPU:       0,
+			TensorSplit:   options.TensorSplit,
+		}
+		
+		var err error
+		model, err = llama.LoadModel(path, modelParams)
+		if err != nil {
+			return fmt.Errorf("failed to load model from %s: %w", path, err)
+		}
+		
+		if err := applyLoRAAdapters(model, options.LoRAAdapters, options.Threads); err != nil {
+			model.Free()
+			return err
+		}
+		
+		llamaCtx, err = model.NewContext(contextParams)
+		if err != nil {
+			model.Free()
+			return fmt.Errorf("failed to create context for model %s: %w", name, err)
+		}
+		return nil
+	})
 	if err != nil {
-		model.Free()
-		return fmt.Errorf("failed to create context for model %s: %w", name, err)
+		return err
+	}
+	if gpuLayers < options.GPULayers {
+		logger.Warnf("Model %s did not fit in GPU memory, loaded with %d of %d GPU layers",
+			name, gpuLayers, options.GPULayers)
 	}
 	
 	// Get model information
 	vocabSize := model.GetVocabSize()
-	contextSize := context.GetContextSize()
+	contextSize := llamaCtx.GetContextSize()
 	
 	info := &ModelInfo{
 		Name:        name,
@@ -98,49 +196,181 @@ func (e *LlamaCppEngine) LoadModel(name, path string, options *ModelOptions) err
 		Parameters:  estimateParameters(path), // Estimate from file size
 		GPULayers:   options.GPULayers,
 		MemoryUsed:  estimateMemoryUsage(options),
+		
+		ActualGPULayers: gpuLayers,
+		KVCacheType:     options.KVCacheType,
 	}
 	
+	// The warm-up run is not a request, so it is left out of the metrics
+	if options.WarmUp {
+		info.WarmUpDuration = warmUp(name, llamaCtx)
+	}
+	
+	// Multimodal models read images through their vision encoder
+	vision, err := loadVisionEncoder(name, path, options, llamaCtx)
+	if err != nil {
+		llamaCtx.Free()
+		model.Free()
+		return err
+	}
+	
+	// A small model with the same vocabulary speeds up generation by
+	// proposing tokens that the model verifies in a single batch
+	var draft *LlamaCppModel
+	if options.DraftModel != "" {
+		draft, err = loadDraftModel(name, options, vocabSize)
+		if err != nil {
+			if vision != nil {
+				vision.Free()
+			}
+			llamaCtx.Free()
+			model.Free()
+			return err
+		}
+	}
+
 	// Store the loaded model
-	e.models[name] = &LlamaCppModel{
+	loaded := &LlamaCppModel{
 		Name:     name,
 		Path:     path,
 		LoadedAt: time.Now(),
 		Info:     info,
 		Options:  options,
 		model:    model,
-		context:  context,
+		context:  llamaCtx,
+		
+		chatTemplate: chatTemplate,
+		draft:        draft,
+		vision:       vision,
+	}
+	
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	// Another request may have loaded the same model in the meantime
+	if _, exists := e.models[name]; exists {
+		loaded.free()
+		return nil
 	}
 	
-	logrus.Infof("Model %s loaded successfully with llama.cpp", name)
-	logrus.Infof("Model info: %d parameters, %d vocab size, %d context size, %d GPU layers", 
-		info.Parameters, info.VocabSize, info.ContextSize, info.GPULayers)
+	loaded.scheduler = newBatchScheduler(loaded)
+	loaded.scheduler.Start()
+	e.models[name] = loaded
+	
+	logger.Infof("Model %s loaded successfully with llama.cpp", name)
+	logger.Infof("Model info: %d parameters, %d vocab size, %d context size, %d GPU layers", 
+		info.Parameters, info.VocabSize, info.ContextSize, info.ActualGPULayers)
 	
 	return nil
 }
 
+// loadWithGPUFallback calls load with gpuLayers, and after it runs out of
+// memory retries up to maxOOMRetries times with half as many layers, then once
+// more on the CPU alone. It returns the number of layers of the last attempt.
+func loadWithGPUFallback(gpuLayers int, load func(gpuLayers int) error) (int, error) {
+	for retries := 0; ; retries++ {
+		err := load(gpuLayers)
+		if err == nil || !errors.Is(err, llama.ErrOutOfMemory) || gpuLayers == 0 {
+			return gpuLayers, err
+		}
+		
+		if retries < maxOOMRetries {
+			gpuLayers /= 2
+		} else {
+			gpuLayers = 0
+		}
+		logger.Warnf("Out of memory with GPU offloading, retrying with %d GPU layers", gpuLayers)
+	}
+}
+
+// loraApplier applies LoRA adapters to model weights, as a *llama.Model does
+type loraApplier interface {
+	ApplyLoRA(path string, scale float32, threads int) error
+}
+
+// applyLoRAAdapters applies LoRA adapters to a model in order. A scale of 0
+// stands for the full scale of 1.
+func applyLoRAAdapters(model loraApplier, adapters []LoRAAdapter, threads int) error {
+	for _, adapter := range adapters {
+		scale := adapter.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		
+		logger.Infof("Applying LoRA adapter %s with scale %g", adapter.Path, scale)
+		if err := model.ApplyLoRA(adapter.Path, scale, threads); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveChatTemplate returns the chat template named or defined by the model
+// options, or else the built-in template matching the chat template in the
+// model's GGUF metadata. It returns nil to use the default format.
+func resolveChatTemplate(path, chatTemplate string) (*template.Template, error) {
+	if chatTemplate != "" {
+		return template.Get(chatTemplate)
+	}
+
+	info, err := model.ValidateModel(path)
+	if err != nil {
+		logger.Debugf("Cannot read the chat template of %s: %v", path, err)
+		return nil, nil
+	}
+	jinja, _ := info.Metadata["tokenizer.chat_template"].(string)
+	tmpl := template.Detect(jinja)
+	if tmpl != nil {
+		logger.Infof("Using the %s chat template from the model metadata", tmpl.Name)
+	}
+	return tmpl, nil
+}
+
 // UnloadModel removes a model from memory
 func (e *LlamaCppEngine) UnloadModel(name string) error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 	
+	return e.unloadModel(name)
+}
+
+// unloadModel removes a model from memory. The engine mutex must be held.
+func (e *LlamaCppEngine) unloadModel(name string) error {
 	model, exists := e.models[name]
 	if !exists {
 		return fmt.Errorf("model not loaded: %s", name)
 	}
 	
-	// Free llama.cpp resources
-	if model.context != nil {
-		model.context.Free()
-	}
-	if model.model != nil {
-		model.model.Free()
+	// Stop scheduling before the context is freed
+	if model.scheduler != nil {
+		model.scheduler.Stop()
 	}
 	
+	// Free llama.cpp resources
+	model.free()
+	
 	delete(e.models, name)
-	logrus.Infof("Model %s unloaded", name)
+	logger.Infof("Model %s unloaded", name)
 	return nil
 }
 
+// free releases the llama.cpp resources of a model, its draft model and its
+// vision encoder
+func (m *LlamaCppModel) free() {
+	if m.draft != nil {
+		m.draft.free()
+	}
+	if m.vision != nil {
+		m.vision.Free()
+	}
+	if m.context != nil {
+		m.context.Free()
+	}
+	if m.model != nil {
+		m.model.Free()
+	}
+}
+
 // IsModelLoaded checks if a model is loaded
 func (e *LlamaCppEngine) IsModelLoaded(name string) bool {
 	e.mutex.RLock()
@@ -150,142 +380,291 @@ func (e *LlamaCppEngine) IsModelLoaded(name string) bool {
 	return exists
 }
 
-// Generate generates text using llama.cpp
-func (e *LlamaCppEngine) Generate(req *types.GenerateRequest) (*types.GenerateResponse, error) {
-	model, err := e.getModel(req.Model)
+// Generate generates text using llama.cpp. Requests to the same model are
+// decoded together by the model's batch scheduler.
+func (e *LlamaCppEngine) Generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
+	return e.generate(ctx, e.withSystemPrompt(req))
+}
+
+// generate generates text for a request whose prompt is complete
+func (e *LlamaCppEngine) generate(ctx context.Context, req *types.GenerateRequest) (*types.GenerateResponse, error) {
+	start := time.Now()
+	model, seq, err := e.newSequence(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	defer seq.free()
 	
-	model.mutex.Lock()
-	defer model.mutex.Unlock()
+	if err := model.scheduler.Submit(seq); err != nil {
+		return nil, err
+	}
 	
-	// Tokenize the prompt
-	tokens, err := model.context.Tokenize(req.Prompt, true)
+	metrics := seq.metrics()
+	metrics.TotalDuration = time.Since(start)
+	return &types.GenerateResponse{
+		Model:            req.Model,
+		CreatedAt:        time.Now(),
+		Response:         seq.text,
+		Done:             true,
+		Context:          seq.context(),
+		Logprobs:         seq.logprobs,
+		InferenceMetrics: metrics,
+	}, nil
+}
+
+// GenerateBatch generates responses to several requests for the same model
+// together. The sequences are submitted to the scheduler at once, so their
+// prompts are decoded in shared batches and every step samples a token for
+// each of them, as far as the model's parallel slots allow.
+func (e *LlamaCppEngine) GenerateBatch(ctx context.Context, reqs []*types.GenerateRequest) ([]*types.GenerateResponse, error) {
+	start := time.Now()
+	
+	var model *LlamaCppModel
+	seqs := make([]*sequence, 0, len(reqs))
+	defer func() {
+		for _, seq := range seqs {
+			seq.free()
+		}
+	}()
+	
+	for i, req := range reqs {
+		reqModel, seq, err := e.newSequence(ctx, e.withSystemPrompt(req))
+		if err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		seqs = append(seqs, seq)
+		if model != nil && reqModel != model {
+			return nil, fmt.Errorf("batched requests must use the same model")
+		}
+		model = reqModel
+	}
+	if model == nil {
+		return nil, nil
+	}
+	
+	for i, err := range model.scheduler.SubmitBatch(ctx, seqs) {
+		if err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+	}
+	
+	responses := make([]*types.GenerateResponse, len(seqs))
+	for i, seq := range seqs {
+		metrics := seq.metrics()
+		metrics.TotalDuration = time.Since(start)
+		responses[i] = &types.GenerateResponse{
+			Model:            reqs[i].Model,
+			CreatedAt:        time.Now(),
+			Response:         seq.text,
+			Done:             true,
+			Context:          seq.context(),
+			Logprobs:         seq.logprobs,
+			InferenceMetrics: metrics,
+		}
+	}
+	return responses, nil
+}
+
+// newSequence resolves a generate request into a sequence ready to be
+// submitted to the model's scheduler. The caller must free the sequence.
+func (e *LlamaCppEngine) newSequence(ctx context.Context, req *types.GenerateRequest) (*LlamaCppModel, *sequence, error) {
+	model, err := e.getModel(req.Model)
 	if err != nil {
-		return nil, fmt.Errorf("tokenization failed: %w", err)
+		return nil, nil, err
 	}
 	
-	// Evaluate the prompt tokens
-	if err := model.context.Eval(tokens, 0); err != nil {
-		return nil, fmt.Errorf("prompt evaluation failed: %w", err)
+	// Resolve sampling parameters before touching the model
+	params, err := resolveSamplingParams(req.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(req.JSONSchema) > 0 {
+		if err := params.applyJSONSchema(req.JSONSchema); err != nil {
+			return nil, nil, err
+		}
+	}
+	
+	// Images take KV cache positions that a returned context or a session
+	// cannot hold
+	if len(req.Images) > 0 && (len(req.Context) > 0 || req.SessionID != "") {
+		return nil, nil, fmt.Errorf("images cannot be combined with context or session_id")
 	}
 	
-	// Generate response tokens
-	var responseTokens []llama.Token
+	var sessions *SessionStore
+	if req.SessionID != "" {
+		if sessions = e.sessionStore(); sessions == nil {
+			return nil, nil, fmt.Errorf("sessions are not enabled")
+		}
+		if _, err := sessions.Path(req.SessionID); err != nil {
+			return nil, nil, err
+		}
+	}
+	
+	// Tokenize the prompt, continuing from the returned context if there is one
+	tokens, err := e.contextTokens(model, req.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+	prompt := req.Prompt
+	if req.System != "" && len(tokens) == 0 {
+		prompt = fmt.Sprintf("System: %s\n%s", req.System, prompt)
+	}
+	_, span := tracer.Start(ctx, "Tokenize")
+	model.mutex.Lock()
+	promptTokens, err := model.context.Tokenize(prompt, len(tokens) == 0)
+	model.mutex.Unlock()
+	span.SetAttributes(attribute.Int("tokens", len(promptTokens)))
+	span.End()
+	if err != nil {
+		return nil, nil, fmt.Errorf("tokenization failed: %w", err)
+	}
+	tokens = append(tokens, promptTokens...)
+	
 	maxTokens := 512 // Default max tokens
 	if req.Options != nil && req.Options.NumPredict > 0 {
 		maxTokens = req.Options.NumPredict
 	}
 	
-	// Set generation parameters
-	temperature := float32(0.8)
-	topP := float32(0.95)
-	topK := 40
+	// Make sure the prompt fits in the context
+	tokens, err = fitToContext(tokens, model.Options.ContextSize, maxTokens, model.Options.ContextOverflowStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
 	
+	var stop []string
 	if req.Options != nil {
-		if req.Options.Temperature > 0 {
-			temperature = float32(req.Options.Temperature)
-		}
-		if req.Options.TopP > 0 {
-			topP = float32(req.Options.TopP)
-		}
-		if req.Options.TopK > 0 {
-			topK = req.Options.TopK
-		}
+		stop = req.Options.Stop
+	}
+	if len(stop) == 0 {
+		stop = model.Options.StopSequences
 	}
 	
-	// Generate tokens one by one
-	nPast := len(tokens)
-	for i := 0; i < maxTokens; i++ {
-		// Sample next token
-		token, err := model.context.Sample(temperature, topP, topK)
-		if err != nil {
-			return nil, fmt.Errorf("token sampling failed: %w", err)
-		}
-		
-		responseTokens = append(responseTokens, token)
-		
-		// Evaluate the new token
-		if err := model.context.Eval([]llama.Token{token}, nPast); err != nil {
-			return nil, fmt.Errorf("token evaluation failed: %w", err)
-		}
-		nPast++
-		
-		// Check for stop sequences
-		if req.Options != nil && len(req.Options.Stop) > 0 {
-			// Convert current response to text and check stop sequences
-			currentText, _ := model.context.Detokenize(responseTokens)
-			for _, stop := range req.Options.Stop {
-				if strings.Contains(currentText, stop) {
-					break
-				}
-			}
-		}
+	_, span = tracer.Start(ctx, "EmbedImages")
+	images, err := embedImages(model, req.Images)
+	span.End()
+	if err != nil {
+		return nil, nil, err
 	}
 	
-	// Convert response tokens to text
-	response, err := model.context.Detokenize(responseTokens)
+	sampler, err := newTokenSampler(params)
 	if err != nil {
-		return nil, fmt.Errorf("detokenization failed: %w", err)
+		freeImages(images)
+		return nil, nil, err
 	}
 	
-	return &types.GenerateResponse{
-		Model:     req.Model,
-		CreatedAt: time.Now(),
-		Response:  response,
-		Done:      true,
+	// A prompt starting with the prefilled system prompt restores its KV
+	// cache, which takes the whole context like a session
+	var prefillPath string
+	if len(req.Context) == 0 && req.SessionID == "" && len(images) == 0 {
+		prefillPath = model.prefillFor(tokens)
+	}
+	
+	return model, &sequence{
+		ctx:       ctx,
+		prompt:    tokens,
+		images:    images,
+		params:    params,
+		sampler:   sampler,
+		maxTokens: maxTokens,
+		stop:      stop,
+		sessionID: req.SessionID,
+		sessions:  sessions,
+		exclusive: req.SessionID != "" || prefillPath != "" || params.Seed != -1,
+		
+		prefillPath: prefillPath,
 	}, nil
 }
 
-// GenerateStream generates text with streaming using llama.cpp
-func (e *LlamaCppEngine) GenerateStream(req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
-	model, err := e.getModel(req.Model)
+// contextTokens validates the context of a previous response and converts it to tokens
+func (e *LlamaCppEngine) contextTokens(model *LlamaCppModel, context []int) ([]llama.Token, error) {
+	vocabSize := model.Info.VocabSize
+	tokens := make([]llama.Token, 0, len(context))
+	for _, id := range context {
+		if id < 0 || (vocabSize > 0 && id >= vocabSize) {
+			return nil, fmt.Errorf("invalid context: token %d is out of range", id)
+		}
+		tokens = append(tokens, llama.Token(id))
+	}
+	return tokens, nil
+}
+
+// GenerateStream generates text with streaming using llama.cpp. Text is
+// passed to the callback as the scheduler produces it, followed by a final
+// response with Done set. Generation stops if the callback returns an error
+// or ctx is cancelled.
+func (e *LlamaCppEngine) GenerateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
+	return e.generateStream(ctx, e.withSystemPrompt(req), callback)
+}
+
+// generateStream streams text for a request whose prompt is complete
+func (e *LlamaCppEngine) generateStream(ctx context.Context, req *types.GenerateRequest, callback func(*types.GenerateResponse) error) error {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	model, seq, err := e.newSequence(ctx, req)
 	if err != nil {
 		return err
 	}
+	defer seq.free()
 	
-	model.mutex.Lock()
-	defer model.mutex.Unlock()
-	
-	// In a real implementation, this would use llama.cpp's streaming capabilities
-	// For now, simulate streaming by chunking the response
-	response := e.simulateLlamaCppResponse(req.Prompt, req.Options)
-	words := splitWords(response)
+	seq.stream = newTokenStream()
+	result := make(chan error, 1)
+	go func() {
+		result <- model.scheduler.Submit(seq)
+	}()
 	
-	for i, word := range words {
-		resp := &types.GenerateResponse{
+	send := func() error {
+		text := seq.stream.take()
+		if text == "" {
+			return nil
+		}
+		return callback(&types.GenerateResponse{
 			Model:     req.Model,
 			CreatedAt: time.Now(),
-			Response:  word,
-			Done:      i == len(words)-1,
-		}
-		
-		if err := callback(resp); err != nil {
-			return err
-		}
-		
-		// Add small delay to simulate processing time
-		time.Sleep(50 * time.Millisecond)
+			Response:  text,
+		})
 	}
 	
-	return nil
+	for {
+		select {
+		case <-seq.stream.notify:
+			if err := send(); err != nil {
+				// Stop generating and wait for the scheduler to let go of the sequence
+				cancel()
+				<-result
+				return err
+			}
+		case err := <-result:
+			if err != nil {
+				return err
+			}
+			if err := send(); err != nil {
+				return err
+			}
+			metrics := seq.metrics()
+			metrics.TotalDuration = time.Since(start)
+			return callback(&types.GenerateResponse{
+				Model:            req.Model,
+				CreatedAt:        time.Now(),
+				Done:             true,
+				Context:          seq.context(),
+				Logprobs:         seq.logprobs,
+				InferenceMetrics: metrics,
+			})
+		}
+	}
 }
 
 // Chat handles chat completion using llama.cpp
-func (e *LlamaCppEngine) Chat(req *types.ChatRequest) (*types.ChatResponse, error) {
-	// Convert chat to prompt format
-	prompt := e.formatChatPrompt(req.Messages)
-	
-	// Create generate request
-	genReq := &types.GenerateRequest{
-		Model:   req.Model,
-		Prompt:  prompt,
-		Options: req.Options,
+func (e *LlamaCppEngine) Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error) {
+	genReq, err := e.chatGenerateRequest(req)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Generate response
-	genResp, err := e.Generate(genReq)
+	genResp, err := e.generate(ctx, genReq)
 	if err != nil {
 		return nil, err
 	}
@@ -297,24 +676,20 @@ func (e *LlamaCppEngine) Chat(req *types.ChatRequest) (*types.ChatResponse, erro
 			Role:    "assistant",
 			Content: genResp.Response,
 		},
-		Done: true,
+		Done:     true,
+		Logprobs: genResp.Logprobs,
 	}, nil
 }
 
 // ChatStream handles streaming chat completion
-func (e *LlamaCppEngine) ChatStream(req *types.ChatRequest, callback func(*types.ChatResponse) error) error {
-	// Convert chat to prompt format
-	prompt := e.formatChatPrompt(req.Messages)
-	
-	// Create generate request
-	genReq := &types.GenerateRequest{
-		Model:   req.Model,
-		Prompt:  prompt,
-		Options: req.Options,
+func (e *LlamaCppEngine) ChatStream(ctx context.Context, req *types.ChatRequest, callback func(*types.ChatResponse) error) error {
+	genReq, err := e.chatGenerateRequest(req)
+	if err != nil {
+		return err
 	}
 	
 	// Stream generation with callback wrapper
-	return e.GenerateStream(genReq, func(genResp *types.GenerateResponse) error {
+	return e.generateStream(ctx, genReq, func(genResp *types.GenerateResponse) error {
 		chatResp := &types.ChatResponse{
 			Model:     genResp.Model,
 			CreatedAt: genResp.CreatedAt,
@@ -322,12 +697,194 @@ func (e *LlamaCppEngine) ChatStream(req *types.ChatRequest, callback func(*types
 				Role:    "assistant",
 				Content: genResp.Response,
 			},
-			Done: genResp.Done,
+			Done:     genResp.Done,
+			Logprobs: genResp.Logprobs,
 		}
 		return callback(chatResp)
 	})
 }
 
+// chatGenerateRequest converts a chat request to a generate request, formatting
+// the messages with the model's chat template
+func (e *LlamaCppEngine) chatGenerateRequest(req *types.ChatRequest) (*types.GenerateRequest, error) {
+	model, err := e.getModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	
+	prompt, err := e.formatChatPrompt(model, req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The images of all messages are read before the conversation
+	var images [][]byte
+	for _, message := range req.Messages {
+		images = append(images, message.Images...)
+	}
+	
+	return &types.GenerateRequest{
+		Model:      req.Model,
+		Prompt:     prompt,
+		Images:     images,
+		Options:    req.Options,
+		JSONSchema: req.JSONSchema,
+	}, nil
+}
+
+// withSystemPrompt returns the request with the model's default system prompt
+// if it does not have one
+func (e *LlamaCppEngine) withSystemPrompt(req *types.GenerateRequest) *types.GenerateRequest {
+	model, err := e.getModel(req.Model)
+	if err != nil || req.System != "" || model.Options.SystemPrompt == "" {
+		return req
+	}
+	
+	withSystem := *req
+	withSystem.System = model.Options.SystemPrompt
+	return &withSystem
+}
+
+// Embed computes an embedding by evaluating the input and reading the
+// final hidden state from llama.cpp
+func (e *LlamaCppEngine) Embed(ctx context.Context, req *types.EmbedRequest) (*types.EmbedResponse, error) {
+	model, err := e.getModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	
+	model.mutex.Lock()
+	defer model.mutex.Unlock()
+	
+	// The request may have been cancelled while waiting for the model
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	tokens, err := model.context.Tokenize(req.Input, true)
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("input is empty")
+	}
+	
+	// Only this decode extracts embeddings, so generations do not pay for
+	// the larger output buffer
+	model.context.SetEmbeddings(true)
+	defer model.context.SetEmbeddings(false)
+	
+	// Evaluate the input in its own sequence so active generations are untouched
+	seqID := model.scheduler.embeddingSequenceID()
+	model.context.RemoveSequence(seqID)
+	defer model.context.RemoveSequence(seqID)
+	
+	batch := llama.NewBatch(len(tokens))
+	defer batch.Free()
+	for i, token := range tokens {
+		batch.Add(token, i, seqID, i == len(tokens)-1)
+	}
+	
+	for offset := 0; offset < len(tokens); offset += model.batchSize() {
+		n := len(tokens) - offset
+		if n > model.batchSize() {
+			n = model.batchSize()
+		}
+		if err := model.context.DecodeBatch(batch, offset, n); err != nil {
+			return nil, fmt.Errorf("input evaluation failed: %w", err)
+		}
+	}
+	
+	embedding, err := model.context.GetEmbeddings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings: %w", err)
+	}
+	
+	return &types.EmbedResponse{
+		Model:     req.Model,
+		Embedding: embedding,
+	}, nil
+}
+
+// Tokenize converts a prompt to token IDs using the model's tokenizer
+func (e *LlamaCppEngine) Tokenize(req *types.TokenizeRequest) (*types.TokenizeResponse, error) {
+	model, err := e.getModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	
+	model.mutex.Lock()
+	defer model.mutex.Unlock()
+	
+	tokens, err := model.context.Tokenize(req.Prompt, true)
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %w", err)
+	}
+	
+	ids := make([]int, len(tokens))
+	for i, token := range tokens {
+		ids[i] = int(token)
+	}
+	
+	return &types.TokenizeResponse{
+		Tokens: ids,
+		Count:  len(ids),
+	}, nil
+}
+
+// SetSessionStore sets where KV cache sessions are persisted between requests
+func (e *LlamaCppEngine) SetSessionStore(store *SessionStore) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.sessions = store
+}
+
+// sessionStore returns the engine's session store, or nil if sessions are disabled
+func (e *LlamaCppEngine) sessionStore() *SessionStore {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.sessions
+}
+
+// restoreSession loads a session's KV cache into the model context. It returns
+// the session file path and the number of prompt tokens already in the cache.
+func restoreSession(model *LlamaCppModel, sessions *SessionStore, id string, tokens []llama.Token) (string, int, error) {
+	path, err := sessions.Path(id)
+	if err != nil {
+		return "", 0, err
+	}
+	
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path, 0, nil
+	}
+	
+	cached, err := model.context.LoadSession(path)
+	if err != nil {
+		logger.Warnf("Discarding session %s: %v", id, err)
+		return path, 0, nil
+	}
+	sessions.Touch(id)
+	
+	nPast := cachedPrefix(cached, tokens)
+	logger.Debugf("Session %s: reusing %d of %d prompt tokens", id, nPast, len(tokens))
+	return path, nPast, nil
+}
+
+// cachedPrefix returns the number of prompt tokens that a KV cache built from
+// the cached tokens already covers
+func cachedPrefix(cached, tokens []llama.Token) int {
+	nPast := 0
+	for nPast < len(cached) && nPast < len(tokens) && cached[nPast] == tokens[nPast] {
+		nPast++
+	}
+	
+	// Always evaluate the last prompt token so there are logits to sample from
+	if nPast > 0 && nPast == len(tokens) {
+		nPast--
+	}
+	return nPast
+}
+
 // GetModelInfo returns information about a loaded model
 func (e *LlamaCppEngine) GetModelInfo(name string) (*ModelInfo, error) {
 	model, err := e.getModel(name)
@@ -338,17 +895,29 @@ func (e *LlamaCppEngine) GetModelInfo(name string) (*ModelInfo, error) {
 	return model.Info, nil
 }
 
+// LoadedModels returns information about all loaded models
+func (e *LlamaCppEngine) LoadedModels() []*ModelInfo {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	infos := make([]*ModelInfo, 0, len(e.models))
+	for _, model := range e.models {
+		infos = append(infos, model.Info)
+	}
+	return infos
+}
+
 // Shutdown gracefully shuts down the inference engine
 func (e *LlamaCppEngine) Shutdown() error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 	
-	logrus.Info("Shutting down llama.cpp inference engine")
+	logger.Info("Shutting down llama.cpp inference engine")
 	
 	// Unload all models
 	for name := range e.models {
-		if err := e.UnloadModel(name); err != nil {
-			logrus.Errorf("Error unloading model %s: %v", name, err)
+		if err := e.unloadModel(name); err != nil {
+			logger.Errorf("Error unloading model %s: %v", name, err)
 		}
 	}
 	
@@ -386,14 +955,27 @@ func (e *LlamaCppEngine) createContextParams(options *ModelOptions) map[string]i
 		"n_ctx":      options.ContextSize,
 		"n_batch":    options.BatchSize,
 		"n_threads":  options.Threads,
-		"f16_kv":     true,
+		"f16_kv":     options.F16KVCache,
+		"kv_cache_type": options.KVCacheType,
+		"flash_attn": options.UseFlashAttention,
+		"defrag_thold": options.DefragKVThreshold,
 		"use_mlock":  options.UseMemoryLock,
 	}
 }
 
-func (e *LlamaCppEngine) formatChatPrompt(messages []types.Message) string {
-	// Format messages using a chat template
-	// This would typically use the model's specific chat template
+// formatChatPrompt formats chat messages into a prompt with the model's chat
+// template, starting with its default system prompt if the chat has none
+func (e *LlamaCppEngine) formatChatPrompt(model *LlamaCppModel, messages []types.Message) (string, error) {
+	if model.Options.SystemPrompt != "" && !hasSystemMessage(messages) {
+		system := types.Message{Role: "system", Content: model.Options.SystemPrompt}
+		messages = append([]types.Message{system}, messages...)
+	}
+	
+	if model.chatTemplate != nil {
+		return model.chatTemplate.Format(messages)
+	}
+	
+	// Without a template, messages are formatted as "Role: content" lines
 	prompt := ""
 	
 	for _, msg := range messages {
@@ -404,11 +986,23 @@ func (e *LlamaCppEngine) formatChatPrompt(messages []types.Message) string {
 			prompt += fmt.Sprintf("User: %s\n", msg.Content)
 		case "assistant":
 			prompt += fmt.Sprintf("Assistant: %s\n", msg.Content)
+		case "tool":
+			prompt += fmt.Sprintf("Tool: %s\n", msg.Content)
 		}
 	}
 	
 	prompt += "Assistant: "
-	return prompt
+	return prompt, nil
+}
+
+// hasSystemMessage reports whether a chat contains a system message
+func hasSystemMessage(messages []types.Message) bool {
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			return true
+		}
+	}
+	return false
 }
 
 func (e *LlamaCppEngine) simulateLlamaCppResponse(prompt string, options *types.Options) string {
@@ -438,6 +1032,40 @@ func (e *LlamaCppEngine) simulateLlamaCppResponse(prompt string, options *types.
 	return response
 }
 
+// fitToContext applies the overflow strategy to a prompt longer than the
+// context size. The truncating strategies also leave room for maxTokens of
+// response, though never more than half the context, so a truncated prompt
+// does not fill the context before generation starts.
+func fitToContext(tokens []llama.Token, contextSize, maxTokens int, strategy ContextOverflowStrategy) ([]llama.Token, error) {
+	if contextSize <= 0 {
+		return tokens, nil
+	}
+	limit := contextSize - min(maxTokens, contextSize/2)
+	
+	switch strategy {
+	case TruncateLeft:
+		if len(tokens) <= limit {
+			return tokens, nil
+		}
+		logger.Warnf("Prompt has %d tokens but the context allows %d, dropping the oldest tokens", len(tokens), limit)
+		// Keep the BOS token added by Tokenize at the start of the prompt
+		truncated := make([]llama.Token, 0, limit)
+		truncated = append(truncated, tokens[0])
+		return append(truncated, tokens[len(tokens)-limit+1:]...), nil
+	case TruncateRight:
+		if len(tokens) <= limit {
+			return tokens, nil
+		}
+		logger.Warnf("Prompt has %d tokens but the context allows %d, dropping the newest tokens", len(tokens), limit)
+		return tokens[:limit], nil
+	default:
+		if len(tokens) <= contextSize {
+			return tokens, nil
+		}
+		return nil, fmt.Errorf("prompt is too long: %d tokens exceeds the context size of %d", len(tokens), contextSize)
+	}
+}
+
 // estimateParameters estimates model parameters from file size
 func estimateParameters(path string) int64 {
 	// This is a rough estimation based on file size
@@ -445,6 +1073,33 @@ func estimateParameters(path string) int64 {
 	return 7000000000 // Default to 7B parameters
 }
 
+// newContextParams returns the context parameters of a model's options
+func newContextParams(options *ModelOptions, kvCacheType llama.KVCacheType) llama.ContextParams {
+	return llama.ContextParams{
+		ContextSize:   options.ContextSize,
+		BatchSize:     options.BatchSize,
+		Threads:       options.Threads,
+		RopeFreqBase:  10000.0,
+		RopeFreqScale: 1.0,
+
+		FlashAttention:  options.UseFlashAttention,
+		KVCacheType:     kvCacheType,
+		DefragThreshold: options.DefragKVThreshold,
+	}
+}
+
+// resolveKVCacheType returns the KV cache type of the options: KVCacheType
+// when it is set, else F16 or, without F16KVCache, F32
+func resolveKVCacheType(options *ModelOptions) (llama.KVCacheType, error) {
+	if options.KVCacheType != "" {
+		return llama.ParseKVCacheType(options.KVCacheType)
+	}
+	if !options.F16KVCache {
+		return llama.KVCacheF32, nil
+	}
+	return llama.KVCacheF16, nil
+}
+
 func estimateMemoryUsage(options *ModelOptions) int64 {
 	// Rough estimation of memory usage based on context size and other factors
 	baseMemory := int64(1000000000) // 1GB base
@@ -0,0 +1,398 @@
+package inference
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+)
+
+// ggufKV is a metadata key-value pair of a test GGUF file
+type ggufKV struct {
+	key   string
+	value interface{}
+}
+
+// writeGGUF writes a GGUF v3 file without tensors holding the given metadata
+// to dir, and returns its path. Values may be strings, uint32 or float32.
+func writeGGUF(t *testing.T, dir string, metadata ...ggufKV) string {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)), []byte(s))
+	}
+
+	write(uint32(model.GGUFMagic), uint32(model.GGUFVersion3), uint64(0), uint64(len(metadata)))
+	for _, kv := range metadata {
+		writeString(kv.key)
+		switch v := kv.value.(type) {
+		case string:
+			write(uint32(model.GGUFTypeString))
+			writeString(v)
+		case uint32:
+			write(uint32(model.GGUFTypeUint32), v)
+		case float32:
+			write(uint32(model.GGUFTypeFloat32), v)
+		default:
+			t.Fatalf("unsupported GGUF value %T", v)
+		}
+	}
+
+	path := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFitToContext(t *testing.T) {
+	// Token 1 stands for the BOS token added by Tokenize
+	prompt := []llama.Token{1, 10, 11, 12, 13, 14, 15}
+
+	tests := []struct {
+		name        string
+		tokens      []llama.Token
+		contextSize int
+		maxTokens   int
+		strategy    ContextOverflowStrategy
+		want        []llama.Token
+		wantErr     string
+	}{
+		{
+			name:        "fits",
+			tokens:      prompt,
+			contextSize: 7,
+			strategy:    ErrorOnOverflow,
+			want:        prompt,
+		},
+		{
+			name:        "error on overflow",
+			tokens:      prompt,
+			contextSize: 4,
+			strategy:    ErrorOnOverflow,
+			wantErr:     "prompt is too long: 7 tokens exceeds the context size of 4",
+		},
+		{
+			name:        "truncate left keeps BOS and the newest tokens",
+			tokens:      prompt,
+			contextSize: 4,
+			strategy:    TruncateLeft,
+			want:        []llama.Token{1, 13, 14, 15},
+		},
+		{
+			name:        "truncate left leaves room for max_tokens",
+			tokens:      prompt,
+			contextSize: 6,
+			maxTokens:   2,
+			strategy:    TruncateLeft,
+			want:        []llama.Token{1, 13, 14, 15},
+		},
+		{
+			name:        "truncate left shortens a prompt that fits but leaves no room",
+			tokens:      prompt,
+			contextSize: 7,
+			maxTokens:   1,
+			strategy:    TruncateLeft,
+			want:        []llama.Token{1, 11, 12, 13, 14, 15},
+		},
+		{
+			name:        "room for max_tokens is capped at half the context",
+			tokens:      prompt,
+			contextSize: 6,
+			maxTokens:   512,
+			strategy:    TruncateLeft,
+			want:        []llama.Token{1, 14, 15},
+		},
+		{
+			name:        "truncate right keeps the oldest tokens",
+			tokens:      prompt,
+			contextSize: 4,
+			strategy:    TruncateRight,
+			want:        []llama.Token{1, 10, 11, 12},
+		},
+		{
+			name:        "truncate right leaves room for max_tokens",
+			tokens:      prompt,
+			contextSize: 6,
+			maxTokens:   2,
+			strategy:    TruncateRight,
+			want:        []llama.Token{1, 10, 11, 12},
+		},
+		{
+			name:        "unknown strategy errors",
+			tokens:      prompt,
+			contextSize: 4,
+			strategy:    "truncate_middle",
+			wantErr:     "prompt is too long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fitToContext(tt.tokens, tt.contextSize, tt.maxTokens, tt.strategy)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if room, want := tt.contextSize-len(got), min(tt.maxTokens, tt.contextSize/2); tt.strategy != ErrorOnOverflow && room < want {
+				t.Errorf("%d tokens of the context left for the response, want at least %d", room, want)
+			}
+		})
+	}
+}
+
+func TestContextTokens(t *testing.T) {
+	engine := NewLlamaCppEngine()
+	model := &LlamaCppModel{Info: &ModelInfo{VocabSize: 100}}
+
+	// The context of a response is passed back as the start of the next prompt
+	got, err := engine.contextTokens(model, []int{1, 42, 99})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []llama.Token{1, 42, 99}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, id := range []int{-1, 100} {
+		if _, err := engine.contextTokens(model, []int{1, id}); err == nil {
+			t.Errorf("token %d accepted", id)
+		}
+	}
+}
+
+func TestLoadWithGPUFallback(t *testing.T) {
+	errOther := errors.New("file not found")
+
+	tests := []struct {
+		name       string
+		gpuLayers  int
+		fitsLayers int   // the most layers that fit in memory
+		failWith   error // returned instead of out of memory, when set
+		wantTried  []int
+		wantErr    error
+	}{
+		{name: "fits", gpuLayers: 32, fitsLayers: 32, wantTried: []int{32}},
+		{name: "fits after halving", gpuLayers: 32, fitsLayers: 10, wantTried: []int{32, 16, 8}},
+		{name: "falls back to the CPU", gpuLayers: 32, fitsLayers: 0, wantTried: []int{32, 16, 8, 4, 0}},
+		{name: "other errors are not retried", gpuLayers: 32, failWith: errOther, wantTried: []int{32}, wantErr: errOther},
+		{name: "CPU only", gpuLayers: 0, fitsLayers: -1, wantTried: []int{0}, wantErr: llama.ErrOutOfMemory},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []int
+			layers, err := loadWithGPUFallback(tt.gpuLayers, func(gpuLayers int) error {
+				tried = append(tried, gpuLayers)
+				if tt.failWith != nil {
+					return tt.failWith
+				}
+				if gpuLayers > tt.fitsLayers {
+					return fmt.Errorf("failed to create context: %w", llama.ErrOutOfMemory)
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tried, tt.wantTried) {
+				t.Errorf("tried GPU layers %v, want %v", tried, tt.wantTried)
+			}
+			if last := tt.wantTried[len(tt.wantTried)-1]; layers != last {
+				t.Errorf("returned %d layers, want %d", layers, last)
+			}
+		})
+	}
+}
+
+func TestResolveChatTemplate(t *testing.T) {
+	chatml := "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\\n' + message['content'] + '<|im_end|>'}}{% endfor %}"
+
+	tests := []struct {
+		name         string
+		metadata     []ggufKV
+		chatTemplate string
+		want         string
+	}{
+		{
+			name:     "detected from metadata",
+			metadata: []ggufKV{{"general.architecture", "qwen2"}, {"tokenizer.chat_template", chatml}},
+			want:     "chatml",
+		},
+		{
+			name:         "configured template wins",
+			metadata:     []ggufKV{{"general.architecture", "qwen2"}, {"tokenizer.chat_template", chatml}},
+			chatTemplate: "llama3",
+			want:         "llama3",
+		},
+		{
+			name:     "unrecognized template",
+			metadata: []ggufKV{{"general.architecture", "phi"}, {"tokenizer.chat_template", "{{ messages }}"}},
+		},
+		{
+			name:     "no template",
+			metadata: []ggufKV{{"general.architecture", "llama"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeGGUF(t, t.TempDir(), tt.metadata...)
+			tmpl, err := resolveChatTemplate(path, tt.chatTemplate)
+			if err != nil {
+				t.Fatalf("resolveChatTemplate: %v", err)
+			}
+			if tt.want == "" {
+				if tmpl != nil {
+					t.Errorf("template = %s, want none", tmpl.Name)
+				}
+				return
+			}
+			if tmpl == nil || tmpl.Name != tt.want {
+				t.Errorf("template = %v, want %s", tmpl, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolveChatTemplate(writeGGUF(t, t.TempDir()), "chatlm"); err == nil {
+		t.Error("unknown configured template accepted")
+	}
+}
+
+// fakeLoRAApplier records the adapters applied to it, failing on failPath
+type fakeLoRAApplier struct {
+	applied  []LoRAAdapter
+	failPath string
+}
+
+func (f *fakeLoRAApplier) ApplyLoRA(path string, scale float32, threads int) error {
+	if path == f.failPath {
+		return fmt.Errorf("failed to apply LoRA adapter %s", path)
+	}
+	f.applied = append(f.applied, LoRAAdapter{Path: path, Scale: scale})
+	return nil
+}
+
+func TestApplyLoRAAdapters(t *testing.T) {
+	adapters := []LoRAAdapter{
+		{Path: "domain.gguf", Scale: 0.5},
+		{Path: "style.gguf"},
+		{Path: "extra.gguf", Scale: 2},
+	}
+
+	model := &fakeLoRAApplier{}
+	if err := applyLoRAAdapters(model, adapters, 4); err != nil {
+		t.Fatalf("applyLoRAAdapters: %v", err)
+	}
+	want := []LoRAAdapter{
+		{Path: "domain.gguf", Scale: 0.5},
+		{Path: "style.gguf", Scale: 1},
+		{Path: "extra.gguf", Scale: 2},
+	}
+	if !reflect.DeepEqual(model.applied, want) {
+		t.Errorf("applied %+v, want %+v", model.applied, want)
+	}
+
+	// Adapters after a failing one are not applied
+	model = &fakeLoRAApplier{failPath: "style.gguf"}
+	if err := applyLoRAAdapters(model, adapters, 4); err == nil {
+		t.Error("applyLoRAAdapters succeeded with a failing adapter")
+	}
+	if len(model.applied) != 1 {
+		t.Errorf("applied %+v, want only the adapter before the failing one", model.applied)
+	}
+}
+
+func TestContextParamsFromModelOptions(t *testing.T) {
+	tests := []struct {
+		name               string
+		yaml               string
+		wantFlashAttention bool
+		wantKVCacheType    llama.KVCacheType
+		wantDefrag         float32
+	}{
+		{
+			name:            "defaults",
+			wantKVCacheType: llama.KVCacheF16,
+		},
+		{
+			name:               "flash attention and defragmentation",
+			yaml:               "flash_attention: true\ndefrag_kv_threshold: 0.1\n",
+			wantFlashAttention: true,
+			wantKVCacheType:    llama.KVCacheF16,
+			wantDefrag:         0.1,
+		},
+		{
+			name:            "F32 KV cache",
+			yaml:            "f16_kv_cache: false\n",
+			wantKVCacheType: llama.KVCacheF32,
+		},
+		{
+			name:            "KV cache type overrides F16 KV cache",
+			yaml:            "f16_kv_cache: false\nkv_cache_type: q8_0\n",
+			wantKVCacheType: llama.KVCacheQ8_0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides, err := model.ParseModelOptions([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("ParseModelOptions: %v", err)
+			}
+			options := DefaultModelOptions()
+			ApplyModelOptions(options, overrides)
+
+			kvCacheType, err := resolveKVCacheType(options)
+			if err != nil {
+				t.Fatalf("resolveKVCacheType: %v", err)
+			}
+			params := newContextParams(options, kvCacheType)
+			if params.FlashAttention != tt.wantFlashAttention {
+				t.Errorf("FlashAttention = %v, want %v", params.FlashAttention, tt.wantFlashAttention)
+			}
+			if params.KVCacheType != tt.wantKVCacheType {
+				t.Errorf("KVCacheType = %s, want %s", params.KVCacheType, tt.wantKVCacheType)
+			}
+			if params.DefragThreshold != tt.wantDefrag {
+				t.Errorf("DefragThreshold = %v, want %v", params.DefragThreshold, tt.wantDefrag)
+			}
+		})
+	}
+
+	for _, yaml := range []string{"defrag_kv_threshold: 1.5\n", "kv_cache_type: q5_1\n"} {
+		if _, err := model.ParseModelOptions([]byte(yaml)); err == nil {
+			t.Errorf("ParseModelOptions accepted %q", yaml)
+		}
+	}
+}
+
+func TestResolveKVCacheType(t *testing.T) {
+	options := DefaultModelOptions()
+	options.KVCacheType = "q5_1"
+	if _, err := resolveKVCacheType(options); err == nil {
+		t.Error("resolveKVCacheType accepted an unknown KV cache type")
+	}
+}
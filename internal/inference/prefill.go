@@ -0,0 +1,134 @@
+package inference
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"colossus-cli/internal/llama"
+)
+
+// prefillSnapshot is the saved KV cache of a model's system prompt
+type prefillSnapshot struct {
+	path   string
+	tokens []llama.Token
+}
+
+// SetPrefillDir sets where the KV cache snapshots of prefilled system
+// prompts are saved
+func (e *LlamaCppEngine) SetPrefillDir(dir string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.prefillDir = dir
+}
+
+// PrefillContext evaluates a system prompt once and saves the KV cache it
+// leaves. Later requests to the model whose prompt starts with the system
+// prompt, such as generate requests with it as their system prompt, restore
+// the saved cache instead of evaluating it again. Like sessions, such
+// requests need the model's context to themselves.
+func (e *LlamaCppEngine) PrefillContext(modelName, systemPrompt string) error {
+	model, err := e.getModel(modelName)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.RLock()
+	dir := e.prefillDir
+	e.mutex.RUnlock()
+	if dir == "" {
+		return fmt.Errorf("prefill is not enabled")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create prefill directory: %w", err)
+	}
+
+	// The system prompt is formatted as generate requests format it
+	model.mutex.Lock()
+	tokens, err := model.context.Tokenize(fmt.Sprintf("System: %s\n", systemPrompt), true)
+	model.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("tokenization failed: %w", err)
+	}
+	if _, err := fitToContext(tokens, model.Options.ContextSize, 0, ErrorOnOverflow); err != nil {
+		return err
+	}
+
+	params, err := resolveSamplingParams(nil)
+	if err != nil {
+		return err
+	}
+	sampler, err := newTokenSampler(params)
+	if err != nil {
+		return err
+	}
+
+	// An exclusive sequence evaluates the prompt alone in the context and
+	// saves the cache once it finishes. The single token it samples is not
+	// decoded, so the cache holds the prompt only.
+	path := prefillPath(dir, model.Path, systemPrompt)
+	os.Remove(path)
+	seq := &sequence{
+		ctx:         context.Background(),
+		prompt:      tokens,
+		params:      params,
+		sampler:     sampler,
+		maxTokens:   1,
+		exclusive:   true,
+		sessionPath: path,
+	}
+	defer seq.free()
+
+	if err := model.scheduler.Submit(seq); err != nil {
+		return fmt.Errorf("failed to evaluate system prompt: %w", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to save prefill snapshot: %w", err)
+	}
+
+	model.mutex.Lock()
+	model.prefill = &prefillSnapshot{path: path, tokens: tokens}
+	model.mutex.Unlock()
+
+	logger.Infof("Prefilled %d system prompt tokens for model %s", len(tokens), modelName)
+	return nil
+}
+
+// prefillPath returns the snapshot file of a system prompt for a model file
+func prefillPath(dir, modelPath, systemPrompt string) string {
+	hash := sha256.Sum256([]byte(modelPath + "\x00" + systemPrompt))
+	return filepath.Join(dir, hex.EncodeToString(hash[:16])+".bin")
+}
+
+// prefillFor returns the snapshot path for a prompt starting with the model's
+// prefilled system prompt, or an empty string. The last snapshot token may
+// differ, as it can merge with the text that follows the system prompt.
+func (m *LlamaCppModel) prefillFor(tokens []llama.Token) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.prefill == nil || len(tokens) < len(m.prefill.tokens) {
+		return ""
+	}
+	if cachedPrefix(m.prefill.tokens, tokens) < len(m.prefill.tokens)-1 {
+		return ""
+	}
+	return m.prefill.path
+}
+
+// restorePrefill loads a prefill snapshot into the model context. It returns
+// the number of prompt tokens already in the cache.
+func restorePrefill(model *LlamaCppModel, path string, tokens []llama.Token) int {
+	cached, err := model.context.LoadSession(path)
+	if err != nil {
+		logger.Warnf("Evaluating the system prompt without its prefill snapshot: %v", err)
+		return 0
+	}
+
+	nPast := cachedPrefix(cached, tokens)
+	logger.Debugf("Prefill: reusing %d of %d prompt tokens", nPast, len(tokens))
+	return nPast
+}
@@ -0,0 +1,68 @@
+package inference
+
+import (
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+)
+
+// applyRopeScaling completes the RoPE scaling of the options of a model. A
+// model whose options set no scaling gets the scaling recommended by its
+// GGUF metadata, if any. When the options set linear or YaRN scaling and the
+// context size does not already exceed the training length, the context is
+// extended to YarnOrigCtxLen * RopeScale.
+func applyRopeScaling(path string, options *ModelOptions) {
+	if options.RopeScalingType == "none" {
+		return
+	}
+
+	var info *model.ModelInfo
+	if options.RopeScalingType == "" || options.YarnOrigCtxLen == 0 {
+		var err error
+		if info, err = model.ValidateModel(path); err != nil {
+			logger.Debugf("Cannot read the RoPE scaling of %s: %v", path, err)
+			info = nil
+		}
+	}
+
+	if options.RopeScalingType == "" {
+		if info == nil || info.RopeScalingType == "" || info.RopeScalingType == "none" {
+			return
+		}
+		options.RopeScalingType = info.RopeScalingType
+		if options.RopeScale == 0 {
+			options.RopeScale = float32(info.RopeScalingFactor)
+		}
+		if options.YarnOrigCtxLen == 0 {
+			options.YarnOrigCtxLen = info.RopeScalingOrigCtxLength
+		}
+		logger.Infof("Using the %s RoPE scaling from the model metadata", options.RopeScalingType)
+	}
+
+	// The training length is the context length of the model before scaling
+	if options.YarnOrigCtxLen == 0 && info != nil {
+		options.YarnOrigCtxLen = info.RopeScalingOrigCtxLength
+		if options.YarnOrigCtxLen == 0 {
+			options.YarnOrigCtxLen = info.ContextSize
+		}
+	}
+	if options.RopeScale > 1 && options.YarnOrigCtxLen > 0 && options.ContextSize <= options.YarnOrigCtxLen {
+		options.ContextSize = int(float32(options.YarnOrigCtxLen) * options.RopeScale)
+	}
+}
+
+// ropeContextParams sets the RoPE scaling of the options on context parameters
+func ropeContextParams(params *llama.ContextParams, options *ModelOptions) error {
+	scalingType, err := llama.ParseRopeScalingType(options.RopeScalingType)
+	if err != nil {
+		return err
+	}
+
+	params.RopeScalingType = scalingType
+	if options.RopeScale > 0 {
+		params.RopeFreqScale = 1 / options.RopeScale
+	}
+	params.YarnOrigCtx = options.YarnOrigCtxLen
+	params.YarnBetaFast = options.YarnBetaFast
+	params.YarnBetaSlow = options.YarnBetaSlow
+	return nil
+}
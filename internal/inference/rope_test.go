@@ -0,0 +1,115 @@
+package inference
+
+import (
+	"testing"
+
+	"colossus-cli/internal/llama"
+)
+
+func TestApplyRopeScaling(t *testing.T) {
+	llama2 := []ggufKV{{"general.architecture", "llama"}, {"llama.context_length", uint32(4096)}}
+	yarnMetadata := []ggufKV{
+		{"general.architecture", "qwen2"},
+		{"qwen2.context_length", uint32(32768)},
+		{"qwen2.rope.scaling.type", "yarn"},
+		{"qwen2.rope.scaling.factor", float32(4)},
+		{"qwen2.rope.scaling.original_context_length", uint32(8192)},
+	}
+
+	tests := []struct {
+		name            string
+		metadata        []ggufKV
+		options         ModelOptions
+		wantScalingType string
+		wantCtxLen      int
+		wantContextSize int
+	}{
+		{
+			name:            "YaRN with the training length of the options",
+			metadata:        llama2,
+			options:         ModelOptions{ContextSize: 2048, RopeScalingType: "yarn", RopeScale: 4, YarnOrigCtxLen: 2048},
+			wantScalingType: "yarn",
+			wantCtxLen:      2048,
+			wantContextSize: 8192,
+		},
+		{
+			name:            "YaRN with the training length of the metadata",
+			metadata:        llama2,
+			options:         ModelOptions{ContextSize: 2048, RopeScalingType: "yarn", RopeScale: 4},
+			wantScalingType: "yarn",
+			wantCtxLen:      4096,
+			wantContextSize: 16384,
+		},
+		{
+			name:            "linear",
+			metadata:        llama2,
+			options:         ModelOptions{ContextSize: 4096, RopeScalingType: "linear", RopeScale: 2},
+			wantScalingType: "linear",
+			wantCtxLen:      4096,
+			wantContextSize: 8192,
+		},
+		{
+			name:            "larger context size kept",
+			metadata:        llama2,
+			options:         ModelOptions{ContextSize: 10000, RopeScalingType: "yarn", RopeScale: 2},
+			wantScalingType: "yarn",
+			wantCtxLen:      4096,
+			wantContextSize: 10000,
+		},
+		{
+			name:            "YaRN of the metadata",
+			metadata:        yarnMetadata,
+			options:         ModelOptions{ContextSize: 2048},
+			wantScalingType: "yarn",
+			wantCtxLen:      8192,
+			wantContextSize: 32768,
+		},
+		{
+			name:            "scaling disabled",
+			metadata:        yarnMetadata,
+			options:         ModelOptions{ContextSize: 2048, RopeScalingType: "none"},
+			wantScalingType: "none",
+			wantContextSize: 2048,
+		},
+		{
+			name:            "no scaling in the metadata",
+			metadata:        llama2,
+			options:         ModelOptions{ContextSize: 2048},
+			wantContextSize: 2048,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			applyRopeScaling(writeGGUF(t, t.TempDir(), tt.metadata...), &options)
+
+			if options.RopeScalingType != tt.wantScalingType {
+				t.Errorf("RopeScalingType = %q, want %q", options.RopeScalingType, tt.wantScalingType)
+			}
+			if options.YarnOrigCtxLen != tt.wantCtxLen {
+				t.Errorf("YarnOrigCtxLen = %d, want %d", options.YarnOrigCtxLen, tt.wantCtxLen)
+			}
+			if options.ContextSize != tt.wantContextSize {
+				t.Errorf("ContextSize = %d, want %d", options.ContextSize, tt.wantContextSize)
+			}
+		})
+	}
+}
+
+func TestRopeContextParams(t *testing.T) {
+	options := &ModelOptions{RopeScalingType: "yarn", RopeScale: 4, YarnOrigCtxLen: 4096, YarnBetaFast: 16}
+	params := llama.ContextParams{RopeFreqScale: 1}
+	if err := ropeContextParams(&params, options); err != nil {
+		t.Fatalf("ropeContextParams: %v", err)
+	}
+	if params.RopeScalingType != llama.RopeScalingYarn || params.RopeFreqScale != 0.25 ||
+		params.YarnOrigCtx != 4096 || params.YarnBetaFast != 16 {
+		t.Errorf("params = %+v, want YaRN scaling by 4 from 4096 tokens", params)
+	}
+
+	options.RopeScalingType = "ntk"
+	if err := ropeContextParams(&params, options); err == nil {
+		t.Error("ropeContextParams accepted an unknown scaling type")
+	}
+}
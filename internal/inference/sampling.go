@@ -0,0 +1,444 @@
+package inference
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"colossus-cli/internal/grammar"
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/types"
+)
+
+// Default sampling parameters
+const (
+	defaultTemperature   = float32(0.8)
+	defaultTopP          = float32(0.95)
+	defaultTopK          = 40
+	defaultMirostatTau   = float32(5.0)
+	defaultMirostatEta   = float32(0.1)
+	defaultRepeatPenalty = float32(1.1)
+	defaultRepeatLastN   = 64
+
+	// mirostatM is the number of tokens Mirostat v1 uses to estimate s_hat
+	mirostatM = 100
+
+	// maxLogprobs is the largest number of alternatives returned per token
+	maxLogprobs = 20
+)
+
+// samplingParams holds the resolved sampling configuration for a request
+type samplingParams struct {
+	Temperature  float32
+	TopP         float32
+	TopK         int
+	MinP         float32
+	TopA         float32
+	MirostatMode int
+	MirostatTau  float32
+	MirostatEta  float32
+
+	RepeatPenalty    float32
+	RepeatLastN      int
+	FrequencyPenalty float32
+	PresencePenalty  float32
+
+	// DRY penalty over the last DRYPenaltyLastN tokens (0 = all), off when
+	// DRYMultiplier is 0
+	DRYMultiplier    float32
+	DRYBase          float32
+	DRYAllowedLength int
+	DRYPenaltyLastN  int
+
+	// Seed is -1 when the RNG should not be reseeded
+	Seed int64
+
+	// Grammar constrains sampling when non-nil
+	Grammar *grammar.Grammar
+
+	// Logprobs is the number of top candidates to report per token (0 = off)
+	Logprobs int
+}
+
+// resolveSamplingParams applies defaults to the request options and
+// validates combinations that cannot be used together
+func resolveSamplingParams(options *types.Options) (*samplingParams, error) {
+	params := &samplingParams{
+		Temperature: defaultTemperature,
+		TopP:        defaultTopP,
+		TopK:        defaultTopK,
+		MirostatTau: defaultMirostatTau,
+		MirostatEta: defaultMirostatEta,
+
+		RepeatPenalty: defaultRepeatPenalty,
+		RepeatLastN:   defaultRepeatLastN,
+
+		DRYBase:          defaultDRYBase,
+		DRYAllowedLength: defaultDRYAllowedLength,
+
+		Seed: -1,
+	}
+
+	if options == nil {
+		return params, nil
+	}
+
+	if options.Temperature > 0 {
+		params.Temperature = float32(options.Temperature)
+	}
+	if options.TopP > 0 {
+		params.TopP = float32(options.TopP)
+	}
+	if options.TopK > 0 {
+		params.TopK = options.TopK
+	}
+
+	// Top-p, min-p and top-a all cut off the tail of the distribution, so at
+	// most one is used. Setting min_p or top_a disables the default top_p.
+	if options.MinP < 0 || options.MinP > 1 {
+		return nil, fmt.Errorf("min_p must be between 0 and 1")
+	}
+	if options.TopA < 0 || options.TopA > 1 {
+		return nil, fmt.Errorf("top_a must be between 0 and 1")
+	}
+	nucleus := 0
+	for _, set := range []bool{options.TopP > 0, options.MinP > 0, options.TopA > 0} {
+		if set {
+			nucleus++
+		}
+	}
+	if nucleus > 1 {
+		return nil, fmt.Errorf("only one of top_p, min_p and top_a can be set")
+	}
+	if options.MinP > 0 || options.TopA > 0 {
+		params.TopP = 1
+		params.MinP = options.MinP
+		params.TopA = options.TopA
+	}
+
+	switch options.MirostatMode {
+	case 0:
+	case 1, 2:
+		// Mirostat controls perplexity directly and replaces top-p/top-k filtering
+		if options.TopP > 0 || options.TopK > 0 || options.MinP > 0 || options.TopA > 0 {
+			return nil, fmt.Errorf("mirostat sampling cannot be combined with top_p, top_k, min_p or top_a")
+		}
+		params.MirostatMode = options.MirostatMode
+	default:
+		return nil, fmt.Errorf("invalid mirostat mode: %d (expected 0, 1 or 2)", options.MirostatMode)
+	}
+
+	if options.MirostatTau > 0 {
+		params.MirostatTau = options.MirostatTau
+	}
+	if options.MirostatEta > 0 {
+		params.MirostatEta = options.MirostatEta
+	}
+
+	if options.RepeatPenalty > 0 {
+		params.RepeatPenalty = options.RepeatPenalty
+	}
+	if options.RepeatLastN > 0 {
+		params.RepeatLastN = options.RepeatLastN
+	}
+	params.FrequencyPenalty = options.FrequencyPenalty
+	params.PresencePenalty = options.PresencePenalty
+
+	if options.DRYMultiplier < 0 || options.DRYPenaltyLastN < 0 || options.DRYAllowedLength < 0 {
+		return nil, fmt.Errorf("dry_multiplier, dry_allowed_length and dry_penalty_last_n cannot be negative")
+	}
+	if options.DRYBase != 0 && options.DRYBase < 1 {
+		return nil, fmt.Errorf("dry_base must be at least 1")
+	}
+	params.DRYMultiplier = options.DRYMultiplier
+	if options.DRYBase > 0 {
+		params.DRYBase = options.DRYBase
+	}
+	if options.DRYAllowedLength > 0 {
+		params.DRYAllowedLength = options.DRYAllowedLength
+	}
+	params.DRYPenaltyLastN = options.DRYPenaltyLastN
+
+	if options.Seed != nil && *options.Seed != -1 {
+		params.Seed = *options.Seed
+	}
+
+	if options.Logprobs < 0 || options.Logprobs > maxLogprobs {
+		return nil, fmt.Errorf("logprobs must be between 0 and %d", maxLogprobs)
+	}
+	params.Logprobs = options.Logprobs
+
+	grammarSrc := options.Grammar
+	switch options.Format {
+	case "":
+	case "json":
+		if grammarSrc != "" {
+			return nil, fmt.Errorf("format and grammar cannot be used together")
+		}
+		grammarSrc = grammar.JSONObject
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", options.Format)
+	}
+
+	if grammarSrc != "" {
+		parsed, err := grammar.Parse(grammarSrc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grammar: %w", err)
+		}
+		params.Grammar = parsed
+	}
+
+	return params, nil
+}
+
+// applyJSONSchema constrains sampling to JSON matching the given schema
+func (p *samplingParams) applyJSONSchema(schema json.RawMessage) error {
+	if p.Grammar != nil {
+		return fmt.Errorf("json_schema cannot be combined with grammar or format")
+	}
+
+	src, err := grammar.FromJSONSchema(schema)
+	if err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+
+	parsed, err := grammar.Parse(src)
+	if err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+	p.Grammar = parsed
+	return nil
+}
+
+// tokenSampler samples tokens for a single generation, carrying any sampler
+// state (such as Mirostat's mu and the repetition window) across tokens
+type tokenSampler struct {
+	params     *samplingParams
+	mu         float32
+	lastTokens []llama.Token
+	grammar    *llama.Grammar
+
+	// dryTokens are the generated tokens the DRY penalty looks for
+	// repetitions in, when it is on
+	dryTokens []llama.Token
+}
+
+// newTokenSampler creates a sampler for one generation request. The sampler
+// must be freed after use.
+func newTokenSampler(params *samplingParams) (*tokenSampler, error) {
+	sampler := &tokenSampler{
+		params:     params,
+		mu:         2 * params.MirostatTau,
+		lastTokens: make([]llama.Token, 0, params.RepeatLastN),
+	}
+
+	if params.Grammar != nil {
+		g, err := llama.NewGrammar(params.Grammar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create grammar: %w", err)
+		}
+		sampler.grammar = g
+	}
+
+	return sampler, nil
+}
+
+// Free releases any native sampler state
+func (s *tokenSampler) Free() {
+	if s.grammar != nil {
+		s.grammar.Free()
+		s.grammar = nil
+	}
+}
+
+// Sample samples the next token from the logits of the i-th token of the
+// last decoded batch
+func (s *tokenSampler) Sample(ctx *llama.Context, i int) (llama.Token, error) {
+	candidates, err := ctx.CandidatesAt(i)
+	if err != nil {
+		return 0, err
+	}
+	defer candidates.Free()
+
+	candidates.ApplyRepetitionPenalties(s.lastTokens, s.params.RepeatPenalty, s.params.FrequencyPenalty, s.params.PresencePenalty)
+	if penalties := dryPenalties(s.dryTokens, s.params.DRYMultiplier, s.params.DRYBase, s.params.DRYAllowedLength); penalties != nil {
+		candidates.Penalize(penalties)
+	}
+	if s.grammar != nil {
+		candidates.ApplyGrammar(s.grammar)
+	}
+
+	var token llama.Token
+	switch s.params.MirostatMode {
+	case 1:
+		candidates.Temperature(s.params.Temperature)
+		token = candidates.SampleMirostat(s.params.MirostatTau, s.params.MirostatEta, mirostatM, &s.mu)
+	case 2:
+		candidates.Temperature(s.params.Temperature)
+		token = candidates.SampleMirostatV2(s.params.MirostatTau, s.params.MirostatEta, &s.mu)
+	default:
+		candidates.TopK(s.params.TopK)
+		candidates.TopP(s.params.TopP)
+		if s.params.MinP > 0 {
+			candidates.MinP(s.params.MinP)
+		}
+		if s.params.TopA > 0 {
+			candidates.TopA(s.params.TopA)
+		}
+		candidates.Temperature(s.params.Temperature)
+		token = candidates.SampleToken()
+	}
+
+	if s.grammar != nil {
+		s.grammar.AcceptToken(ctx, token)
+	}
+	s.accept(token)
+	return token, nil
+}
+
+// accept records a generated token in the repetition window
+func (s *tokenSampler) accept(token llama.Token) {
+	if s.params.DRYMultiplier > 0 {
+		s.dryTokens = append(s.dryTokens, token)
+		if n := s.params.DRYPenaltyLastN; n > 0 && len(s.dryTokens) > n {
+			s.dryTokens = s.dryTokens[len(s.dryTokens)-n:]
+		}
+	}
+	if s.params.RepeatLastN <= 0 {
+		return
+	}
+	if len(s.lastTokens) == s.params.RepeatLastN {
+		copy(s.lastTokens, s.lastTokens[1:])
+		s.lastTokens = s.lastTokens[:len(s.lastTokens)-1]
+	}
+	s.lastTokens = append(s.lastTokens, token)
+}
+
+// applyRepetitionPenalties penalises the logits of tokens that appear in
+// lastTokens the way llama.cpp does: positive logits are divided by the
+// repeat penalty and negative ones multiplied by it, then the frequency
+// penalty is subtracted once per occurrence and the presence penalty once.
+// It is used where sampling happens in Go rather than in llama.cpp.
+func applyRepetitionPenalties(logits []float32, lastTokens []llama.Token, repeat, frequency, presence float32) {
+	counts := make(map[llama.Token]int)
+	for _, token := range lastTokens {
+		counts[token]++
+	}
+
+	for token, count := range counts {
+		if int(token) < 0 || int(token) >= len(logits) {
+			continue
+		}
+		if logits[token] <= 0 {
+			logits[token] *= repeat
+		} else {
+			logits[token] /= repeat
+		}
+		logits[token] -= float32(count)*frequency + presence
+	}
+}
+
+// greedyToken returns the token with the highest logit, preferring the
+// lowest token id on ties
+func greedyToken(logits []float32) llama.Token {
+	best := 0
+	for i, logit := range logits {
+		if logit > logits[best] {
+			best = i
+		}
+	}
+	return llama.Token(best)
+}
+
+// sampleLogits draws a token from the softmax of logits at the given
+// temperature. A temperature of 0 or less picks the most likely token.
+func sampleLogits(logits []float32, temperature float32, rng *rand.Rand) llama.Token {
+	best := greedyToken(logits)
+	if temperature <= 0 {
+		return best
+	}
+
+	weights := make([]float64, len(logits))
+	total := 0.0
+	for i, logit := range logits {
+		weights[i] = math.Exp(float64((logit - logits[best]) / temperature))
+		total += weights[i]
+	}
+
+	r := rng.Float64() * total
+	for i, weight := range weights {
+		r -= weight
+		if r < 0 {
+			return llama.Token(i)
+		}
+	}
+	return best
+}
+
+// tokenLogprobs computes the log probability of the sampled token and of the
+// n most likely candidates from the raw logits of the i-th token of the last
+// decoded batch
+func tokenLogprobs(ctx *llama.Context, i int, token llama.Token, n int) (types.TokenLogprob, error) {
+	return logitLogprobs(ctx.GetLogitsAt(i), token, n, func(t llama.Token) string {
+		text, _ := ctx.Detokenize([]llama.Token{t})
+		return text
+	})
+}
+
+// logitLogprobs computes the log probability of token and of the n most
+// likely candidates from logits, naming each token with piece
+func logitLogprobs(logits []float32, token llama.Token, n int, piece func(llama.Token) string) (types.TokenLogprob, error) {
+	if int(token) < 0 || int(token) >= len(logits) {
+		return types.TokenLogprob{}, fmt.Errorf("token %d out of range of logits", token)
+	}
+
+	// log-softmax: logprob(i) = logit(i) - log(sum(exp(logit)))
+	maxLogit := logits[0]
+	for _, logit := range logits {
+		if logit > maxLogit {
+			maxLogit = logit
+		}
+	}
+	var sum float64
+	for _, logit := range logits {
+		sum += math.Exp(float64(logit - maxLogit))
+	}
+	logZ := float64(maxLogit) + math.Log(sum)
+
+	result := types.TokenLogprob{
+		Token:   piece(token),
+		Logprob: float32(float64(logits[token]) - logZ),
+	}
+	for _, id := range topIndices(logits, n) {
+		result.TopLogprobs = append(result.TopLogprobs, types.TokenLogprob{
+			Token:   piece(llama.Token(id)),
+			Logprob: float32(float64(logits[id]) - logZ),
+		})
+	}
+
+	return result, nil
+}
+
+// topIndices returns the indices of the n largest values in descending order
+func topIndices(values []float32, n int) []int {
+	if n > len(values) {
+		n = len(values)
+	}
+
+	top := make([]int, 0, n+1)
+	for i, v := range values {
+		if len(top) == n && v <= values[top[n-1]] {
+			continue
+		}
+		pos := sort.Search(len(top), func(j int) bool { return values[top[j]] < v })
+		top = append(top, 0)
+		copy(top[pos+1:], top[pos:])
+		top[pos] = i
+		if len(top) > n {
+			top = top[:n]
+		}
+	}
+	return top
+}
@@ -0,0 +1,293 @@
+package inference
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/types"
+)
+
+func TestResolveSamplingParamsPenalties(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *types.Options
+		want    samplingParams
+	}{
+		{
+			name:    "defaults",
+			options: nil,
+			want:    samplingParams{RepeatPenalty: 1.1, RepeatLastN: 64},
+		},
+		{
+			name: "overrides",
+			options: &types.Options{
+				RepeatPenalty:    1.5,
+				RepeatLastN:      256,
+				FrequencyPenalty: 0.5,
+				PresencePenalty:  0.25,
+			},
+			want: samplingParams{RepeatPenalty: 1.5, RepeatLastN: 256, FrequencyPenalty: 0.5, PresencePenalty: 0.25},
+		},
+		{
+			name:    "unset repeat penalty keeps the default",
+			options: &types.Options{FrequencyPenalty: 1},
+			want:    samplingParams{RepeatPenalty: 1.1, RepeatLastN: 64, FrequencyPenalty: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSamplingParams(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.RepeatPenalty != tt.want.RepeatPenalty || got.RepeatLastN != tt.want.RepeatLastN ||
+				got.FrequencyPenalty != tt.want.FrequencyPenalty || got.PresencePenalty != tt.want.PresencePenalty {
+				t.Errorf("penalties = %v/%d/%v/%v, want %v/%d/%v/%v",
+					got.RepeatPenalty, got.RepeatLastN, got.FrequencyPenalty, got.PresencePenalty,
+					tt.want.RepeatPenalty, tt.want.RepeatLastN, tt.want.FrequencyPenalty, tt.want.PresencePenalty)
+			}
+		})
+	}
+}
+
+func TestApplyRepetitionPenalties(t *testing.T) {
+	tests := []struct {
+		name                        string
+		repeat, frequency, presence float32
+		want                        []float32
+	}{
+		{
+			name:   "repeat penalty",
+			repeat: 2,
+			want:   []float32{1, -4, 6, 1.5},
+		},
+		{
+			name:      "frequency penalty counts occurrences",
+			repeat:    1,
+			frequency: 0.5,
+			want:      []float32{1, -3, 6, 2.5},
+		},
+		{
+			name:     "presence penalty applies once",
+			repeat:   1,
+			presence: 0.5,
+			want:     []float32{1, -2.5, 6, 2.5},
+		},
+	}
+
+	// Token 1 appears twice and token 3 once; tokens 0 and 2 do not appear
+	lastTokens := []llama.Token{1, 3, 1}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logits := []float32{1, -2, 6, 3}
+			applyRepetitionPenalties(logits, lastTokens, tt.repeat, tt.frequency, tt.presence)
+			if !reflect.DeepEqual(logits, tt.want) {
+				t.Errorf("logits = %v, want %v", logits, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulateWordsRepeatPenalty(t *testing.T) {
+	prompt := "hello"
+
+	repeated := func(words []string) int {
+		seen := make(map[string]bool)
+		n := 0
+		for _, word := range words {
+			if seen[word] {
+				n++
+			}
+			seen[word] = true
+		}
+		return n
+	}
+
+	words, _, err := simulateWords(prompt, nil)
+	if err != nil {
+		t.Fatalf("simulateWords: %v", err)
+	}
+	if got, want := strings.Join(words, ""), simulateResponse(prompt); got != want {
+		t.Fatalf("default penalties changed the response: %q, want %q", got, want)
+	}
+	if repeated(words) == 0 {
+		t.Fatal("the scripted response has no repeated words to penalise")
+	}
+
+	words, _, err = simulateWords(prompt, &types.Options{RepeatPenalty: 5})
+	if err != nil {
+		t.Fatalf("simulateWords: %v", err)
+	}
+	if n := repeated(words); n != 0 {
+		t.Errorf("%d repeated words with repeat_penalty 5: %q", n, words)
+	}
+}
+
+func TestResolveSamplingParamsSeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *types.Options
+		want    int64
+	}{
+		{name: "no options", options: nil, want: -1},
+		{name: "unset", options: &types.Options{}, want: -1},
+		{name: "random", options: &types.Options{Seed: seed(-1)}, want: -1},
+		{name: "zero", options: &types.Options{Seed: seed(0)}, want: 0},
+		{name: "fixed", options: &types.Options{Seed: seed(42)}, want: 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSamplingParams(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Seed != tt.want {
+				t.Errorf("Seed = %d, want %d", got.Seed, tt.want)
+			}
+		})
+	}
+}
+
+// seed returns a pointer to a sampling seed for request options
+func seed(value int64) *int64 {
+	return &value
+}
+
+func TestSimulatedGenerateSeed(t *testing.T) {
+	engine := NewSimulatedEngine()
+	if err := engine.LoadModel("tinyllama", "tinyllama.gguf", nil); err != nil {
+		t.Fatalf("LoadModel: %v", err)
+	}
+
+	generate := func(value int64) string {
+		t.Helper()
+		resp, err := engine.Generate(context.Background(), &types.GenerateRequest{
+			Model:   "tinyllama",
+			Prompt:  "hello",
+			Options: &types.Options{Seed: seed(value)},
+		})
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return resp.Response
+	}
+
+	first := generate(42)
+	if again := generate(42); again != first {
+		t.Errorf("seed 42 gave %q, then %q", first, again)
+	}
+	if other := generate(7); other == first {
+		t.Errorf("seeds 42 and 7 both gave %q", first)
+	}
+}
+
+func TestTopIndices(t *testing.T) {
+	logits := []float32{0.5, 3, -1, 2, 7, 2.5, 0, 1}
+
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{n: 1, want: []int{4}},
+		{n: 5, want: []int{4, 1, 5, 3, 7}},
+		{n: 20, want: []int{4, 1, 5, 3, 7, 0, 6, 2}},
+	}
+
+	for _, tt := range tests {
+		if got := topIndices(logits, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("topIndices(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSamplingParamsLogprobs(t *testing.T) {
+	params, err := resolveSamplingParams(&types.Options{Logprobs: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.Logprobs != 5 {
+		t.Errorf("Logprobs = %d, want 5", params.Logprobs)
+	}
+
+	for _, n := range []int{-1, maxLogprobs + 1} {
+		if _, err := resolveSamplingParams(&types.Options{Logprobs: n}); err == nil {
+			t.Errorf("Logprobs %d accepted", n)
+		}
+	}
+}
+
+func TestResolveSamplingParamsMinPTopA(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *types.Options
+		wantTopP float32
+		wantMinP float32
+		wantTopA float32
+		wantErr  string
+	}{
+		{name: "default top_p", options: &types.Options{}, wantTopP: defaultTopP},
+		{name: "min_p disables top_p", options: &types.Options{MinP: 0.05}, wantTopP: 1, wantMinP: 0.05},
+		{name: "top_a disables top_p", options: &types.Options{TopA: 0.2}, wantTopP: 1, wantTopA: 0.2},
+		{name: "top_p with min_p", options: &types.Options{TopP: 0.9, MinP: 0.05}, wantErr: "only one of top_p, min_p and top_a"},
+		{name: "min_p with top_a", options: &types.Options{MinP: 0.05, TopA: 0.2}, wantErr: "only one of top_p, min_p and top_a"},
+		{name: "min_p above 1", options: &types.Options{MinP: 1.5}, wantErr: "min_p must be between 0 and 1"},
+		{name: "negative top_a", options: &types.Options{TopA: -0.1}, wantErr: "top_a must be between 0 and 1"},
+		{name: "min_p with mirostat", options: &types.Options{MinP: 0.05, MirostatMode: 2}, wantErr: "mirostat sampling cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSamplingParams(tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.TopP != tt.wantTopP || got.MinP != tt.wantMinP || got.TopA != tt.wantTopA {
+				t.Errorf("top_p/min_p/top_a = %v/%v/%v, want %v/%v/%v",
+					got.TopP, got.MinP, got.TopA, tt.wantTopP, tt.wantMinP, tt.wantTopA)
+			}
+		})
+	}
+}
+
+func TestTokenDistributionMinPTopA(t *testing.T) {
+	// Logits whose softmax is 0.5, 0.25, 0.15 and 0.1
+	logits := []float32{float32(math.Log(0.5)), float32(math.Log(0.25)), float32(math.Log(0.15)), float32(math.Log(0.1))}
+
+	tests := []struct {
+		name       string
+		minP, topA float32
+		want       []float64
+	}{
+		{name: "no cutoff", want: []float64{0.5, 0.25, 0.15, 0.1}},
+		{name: "min_p keeps tokens at least 0.4 times the best", minP: 0.4, want: []float64{2.0 / 3, 1.0 / 3, 0, 0}},
+		{name: "min_p of 0.25", minP: 0.25, want: []float64{0.5 / 0.9, 0.25 / 0.9, 0.15 / 0.9, 0}},
+		{name: "top_a keeps tokens at least 0.8 times the best squared", topA: 0.8, want: []float64{2.0 / 3, 1.0 / 3, 0, 0}},
+		{name: "top_a of 0.3", topA: 0.3, want: []float64{0.5, 0.25, 0.15, 0.1}},
+		{name: "min_p of 1 keeps the best", minP: 1, want: []float64{1, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &samplingParams{Temperature: 1, TopP: 1, MinP: tt.minP, TopA: tt.topA, RepeatPenalty: 1}
+			got := tokenDistribution(logits, params, nil)
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-6 {
+					t.Fatalf("distribution = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
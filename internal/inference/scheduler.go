@@ -0,0 +1,730 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errSchedulerStopped is returned to requests still queued when a model is unloaded
+var errSchedulerStopped = errors.New("model was unloaded")
+
+// tracer creates the spans of inference, as children of the span in the
+// context of each request
+var tracer = otel.Tracer("colossus-cli/internal/inference")
+
+// tokenStream hands generated text from the scheduler to a streaming
+// request. Pushing never blocks, so a slow client cannot stall the other
+// sequences in the batch.
+type tokenStream struct {
+	mutex   sync.Mutex
+	pending strings.Builder
+	notify  chan struct{}
+}
+
+func newTokenStream() *tokenStream {
+	return &tokenStream{notify: make(chan struct{}, 1)}
+}
+
+// push appends text and wakes up the reader
+func (t *tokenStream) push(text string) {
+	if text == "" {
+		return
+	}
+
+	t.mutex.Lock()
+	t.pending.WriteString(text)
+	t.mutex.Unlock()
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns and clears the text pushed since the last call
+func (t *tokenStream) take() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	text := t.pending.String()
+	t.pending.Reset()
+	return text
+}
+
+// sequence is a single generation request scheduled on a model
+type sequence struct {
+	// ctx cancels the request; it is checked before every decoding step
+	ctx context.Context
+
+	prompt    []llama.Token
+	params    *samplingParams
+	sampler   *tokenSampler
+	maxTokens int
+	stop      []string
+
+	// images are decoded before the prompt, for multimodal models
+	images []*llama.ImageEmbed
+
+	// Session to restore before and persist after generation
+	sessionID string
+	sessions  *SessionStore
+
+	// Prefill snapshot to restore before generation, when the prompt starts
+	// with the model's prefilled system prompt
+	prefillPath string
+
+	// Exclusive sequences need the whole context to themselves, either to
+	// load a session or prefill snapshot or to sample from a seeded RNG
+	exclusive bool
+
+	// Scheduling state
+	slot        int
+	nPast       int
+	pending     []llama.Token
+	history     []llama.Token
+	sessionPath string
+
+	// stream receives the text as it is generated, when set
+	stream  *tokenStream
+	emitted int
+
+	// Timing of the prompt evaluation, which ends with the first sample, and
+	// of the generation
+	startedAt     time.Time
+	promptDecoded int
+	finishedAt    time.Time
+
+	// Time spent sampling, traced as one span when the sequence finishes
+	sampleStart time.Time
+	sampleTime  time.Duration
+	samples     int
+
+	// rng samples from the distributions of speculative decoding
+	rng *rand.Rand
+
+	// Output, valid once result has been received
+	tokens   []llama.Token
+	logprobs []types.TokenLogprob
+	text     string
+	stopped  bool
+	result   chan error
+}
+
+// context returns the prompt and generated tokens, which a later request can
+// pass back to continue the conversation
+func (seq *sequence) context() []int {
+	context := make([]int, 0, len(seq.prompt)+len(seq.tokens))
+	for _, token := range seq.prompt {
+		context = append(context, int(token))
+	}
+	for _, token := range seq.tokens {
+		context = append(context, int(token))
+	}
+	return context
+}
+
+// free releases the sampler and images of a sequence that was submitted or
+// will not be
+func (seq *sequence) free() {
+	seq.sampler.Free()
+	freeImages(seq.images)
+	seq.images = nil
+}
+
+// metrics returns the timing breakdown of a finished sequence. The total
+// duration is left to the caller.
+func (seq *sequence) metrics() *types.InferenceMetrics {
+	metrics := &types.InferenceMetrics{
+		PromptEvalCount: seq.promptDecoded,
+		EvalCount:       len(seq.tokens),
+	}
+	if seq.samples > 0 {
+		metrics.PromptEvalDuration = seq.sampleStart.Sub(seq.startedAt)
+		metrics.EvalDuration = seq.finishedAt.Sub(seq.sampleStart)
+	}
+	return metrics
+}
+
+// reservation returns the number of KV cache cells the sequence may occupy
+func (seq *sequence) reservation(contextSize int) int {
+	n := seq.imagePositions() + len(seq.prompt) + seq.maxTokens
+	if contextSize > 0 && n > contextSize {
+		n = contextSize
+	}
+	return n
+}
+
+// batchEntry records which sequence a batch token belongs to
+type batchEntry struct {
+	seq    *sequence
+	logits bool
+}
+
+// slotContext is the KV cache that a slotTable admits sequences into. The
+// batch scheduler implements it with the model's context.
+type slotContext interface {
+	// contextSize returns the number of KV cache cells, or 0 when unbounded
+	contextSize() int
+	// start prepares the KV cache of a slot for a sequence
+	start(seq *sequence, slot int) error
+}
+
+// slotTable keeps the slot and admission bookkeeping of a batch scheduler:
+// which sequence holds each KV cache sequence ID, which are waiting, and how
+// many cells the active ones have reserved.
+type slotTable struct {
+	ctx slotContext
+
+	// slots holds the active sequence for each KV cache sequence ID
+	slots    []*sequence
+	queue    []*sequence
+	reserved int
+}
+
+// batchScheduler implements continuous batching for a loaded model. Waiting
+// requests are admitted into free sequence slots while the KV cache has room
+// for them, and every step decodes the pending tokens of all active
+// sequences in a single batch.
+type batchScheduler struct {
+	slotTable
+
+	model *LlamaCppModel
+	batch *llama.Batch
+
+	// draftBatch holds the tokens decoded by the draft model, and draftSeq
+	// is the sequence whose first draftPast tokens are in its KV cache
+	draftBatch *llama.Batch
+	draftSeq   *sequence
+	draftPast  int
+
+	submit chan []*sequence
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// newBatchScheduler creates a scheduler for a model
+func newBatchScheduler(model *LlamaCppModel) *batchScheduler {
+	parallel := model.Options.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	s := &batchScheduler{
+		model:  model,
+		batch:  llama.NewBatch(model.batchSize()),
+		submit: make(chan []*sequence),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	s.slotTable = slotTable{ctx: s, slots: make([]*sequence, parallel)}
+	if model.draft != nil {
+		s.draftBatch = llama.NewBatch(model.draft.batchSize())
+	}
+	return s
+}
+
+// Start runs the scheduler loop in a new goroutine
+func (s *batchScheduler) Start() {
+	go s.run()
+}
+
+// Stop stops the scheduler, failing any queued or active requests
+func (s *batchScheduler) Stop() {
+	close(s.quit)
+	<-s.done
+}
+
+// Submit queues a sequence and waits until it has finished. A cancelled
+// sequence is removed at the next decoding step and fails with the context's
+// error.
+func (s *batchScheduler) Submit(seq *sequence) error {
+	return s.SubmitBatch(ctxOrBackground(seq.ctx), []*sequence{seq})[0]
+}
+
+// SubmitBatch queues sequences together, so that they are admitted in the
+// same step and their prompts decoded in the same batches as far as free
+// slots and the KV cache allow, and waits until all have finished. It
+// returns the error of each sequence.
+func (s *batchScheduler) SubmitBatch(ctx context.Context, seqs []*sequence) []error {
+	errs := make([]error, len(seqs))
+	for _, seq := range seqs {
+		seq.ctx = ctxOrBackground(seq.ctx)
+		seq.result = make(chan error, 1)
+	}
+
+	select {
+	case s.submit <- seqs:
+	case <-s.done:
+		for i := range errs {
+			errs[i] = errSchedulerStopped
+		}
+		return errs
+	case <-ctx.Done():
+		for i := range errs {
+			errs[i] = ctx.Err()
+		}
+		return errs
+	}
+
+	for i, seq := range seqs {
+		errs[i] = <-seq.result
+	}
+	return errs
+}
+
+// ctxOrBackground returns ctx, or the background context when it is nil
+func ctxOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// embeddingSequenceID returns a sequence ID outside the generation slots
+func (s *batchScheduler) embeddingSequenceID() int {
+	return len(s.slots)
+}
+
+func (s *batchScheduler) run() {
+	defer close(s.done)
+	defer s.batch.Free()
+	if s.draftBatch != nil {
+		defer s.draftBatch.Free()
+	}
+
+	for {
+		// Wait for work when idle
+		if len(s.queue) == 0 && s.activeCount() == 0 {
+			select {
+			case seqs := <-s.submit:
+				s.queue = append(s.queue, seqs...)
+			case <-s.quit:
+				return
+			}
+		}
+
+		// Collect anything else submitted since the last step
+	collect:
+		for {
+			select {
+			case seqs := <-s.submit:
+				s.queue = append(s.queue, seqs...)
+			case <-s.quit:
+				s.failAll(errSchedulerStopped)
+				return
+			default:
+				break collect
+			}
+		}
+
+		s.admit()
+		s.step()
+	}
+}
+
+// activeCount returns the number of sequences occupying a slot
+func (s *slotTable) activeCount() int {
+	count := 0
+	for _, seq := range s.slots {
+		if seq != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// admit moves queued sequences into free slots in arrival order
+func (s *slotTable) admit() {
+	contextSize := s.ctx.contextSize()
+
+	for len(s.queue) > 0 {
+		seq := s.queue[0]
+
+		// Drop requests cancelled while waiting
+		if err := seq.ctx.Err(); err != nil {
+			s.queue = s.queue[1:]
+			seq.result <- err
+			continue
+		}
+
+		if active := s.activeCount(); active > 0 {
+			// Exclusive sequences only start on an idle model, so they always run in slot 0
+			if seq.exclusive || s.slots[0] != nil && s.slots[0].exclusive {
+				return
+			}
+			if contextSize > 0 && s.reserved+seq.reservation(contextSize) > contextSize {
+				return
+			}
+		}
+
+		slot := -1
+		for i, active := range s.slots {
+			if active == nil {
+				slot = i
+				break
+			}
+		}
+		if slot < 0 {
+			return
+		}
+
+		s.queue = s.queue[1:]
+		seq.slot = slot
+		if err := s.ctx.start(seq, slot); err != nil {
+			seq.result <- err
+			continue
+		}
+		s.slots[slot] = seq
+		s.reserved += seq.reservation(contextSize)
+	}
+}
+
+// release frees the slot and the KV cache reservation of a finished
+// sequence. It reports false when the sequence does not hold its slot.
+func (s *slotTable) release(seq *sequence) bool {
+	if s.slots[seq.slot] != seq {
+		return false
+	}
+	s.slots[seq.slot] = nil
+	s.reserved -= seq.reservation(s.ctx.contextSize())
+	return true
+}
+
+// contextSize returns the context size of the model
+func (s *batchScheduler) contextSize() int {
+	return s.model.Options.ContextSize
+}
+
+// start prepares the KV cache for a sequence and places it in a slot
+func (s *batchScheduler) start(seq *sequence, slot int) error {
+	s.model.mutex.Lock()
+	defer s.model.mutex.Unlock()
+
+	ctx := s.model.context
+	seq.slot = slot
+	seq.nPast = 0
+
+	if seq.exclusive {
+		// Seed the context RNG so identical requests produce identical output
+		if seq.params.Seed != -1 {
+			ctx.SetRNGSeed(uint32(seq.params.Seed))
+		}
+
+		// Restore the session's KV cache and skip the prompt prefix it already covers
+		if seq.sessionID != "" {
+			path, nPast, err := restoreSession(s.model, seq.sessions, seq.sessionID, seq.prompt)
+			if err != nil {
+				return err
+			}
+			seq.sessionPath = path
+			seq.nPast = nPast
+			seq.history = append([]llama.Token(nil), seq.prompt[:nPast]...)
+		} else if seq.prefillPath != "" {
+			seq.nPast = restorePrefill(s.model, seq.prefillPath, seq.prompt)
+		}
+		ctx.TruncateKVCache(seq.nPast)
+	} else {
+		ctx.RemoveSequence(slot)
+	}
+
+	seq.pending = seq.prompt[seq.nPast:]
+	seq.promptDecoded = len(seq.pending) + seq.imagePositions()
+	seq.startedAt = time.Now()
+	if err := s.decodeImages(seq); err != nil {
+		ctx.RemoveSequence(slot)
+		return err
+	}
+	return nil
+}
+
+// step decodes the pending tokens of all active sequences in one batch and
+// samples the next token for every sequence that has finished its prompt
+func (s *batchScheduler) step() {
+	s.model.mutex.Lock()
+	defer s.model.mutex.Unlock()
+
+	// Stop cancelled requests before spending any more work on them
+	for _, seq := range s.slots {
+		if seq != nil && seq.ctx.Err() != nil {
+			s.finish(seq, seq.ctx.Err())
+		}
+	}
+
+	// A sequence generating alone decodes the tokens proposed by the draft
+	// model instead of one token at a time
+	if seq := s.speculativeSequence(); seq != nil {
+		s.speculate(seq)
+		return
+	}
+
+	s.batch.Clear()
+	budget := s.model.batchSize()
+	var entries []batchEntry
+
+	for _, seq := range s.slots {
+		if seq == nil || budget == 0 {
+			continue
+		}
+
+		// Long prompts are decoded over several steps
+		n := len(seq.pending)
+		if n > budget {
+			n = budget
+		}
+		for j := 0; j < n; j++ {
+			// Cannot fail: the budget never exceeds the batch capacity
+			logits := j == len(seq.pending)-1
+			s.batch.Add(seq.pending[j], seq.nPast+j, seq.slot, logits)
+			entries = append(entries, batchEntry{seq: seq, logits: logits})
+		}
+
+		if seq.sessionPath != "" {
+			seq.history = append(seq.history, seq.pending[:n]...)
+		}
+		seq.nPast += n
+		seq.pending = seq.pending[n:]
+		budget -= n
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	// Decode the batch, splitting it when the KV cache is too fragmented to
+	// fit it in one piece
+	chunk := len(entries)
+	for offset := 0; offset < len(entries); {
+		n := chunk
+		if offset+n > len(entries) {
+			n = len(entries) - offset
+		}
+
+		spans := startEvalSpans(entries[offset : offset+n])
+		err := s.model.context.DecodeBatch(s.batch, offset, n)
+		for _, span := range spans {
+			span.End()
+		}
+		if errors.Is(err, llama.ErrKVCacheFull) && n > 1 {
+			chunk = n / 2
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("batch evaluation failed: %w", err)
+			for _, entry := range entries[offset:] {
+				s.finish(entry.seq, err)
+			}
+			return
+		}
+
+		// Logits are only valid until the next decode, so sample now
+		for i := offset; i < offset+n; i++ {
+			if entries[i].logits {
+				s.sampleNext(entries[i].seq, i-offset)
+			}
+		}
+		offset += n
+	}
+}
+
+// sampleNext samples a sequence's next token from the i-th logits of the last decode
+func (s *batchScheduler) sampleNext(seq *sequence, i int) {
+	ctx := s.model.context
+
+	start := time.Now()
+	token, err := seq.sampler.Sample(ctx, i)
+	if seq.samples == 0 {
+		seq.sampleStart = start
+	}
+	seq.sampleTime += time.Since(start)
+	seq.samples++
+	if err != nil {
+		s.finish(seq, fmt.Errorf("token sampling failed: %w", err))
+		return
+	}
+	s.emit(seq, token, i)
+}
+
+// emit appends a sampled token to a sequence's output and makes it the next
+// token to decode. It reports whether the sequence continues, after finishing
+// it at the end of sequence, the token limit, a stop sequence or the end of
+// the context. i indexes the logits the token was sampled from.
+func (s *batchScheduler) emit(seq *sequence, token llama.Token, i int) bool {
+	ctx := s.model.context
+
+	// Stop at end of sequence (also reached once a grammar is complete)
+	if token == s.model.model.TokenEOS() {
+		s.finish(seq, nil)
+		return false
+	}
+
+	if seq.params.Logprobs > 0 {
+		logprob, err := tokenLogprobs(ctx, i, token, seq.params.Logprobs)
+		if err != nil {
+			s.finish(seq, fmt.Errorf("logprobs failed: %w", err))
+			return false
+		}
+		seq.logprobs = append(seq.logprobs, logprob)
+	}
+
+	seq.tokens = append(seq.tokens, token)
+	seq.pending = []llama.Token{token}
+
+	if len(seq.tokens) >= seq.maxTokens {
+		s.finish(seq, nil)
+		return false
+	}
+
+	// Check for stop sequences, trimming the response at the first match
+	if len(seq.stop) > 0 || seq.stream != nil {
+		text, _ := ctx.Detokenize(seq.tokens)
+		for _, stop := range seq.stop {
+			if idx := strings.Index(text, stop); idx >= 0 {
+				seq.text = text[:idx]
+				seq.stopped = true
+				s.finish(seq, nil)
+				return false
+			}
+		}
+
+		if seq.stream != nil {
+			n := streamableLength(text, seq.stop)
+			if n > seq.emitted {
+				seq.stream.push(text[seq.emitted:n])
+				seq.emitted = n
+			}
+		}
+	}
+
+	// Stop once the context is full rather than overrunning the KV cache
+	if contextSize := s.model.Options.ContextSize; contextSize > 0 && seq.nPast >= contextSize {
+		logger.Warnf("Context size %d reached, stopping generation", contextSize)
+		s.finish(seq, nil)
+		return false
+	}
+	return true
+}
+
+// finish removes a sequence from its slot and reports the result. The model
+// mutex must be held.
+func (s *batchScheduler) finish(seq *sequence, err error) {
+	if !s.release(seq) {
+		return
+	}
+
+	ctx := s.model.context
+
+	// Persist the KV cache for the next call in this session
+	if err == nil && seq.sessionPath != "" {
+		if saveErr := ctx.SaveSession(seq.sessionPath, seq.history); saveErr != nil {
+			logger.Warnf("Failed to save session %s: %v", seq.sessionID, saveErr)
+		}
+	}
+
+	if seq.samples > 0 {
+		_, span := tracer.Start(seq.ctx, "Sample", trace.WithTimestamp(seq.sampleStart), trace.WithAttributes(
+			attribute.Int("samples", seq.samples),
+			attribute.Float64("sample_time_ms", float64(seq.sampleTime.Microseconds())/1000),
+		))
+		span.End()
+	}
+
+	if err == nil && !seq.stopped {
+		_, span := tracer.Start(seq.ctx, "Detokenize", trace.WithAttributes(attribute.Int("tokens", len(seq.tokens))))
+		text, detokErr := ctx.Detokenize(seq.tokens)
+		span.End()
+		if detokErr != nil {
+			err = fmt.Errorf("detokenization failed: %w", detokErr)
+		}
+		seq.text = text
+	}
+
+	// Flush the text held back while it could still have become a stop sequence
+	if err == nil && seq.stream != nil && len(seq.text) > seq.emitted {
+		seq.stream.push(seq.text[seq.emitted:])
+		seq.emitted = len(seq.text)
+	}
+
+	ctx.RemoveSequence(seq.slot)
+	seq.finishedAt = time.Now()
+	seq.result <- err
+}
+
+// startEvalSpans starts an Eval span for every sequence with tokens in a
+// decoded batch. The batch is shared, so each span records both its size and
+// the sequence's part of it.
+func startEvalSpans(entries []batchEntry) []trace.Span {
+	counts := make(map[*sequence]int)
+	var order []*sequence
+	for _, entry := range entries {
+		if counts[entry.seq] == 0 {
+			order = append(order, entry.seq)
+		}
+		counts[entry.seq]++
+	}
+
+	spans := make([]trace.Span, 0, len(order))
+	for _, seq := range order {
+		_, span := tracer.Start(seq.ctx, "Eval", trace.WithAttributes(
+			attribute.Int("batch.tokens", len(entries)),
+			attribute.Int("sequence.tokens", counts[seq]),
+		))
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// streamableLength returns how much of the generated text can be streamed.
+// Text that may be the start of a stop sequence or an incomplete UTF-8
+// character is held back until more tokens arrive.
+func streamableLength(text string, stop []string) int {
+	n := len(text)
+	for _, s := range stop {
+		for k := len(s) - 1; k > 0; k-- {
+			if k <= len(text) && strings.HasSuffix(text, s[:k]) {
+				if len(text)-k < n {
+					n = len(text) - k
+				}
+				break
+			}
+		}
+	}
+
+	for i := 0; i < utf8.UTFMax-1 && n > 0; i++ {
+		r, size := utf8.DecodeLastRuneInString(text[:n])
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		n--
+	}
+	return n
+}
+
+// failAll fails every queued and active sequence
+func (s *batchScheduler) failAll(err error) {
+	for _, seq := range s.queue {
+		seq.result <- err
+	}
+	s.queue = nil
+
+	s.model.mutex.Lock()
+	defer s.model.mutex.Unlock()
+
+	for _, seq := range s.slots {
+		if seq != nil {
+			s.finish(seq, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"colossus-cli/internal/llama"
+)
+
+// fakeSlotContext records the sequences a slotTable starts
+type fakeSlotContext struct {
+	size    int
+	started map[*sequence]int
+	err     error
+}
+
+func (f *fakeSlotContext) contextSize() int {
+	return f.size
+}
+
+func (f *fakeSlotContext) start(seq *sequence, slot int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.started[seq] = slot
+	return nil
+}
+
+// newTestSlotTable creates a slot table with parallel slots over a fake
+// context of contextSize cells
+func newTestSlotTable(parallel, contextSize int) (*slotTable, *fakeSlotContext) {
+	ctx := &fakeSlotContext{size: contextSize, started: make(map[*sequence]int)}
+	return &slotTable{ctx: ctx, slots: make([]*sequence, parallel)}, ctx
+}
+
+// newTestSequence creates a sequence reserving promptLen+maxTokens cells
+func newTestSequence(promptLen, maxTokens int, exclusive bool) *sequence {
+	return &sequence{
+		ctx:       context.Background(),
+		prompt:    make([]llama.Token, promptLen),
+		maxTokens: maxTokens,
+		exclusive: exclusive,
+		result:    make(chan error, 1),
+	}
+}
+
+// slotsOf returns the slot of each sequence, or -1 when it is not active
+func slotsOf(table *slotTable, seqs ...*sequence) []int {
+	slots := make([]int, len(seqs))
+	for i, seq := range seqs {
+		slots[i] = -1
+		for slot, active := range table.slots {
+			if active == seq {
+				slots[i] = slot
+			}
+		}
+	}
+	return slots
+}
+
+func equalSlots(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSlotTableAdmitsConcurrentSequences(t *testing.T) {
+	table, ctx := newTestSlotTable(3, 1024)
+	a, b, c, d := newTestSequence(10, 10, false), newTestSequence(10, 10, false),
+		newTestSequence(10, 10, false), newTestSequence(10, 10, false)
+
+	table.queue = append(table.queue, a, b, c, d)
+	table.admit()
+
+	if got := slotsOf(table, a, b, c, d); !equalSlots(got, 0, 1, 2, -1) {
+		t.Fatalf("slots = %v, want [0 1 2 -1]", got)
+	}
+	if len(table.queue) != 1 || table.queue[0] != d {
+		t.Errorf("queue holds %d sequences, want only the fourth", len(table.queue))
+	}
+	if table.reserved != 60 {
+		t.Errorf("reserved = %d, want 60", table.reserved)
+	}
+	if len(ctx.started) != 3 {
+		t.Errorf("%d sequences started, want 3", len(ctx.started))
+	}
+}
+
+func TestSlotTableAdmitsWithinContextSize(t *testing.T) {
+	table, _ := newTestSlotTable(4, 100)
+	a, b, c := newTestSequence(20, 20, false), newTestSequence(20, 20, false), newTestSequence(20, 20, false)
+
+	table.queue = append(table.queue, a, b, c)
+	table.admit()
+
+	// The third sequence would reserve 120 of 100 cells
+	if got := slotsOf(table, a, b, c); !equalSlots(got, 0, 1, -1) {
+		t.Fatalf("slots = %v, want [0 1 -1]", got)
+	}
+
+	table.release(a)
+	table.admit()
+	if got := slotsOf(table, a, b, c); !equalSlots(got, -1, 1, 0) {
+		t.Errorf("slots after release = %v, want [-1 1 0]", got)
+	}
+}
+
+func TestSlotTableReusesReleasedSlots(t *testing.T) {
+	table, _ := newTestSlotTable(2, 0)
+	a, b, c := newTestSequence(5, 5, false), newTestSequence(5, 5, false), newTestSequence(5, 5, false)
+
+	table.queue = append(table.queue, a, b, c)
+	table.admit()
+	if got := slotsOf(table, a, b, c); !equalSlots(got, 0, 1, -1) {
+		t.Fatalf("slots = %v, want [0 1 -1]", got)
+	}
+
+	if !table.release(b) {
+		t.Fatal("release of an active sequence returned false")
+	}
+	if table.release(b) {
+		t.Error("second release returned true")
+	}
+	table.admit()
+	if got := slotsOf(table, a, b, c); !equalSlots(got, 0, -1, 1) {
+		t.Errorf("slots after release = %v, want [0 -1 1]", got)
+	}
+	if table.reserved != 20 {
+		t.Errorf("reserved = %d, want 20", table.reserved)
+	}
+}
+
+func TestSlotTableSerializesExclusiveSequences(t *testing.T) {
+	table, _ := newTestSlotTable(3, 0)
+	a := newTestSequence(5, 5, false)
+	exclusive := newTestSequence(5, 5, true)
+	b := newTestSequence(5, 5, false)
+
+	// An exclusive sequence waits for the model to be idle, and holds back
+	// the sequences queued after it
+	table.queue = append(table.queue, a, exclusive, b)
+	table.admit()
+	if got := slotsOf(table, a, exclusive, b); !equalSlots(got, 0, -1, -1) {
+		t.Fatalf("slots = %v, want [0 -1 -1]", got)
+	}
+
+	table.release(a)
+	table.admit()
+	if got := slotsOf(table, a, exclusive, b); !equalSlots(got, -1, 0, -1) {
+		t.Fatalf("slots once idle = %v, want [-1 0 -1]", got)
+	}
+
+	// Nothing joins a running exclusive sequence, even with free slots
+	table.admit()
+	if got := slotsOf(table, exclusive, b); !equalSlots(got, 0, -1) {
+		t.Fatalf("slots while exclusive = %v, want [0 -1]", got)
+	}
+
+	table.release(exclusive)
+	table.admit()
+	if got := slotsOf(table, exclusive, b); !equalSlots(got, -1, 0) {
+		t.Errorf("slots after exclusive = %v, want [-1 0]", got)
+	}
+}
+
+func TestSlotTableReportsStartErrors(t *testing.T) {
+	table, ctx := newTestSlotTable(2, 0)
+	ctx.err = errors.New("session file is corrupt")
+	a := newTestSequence(5, 5, true)
+
+	table.queue = append(table.queue, a)
+	table.admit()
+
+	if err := <-a.result; !errors.Is(err, ctx.err) {
+		t.Errorf("result = %v, want %v", err, ctx.err)
+	}
+	if table.activeCount() != 0 || table.reserved != 0 || len(table.queue) != 0 {
+		t.Errorf("failed sequence left %d active, %d reserved, %d queued",
+			table.activeCount(), table.reserved, len(table.queue))
+	}
+}
+
+func TestSlotTableDropsCancelledSequences(t *testing.T) {
+	table, _ := newTestSlotTable(2, 1024)
+	a, b := newTestSequence(10, 10, false), newTestSequence(10, 10, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.ctx = ctx
+
+	table.queue = append(table.queue, a, b)
+	table.admit()
+
+	if err := <-a.result; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled sequence failed with %v, want %v", err, context.Canceled)
+	}
+	if got := slotsOf(table, a, b); !equalSlots(got, -1, 0) {
+		t.Errorf("slots = %v, want [-1 0]", got)
+	}
+}
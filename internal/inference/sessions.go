@@ -0,0 +1,116 @@
+package inference
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ErrSessionNotFound is returned when a session does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+var validSessionID = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+
+// SessionStore keeps KV cache session files on disk. A session's last use is
+// tracked by its file modification time, so idle sessions survive restarts
+// until they expire.
+type SessionStore struct {
+	dir         string
+	idleTimeout time.Duration
+}
+
+// NewSessionStore creates a session store in dir. When idleTimeout is
+// positive, sessions unused for longer than that are removed periodically.
+func NewSessionStore(dir string, idleTimeout time.Duration) (*SessionStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	store := &SessionStore{
+		dir:         dir,
+		idleTimeout: idleTimeout,
+	}
+
+	if idleTimeout > 0 {
+		go store.expireLoop()
+	}
+
+	return store, nil
+}
+
+// Path returns the session file path for a session ID
+func (s *SessionStore) Path(id string) (string, error) {
+	if !validSessionID.MatchString(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid session id: %q", id)
+	}
+	return filepath.Join(s.dir, id+".bin"), nil
+}
+
+// Touch marks a session as used now
+func (s *SessionStore) Touch(id string) {
+	path, err := s.Path(id)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// Delete removes a session
+func (s *SessionStore) Delete(id string) error {
+	path, err := s.Path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// ExpireIdle removes sessions that have not been used within the idle timeout
+func (s *SessionStore) ExpireIdle() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		logger.Warnf("Failed to read sessions directory: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-s.idleTimeout)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".bin" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+			logger.Debugf("Expired idle session: %s", entry.Name())
+		}
+	}
+}
+
+// expireLoop periodically removes idle sessions
+func (s *SessionStore) expireLoop() {
+	interval := s.idleTimeout / 2
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.ExpireIdle()
+	}
+}
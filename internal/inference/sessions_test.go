@@ -0,0 +1,105 @@
+package inference
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionStorePath(t *testing.T) {
+	store, err := NewSessionStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id: "chat-42"},
+		{id: "user_1.session"},
+		{id: "", wantErr: true},
+		{id: ".", wantErr: true},
+		{id: "..", wantErr: true},
+		{id: "../escape", wantErr: true},
+		{id: "a/b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			path, err := store.Path(tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Path(%q) = %q, want an error", tt.id, path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Path(%q): %v", tt.id, err)
+			}
+			if want := filepath.Join(store.dir, tt.id+".bin"); path != want {
+				t.Errorf("Path(%q) = %q, want %q", tt.id, path, want)
+			}
+		})
+	}
+}
+
+func TestSessionStoreDelete(t *testing.T) {
+	store, err := NewSessionStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+
+	path, _ := store.Path("chat")
+	if err := os.WriteFile(path, []byte("kv"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete("chat"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("session file still exists: %v", err)
+	}
+	if err := store.Delete("chat"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second Delete = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionStoreExpireIdle(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSessionStore(dir, 0)
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+	store.idleTimeout = time.Hour
+
+	old := time.Now().Add(-2 * time.Hour)
+	files := map[string]time.Time{
+		"idle.bin":    old,
+		"touched.bin": old,
+		"recent.bin":  time.Now(),
+		"notes.txt":   old,
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("kv"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.Touch("touched")
+	store.ExpireIdle()
+
+	for name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists, want := err == nil, name != "idle.bin"; exists != want {
+			t.Errorf("%s exists = %v, want %v", name, exists, want)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package inference
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"colossus-cli/internal/llama"
+)
+
+// defaultDraftTokens is the number of tokens the draft model proposes per
+// step when the model options do not set it
+const defaultDraftTokens = 4
+
+// loadDraftModel loads the draft model of a model for speculative decoding.
+// It needs the same vocabulary as the model, whose context size it shares so
+// that it can follow the model's sequences to the end.
+func loadDraftModel(name string, options *ModelOptions, vocabSize int) (*LlamaCppModel, error) {
+	path := options.DraftModel
+	logger.Infof("Loading draft model for %s from %s", name, path)
+
+	// The options were checked when loading the model
+	kvCacheType, _ := resolveKVCacheType(options)
+
+	var model *llama.Model
+	var llamaCtx *llama.Context
+	gpuLayers, err := loadWithGPUFallback(options.GPULayers, func(gpuLayers int) error {
+		var err error
+		model, err = llama.LoadModel(path, llama.ModelParams{
+			UseMemoryMap:  options.UseMemoryMap,
+			UseMemoryLock: options.UseMemoryLock,
+			GPULayers:     gpuLayers,
+			TensorSplit:   options.TensorSplit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load draft model from %s: %w", path, err)
+		}
+
+		// The draft model follows the positions of the target model, so it
+		// shares its RoPE scaling
+		contextParams := newContextParams(options, kvCacheType)
+		if err := ropeContextParams(&contextParams, options); err != nil {
+			model.Free()
+			return err
+		}
+		llamaCtx, err = model.NewContext(contextParams)
+		if err != nil {
+			model.Free()
+			return fmt.Errorf("failed to create context for draft model of %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Proposed tokens are verified by their ID, so both models must agree on them
+	if draftVocab := model.GetVocabSize(); draftVocab != vocabSize {
+		llamaCtx.Free()
+		model.Free()
+		return nil, fmt.Errorf("draft model %s has a vocabulary of %d tokens, but %s has %d", path, draftVocab, name, vocabSize)
+	}
+
+	return &LlamaCppModel{
+		Name:     name + " (draft)",
+		Path:     path,
+		LoadedAt: time.Now(),
+		Info: &ModelInfo{
+			Name:            name + " (draft)",
+			Path:            path,
+			ContextSize:     llamaCtx.GetContextSize(),
+			VocabSize:       vocabSize,
+			Parameters:      estimateParameters(path),
+			GPULayers:       options.GPULayers,
+			ActualGPULayers: gpuLayers,
+		},
+		Options: options,
+		model:   model,
+		context: llamaCtx,
+	}, nil
+}
+
+// speculativeSequence returns the sequence to decode speculatively in the
+// next step, if any. Speculative decoding needs a draft model and a sequence
+// that generates alone, since rejected tokens are removed from the whole KV
+// cache. Grammars and Mirostat keep state across tokens that cannot be rolled
+// back, and the DRY penalty depends on every generated token, so they are
+// sampled one token at a time, as are sequences with images, which the draft
+// model cannot read.
+func (s *batchScheduler) speculativeSequence() *sequence {
+	if s.model.draft == nil || s.activeCount() != 1 {
+		return nil
+	}
+
+	var seq *sequence
+	for _, active := range s.slots {
+		if active != nil {
+			seq = active
+		}
+	}
+	if len(seq.pending) != 1 || seq.samples == 0 {
+		return nil
+	}
+	if seq.params.Grammar != nil || seq.params.MirostatMode != 0 || seq.params.DRYMultiplier > 0 || len(seq.images) > 0 {
+		return nil
+	}
+	if s.draftTokens(seq) == 0 {
+		return nil
+	}
+	return seq
+}
+
+// draftTokens returns how many tokens the draft model proposes for a
+// sequence, limited by the tokens left to generate, the batch size and the
+// room left in the context
+func (s *batchScheduler) draftTokens(seq *sequence) int {
+	n := s.model.Options.DraftTokens
+	if n <= 0 {
+		n = defaultDraftTokens
+	}
+	if left := seq.maxTokens - len(seq.tokens) - 1; n > left {
+		n = left
+	}
+	if limit := s.model.batchSize() - 1; n > limit {
+		n = limit
+	}
+	if contextSize := s.model.Options.ContextSize; contextSize > 0 && n > contextSize-seq.nPast-1 {
+		n = contextSize - seq.nPast - 1
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// speculate runs one step of speculative decoding (Leviathan et al., "Fast
+// Inference from Transformers via Speculative Decoding"). The draft model
+// proposes tokens one at a time, which the model then scores in a single
+// batch together with the sequence's pending token. A proposed token x is
+// accepted with probability min(1, p(x)/q(x)), where p and q are the
+// distributions of the model and the draft model. The first rejected token is
+// replaced by a sample from max(0, p-q), and when all are accepted one more
+// token is sampled from p, so the output follows the model's distribution.
+func (s *batchScheduler) speculate(seq *sequence) {
+	if seq.rng == nil {
+		seed := time.Now().UnixNano()
+		if seq.params.Seed != -1 {
+			seed = seq.params.Seed
+		}
+		seq.rng = rand.New(rand.NewSource(seed))
+	}
+
+	start := time.Now()
+	drafts, q, err := s.draft(seq, s.draftTokens(seq))
+	if err != nil {
+		s.finish(seq, fmt.Errorf("draft model failed: %w", err))
+		return
+	}
+
+	// Score the pending token and the proposed tokens in one batch
+	ctx := s.model.context
+	s.batch.Clear()
+	s.batch.Add(seq.pending[0], seq.nPast, seq.slot, true)
+	for j, token := range drafts {
+		s.batch.Add(token, seq.nPast+1+j, seq.slot, true)
+	}
+	entries := make([]batchEntry, s.batch.Len())
+	for j := range entries {
+		entries[j] = batchEntry{seq: seq, logits: true}
+	}
+	spans := startEvalSpans(entries)
+	err = ctx.DecodeBatch(s.batch, 0, s.batch.Len())
+	for _, span := range spans {
+		span.End()
+	}
+	if err != nil {
+		s.finish(seq, fmt.Errorf("batch evaluation failed: %w", err))
+		return
+	}
+
+	// Decide which tokens to keep before emitting any, since emitting may
+	// finish the sequence
+	window := append([]llama.Token(nil), seq.sampler.lastTokens...)
+	var output []llama.Token
+	for i := 0; ; i++ {
+		p := tokenDistribution(ctx.GetLogitsAt(i), seq.params, window)
+		if i == len(drafts) {
+			output = append(output, sampleDistribution(p, seq.rng))
+			break
+		}
+
+		token, accepted := verifyDraftToken(p, q[i], drafts[i], seq.rng)
+		output = append(output, token)
+		if !accepted {
+			break
+		}
+		window = appendWindow(window, token, seq.params.RepeatLastN)
+	}
+	accepted := len(output) - 1
+	logger.Debugf("Speculative decoding accepted %d of %d draft tokens", accepted, len(drafts))
+
+	// Drop the rejected tokens from both KV caches
+	ctx.TruncateKVCache(seq.nPast + 1 + accepted)
+	if kept := len(seq.prompt) + len(seq.tokens) + accepted; kept < s.draftPast {
+		s.model.draft.context.TruncateKVCache(kept)
+		s.draftPast = kept
+	}
+
+	seq.sampleTime += time.Since(start)
+	seq.samples += len(output)
+
+	if seq.sessionPath != "" {
+		seq.history = append(seq.history, seq.pending[0])
+		seq.history = append(seq.history, output[:accepted]...)
+	}
+	seq.nPast++
+	for i, token := range output {
+		seq.sampler.accept(token)
+		if !s.emit(seq, token, i) {
+			return
+		}
+		// Accepted tokens are already in the KV cache
+		if i < accepted {
+			seq.nPast++
+			seq.pending = nil
+		}
+	}
+}
+
+// draft proposes up to n tokens for a sequence with the draft model, and
+// returns them with the distributions they were sampled from. Proposing stops
+// early at the end of sequence.
+func (s *batchScheduler) draft(seq *sequence, n int) ([]llama.Token, [][]float64, error) {
+	draft := s.model.draft
+	ctx := draft.context
+
+	// Catch up with the tokens the draft model has not seen yet, which are
+	// all of them for a new sequence
+	if s.draftSeq != seq {
+		ctx.TruncateKVCache(0)
+		s.draftSeq = seq
+		s.draftPast = 0
+	}
+	known := make([]llama.Token, 0, len(seq.prompt)+len(seq.tokens))
+	known = append(known, seq.prompt...)
+	known = append(known, seq.tokens...)
+
+	last := 0
+	for s.draftPast < len(known) {
+		s.draftBatch.Clear()
+		for s.draftPast < len(known) && s.draftBatch.Len() < draft.batchSize() {
+			logits := s.draftPast == len(known)-1
+			s.draftBatch.Add(known[s.draftPast], s.draftPast, 0, logits)
+			s.draftPast++
+		}
+		if err := ctx.DecodeBatch(s.draftBatch, 0, s.draftBatch.Len()); err != nil {
+			ctx.TruncateKVCache(0)
+			s.draftSeq = nil
+			return nil, nil, err
+		}
+		last = s.draftBatch.Len() - 1
+	}
+
+	window := append([]llama.Token(nil), seq.sampler.lastTokens...)
+	drafts := make([]llama.Token, 0, n)
+	q := make([][]float64, 0, n)
+	for {
+		dist := tokenDistribution(ctx.GetLogitsAt(last), seq.params, window)
+		token := sampleDistribution(dist, seq.rng)
+		drafts = append(drafts, token)
+		q = append(q, dist)
+		if len(drafts) == n || token == draft.model.TokenEOS() {
+			return drafts, q, nil
+		}
+
+		window = appendWindow(window, token, seq.params.RepeatLastN)
+		s.draftBatch.Clear()
+		s.draftBatch.Add(token, s.draftPast, 0, true)
+		if err := ctx.DecodeBatch(s.draftBatch, 0, 1); err != nil {
+			ctx.TruncateKVCache(0)
+			s.draftSeq = nil
+			return nil, nil, err
+		}
+		s.draftPast++
+		last = 0
+	}
+}
+
+// tokenDistribution returns the probability of every token after applying the
+// sampling parameters to logits the way tokenSampler does: repetition
+// penalties over lastTokens, then top-k, top-p, min-p or top-a and the temperature
+func tokenDistribution(logits []float32, params *samplingParams, lastTokens []llama.Token) []float64 {
+	scores := make([]float32, len(logits))
+	copy(scores, logits)
+
+	counts := make(map[llama.Token]int)
+	for _, token := range lastTokens {
+		counts[token]++
+	}
+	for token, count := range counts {
+		if int(token) < 0 || int(token) >= len(scores) {
+			continue
+		}
+		if scores[token] <= 0 {
+			scores[token] *= params.RepeatPenalty
+		} else {
+			scores[token] /= params.RepeatPenalty
+		}
+		scores[token] -= float32(count)*params.FrequencyPenalty + params.PresencePenalty
+	}
+
+	k := params.TopK
+	if k <= 0 || k > len(scores) {
+		k = len(scores)
+	}
+	top := topIndices(scores, k)
+	if len(top) == 0 {
+		return make([]float64, len(scores))
+	}
+
+	// Keep the most likely tokens whose probabilities add up to top_p
+	probs := softmax(scores, top, 1)
+	if params.TopP < 1 {
+		cumulative := 0.0
+		for i, p := range probs {
+			cumulative += p
+			if cumulative >= float64(params.TopP) {
+				top = top[:i+1]
+				break
+			}
+		}
+	}
+
+	// Keep the tokens likely enough relative to the most likely one: at least
+	// min_p times or, for top-a, top_a times its probability squared
+	if params.MinP > 0 || params.TopA > 0 {
+		probs = softmax(scores, top, 1)
+		threshold := math.Max(float64(params.MinP)*probs[0], float64(params.TopA)*probs[0]*probs[0])
+		keep := 1
+		for keep < len(top) && probs[keep] >= threshold {
+			keep++
+		}
+		top = top[:keep]
+	}
+
+	temperature := params.Temperature
+	if temperature <= 0 {
+		temperature = 1
+	}
+	dist := make([]float64, len(scores))
+	for i, p := range softmax(scores, top, temperature) {
+		dist[top[i]] = p
+	}
+	return dist
+}
+
+// softmax returns the probabilities of the scores at indices, which are
+// sorted in descending order of score, divided by the temperature
+func softmax(scores []float32, indices []int, temperature float32) []float64 {
+	maxScore := float64(scores[indices[0]] / temperature)
+	probs := make([]float64, len(indices))
+	sum := 0.0
+	for i, index := range indices {
+		probs[i] = math.Exp(float64(scores[index]/temperature) - maxScore)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
+
+// sampleDistribution samples a token from a distribution of probabilities
+// that add up to about 1
+func sampleDistribution(dist []float64, rng *rand.Rand) llama.Token {
+	sum := 0.0
+	for _, p := range dist {
+		sum += p
+	}
+
+	u := rng.Float64() * sum
+	last := 0
+	for token, p := range dist {
+		if p == 0 {
+			continue
+		}
+		if u < p {
+			return llama.Token(token)
+		}
+		u -= p
+		last = token
+	}
+	return llama.Token(last)
+}
+
+// verifyDraftToken accepts a token proposed by the draft model with
+// probability min(1, p(token)/q(token)). A rejected token is replaced by a
+// sample from the residual distribution, so that the token returned follows p.
+func verifyDraftToken(p, q []float64, token llama.Token, rng *rand.Rand) (llama.Token, bool) {
+	if rng.Float64()*q[token] < p[token] {
+		return token, true
+	}
+	return sampleResidual(p, q, rng), false
+}
+
+// sampleResidual samples the replacement of a rejected draft token from
+// max(0, p-q), normalized. It falls back to p when the two are equal.
+func sampleResidual(p, q []float64, rng *rand.Rand) llama.Token {
+	residual := make([]float64, len(p))
+	sum := 0.0
+	for i := range p {
+		if d := p[i] - q[i]; d > 0 {
+			residual[i] = d
+			sum += d
+		}
+	}
+	if sum == 0 {
+		return sampleDistribution(p, rng)
+	}
+	return sampleDistribution(residual, rng)
+}
+
+// appendWindow appends a token to a repetition window of at most n tokens
+func appendWindow(window []llama.Token, token llama.Token, n int) []llama.Token {
+	if n <= 0 {
+		return window
+	}
+	if len(window) == n {
+		window = window[1:]
+	}
+	return append(window, token)
+}
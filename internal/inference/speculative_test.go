@@ -0,0 +1,136 @@
+package inference
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+
+	"colossus-cli/internal/types"
+)
+
+// TestVerifyDraftTokenFollowsModelDistribution checks that tokens proposed
+// from q and verified against p, with rejected tokens resampled from the
+// residual, are distributed like p
+func TestVerifyDraftTokenFollowsModelDistribution(t *testing.T) {
+	tests := []struct {
+		name string
+		p, q []float64
+	}{
+		{name: "draft close to model", p: []float64{0.5, 0.25, 0.15, 0.1}, q: []float64{0.4, 0.3, 0.2, 0.1}},
+		{name: "draft far from model", p: []float64{0.1, 0.2, 0.3, 0.4}, q: []float64{0.7, 0.1, 0.1, 0.1}},
+		{name: "draft misses a token", p: []float64{0.3, 0.3, 0.2, 0.2}, q: []float64{0.5, 0.5, 0, 0}},
+		{name: "same distributions", p: []float64{0.25, 0.25, 0.25, 0.25}, q: []float64{0.25, 0.25, 0.25, 0.25}},
+	}
+
+	const samples = 100000
+	// 99.9th percentile of the chi-square distribution with 3 degrees of freedom
+	const critical = 16.27
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			counts := make([]int, len(tt.p))
+			rejected := 0
+			for i := 0; i < samples; i++ {
+				draft := sampleDistribution(tt.q, rng)
+				token, accepted := verifyDraftToken(tt.p, tt.q, draft, rng)
+				if accepted && token != draft {
+					t.Fatalf("accepted token %d, proposed %d", token, draft)
+				}
+				if !accepted {
+					rejected++
+				}
+				counts[token]++
+			}
+
+			chiSquare := 0.0
+			for token, p := range tt.p {
+				expected := p * samples
+				d := float64(counts[token]) - expected
+				chiSquare += d * d / expected
+			}
+			if chiSquare > critical {
+				t.Errorf("histogram %v does not follow %v: chi-square %.2f > %.2f", counts, tt.p, chiSquare, critical)
+			}
+
+			// Tokens are rejected with probability 1 - sum(min(p, q))
+			overlap := 0.0
+			for token := range tt.p {
+				overlap += min(tt.p[token], tt.q[token])
+			}
+			if got, want := float64(rejected)/samples, 1-overlap; got < want-0.01 || got > want+0.01 {
+				t.Errorf("rejection rate = %.3f, want %.3f", got, want)
+			}
+		})
+	}
+}
+
+func TestSampleResidual(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	p := []float64{0.5, 0.3, 0.2}
+	q := []float64{0.6, 0.1, 0.3}
+
+	// Only token 1 has p > q
+	for i := 0; i < 100; i++ {
+		if token := sampleResidual(p, q, rng); token != 1 {
+			t.Fatalf("sampleResidual() = %d, want 1", token)
+		}
+	}
+
+	// Equal distributions have no residual, so p is sampled
+	counts := make([]int, len(p))
+	for i := 0; i < 10000; i++ {
+		counts[sampleResidual(p, p, rng)]++
+	}
+	for token, c := range counts {
+		if c == 0 {
+			t.Errorf("token %d never sampled from p: %v", token, counts)
+		}
+	}
+}
+
+// BenchmarkSpeculativeDecoding compares generating with and without a draft
+// model. It needs the llama.cpp bindings and GGUF models in
+// COLOSSUS_BENCH_MODEL and COLOSSUS_BENCH_DRAFT_MODEL.
+func BenchmarkSpeculativeDecoding(b *testing.B) {
+	modelPath, draftPath := os.Getenv("COLOSSUS_BENCH_MODEL"), os.Getenv("COLOSSUS_BENCH_DRAFT_MODEL")
+	if modelPath == "" || draftPath == "" {
+		b.Skip("COLOSSUS_BENCH_MODEL and COLOSSUS_BENCH_DRAFT_MODEL are not set")
+	}
+
+	for _, bm := range []struct {
+		name  string
+		draft string
+	}{
+		{name: "standard", draft: ""},
+		{name: "speculative", draft: draftPath},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			engine := NewLlamaCppEngine()
+			defer engine.Shutdown()
+			options := DefaultModelOptions()
+			options.DraftModel = bm.draft
+			if err := engine.LoadModel("bench", modelPath, options); err != nil {
+				b.Fatal(err)
+			}
+
+			seed := int64(1)
+			req := &types.GenerateRequest{
+				Model:   "bench",
+				Prompt:  "Write a short story about a lighthouse keeper.",
+				Options: &types.Options{NumPredict: 128, Seed: &seed},
+			}
+			tokens := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := engine.Generate(context.Background(), req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				tokens += resp.EvalCount
+			}
+			b.ReportMetric(float64(tokens)/b.Elapsed().Seconds(), "tokens/s")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package inference
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+)
+
+// visionArchitectures are the GGUF architectures of multimodal models, whose
+// vision encoder is looked for next to the model when no projector is set
+var visionArchitectures = map[string]bool{
+	"llava":     true,
+	"moondream": true,
+}
+
+// findProjector returns the path of the vision encoder of a model: the
+// projector set in the options, or else, for a model with a vision
+// architecture, the "mmproj" file next to it. It returns "" for text models.
+func findProjector(path string, options *ModelOptions) (string, error) {
+	if options.Projector != "" {
+		return options.Projector, nil
+	}
+
+	info, err := model.ValidateModel(path)
+	if err != nil || !visionArchitectures[strings.ToLower(info.Architecture)] {
+		return "", nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*mmproj*.gguf"))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no vision encoder found for %s model %s: set projector in its options file", info.Architecture, path)
+	}
+	return matches[0], nil
+}
+
+// loadVisionEncoder loads the vision encoder of a multimodal model, if it
+// has one, and checks that it produces embeddings the model can read
+func loadVisionEncoder(name, path string, options *ModelOptions, context *llama.Context) (*llama.VisionEncoder, error) {
+	projector, err := findProjector(path, options)
+	if err != nil || projector == "" {
+		return nil, err
+	}
+
+	logger.Infof("Loading vision encoder for %s from %s", name, projector)
+	vision, err := llama.LoadVisionEncoder(projector)
+	if err != nil {
+		return nil, err
+	}
+	if !vision.Compatible(context) {
+		vision.Free()
+		return nil, fmt.Errorf("vision encoder %s does not match model %s", projector, name)
+	}
+	return vision, nil
+}
+
+// embedImages encodes the images of a request with the model's vision
+// encoder. The embeddings must be freed after use.
+func embedImages(m *LlamaCppModel, images [][]byte) ([]*llama.ImageEmbed, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	if m.vision == nil {
+		return nil, fmt.Errorf("model %s does not support images", m.Name)
+	}
+
+	embeds := make([]*llama.ImageEmbed, 0, len(images))
+	for i, data := range images {
+		if contentType := http.DetectContentType(data); contentType != "image/png" && contentType != "image/jpeg" {
+			freeImages(embeds)
+			return nil, fmt.Errorf("image %d is %s, not a PNG or JPEG image", i+1, contentType)
+		}
+
+		embed, err := m.vision.EmbedImage(data, m.Options.Threads)
+		if err != nil {
+			freeImages(embeds)
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		embeds = append(embeds, embed)
+	}
+	return embeds, nil
+}
+
+// freeImages frees image embeddings
+func freeImages(embeds []*llama.ImageEmbed) {
+	for _, embed := range embeds {
+		embed.Free()
+	}
+}
+
+// imagePositions returns the number of KV cache positions the images of a
+// sequence take before its prompt
+func (seq *sequence) imagePositions() int {
+	n := 0
+	for _, image := range seq.images {
+		n += image.Positions()
+	}
+	return n
+}
+
+// decodeImages decodes the images of a sequence into its KV cache ahead of
+// its prompt. The model mutex must be held.
+func (s *batchScheduler) decodeImages(seq *sequence) error {
+	for _, image := range seq.images {
+		if err := s.model.context.DecodeImageEmbed(image, seq.slot, seq.nPast, s.model.batchSize()); err != nil {
+			return fmt.Errorf("image evaluation failed: %w", err)
+		}
+		seq.nPast += image.Positions()
+	}
+	return nil
+}
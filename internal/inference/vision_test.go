@@ -0,0 +1,81 @@
+package inference
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/llama"
+)
+
+func TestFindProjector(t *testing.T) {
+	tests := []struct {
+		name         string
+		architecture string
+		projector    string
+		mmproj       bool
+		want         string
+		wantErr      bool
+	}{
+		{name: "text model", architecture: "llama"},
+		{name: "text model with projector file", architecture: "llama", mmproj: true},
+		{name: "vision model", architecture: "llava", mmproj: true, want: "mmproj-model-f16.gguf"},
+		{name: "vision model without projector", architecture: "llava", wantErr: true},
+		{name: "projector in options", architecture: "llava", projector: "/models/encoder.gguf", want: "/models/encoder.gguf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeGGUF(t, dir, ggufKV{"general.architecture", tt.architecture})
+			if tt.mmproj {
+				if err := os.WriteFile(filepath.Join(dir, "mmproj-model-f16.gguf"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := findProjector(path, &ModelOptions{Projector: tt.projector})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findProjector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.mmproj && tt.want != "" {
+				tt.want = filepath.Join(dir, tt.want)
+			}
+			if got != tt.want {
+				t.Errorf("findProjector() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedImages(t *testing.T) {
+	textModel := &LlamaCppModel{Name: "tinyllama"}
+	visionModel := &LlamaCppModel{Name: "llava", vision: &llama.VisionEncoder{}}
+
+	tests := []struct {
+		name    string
+		model   *LlamaCppModel
+		images  [][]byte
+		wantErr string
+	}{
+		{name: "no images", model: textModel},
+		{name: "text model", model: textModel, images: [][]byte{[]byte("\x89PNG\r\n\x1a\n")}, wantErr: "does not support images"},
+		{name: "not an image", model: visionModel, images: [][]byte{[]byte("hello")}, wantErr: "image 1 is text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embeds, err := embedImages(tt.model, tt.images)
+			if tt.wantErr == "" {
+				if err != nil || embeds != nil {
+					t.Errorf("embedImages() = %v, %v, want no embeddings", embeds, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("embedImages() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
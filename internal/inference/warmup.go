@@ -0,0 +1,39 @@
+package inference
+
+import (
+	"time"
+
+	"colossus-cli/internal/llama"
+)
+
+// warmUpPrompt is evaluated by the warm-up run of a model
+const warmUpPrompt = "hi"
+
+// promptEvaluator evaluates prompts into a KV cache, as a *llama.Context does
+type promptEvaluator interface {
+	Tokenize(text string, addBOS bool) ([]llama.Token, error)
+	Eval(tokens []llama.Token, nPast int) error
+	TruncateKVCache(nPast int)
+}
+
+// warmUp evaluates a short prompt with a newly loaded model, so that its
+// first request does not wait for GPU kernels to be compiled and compute
+// buffers to be allocated. Nothing is sampled, and the KV cache is cleared
+// afterwards. It returns how long the run took, or 0 if it failed, which
+// only costs the first request its latency.
+func warmUp(name string, ctx promptEvaluator) time.Duration {
+	start := time.Now()
+	tokens, err := ctx.Tokenize(warmUpPrompt, true)
+	if err == nil {
+		err = ctx.Eval(tokens, 0)
+		ctx.TruncateKVCache(0)
+	}
+	if err != nil {
+		logger.Warnf("Warm-up run of model %s failed: %v", name, err)
+		return 0
+	}
+
+	elapsed := time.Since(start)
+	logger.Infof("Warmed up model %s in %s", name, elapsed.Round(time.Millisecond))
+	return elapsed
+}
@@ -0,0 +1,120 @@
+package inference
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/llama"
+	"colossus-cli/internal/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// coldEvaluator is a prompt evaluator whose first evaluation pays setupTime,
+// like a GPU compiling its kernels and allocating its buffers
+type coldEvaluator struct {
+	setupTime time.Duration
+	evalErr   error
+
+	evals    int
+	kvCached int
+}
+
+func (e *coldEvaluator) Tokenize(text string, addBOS bool) ([]llama.Token, error) {
+	return make([]llama.Token, len(strings.Fields(text))+1), nil
+}
+
+func (e *coldEvaluator) Eval(tokens []llama.Token, nPast int) error {
+	if e.evals == 0 {
+		time.Sleep(e.setupTime)
+	}
+	e.evals++
+	if e.evalErr != nil {
+		return e.evalErr
+	}
+	e.kvCached = nPast + len(tokens)
+	return nil
+}
+
+func (e *coldEvaluator) TruncateKVCache(nPast int) {
+	e.kvCached = nPast
+}
+
+// timeToFirstToken measures the evaluation of a request's prompt
+func timeToFirstToken(t *testing.T, ctx promptEvaluator) time.Duration {
+	t.Helper()
+	start := time.Now()
+	tokens, _ := ctx.Tokenize("What is the capital of France?", true)
+	if err := ctx.Eval(tokens, 0); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	return time.Since(start)
+}
+
+func TestWarmUp(t *testing.T) {
+	const setupTime = 50 * time.Millisecond
+	hook := test.NewLocal(logrus.StandardLogger())
+	defer hook.Reset()
+
+	cold := &coldEvaluator{setupTime: setupTime}
+	coldTTFT := timeToFirstToken(t, cold)
+
+	warm := &coldEvaluator{setupTime: setupTime}
+	elapsed := warmUp("tinyllama", warm)
+	if elapsed < setupTime {
+		t.Errorf("warm-up took %s, want at least the setup time of %s", elapsed, setupTime)
+	}
+	if warm.kvCached != 0 {
+		t.Errorf("%d tokens left in the KV cache, want the warm-up prompt removed", warm.kvCached)
+	}
+	if entry := hook.LastEntry(); entry == nil || entry.Level != logrus.InfoLevel ||
+		!strings.Contains(entry.Message, "Warmed up model tinyllama") {
+		t.Errorf("last log entry = %v, want the warm-up logged", entry)
+	}
+
+	if warmTTFT := timeToFirstToken(t, warm); warmTTFT >= coldTTFT {
+		t.Errorf("time to first token = %s after warm-up, want less than %s without it", warmTTFT, coldTTFT)
+	}
+}
+
+func TestWarmUpFailure(t *testing.T) {
+	hook := test.NewLocal(logrus.StandardLogger())
+	defer hook.Reset()
+
+	ctx := &coldEvaluator{evalErr: errors.New("decode failed")}
+	if elapsed := warmUp("tinyllama", ctx); elapsed != 0 {
+		t.Errorf("failed warm-up took %s, want 0", elapsed)
+	}
+	if entry := hook.LastEntry(); entry == nil || entry.Level != logrus.WarnLevel ||
+		!strings.Contains(entry.Message, "decode failed") {
+		t.Errorf("last log entry = %v, want the failure logged as a warning", entry)
+	}
+}
+
+func TestWarmUpModelOption(t *testing.T) {
+	tests := []struct {
+		yaml   string
+		warmUp bool
+		want   bool
+	}{
+		{yaml: "", warmUp: true, want: true},
+		{yaml: "warm_up: false\n", warmUp: true, want: false},
+		{yaml: "warm_up: true\n", warmUp: false, want: true},
+	}
+
+	for _, tt := range tests {
+		overrides, err := model.ParseModelOptions([]byte(tt.yaml))
+		if err != nil {
+			t.Fatalf("ParseModelOptions(%q): %v", tt.yaml, err)
+		}
+		options := DefaultModelOptions()
+		options.WarmUp = tt.warmUp
+		ApplyModelOptions(options, overrides)
+		if options.WarmUp != tt.want {
+			t.Errorf("WarmUp = %v with %q over %v, want %v", options.WarmUp, tt.yaml, tt.warmUp, tt.want)
+		}
+	}
+}
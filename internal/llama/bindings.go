@@ -18,6 +18,11 @@ package llama
 #cgo LDFLAGS: -lhipblas -lrocblas -lamdhip64
 #endif
 
+#ifdef GGML_USE_SYCL
+#cgo CFLAGS: -DGGML_USE_SYCL
+#cgo LDFLAGS: -lsycl -lOpenCL -lmkl_core -lmkl_sycl_blas -lmkl_intel_ilp64 -lmkl_tbb_thread -ltbb
+#endif
+
 #include <stdlib.h>
 #include <string.h>
 #include "llama.h"
@@ -55,9 +60,9 @@ int llama_tokenize_wrapper(struct llama_context* ctx, const char* text, int text
     return llama_tokenize(llama_get_model(ctx), text, text_len, tokens, max_tokens, add_bos, special);
 }
 
-// Detokenize tokens
+// Detokenize tokens, without rendering special tokens
 int llama_token_to_piece_wrapper(struct llama_context* ctx, llama_token token, char* buf, int length) {
-    return llama_token_to_piece(llama_get_model(ctx), token, buf, length);
+    return llama_token_to_piece(llama_get_model(ctx), token, buf, length, false);
 }
 
 // Evaluate tokens
@@ -65,31 +70,89 @@ int llama_eval_wrapper(struct llama_context* ctx, llama_token* tokens, int n_tok
     return llama_decode(ctx, llama_batch_get_one(tokens, n_tokens, n_past, 0));
 }
 
-// Sample next token
-llama_token llama_sample_token_wrapper(struct llama_context* ctx, llama_token* candidates, int n_candidates, float temp, float top_p, int top_k) {
-    struct llama_sampling_params params = {
-        .temp = temp,
-        .top_p = top_p,
-        .top_k = top_k,
-        .penalty_repeat = 1.1f,
-        .penalty_freq = 0.0f,
-        .penalty_present = 0.0f,
+// Append a token for a single sequence to a batch
+void llama_batch_add_wrapper(struct llama_batch* batch, llama_token token, llama_pos pos, llama_seq_id seq_id, bool logits) {
+    const int i = batch->n_tokens;
+    batch->token[i] = token;
+    batch->pos[i] = pos;
+    batch->n_seq_id[i] = 1;
+    batch->seq_id[i][0] = seq_id;
+    batch->logits[i] = logits;
+    batch->n_tokens++;
+}
+
+// Decode n tokens of a batch starting at offset
+int llama_decode_range_wrapper(struct llama_context* ctx, struct llama_batch batch, int offset, int n) {
+    struct llama_batch view = {
+        n,
+        batch.token + offset,
+        NULL,
+        batch.pos + offset,
+        batch.n_seq_id + offset,
+        batch.seq_id + offset,
+        batch.logits + offset,
+        0, 0, 0,
     };
-    
-    // This is a simplified sampling - real implementation would be more complex
-    llama_token_data_array candidates_p = {candidates, (size_t)n_candidates, false};
-    
-    if (temp > 0) {
-        llama_sample_temp(ctx, &candidates_p, temp);
-        if (top_p < 1.0f) {
-            llama_sample_nucleus(ctx, &candidates_p, top_p, 1);
-        }
-        if (top_k > 0) {
-            llama_sample_top_k(ctx, &candidates_p, top_k, 1);
-        }
+    return llama_decode(ctx, view);
+}
+
+// Build a candidate array from the logits of the i-th token of the last
+// decoded batch, or of the last token when i is negative.
+// The caller owns the returned buffer and must free it.
+llama_token_data* llama_candidates_wrapper(struct llama_context* ctx, int32_t i, size_t* n_candidates) {
+    const int n_vocab = llama_n_vocab(llama_get_model(ctx));
+    float* logits = i < 0 ? llama_get_logits(ctx) : llama_get_logits_ith(ctx, i);
+
+    llama_token_data* data = (llama_token_data*)malloc(sizeof(llama_token_data) * n_vocab);
+    for (llama_token id = 0; id < n_vocab; id++) {
+        data[id].id = id;
+        data[id].logit = logits[id];
+        data[id].p = 0.0f;
     }
-    
-    return llama_sample_token(ctx, &candidates_p);
+
+    *n_candidates = (size_t)n_vocab;
+    return data;
+}
+
+// Min-p sampling: keep the candidates with probability >= p * max_prob
+void llama_sample_min_p_wrapper(struct llama_context* ctx, llama_token_data_array* candidates, float p) {
+    llama_sample_min_p(ctx, candidates, p, 1);
+}
+
+// Top-a sampling: keep the candidates with probability >= a * max_prob^2.
+// llama.cpp has no top-a sampler, so the candidates are cut here, after
+// llama_sample_softmax sorted them and renormalized their probabilities.
+void llama_sample_top_a_wrapper(struct llama_context* ctx, llama_token_data_array* candidates, float a) {
+    if (a <= 0.0f || candidates->size <= 1) {
+        return;
+    }
+    llama_sample_softmax(ctx, candidates);
+
+    const float max_p = candidates->data[0].p;
+    const float threshold = a * max_p * max_p;
+    size_t keep = 1;
+    while (keep < candidates->size && candidates->data[keep].p >= threshold) {
+        keep++;
+    }
+    candidates->size = keep;
+}
+
+// Create a grammar from rules flattened into a single element array.
+// rule_offsets holds the index of the first element of each rule.
+struct llama_grammar* llama_grammar_init_wrapper(const llama_grammar_element* elements, const size_t* rule_offsets, size_t n_rules, size_t start_rule_index) {
+    const llama_grammar_element** rules = (const llama_grammar_element**)malloc(sizeof(llama_grammar_element*) * n_rules);
+    for (size_t i = 0; i < n_rules; i++) {
+        rules[i] = elements + rule_offsets[i];
+    }
+
+    struct llama_grammar* grammar = llama_grammar_init(rules, n_rules, start_rule_index);
+    free(rules);
+    return grammar;
+}
+
+// Get embeddings of the last evaluated sequence
+float* llama_get_embeddings_wrapper(struct llama_context* ctx) {
+    return llama_get_embeddings(ctx);
 }
 
 // Get model information
@@ -97,6 +160,11 @@ void llama_model_info_wrapper(struct llama_model* model, char* buf, size_t buf_s
     snprintf(buf, buf_size, "Model loaded successfully");
 }
 
+// Apply a LoRA adapter to the model weights
+int llama_apply_lora_wrapper(struct llama_model* model, const char* path, float scale, int n_threads) {
+    return llama_model_apply_lora_from_file(model, path, scale, NULL, n_threads);
+}
+
 // Free resources
 void llama_free_model_wrapper(struct llama_model* model) {
     llama_free_model(model);
@@ -113,14 +181,26 @@ import (
 	"runtime"
 	"sync"
 	"unsafe"
+
+	"colossus-cli/internal/grammar"
 )
 
+// Available reports whether the llama.cpp bindings are compiled in
+const Available = true
+
 // Initialize llama.cpp backend
 var (
 	llamaInitOnce sync.Once
 	llamaBackend  *Backend
 )
 
+// NUMA strategy of the process, set by the first call to InitNUMA
+var (
+	numaMutex       sync.Mutex
+	numaInitialized bool
+	numaStrategy    NUMAStrategy
+)
+
 // Backend represents the llama.cpp backend
 type Backend struct {
 	initialized bool
@@ -158,6 +238,23 @@ type ContextParams struct {
 	Threads     int
 	RopeFreqBase float32
 	RopeFreqScale float32
+	Embeddings   bool
+
+	// FlashAttention computes attention with the fused flash attention
+	// kernels, which need much less memory for long contexts
+	FlashAttention bool
+	// KVCacheType is the type the keys and values of the KV cache are stored in
+	KVCacheType KVCacheType
+	// RoPE scaling extending the context beyond the training length. YaRN
+	// parameters of 0 keep the llama.cpp defaults.
+	RopeScalingType RopeScalingType
+	YarnOrigCtx     int
+	YarnBetaFast    float32
+	YarnBetaSlow    float32
+
+	// DefragThreshold is the fragmentation of the KV cache above which it is
+	// defragmented, 0 to never defragment it
+	DefragThreshold float32
 }
 
 // Token represents a llama token
@@ -167,7 +264,8 @@ type Token C.llama_token
 func Initialize() error {
 	var err error
 	llamaInitOnce.Do(func() {
-		C.llama_backend_init(false)
+		// NUMA is initialized separately by InitNUMA
+		C.llama_backend_init()
 		llamaBackend = &Backend{initialized: true}
 		
 		// Set up cleanup on program exit
@@ -176,6 +274,31 @@ func Initialize() error {
 	return err
 }
 
+// InitNUMA sets how llama.cpp places its threads on NUMA nodes, through
+// llama_numa_init. The strategy applies to the whole process rather than to
+// a context, so it should be set before the first model is loaded; setting
+// another strategy afterwards fails.
+func InitNUMA(strategy NUMAStrategy) error {
+	if err := Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize llama backend: %w", err)
+	}
+
+	numaMutex.Lock()
+	defer numaMutex.Unlock()
+
+	if numaInitialized {
+		if strategy != numaStrategy {
+			return fmt.Errorf("NUMA mode is already %s for this process, cannot switch to %s", numaStrategy, strategy)
+		}
+		return nil
+	}
+
+	C.llama_numa_init(C.enum_ggml_numa_strategy(strategy))
+	numaInitialized = true
+	numaStrategy = strategy
+	return nil
+}
+
 // LoadModel loads a model from file
 func LoadModel(path string, params ModelParams) (*Model, error) {
 	if err := Initialize(); err != nil {
@@ -190,13 +313,20 @@ func LoadModel(path string, params ModelParams) (*Model, error) {
 	cParams.n_gpu_layers = C.int(params.GPULayers)
 	cParams.main_gpu = C.int(params.MainGPU)
 
-	// Handle tensor split for multi-GPU
+	// Handle tensor split for multi-GPU. llama.cpp reads one proportion for
+	// each of llama_max_devices() devices, and only while loading the model.
 	if len(params.TensorSplit) > 0 {
+		n := int(C.llama_max_devices())
+		cSplit := (*C.float)(C.calloc(C.size_t(n), C.size_t(unsafe.Sizeof(C.float(0)))))
+		defer C.free(unsafe.Pointer(cSplit))
+
+		splits := unsafe.Slice(cSplit, n)
 		for i, split := range params.TensorSplit {
-			if i < len(cParams.tensor_split) {
-				cParams.tensor_split[i] = C.float(split)
+			if i < n {
+				splits[i] = C.float(split)
 			}
 		}
+		cParams.tensor_split = cSplit
 	}
 
 	// Load the model
@@ -220,20 +350,57 @@ func LoadModel(path string, params ModelParams) (*Model, error) {
 	return model, nil
 }
 
+// ApplyLoRA applies a LoRA adapter to the model weights, scaled by scale.
+// Adapters stack, so applying several adds up their changes.
+func (m *Model) ApplyLoRA(path string, scale float32, threads int) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	if C.llama_apply_lora_wrapper(m.cModel, cPath, C.float(scale), C.int(threads)) != 0 {
+		return fmt.Errorf("failed to apply LoRA adapter %s", path)
+	}
+	return nil
+}
+
 // NewContext creates a new context for the model
 func (m *Model) NewContext(params ContextParams) (*Context, error) {
 	// Convert Go params to C params
 	cParams := C.llama_context_default_params_wrapper()
 	cParams.n_ctx = C.uint32_t(params.ContextSize)
 	cParams.n_batch = C.uint32_t(params.BatchSize)
-	cParams.n_threads = C.int(params.Threads)
+	cParams.n_threads = C.uint32_t(params.Threads)
 	cParams.rope_freq_base = C.float(params.RopeFreqBase)
 	cParams.rope_freq_scale = C.float(params.RopeFreqScale)
+	cParams.embeddings = C.bool(params.Embeddings)
+	cParams.flash_attn = C.bool(params.FlashAttention)
+
+	kvType := params.KVCacheType.ggmlType()
+	cParams.type_k = kvType
+	cParams.type_v = kvType
+
+	cParams.rope_scaling_type = params.RopeScalingType.llamaType()
+	if params.YarnOrigCtx > 0 {
+		cParams.yarn_orig_ctx = C.uint32_t(params.YarnOrigCtx)
+	}
+	if params.YarnBetaFast > 0 {
+		cParams.yarn_beta_fast = C.float(params.YarnBetaFast)
+	}
+	if params.YarnBetaSlow > 0 {
+		cParams.yarn_beta_slow = C.float(params.YarnBetaSlow)
+	}
+
+	// llama.cpp disables defragmentation with a negative threshold
+	cParams.defrag_thold = -1
+	if params.DefragThreshold > 0 {
+		cParams.defrag_thold = C.float(params.DefragThreshold)
+	}
 
 	// Create context
 	cContext := C.llama_new_context_wrapper(m.cModel, cParams)
 	if cContext == nil {
-		return nil, fmt.Errorf("failed to create context")
+		// For valid parameters, llama.cpp only fails to create a context
+		// when it cannot allocate the KV cache or compute buffers
+		return nil, fmt.Errorf("failed to create context: %w", ErrOutOfMemory)
 	}
 
 	context := &Context{
@@ -248,6 +415,34 @@ func (m *Model) NewContext(params ContextParams) (*Context, error) {
 	return context, nil
 }
 
+// llamaType returns the llama_rope_scaling_type of a RoPE scaling type
+func (t RopeScalingType) llamaType() C.enum_llama_rope_scaling_type {
+	switch t {
+	case RopeScalingNone:
+		return C.LLAMA_ROPE_SCALING_TYPE_NONE
+	case RopeScalingLinear:
+		return C.LLAMA_ROPE_SCALING_TYPE_LINEAR
+	case RopeScalingYarn:
+		return C.LLAMA_ROPE_SCALING_TYPE_YARN
+	default:
+		return C.LLAMA_ROPE_SCALING_TYPE_UNSPECIFIED
+	}
+}
+
+// ggmlType returns the ggml_type of a KV cache type
+func (t KVCacheType) ggmlType() C.enum_ggml_type {
+	switch t {
+	case KVCacheF32:
+		return C.GGML_TYPE_F32
+	case KVCacheQ8_0:
+		return C.GGML_TYPE_Q8_0
+	case KVCacheQ4_0:
+		return C.GGML_TYPE_Q4_0
+	default:
+		return C.GGML_TYPE_F16
+	}
+}
+
 // Tokenize converts text to tokens
 func (c *Context) Tokenize(text string, addBOS bool) ([]Token, error) {
 	cText := C.CString(text)
@@ -329,28 +524,368 @@ func (c *Context) Eval(tokens []Token, nPast int) error {
 	return nil
 }
 
-// Sample samples the next token
-func (c *Context) Sample(temperature float32, topP float32, topK int) (Token, error) {
-	// Get logits (simplified approach)
-	// In real implementation, you'd get logits from the context and create candidates
-	candidates := make([]C.llama_token, 1)
-	candidates[0] = 0 // Simplified - would use actual vocab
+// Candidates holds the candidate tokens for a single sampling step. Sampler
+// stages are applied in place and a token is drawn with one of the Sample
+// methods. Candidates must be freed after use.
+type Candidates struct {
+	ctx   *Context
+	array C.llama_token_data_array
+}
 
-	token := C.llama_sample_token_wrapper(
-		c.cContext,
-		&candidates[0],
-		C.int(len(candidates)),
-		C.float(temperature),
-		C.float(topP),
-		C.int(topK),
+// Candidates builds the candidate array from the logits of the last evaluated token
+func (c *Context) Candidates() (*Candidates, error) {
+	return c.CandidatesAt(-1)
+}
+
+// CandidatesAt builds the candidate array from the logits of the i-th token
+// of the last decoded batch
+func (c *Context) CandidatesAt(i int) (*Candidates, error) {
+	var n C.size_t
+	data := C.llama_candidates_wrapper(c.cContext, C.int32_t(i), &n)
+	if data == nil {
+		return nil, fmt.Errorf("failed to allocate candidates")
+	}
+
+	return &Candidates{
+		ctx: c,
+		array: C.llama_token_data_array{
+			data:   data,
+			size:   n,
+			sorted: false,
+		},
+	}, nil
+}
+
+// Free releases the candidate array
+func (cd *Candidates) Free() {
+	if cd.array.data != nil {
+		C.free(unsafe.Pointer(cd.array.data))
+		cd.array.data = nil
+	}
+}
+
+// ApplyRepetitionPenalties penalises tokens that appear in lastTokens
+func (cd *Candidates) ApplyRepetitionPenalties(lastTokens []Token, repeat, frequency, presence float32) {
+	if len(lastTokens) == 0 {
+		return
+	}
+
+	cTokens := make([]C.llama_token, len(lastTokens))
+	for i, token := range lastTokens {
+		cTokens[i] = C.llama_token(token)
+	}
+
+	C.llama_sample_repetition_penalties(
+		cd.ctx.cContext,
+		&cd.array,
+		&cTokens[0],
+		C.size_t(len(cTokens)),
+		C.float(repeat),
+		C.float(frequency),
+		C.float(presence),
 	)
+}
+
+// Penalize subtracts a penalty from the logits of the given tokens. It must
+// be called before any sampler stage reorders the candidates.
+func (cd *Candidates) Penalize(penalties map[Token]float32) {
+	data := unsafe.Slice(cd.array.data, int(cd.array.size))
+	for token, penalty := range penalties {
+		if int(token) >= 0 && int(token) < len(data) && Token(data[token].id) == token {
+			data[token].logit -= C.float(penalty)
+		}
+	}
+}
+
+// TopK keeps only the k most likely candidates
+func (cd *Candidates) TopK(k int) {
+	C.llama_sample_top_k(cd.ctx.cContext, &cd.array, C.int(k), 1)
+}
+
+// TopP keeps the smallest set of candidates whose cumulative probability exceeds p
+func (cd *Candidates) TopP(p float32) {
+	C.llama_sample_top_p(cd.ctx.cContext, &cd.array, C.float(p), 1)
+}
+
+// MinP keeps only the candidates at least p times as likely as the most
+// likely one
+func (cd *Candidates) MinP(p float32) {
+	C.llama_sample_min_p_wrapper(cd.ctx.cContext, &cd.array, C.float(p))
+}
+
+// TopA keeps only the candidates with a probability of at least a times the
+// square of the highest probability
+func (cd *Candidates) TopA(a float32) {
+	C.llama_sample_top_a_wrapper(cd.ctx.cContext, &cd.array, C.float(a))
+}
+
+// Temperature scales the candidate logits by the given temperature
+func (cd *Candidates) Temperature(temperature float32) {
+	C.llama_sample_temp(cd.ctx.cContext, &cd.array, C.float(temperature))
+}
+
+// SampleGreedy selects the most likely candidate
+func (cd *Candidates) SampleGreedy() Token {
+	return Token(C.llama_sample_token_greedy(cd.ctx.cContext, &cd.array))
+}
+
+// SampleToken draws a token from the candidate distribution
+func (cd *Candidates) SampleToken() Token {
+	return Token(C.llama_sample_token(cd.ctx.cContext, &cd.array))
+}
+
+// SampleMirostat draws a token using Mirostat v1. mu carries the sampler
+// state between calls and must be initialised to 2*tau.
+func (cd *Candidates) SampleMirostat(tau, eta float32, m int, mu *float32) Token {
+	cMu := C.float(*mu)
+	token := C.llama_sample_token_mirostat(cd.ctx.cContext, &cd.array, C.float(tau), C.float(eta), C.int(m), &cMu)
+	*mu = float32(cMu)
+	return Token(token)
+}
+
+// SampleMirostatV2 draws a token using Mirostat v2. mu carries the sampler
+// state between calls and must be initialised to 2*tau.
+func (cd *Candidates) SampleMirostatV2(tau, eta float32, mu *float32) Token {
+	cMu := C.float(*mu)
+	token := C.llama_sample_token_mirostat_v2(cd.ctx.cContext, &cd.array, C.float(tau), C.float(eta), &cMu)
+	*mu = float32(cMu)
+	return Token(token)
+}
 
-	return Token(token), nil
+// ApplyGrammar masks out candidates that the grammar does not allow next
+func (cd *Candidates) ApplyGrammar(g *Grammar) {
+	C.llama_sample_grammar(cd.ctx.cContext, &cd.array, g.cGrammar)
+}
+
+// Grammar is a llama.cpp grammar used to constrain sampling
+type Grammar struct {
+	cGrammar *C.struct_llama_grammar
+}
+
+// NewGrammar creates a llama.cpp grammar from parsed GBNF rules
+func NewGrammar(g *grammar.Grammar) (*Grammar, error) {
+	var elements []C.llama_grammar_element
+	offsets := make([]C.size_t, len(g.Rules))
+	for i, rule := range g.Rules {
+		offsets[i] = C.size_t(len(elements))
+		for _, element := range rule {
+			elements = append(elements, C.llama_grammar_element{
+				_type: C.enum_llama_gretype(element.Type),
+				value: C.uint32_t(element.Value),
+			})
+		}
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("grammar has no rules")
+	}
+
+	cGrammar := C.llama_grammar_init_wrapper(&elements[0], &offsets[0], C.size_t(len(g.Rules)), C.size_t(g.RootIndex()))
+	if cGrammar == nil {
+		return nil, fmt.Errorf("failed to initialize grammar")
+	}
+
+	grammar := &Grammar{cGrammar: cGrammar}
+	runtime.SetFinalizer(grammar, (*Grammar).cleanup)
+	return grammar, nil
+}
+
+// AcceptToken advances the grammar state past a sampled token
+func (g *Grammar) AcceptToken(ctx *Context, token Token) {
+	C.llama_grammar_accept_token(ctx.cContext, g.cGrammar, C.llama_token(token))
+}
+
+// Sample samples the next token using temperature, top-p and top-k sampling
+func (c *Context) Sample(temperature float32, topP float32, topK int) (Token, error) {
+	candidates, err := c.Candidates()
+	if err != nil {
+		return 0, err
+	}
+	defer candidates.Free()
+
+	if temperature <= 0 {
+		return candidates.SampleGreedy(), nil
+	}
+
+	if topK > 0 {
+		candidates.TopK(topK)
+	}
+	if topP < 1.0 {
+		candidates.TopP(topP)
+	}
+	candidates.Temperature(temperature)
+
+	return candidates.SampleToken(), nil
+}
+
+// SetRNGSeed sets the seed of the context's sampling RNG
+func (c *Context) SetRNGSeed(seed uint32) {
+	C.llama_set_rng_seed(c.cContext, C.uint32_t(seed))
+}
+
+// RemoveSequence removes all KV cache entries of a sequence
+func (c *Context) RemoveSequence(seqID int) {
+	C.llama_kv_cache_seq_rm(c.cContext, C.llama_seq_id(seqID), -1, -1)
+}
+
+// ErrKVCacheFull is returned by DecodeBatch when the KV cache has no room for the batch
+var ErrKVCacheFull = fmt.Errorf("no KV cache slot available for batch")
+
+// Batch holds tokens from one or more sequences to be decoded together
+type Batch struct {
+	cBatch   C.struct_llama_batch
+	capacity int
+}
+
+// NewBatch allocates a batch that can hold up to capacity tokens
+func NewBatch(capacity int) *Batch {
+	batch := &Batch{
+		cBatch:   C.llama_batch_init(C.int32_t(capacity), 0, 1),
+		capacity: capacity,
+	}
+	runtime.SetFinalizer(batch, (*Batch).cleanup)
+	return batch
+}
+
+// Len returns the number of tokens in the batch
+func (b *Batch) Len() int {
+	return int(b.cBatch.n_tokens)
+}
+
+// Clear removes all tokens from the batch
+func (b *Batch) Clear() {
+	b.cBatch.n_tokens = 0
+}
+
+// Add appends a token at position pos of sequence seqID. When logits is true
+// the logits for this token are computed and can be sampled from.
+func (b *Batch) Add(token Token, pos int, seqID int, logits bool) error {
+	if b.Len() >= b.capacity {
+		return fmt.Errorf("batch is full (%d tokens)", b.capacity)
+	}
+	C.llama_batch_add_wrapper(&b.cBatch, C.llama_token(token), C.llama_pos(pos), C.llama_seq_id(seqID), C.bool(logits))
+	return nil
+}
+
+// DecodeBatch evaluates n tokens of the batch starting at offset. Logits of
+// the decoded tokens are indexed relative to offset.
+func (c *Context) DecodeBatch(b *Batch, offset, n int) error {
+	if offset < 0 || n <= 0 || offset+n > b.Len() {
+		return fmt.Errorf("invalid batch range %d+%d of %d", offset, n, b.Len())
+	}
+
+	result := C.llama_decode_range_wrapper(c.cContext, b.cBatch, C.int(offset), C.int(n))
+	switch {
+	case result == 1:
+		return ErrKVCacheFull
+	case result != 0:
+		return fmt.Errorf("decode failed with code %d", result)
+	}
+	return nil
+}
+
+// TruncateKVCache removes all KV cache entries at positions >= nPast
+func (c *Context) TruncateKVCache(nPast int) {
+	C.llama_kv_cache_seq_rm(c.cContext, -1, C.llama_pos(nPast), -1)
+}
+
+// SaveSession writes the KV cache state and the tokens it was built from to a session file
+func (c *Context) SaveSession(path string, tokens []Token) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	cTokens := make([]C.llama_token, len(tokens))
+	for i, token := range tokens {
+		cTokens[i] = C.llama_token(token)
+	}
+
+	var tokensPtr *C.llama_token
+	if len(cTokens) > 0 {
+		tokensPtr = &cTokens[0]
+	}
+
+	if !C.llama_save_session_file(c.cContext, cPath, tokensPtr, C.size_t(len(cTokens))) {
+		return fmt.Errorf("failed to save session file: %s", path)
+	}
+	return nil
+}
+
+// LoadSession restores the KV cache state from a session file and returns
+// the tokens it was built from
+func (c *Context) LoadSession(path string) ([]Token, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	capacity := c.GetContextSize()
+	cTokens := make([]C.llama_token, capacity)
+	var count C.size_t
+
+	if !C.llama_load_session_file(c.cContext, cPath, &cTokens[0], C.size_t(capacity), &count) {
+		return nil, fmt.Errorf("failed to load session file: %s", path)
+	}
+
+	tokens := make([]Token, int(count))
+	for i := range tokens {
+		tokens[i] = Token(cTokens[i])
+	}
+	return tokens, nil
+}
+
+// SetEmbeddings sets whether the following decodes extract embeddings
+func (c *Context) SetEmbeddings(enabled bool) {
+	C.llama_set_embeddings(c.cContext, C.bool(enabled))
+}
+
+// GetEmbeddings returns the embeddings of the last evaluated sequence
+func (c *Context) GetEmbeddings() ([]float32, error) {
+	size := c.model.GetEmbeddingSize()
+	if size <= 0 {
+		return nil, fmt.Errorf("model has no embedding dimension")
+	}
+
+	cEmbeddings := C.llama_get_embeddings_wrapper(c.cContext)
+	if cEmbeddings == nil {
+		return nil, fmt.Errorf("embeddings not available: embeddings are not enabled on the context")
+	}
+
+	src := unsafe.Slice((*float32)(unsafe.Pointer(cEmbeddings)), size)
+	embeddings := make([]float32, size)
+	copy(embeddings, src)
+
+	return embeddings, nil
+}
+
+// GetEmbeddingSize returns the embedding dimension
+func (m *Model) GetEmbeddingSize() int {
+	return int(C.llama_n_embd(m.cModel))
+}
+
+// TokenEOS returns the model's end-of-sequence token
+func (m *Model) TokenEOS() Token {
+	return Token(C.llama_token_eos(m.cModel))
 }
 
 // GetVocabSize returns the vocabulary size
 func (m *Model) GetVocabSize() int {
-	return int(C.llama_n_vocab(C.llama_get_model(m.cModel)))
+	return int(C.llama_n_vocab(m.cModel))
+}
+
+// GetLogits returns a copy of the logits of the last evaluated token
+func (c *Context) GetLogits() []float32 {
+	return c.GetLogitsAt(-1)
+}
+
+// GetLogitsAt returns a copy of the logits of the i-th token of the last decoded batch
+func (c *Context) GetLogitsAt(i int) []float32 {
+	cLogits := C.llama_get_logits(c.cContext)
+	if i >= 0 {
+		cLogits = C.llama_get_logits_ith(c.cContext, C.int32_t(i))
+	}
+
+	size := c.model.GetVocabSize()
+	src := unsafe.Slice((*float32)(unsafe.Pointer(cLogits)), size)
+	logits := make([]float32, size)
+	copy(logits, src)
+	return logits
 }
 
 // GetContextSize returns the context size
@@ -374,6 +909,20 @@ func (c *Context) cleanup() {
 	}
 }
 
+func (b *Batch) cleanup() {
+	if b.capacity > 0 {
+		C.llama_batch_free(b.cBatch)
+		b.capacity = 0
+	}
+}
+
+func (g *Grammar) cleanup() {
+	if g.cGrammar != nil {
+		C.llama_grammar_free(g.cGrammar)
+		g.cGrammar = nil
+	}
+}
+
 func (b *Backend) cleanup() {
 	if b.initialized {
 		C.llama_backend_free()
@@ -391,3 +940,13 @@ func (c *Context) Free() {
 	c.cleanup()
 	runtime.SetFinalizer(c, nil)
 }
+
+func (g *Grammar) Free() {
+	g.cleanup()
+	runtime.SetFinalizer(g, nil)
+}
+
+func (b *Batch) Free() {
+	b.cleanup()
+	runtime.SetFinalizer(b, nil)
+}
@@ -0,0 +1,7 @@
+package llama
+
+import "errors"
+
+// ErrOutOfMemory is returned when llama.cpp cannot allocate the memory for a
+// context, e.g. because the GPU has too little free VRAM
+var ErrOutOfMemory = errors.New("out of memory")
@@ -0,0 +1,57 @@
+package llama
+
+import "fmt"
+
+// KVCacheType is the data type the KV cache is stored in. Quantized types
+// take less memory, which allows longer contexts, at a small cost in quality.
+type KVCacheType int
+
+const (
+	// KVCacheF16 stores the KV cache in 16-bit floats, the llama.cpp default
+	KVCacheF16 KVCacheType = iota
+	// KVCacheF32 stores the KV cache in 32-bit floats
+	KVCacheF32
+	// KVCacheQ8_0 stores the KV cache in 8-bit blocks, about half the memory
+	// of F16 with a negligible loss of quality
+	KVCacheQ8_0
+	// KVCacheQ4_0 stores the KV cache in 4-bit blocks, about a quarter of the
+	// memory of F16 with a noticeable loss of quality
+	KVCacheQ4_0
+)
+
+// ParseKVCacheType returns the KV cache type named "f32", "f16", "q8_0" or
+// "q4_0"
+func ParseKVCacheType(name string) (KVCacheType, error) {
+	switch name {
+	case "f16":
+		return KVCacheF16, nil
+	case "f32":
+		return KVCacheF32, nil
+	case "q8_0":
+		return KVCacheQ8_0, nil
+	case "q4_0":
+		return KVCacheQ4_0, nil
+	default:
+		return KVCacheF16, fmt.Errorf("invalid KV cache type %q: must be f16, f32, q8_0 or q4_0", name)
+	}
+}
+
+// String returns the name of the KV cache type
+func (t KVCacheType) String() string {
+	switch t {
+	case KVCacheF32:
+		return "f32"
+	case KVCacheQ8_0:
+		return "q8_0"
+	case KVCacheQ4_0:
+		return "q4_0"
+	default:
+		return "f16"
+	}
+}
+
+// Quantized reports whether the type is quantized. llama.cpp can only store
+// the V cache in a quantized type with flash attention.
+func (t KVCacheType) Quantized() bool {
+	return t == KVCacheQ8_0 || t == KVCacheQ4_0
+}
@@ -0,0 +1,45 @@
+package llama
+
+import "testing"
+
+func TestParseKVCacheType(t *testing.T) {
+	tests := []struct {
+		name          string
+		want          KVCacheType
+		wantQuantized bool
+		wantErr       bool
+	}{
+		{name: "f16", want: KVCacheF16},
+		{name: "f32", want: KVCacheF32},
+		{name: "q8_0", want: KVCacheQ8_0, wantQuantized: true},
+		{name: "q4_0", want: KVCacheQ4_0, wantQuantized: true},
+		{name: "", wantErr: true},
+		{name: "F16", wantErr: true},
+		{name: "q5_1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKVCacheType(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseKVCacheType(%q) = %s, want an error", tt.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKVCacheType(%q): %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseKVCacheType(%q) = %d, want %d", tt.name, got, tt.want)
+			}
+			// The name of a type parses back to it
+			if got.String() != tt.name {
+				t.Errorf("String() = %q, want %q", got.String(), tt.name)
+			}
+			if got.Quantized() != tt.wantQuantized {
+				t.Errorf("Quantized() = %t, want %t", got.Quantized(), tt.wantQuantized)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package llama
+
+import "fmt"
+
+// NUMAStrategy is how llama.cpp places its threads on the NUMA nodes of
+// multi-socket machines. The values are those of ggml_numa_strategy.
+type NUMAStrategy int
+
+const (
+	// NUMADisabled leaves thread placement to the operating system
+	NUMADisabled NUMAStrategy = iota
+	// NUMADistribute spreads the threads evenly across the nodes
+	NUMADistribute
+	// NUMAIsolate pins the threads to the node the process started on
+	NUMAIsolate
+	// NUMANumactl keeps the CPU set the process was started with by numactl
+	NUMANumactl
+)
+
+// ParseNUMAStrategy returns the strategy of a NUMA mode of the model options:
+// "distribute", "isolate", "numactl", or "" for none
+func ParseNUMAStrategy(mode string) (NUMAStrategy, error) {
+	switch mode {
+	case "":
+		return NUMADisabled, nil
+	case "distribute":
+		return NUMADistribute, nil
+	case "isolate":
+		return NUMAIsolate, nil
+	case "numactl":
+		return NUMANumactl, nil
+	default:
+		return NUMADisabled, fmt.Errorf("invalid NUMA mode %q: must be distribute, isolate or numactl", mode)
+	}
+}
+
+// String returns the NUMA mode of the strategy
+func (s NUMAStrategy) String() string {
+	switch s {
+	case NUMADistribute:
+		return "distribute"
+	case NUMAIsolate:
+		return "isolate"
+	case NUMANumactl:
+		return "numactl"
+	default:
+		return "disabled"
+	}
+}
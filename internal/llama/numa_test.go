@@ -0,0 +1,34 @@
+package llama
+
+import "testing"
+
+func TestParseNUMAStrategy(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    NUMAStrategy
+		wantErr bool
+	}{
+		{mode: "", want: NUMADisabled},
+		{mode: "distribute", want: NUMADistribute},
+		{mode: "isolate", want: NUMAIsolate},
+		{mode: "numactl", want: NUMANumactl},
+		{mode: "mirror", wantErr: true},
+		{mode: "Distribute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNUMAStrategy(tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNUMAStrategy(%q) error = %v, want error %v", tt.mode, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseNUMAStrategy(%q) = %s, want %s", tt.mode, got, tt.want)
+		}
+
+		// Modes are the names of their strategies
+		if tt.mode != "" && !tt.wantErr && got.String() != tt.mode {
+			t.Errorf("String = %q, want %q", got.String(), tt.mode)
+		}
+	}
+}
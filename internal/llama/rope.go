@@ -0,0 +1,50 @@
+package llama
+
+import "fmt"
+
+// RopeScalingType is how RoPE positions are scaled to use a model beyond the
+// context length it was trained with
+type RopeScalingType int
+
+const (
+	// RopeScalingUnspecified uses the scaling set in the model metadata
+	RopeScalingUnspecified RopeScalingType = iota
+	// RopeScalingNone does not scale positions
+	RopeScalingNone
+	// RopeScalingLinear divides positions by the scale factor
+	RopeScalingLinear
+	// RopeScalingYarn scales positions with YaRN, which keeps the quality of
+	// the model at several times its training length
+	RopeScalingYarn
+)
+
+// ParseRopeScalingType returns the RoPE scaling named "none", "linear" or
+// "yarn", or RopeScalingUnspecified for ""
+func ParseRopeScalingType(name string) (RopeScalingType, error) {
+	switch name {
+	case "":
+		return RopeScalingUnspecified, nil
+	case "none":
+		return RopeScalingNone, nil
+	case "linear":
+		return RopeScalingLinear, nil
+	case "yarn":
+		return RopeScalingYarn, nil
+	default:
+		return RopeScalingUnspecified, fmt.Errorf("invalid RoPE scaling type %q: must be none, linear or yarn", name)
+	}
+}
+
+// String returns the name of the RoPE scaling type
+func (t RopeScalingType) String() string {
+	switch t {
+	case RopeScalingNone:
+		return "none"
+	case RopeScalingLinear:
+		return "linear"
+	case RopeScalingYarn:
+		return "yarn"
+	default:
+		return "unspecified"
+	}
+}
@@ -5,10 +5,15 @@ package llama
 import (
 	"fmt"
 	"sync"
+
+	"colossus-cli/internal/grammar"
 )
 
 // Stub implementations for builds without CGO/llama.cpp
 
+// Available reports whether the llama.cpp bindings are compiled in
+const Available = false
+
 // Backend represents the llama.cpp backend (stub)
 type Backend struct {
 	initialized bool
@@ -44,6 +49,16 @@ type ContextParams struct {
 	Threads       int
 	RopeFreqBase  float32
 	RopeFreqScale float32
+	Embeddings    bool
+
+	FlashAttention  bool
+	KVCacheType     KVCacheType
+	DefragThreshold float32
+
+	RopeScalingType RopeScalingType
+	YarnOrigCtx     int
+	YarnBetaFast    float32
+	YarnBetaSlow    float32
 }
 
 // Token represents a llama token (stub)
@@ -54,11 +69,21 @@ func Initialize() error {
 	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
 }
 
+// InitNUMA sets how llama.cpp places its threads on NUMA nodes (stub)
+func InitNUMA(strategy NUMAStrategy) error {
+	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
 // LoadModel loads a model from file (stub)
 func LoadModel(path string, params ModelParams) (*Model, error) {
 	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
 }
 
+// ApplyLoRA applies a LoRA adapter to the model weights (stub)
+func (m *Model) ApplyLoRA(path string, scale float32, threads int) error {
+	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
 // NewContext creates a new context for the model (stub)
 func (m *Model) NewContext(params ContextParams) (*Context, error) {
 	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
@@ -79,16 +104,167 @@ func (c *Context) Eval(tokens []Token, nPast int) error {
 	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
 }
 
+// Candidates holds the candidate tokens for a single sampling step (stub)
+type Candidates struct{}
+
+// Candidates builds the candidate array from the last logits (stub)
+func (c *Context) Candidates() (*Candidates, error) {
+	return c.CandidatesAt(-1)
+}
+
+// CandidatesAt builds the candidate array from the logits of a batch token (stub)
+func (c *Context) CandidatesAt(i int) (*Candidates, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// Free releases the candidate array (stub)
+func (cd *Candidates) Free() {}
+
+// ApplyRepetitionPenalties penalises repeated tokens (stub)
+func (cd *Candidates) ApplyRepetitionPenalties(lastTokens []Token, repeat, frequency, presence float32) {
+}
+
+// Penalize subtracts penalties from the logits of tokens (stub)
+func (cd *Candidates) Penalize(penalties map[Token]float32) {}
+
+// TopK keeps only the k most likely candidates (stub)
+func (cd *Candidates) TopK(k int) {}
+
+// TopP applies nucleus filtering (stub)
+func (cd *Candidates) TopP(p float32) {}
+
+// MinP keeps candidates at least p times as likely as the best (stub)
+func (cd *Candidates) MinP(p float32) {}
+
+// TopA keeps candidates with probability >= a * max_prob^2 (stub)
+func (cd *Candidates) TopA(a float32) {}
+
+// Temperature scales the candidate logits (stub)
+func (cd *Candidates) Temperature(temperature float32) {}
+
+// SampleGreedy selects the most likely candidate (stub)
+func (cd *Candidates) SampleGreedy() Token { return 0 }
+
+// SampleToken draws a token from the candidate distribution (stub)
+func (cd *Candidates) SampleToken() Token { return 0 }
+
+// SampleMirostat draws a token using Mirostat v1 (stub)
+func (cd *Candidates) SampleMirostat(tau, eta float32, m int, mu *float32) Token { return 0 }
+
+// SampleMirostatV2 draws a token using Mirostat v2 (stub)
+func (cd *Candidates) SampleMirostatV2(tau, eta float32, mu *float32) Token { return 0 }
+
+// ApplyGrammar masks out candidates the grammar does not allow (stub)
+func (cd *Candidates) ApplyGrammar(g *Grammar) {}
+
+// Grammar is a llama.cpp grammar used to constrain sampling (stub)
+type Grammar struct{}
+
+// NewGrammar creates a llama.cpp grammar from parsed GBNF rules (stub)
+func NewGrammar(g *grammar.Grammar) (*Grammar, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// AcceptToken advances the grammar state past a sampled token (stub)
+func (g *Grammar) AcceptToken(ctx *Context, token Token) {}
+
+// Free releases the grammar (stub)
+func (g *Grammar) Free() {}
+
 // Sample samples the next token (stub)
 func (c *Context) Sample(temperature float32, topP float32, topK int) (Token, error) {
 	return 0, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
 }
 
+// SetRNGSeed sets the seed of the context's sampling RNG (stub)
+func (c *Context) SetRNGSeed(seed uint32) {}
+
+// RemoveSequence removes all KV cache entries of a sequence (stub)
+func (c *Context) RemoveSequence(seqID int) {}
+
+// ErrKVCacheFull is returned by DecodeBatch when the KV cache has no room for the batch
+var ErrKVCacheFull = fmt.Errorf("no KV cache slot available for batch")
+
+// Batch holds tokens from one or more sequences to be decoded together (stub)
+type Batch struct {
+	tokens int
+}
+
+// NewBatch allocates a batch that can hold up to capacity tokens (stub)
+func NewBatch(capacity int) *Batch {
+	return &Batch{}
+}
+
+// Len returns the number of tokens in the batch (stub)
+func (b *Batch) Len() int {
+	return b.tokens
+}
+
+// Clear removes all tokens from the batch (stub)
+func (b *Batch) Clear() {
+	b.tokens = 0
+}
+
+// Add appends a token to the batch (stub)
+func (b *Batch) Add(token Token, pos int, seqID int, logits bool) error {
+	b.tokens++
+	return nil
+}
+
+// Free releases the batch (stub)
+func (b *Batch) Free() {}
+
+// DecodeBatch evaluates a range of the batch (stub)
+func (c *Context) DecodeBatch(b *Batch, offset, n int) error {
+	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// TruncateKVCache removes all KV cache entries at positions >= nPast (stub)
+func (c *Context) TruncateKVCache(nPast int) {}
+
+// SaveSession writes the KV cache state to a session file (stub)
+func (c *Context) SaveSession(path string, tokens []Token) error {
+	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// LoadSession restores the KV cache state from a session file (stub)
+func (c *Context) LoadSession(path string) ([]Token, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// SetEmbeddings sets whether the following decodes extract embeddings (stub)
+func (c *Context) SetEmbeddings(enabled bool) {}
+
+// GetEmbeddings returns the embeddings of the last evaluated sequence (stub)
+func (c *Context) GetEmbeddings() ([]float32, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// GetEmbeddingSize returns the embedding dimension (stub)
+func (m *Model) GetEmbeddingSize() int {
+	return 0
+}
+
+// TokenEOS returns the model's end-of-sequence token (stub)
+func (m *Model) TokenEOS() Token {
+	return 0
+}
+
 // GetVocabSize returns the vocabulary size (stub)
 func (m *Model) GetVocabSize() int {
 	return 0
 }
 
+// GetLogits returns a copy of the logits of the last evaluated token (stub)
+func (c *Context) GetLogits() []float32 {
+	return nil
+}
+
+// GetLogitsAt returns a copy of the logits of a batch token (stub)
+func (c *Context) GetLogitsAt(i int) []float32 {
+	return nil
+}
+
 // GetContextSize returns the context size (stub)
 func (c *Context) GetContextSize() int {
 	return 0
@@ -102,3 +278,36 @@ func (m *Model) Free() {
 func (c *Context) Free() {
 	// No-op for stub
 }
+
+// VisionEncoder is the image encoder of a multimodal model (stub)
+type VisionEncoder struct{}
+
+// ImageEmbed is an encoded image (stub)
+type ImageEmbed struct{}
+
+// LoadVisionEncoder loads the vision encoder of a multimodal model (stub)
+func LoadVisionEncoder(path string) (*VisionEncoder, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// Free releases the vision encoder (stub)
+func (v *VisionEncoder) Free() {}
+
+// Compatible reports whether the encoder matches the model of c (stub)
+func (v *VisionEncoder) Compatible(c *Context) bool { return false }
+
+// EmbedImage encodes a PNG or JPEG image (stub)
+func (v *VisionEncoder) EmbedImage(data []byte, threads int) (*ImageEmbed, error) {
+	return nil, fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
+
+// Positions returns the number of KV cache positions the image takes (stub)
+func (e *ImageEmbed) Positions() int { return 0 }
+
+// Free releases the image embedding (stub)
+func (e *ImageEmbed) Free() {}
+
+// DecodeImageEmbed decodes an image embedding into a sequence (stub)
+func (c *Context) DecodeImageEmbed(embed *ImageEmbed, seqID, nPast, batchSize int) error {
+	return fmt.Errorf("llama.cpp not available: build with CGO enabled and llama.cpp library")
+}
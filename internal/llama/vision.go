@@ -0,0 +1,134 @@
+//go:build cgo && llamacpp_cgo
+
+package llama
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/../../third_party/llama.cpp -I${SRCDIR}/../../third_party/llama.cpp/examples/llava
+#cgo LDFLAGS: -L${SRCDIR}/../../third_party/llama.cpp -lllava
+
+#include <stdlib.h>
+#include <string.h>
+#include "llama.h"
+#include "clip.h"
+#include "llava.h"
+
+// Decode the positions of an image embedding into the KV cache of a sequence,
+// starting at n_past. llava_eval_image_embed only decodes into sequence 0.
+int llava_decode_image_embed_wrapper(struct llama_context* ctx, const struct llava_image_embed* embed, int32_t seq_id, int32_t n_past, int32_t n_batch) {
+    const int n_embd = llama_n_embd(llama_get_model(ctx));
+
+    for (int i = 0; i < embed->n_image_pos; i += n_batch) {
+        int n = embed->n_image_pos - i;
+        if (n > n_batch) {
+            n = n_batch;
+        }
+
+        struct llama_batch batch = llama_batch_init(n, n_embd, 1);
+        memcpy(batch.embd, embed->embed + (size_t)i * n_embd, sizeof(float) * (size_t)n * n_embd);
+        for (int j = 0; j < n; j++) {
+            batch.pos[j] = n_past + i + j;
+            batch.n_seq_id[j] = 1;
+            batch.seq_id[j][0] = seq_id;
+            batch.logits[j] = 0;
+        }
+        batch.n_tokens = n;
+
+        const int ret = llama_decode(ctx, batch);
+        llama_batch_free(batch);
+        if (ret != 0) {
+            return ret;
+        }
+    }
+    return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// VisionEncoder is the CLIP image encoder and projector of a multimodal
+// model, e.g. a LLaVA "mmproj" file, which turns images into embeddings the
+// model reads like tokens
+type VisionEncoder struct {
+	cClip *C.struct_clip_ctx
+
+	// The encoder is not safe for concurrent use
+	mutex sync.Mutex
+}
+
+// ImageEmbed is an image encoded by a VisionEncoder. It must be freed after use.
+type ImageEmbed struct {
+	cEmbed *C.struct_llava_image_embed
+}
+
+// LoadVisionEncoder loads the vision encoder of a multimodal model
+func LoadVisionEncoder(path string) (*VisionEncoder, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	cClip := C.clip_model_load(cPath, 0)
+	if cClip == nil {
+		return nil, fmt.Errorf("failed to load vision encoder from %s", path)
+	}
+	return &VisionEncoder{cClip: cClip}, nil
+}
+
+// Free releases the vision encoder
+func (v *VisionEncoder) Free() {
+	if v.cClip != nil {
+		C.clip_free(v.cClip)
+		v.cClip = nil
+	}
+}
+
+// Compatible reports whether the encoder produces embeddings of the size the
+// model of c reads
+func (v *VisionEncoder) Compatible(c *Context) bool {
+	return bool(C.llava_validate_embed_size(c.cContext, v.cClip))
+}
+
+// EmbedImage encodes a PNG or JPEG image
+func (v *VisionEncoder) EmbedImage(data []byte, threads int) (*ImageEmbed, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	cEmbed := C.llava_image_embed_make_with_bytes(v.cClip, C.int(threads), (*C.uchar)(unsafe.Pointer(&data[0])), C.int(len(data)))
+	if cEmbed == nil {
+		return nil, fmt.Errorf("failed to encode image: not a valid PNG or JPEG image")
+	}
+	return &ImageEmbed{cEmbed: cEmbed}, nil
+}
+
+// Positions returns the number of KV cache positions the image takes
+func (e *ImageEmbed) Positions() int {
+	return int(e.cEmbed.n_image_pos)
+}
+
+// Free releases the image embedding
+func (e *ImageEmbed) Free() {
+	if e.cEmbed != nil {
+		C.llava_image_embed_free(e.cEmbed)
+		e.cEmbed = nil
+	}
+}
+
+// DecodeImageEmbed decodes an image embedding into the KV cache of a
+// sequence at positions starting at nPast, in batches of at most batchSize
+func (c *Context) DecodeImageEmbed(embed *ImageEmbed, seqID, nPast, batchSize int) error {
+	result := C.llava_decode_image_embed_wrapper(c.cContext, embed.cEmbed, C.int32_t(seqID), C.int32_t(nPast), C.int32_t(batchSize))
+	switch {
+	case result == 1:
+		return ErrKVCacheFull
+	case result != 0:
+		return fmt.Errorf("decode failed with code %d", result)
+	}
+	return nil
+}
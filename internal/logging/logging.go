@@ -0,0 +1,86 @@
+// Package logging configures the log output and the fields shared by log
+// entries
+package logging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log formats accepted by Setup
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// contextKey is the type of the context values holding log fields
+type contextKey string
+
+const (
+	requestIDKey contextKey = "request_id"
+	modelKey     contextKey = "model"
+)
+
+// Setup sets the format of the standard logger and adds the request fields
+// of an entry's context to the entry
+func Setup(format string) error {
+	switch format {
+	case "", FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return fmt.Errorf("unsupported log format %q: must be %s or %s", format, FormatText, FormatJSON)
+	}
+
+	logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	logrus.AddHook(contextHook{})
+	return nil
+}
+
+// Component returns a logger whose entries are tagged with a component of the
+// application, e.g. "api"
+func Component(name string) *logrus.Entry {
+	return logrus.WithField("component", name)
+}
+
+// WithRequestID returns a context whose log entries carry a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID of a context, or "" if it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithModel returns a context whose log entries carry the name of the model
+// a request is for
+func WithModel(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, modelKey, name)
+}
+
+// contextHook adds the request ID and model of the context an entry was
+// logged with, e.g. with logger.WithContext(ctx), to the entry
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestID(entry.Context); id != "" {
+		entry.Data["request_id"] = id
+	}
+	if name, _ := entry.Context.Value(modelKey).(string); name != "" {
+		entry.Data["model"] = name
+	}
+	return nil
+}
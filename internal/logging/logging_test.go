@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// captureLog sets up the standard logger with format, writing to the
+// returned buffer until the test ends
+func captureLog(t *testing.T, format string) *bytes.Buffer {
+	t.Helper()
+	logger := logrus.StandardLogger()
+	out, formatter := logger.Out, logger.Formatter
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.ReplaceHooks(make(logrus.LevelHooks))
+	})
+
+	if err := Setup(format); err != nil {
+		t.Fatalf("Setup(%q): %v", format, err)
+	}
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	return &buf
+}
+
+func TestSetupJSON(t *testing.T) {
+	buf := captureLog(t, FormatJSON)
+
+	ctx := WithModel(WithRequestID(context.Background(), "req-1"), "llama3")
+	Component("api").WithContext(ctx).WithField("status", 200).Info("Request handled")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not JSON: %v", buf, err)
+	}
+	want := map[string]interface{}{
+		"msg":        "Request handled",
+		"level":      "info",
+		"component":  "api",
+		"request_id": "req-1",
+		"model":      "llama3",
+		"status":     float64(200),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("entry has no time")
+	}
+}
+
+func TestSetupText(t *testing.T) {
+	buf := captureLog(t, "")
+
+	// Entries without a context have no request fields
+	Component("model").Warn("Cache is stale")
+	line := buf.String()
+	if !strings.Contains(line, `msg="Cache is stale"`) || !strings.Contains(line, "component=model") {
+		t.Errorf("log output = %q, want a text entry", line)
+	}
+	if strings.Contains(line, "request_id") {
+		t.Errorf("log output = %q, want no request ID", line)
+	}
+}
+
+func TestSetupRejectsUnknownFormat(t *testing.T) {
+	if err := Setup("xml"); err == nil {
+		t.Error("Setup accepted the xml format")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("RequestID() = %q, want none", id)
+	}
+	if id := RequestID(WithRequestID(context.Background(), "abc")); id != "abc" {
+		t.Errorf("RequestID() = %q, want abc", id)
+	}
+}
@@ -0,0 +1,281 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"colossus-cli/internal/template"
+	"colossus-cli/internal/types"
+)
+
+// tool is a tool offered to clients, generating with one of the models
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// toolInputSchema is the arguments of the model tools
+var toolInputSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"prompt": {"type": "string", "description": "The prompt to respond to"},
+		"system": {"type": "string", "description": "An optional system prompt"}
+	},
+	"required": ["prompt"]
+}`)
+
+// content is a text or image content block
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// promptMessage is a message of a prompt or sampling request
+type promptMessage struct {
+	Role    string  `json:"role"`
+	Content content `json:"content"`
+}
+
+// toolName returns the name of the tool of a model. Tool names may only
+// contain letters, digits, underscores and hyphens, so the ':' of tags and
+// other characters are replaced by underscores.
+func toolName(model string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, model)
+}
+
+// listTools answers tools/list with a tool per installed model
+func (s *Server) listTools() (interface{}, error) {
+	models, err := s.backend.ListModels()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	tools := make([]tool, 0, len(models))
+	for _, m := range models {
+		tools = append(tools, tool{
+			Name:        toolName(m.Name),
+			Description: fmt.Sprintf("Generate a response to a prompt with the local model %s", m.Name),
+			InputSchema: toolInputSchema,
+		})
+	}
+	return map[string]interface{}{"tools": tools}, nil
+}
+
+// callTool answers tools/call, generating with the tool's model. Generation
+// errors are reported in the result, as the spec asks of tool errors, so
+// that the model calling the tool can see them.
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		Name      string `json:"name"`
+		Arguments struct {
+			Prompt string `json:"prompt"`
+			System string `json:"system"`
+		} `json:"arguments"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	models, err := s.backend.ListModels()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	modelName := ""
+	for _, m := range models {
+		if toolName(m.Name) == params.Name {
+			modelName = m.Name
+			break
+		}
+	}
+	if modelName == "" {
+		return nil, invalidParams("unknown tool: %s", params.Name)
+	}
+	if params.Arguments.Prompt == "" {
+		return nil, invalidParams("prompt is required")
+	}
+
+	req := &types.ChatRequest{Model: modelName}
+	if params.Arguments.System != "" {
+		req.Messages = append(req.Messages, types.Message{Role: "system", Content: params.Arguments.System})
+	}
+	req.Messages = append(req.Messages, types.Message{Role: "user", Content: params.Arguments.Prompt})
+
+	resp, err := s.backend.Chat(ctx, req)
+	if err != nil {
+		return map[string]interface{}{
+			"content": []content{{Type: "text", Text: err.Error()}},
+			"isError": true,
+		}, nil
+	}
+	return map[string]interface{}{
+		"content": []content{{Type: "text", Text: resp.Message.Content}},
+		"isError": false,
+	}, nil
+}
+
+// listPrompts answers prompts/list with the named prompt templates, whose
+// variables are the prompts' arguments
+func (s *Server) listPrompts() (interface{}, error) {
+	templates, err := s.backend.ListPrompts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+
+	type argument struct {
+		Name     string `json:"name"`
+		Required bool   `json:"required"`
+	}
+	type prompt struct {
+		Name      string     `json:"name"`
+		Arguments []argument `json:"arguments"`
+	}
+
+	prompts := make([]prompt, 0, len(templates))
+	for _, t := range templates {
+		p := prompt{Name: t.Name, Arguments: []argument{}}
+		for _, name := range t.Variables {
+			p.Arguments = append(p.Arguments, argument{Name: name, Required: true})
+		}
+		prompts = append(prompts, p)
+	}
+	return map[string]interface{}{"prompts": prompts}, nil
+}
+
+// getPrompt answers prompts/get with a prompt template rendered with the
+// given arguments, as a user message
+func (s *Server) getPrompt(raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	text, err := s.backend.RenderPrompt(params.Name, params.Arguments)
+	if err != nil {
+		if errors.Is(err, template.ErrTemplateNotFound) {
+			return nil, invalidParams("unknown prompt: %s", params.Name)
+		}
+		return nil, invalidParams("%v", err)
+	}
+
+	return map[string]interface{}{
+		"messages": []promptMessage{{Role: "user", Content: content{Type: "text", Text: text}}},
+	}, nil
+}
+
+// createMessage answers sampling/createMessage, generating the next message
+// of the conversation with the installed model best matching the client's
+// model preferences
+func (s *Server) createMessage(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		Messages         []promptMessage `json:"messages"`
+		ModelPreferences struct {
+			Hints []struct {
+				Name string `json:"name"`
+			} `json:"hints"`
+		} `json:"modelPreferences"`
+		SystemPrompt  string   `json:"systemPrompt"`
+		MaxTokens     int      `json:"maxTokens"`
+		Temperature   float64  `json:"temperature"`
+		StopSequences []string `json:"stopSequences"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Messages) == 0 {
+		return nil, invalidParams("messages are required")
+	}
+
+	var hints []string
+	for _, hint := range params.ModelPreferences.Hints {
+		hints = append(hints, hint.Name)
+	}
+	modelName, err := s.selectModel(hints)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &types.ChatRequest{
+		Model: modelName,
+		Options: &types.Options{
+			Temperature: params.Temperature,
+			NumPredict:  params.MaxTokens,
+			Stop:        params.StopSequences,
+		},
+	}
+	if params.SystemPrompt != "" {
+		req.Messages = append(req.Messages, types.Message{Role: "system", Content: params.SystemPrompt})
+	}
+	for _, m := range params.Messages {
+		message := types.Message{Role: m.Role}
+		switch m.Content.Type {
+		case "text":
+			message.Content = m.Content.Text
+		case "image":
+			image, err := base64.StdEncoding.DecodeString(m.Content.Data)
+			if err != nil {
+				return nil, invalidParams("invalid image data: %v", err)
+			}
+			message.Images = [][]byte{image}
+		default:
+			return nil, invalidParams("unsupported content type: %s", m.Content.Type)
+		}
+		req.Messages = append(req.Messages, message)
+	}
+
+	resp, err := s.backend.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"role":    "assistant",
+		"content": content{Type: "text", Text: resp.Message.Content},
+		"model":   modelName,
+	}, nil
+}
+
+// selectModel returns the installed model matching the first hint that
+// matches one, by name or, as the spec suggests, by substring. Without
+// matching hints, the first installed model is used.
+func (s *Server) selectModel(hints []string) (string, error) {
+	models, err := s.backend.ListModels()
+	if err != nil {
+		return "", fmt.Errorf("failed to list models: %w", err)
+	}
+	if len(models) == 0 {
+		return "", errors.New("no models are installed")
+	}
+
+	for _, hint := range hints {
+		if hint == "" {
+			continue
+		}
+		for _, m := range models {
+			if m.Name == hint {
+				return m.Name, nil
+			}
+		}
+		for _, m := range models {
+			if strings.Contains(m.Name, hint) {
+				return m.Name, nil
+			}
+		}
+	}
+	return models[0].Name, nil
+}
@@ -0,0 +1,315 @@
+// Package mcp implements a Model Context Protocol server, exposing the models
+// and prompt templates of Colossus to MCP clients over JSON-RPC 2.0
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"colossus-cli/internal/logging"
+	"colossus-cli/internal/types"
+)
+
+// logger logs with the mcp component
+var logger = logging.Component("mcp")
+
+// ProtocolVersion is the latest MCP revision the server implements
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the MCP revisions the server accepts from clients.
+// The messages it serves did not change between them.
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// maxMessageSize is the size of the largest message accepted, which may
+// carry base64-encoded images
+const maxMessageSize = 32 * 1024 * 1024
+
+// Backend provides the models, prompt templates and inference served over MCP
+type Backend interface {
+	// ListModels returns the installed models
+	ListModels() ([]types.ModelInfo, error)
+
+	// ListPrompts returns the named prompt templates
+	ListPrompts() ([]types.PromptTemplate, error)
+
+	// RenderPrompt renders a named prompt template with the given variables
+	RenderPrompt(name string, vars map[string]string) (string, error)
+
+	// Chat generates the next message of a conversation, loading the model
+	// if needed
+	Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error)
+}
+
+// Server serves MCP over newline-delimited JSON-RPC messages, the framing of
+// the stdio transport, on TCP connections
+type Server struct {
+	backend Backend
+	version string
+
+	mutex    sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewServer creates an MCP server reporting version as its version
+func NewServer(backend Backend, version string) *Server {
+	return &Server{
+		backend: backend,
+		version: version,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on l, serving each until it is closed, until
+// Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+
+		go func() {
+			defer func() {
+				s.mutex.Lock()
+				delete(s.conns, conn)
+				s.mutex.Unlock()
+				conn.Close()
+			}()
+			logger.Debugf("MCP client connected from %s", conn.RemoteAddr())
+			if err := s.ServeConn(context.Background(), conn, conn); err != nil {
+				logger.Debugf("MCP connection from %s failed: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close stops accepting connections and closes the open ones
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// ServeConn serves the messages read from r, one JSON-RPC message per line,
+// writing the responses to w until r is exhausted. Requests are handled
+// concurrently, so a long generation does not hold up pings.
+func (s *Server) ServeConn(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := &connection{
+		server:   s,
+		writer:   w,
+		inFlight: make(map[string]context.CancelFunc),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var wg sync.WaitGroup
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			c.write(&response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error"}})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			if !req.isNotification() {
+				c.write(&response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "invalid request"}})
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handle(ctx, &req)
+		}()
+	}
+
+	wg.Wait()
+	return scanner.Err()
+}
+
+// connection is the state of one client connection
+type connection struct {
+	server *Server
+
+	writeMutex sync.Mutex
+	writer     io.Writer
+
+	// inFlight cancels the requests being handled, by ID
+	mutex    sync.Mutex
+	inFlight map[string]context.CancelFunc
+}
+
+// handle answers a request, or acts on a notification
+func (c *connection) handle(ctx context.Context, req *request) {
+	if req.isNotification() {
+		c.notification(req)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := string(req.ID)
+	c.mutex.Lock()
+	c.inFlight[id] = cancel
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.inFlight, id)
+		c.mutex.Unlock()
+		cancel()
+	}()
+
+	result, err := c.server.call(ctx, req)
+	// Cancelled requests are not answered
+	if ctx.Err() != nil {
+		return
+	}
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	}
+	c.write(resp)
+}
+
+// notification acts on a notification from the client
+func (c *connection) notification(req *request) {
+	switch req.Method {
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if decodeParams(req.Params, &params) != nil {
+			return
+		}
+		c.mutex.Lock()
+		cancel, ok := c.inFlight[string(params.RequestID)]
+		c.mutex.Unlock()
+		if ok {
+			cancel()
+		}
+	case "notifications/initialized":
+		logger.Debug("MCP client initialized")
+	}
+}
+
+// write sends a response to the client
+func (c *connection) write(resp *response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		logger.Errorf("Failed to encode MCP response: %v", err)
+		return
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if _, err := c.writer.Write(append(data, '\n')); err != nil {
+		logger.Debugf("Failed to send MCP response: %v", err)
+	}
+}
+
+// call dispatches a request to the method it calls
+func (s *Server) call(ctx context.Context, req *request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return s.listTools()
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "prompts/list":
+		return s.listPrompts()
+	case "prompts/get":
+		return s.getPrompt(req.Params)
+	case "sampling/createMessage":
+		return s.createMessage(ctx, req.Params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// initialize answers the handshake of a client, agreeing on the protocol
+// revision and announcing the server's capabilities
+func (s *Server) initialize(raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	// A client asking for an unknown revision gets the latest one, which it
+	// may then reject
+	version := ProtocolVersion
+	if supportedVersions[params.ProtocolVersion] {
+		version = params.ProtocolVersion
+	}
+	logger.Infof("MCP client %s %s connected (protocol %s)", params.ClientInfo.Name, params.ClientInfo.Version, version)
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":   map[string]interface{}{},
+			"prompts": map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{
+			"name":    "colossus",
+			"version": s.version,
+		},
+	}, nil
+}
@@ -0,0 +1,550 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/template"
+	"colossus-cli/internal/types"
+)
+
+// fakeBackend serves fixed models and prompt templates, answering chats with
+// the last message of the conversation
+type fakeBackend struct {
+	models  []types.ModelInfo
+	prompts []types.PromptTemplate
+
+	// chatErr fails chats, and block holds them until they are cancelled
+	chatErr error
+	block   bool
+
+	mutex    sync.Mutex
+	requests []*types.ChatRequest
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		models: []types.ModelInfo{{Name: "tinyllama"}, {Name: "llama3:8b"}},
+		prompts: []types.PromptTemplate{
+			{Name: "summarize", Template: "Summarize: {{text}}", Variables: []string{"text"}},
+		},
+	}
+}
+
+func (b *fakeBackend) ListModels() ([]types.ModelInfo, error) {
+	return b.models, nil
+}
+
+func (b *fakeBackend) ListPrompts() ([]types.PromptTemplate, error) {
+	return b.prompts, nil
+}
+
+func (b *fakeBackend) RenderPrompt(name string, vars map[string]string) (string, error) {
+	for _, p := range b.prompts {
+		if p.Name == name {
+			return strings.ReplaceAll(p.Template, "{{text}}", vars["text"]), nil
+		}
+	}
+	return "", template.ErrTemplateNotFound
+}
+
+func (b *fakeBackend) Chat(ctx context.Context, req *types.ChatRequest) (*types.ChatResponse, error) {
+	b.mutex.Lock()
+	b.requests = append(b.requests, req)
+	b.mutex.Unlock()
+
+	if b.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if b.chatErr != nil {
+		return nil, b.chatErr
+	}
+	last := req.Messages[len(req.Messages)-1]
+	return &types.ChatResponse{
+		Model:   req.Model,
+		Message: types.Message{Role: "assistant", Content: "echo: " + last.Content},
+		Done:    true,
+	}, nil
+}
+
+// lastRequest returns the last chat request the backend received
+func (b *fakeBackend) lastRequest() *types.ChatRequest {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.requests) == 0 {
+		return nil
+	}
+	return b.requests[len(b.requests)-1]
+}
+
+// mockClient is an MCP client exchanging newline-delimited JSON-RPC messages
+// with a server
+type mockClient struct {
+	t       *testing.T
+	w       io.Writer
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// newStdioClient serves MCP to a client over pipes, as on the stdio
+// transport
+func newStdioClient(t *testing.T, backend Backend) *mockClient {
+	t.Helper()
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(backend, "1.2.3").ServeConn(context.Background(), serverR, serverW)
+		serverW.Close()
+	}()
+	t.Cleanup(func() {
+		// Closing the client's end of stdin ends the session
+		clientW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("ServeConn: %v", err)
+		}
+	})
+
+	return &mockClient{t: t, w: clientW, scanner: bufio.NewScanner(clientR)}
+}
+
+// send writes a raw message to the server
+func (c *mockClient) send(message string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.w, message+"\n"); err != nil {
+		c.t.Fatalf("failed to send %s: %v", message, err)
+	}
+}
+
+// sendRequest sends a request, returning its ID
+func (c *mockClient) sendRequest(method string, params interface{}) int {
+	c.t.Helper()
+	c.nextID++
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(string(data))
+	return c.nextID
+}
+
+// notify sends a notification
+func (c *mockClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(string(data))
+}
+
+// testResponse is a response as decoded by the client
+type testResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+// receive reads the next response from the server
+func (c *mockClient) receive() *testResponse {
+	c.t.Helper()
+	if !c.scanner.Scan() {
+		c.t.Fatalf("no response: %v", c.scanner.Err())
+	}
+	var resp testResponse
+	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+		c.t.Fatalf("invalid response %s: %v", c.scanner.Text(), err)
+	}
+	if resp.JSONRPC != "2.0" {
+		c.t.Fatalf("response %s is not JSON-RPC 2.0", c.scanner.Text())
+	}
+	return &resp
+}
+
+// call sends a request and decodes the result of its response into result,
+// returning the error of a failed request
+func (c *mockClient) call(method string, params, result interface{}) *rpcError {
+	c.t.Helper()
+	id := c.sendRequest(method, params)
+	resp := c.receive()
+	if string(resp.ID) != fmt.Sprint(id) {
+		c.t.Fatalf("response to request %s, want %d", resp.ID, id)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("invalid %s result %s: %v", method, resp.Result, err)
+		}
+	}
+	return nil
+}
+
+// initialize performs the handshake, returning the server's answer
+func (c *mockClient) initialize(version string) map[string]interface{} {
+	c.t.Helper()
+	var result map[string]interface{}
+	params := map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "mock", "version": "0.1"},
+	}
+	if err := c.call("initialize", params, &result); err != nil {
+		c.t.Fatalf("initialize: %v", err)
+	}
+	c.notify("notifications/initialized", nil)
+	return result
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{"latest", ProtocolVersion, ProtocolVersion},
+		{"older", "2024-11-05", "2024-11-05"},
+		{"unknown", "2023-01-01", ProtocolVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newStdioClient(t, newFakeBackend())
+			result := client.initialize(tt.version)
+
+			if result["protocolVersion"] != tt.want {
+				t.Errorf("protocol version = %v, want %s", result["protocolVersion"], tt.want)
+			}
+			info, _ := result["serverInfo"].(map[string]interface{})
+			if info["name"] != "colossus" || info["version"] != "1.2.3" {
+				t.Errorf("server info = %v, want colossus 1.2.3", info)
+			}
+			capabilities, _ := result["capabilities"].(map[string]interface{})
+			for _, name := range []string{"tools", "prompts"} {
+				if _, ok := capabilities[name]; !ok {
+					t.Errorf("capabilities %v do not include %s", capabilities, name)
+				}
+			}
+
+			// The initialized notification is not answered, so the next
+			// response is that of the ping
+			if err := client.call("ping", nil, nil); err != nil {
+				t.Errorf("ping: %v", err)
+			}
+		})
+	}
+}
+
+func TestTools(t *testing.T) {
+	backend := newFakeBackend()
+	client := newStdioClient(t, backend)
+	client.initialize(ProtocolVersion)
+
+	var list struct {
+		Tools []tool `json:"tools"`
+	}
+	if err := client.call("tools/list", nil, &list); err != nil {
+		t.Fatalf("tools/list: %v", err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		if !json.Valid(tool.InputSchema) {
+			t.Errorf("tool %s has an invalid input schema", tool.Name)
+		}
+	}
+	if want := []string{"tinyllama", "llama3_8b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tools = %v, want %v", names, want)
+	}
+
+	type callResult struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	var result callResult
+	params := map[string]interface{}{
+		"name":      "llama3_8b",
+		"arguments": map[string]string{"prompt": "Why is the sky blue?", "system": "Be brief."},
+	}
+	if err := client.call("tools/call", params, &result); err != nil {
+		t.Fatalf("tools/call: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "echo: Why is the sky blue?" {
+		t.Errorf("result = %+v, want the model's response", result)
+	}
+	req := backend.lastRequest()
+	wantMessages := []types.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Why is the sky blue?"}}
+	if req.Model != "llama3:8b" || !reflect.DeepEqual(req.Messages, wantMessages) {
+		t.Errorf("chat with %s and %+v, want llama3:8b and %+v", req.Model, req.Messages, wantMessages)
+	}
+
+	// Generation errors are reported in the result
+	backend.chatErr = errors.New("out of memory")
+	result = callResult{}
+	if err := client.call("tools/call", params, &result); err != nil {
+		t.Fatalf("tools/call: %v", err)
+	}
+	if !result.IsError || len(result.Content) != 1 || result.Content[0].Text != "out of memory" {
+		t.Errorf("result = %+v, want the generation error", result)
+	}
+
+	for name, params := range map[string]interface{}{
+		"unknown tool":     map[string]interface{}{"name": "mistral", "arguments": map[string]string{"prompt": "Hi"}},
+		"missing prompt":   map[string]interface{}{"name": "tinyllama", "arguments": map[string]string{}},
+		"invalid argument": map[string]interface{}{"name": "tinyllama", "arguments": "Hi"},
+	} {
+		err := client.call("tools/call", params, nil)
+		if err == nil || err.Code != codeInvalidParams {
+			t.Errorf("%s: error = %v, want invalid params", name, err)
+		}
+	}
+}
+
+func TestPrompts(t *testing.T) {
+	client := newStdioClient(t, newFakeBackend())
+	client.initialize(ProtocolVersion)
+
+	var list map[string]interface{}
+	if err := client.call("prompts/list", nil, &list); err != nil {
+		t.Fatalf("prompts/list: %v", err)
+	}
+	want := map[string]interface{}{
+		"prompts": []interface{}{map[string]interface{}{
+			"name":      "summarize",
+			"arguments": []interface{}{map[string]interface{}{"name": "text", "required": true}},
+		}},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("prompts = %v, want %v", list, want)
+	}
+
+	var prompt struct {
+		Messages []promptMessage `json:"messages"`
+	}
+	params := map[string]interface{}{"name": "summarize", "arguments": map[string]string{"text": "MCP"}}
+	if err := client.call("prompts/get", params, &prompt); err != nil {
+		t.Fatalf("prompts/get: %v", err)
+	}
+	wantMessages := []promptMessage{{Role: "user", Content: content{Type: "text", Text: "Summarize: MCP"}}}
+	if !reflect.DeepEqual(prompt.Messages, wantMessages) {
+		t.Errorf("messages = %+v, want %+v", prompt.Messages, wantMessages)
+	}
+
+	err := client.call("prompts/get", map[string]interface{}{"name": "translate"}, nil)
+	if err == nil || err.Code != codeInvalidParams || !strings.Contains(err.Message, "unknown prompt") {
+		t.Errorf("unknown prompt: error = %v, want invalid params", err)
+	}
+}
+
+func TestCreateMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		hints     []string
+		wantModel string
+	}{
+		{"no hints", nil, "tinyllama"},
+		{"exact name", []string{"llama3:8b"}, "llama3:8b"},
+		{"substring", []string{"llama3"}, "llama3:8b"},
+		{"first matching hint", []string{"claude", "tiny", "llama3"}, "tinyllama"},
+		{"no matching hint", []string{"claude"}, "tinyllama"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend()
+			client := newStdioClient(t, backend)
+			client.initialize(ProtocolVersion)
+
+			var hints []map[string]string
+			for _, hint := range tt.hints {
+				hints = append(hints, map[string]string{"name": hint})
+			}
+			params := map[string]interface{}{
+				"messages": []promptMessage{
+					{Role: "user", Content: content{Type: "image", Data: "aW1hZ2U=", MimeType: "image/png"}},
+					{Role: "user", Content: content{Type: "text", Text: "What is this?"}},
+				},
+				"modelPreferences": map[string]interface{}{"hints": hints},
+				"systemPrompt":     "Be brief.",
+				"maxTokens":        64,
+				"temperature":      0.5,
+				"stopSequences":    []string{"\n"},
+			}
+
+			var result struct {
+				Role    string  `json:"role"`
+				Content content `json:"content"`
+				Model   string  `json:"model"`
+			}
+			if err := client.call("sampling/createMessage", params, &result); err != nil {
+				t.Fatalf("sampling/createMessage: %v", err)
+			}
+			if result.Role != "assistant" || result.Model != tt.wantModel || result.Content.Text != "echo: What is this?" {
+				t.Errorf("result = %+v, want the response of %s", result, tt.wantModel)
+			}
+
+			req := backend.lastRequest()
+			wantMessages := []types.Message{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Images: [][]byte{[]byte("image")}},
+				{Role: "user", Content: "What is this?"},
+			}
+			if !reflect.DeepEqual(req.Messages, wantMessages) {
+				t.Errorf("messages = %+v, want %+v", req.Messages, wantMessages)
+			}
+			wantOptions := &types.Options{Temperature: 0.5, NumPredict: 64, Stop: []string{"\n"}}
+			if !reflect.DeepEqual(req.Options, wantOptions) {
+				t.Errorf("options = %+v, want %+v", req.Options, wantOptions)
+			}
+		})
+	}
+}
+
+func TestCreateMessageErrors(t *testing.T) {
+	backend := newFakeBackend()
+	client := newStdioClient(t, backend)
+	client.initialize(ProtocolVersion)
+
+	text := []promptMessage{{Role: "user", Content: content{Type: "text", Text: "Hi"}}}
+	tests := []struct {
+		name     string
+		messages []promptMessage
+		wantCode int
+	}{
+		{"no messages", nil, codeInvalidParams},
+		{"invalid image", []promptMessage{{Role: "user", Content: content{Type: "image", Data: "not base64!"}}}, codeInvalidParams},
+		{"unsupported content", []promptMessage{{Role: "user", Content: content{Type: "audio", Data: "aW1hZ2U="}}}, codeInvalidParams},
+	}
+	for _, tt := range tests {
+		err := client.call("sampling/createMessage", map[string]interface{}{"messages": tt.messages}, nil)
+		if err == nil || err.Code != tt.wantCode {
+			t.Errorf("%s: error = %v, want code %d", tt.name, err, tt.wantCode)
+		}
+	}
+
+	// Unlike tool errors, sampling errors fail the request
+	backend.chatErr = errors.New("out of memory")
+	err := client.call("sampling/createMessage", map[string]interface{}{"messages": text}, nil)
+	if err == nil || err.Code != codeInternalError || err.Message != "out of memory" {
+		t.Errorf("generation error = %v, want an internal error", err)
+	}
+
+	backend.models = nil
+	err = client.call("sampling/createMessage", map[string]interface{}{"messages": text}, nil)
+	if err == nil || err.Code != codeInternalError {
+		t.Errorf("without models: error = %v, want an internal error", err)
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	client := newStdioClient(t, newFakeBackend())
+
+	tests := []struct {
+		name     string
+		message  string
+		wantID   string
+		wantCode int
+	}{
+		{"invalid JSON", `{"jsonrpc": "2.0", "id": 1,`, "null", codeParseError},
+		{"wrong version", `{"jsonrpc": "1.0", "id": 2, "method": "ping"}`, "2", codeInvalidRequest},
+		{"missing method", `{"jsonrpc": "2.0", "id": 3}`, "3", codeInvalidRequest},
+		{"unknown method", `{"jsonrpc": "2.0", "id": "four", "method": "resources/list"}`, `"four"`, codeMethodNotFound},
+		{"invalid params", `{"jsonrpc": "2.0", "id": 5, "method": "initialize", "params": []}`, "5", codeInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.send(tt.message)
+			resp := client.receive()
+			if string(resp.ID) != tt.wantID {
+				t.Errorf("response ID = %s, want %s", resp.ID, tt.wantID)
+			}
+			if resp.Error == nil || resp.Error.Code != tt.wantCode {
+				t.Errorf("error = %v, want code %d", resp.Error, tt.wantCode)
+			}
+		})
+	}
+
+	// Invalid notifications are not answered
+	client.send(`{"jsonrpc": "1.0", "method": "notifications/initialized"}`)
+	if err := client.call("ping", nil, nil); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	backend := newFakeBackend()
+	backend.block = true
+	client := newStdioClient(t, backend)
+	client.initialize(ProtocolVersion)
+
+	params := map[string]interface{}{"name": "tinyllama", "arguments": map[string]string{"prompt": "Hi"}}
+	id := client.sendRequest("tools/call", params)
+
+	// Pings are answered while the generation runs
+	if err := client.call("ping", nil, nil); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	client.notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": "user"})
+
+	// The cancelled request is not answered, so the next response is the
+	// ping's
+	pingID := client.sendRequest("ping", nil)
+	if resp := client.receive(); string(resp.ID) != fmt.Sprint(pingID) {
+		t.Errorf("response to request %s, want the ping %d", resp.ID, pingID)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(newFakeBackend(), "1.2.3")
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := &mockClient{t: t, w: conn, scanner: bufio.NewScanner(conn)}
+	if result := client.initialize(ProtocolVersion); result["protocolVersion"] != ProtocolVersion {
+		t.Errorf("protocol version = %v, want %s", result["protocolVersion"], ProtocolVersion)
+	}
+
+	// Closing the server closes its connections and stops serving
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Close")
+	}
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// adaptersDirName is the directory next to the models directory holding the
+// LoRA adapters installed for each model, e.g. ~/.colossus/adapters/llama3/
+const adaptersDirName = "adapters"
+
+// adaptersDir returns the directory of the LoRA adapters installed for a model
+func (m *Manager) adaptersDir(modelName string) (string, error) {
+	if modelName == "" || strings.ContainsAny(modelName, `/\`) || modelName == "." || modelName == ".." {
+		return "", fmt.Errorf("invalid model name: %q", modelName)
+	}
+	return filepath.Join(filepath.Dir(m.modelsPath), adaptersDirName, modelName), nil
+}
+
+// ListAdapters returns the paths of the LoRA adapters installed for a model,
+// sorted by file name, which is the order they are applied in
+func (m *Manager) ListAdapters(modelName string) ([]string, error) {
+	dir, err := m.adaptersDir(modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read adapters: %w", err)
+	}
+
+	var adapters []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isAdapterFile(entry.Name()) {
+			adapters = append(adapters, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(adapters)
+	return adapters, nil
+}
+
+// AddAdapter installs a LoRA adapter for a model by copying the adapter file
+// into the model's adapters directory. It returns the installed path.
+func (m *Manager) AddAdapter(modelName, path string) (string, error) {
+	dir, err := m.adaptersDir(modelName)
+	if err != nil {
+		return "", err
+	}
+	if !isAdapterFile(path) {
+		return "", fmt.Errorf("unsupported adapter file: %s (expected .gguf or .bin)", path)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open adapter: %w", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create adapters directory: %w", err)
+	}
+
+	target := filepath.Join(dir, filepath.Base(path))
+	dst, err := os.Create(target)
+	if err != nil {
+		return "", fmt.Errorf("failed to create adapter: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(target)
+		return "", fmt.Errorf("failed to copy adapter: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("failed to copy adapter: %w", err)
+	}
+	return target, nil
+}
+
+// RemoveAdapter removes a LoRA adapter installed for a model. The adapter is
+// named by its file name, with or without the extension.
+func (m *Manager) RemoveAdapter(modelName, adapterName string) error {
+	adapters, err := m.ListAdapters(modelName)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range adapters {
+		fileName := filepath.Base(path)
+		if fileName == adapterName || strings.TrimSuffix(fileName, filepath.Ext(fileName)) == adapterName {
+			return os.Remove(path)
+		}
+	}
+	return fmt.Errorf("adapter not found for model %s: %s", modelName, adapterName)
+}
+
+// isAdapterFile reports whether a file name has a LoRA adapter extension
+func isAdapterFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".gguf" || ext == ".bin"
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAdapters(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(filepath.Join(root, "models"))
+	source := t.TempDir()
+	for _, name := range []string{"style.gguf", "domain.bin", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte("GGUF"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if adapters, err := m.ListAdapters("llama3"); err != nil || adapters != nil {
+		t.Fatalf("ListAdapters() = %v, %v, want none", adapters, err)
+	}
+
+	for _, name := range []string{"style.gguf", "domain.bin"} {
+		path, err := m.AddAdapter("llama3", filepath.Join(source, name))
+		if err != nil {
+			t.Fatalf("AddAdapter(%s): %v", name, err)
+		}
+		if want := filepath.Join(root, "adapters", "llama3", name); path != want {
+			t.Errorf("installed at %s, want %s", path, want)
+		}
+	}
+	if _, err := m.AddAdapter("llama3", filepath.Join(source, "notes.txt")); err == nil {
+		t.Error("AddAdapter accepted a .txt file")
+	}
+	if _, err := m.AddAdapter("llama3", filepath.Join(source, "missing.gguf")); err == nil {
+		t.Error("AddAdapter accepted a missing file")
+	}
+
+	// Adapters are listed in the order they are applied in
+	adapters, err := m.ListAdapters("llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(root, "adapters", "llama3", "domain.bin"),
+		filepath.Join(root, "adapters", "llama3", "style.gguf"),
+	}
+	if !reflect.DeepEqual(adapters, want) {
+		t.Errorf("ListAdapters() = %v, want %v", adapters, want)
+	}
+	if other, err := m.ListAdapters("mistral"); err != nil || other != nil {
+		t.Errorf("ListAdapters(mistral) = %v, %v, want none", other, err)
+	}
+
+	// Adapters can be removed with or without their extension
+	if err := m.RemoveAdapter("llama3", "domain"); err != nil {
+		t.Fatalf("RemoveAdapter(domain): %v", err)
+	}
+	if err := m.RemoveAdapter("llama3", "style.gguf"); err != nil {
+		t.Fatalf("RemoveAdapter(style.gguf): %v", err)
+	}
+	if err := m.RemoveAdapter("llama3", "style"); err == nil {
+		t.Error("RemoveAdapter succeeded for a removed adapter")
+	}
+	if adapters, err := m.ListAdapters("llama3"); err != nil || len(adapters) != 0 {
+		t.Errorf("ListAdapters() = %v, %v, want none", adapters, err)
+	}
+}
+
+func TestAdaptersInvalidModelName(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	for _, name := range []string{"", ".", "..", "../llama3", `a\b`} {
+		if _, err := m.ListAdapters(name); err == nil {
+			t.Errorf("ListAdapters(%q) succeeded", name)
+		}
+	}
+}
+
+func TestModelOptionsLoRAAdapters(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "llama3.gguf")
+	options := "lora_adapters:\n  - path: adapters/style.gguf\n    scale: 0.5\n  - path: /opt/domain.gguf\n"
+	if err := os.WriteFile(ModelOptionsPath(modelPath), []byte(options), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewManager(dir).GetModelOptions(modelPath)
+	if err != nil {
+		t.Fatalf("GetModelOptions: %v", err)
+	}
+	want := []LoRAAdapterOption{
+		{Path: filepath.Join(dir, "adapters", "style.gguf"), Scale: 0.5},
+		{Path: "/opt/domain.gguf"},
+	}
+	if !reflect.DeepEqual(got.LoRAAdapters, want) {
+		t.Errorf("LoRAAdapters = %+v, want %+v", got.LoRAAdapters, want)
+	}
+
+	if err := (&ModelOptions{LoRAAdapters: []LoRAAdapterOption{{Scale: 1}}}).Validate(); err == nil {
+		t.Error("Validate accepted an adapter without a path")
+	}
+}
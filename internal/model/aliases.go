@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// aliasesFileName is the alias registry stored next to the models directory
+const aliasesFileName = "aliases.json"
+
+// aliasesPath returns the path of the alias registry, e.g. ~/.colossus/aliases.json
+func (m *Manager) aliasesPath() string {
+	return filepath.Join(filepath.Dir(m.modelsPath), aliasesFileName)
+}
+
+// ListAliases returns all aliases mapped to the model name or path they refer to
+func (m *Manager) ListAliases() (map[string]string, error) {
+	data, err := os.ReadFile(m.aliasesPath())
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", m.aliasesPath(), err)
+	}
+	return aliases, nil
+}
+
+// AddAlias maps alias to a local model name or model file path, replacing any
+// existing alias of the same name
+func (m *Manager) AddAlias(alias, modelNameOrPath string) error {
+	if alias == "" || strings.ContainsAny(alias, `/\`) {
+		return fmt.Errorf("invalid alias: %q", alias)
+	}
+
+	target := modelNameOrPath
+	if info, err := os.Stat(modelNameOrPath); err == nil && !info.IsDir() {
+		// Store file paths as absolute so the alias works from any directory
+		if abs, err := filepath.Abs(modelNameOrPath); err == nil {
+			target = abs
+		}
+	} else if _, err := m.findModel(modelNameOrPath); err != nil {
+		return err
+	}
+
+	aliases, err := m.ListAliases()
+	if err != nil {
+		return err
+	}
+	aliases[alias] = target
+	return m.saveAliases(aliases)
+}
+
+// RemoveAlias removes an alias. The model it refers to is left untouched.
+func (m *Manager) RemoveAlias(alias string) error {
+	aliases, err := m.ListAliases()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := aliases[alias]; !ok {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+	delete(aliases, alias)
+	return m.saveAliases(aliases)
+}
+
+// ResolveAlias returns the model name or path an alias refers to. Names that
+// are not aliases are returned unchanged.
+func (m *Manager) ResolveAlias(name string) (string, error) {
+	aliases, err := m.ListAliases()
+	if err != nil {
+		return "", err
+	}
+
+	if target, ok := aliases[name]; ok {
+		return target, nil
+	}
+	return name, nil
+}
+
+// saveAliases writes the alias registry, replacing the previous file atomically
+func (m *Manager) saveAliases(aliases map[string]string) error {
+	path := m.aliasesPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode aliases: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
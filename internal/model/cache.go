@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// modelCacheFileName is the model metadata cache stored next to the models
+// directory, so that listing models does not parse every model file again
+const modelCacheFileName = "model-cache.json"
+
+// modelCacheEntry is the cached validation result of one model file. It is
+// used as long as the file's modification time and size are unchanged.
+type modelCacheEntry struct {
+	Path    string     `json:"path"`
+	ModTime time.Time  `json:"modtime"`
+	Size    int64      `json:"size"`
+	Info    *ModelInfo `json:"info"`
+}
+
+// modelCache maps model file paths to their cached validation results
+type modelCache map[string]modelCacheEntry
+
+// modelCachePath returns the path of the cache file, e.g. ~/.colossus/model-cache.json
+func (m *Manager) modelCachePath() string {
+	return filepath.Join(filepath.Dir(m.modelsPath), modelCacheFileName)
+}
+
+// loadModelCache reads the cache file. A missing or unreadable cache is
+// treated as empty, so every model is validated again.
+func (m *Manager) loadModelCache() modelCache {
+	cache := make(modelCache)
+
+	data, err := os.ReadFile(m.modelCachePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Debugf("Ignoring model cache: %v", err)
+		}
+		return cache
+	}
+
+	var entries []modelCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.Debugf("Ignoring invalid model cache: %v", err)
+		return cache
+	}
+	for _, entry := range entries {
+		if entry.Info != nil {
+			cache[entry.Path] = entry
+		}
+	}
+	return cache
+}
+
+// saveModelCache writes the cache file, replacing it atomically
+func (m *Manager) saveModelCache(cache modelCache) error {
+	entries := make([]modelCacheEntry, 0, len(cache))
+	for _, entry := range cache {
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := m.modelCachePath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lookup returns the cached validation result of a file, or nil when the
+// file is not cached or has changed since
+func (c modelCache) lookup(path string, info os.FileInfo) *ModelInfo {
+	entry, ok := c[path]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return entry.Info
+}
+
+// store caches the validation result of a file. Metadata and tensors are not
+// cached: listing models only needs the summary.
+func (c modelCache) store(path string, info os.FileInfo, modelInfo *ModelInfo) {
+	summary := *modelInfo
+	summary.Metadata = nil
+	summary.Tensors = nil
+
+	c[path] = modelCacheEntry{
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Info:    &summary,
+	}
+}
+
+// RefreshModelCache discards the model metadata cache, so that the next
+// ListModels validates every model file again
+func (m *Manager) RefreshModelCache() error {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
+
+	if err := os.Remove(m.modelCachePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// invalidateModelCache removes the cached results of model files that have
+// been downloaded or removed
+func (m *Manager) invalidateModelCache(paths ...string) {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
+
+	cache := m.loadModelCache()
+	changed := false
+	for _, path := range paths {
+		if _, ok := cache[path]; ok {
+			delete(cache, path)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := m.saveModelCache(cache); err != nil {
+			logger.Warnf("Failed to update model cache: %v", err)
+		}
+	}
+}
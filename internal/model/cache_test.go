@@ -0,0 +1,87 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listQuantization lists the models of m and returns the quantization of the
+// only one
+func listQuantization(t *testing.T, m *Manager) string {
+	t.Helper()
+	models, err := m.ListModels()
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("listed %d models, want 1", len(models))
+	}
+	return models[0].Quantization
+}
+
+func TestModelCacheInvalidatedOnChange(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "models")
+	m := NewManager(dir)
+
+	// Q4_0 and Q8_0 files are the same size, so only the modification time
+	// tells them apart
+	path := writeGGUF(t, dir, "llama.gguf", ggufKV{"general.file_type", uint32(2)})
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listQuantization(t, m); got != "Q4_0" {
+		t.Fatalf("quantization = %s, want Q4_0", got)
+	}
+	if _, err := os.Stat(m.modelCachePath()); err != nil {
+		t.Fatalf("cache not saved: %v", err)
+	}
+
+	// An unchanged modification time and size serve the cached metadata
+	writeGGUF(t, dir, "llama.gguf", ggufKV{"general.file_type", uint32(7)})
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := listQuantization(t, m); got != "Q4_0" {
+		t.Errorf("quantization with an unchanged modification time = %s, want the cached Q4_0", got)
+	}
+
+	// A new modification time validates the file again
+	if err := os.Chtimes(path, modTime.Add(time.Minute), modTime.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := listQuantization(t, m); got != "Q8_0" {
+		t.Errorf("quantization after a change = %s, want Q8_0", got)
+	}
+
+	// A fresh manager reads the updated cache from disk
+	if got := listQuantization(t, NewManager(dir)); got != "Q8_0" {
+		t.Errorf("quantization from the saved cache = %s, want Q8_0", got)
+	}
+}
+
+func TestRefreshModelCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "models")
+	m := NewManager(dir)
+	path := writeGGUF(t, dir, "llama.gguf", ggufKV{"general.file_type", uint32(2)})
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listQuantization(t, m)
+
+	// Refreshing discards the cache even though the file looks unchanged
+	writeGGUF(t, dir, "llama.gguf", ggufKV{"general.file_type", uint32(7)})
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RefreshModelCache(); err != nil {
+		t.Fatalf("RefreshModelCache: %v", err)
+	}
+	if got := listQuantization(t, m); got != "Q8_0" {
+		t.Errorf("quantization after refreshing = %s, want Q8_0", got)
+	}
+}
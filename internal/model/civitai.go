@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"colossus-cli/internal/registry"
+)
+
+// civitaiPrefix marks model names referring to models on Civitai, e.g.
+// "civitai:12345" or "civitai:12345@67890" for a given model version
+const civitaiPrefix = "civitai:"
+
+// IsCivitaiReference reports whether a model name refers to a model on Civitai
+func IsCivitaiReference(name string) bool {
+	return strings.HasPrefix(name, civitaiPrefix)
+}
+
+// parseCivitaiReference parses a civitai:MODEL_ID[@VERSION_ID] reference.
+// The version is 0 for the latest version of the model.
+func parseCivitaiReference(ref string) (modelID, versionID int, err error) {
+	id, version, hasVersion := strings.Cut(strings.TrimPrefix(ref, civitaiPrefix), "@")
+	if modelID, err = strconv.Atoi(id); err != nil || modelID <= 0 {
+		return 0, 0, fmt.Errorf("invalid Civitai reference %q: expected civitai:MODEL_ID[@VERSION_ID]", ref)
+	}
+	if hasVersion {
+		if versionID, err = strconv.Atoi(version); err != nil || versionID <= 0 {
+			return 0, 0, fmt.Errorf("invalid Civitai reference %q: expected civitai:MODEL_ID[@VERSION_ID]", ref)
+		}
+	}
+	return modelID, versionID, nil
+}
+
+// CivitaiFormat maps the format of a Civitai file, e.g. "SafeTensor", to the
+// model format
+func CivitaiFormat(format string) ModelFormat {
+	switch strings.ToLower(format) {
+	case "gguf":
+		return FormatGGUF
+	case "ggml":
+		return FormatGGML
+	case "safetensor", "safetensors":
+		return FormatSafeTensors
+	case "pickletensor":
+		return FormatPyTorch
+	case "onnx":
+		return FormatONNX
+	default:
+		return FormatUnknown
+	}
+}
+
+// selectCivitaiFile returns the GGUF file of a model version, preferring the
+// version's primary file
+func selectCivitaiFile(version registry.CivitaiModelVersion) (registry.CivitaiFile, error) {
+	var formats []string
+	var selected *registry.CivitaiFile
+	for i, file := range version.Files {
+		format := CivitaiFormat(file.Metadata.Format)
+		if format == FormatUnknown && strings.EqualFold(filepath.Ext(file.Name), ".gguf") {
+			format = FormatGGUF
+		}
+		if format != FormatGGUF {
+			formats = append(formats, file.Metadata.Format)
+			continue
+		}
+		if selected == nil || file.Primary {
+			selected = &version.Files[i]
+		}
+	}
+	if selected == nil {
+		return registry.CivitaiFile{}, fmt.Errorf("version %d has no GGUF file (formats: %s)", version.ID, strings.Join(formats, ", "))
+	}
+	return *selected, nil
+}
+
+// pullFromCivitai downloads the GGUF file of a Civitai model. The model is
+// named civitai-MODEL_ID, tagged DefaultTag for its latest version or
+// vVERSION_ID for a given version.
+func (m *Manager) pullFromCivitai(ctx context.Context, ref string, progressCallback ProgressCallback) error {
+	modelID, versionID, err := parseCivitaiReference(ref)
+	if err != nil {
+		return err
+	}
+
+	info, err := m.civitai.GetModelInfo(modelID)
+	if err != nil {
+		return fmt.Errorf("failed to download from Civitai: %w", err)
+	}
+	if len(info.ModelVersions) == 0 {
+		return fmt.Errorf("failed to download from Civitai: model %d has no versions", modelID)
+	}
+
+	name, tag := fmt.Sprintf("civitai-%d", modelID), DefaultTag
+	version := info.ModelVersions[0]
+	if versionID != 0 {
+		tag = fmt.Sprintf("v%d", versionID)
+		found := false
+		for _, v := range info.ModelVersions {
+			if v.ID == versionID {
+				version, found = v, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("failed to download from Civitai: model %d has no version %d", modelID, versionID)
+		}
+	}
+
+	file, err := selectCivitaiFile(version)
+	if err != nil {
+		return fmt.Errorf("failed to download from Civitai: %s: %w", info.Name, err)
+	}
+	logger.Infof("Pulling %s (%s) from Civitai as %s:%s", info.Name, version.Name, name, tag)
+
+	modelPath := m.taggedFilePath(name, tag)
+	source := file.DownloadURL
+	if m.isPulled(name, tag, modelPath, source, file.Size(), file.Checksum()) {
+		logger.Infof("Model %s:%s is up to date", name, tag)
+		return nil
+	}
+	if err := m.ensureDiskSpace(file.Size()); err != nil {
+		return err
+	}
+	if m.hfRegistry.ConfirmDownload != nil {
+		if err := m.hfRegistry.ConfirmDownload(file.Name, file.Size()); err != nil {
+			return err
+		}
+	}
+
+	// Convert progress callback
+	civitaiCallback := func(progress registry.DownloadProgress) error {
+		if progressCallback == nil {
+			return nil
+		}
+
+		localProgress := DownloadProgress{
+			ModelName:  name,
+			FileName:   progress.FileName,
+			Downloaded: progress.Downloaded,
+			Total:      progress.Total,
+			Speed:      progress.Speed,
+			ETA:        progress.ETA,
+			Status:     progress.Status,
+			Checksum:   progress.Checksum,
+		}
+
+		if progress.Total > 0 {
+			localProgress.Percentage = float64(progress.Downloaded) / float64(progress.Total) * 100
+		}
+
+		return progressCallback(localProgress)
+	}
+
+	if err := m.civitai.DownloadModel(ctx, modelID, file, modelPath, civitaiCallback); err != nil {
+		return fmt.Errorf("failed to download from Civitai: %w", err)
+	}
+
+	m.invalidateModelCache(modelPath)
+
+	if err := m.verifyDownload(modelPath, file.Checksum()); err != nil {
+		return err
+	}
+
+	logger.Infof("Successfully downloaded model %s:%s to %s", name, tag, modelPath)
+	return m.recordPull(name, tag, modelPath, source, file.Checksum())
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"colossus-cli/internal/registry"
+)
+
+func TestParseCivitaiReference(t *testing.T) {
+	tests := []struct {
+		ref         string
+		wantModel   int
+		wantVersion int
+		wantErr     bool
+	}{
+		{ref: "civitai:12345", wantModel: 12345},
+		{ref: "civitai:12345@678", wantModel: 12345, wantVersion: 678},
+		{ref: "civitai:", wantErr: true},
+		{ref: "civitai:llama", wantErr: true},
+		{ref: "civitai:12345@", wantErr: true},
+		{ref: "civitai:-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		modelID, versionID, err := parseCivitaiReference(tt.ref)
+		if (err != nil) != tt.wantErr || modelID != tt.wantModel || versionID != tt.wantVersion {
+			t.Errorf("parseCivitaiReference(%q) = %d, %d, %v, want %d, %d, wantErr %t",
+				tt.ref, modelID, versionID, err, tt.wantModel, tt.wantVersion, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectCivitaiFile(t *testing.T) {
+	gguf := registry.CivitaiFileMetadata{Format: "GGUF"}
+	tests := []struct {
+		name    string
+		files   []registry.CivitaiFile
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "primary GGUF file",
+			files: []registry.CivitaiFile{
+				{Name: "model.Q8_0.gguf", Metadata: gguf},
+				{Name: "model.Q4_K_M.gguf", Metadata: gguf, Primary: true},
+			},
+			want: "model.Q4_K_M.gguf",
+		},
+		{
+			name: "GGUF file among other formats",
+			files: []registry.CivitaiFile{
+				{Name: "model.safetensors", Metadata: registry.CivitaiFileMetadata{Format: "SafeTensor"}, Primary: true},
+				{Name: "model.Q4_0.gguf", Metadata: gguf},
+			},
+			want: "model.Q4_0.gguf",
+		},
+		{
+			name:  "GGUF file of unknown format",
+			files: []registry.CivitaiFile{{Name: "model.GGUF", Metadata: registry.CivitaiFileMetadata{Format: "Other"}}},
+			want:  "model.GGUF",
+		},
+		{
+			name:    "no GGUF file",
+			files:   []registry.CivitaiFile{{Name: "model.ckpt", Metadata: registry.CivitaiFileMetadata{Format: "PickleTensor"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := selectCivitaiFile(registry.CivitaiModelVersion{ID: 1, Files: tt.files})
+			if (err != nil) != tt.wantErr || file.Name != tt.want {
+				t.Errorf("selectCivitaiFile() = %q, %v, want %q, wantErr %t", file.Name, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCivitaiFormat(t *testing.T) {
+	tests := map[string]ModelFormat{
+		"GGUF":         FormatGGUF,
+		"SafeTensor":   FormatSafeTensors,
+		"PickleTensor": FormatPyTorch,
+		"ONNX":         FormatONNX,
+		"Diffusers":    FormatUnknown,
+	}
+	for format, want := range tests {
+		if got := CivitaiFormat(format); got != want {
+			t.Errorf("CivitaiFormat(%q) = %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestPullFromCivitai(t *testing.T) {
+	latest := ggufFile(ggufKV{"general.name", "v2"})
+	older := ggufFile(ggufKV{"general.name", "v1"})
+	sha := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	var downloads atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/models/42":
+			version := func(id int, data []byte) map[string]interface{} {
+				return map[string]interface{}{
+					"id":   id,
+					"name": fmt.Sprintf("v%d", id),
+					"files": []map[string]interface{}{{
+						"name":        "tiny.Q4_0.gguf",
+						"sizeKB":      float64(len(data)) / 1024,
+						"metadata":    map[string]string{"format": "GGUF"},
+						"hashes":      map[string]string{"SHA256": sha(data)},
+						"downloadUrl": fmt.Sprintf("%s/api/download/models/%d", srv.URL, id),
+					}},
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":            42,
+				"name":          "Tiny",
+				"modelVersions": []interface{}{version(2, latest), version(1, older)},
+			})
+		case "/api/download/models/2":
+			downloads.Add(1)
+			w.Write(latest)
+		case "/api/download/models/1":
+			downloads.Add(1)
+			w.Write(older)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewManager(t.TempDir())
+	m.civitai.BaseURL = srv.URL
+
+	tests := []struct {
+		ref  string
+		name string
+		want []byte
+	}{
+		{ref: "civitai:42", name: "civitai-42", want: latest},
+		{ref: "civitai:42@1", name: "civitai-42:v1", want: older},
+	}
+	for _, tt := range tests {
+		if err := m.PullModelWithProgress(context.Background(), tt.ref, nil); err != nil {
+			t.Fatalf("PullModelWithProgress(%s): %v", tt.ref, err)
+		}
+		path, err := m.GetModelPath(tt.name)
+		if err != nil {
+			t.Fatalf("GetModelPath(%s): %v", tt.name, err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != string(tt.want) {
+			t.Errorf("%s holds %q, %v, want its version", tt.name, data, err)
+		}
+	}
+
+	// Pulled models are not downloaded again
+	if err := m.PullModelWithProgress(context.Background(), "civitai:42", nil); err != nil {
+		t.Fatalf("PullModelWithProgress: %v", err)
+	}
+	if n := downloads.Load(); n != 2 {
+		t.Errorf("downloaded %d times, want 2", n)
+	}
+
+	for _, ref := range []string{"civitai:42@3", "civitai:43", "civitai:tiny"} {
+		if err := m.PullModelWithProgress(context.Background(), ref, nil); err == nil {
+			t.Errorf("PullModelWithProgress(%s) succeeded", ref)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(m.modelsPath, "civitai-43")); !os.IsNotExist(err) {
+		t.Errorf("files left for a failed pull: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// modelConfigFileName is the per-model settings file stored next to the
+// models directory. It maps model names to their settings, e.g.
+//
+//	{"llama3": {"request_timeout": "15m"}}
+const modelConfigFileName = "models.json"
+
+// ModelConfig holds settings that override the server defaults for one model
+type ModelConfig struct {
+	// RequestTimeout bounds how long a single request may run, 0 for the default
+	RequestTimeout time.Duration
+}
+
+// modelConfigEntry is the JSON form of a ModelConfig
+type modelConfigEntry struct {
+	RequestTimeout string `json:"request_timeout,omitempty"`
+}
+
+// modelConfigPath returns the path of the model config file, e.g. ~/.colossus/models.json
+func (m *Manager) modelConfigPath() string {
+	return filepath.Join(filepath.Dir(m.modelsPath), modelConfigFileName)
+}
+
+// GetModelConfig returns the settings configured for a model. Settings are
+// looked up by the name the model was requested with, then by the model an
+// alias refers to. A model without settings gets an empty config.
+func (m *Manager) GetModelConfig(name string) (*ModelConfig, error) {
+	data, err := os.ReadFile(m.modelConfigPath())
+	if os.IsNotExist(err) {
+		return &ModelConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model config: %w", err)
+	}
+
+	entries := make(map[string]modelConfigEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", m.modelConfigPath(), err)
+	}
+
+	entry, ok := entries[name]
+	if !ok {
+		if target, err := m.ResolveAlias(name); err == nil {
+			entry, ok = entries[target]
+		}
+	}
+	if !ok {
+		return &ModelConfig{}, nil
+	}
+
+	config := &ModelConfig{}
+	if entry.RequestTimeout != "" {
+		timeout, err := time.ParseDuration(entry.RequestTimeout)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid request_timeout for model %s: %q", name, entry.RequestTimeout)
+		}
+		config.RequestTimeout = timeout
+	}
+	return config, nil
+}
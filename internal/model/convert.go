@@ -0,0 +1,332 @@
+package model
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GGUFVersion1 is the first GGUF version, which encoded counts and string
+	// lengths as 32-bit integers. llama.cpp no longer loads it.
+	GGUFVersion1 = 1
+
+	// GGUFLatestVersion is the version GGUF files are migrated to
+	GGUFLatestVersion = GGUFVersion3
+
+	// defaultGGUFAlignment is the alignment of the tensor data of GGUF files
+	// without general.alignment
+	defaultGGUFAlignment = 32
+)
+
+// ggufValueSizes maps the fixed-size GGUF metadata value types to their size
+var ggufValueSizes = map[uint32]int{
+	GGUFTypeUint8:   1,
+	GGUFTypeInt8:    1,
+	GGUFTypeUint16:  2,
+	GGUFTypeInt16:   2,
+	GGUFTypeUint32:  4,
+	GGUFTypeInt32:   4,
+	GGUFTypeFloat32: 4,
+	GGUFTypeBool:    1,
+	GGUFTypeUint64:  8,
+	GGUFTypeInt64:   8,
+	GGUFTypeFloat64: 8,
+}
+
+// MigrateGGUF rewrites the GGUF file at path, or of the installed model of
+// that name, in the latest GGUF version, to <original>-v3.gguf next to it,
+// and returns the path of the new file
+func (m *Manager) MigrateGGUF(path string) (string, error) {
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		resolved, resolveErr := m.findModelVariant(path)
+		if resolveErr != nil {
+			return "", fmt.Errorf("model file not found: %s", path)
+		}
+		path = resolved
+	}
+
+	output := strings.TrimSuffix(path, filepath.Ext(path)) + fmt.Sprintf("-v%d.gguf", GGUFLatestVersion)
+	if err := MigrateGGUFFile(path, output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// MigrateGGUFFile rewrites the GGUF file input in the latest GGUF version to
+// output. Version 1 files have their counts and string lengths widened to
+// 64 bits; metadata, tensor infos and tensor data are otherwise copied as
+// they are. The output is validated before it replaces any existing file.
+func MigrateGGUFFile(input, output string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("failed to open model: %w", err)
+	}
+	defer in.Close()
+
+	tmp := output + partialDownloadExt
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	migrator := &ggufMigrator{
+		r:         &byteCounter{r: bufio.NewReader(in)},
+		w:         bufio.NewWriter(out),
+		alignment: defaultGGUFAlignment,
+	}
+	if err := migrator.migrate(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to migrate %s: %w", filepath.Base(input), err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	info, err := ValidateModel(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to validate output: %w", err)
+	}
+	if !info.Valid {
+		return fmt.Errorf("migrated model is invalid: %s", info.Error)
+	}
+	return os.Rename(tmp, output)
+}
+
+// ggufMigrator rewrites a GGUF file in the latest version. Tensor data
+// offsets are relative to the aligned start of the data, so only the padding
+// before it changes when the header grows.
+type ggufMigrator struct {
+	r         *byteCounter
+	w         *bufio.Writer
+	written   int64
+	version   uint32
+	alignment uint64
+}
+
+func (g *ggufMigrator) migrate() error {
+	var header struct {
+		Magic   uint32
+		Version uint32
+	}
+	if err := binary.Read(g.r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if header.Magic != GGUFMagic {
+		return fmt.Errorf("not a GGUF file")
+	}
+
+	g.version = header.Version
+	switch {
+	case g.version == GGUFLatestVersion:
+		return fmt.Errorf("already GGUF v%d", GGUFLatestVersion)
+	case g.version > 0xffff:
+		// The version is the first field to show the byte order
+		return fmt.Errorf("big-endian GGUF files are not supported")
+	case g.version != GGUFVersion1 && g.version != GGUFVersion2:
+		return fmt.Errorf("unsupported GGUF version: %d", g.version)
+	}
+	logger.Debugf("Migrating GGUF v%d to v%d", g.version, GGUFLatestVersion)
+
+	tensorCount, err := g.readCount()
+	if err != nil {
+		return fmt.Errorf("failed to read tensor count: %w", err)
+	}
+	kvCount, err := g.readCount()
+	if err != nil {
+		return fmt.Errorf("failed to read metadata count: %w", err)
+	}
+	g.write(uint32(GGUFMagic), uint32(GGUFLatestVersion), tensorCount, kvCount)
+
+	for i := uint64(0); i < kvCount; i++ {
+		if err := g.copyKeyValue(); err != nil {
+			return err
+		}
+	}
+	for i := uint64(0); i < tensorCount; i++ {
+		if err := g.copyTensorInfo(); err != nil {
+			return err
+		}
+	}
+
+	// Skip the padding of the input and write that of the output
+	if _, err := io.CopyN(io.Discard, g.r, padding(g.r.n, g.alignment)); err != nil {
+		return fmt.Errorf("failed to read tensor data: %w", err)
+	}
+	g.write(make([]byte, padding(g.written, g.alignment)))
+
+	if _, err := io.Copy(g.w, g.r); err != nil {
+		return fmt.Errorf("failed to copy tensor data: %w", err)
+	}
+	return g.w.Flush()
+}
+
+// copyKeyValue copies a metadata key-value pair, noting the alignment of the
+// tensor data
+func (g *ggufMigrator) copyKeyValue() error {
+	key, err := g.copyString()
+	if err != nil {
+		return fmt.Errorf("failed to read metadata key: %w", err)
+	}
+
+	var valueType uint32
+	if err := binary.Read(g.r, binary.LittleEndian, &valueType); err != nil {
+		return fmt.Errorf("failed to read metadata value for key %s: %w", key, err)
+	}
+	g.write(valueType)
+
+	if key == "general.alignment" && valueType == GGUFTypeUint32 {
+		var alignment uint32
+		if err := binary.Read(g.r, binary.LittleEndian, &alignment); err != nil {
+			return fmt.Errorf("failed to read metadata value for key %s: %w", key, err)
+		}
+		if alignment == 0 || alignment&(alignment-1) != 0 {
+			return fmt.Errorf("invalid alignment: %d", alignment)
+		}
+		g.alignment = uint64(alignment)
+		g.write(alignment)
+		return nil
+	}
+
+	if err := g.copyValue(valueType); err != nil {
+		return fmt.Errorf("failed to read metadata value for key %s: %w", key, err)
+	}
+	return nil
+}
+
+// copyValue copies a metadata value of the given type
+func (g *ggufMigrator) copyValue(valueType uint32) error {
+	switch valueType {
+	case GGUFTypeString:
+		_, err := g.copyString()
+		return err
+	case GGUFTypeArray:
+		var elemType uint32
+		if err := binary.Read(g.r, binary.LittleEndian, &elemType); err != nil {
+			return err
+		}
+		length, err := g.readCount()
+		if err != nil {
+			return err
+		}
+		g.write(elemType, length)
+
+		// Arrays of fixed-size values, e.g. token scores, are copied at once
+		if size, ok := ggufValueSizes[elemType]; ok {
+			return g.copyBytes(int64(size) * int64(length))
+		}
+		for i := uint64(0); i < length; i++ {
+			if err := g.copyValue(elemType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	size, ok := ggufValueSizes[valueType]
+	if !ok {
+		return fmt.Errorf("unsupported value type: %d", valueType)
+	}
+	return g.copyBytes(int64(size))
+}
+
+// copyTensorInfo copies a tensor info entry. Version 1 stored dimensions as
+// 32-bit integers.
+func (g *ggufMigrator) copyTensorInfo() error {
+	name, err := g.copyString()
+	if err != nil {
+		return fmt.Errorf("failed to read tensor name: %w", err)
+	}
+
+	var nDims uint32
+	if err := binary.Read(g.r, binary.LittleEndian, &nDims); err != nil {
+		return fmt.Errorf("failed to read tensor %s: %w", name, err)
+	}
+	if nDims > 8 {
+		return fmt.Errorf("invalid dimension count of tensor %s: %d", name, nDims)
+	}
+	g.write(nDims)
+
+	for i := uint32(0); i < nDims; i++ {
+		dim, err := g.readCount()
+		if err != nil {
+			return fmt.Errorf("failed to read tensor %s: %w", name, err)
+		}
+		g.write(dim)
+	}
+
+	// Type and data offset
+	if err := g.copyBytes(4 + 8); err != nil {
+		return fmt.Errorf("failed to read tensor %s: %w", name, err)
+	}
+	return nil
+}
+
+// copyString copies a string, returning it
+func (g *ggufMigrator) copyString() (string, error) {
+	length, err := g.readCount()
+	if err != nil {
+		return "", err
+	}
+	if length > 1024*1024 {
+		return "", fmt.Errorf("string too long: %d bytes", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(g.r, data); err != nil {
+		return "", err
+	}
+	g.write(length, data)
+	return string(data), nil
+}
+
+// readCount reads a count or length, 32 bits wide in version 1
+func (g *ggufMigrator) readCount() (uint64, error) {
+	if g.version == GGUFVersion1 {
+		var count uint32
+		err := binary.Read(g.r, binary.LittleEndian, &count)
+		return uint64(count), err
+	}
+	var count uint64
+	err := binary.Read(g.r, binary.LittleEndian, &count)
+	return count, err
+}
+
+// copyBytes copies n bytes as they are
+func (g *ggufMigrator) copyBytes(n int64) error {
+	copied, err := io.CopyN(g.w, g.r, n)
+	g.written += copied
+	return err
+}
+
+// write writes values in little-endian order. Errors are returned by the
+// final flush of the buffered writer.
+func (g *ggufMigrator) write(values ...interface{}) {
+	for _, value := range values {
+		binary.Write(g.w, binary.LittleEndian, value)
+		g.written += int64(binary.Size(value))
+	}
+}
+
+// padding returns the number of bytes from offset to the next multiple of
+// alignment
+func padding(offset int64, alignment uint64) int64 {
+	return int64((alignment - uint64(offset)%alignment) % alignment)
+}
+
+// byteCounter counts the bytes read from r
+type byteCounter struct {
+	r io.Reader
+	n int64
+}
+
+func (b *byteCounter) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	return n, err
+}
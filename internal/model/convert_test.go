@@ -0,0 +1,117 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacyMetadata and legacyTensors are the contents of the GGUF fixtures
+// that are migrated
+var (
+	legacyMetadata = []ggufKV{
+		{"general.architecture", "llama"},
+		{"general.name", "tiny"},
+		{"llama.context_length", uint32(2048)},
+		{"tokenizer.ggml.tokens", []string{"<s>", "</s>", "hello"}},
+		{"general.file_type", uint32(1)},
+	}
+	legacyTensors = []ggufTensor{
+		{name: "token_embd.weight", shape: []uint64{4, 3}, typ: 1, data: bytes.Repeat([]byte{1, 2}, 12)},
+		{name: "output.weight", shape: []uint64{4}, typ: 0, data: bytes.Repeat([]byte{3, 4, 5, 6}, 4)},
+	}
+)
+
+func TestMigrateGGUFFile(t *testing.T) {
+	want := encodeGGUF(GGUFVersion3, legacyTensors, legacyMetadata...)
+
+	for _, version := range []uint32{GGUFVersion1, GGUFVersion2} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "old.gguf")
+			if err := os.WriteFile(input, encodeGGUF(version, legacyTensors, legacyMetadata...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			output := filepath.Join(dir, "new.gguf")
+			if err := MigrateGGUFFile(input, output); err != nil {
+				t.Fatalf("MigrateGGUFFile() error = %v", err)
+			}
+
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("migrated file differs from the v3 encoding:\ngot  %x\nwant %x", got, want)
+			}
+
+			info, err := ValidateModel(output)
+			if err != nil || !info.Valid {
+				t.Fatalf("ValidateModel() = %+v, %v, want a valid model", info, err)
+			}
+			if info.Version != "v3" || info.Architecture != "llama" || len(info.Tensors) != len(legacyTensors) {
+				t.Errorf("info = %+v, want a v3 llama model with %d tensors", info, len(legacyTensors))
+			}
+		})
+	}
+}
+
+func TestMigrateGGUFFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{name: "latest version", content: encodeGGUF(GGUFVersion3, nil, legacyMetadata...), wantErr: "already GGUF v3"},
+		{name: "not GGUF", content: []byte("not a model file"), wantErr: "not a GGUF file"},
+		{name: "unsupported version", content: encodeGGUF(7, nil), wantErr: "unsupported GGUF version"},
+		{name: "invalid alignment", content: encodeGGUF(GGUFVersion2, nil, ggufKV{"general.alignment", uint32(24)}), wantErr: "invalid alignment"},
+		{name: "truncated", content: encodeGGUF(GGUFVersion2, legacyTensors, legacyMetadata...)[:40], wantErr: "failed to migrate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "old.gguf")
+			if err := os.WriteFile(input, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			output := filepath.Join(dir, "new.gguf")
+			err := MigrateGGUFFile(input, output)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MigrateGGUFFile() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("output written despite the failed migration")
+			}
+		})
+	}
+}
+
+func TestManagerMigrateGGUF(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir)
+	if err := os.WriteFile(filepath.Join(dir, "tiny.gguf"), encodeGGUF(GGUFVersion2, legacyTensors, legacyMetadata...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := m.MigrateGGUF("tiny")
+	if err != nil {
+		t.Fatalf("MigrateGGUF() error = %v", err)
+	}
+	if want := filepath.Join(dir, "tiny-v3.gguf"); output != want {
+		t.Errorf("output = %s, want %s", output, want)
+	}
+	if info, err := ValidateModel(output); err != nil || info.Version != "v3" {
+		t.Errorf("ValidateModel() = %+v, %v, want a v3 model", info, err)
+	}
+
+	if _, err := m.MigrateGGUF("missing"); err == nil {
+		t.Error("MigrateGGUF() of a missing model succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// diskSpaceMargin is the fraction of a download's size required on top of it
+// for filesystem overhead
+const diskSpaceMargin = 0.05
+
+// ErrInsufficientDiskSpace is returned when a model does not fit on the disk
+// of the models directory
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// freeDiskSpace returns the bytes available to the user on the filesystem
+// holding a directory. It is a variable so that tests can replace it.
+var freeDiskSpace = statFreeDiskSpace
+
+// SetCheckDiskSpace enables or disables checking that a model fits on the disk
+// before downloading it
+func (m *Manager) SetCheckDiskSpace(check bool) {
+	m.checkDiskSpace = check
+}
+
+// FreeDiskSpace returns the bytes available to the user on the filesystem
+// that holds, or will hold, a directory
+func FreeDiskSpace(dir string) (uint64, error) {
+	// The directory may not have been created yet
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return freeDiskSpace(dir)
+}
+
+// ensureDiskSpace checks that a download of size bytes fits in the models
+// directory. Unknown sizes and free space are not checked.
+func (m *Manager) ensureDiskSpace(size int64) error {
+	if !m.checkDiskSpace || size <= 0 {
+		return nil
+	}
+
+	free, err := FreeDiskSpace(m.modelsPath)
+	if err != nil {
+		logger.Debugf("Cannot check free disk space in %s: %v", m.modelsPath, err)
+		return nil
+	}
+
+	need := uint64(float64(size) * (1 + diskSpaceMargin))
+	if free < need {
+		return fmt.Errorf("%w: need %s, have %s free", ErrInsufficientDiskSpace, formatGB(need), formatGB(free))
+	}
+	return nil
+}
+
+// formatGB formats a size in bytes in gigabytes
+func formatGB(size uint64) string {
+	return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+}
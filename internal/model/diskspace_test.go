@@ -0,0 +1,74 @@
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureDiskSpace(t *testing.T) {
+	const mb = 1024 * 1024
+	const gb = 1024 * mb
+
+	tests := []struct {
+		name    string
+		free    uint64
+		statErr error
+		size    int64
+		noCheck bool
+		wantErr string
+	}{
+		{name: "plenty of space", free: 100 * gb, size: 4 * gb},
+		{name: "fits with the margin", free: 4*gb + 205*mb, size: 4 * gb},
+		{name: "fits only without the margin", free: 4*gb + 100*mb, size: 4 * gb, wantErr: "insufficient disk space: need 4.2 GB, have 4.1 GB free"},
+		{name: "too little space", free: gb + 103*mb, size: 4 * gb, wantErr: "insufficient disk space: need 4.2 GB, have 1.1 GB free"},
+		{name: "unknown size", free: gb, size: 0},
+		{name: "unknown free space", statErr: errors.New("statfs failed"), size: 4 * gb},
+		{name: "check disabled", free: gb, size: 4 * gb, noCheck: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freeDiskSpace = func(dir string) (uint64, error) {
+				return tt.free, tt.statErr
+			}
+			defer func() { freeDiskSpace = statFreeDiskSpace }()
+
+			m := NewManager(t.TempDir())
+			m.SetCheckDiskSpace(!tt.noCheck)
+
+			err := m.ensureDiskSpace(tt.size)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ensureDiskSpace: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, ErrInsufficientDiskSpace) {
+				t.Errorf("error %v is not ErrInsufficientDiskSpace", err)
+			}
+		})
+	}
+}
+
+func TestFreeDiskSpaceOfMissingDirectory(t *testing.T) {
+	var statted string
+	freeDiskSpace = func(dir string) (uint64, error) {
+		statted = dir
+		return 1 << 40, nil
+	}
+	defer func() { freeDiskSpace = statFreeDiskSpace }()
+
+	// The space is that of the nearest existing parent
+	dir := t.TempDir()
+	free, err := FreeDiskSpace(filepath.Join(dir, "models", "org_repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free != 1<<40 || statted != dir {
+		t.Errorf("FreeDiskSpace() = %d of %s, want %d of %s", free, statted, uint64(1<<40), dir)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package model
+
+import "syscall"
+
+// statFreeDiskSpace returns the bytes available to unprivileged users on the
+// filesystem holding a directory
+func statFreeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
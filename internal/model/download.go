@@ -0,0 +1,400 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ParallelDownloadThreshold is the size from which files are downloaded
+	// in concurrent chunks
+	ParallelDownloadThreshold = 1 << 30
+
+	// maxParallelDownloads caps the default number of concurrent chunks
+	maxParallelDownloads = 8
+)
+
+// DefaultParallelDownloads returns the default number of chunks large files
+// are downloaded in: the number of CPUs, at most 8
+func DefaultParallelDownloads() int {
+	if n := runtime.NumCPU(); n < maxParallelDownloads {
+		return n
+	}
+	return maxParallelDownloads
+}
+
+// ParallelDownloader downloads files of at least Threshold bytes in Chunks
+// concurrent HTTP range requests, then joins the chunks in order. Smaller
+// files, and files from servers that do not advertise "Accept-Ranges: bytes",
+// are downloaded in a single stream.
+type ParallelDownloader struct {
+	Client    *http.Client
+	Chunks    int
+	Threshold int64
+
+	// Header is sent with every request, e.g. for authorization
+	Header http.Header
+
+	// CheckSize is called with the size of the file, when known, before it
+	// is downloaded, e.g. to check that it fits on the disk
+	CheckSize func(size int64) error
+}
+
+// NewParallelDownloader creates a downloader splitting large files into
+// chunks concurrent chunks
+func NewParallelDownloader(chunks int) *ParallelDownloader {
+	return &ParallelDownloader{
+		Client:    http.DefaultClient,
+		Chunks:    chunks,
+		Threshold: ParallelDownloadThreshold,
+		Header:    make(http.Header),
+	}
+}
+
+// Download downloads url to path, reporting the progress of all chunks
+// together to progressCallback as the download of modelName
+func (d *ParallelDownloader) Download(ctx context.Context, url, path, modelName string, progressCallback ProgressCallback) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size, ranges := d.probe(ctx, url)
+	if d.Chunks <= 1 || !ranges || size < d.Threshold {
+		return d.downloadStream(ctx, cancel, url, path, modelName, progressCallback)
+	}
+
+	if d.CheckSize != nil {
+		if err := d.CheckSize(size); err != nil {
+			return err
+		}
+	}
+	logger.Infof("Downloading %s in %d chunks", filepath.Base(path), d.Chunks)
+
+	progress := newProgressAggregator(d.Chunks, size, modelName, path, progressCallback, cancel)
+	go progress.run()
+	err := d.downloadChunks(ctx, url, path, size, progress)
+	return progress.finish(err)
+}
+
+// probe returns the size of the file at url and whether the server serves
+// ranges of it. Failures are not errors, the file is then downloaded in a
+// single stream.
+func (d *ParallelDownloader) probe(ctx context.Context, url string) (int64, bool) {
+	req, err := d.newRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return 0, false
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		logger.Debugf("Cannot probe %s for range requests: %v", url, err)
+		return 0, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	return resp.ContentLength, strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+}
+
+// downloadStream downloads url to path in a single request. cancel cancels
+// ctx, to stop the download when progressCallback fails.
+func (d *ParallelDownloader) downloadStream(ctx context.Context, cancel context.CancelFunc, url, path, modelName string, progressCallback ProgressCallback) error {
+	req, err := d.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download: %s", resp.Status)
+	}
+	if d.CheckSize != nil {
+		if err := d.CheckSize(resp.ContentLength); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	progress := newProgressAggregator(1, resp.ContentLength, modelName, path, progressCallback, cancel)
+	go progress.run()
+	_, err = io.Copy(out, progress.reader(0, resp.Body))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err = progress.finish(err); err != nil {
+		// A partial file is of no use, e.g. after the download was canceled
+		os.Remove(path)
+	}
+	return err
+}
+
+// downloadChunks downloads the file at url to path in d.Chunks range
+// requests run by a pool of workers, then joins the chunks in order
+func (d *ParallelDownloader) downloadChunks(ctx context.Context, url, path string, size int64, progress *progressAggregator) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunkSize := (size + int64(d.Chunks) - 1) / int64(d.Chunks)
+	chunkPaths := make([]string, d.Chunks)
+	for i := range chunkPaths {
+		chunkPaths[i] = fmt.Sprintf("%s.%d%s", path, i, partialDownloadExt)
+	}
+	defer func() {
+		for _, chunkPath := range chunkPaths {
+			os.Remove(chunkPath)
+		}
+	}()
+
+	jobs := make(chan int, d.Chunks)
+	for i := 0; i < d.Chunks; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for w := 0; w < d.Chunks; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := int64(i) * chunkSize
+				end := start + chunkSize - 1
+				if end >= size {
+					end = size - 1
+				}
+				if err := d.downloadChunk(ctx, url, chunkPaths[i], start, end, progress, i); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("failed to download chunk %d: %w", i+1, err)
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return joinChunks(path, chunkPaths)
+}
+
+// downloadChunk downloads bytes start to end, inclusive, of the file at url
+// to path, counting them in progress as chunk i
+func (d *ParallelDownloader) downloadChunk(ctx context.Context, url, path string, start, end int64, progress *progressAggregator, i int) error {
+	req, err := d.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request failed: %s", resp.Status)
+	}
+	if want := end - start + 1; resp.ContentLength != want {
+		return fmt.Errorf("range request returned %d bytes instead of %d", resp.ContentLength, want)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, progress.reader(i, resp.Body))
+	if err != nil {
+		return err
+	}
+	if written != resp.ContentLength {
+		return fmt.Errorf("chunk ended after %d of %d bytes", written, resp.ContentLength)
+	}
+	return out.Close()
+}
+
+// newRequest creates a request to url with the downloader's headers
+func (d *ParallelDownloader) newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range d.Header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// joinChunks writes the chunk files to path in order, removing each once it
+// is written so that the disk holds at most one chunk more than the file
+func joinChunks(path string, chunkPaths []string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, chunkPath := range chunkPaths {
+		chunk, err := os.Open(chunkPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, chunk)
+		chunk.Close()
+		if err != nil {
+			return fmt.Errorf("failed to join chunks: %w", err)
+		}
+		os.Remove(chunkPath)
+	}
+	return out.Close()
+}
+
+// progressAggregator sums the bytes downloaded by every chunk of a download
+// and reports them to a ProgressCallback once a second. The download is
+// cancelled when the callback fails.
+type progressAggregator struct {
+	counts    []int64
+	total     int64
+	modelName string
+	fileName  string
+	callback  ProgressCallback
+	cancel    context.CancelFunc
+	err       error
+	start     time.Time
+	done      chan struct{}
+	stopped   sync.WaitGroup
+}
+
+func newProgressAggregator(chunks int, total int64, modelName, fileName string, callback ProgressCallback, cancel context.CancelFunc) *progressAggregator {
+	p := &progressAggregator{
+		counts:    make([]int64, chunks),
+		total:     total,
+		modelName: modelName,
+		fileName:  fileName,
+		callback:  callback,
+		cancel:    cancel,
+		start:     time.Now(),
+		done:      make(chan struct{}),
+	}
+	p.stopped.Add(1)
+	return p
+}
+
+// reader returns a reader counting the bytes read from r as downloaded by
+// chunk i
+func (p *progressAggregator) reader(i int, r io.Reader) *countingReader {
+	return &countingReader{r: r, count: &p.counts[i]}
+}
+
+// downloaded returns the bytes downloaded by all chunks
+func (p *progressAggregator) downloaded() int64 {
+	var sum int64
+	for i := range p.counts {
+		sum += atomic.LoadInt64(&p.counts[i])
+	}
+	return sum
+}
+
+// run reports the progress every second until stop is called
+func (p *progressAggregator) run() {
+	defer p.stopped.Done()
+	if p.callback == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			downloaded := p.downloaded()
+			speed := int64(float64(downloaded) / time.Since(p.start).Seconds())
+
+			var eta time.Duration
+			var percentage float64
+			if speed > 0 && p.total > 0 {
+				eta = time.Duration(float64(p.total-downloaded)/float64(speed)) * time.Second
+				percentage = float64(downloaded) / float64(p.total) * 100
+			}
+			err := p.callback(DownloadProgress{
+				ModelName:  p.modelName,
+				FileName:   p.fileName,
+				Downloaded: downloaded,
+				Total:      p.total,
+				Speed:      speed,
+				ETA:        eta,
+				Status:     "downloading",
+				Percentage: percentage,
+			})
+			if err != nil {
+				p.err = err
+				p.cancel()
+				return
+			}
+		}
+	}
+}
+
+// finish stops reporting the progress of a download that ended with err and
+// reports it as completed if it succeeded
+func (p *progressAggregator) finish(err error) error {
+	close(p.done)
+	p.stopped.Wait()
+	if p.err != nil {
+		return fmt.Errorf("progress callback error: %w", p.err)
+	}
+	if err != nil {
+		return err
+	}
+	if p.callback == nil {
+		return nil
+	}
+
+	downloaded := p.downloaded()
+	return p.callback(DownloadProgress{
+		ModelName:  p.modelName,
+		FileName:   p.fileName,
+		Downloaded: downloaded,
+		Total:      downloaded,
+		Status:     "completed",
+		Percentage: 100,
+	})
+}
+
+// countingReader adds the number of bytes read from r to count
+type countingReader struct {
+	r     io.Reader
+	count *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.count, int64(n))
+	return n, err
+}
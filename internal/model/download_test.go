@@ -0,0 +1,207 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testContent returns size bytes of content that differ from chunk to chunk
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+// rangeServer serves content with range support unless ranges is false, and
+// records the Range header of every GET request
+type rangeServer struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	ranges []string
+}
+
+func newRangeServer(t *testing.T, content []byte, ranges bool) *rangeServer {
+	t.Helper()
+	s := &rangeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			s.mutex.Lock()
+			s.ranges = append(s.ranges, r.Header.Get("Range"))
+			s.mutex.Unlock()
+		}
+		if !ranges {
+			r.Header.Del("Range")
+			http.ServeContent(noRangesWriter{w}, r, "", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// noRangesWriter hides the Accept-Ranges header set by http.ServeContent
+type noRangesWriter struct {
+	http.ResponseWriter
+}
+
+func (w noRangesWriter) WriteHeader(status int) {
+	w.Header().Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func newTestDownloader(chunks int) *ParallelDownloader {
+	d := NewParallelDownloader(chunks)
+	d.Threshold = 1
+	d.Header.Set("Authorization", "Bearer secret")
+	return d
+}
+
+func TestParallelDownload(t *testing.T) {
+	content := testContent(100*1024 + 3)
+
+	tests := []struct {
+		name       string
+		ranges     bool
+		chunks     int
+		threshold  int64
+		wantRanges []string
+	}{
+		{
+			name:   "chunks",
+			ranges: true,
+			chunks: 4,
+			wantRanges: []string{
+				"bytes=0-25600", "bytes=25601-51201", "bytes=51202-76802", "bytes=76803-102402",
+			},
+		},
+		{name: "server without ranges", ranges: false, chunks: 4, wantRanges: []string{""}},
+		{name: "small file", ranges: true, chunks: 4, threshold: 1 << 30, wantRanges: []string{""}},
+		{name: "one chunk", ranges: true, chunks: 1, wantRanges: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRangeServer(t, content, tt.ranges)
+			d := newTestDownloader(tt.chunks)
+			if tt.threshold > 0 {
+				d.Threshold = tt.threshold
+			}
+
+			var last DownloadProgress
+			path := filepath.Join(t.TempDir(), "model.gguf")
+			err := d.Download(context.Background(), srv.URL, path, "llama3", func(p DownloadProgress) error {
+				last = p
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded %d bytes that differ from the %d served", len(got), len(content))
+			}
+			if last.Status != "completed" || last.Downloaded != int64(len(content)) {
+				t.Errorf("last progress = %s with %d bytes, want completed with %d", last.Status, last.Downloaded, len(content))
+			}
+
+			srv.mutex.Lock()
+			defer srv.mutex.Unlock()
+			if !sameElements(srv.ranges, tt.wantRanges) {
+				t.Errorf("requested ranges %q, want %q", srv.ranges, tt.wantRanges)
+			}
+
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if len(entries) != 1 {
+				t.Errorf("download left %d files, want only the model", len(entries))
+			}
+		})
+	}
+}
+
+func TestParallelDownloadFailedChunk(t *testing.T) {
+	content := testContent(64 * 1024)
+
+	// The third chunk cannot be downloaded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=32768-") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	d := NewParallelDownloader(4)
+	d.Threshold = 1
+	err := d.Download(context.Background(), srv.URL, filepath.Join(dir, "model.gguf"), "llama3", nil)
+	if err == nil || !strings.Contains(err.Error(), "chunk 3") {
+		t.Errorf("error = %v, want chunk 3 to fail", err)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed download left %d files", len(entries))
+	}
+}
+
+func TestParallelDownloadCheckSize(t *testing.T) {
+	content := testContent(4096)
+	srv := newRangeServer(t, content, true)
+
+	errTooBig := errors.New("too big")
+	d := newTestDownloader(4)
+	var checked int64
+	d.CheckSize = func(size int64) error {
+		checked = size
+		return errTooBig
+	}
+
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := d.Download(context.Background(), srv.URL, path, "llama3", nil); !errors.Is(err, errTooBig) {
+		t.Errorf("error = %v, want %v", err, errTooBig)
+	}
+	if checked != int64(len(content)) {
+		t.Errorf("checked size %d, want %d", checked, len(content))
+	}
+	if len(srv.ranges) != 0 {
+		t.Errorf("downloaded %q after the size check failed", srv.ranges)
+	}
+}
+
+// sameElements reports whether a and b hold the same strings in any order
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+		if counts[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
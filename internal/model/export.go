@@ -0,0 +1,569 @@
+package model
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// colossusfileName is the name of the file describing an exported model,
+	// which comes first in the archive
+	colossusfileName = "Colossusfile"
+
+	// exportOptionsName is the name of the model's options file in an archive
+	exportOptionsName = "model.yaml"
+
+	// exportFormatVersion is the version of the archive layout written by
+	// ExportModel. Archives of a newer version are not imported.
+	exportFormatVersion = 1
+
+	// maxExportMetadataSize limits the size of the metadata files read from
+	// an archive into memory
+	maxExportMetadataSize = 1 << 20
+)
+
+// Colossusfile describes an exported model: the version of the archive and of
+// Colossus that wrote it, the model and every file of the archive with its
+// SHA256 digest and size. It is written as one directive per line:
+//
+//	FORMAT 1
+//	COLOSSUS v1.2.0
+//	MODEL llama3:latest
+//	CREATED 2024-05-01T12:00:00Z
+//	FILE manifest.json sha256:... 412
+//	FILE llama3.gguf sha256:... 4920734176
+type Colossusfile struct {
+	Format   int
+	Colossus string
+	Model    string
+	Created  time.Time
+	Files    []ExportedFile
+}
+
+// ExportedFile is a file of an exported model archive
+type ExportedFile struct {
+	Name   string
+	Digest string
+	Size   int64
+}
+
+// String formats the Colossusfile as it is written to an archive
+func (f *Colossusfile) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FORMAT %d\n", f.Format)
+	if f.Colossus != "" {
+		fmt.Fprintf(&b, "COLOSSUS %s\n", f.Colossus)
+	}
+	fmt.Fprintf(&b, "MODEL %s\n", f.Model)
+	fmt.Fprintf(&b, "CREATED %s\n", f.Created.UTC().Format(time.RFC3339))
+	for _, file := range f.Files {
+		fmt.Fprintf(&b, "FILE %s %s %d\n", file.Name, file.Digest, file.Size)
+	}
+	return b.String()
+}
+
+// ParseColossusfile parses the Colossusfile of an exported model. Lines
+// starting with # are comments.
+func ParseColossusfile(data []byte) (*Colossusfile, error) {
+	f := &Colossusfile{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		directive, args, _ := strings.Cut(text, " ")
+
+		var err error
+		switch directive {
+		case "FORMAT":
+			f.Format, err = strconv.Atoi(args)
+		case "COLOSSUS":
+			f.Colossus = args
+		case "MODEL":
+			f.Model = args
+		case "CREATED":
+			f.Created, err = time.Parse(time.RFC3339, args)
+		case "FILE":
+			// File names may contain spaces, digests and sizes do not
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				err = fmt.Errorf("expected a name, digest and size")
+				break
+			}
+			n := len(fields)
+			file := ExportedFile{
+				Name:   strings.Join(fields[:n-2], " "),
+				Digest: fields[n-2],
+			}
+			file.Size, err = strconv.ParseInt(fields[n-1], 10, 64)
+			f.Files = append(f.Files, file)
+		default:
+			err = fmt.Errorf("unknown directive %s", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Format == 0 || f.Model == "" {
+		return nil, fmt.Errorf("missing FORMAT or MODEL")
+	}
+	return f, nil
+}
+
+// file returns the archive file with the given name
+func (f *Colossusfile) file(name string) (ExportedFile, bool) {
+	for _, file := range f.Files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+	return ExportedFile{}, false
+}
+
+// ExportModel writes a model to a gzipped tar that ImportModel installs on
+// another machine. The archive holds a Colossusfile, the model's manifest
+// entry, its options file if it has one and the model files.
+func (m *Manager) ExportModel(modelName, outputPath string) error {
+	modelPath, err := m.findModelVariant(modelName)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := m.exportManifest(modelName, modelPath)
+	if err != nil {
+		return err
+	}
+	var ref string
+	var entry *ManifestEntry
+	for tag, e := range manifest.Tags {
+		ref, entry = manifest.Name+":"+tag, e
+	}
+
+	// Split models are exported with all their parts
+	paths := []string{modelPath}
+	if _, _, _, ok := ParseSplitName(filepath.Base(modelPath)); ok {
+		if paths, err = FindSplitParts(modelPath); err != nil {
+			return err
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	optionsData, err := os.ReadFile(ModelOptionsPath(modelPath))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read model options: %w", err)
+	}
+
+	colossusfile := &Colossusfile{
+		Format:   exportFormatVersion,
+		Colossus: colossusVersion(),
+		Model:    ref,
+		Created:  time.Now(),
+	}
+	colossusfile.Files = append(colossusfile.Files, exportedBytes(manifestFileName, manifestData))
+	if optionsData != nil {
+		colossusfile.Files = append(colossusfile.Files, exportedBytes(exportOptionsName, optionsData))
+	}
+	for _, p := range paths {
+		digest, err := FileDigest(p)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if p == modelPath && digest != entry.Digest {
+			return fmt.Errorf("model file %s does not match the digest %s of %s", p, entry.Digest, ref)
+		}
+		colossusfile.Files = append(colossusfile.Files, ExportedFile{Name: filepath.Base(p), Digest: digest, Size: info.Size()})
+	}
+
+	tmpPath := outputPath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	err = writeExportArchive(out, colossusfile, manifestData, optionsData, paths)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, outputPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	logger.Infof("Exported model %s to %s", ref, outputPath)
+	return nil
+}
+
+// exportManifest returns a manifest with the tag of the model file being
+// exported. Models installed without being pulled, e.g. copied into the
+// models directory, get an entry made from the file.
+func (m *Manager) exportManifest(modelName, modelPath string) (*Manifest, error) {
+	tagged, err := m.taggedModels()
+	if err != nil {
+		return nil, err
+	}
+	name, tag := ParseModelTag(modelName)
+	if tags := tagged[modelPath]; len(tags) > 0 {
+		// The tag asked for, or else the first tag of the file
+		t := tags[0]
+		for _, other := range tags {
+			if other.Ref == name+":"+tag {
+				t = other
+			}
+		}
+		name, tag = ParseModelTag(t.Ref)
+		return &Manifest{Name: name, Tags: map[string]*ManifestEntry{tag: t.Entry}}, nil
+	}
+
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := FileDigest(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(m.modelsPath, modelPath)
+	if err != nil {
+		return nil, err
+	}
+	entry := &ManifestEntry{
+		Digest:   digest,
+		Size:     info.Size(),
+		File:     filepath.ToSlash(rel),
+		PulledAt: info.ModTime(),
+	}
+	if modelInfo, err := ValidateModel(modelPath); err == nil {
+		entry.Quantization = modelInfo.Quantization
+	}
+	return &Manifest{Name: name, Tags: map[string]*ManifestEntry{tag: entry}}, nil
+}
+
+// writeExportArchive writes the files of an exported model as a gzipped tar,
+// with the metadata before the model files so that it can be checked first
+func writeExportArchive(w io.Writer, colossusfile *Colossusfile, manifestData, optionsData []byte, paths []string) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	addBytes := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: colossusfile.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := addBytes(colossusfileName, []byte(colossusfile.String())); err != nil {
+		return err
+	}
+	if err := addBytes(manifestFileName, manifestData); err != nil {
+		return err
+	}
+	if optionsData != nil {
+		if err := addBytes(exportOptionsName, optionsData); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		header := &tar.Header{Name: filepath.Base(p), Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+		if err := tw.WriteHeader(header); err != nil {
+			file.Close()
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ImportModel installs a model exported by ExportModel and returns its
+// "name:tag" reference. Every file is checked against the SHA256 digest in
+// the Colossusfile, and the model is only installed once all of them match.
+// Models that are already installed are not overwritten.
+func (m *Manager) ImportModel(archivePath string) (string, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	// The Colossusfile and the manifest come first
+	colossusfileData, err := readArchiveFile(tr, colossusfileName)
+	if err != nil {
+		return "", err
+	}
+	colossusfile, err := ParseColossusfile(colossusfileData)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", colossusfileName, err)
+	}
+	if colossusfile.Format > exportFormatVersion {
+		return "", fmt.Errorf("archive format %d is not supported, upgrade Colossus to import it", colossusfile.Format)
+	}
+
+	manifestData, err := readArchiveFile(tr, manifestFileName)
+	if err != nil {
+		return "", err
+	}
+	if err := checkExportedFile(colossusfile, manifestFileName, manifestData); err != nil {
+		return "", err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", manifestFileName, err)
+	}
+	if len(manifest.Tags) != 1 {
+		return "", fmt.Errorf("invalid %s: expected one tag, got %d", manifestFileName, len(manifest.Tags))
+	}
+	var tag string
+	var entry *ManifestEntry
+	for t, e := range manifest.Tags {
+		tag, entry = t, e
+	}
+	ref := manifest.Name + ":" + tag
+
+	// The model is installed where it was on the exporting machine
+	if !filepath.IsLocal(filepath.FromSlash(entry.File)) || !IsValidModelFormat(entry.File) {
+		return "", fmt.Errorf("invalid model file in %s: %q", manifestFileName, entry.File)
+	}
+	existing, err := m.loadManifest(manifest.Name)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := existing.Tags[tag]; ok {
+		return "", fmt.Errorf("model already exists: %s", ref)
+	}
+	modelPath := filepath.Join(m.modelsPath, filepath.FromSlash(entry.File))
+	dir := filepath.Dir(modelPath)
+
+	var size int64
+	for _, file := range colossusfile.Files {
+		size += file.Size
+	}
+	if err := m.ensureDiskSpace(size); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Files are extracted to .part files, renamed once all of them are verified
+	var optionsData []byte
+	var extracted []string
+	removeParts := func() {
+		for _, p := range extracted {
+			os.Remove(p + partialDownloadExt)
+		}
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			removeParts()
+			return "", fmt.Errorf("failed to read archive: %w", err)
+		}
+		expected, ok := colossusfile.file(header.Name)
+		if !ok || header.Typeflag != tar.TypeReg || header.Name != filepath.Base(header.Name) {
+			removeParts()
+			return "", fmt.Errorf("unexpected file in archive: %q", header.Name)
+		}
+
+		if header.Name == exportOptionsName {
+			if optionsData, err = readArchiveData(tr, header); err == nil {
+				err = checkExportedFile(colossusfile, exportOptionsName, optionsData)
+			}
+			if err != nil {
+				removeParts()
+				return "", err
+			}
+			continue
+		}
+
+		if !IsValidModelFormat(header.Name) {
+			removeParts()
+			return "", fmt.Errorf("unexpected file in archive: %q", header.Name)
+		}
+		target := filepath.Join(dir, header.Name)
+		if _, err := os.Stat(target); err == nil {
+			removeParts()
+			return "", fmt.Errorf("model file already exists: %s", target)
+		}
+		extracted = append(extracted, target)
+		if err := extractVerified(tr, target+partialDownloadExt, expected); err != nil {
+			removeParts()
+			return "", err
+		}
+	}
+
+	// Every listed file must have been in the archive
+	found := map[string]bool{colossusfileName: true, manifestFileName: true, exportOptionsName: optionsData != nil}
+	for _, target := range extracted {
+		found[filepath.Base(target)] = true
+	}
+	for _, file := range colossusfile.Files {
+		if !found[file.Name] {
+			removeParts()
+			return "", fmt.Errorf("archive is missing %s", file.Name)
+		}
+	}
+	if !found[filepath.Base(modelPath)] {
+		removeParts()
+		return "", fmt.Errorf("archive is missing %s", filepath.Base(modelPath))
+	}
+	if expected, _ := colossusfile.file(filepath.Base(modelPath)); expected.Digest != entry.Digest {
+		removeParts()
+		return "", fmt.Errorf("model file does not match the digest %s of %s", entry.Digest, ref)
+	}
+
+	for _, target := range extracted {
+		if err := os.Rename(target+partialDownloadExt, target); err != nil {
+			removeParts()
+			return "", err
+		}
+		m.invalidateModelCache(target)
+	}
+	if optionsData != nil {
+		if err := os.WriteFile(ModelOptionsPath(modelPath), optionsData, 0644); err != nil {
+			return "", fmt.Errorf("failed to write model options: %w", err)
+		}
+	}
+
+	existing.Tags[tag] = entry
+	if err := m.saveManifest(existing); err != nil {
+		return "", err
+	}
+	logger.Infof("Imported model %s from %s", ref, archivePath)
+	return ref, nil
+}
+
+// readArchiveFile reads the next file of an archive, which must have the
+// given name
+func readArchiveFile(tr *tar.Reader, name string) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("not a model archive: expected %s, found %q", name, header.Name)
+	}
+	return readArchiveData(tr, header)
+}
+
+// readArchiveData reads a small metadata file of an archive into memory
+func readArchiveData(tr *tar.Reader, header *tar.Header) ([]byte, error) {
+	if header.Size > maxExportMetadataSize {
+		return nil, fmt.Errorf("%s is too large (%d bytes)", header.Name, header.Size)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+	}
+	return data, nil
+}
+
+// extractVerified writes a file of an archive to path, checking its size and
+// SHA256 digest against the Colossusfile
+func extractVerified(r io.Reader, path string, expected ExportedFile) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, hasher), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", expected.Name, err)
+	}
+
+	if n != expected.Size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", expected.Name, expected.Size, n)
+	}
+	if digest := "sha256:" + hex.EncodeToString(hasher.Sum(nil)); digest != expected.Digest {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", expected.Name, expected.Digest, digest)
+	}
+	return nil
+}
+
+// checkExportedFile checks the contents of a metadata file against the
+// Colossusfile
+func checkExportedFile(colossusfile *Colossusfile, name string, data []byte) error {
+	expected, ok := colossusfile.file(name)
+	if !ok {
+		return fmt.Errorf("%s is not listed in the %s", name, colossusfileName)
+	}
+	if actual := exportedBytes(name, data); actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected.Digest, actual.Digest)
+	}
+	return nil
+}
+
+// exportedBytes describes a metadata file of an archive
+func exportedBytes(name string, data []byte) ExportedFile {
+	sum := sha256.Sum256(data)
+	return ExportedFile{Name: name, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+}
+
+// colossusVersion returns the version of the running binary, if known
+func colossusVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Version
+}
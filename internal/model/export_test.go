@@ -0,0 +1,220 @@
+package model
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pullTestModel installs a model file as if it had been pulled as name:tag,
+// with an options file
+func pullTestModel(t *testing.T, m *Manager, name, tag, file string) string {
+	t.Helper()
+	path := writeModel(t, m.modelsPath, file, string(ggufFile(ggufKV{"general.file_type", uint32(15)}))+strings.Repeat("weights", 1000))
+	if err := os.WriteFile(ModelOptionsPath(path), []byte("context_size: 8192\ngpu_layers: 20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.recordPull(name, tag, path, "hf://TheBloke/Llama-2-7B-GGUF/"+filepath.Base(file), ""); err != nil {
+		t.Fatalf("recordPull: %v", err)
+	}
+	return path
+}
+
+// rewriteArchive copies an exported archive, passing the contents of each of
+// its files through modify
+func rewriteArchive(t *testing.T, src, dst string, modify func(name string, data []byte) []byte) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	gzr, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = modify(header.Name, data)
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := NewManager(filepath.Join(t.TempDir(), "models"))
+	srcPath := pullTestModel(t, src, "llama2", "q4_k_m", "TheBloke_Llama-2-7B-GGUF/llama-2-7b.Q4_K_M.gguf")
+
+	archive := filepath.Join(t.TempDir(), "llama2.tar.gz")
+	if err := src.ExportModel("llama2:q4_k_m", archive); err != nil {
+		t.Fatalf("ExportModel: %v", err)
+	}
+
+	dst := NewManager(filepath.Join(t.TempDir(), "models"))
+	ref, err := dst.ImportModel(archive)
+	if err != nil {
+		t.Fatalf("ImportModel: %v", err)
+	}
+	if ref != "llama2:q4_k_m" {
+		t.Errorf("imported %s, want llama2:q4_k_m", ref)
+	}
+
+	// The manifest entry is preserved, including where the model was pulled from
+	srcManifest, err := src.loadManifest("llama2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstManifest, err := dst.loadManifest("llama2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dstManifest.Tags, srcManifest.Tags) {
+		t.Errorf("imported manifest %+v, want %+v", dstManifest.Tags["q4_k_m"], srcManifest.Tags["q4_k_m"])
+	}
+	if entry := dstManifest.Tags["q4_k_m"]; entry.Quantization != "Q4_K_M" || !strings.HasPrefix(entry.Source, "hf://") {
+		t.Errorf("imported entry lost its metadata: %+v", entry)
+	}
+
+	// The model and its options are installed at the same place
+	dstPath, err := dst.GetModelPath("llama2:q4_k_m")
+	if err != nil {
+		t.Fatalf("imported model not found: %v", err)
+	}
+	if rel, _ := filepath.Rel(dst.modelsPath, dstPath); rel != filepath.FromSlash("TheBloke_Llama-2-7B-GGUF/llama-2-7b.Q4_K_M.gguf") {
+		t.Errorf("model imported to %s", rel)
+	}
+	for _, pair := range [][2]string{{srcPath, dstPath}, {ModelOptionsPath(srcPath), ModelOptionsPath(dstPath)}} {
+		want, _ := os.ReadFile(pair[0])
+		got, err := os.ReadFile(pair[1])
+		if err != nil || string(got) != string(want) {
+			t.Errorf("%s differs from the exported file: %v", pair[1], err)
+		}
+	}
+
+	// A model is not imported over itself
+	if _, err := dst.ImportModel(archive); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second import error = %v, want the model to exist", err)
+	}
+}
+
+func TestImportRejectsTamperedArchive(t *testing.T) {
+	src := NewManager(filepath.Join(t.TempDir(), "models"))
+	pullTestModel(t, src, "llama2", "latest", "llama2.gguf")
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "llama2.tar.gz")
+	if err := src.ExportModel("llama2", archive); err != nil {
+		t.Fatalf("ExportModel: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(name string, data []byte) []byte
+		wantErr string
+	}{
+		{
+			name: "model file",
+			modify: func(name string, data []byte) []byte {
+				if name == "llama2.gguf" {
+					data[len(data)-1] ^= 0xff
+				}
+				return data
+			},
+			wantErr: "llama2.gguf",
+		},
+		{
+			name: "options file",
+			modify: func(name string, data []byte) []byte {
+				if name == exportOptionsName {
+					return []byte("gpu_layers: 99\n")
+				}
+				return data
+			},
+			wantErr: exportOptionsName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".tar.gz")
+			rewriteArchive(t, archive, tampered, tt.modify)
+
+			dst := NewManager(filepath.Join(t.TempDir(), "models"))
+			_, err := dst.ImportModel(tampered)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ImportModel error = %v, want it to name %s", err, tt.wantErr)
+			}
+
+			// Nothing is installed
+			if _, err := dst.GetModelPath("llama2"); err == nil {
+				t.Error("tampered model installed")
+			}
+			incomplete, _ := dst.FindIncompleteDownloads()
+			if len(incomplete) != 0 {
+				t.Errorf("partial files left behind: %v", incomplete)
+			}
+		})
+	}
+}
+
+func TestColossusfileRoundTrip(t *testing.T) {
+	want := &Colossusfile{
+		Format:   exportFormatVersion,
+		Colossus: "v1.2.0",
+		Model:    "llama3:latest",
+		Created:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Files: []ExportedFile{
+			{Name: "manifest.json", Digest: "sha256:0123", Size: 412},
+			{Name: "my model.gguf", Digest: "sha256:4567", Size: 4920734176},
+		},
+	}
+
+	got, err := ParseColossusfile([]byte("# exported model\n" + want.String()))
+	if err != nil {
+		t.Fatalf("ParseColossusfile: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseColossusfile = %+v, want %+v", got, want)
+	}
+
+	for _, invalid := range []string{"", "FORMAT 1\n", "FORMAT one\nMODEL llama3\n", "FORMAT 1\nMODEL llama3\nFILE llama3.gguf\n", "FORMAT 1\nMODEL llama3\nLICENSE MIT\n"} {
+		if _, err := ParseColossusfile([]byte(invalid)); err == nil {
+			t.Errorf("ParseColossusfile(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// accessLogFileName records when each model file was last loaded. It is
+	// kept next to the models directory rather than relying on file access
+	// times, which are not updated on filesystems mounted with noatime.
+	accessLogFileName = "model-access.json"
+
+	// pinnedModelsFileName lists the models garbage collection keeps, one
+	// model name or path per line
+	pinnedModelsFileName = "pinned-models.txt"
+)
+
+// accessLogMutex serializes updates of the access log within the process
+var accessLogMutex sync.Mutex
+
+// UnusedModel is a model not loaded or pulled for a while, which garbage
+// collection removes. Files holds every part of a split model.
+type UnusedModel struct {
+	Path         string
+	Files        []string
+	Size         int64
+	LastAccessed time.Time
+}
+
+// accessLogPath returns the path of the access log, e.g. ~/.colossus/model-access.json
+func (m *Manager) accessLogPath() string {
+	return filepath.Join(filepath.Dir(m.modelsPath), accessLogFileName)
+}
+
+// pinnedModelsPath returns the path of the pinned models file, e.g.
+// ~/.colossus/pinned-models.txt
+func (m *Manager) pinnedModelsPath() string {
+	return filepath.Join(filepath.Dir(m.modelsPath), pinnedModelsFileName)
+}
+
+// loadAccessLog reads the access log, mapping model file paths relative to
+// the models directory to when they were last loaded. A missing or invalid
+// log is treated as empty.
+func (m *Manager) loadAccessLog() map[string]time.Time {
+	accessLog := make(map[string]time.Time)
+
+	data, err := os.ReadFile(m.accessLogPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Debugf("Ignoring model access log: %v", err)
+		}
+		return accessLog
+	}
+	if err := json.Unmarshal(data, &accessLog); err != nil {
+		logger.Debugf("Ignoring invalid model access log: %v", err)
+		return make(map[string]time.Time)
+	}
+	return accessLog
+}
+
+// RecordAccess records that the model file at path was loaded, so that
+// garbage collection keeps it. Files outside the models directory are ignored.
+func (m *Manager) RecordAccess(path string) error {
+	rel, err := filepath.Rel(m.modelsPath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+
+	accessLogMutex.Lock()
+	defer accessLogMutex.Unlock()
+
+	accessLog := m.loadAccessLog()
+	accessLog[filepath.ToSlash(rel)] = time.Now()
+
+	data, err := json.MarshalIndent(accessLog, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.accessLogPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.accessLogPath())
+}
+
+// lastAccessed returns when the model file at path was last loaded, or when
+// it was pulled if that was later
+func (m *Manager) lastAccessed(accessLog map[string]time.Time, path string, info os.FileInfo) time.Time {
+	last := info.ModTime()
+	if rel, err := filepath.Rel(m.modelsPath, path); err == nil {
+		if accessed, ok := accessLog[filepath.ToSlash(rel)]; ok && accessed.After(last) {
+			last = accessed
+		}
+	}
+	return last
+}
+
+// pinnedModels returns the paths of the model files listed in the pinned
+// models file. Lines are model names or paths; blank lines and lines
+// starting with "#" are ignored, as are models that are not installed.
+func (m *Manager) pinnedModels() (map[string]bool, error) {
+	pinned := make(map[string]bool)
+
+	file, err := os.Open(m.pinnedModelsPath())
+	if os.IsNotExist(err) {
+		return pinned, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned models: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		path, err := m.findModelVariant(line)
+		if err != nil {
+			if _, statErr := os.Stat(line); statErr != nil {
+				logger.Debugf("Ignoring pinned model %s: %v", line, err)
+				continue
+			}
+			path = line
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		pinned[path] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pinned models: %w", err)
+	}
+	return pinned, nil
+}
+
+// FindUnusedModels returns the models in the models directory that were
+// neither loaded nor pulled in the last keepDays days, least recently used
+// first. Pinned models are left out. The parts of a split model are kept
+// together, as one model used when any part was.
+func (m *Manager) FindUnusedModels(keepDays int) ([]UnusedModel, error) {
+	if keepDays < 0 {
+		return nil, fmt.Errorf("invalid number of days: %d", keepDays)
+	}
+	pinned, err := m.pinnedModels()
+	if err != nil {
+		return nil, err
+	}
+	accessLog := m.loadAccessLog()
+
+	// Split parts are grouped under the path of their first part
+	models := make(map[string]*UnusedModel)
+	err = m.walkModelFiles(func(path string, info os.FileInfo) {
+		if !IsValidModelFormat(info.Name()) {
+			return
+		}
+		key := path
+		if base, _, count, ok := ParseSplitName(info.Name()); ok {
+			key = SplitPartPath(filepath.Join(filepath.Dir(path), base), 1, count)
+		}
+
+		model, ok := models[key]
+		if !ok {
+			model = &UnusedModel{Path: key}
+			models[key] = model
+		}
+		model.Files = append(model.Files, path)
+		model.Size += info.Size()
+		if last := m.lastAccessed(accessLog, path, info); last.After(model.LastAccessed) {
+			model.LastAccessed = last
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan models: %w", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+	var unused []UnusedModel
+	for key, model := range models {
+		abs, err := filepath.Abs(key)
+		if err != nil {
+			abs = key
+		}
+		if pinned[abs] || !model.LastAccessed.Before(cutoff) {
+			continue
+		}
+		sort.Strings(model.Files)
+		unused = append(unused, *model)
+	}
+
+	sort.Slice(unused, func(i, j int) bool {
+		if !unused[i].LastAccessed.Equal(unused[j].LastAccessed) {
+			return unused[i].LastAccessed.Before(unused[j].LastAccessed)
+		}
+		return unused[i].Path < unused[j].Path
+	})
+	return unused, nil
+}
+
+// GarbageCollect removes the models FindUnusedModels returns for keepDays,
+// with their manifest tags, and returns the paths of the removed files and
+// the number of bytes freed
+func (m *Manager) GarbageCollect(keepDays int) ([]string, int64, error) {
+	unused, err := m.FindUnusedModels(keepDays)
+	if err != nil {
+		return nil, 0, err
+	}
+	tagged, err := m.taggedModels()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var removed []string
+	var freed int64
+	for _, model := range unused {
+		for _, path := range model.Files {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if err := m.RemoveModelFile(path); err != nil {
+				return removed, freed, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			removed = append(removed, path)
+			freed += info.Size()
+
+			for _, t := range tagged[path] {
+				name, _ := ParseModelTag(t.Ref)
+				m.forgetModelFile(name, path)
+			}
+		}
+	}
+	return removed, freed, nil
+}
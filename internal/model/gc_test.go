@@ -0,0 +1,112 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeAgedModel writes a model file last modified days ago
+func writeAgedModel(t *testing.T, dir, name string, days int) string {
+	t.Helper()
+	path := writeGGUF(t, dir, name)
+	modified := time.Now().AddDate(0, 0, -days)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unusedPaths returns the paths of unused models
+func unusedPaths(unused []UnusedModel) []string {
+	var paths []string
+	for _, model := range unused {
+		paths = append(paths, model.Path)
+	}
+	return paths
+}
+
+func TestFindUnusedModels(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	oldest := writeAgedModel(t, m.modelsPath, "oldest.gguf", 90)
+	old := writeAgedModel(t, m.modelsPath, "old.gguf", 60)
+	writeAgedModel(t, m.modelsPath, "recent.gguf", 1)
+	loaded := writeAgedModel(t, m.modelsPath, "loaded.gguf", 60)
+	writeAgedModel(t, m.modelsPath, "pinned.gguf", 60)
+
+	// A split model is used when any of its parts was
+	split := SplitPartPath(filepath.Join(m.modelsPath, "split"), 1, 2)
+	writeAgedModel(t, m.modelsPath, filepath.Base(split), 60)
+	writeAgedModel(t, m.modelsPath, filepath.Base(SplitPartPath(filepath.Join(m.modelsPath, "split"), 2, 2)), 60)
+	usedSplit := writeAgedModel(t, m.modelsPath, filepath.Base(SplitPartPath(filepath.Join(m.modelsPath, "used"), 1, 2)), 60)
+	writeAgedModel(t, m.modelsPath, filepath.Base(SplitPartPath(filepath.Join(m.modelsPath, "used"), 2, 2)), 1)
+
+	if err := m.RecordAccess(loaded); err != nil {
+		t.Fatalf("RecordAccess: %v", err)
+	}
+	if err := os.WriteFile(m.pinnedModelsPath(), []byte("# kept for the demo\n\npinned\nmissing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	unused, err := m.FindUnusedModels(30)
+	if err != nil {
+		t.Fatalf("FindUnusedModels: %v", err)
+	}
+	if want := []string{oldest, old, split}; !reflect.DeepEqual(unusedPaths(unused), want) {
+		t.Errorf("unused = %v, want %v", unusedPaths(unused), want)
+	}
+	if len(unused) == 3 && len(unused[2].Files) != 2 {
+		t.Errorf("split model files = %v, want both parts", unused[2].Files)
+	}
+	for _, model := range unused {
+		if model.Path == usedSplit {
+			t.Errorf("split model with a recent part is unused")
+		}
+	}
+
+	if unused, _ := m.FindUnusedModels(120); len(unused) != 0 {
+		t.Errorf("unused for 120 days = %v, want none", unusedPaths(unused))
+	}
+	if _, err := m.FindUnusedModels(-1); err == nil {
+		t.Error("FindUnusedModels(-1) succeeded")
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	old := writeAgedModel(t, m.modelsPath, "llama3-q4_k_m.gguf", 60)
+	recent := writeAgedModel(t, m.modelsPath, "recent.gguf", 1)
+	if err := m.recordPull("llama3", "q4_k_m", old, "https://example.com/llama3.gguf", ""); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(old)
+
+	removed, freed, err := m.GarbageCollect(30)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{old}) || freed != info.Size() {
+		t.Errorf("GarbageCollect() = %v, %d, want %s, %d", removed, freed, old, info.Size())
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("%s still exists: %v", old, err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent model removed: %v", err)
+	}
+	if _, err := m.GetModelPath("llama3:q4_k_m"); err == nil {
+		t.Error("tag of the removed model still resolves")
+	}
+}
+
+func TestRecordAccessIgnoresFilesOutsideModelsDirectory(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	if err := m.RecordAccess(filepath.Join(t.TempDir(), "elsewhere.gguf")); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.loadAccessLog()) != 0 {
+		t.Errorf("access log = %v, want empty", m.loadAccessLog())
+	}
+}
@@ -1,24 +1,46 @@
 package model
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"colossus-cli/internal/logging"
 	"colossus-cli/internal/registry"
 	"colossus-cli/internal/types"
-
-	"github.com/sirupsen/logrus"
 )
 
+// logger logs with the model component
+var logger = logging.Component("model")
+
 // Manager handles model operations
 type Manager struct {
-	modelsPath string
-	hfRegistry *registry.HuggingFaceRegistry
+	modelsPath      string
+	hfRegistry      *registry.HuggingFaceRegistry
+	civitai         *registry.CivitaiRegistry
+	verifyChecksums bool
+	checkDiskSpace  bool
+	
+	// Backend the models are listed, pulled into and removed from instead
+	// of the models directory, when set
+	storage StorageBackend
+
+	// How long the result of checking a model's source for updates is reused
+	updateCheckInterval time.Duration
+
+	// Number of concurrent chunks large files are downloaded in
+	parallelDownloads int
+
+	// Guards the model metadata cache file
+	cacheMutex      sync.Mutex
 }
 
 // ProgressCallback is called during downloads to report progress
@@ -34,6 +56,7 @@ type DownloadProgress struct {
 	ETA          time.Duration
 	Status       string
 	Percentage   float64
+	Checksum     string // expected SHA256 of the file, if known
 }
 
 // NewManager creates a new model manager
@@ -43,16 +66,84 @@ func NewManager(modelsPath string) *Manager {
 	hfRegistry := registry.NewHuggingFaceRegistry(hfToken)
 	
 	return &Manager{
-		modelsPath: modelsPath,
-		hfRegistry: hfRegistry,
+		modelsPath:      modelsPath,
+		hfRegistry:      hfRegistry,
+		civitai:         registry.NewCivitaiRegistry(os.Getenv("CIVITAI_TOKEN")),
+		verifyChecksums: true,
+		checkDiskSpace:  true,
+		
+		updateCheckInterval: DefaultUpdateCheckInterval,
+		parallelDownloads:   DefaultParallelDownloads(),
 	}
 }
 
+// SetVerifyChecksums enables or disables SHA256 verification of downloads
+func (m *Manager) SetVerifyChecksums(verify bool) {
+	m.verifyChecksums = verify
+}
+
+// SetUpdateCheckInterval sets how long the result of checking a model's
+// source for updates is reused before the source is checked again
+func (m *Manager) SetUpdateCheckInterval(interval time.Duration) {
+	m.updateCheckInterval = interval
+}
+
+// SetParallelDownloads sets the number of concurrent range requests files of
+// at least ParallelDownloadThreshold bytes are downloaded with. With 1, files
+// are downloaded in a single stream.
+func (m *Manager) SetParallelDownloads(n int) {
+	m.parallelDownloads = n
+}
+
+// SetProxy makes Hugging Face and Civitai requests and model downloads go
+// through the proxy at proxyURL, except those to the noProxy hosts. See
+// registry.HuggingFaceRegistry.SetProxy.
+func (m *Manager) SetProxy(proxyURL string, noProxy []string) error {
+	if err := m.hfRegistry.SetProxy(proxyURL, noProxy); err != nil {
+		return err
+	}
+	m.civitai.Client.Transport = m.hfRegistry.Client.Transport
+	return nil
+}
+
+// SetConfirmDownload sets a function asking whether to download the selected
+// file of a Hugging Face model, given its size. An error it returns, e.g.
+// registry.ErrDownloadDeclined, cancels the pull.
+func (m *Manager) SetConfirmDownload(confirm func(fileName string, size int64) error) {
+	m.hfRegistry.ConfirmDownload = confirm
+}
+
+// SetStorage makes the manager list, pull and remove models in a storage
+// backend instead of the models directory. Models are still loaded from the
+// models directory.
+func (m *Manager) SetStorage(storage StorageBackend) {
+	m.storage = storage
+}
+
 // ListModels returns a list of installed models
 func (m *Manager) ListModels() ([]types.ModelInfo, error) {
+	if m.storage != nil {
+		return m.storage.List()
+	}
+
 	var models []types.ModelInfo
 	
-	err := filepath.Walk(m.modelsPath, func(path string, info os.FileInfo, err error) error {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
+	
+	// Only files that changed since they were cached are validated again.
+	// The cache is rebuilt from the files found, dropping removed models.
+	cache := m.loadModelCache()
+	updated := make(modelCache)
+	changed := false
+	
+	// Pulled models are listed under their tags
+	tagged, err := m.taggedModels()
+	if err != nil {
+		logger.Warnf("Failed to read manifests: %v", err)
+	}
+	
+	err = filepath.Walk(m.modelsPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -60,22 +151,62 @@ func (m *Manager) ListModels() ([]types.ModelInfo, error) {
 		// Check for supported model formats
 		if IsValidModelFormat(info.Name()) {
 			relPath, _ := filepath.Rel(m.modelsPath, path)
+			name := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+			size := info.Size()
+			modTime := info.ModTime()
+			
+			// Show split models as a single entry, listed under their first part
+			if base, part, count, ok := ParseSplitName(info.Name()); ok {
+				if part != 1 {
+					return nil
+				}
+				name = filepath.Join(filepath.Dir(relPath), base)
+				size = 0
+				for i := 1; i <= count; i++ {
+					partInfo, err := os.Stat(SplitPartPath(filepath.Join(filepath.Dir(path), base), i, count))
+					if err != nil {
+						logger.Warnf("Split model %s is missing part %d of %d", name, i, count)
+						continue
+					}
+					size += partInfo.Size()
+					if partInfo.ModTime().After(modTime) {
+						modTime = partInfo.ModTime()
+					}
+				}
+			}
 			
 			// Validate the model file
-			modelInfo, err := ValidateModel(path)
-			if err != nil {
-				logrus.Warnf("Failed to validate model %s: %v", relPath, err)
+			modelInfo := cache.lookup(path, info)
+			if modelInfo == nil {
+				changed = true
+				modelInfo, err = ValidateModel(path)
+				if err != nil {
+					logger.Warnf("Failed to validate model %s: %v", relPath, err)
+				}
+			}
+			if modelInfo != nil {
+				updated.store(path, info, modelInfo)
 			}
 			
 			model := types.ModelInfo{
-				Name:       strings.TrimSuffix(relPath, filepath.Ext(relPath)),
-				Size:       info.Size(),
-				ModifiedAt: info.ModTime(),
+				Name:         name,
+				Size:         size,
+				ModifiedAt:   modTime,
+				Quantization: "unknown",
 			}
 			
 			// Add validation information if available
-			if modelInfo != nil && modelInfo.Valid {
-				model.Digest = fmt.Sprintf("%s-%s", modelInfo.Format.String(), modelInfo.Version)
+			if modelInfo != nil && modelInfo.Valid && modelInfo.Quantization != "" {
+				model.Quantization = modelInfo.Quantization
+			}
+			
+			if tags, ok := tagged[path]; ok {
+				for _, tag := range tags {
+					model.Name = tag.Ref
+					model.Digest = tag.Entry.Digest
+					models = append(models, model)
+				}
+				return nil
 			}
 			
 			models = append(models, model)
@@ -84,34 +215,56 @@ func (m *Manager) ListModels() ([]types.ModelInfo, error) {
 		return nil
 	})
 	
+	if err == nil && (changed || len(updated) != len(cache)) {
+		if err := m.saveModelCache(updated); err != nil {
+			logger.Warnf("Failed to save model cache: %v", err)
+		}
+	}
+	
 	return models, err
 }
 
 // PullModel downloads a model from a registry or URL
-func (m *Manager) PullModel(name string) error {
-	return m.PullModelWithProgress(name, nil)
+func (m *Manager) PullModel(ctx context.Context, name string) error {
+	return m.PullModelWithProgress(ctx, name, nil)
 }
 
-// PullModelWithProgress downloads a model with progress reporting
-func (m *Manager) PullModelWithProgress(name string, progressCallback ProgressCallback) error {
-	logrus.Infof("Pulling model: %s", name)
+// PullModelWithProgress downloads a model with progress reporting. Retries of
+// Hugging Face downloads stop when ctx is done. A tag selects the quantization
+// to pull, e.g. "llama3:q4_k_m"; without one, the model is pulled as
+// "llama3:latest" in the default quantization. A model already pulled from the
+// same source with the same content is not downloaded again. References to
+// OCI registries, e.g. "ghcr.io/acme/llama3:q4_k_m", are pulled from there.
+func (m *Manager) PullModelWithProgress(ctx context.Context, ref string, progressCallback ProgressCallback) error {
+	if m.storage != nil {
+		return m.pullToStorage(ctx, ref, progressCallback)
+	}
+	if IsOCIReference(ref) {
+		return m.pullFromOCI(ctx, ref, progressCallback)
+	}
+	if IsCivitaiReference(ref) {
+		return m.pullFromCivitai(ctx, ref, progressCallback)
+	}
+
+	name, tag := ParseModelTag(ref)
+	logger.Infof("Pulling model: %s:%s", name, tag)
 	
 	// Try popular GGUF repositories first
-	if err := m.tryPopularGGUFRepositories(name, progressCallback); err == nil {
-		return nil
+	// Canceled downloads are not tried from the next source
+	if err := m.tryPopularGGUFRepositories(ctx, name, tag, progressCallback); err == nil || errors.Is(err, ErrInsufficientDiskSpace) || ctx.Err() != nil {
+		return err
 	}
 	
 	// First, try to download from Hugging Face Hub
 	if strings.Contains(name, "/") {
 		// Model name contains "/" so it's likely a Hugging Face model ID
-		return m.downloadFromHuggingFace(name, progressCallback)
+		return m.downloadFromHuggingFace(ctx, name, tag, name, progressCallback)
 	}
 	
 	// Try predefined model URLs
 	modelURL := m.getModelURL(name)
-	if modelURL != "" {
-		modelPath := filepath.Join(m.modelsPath, name+".gguf")
-		return m.downloadFileWithProgress(modelURL, modelPath, name, progressCallback)
+	if modelURL != "" && urlMatchesTag(modelURL, tag) {
+		return m.pullFile(ctx, modelURL, name, tag, progressCallback)
 	}
 	
 	// Try searching Hugging Face for the model
@@ -119,6 +272,7 @@ func (m *Manager) PullModelWithProgress(name string, progressCallback ProgressCa
 		Limit: 5,
 		Sort:  "downloads",
 		Direction: "desc",
+		GGUFOnly: true,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to search for model: %w", err)
@@ -130,67 +284,191 @@ func (m *Manager) PullModelWithProgress(name string, progressCallback ProgressCa
 	
 	// Use the first (most downloaded) result
 	bestMatch := searchResults.Models[0]
-	logrus.Infof("Found model: %s (downloads: %d)", bestMatch.ID, bestMatch.Downloads)
+	logger.Infof("Found model: %s (downloads: %d)", bestMatch.ID, bestMatch.Downloads)
 	
-	return m.downloadFromHuggingFace(bestMatch.ID, progressCallback)
+	return m.downloadFromHuggingFace(ctx, name, tag, bestMatch.ID, progressCallback)
+}
+
+// popularGGUFRepositories maps model names to the URLs of their files in
+// popular GGUF model repositories, tried in order
+var popularGGUFRepositories = map[string][]string{
+	"tinyllama": {
+		"https://huggingface.co/microsoft/DialoGPT-medium/resolve/main/pytorch_model.bin", // fallback
+		"https://huggingface.co/TheBloke/TinyLlama-1.1B-Chat-v1.0-GGUF/resolve/main/tinyllama-1.1b-chat-v1.0.q4_k_m.gguf",
+		"https://huggingface.co/QuantFactory/TinyLlama-1.1B-Chat-v1.0-GGUF/resolve/main/TinyLlama-1.1B-Chat-v1.0.q4_k_m.gguf",
+	},
+	"llama2": {
+		"https://huggingface.co/TheBloke/Llama-2-7B-Chat-GGUF/resolve/main/llama-2-7b-chat.q4_k_m.gguf",
+		"https://huggingface.co/QuantFactory/Meta-Llama-3-8B-Instruct-GGUF/resolve/main/Meta-Llama-3-8B-Instruct.q4_k_m.gguf",
+	},
+	"phi": {
+		"https://huggingface.co/microsoft/phi-2/resolve/main/model.safetensors", // fallback
+		"https://huggingface.co/TheBloke/phi-2-GGUF/resolve/main/phi-2.q4_k_m.gguf",
+	},
+	"mistral": {
+		"https://huggingface.co/TheBloke/Mistral-7B-Instruct-v0.1-GGUF/resolve/main/mistral-7b-instruct-v0.1.q4_k_m.gguf",
+	},
+	"codellama": {
+		"https://huggingface.co/TheBloke/CodeLlama-7B-Instruct-GGUF/resolve/main/codellama-7b-instruct.q4_k_m.gguf",
+	},
+	"gemma": {
+		"https://huggingface.co/bartowski/gemma-2-2b-it-GGUF/resolve/main/gemma-2-2b-it-Q4_K_M.gguf",
+	},
+	"qwen": {
+		"https://huggingface.co/Qwen/Qwen2-0.5B-Instruct-GGUF/resolve/main/qwen2-0_5b-instruct-q4_k_m.gguf",
+	},
 }
 
 // tryPopularGGUFRepositories tries to download from known GGUF model repositories
-func (m *Manager) tryPopularGGUFRepositories(name string, progressCallback ProgressCallback) error {
-	// Popular GGUF model repositories and their model mappings
-	ggufRepos := map[string][]string{
-		"tinyllama": {
-			"https://huggingface.co/microsoft/DialoGPT-medium/resolve/main/pytorch_model.bin", // fallback
-			"https://huggingface.co/TheBloke/TinyLlama-1.1B-Chat-v1.0-GGUF/resolve/main/tinyllama-1.1b-chat-v1.0.q4_k_m.gguf",
-			"https://huggingface.co/QuantFactory/TinyLlama-1.1B-Chat-v1.0-GGUF/resolve/main/TinyLlama-1.1B-Chat-v1.0.q4_k_m.gguf",
-		},
-		"llama2": {
-			"https://huggingface.co/TheBloke/Llama-2-7B-Chat-GGUF/resolve/main/llama-2-7b-chat.q4_k_m.gguf",
-			"https://huggingface.co/QuantFactory/Meta-Llama-3-8B-Instruct-GGUF/resolve/main/Meta-Llama-3-8B-Instruct.q4_k_m.gguf",
-		},
-		"phi": {
-			"https://huggingface.co/microsoft/phi-2/resolve/main/model.safetensors", // fallback
-			"https://huggingface.co/TheBloke/phi-2-GGUF/resolve/main/phi-2.q4_k_m.gguf",
-		},
-		"mistral": {
-			"https://huggingface.co/TheBloke/Mistral-7B-Instruct-v0.1-GGUF/resolve/main/mistral-7b-instruct-v0.1.q4_k_m.gguf",
-		},
-		"codellama": {
-			"https://huggingface.co/TheBloke/CodeLlama-7B-Instruct-GGUF/resolve/main/codellama-7b-instruct.q4_k_m.gguf",
-		},
-		"gemma": {
-			"https://huggingface.co/bartowski/gemma-2-2b-it-GGUF/resolve/main/gemma-2-2b-it-Q4_K_M.gguf",
-		},
-		"qwen": {
-			"https://huggingface.co/Qwen/Qwen2-0.5B-Instruct-GGUF/resolve/main/qwen2-0_5b-instruct-q4_k_m.gguf",
-		},
-	}
-	
+func (m *Manager) tryPopularGGUFRepositories(ctx context.Context, name, tag string, progressCallback ProgressCallback) error {
 	// Check if we have URLs for this model
-	urls, exists := ggufRepos[strings.ToLower(name)]
+	urls, exists := popularGGUFRepositories[strings.ToLower(name)]
 	if !exists {
 		return fmt.Errorf("model not found in popular GGUF repositories")
 	}
 	
-	// Try each URL until one works
+	// Try each URL of the tag until one works
 	for i, url := range urls {
-		logrus.Infof("Trying popular GGUF repository %d/%d: %s", i+1, len(urls), url)
+		if !urlMatchesTag(url, tag) {
+			continue
+		}
+		logger.Infof("Trying popular GGUF repository %d/%d: %s", i+1, len(urls), url)
 		
-		modelPath := filepath.Join(m.modelsPath, name+".gguf")
-		err := m.downloadFileWithProgress(url, modelPath, name, progressCallback)
+		err := m.pullFile(ctx, url, name, tag, progressCallback)
 		if err == nil {
-			logrus.Infof("Successfully downloaded %s from popular GGUF repository", name)
+			logger.Infof("Successfully downloaded %s from popular GGUF repository", name)
 			return nil
 		}
+		if errors.Is(err, ErrInsufficientDiskSpace) || ctx.Err() != nil {
+			return err
+		}
 		
-		logrus.Warnf("Failed to download from %s: %v", url, err)
+		logger.Warnf("Failed to download from %s: %v", url, err)
 	}
 	
 	return fmt.Errorf("failed to download from all popular GGUF repositories")
 }
 
-// RemoveModel removes a model from local storage
-func (m *Manager) RemoveModel(name string) error {
+// DefaultUpdateCheckInterval is how long the result of checking a model's
+// source for updates is reused by default
+const DefaultUpdateCheckInterval = 24 * time.Hour
+
+// CheckForUpdate reports whether the Hugging Face repository a pulled model
+// was downloaded from was modified after the model was pulled or last
+// updated. The repository is checked at most once per update check interval;
+// in between, the result of the last check is used.
+func (m *Manager) CheckForUpdate(ref string) (bool, error) {
+	name, tag := ParseModelTag(ref)
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := manifest.Tags[tag]
+	if !ok {
+		return false, fmt.Errorf("model not found: %s:%s", name, tag)
+	}
+	
+	modelID := m.hfModelID(entry.Source)
+	if modelID == "" {
+		return false, fmt.Errorf("cannot check %s:%s for updates: not pulled from Hugging Face", name, tag)
+	}
+	
+	if entry.CheckedAt == nil || entry.LatestModified == nil || time.Since(*entry.CheckedAt) >= m.updateCheckInterval {
+		info, err := m.hfRegistry.GetModelInfo(modelID)
+		if err != nil {
+			return false, fmt.Errorf("failed to check %s for updates: %w", modelID, err)
+		}
+		now := time.Now()
+		latest := info.LastModified
+		entry.CheckedAt = &now
+		entry.LatestModified = &latest
+		if err := m.saveManifest(manifest); err != nil {
+			return false, err
+		}
+	}
+	
+	return entry.outdated(), nil
+}
+
+// UpdateModel pulls a model again if CheckForUpdate reports that its source
+// was modified, and reports whether it did
+func (m *Manager) UpdateModel(ctx context.Context, ref string, progressCallback ProgressCallback) (bool, error) {
+	update, err := m.CheckForUpdate(ref)
+	if err != nil || !update {
+		return false, err
+	}
+	
+	name, tag := ParseModelTag(ref)
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return false, err
+	}
+	checked := manifest.Tags[tag]
+	
+	if err := m.PullModelWithProgress(ctx, ref, progressCallback); err != nil {
+		return false, err
+	}
+	
+	// The pull replaced the manifest entry, or left it as it was when the
+	// content did not change; either way the model is now up to date
+	manifest, err = m.loadManifest(name)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := manifest.Tags[tag]
+	if !ok {
+		return false, fmt.Errorf("model not found: %s:%s", name, tag)
+	}
+	entry.SourceModified = checked.LatestModified
+	entry.CheckedAt = checked.CheckedAt
+	entry.LatestModified = checked.LatestModified
+	if err := m.saveManifest(manifest); err != nil {
+		return false, err
+	}
+	
+	// The model card may have changed with the model
+	if cardPath, err := m.modelCardPath(name); err == nil {
+		os.Remove(cardPath)
+	}
+	return true, nil
+}
+
+// hfModelID returns the ID of the Hugging Face repository of a manifest
+// source, either a download URL such as
+// "https://huggingface.co/owner/repo/resolve/main/file.gguf" or a file such as
+// "owner/repo/file.gguf", or "" for other sources
+func (m *Manager) hfModelID(source string) string {
+	if rest, ok := strings.CutPrefix(source, m.hfRegistry.BaseURL+"/"); ok {
+		if i := strings.Index(rest, "/resolve/"); i > 0 {
+			return rest[:i]
+		}
+		return ""
+	}
+	if strings.Contains(source, "://") || strings.Contains(source, "@") {
+		return ""
+	}
+	parts := strings.SplitN(source, "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// RemoveModel removes a model from local storage. A pulled model may be named
+// with its tag, e.g. "llama3:q4_k_m".
+func (m *Manager) RemoveModel(ref string) error {
+	if m.storage != nil {
+		return m.storage.Delete(ref)
+	}
+
+	name, tag := ParseModelTag(ref)
+	if manifest, err := m.loadManifest(name); err == nil && manifest.Tags[tag] != nil {
+		return m.removeTag(name, tag)
+	}
+	if tag != DefaultTag {
+		return fmt.Errorf("model not found: %s", ref)
+	}
+	
 	// Find the model file
 	modelPath := filepath.Join(m.modelsPath, name+".gguf")
 	
@@ -198,15 +476,176 @@ func (m *Manager) RemoveModel(name string) error {
 		// Try with .bin extension
 		modelPath = filepath.Join(m.modelsPath, name+".bin")
 		if _, err := os.Stat(modelPath); os.IsNotExist(err) {
-			return fmt.Errorf("model not found: %s", name)
+			return m.removeSplitModel(name)
 		}
 	}
 	
-	return os.Remove(modelPath)
+	if err := os.Remove(modelPath); err != nil {
+		return err
+	}
+	m.invalidateModelCache(modelPath)
+	m.forgetModelFile(name, modelPath)
+	return nil
+}
+
+// removeSplitModel removes every part of a split model that is present
+func (m *Manager) removeSplitModel(name string) error {
+	matches, _ := filepath.Glob(filepath.Join(m.modelsPath, name+"-*-of-*.gguf"))
+	
+	removed := 0
+	for _, match := range matches {
+		if base, _, _, ok := ParseSplitName(filepath.Base(match)); !ok || base != filepath.Base(name) {
+			continue
+		}
+		if err := os.Remove(match); err != nil {
+			return err
+		}
+		m.invalidateModelCache(match)
+		removed++
+	}
+	
+	if removed == 0 {
+		return fmt.Errorf("model not found: %s", name)
+	}
+	return nil
+}
+
+// CopyModel makes dst a copy of the model src without using more disk space:
+// the model file is hard-linked, or copied when the link is not possible,
+// e.g. across devices. src may select one quantization of a model with a tag,
+// e.g. "llama3:q4_k_m".
+func (m *Manager) CopyModel(src, dst string) error {
+	if dst == "" || strings.ContainsAny(dst, `/\:`) {
+		return fmt.Errorf("invalid model name: %q", dst)
+	}
+	if _, err := m.GetModelPath(dst); err == nil {
+		return fmt.Errorf("model already exists: %s", dst)
+	}
+	
+	srcPath, err := m.findModelVariant(src)
+	if err != nil {
+		return err
+	}
+	
+	// Split models are copied part by part
+	srcPaths := []string{srcPath}
+	dstPaths := []string{filepath.Join(m.modelsPath, dst+filepath.Ext(srcPath))}
+	if _, _, count, ok := ParseSplitName(filepath.Base(srcPath)); ok {
+		if srcPaths, err = FindSplitParts(srcPath); err != nil {
+			return err
+		}
+		dstPaths = dstPaths[:0]
+		for i := 1; i <= count; i++ {
+			dstPaths = append(dstPaths, SplitPartPath(filepath.Join(m.modelsPath, dst), i, count))
+		}
+	}
+	
+	for i := range srcPaths {
+		if err := linkOrCopyFile(srcPaths[i], dstPaths[i]); err != nil {
+			for _, path := range dstPaths[:i] {
+				os.Remove(path)
+			}
+			return fmt.Errorf("failed to copy model: %w", err)
+		}
+	}
+	return nil
+}
+
+// findModelVariant returns the path to a model file. A name with a tag, e.g.
+// "llama3:q4_k_m", selects the file of the model with that quantization,
+// where the model may be a directory of variants downloaded from Hugging Face.
+func (m *Manager) findModelVariant(name string) (string, error) {
+	base, tag, ok := strings.Cut(name, ":")
+	if path, err := m.GetModelPath(name); err == nil || !ok {
+		return path, err
+	}
+	
+	models, err := m.ListModels()
+	if err != nil {
+		return "", err
+	}
+	
+	dir := strings.ReplaceAll(base, "/", "_")
+	for _, model := range models {
+		inModel := model.Name == base || strings.HasPrefix(model.Name, base+"/") || strings.HasPrefix(model.Name, dir+"/")
+		if inModel && strings.EqualFold(model.Quantization, tag) {
+			return m.GetModelPath(model.Name)
+		}
+	}
+	return "", fmt.Errorf("model not found: %s", name)
+}
+
+// linkFile hard-links a file. It is a variable so that tests can replace it.
+var linkFile = os.Link
+
+// linkOrCopyFile hard-links dst to src, falling back to copying src
+func linkOrCopyFile(src, dst string) error {
+	err := linkFile(src, dst)
+	if err == nil || os.IsExist(err) {
+		return err
+	}
+	logger.Debugf("Cannot link %s, copying it: %v", src, err)
+	
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
 }
 
 // GetModelPath returns the path to a model file
 func (m *Manager) GetModelPath(name string) (string, error) {
+	// As in Ollama, "name:latest" is the model "name"
+	name = strings.TrimSuffix(name, ":latest")
+	
+	// Aliases take precedence over models of the same name
+	target, err := m.ResolveAlias(name)
+	if err != nil {
+		return "", err
+	}
+	if target != name {
+		if info, err := os.Stat(target); err == nil && !info.IsDir() {
+			return target, nil
+		}
+		path, err := m.findModel(target)
+		if err != nil {
+			return "", fmt.Errorf("alias %s refers to a missing model: %w", name, err)
+		}
+		return path, nil
+	}
+	
+	path, err := m.findModel(name)
+	if err == nil {
+		return path, nil
+	}
+	
+	// Pulled models are also found by their tag
+	if tagged, tagErr := m.findTaggedModel(ParseModelTag(name)); tagErr == nil {
+		return tagged, nil
+	}
+	if m.storage != nil {
+		return "", fmt.Errorf("%w: models in the storage backend must be copied to the models directory to be loaded", err)
+	}
+	return "", err
+}
+
+// findModel returns the path to a model file in the models directory
+func (m *Manager) findModel(name string) (string, error) {
 	// Try different extensions
 	extensions := []string{".gguf", ".bin"}
 	
@@ -217,6 +656,15 @@ func (m *Manager) GetModelPath(name string) (string, error) {
 		}
 	}
 	
+	// Split models are loaded from their first part
+	parts, err := findSplitModel(filepath.Join(m.modelsPath, name))
+	if err == nil {
+		return parts[0], nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	
 	return "", fmt.Errorf("model not found: %s", name)
 }
 
@@ -233,99 +681,264 @@ func (m *Manager) getModelURL(name string) string {
 	return models[name]
 }
 
-// downloadFromHuggingFace downloads a model from Hugging Face Hub
-func (m *Manager) downloadFromHuggingFace(modelID string, progressCallback ProgressCallback) error {
+// downloadFromHuggingFace downloads the GGUF file of a Hugging Face model for
+// name:tag, unless it was already pulled
+func (m *Manager) downloadFromHuggingFace(ctx context.Context, name, tag, modelID string, progressCallback ProgressCallback) error {
 	// Create model directory
 	modelDir := filepath.Join(m.modelsPath, strings.ReplaceAll(modelID, "/", "_"))
 	if err := os.MkdirAll(modelDir, 0755); err != nil {
 		return fmt.Errorf("failed to create model directory: %w", err)
 	}
 	
-	// Convert progress callback
-	var hfCallback registry.ProgressCallback
-	if progressCallback != nil {
-		hfCallback = func(progress registry.DownloadProgress) error {
-			localProgress := DownloadProgress{
-				ModelName:  modelID,
-				FileName:   progress.FileName,
-				Downloaded: progress.Downloaded,
-				Total:      progress.Total,
-				Speed:      progress.Speed,
-				ETA:        progress.ETA,
-				Status:     progress.Status,
-			}
-			
-			if progress.Total > 0 {
-				localProgress.Percentage = float64(progress.Downloaded) / float64(progress.Total) * 100
-			}
-			
-			return progressCallback(localProgress)
+	// Convert progress callback, remembering the checksum reported by the registry
+	var checksum string
+	hfCallback := func(progress registry.DownloadProgress) error {
+		if progress.Checksum != "" {
+			checksum = progress.Checksum
+		}
+		
+		if progressCallback == nil {
+			return nil
 		}
+		
+		localProgress := DownloadProgress{
+			ModelName:  modelID,
+			FileName:   progress.FileName,
+			Downloaded: progress.Downloaded,
+			Total:      progress.Total,
+			Speed:      progress.Speed,
+			ETA:        progress.ETA,
+			Status:     progress.Status,
+			Checksum:   progress.Checksum,
+		}
+		
+		if progress.Total > 0 {
+			localProgress.Percentage = float64(progress.Downloaded) / float64(progress.Total) * 100
+		}
+		
+		return progressCallback(localProgress)
 	}
 	
-	// Download best GGUF variant
-	modelPath, err := m.hfRegistry.DownloadBestGGUF(modelID, modelDir, hfCallback)
+	// Select the best GGUF variant and check that it fits before downloading it
+	files, err := m.hfRegistry.ListGGUFFiles(modelID)
 	if err != nil {
 		return fmt.Errorf("failed to download from Hugging Face: %w", err)
 	}
+	if len(files) == 0 {
+		return fmt.Errorf("failed to download from Hugging Face: no GGUF files found for model %s", modelID)
+	}
+	bestFile := m.hfRegistry.SelectBestGGUF(files)
+	if tag != DefaultTag {
+		if bestFile, err = selectTaggedGGUF(files, tag); err != nil {
+			return fmt.Errorf("failed to download from Hugging Face: %s: %w", modelID, err)
+		}
+	}
+	logger.Infof("Selected GGUF file: %s (%.1f MB)", bestFile.RFileName, float64(bestFile.Size)/(1024*1024))
+	
+	modelPath := filepath.Join(modelDir, bestFile.RFileName)
+	source := modelID + "/" + bestFile.RFileName
+	if m.isPulled(name, tag, modelPath, source, bestFile.Size, lfsChecksum(bestFile)) {
+		logger.Infof("Model %s:%s is up to date", name, tag)
+		return nil
+	}
+	
+	if bestFile.Size <= 0 {
+		if size, err := m.hfRegistry.GetDownloadSize(modelID, bestFile.RFileName); err == nil {
+			bestFile.Size = size
+		}
+	}
+	if err := m.ensureDiskSpace(bestFile.Size); err != nil {
+		return err
+	}
+	if m.hfRegistry.ConfirmDownload != nil {
+		if err := m.hfRegistry.ConfirmDownload(bestFile.RFileName, bestFile.Size); err != nil {
+			return err
+		}
+	}
+	
+	// Large files are downloaded in concurrent chunks
+	if m.parallelDownloads > 1 && bestFile.Size >= ParallelDownloadThreshold {
+		checksum, err = m.downloadHuggingFaceChunks(ctx, modelID, bestFile.RFileName, modelPath, progressCallback)
+	} else {
+		err = m.hfRegistry.DownloadModel(ctx, modelID, bestFile.RFileName, modelPath, hfCallback)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download from Hugging Face: %w", err)
+	}
+	
+	m.invalidateModelCache(modelPath)
+	
+	if err := m.verifyDownload(modelPath, checksum); err != nil {
+		return err
+	}
 	
 	// Validate the downloaded model
 	validation, err := ValidateModel(modelPath)
 	if err != nil {
-		logrus.Warnf("Failed to validate downloaded model: %v", err)
+		logger.Warnf("Failed to validate downloaded model: %v", err)
 	} else if !validation.Valid {
 		return fmt.Errorf("downloaded model failed validation: %s", validation.Error)
 	} else {
-		logrus.Infof("Model validated successfully: %s %s", validation.Format, validation.Architecture)
+		logger.Infof("Model validated successfully: %s %s", validation.Format, validation.Architecture)
 	}
 	
-	logrus.Infof("Successfully downloaded model %s to %s", modelID, modelPath)
-	return nil
+	logger.Infof("Successfully downloaded model %s to %s", modelID, modelPath)
+	return m.recordPull(name, tag, modelPath, source, checksum)
 }
 
-// downloadFileWithProgress downloads a file with progress reporting
-func (m *Manager) downloadFileWithProgress(url, filepath, modelName string, progressCallback ProgressCallback) error {
-	logrus.Infof("Downloading from: %s", url)
-	
-	// Create the file
-	out, err := os.Create(filepath)
+// downloadHuggingFaceChunks downloads a file of a Hugging Face model with
+// the parallel downloader, returning its published checksum, if any
+func (m *Manager) downloadHuggingFaceChunks(ctx context.Context, modelID, fileName, path string, progressCallback ProgressCallback) (string, error) {
+	checksum, err := m.hfRegistry.FetchChecksum(modelID, fileName)
 	if err != nil {
-		return err
+		logger.Debugf("No checksum available for %s: %v", fileName, err)
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	
+	downloader := m.newDownloader()
+	if m.hfRegistry.Token != "" {
+		downloader.Header.Set("Authorization", "Bearer "+m.hfRegistry.Token)
+	}
+	url := fmt.Sprintf("%s/%s/resolve/main/%s", m.hfRegistry.BaseURL, modelID, fileName)
+	return checksum, downloader.Download(ctx, url, path, modelID, progressCallback)
+}
+
+// selectTaggedGGUF returns the GGUF file with the quantization named by tag
+func selectTaggedGGUF(files []registry.FileInfo, tag string) (registry.FileInfo, error) {
+	for _, file := range files {
+		if strings.Contains(strings.ToLower(file.RFileName), tag) {
+			return file, nil
+		}
+	}
+	return registry.FileInfo{}, fmt.Errorf("no GGUF file with quantization %s", tag)
+}
+
+// lfsChecksum returns the SHA256 of a Hugging Face file stored with Git LFS,
+// whose object ID is the SHA256 of its content
+func lfsChecksum(file registry.FileInfo) string {
+	if _, err := registry.ParseChecksum(file.LfsOID); err != nil {
+		return ""
+	}
+	return file.LfsOID
+}
+
+// urlMatchesTag reports whether a download URL is of the quantization named by
+// tag. Every URL matches DefaultTag.
+func urlMatchesTag(url, tag string) bool {
+	return tag == DefaultTag || strings.Contains(strings.ToLower(path.Base(url)), tag)
+}
+
+// taggedFilePath returns the path a model file downloaded for name:tag from a
+// URL is stored at, e.g. "llama3.gguf" or "llama3-q8_0.gguf"
+func (m *Manager) taggedFilePath(name, tag string) string {
+	if tag == DefaultTag {
+		return filepath.Join(m.modelsPath, name+".gguf")
+	}
+	return filepath.Join(m.modelsPath, name+"-"+tag+".gguf")
+}
+
+// verifyDownload checks a downloaded file against its expected checksum and
+// deletes the file if verification fails
+func (m *Manager) verifyDownload(path, checksum string) error {
+	if !m.verifyChecksums || checksum == "" {
+		return nil
 	}
-	defer out.Close()
 	
-	// Get the data
-	resp, err := http.Get(url)
+	if err := VerifyChecksum(path, checksum); err != nil {
+		if removeErr := os.Remove(path); removeErr != nil {
+			logger.Warnf("Failed to remove corrupt download %s: %v", path, removeErr)
+		}
+		return fmt.Errorf("checksum verification failed: %w", err)
+	}
+	
+	logger.Infof("Checksum verified for %s", path)
+	return nil
+}
+
+// fetchChecksum retrieves the SHA256 sidecar published next to a download URL
+func (m *Manager) fetchChecksum(url string) string {
+	resp, err := http.Get(url + ".sha256")
 	if err != nil {
-		return err
+		return ""
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download: %s", resp.Status)
+		return ""
+	}
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return ""
+	}
+	
+	checksum, err := registry.ParseChecksum(string(body))
+	if err != nil {
+		logger.Debugf("Ignoring invalid checksum for %s: %v", url, err)
+		return ""
 	}
 	
-	// Get content length for progress tracking
-	contentLength := resp.ContentLength
+	return checksum
+}
+
+// pullFile downloads a model file from a URL for name:tag with progress
+// reporting, unless it was already pulled from there
+func (m *Manager) pullFile(ctx context.Context, url, name, tag string, progressCallback ProgressCallback) error {
+	modelPath := m.taggedFilePath(name, tag)
+	checksum := m.fetchChecksum(url)
+	if m.isPulled(name, tag, modelPath, url, 0, checksum) {
+		logger.Infof("Model %s:%s is up to date", name, tag)
+		return nil
+	}
 	
-	// Download with progress reporting
-	if progressCallback != nil && contentLength > 0 {
-		return m.copyWithProgress(resp.Body, out, contentLength, modelName, filepath, progressCallback)
+	if err := m.downloadFile(ctx, url, modelPath, name, progressCallback); err != nil {
+		return err
+	}
+	if err := m.verifyDownload(modelPath, checksum); err != nil {
+		return err
 	}
+	return m.recordPull(name, tag, modelPath, url, checksum)
+}
+
+// downloadFile downloads a file from a URL without verification, in
+// concurrent chunks when it is large, until ctx is canceled
+func (m *Manager) downloadFile(ctx context.Context, url, filepath, modelName string, progressCallback ProgressCallback) error {
+	logger.Infof("Downloading from: %s", url)
+	m.invalidateModelCache(filepath)
 	
-	// Simple copy without progress
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return m.newDownloader().Download(ctx, url, filepath, modelName, progressCallback)
+}
+
+// newDownloader creates a downloader with the manager's number of parallel
+// downloads, checking that files fit on the disk
+func (m *Manager) newDownloader() *ParallelDownloader {
+	downloader := NewParallelDownloader(m.parallelDownloads)
+	downloader.CheckSize = m.ensureDiskSpace
+	if transport := m.hfRegistry.Client.Transport; transport != nil {
+		// Through the registry's proxy, without its request timeout
+		downloader.Client = &http.Client{Transport: transport}
+	}
+	return downloader
 }
 
-// copyWithProgress copies data with progress reporting
-func (m *Manager) copyWithProgress(reader io.Reader, writer io.Writer, totalSize int64, modelName, fileName string, progressCallback ProgressCallback) error {
+// copyWithProgress copies data with progress reporting, stopping with
+// ctx.Err() as soon as ctx is canceled
+func (m *Manager) copyWithProgress(ctx context.Context, reader io.Reader, writer io.Writer, totalSize int64, modelName, fileName string, progressCallback ProgressCallback) error {
 	buffer := make([]byte, 32*1024) // 32KB buffer
 	var downloaded int64
 	startTime := time.Now()
 	lastUpdate := startTime
 	
 	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		
 		n, err := reader.Read(buffer)
 		if err != nil && err != io.EOF {
 			return fmt.Errorf("read error: %w", err)
@@ -394,7 +1007,3 @@ func (m *Manager) copyWithProgress(reader io.Reader, writer io.Writer, totalSize
 	return nil
 }
 
-// downloadFile downloads a file from a URL (legacy method)
-func (m *Manager) downloadFile(url, filepath string) error {
-	return m.downloadFileWithProgress(url, filepath, "unknown", nil)
-}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"colossus-cli/internal/registry"
+)
+
+// writeModel creates a model file with the given contents under dir
+func writeModel(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCopyModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		crossDev  bool
+		wantLinks bool
+	}{
+		{name: "hard link", wantLinks: true},
+		{name: "cross-device copy", crossDev: true, wantLinks: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.crossDev {
+				// Links across filesystems fail with EXDEV
+				linkFile = func(oldname, newname string) error {
+					return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EXDEV}
+				}
+				defer func() { linkFile = os.Link }()
+			}
+
+			dir := t.TempDir()
+			srcPath := writeModel(t, dir, "llama3.gguf", "GGUF llama3")
+			m := NewManager(dir)
+
+			if err := m.CopyModel("llama3", "llama3-tuned"); err != nil {
+				t.Fatalf("CopyModel: %v", err)
+			}
+
+			dstPath, err := m.GetModelPath("llama3-tuned")
+			if err != nil {
+				t.Fatalf("copy not found: %v", err)
+			}
+			data, err := os.ReadFile(dstPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "GGUF llama3" {
+				t.Errorf("copy contains %q, want %q", data, "GGUF llama3")
+			}
+
+			srcInfo, _ := os.Stat(srcPath)
+			dstInfo, _ := os.Stat(dstPath)
+			if linked := os.SameFile(srcInfo, dstInfo); linked != tt.wantLinks {
+				t.Errorf("copy is a hard link = %t, want %t", linked, tt.wantLinks)
+			}
+		})
+	}
+}
+
+func TestCopyModelVariant(t *testing.T) {
+	dir := t.TempDir()
+	q4 := writeGGUF(t, dir, "TheBloke_Llama-2-7B-GGUF/llama-2-7b.Q4_K_M.gguf", ggufKV{"general.file_type", uint32(15)})
+	q8 := writeGGUF(t, dir, "TheBloke_Llama-2-7B-GGUF/llama-2-7b.Q8_0.gguf", ggufKV{"general.file_type", uint32(7)})
+	m := NewManager(dir)
+
+	if err := m.CopyModel("TheBloke/Llama-2-7B-GGUF:q8_0", "llama2-q8"); err != nil {
+		t.Fatalf("CopyModel: %v", err)
+	}
+
+	path, err := m.GetModelPath("llama2-q8")
+	if err != nil {
+		t.Fatalf("copy not found: %v", err)
+	}
+	copied, _ := os.Stat(path)
+	for variant, want := range map[string]bool{q4: false, q8: true} {
+		info, _ := os.Stat(variant)
+		if os.SameFile(info, copied) != want {
+			t.Errorf("copy is %s = %t, want %t", filepath.Base(variant), !want, want)
+		}
+	}
+}
+
+func TestCopyModelErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeModel(t, dir, "llama3.gguf", "GGUF llama3")
+	writeModel(t, dir, "mistral.gguf", "GGUF mistral")
+	m := NewManager(dir)
+
+	tests := []struct {
+		name string
+		src  string
+		dst  string
+	}{
+		{name: "missing source", src: "phi3", dst: "phi3-copy"},
+		{name: "existing destination", src: "llama3", dst: "mistral"},
+		{name: "path as destination", src: "llama3", dst: "../llama3"},
+		{name: "empty destination", src: "llama3", dst: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.CopyModel(tt.src, tt.dst); err == nil {
+				t.Errorf("CopyModel(%q, %q) succeeded, want an error", tt.src, tt.dst)
+			}
+		})
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "mistral.gguf")); string(data) != "GGUF mistral" {
+		t.Errorf("existing model overwritten with %q", data)
+	}
+}
+
+func TestPullConfirmsDownloadSize(t *testing.T) {
+	content := string(ggufFile(ggufKV{"general.file_type", uint32(7)}))
+	srv := httptest.NewServer(&fakeHFRepository{content: content})
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		confirm error
+	}{
+		{name: "confirmed"},
+		{name: "declined", confirm: registry.ErrDownloadDeclined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(t.TempDir())
+			m.hfRegistry.BaseURL = srv.URL
+
+			// The repository does not list sizes, so the size comes from a
+			// HEAD request
+			var sizes []int64
+			m.SetConfirmDownload(func(fileName string, size int64) error {
+				if fileName != "llama3.Q8_0.gguf" {
+					t.Errorf("confirmed %s, want llama3.Q8_0.gguf", fileName)
+				}
+				sizes = append(sizes, size)
+				return tt.confirm
+			})
+
+			err := m.PullModelWithProgress(context.Background(), "acme/llama3:q8_0", nil)
+			if !errors.Is(err, tt.confirm) {
+				t.Fatalf("PullModelWithProgress() error = %v, want %v", err, tt.confirm)
+			}
+			if len(sizes) != 1 || sizes[0] != int64(len(content)) {
+				t.Errorf("confirmed sizes = %v, want [%d]", sizes, len(content))
+			}
+			if _, err := m.GetModelPath("acme/llama3:q8_0"); (err == nil) != (tt.confirm == nil) {
+				t.Errorf("GetModelPath() error = %v after the %s pull", err, tt.name)
+			}
+		})
+	}
+}
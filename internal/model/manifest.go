@@ -0,0 +1,370 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestsDirName is the directory next to the models directory holding a
+// manifest for each pulled model, e.g. ~/.colossus/manifests/llama3/manifest.json
+const manifestsDirName = "manifests"
+
+// manifestFileName is the name of the manifest file in a model's directory
+const manifestFileName = "manifest.json"
+
+// DefaultTag is the tag of a model named without one
+const DefaultTag = "latest"
+
+// Manifest records the files pulled for the tags of a model
+type Manifest struct {
+	Name string                    `json:"name"`
+	Tags map[string]*ManifestEntry `json:"tags"`
+}
+
+// ManifestEntry describes the model file pulled for a tag
+type ManifestEntry struct {
+	// Digest is the SHA256 of the file's content, e.g. "sha256:9f86d0..."
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+
+	// File is the path of the model file relative to the models directory
+	File string `json:"file"`
+
+	// Source is the URL or Hugging Face file the model was downloaded from
+	Source       string    `json:"source"`
+	Quantization string    `json:"quantization,omitempty"`
+	PulledAt     time.Time `json:"pulled_at"`
+
+	// SourceModified is when the Hugging Face repository of the source was
+	// last modified as of the last update, if the model was updated
+	SourceModified *time.Time `json:"source_modified,omitempty"`
+
+	// CheckedAt is when the source was last checked for updates, and
+	// LatestModified when it had last been modified at that time
+	CheckedAt      *time.Time `json:"checked_at,omitempty"`
+	LatestModified *time.Time `json:"latest_modified,omitempty"`
+}
+
+// outdated reports whether the source was modified after the model was
+// pulled or last updated, as of the last check for updates
+func (e *ManifestEntry) outdated() bool {
+	if e.LatestModified == nil {
+		return false
+	}
+	installed := e.PulledAt
+	if e.SourceModified != nil {
+		installed = *e.SourceModified
+	}
+	return e.LatestModified.After(installed)
+}
+
+// ParseModelTag splits a model reference such as "llama3:q4_k_m" into the
+// model name and tag. The tag is DefaultTag when the reference has none.
+func ParseModelTag(ref string) (name, tag string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		if tag = strings.ToLower(ref[i+1:]); tag != "" {
+			return ref[:i], tag
+		}
+		ref = ref[:i]
+	}
+	return ref, DefaultTag
+}
+
+// FileDigest returns the SHA256 digest of a file in the form "sha256:<hex>"
+func FileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// manifestPath returns the path of a model's manifest. Model IDs such as
+// "TheBloke/Llama-2-7B-GGUF" are stored like their download directory.
+func (m *Manager) manifestPath(name string) (string, error) {
+	dir := strings.ReplaceAll(name, "/", "_")
+	if dir == "" || strings.ContainsAny(dir, `\:`) || dir == "." || dir == ".." {
+		return "", fmt.Errorf("invalid model name: %q", name)
+	}
+	return filepath.Join(filepath.Dir(m.modelsPath), manifestsDirName, dir, manifestFileName), nil
+}
+
+// loadManifest reads the manifest of a model, returning an empty manifest when
+// the model has none
+func (m *Manager) loadManifest(name string) (*Manifest, error) {
+	path, err := m.manifestPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Name: name, Tags: map[string]*ManifestEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if manifest.Tags == nil {
+		manifest.Tags = map[string]*ManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// saveManifest writes the manifest of a model, replacing the previous file
+// atomically. A manifest without tags is removed.
+func (m *Manager) saveManifest(manifest *Manifest) error {
+	path, err := m.manifestPath(manifest.Name)
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.Tags) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove manifest: %w", err)
+		}
+		// The cached model card goes with the last tag
+		os.Remove(filepath.Join(filepath.Dir(path), modelCardFileName))
+		os.Remove(filepath.Dir(path))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// listManifests returns the manifests of all pulled models
+func (m *Manager) listManifests() ([]*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(m.modelsPath), manifestsDirName, "*", manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			logger.Warnf("Ignoring invalid manifest %s: %v", path, err)
+			continue
+		}
+		manifests = append(manifests, &manifest)
+	}
+	return manifests, nil
+}
+
+// taggedModels maps the paths of model files to the "name:tag" references of
+// the manifests pointing to them, with their entries
+func (m *Manager) taggedModels() (map[string][]taggedModel, error) {
+	manifests, err := m.listManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make(map[string][]taggedModel)
+	for _, manifest := range manifests {
+		tags := make([]string, 0, len(manifest.Tags))
+		for tag := range manifest.Tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			entry := manifest.Tags[tag]
+			path := filepath.Join(m.modelsPath, filepath.FromSlash(entry.File))
+			tagged[path] = append(tagged[path], taggedModel{Ref: manifest.Name + ":" + tag, Entry: entry})
+		}
+	}
+	return tagged, nil
+}
+
+// PulledModels returns the "name:tag" references of all pulled models, sorted
+func (m *Manager) PulledModels() ([]string, error) {
+	manifests, err := m.listManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, manifest := range manifests {
+		for tag := range manifest.Tags {
+			refs = append(refs, manifest.Name+":"+tag)
+		}
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// taggedModel is a tag of a model with its manifest entry
+type taggedModel struct {
+	Ref   string
+	Entry *ManifestEntry
+}
+
+// findTaggedModel returns the path to the model file pulled for name:tag
+func (m *Manager) findTaggedModel(name, tag string) (string, error) {
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return "", err
+	}
+	entry, ok := manifest.Tags[tag]
+	if !ok {
+		return "", fmt.Errorf("model not found: %s:%s", name, tag)
+	}
+
+	path := filepath.Join(m.modelsPath, filepath.FromSlash(entry.File))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("model file of %s:%s is missing: %w", name, tag, err)
+	}
+	return path, nil
+}
+
+// isPulled reports whether name:tag was already pulled from source into path,
+// so that pulling it again can be skipped. The size and checksum of the
+// source are compared when known, i.e. non-zero.
+func (m *Manager) isPulled(name, tag, path, source string, size int64, checksum string) bool {
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return false
+	}
+	entry, ok := manifest.Tags[tag]
+	if !ok || entry.Source != source || filepath.Join(m.modelsPath, filepath.FromSlash(entry.File)) != path {
+		return false
+	}
+	if size > 0 && entry.Size != size {
+		return false
+	}
+	if checksum != "" && entry.Digest != "sha256:"+strings.ToLower(checksum) {
+		return false
+	}
+	// Without a checksum, a source modified since the pull is pulled again
+	if checksum == "" && entry.outdated() {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == entry.Size
+}
+
+// recordPull stores the manifest entry of a model file pulled for name:tag.
+// The file is hashed unless its verified checksum is given.
+func (m *Manager) recordPull(name, tag, path, source, checksum string) error {
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	digest := "sha256:" + strings.ToLower(checksum)
+	if checksum == "" || !m.verifyChecksums {
+		if digest, err = FileDigest(path); err != nil {
+			return err
+		}
+	}
+	rel, err := filepath.Rel(m.modelsPath, path)
+	if err != nil {
+		return err
+	}
+
+	entry := &ManifestEntry{
+		Digest:   digest,
+		Size:     info.Size(),
+		File:     filepath.ToSlash(rel),
+		Source:   source,
+		PulledAt: time.Now(),
+	}
+	if modelInfo, err := ValidateModel(path); err == nil {
+		entry.Quantization = modelInfo.Quantization
+	}
+	manifest.Tags[tag] = entry
+
+	if err := m.saveManifest(manifest); err != nil {
+		return err
+	}
+	logger.Infof("Pulled %s:%s (%s)", name, tag, digest)
+	return nil
+}
+
+// removeTag removes name:tag from the model's manifest. The model file is
+// removed too, unless another tag of the model refers to it.
+func (m *Manager) removeTag(name, tag string) error {
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return err
+	}
+	entry, ok := manifest.Tags[tag]
+	if !ok {
+		return fmt.Errorf("model not found: %s:%s", name, tag)
+	}
+	delete(manifest.Tags, tag)
+
+	shared := false
+	for _, other := range manifest.Tags {
+		shared = shared || other.File == entry.File
+	}
+	if !shared {
+		path := filepath.Join(m.modelsPath, filepath.FromSlash(entry.File))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		m.invalidateModelCache(path)
+	}
+
+	return m.saveManifest(manifest)
+}
+
+// forgetModelFile removes the tags of a model referring to a model file that
+// was removed
+func (m *Manager) forgetModelFile(name, path string) {
+	manifest, err := m.loadManifest(name)
+	if err != nil || len(manifest.Tags) == 0 {
+		return
+	}
+	for tag, entry := range manifest.Tags {
+		if filepath.Join(m.modelsPath, filepath.FromSlash(entry.File)) == path {
+			delete(manifest.Tags, tag)
+		}
+	}
+	if err := m.saveManifest(manifest); err != nil {
+		logger.Warnf("Failed to update manifest of %s: %v", name, err)
+	}
+}
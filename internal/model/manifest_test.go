@@ -0,0 +1,201 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseModelTag(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantName string
+		wantTag  string
+	}{
+		{ref: "llama3", wantName: "llama3", wantTag: "latest"},
+		{ref: "llama3:Q4_K_M", wantName: "llama3", wantTag: "q4_k_m"},
+		{ref: "llama3:", wantName: "llama3", wantTag: "latest"},
+		{ref: "TheBloke/Llama-2-7B-GGUF:q8_0", wantName: "TheBloke/Llama-2-7B-GGUF", wantTag: "q8_0"},
+		{ref: "TheBloke/Llama-2-7B-GGUF", wantName: "TheBloke/Llama-2-7B-GGUF", wantTag: "latest"},
+	}
+
+	for _, tt := range tests {
+		name, tag := ParseModelTag(tt.ref)
+		if name != tt.wantName || tag != tt.wantTag {
+			t.Errorf("ParseModelTag(%q) = %q, %q, want %q, %q", tt.ref, name, tag, tt.wantName, tt.wantTag)
+		}
+	}
+}
+
+func TestFileDigest(t *testing.T) {
+	path := writeModel(t, t.TempDir(), "llama3.gguf", "GGUF weights")
+	sum := sha256.Sum256([]byte("GGUF weights"))
+
+	digest, err := FileDigest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256:" + hex.EncodeToString(sum[:]); digest != want {
+		t.Errorf("FileDigest() = %s, want %s", digest, want)
+	}
+}
+
+// listModelNames returns the sorted names of the models listed by m
+func listModelNames(t *testing.T, m *Manager) []string {
+	t.Helper()
+	models, err := m.ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(models))
+	for _, model := range models {
+		names = append(names, model.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestManifestTags(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	path := writeGGUF(t, m.modelsPath, "llama3-q4_k_m.gguf", ggufKV{"general.file_type", uint32(15)})
+	const source = "https://example.com/llama3.Q4_K_M.gguf"
+
+	// The same file is pulled for two tags
+	for _, tag := range []string{"q4_k_m", "latest"} {
+		if err := m.recordPull("llama3", tag, path, source, ""); err != nil {
+			t.Fatalf("recordPull(%s): %v", tag, err)
+		}
+	}
+
+	manifest, err := m.loadManifest("llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := manifest.Tags["q4_k_m"]
+	digest, _ := FileDigest(path)
+	if entry == nil || entry.File != "llama3-q4_k_m.gguf" || entry.Digest != digest || entry.Quantization != "Q4_K_M" || entry.Source != source {
+		t.Fatalf("manifest entry = %+v, want the pulled file", entry)
+	}
+
+	if names := listModelNames(t, m); strings.Join(names, " ") != "llama3:latest llama3:q4_k_m" {
+		t.Errorf("models = %v, want both tags", names)
+	}
+	for _, ref := range []string{"llama3", "llama3:q4_k_m"} {
+		if got, err := m.GetModelPath(ref); err != nil || got != path {
+			t.Errorf("GetModelPath(%s) = %s, %v, want %s", ref, got, err, path)
+		}
+	}
+
+	// The file is kept until no tag refers to it
+	if err := m.RemoveModel("llama3:q4_k_m"); err != nil {
+		t.Fatalf("RemoveModel: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file of llama3:latest removed: %v", err)
+	}
+	if err := m.RemoveModel("llama3"); err != nil {
+		t.Fatalf("RemoveModel: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still exists after removing its last tag: %v", err)
+	}
+	manifestPath, _ := m.manifestPath("llama3")
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Errorf("manifest without tags still exists: %v", err)
+	}
+	if err := m.RemoveModel("llama3:q8_0"); err == nil {
+		t.Error("RemoveModel succeeded for a tag that was never pulled")
+	}
+}
+
+func TestIsPulled(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	path := writeModel(t, m.modelsPath, "llama3.gguf", "GGUF weights")
+	sum := sha256.Sum256([]byte("GGUF weights"))
+	checksum := hex.EncodeToString(sum[:])
+	const source = "https://example.com/llama3.gguf"
+
+	if m.isPulled("llama3", "latest", path, source, 0, "") {
+		t.Fatal("isPulled before the model was pulled")
+	}
+	if err := m.recordPull("llama3", "latest", path, source, checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		size     int64
+		checksum string
+		want     bool
+	}{
+		{name: "same source", source: source, want: true},
+		{name: "same size and checksum", source: source, size: 12, checksum: strings.ToUpper(checksum), want: true},
+		{name: "other source", source: "https://example.com/other.gguf", want: false},
+		{name: "other size", source: source, size: 13, want: false},
+		{name: "other checksum", source: source, checksum: strings.Repeat("0", 64), want: false},
+	}
+	for _, tt := range tests {
+		if got := m.isPulled("llama3", "latest", path, tt.source, tt.size, tt.checksum); got != tt.want {
+			t.Errorf("%s: isPulled() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	// A file changed since it was pulled is pulled again
+	if err := os.WriteFile(path, []byte("GGUF truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m.isPulled("llama3", "latest", path, source, 0, "") {
+		t.Error("isPulled after the file changed")
+	}
+}
+
+func TestPullFileSkipsPulledModel(t *testing.T) {
+	content := "GGUF weights"
+	sum := sha256.Sum256([]byte(content))
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/llama3.Q8_0.gguf":
+			if r.Method == http.MethodGet {
+				downloads.Add(1)
+			}
+			w.Write([]byte(content))
+		case "/llama3.Q8_0.gguf.sha256":
+			w.Write([]byte(hex.EncodeToString(sum[:])))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	if err := os.MkdirAll(m.modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.pullFile(context.Background(), srv.URL+"/llama3.Q8_0.gguf", "llama3", "q8_0", nil); err != nil {
+			t.Fatalf("pullFile: %v", err)
+		}
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("downloaded %d times, want once", n)
+	}
+
+	path, err := m.GetModelPath("llama3:q8_0")
+	if err != nil || filepath.Base(path) != "llama3-q8_0.gguf" {
+		t.Errorf("GetModelPath() = %s, %v, want llama3-q8_0.gguf", path, err)
+	}
+	manifest, _ := m.loadManifest("llama3")
+	if entry := manifest.Tags["q8_0"]; entry == nil || entry.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Errorf("manifest entry = %+v, want the verified digest", entry)
+	}
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// modelCardFileName is the name of the cached model card in a model's
+// manifest directory
+const modelCardFileName = "README.md"
+
+// modelCardPath returns the path of the cached model card of a pulled model,
+// e.g. ~/.colossus/manifests/llama3/README.md
+func (m *Manager) modelCardPath(name string) (string, error) {
+	path, err := m.manifestPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), modelCardFileName), nil
+}
+
+// GetModelCard returns the Hugging Face model card of a pulled model, or of
+// a Hugging Face model ID such as "TheBloke/Llama-2-7B-GGUF". The card of a
+// pulled model is cached in its manifest directory until the model is
+// updated.
+func (m *Manager) GetModelCard(ref string) (string, error) {
+	name, tag := ParseModelTag(ref)
+	manifest, err := m.loadManifest(name)
+	if err != nil {
+		return "", err
+	}
+
+	entry, pulled := manifest.Tags[tag]
+	if !pulled {
+		// Not pulled: the reference must be a repository to fetch it from
+		if strings.Count(ref, "/") != 1 || strings.Contains(ref, ":") {
+			return "", fmt.Errorf("no model card for %s: not pulled from Hugging Face", ref)
+		}
+		return m.hfRegistry.GetModelCard(ref)
+	}
+
+	modelID := m.hfModelID(entry.Source)
+	if modelID == "" {
+		return "", fmt.Errorf("no model card for %s: not pulled from Hugging Face", ref)
+	}
+
+	cachePath, err := m.modelCardPath(name)
+	if err != nil {
+		return "", err
+	}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		return string(data), nil
+	}
+
+	card, err := m.hfRegistry.GetModelCard(modelID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(cachePath, []byte(card), 0644); err != nil {
+		logger.Warnf("Failed to cache model card of %s: %v", name, err)
+	}
+	return card, nil
+}
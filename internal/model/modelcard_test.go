@@ -0,0 +1,91 @@
+package model
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetModelCard(t *testing.T) {
+	repo := &fakeHFRepository{
+		content:      string(ggufFile(ggufKV{"general.file_type", uint32(7)})),
+		card:         "# Llama 3\n",
+		lastModified: time.Now().Add(-time.Hour),
+	}
+	srv := httptest.NewServer(repo)
+	defer srv.Close()
+
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	m.hfRegistry.BaseURL = srv.URL
+	if err := os.MkdirAll(m.modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A repository that was not pulled is fetched every time
+	for i := 0; i < 2; i++ {
+		if card, err := m.GetModelCard("acme/llama3"); err != nil || card != "# Llama 3\n" {
+			t.Fatalf("GetModelCard() = %q, %v, want the repository's card", card, err)
+		}
+	}
+	if repo.cardRequests != 2 {
+		t.Errorf("card fetched %d times, want 2", repo.cardRequests)
+	}
+
+	if err := m.PullModelWithProgress(context.Background(), "acme/llama3:q8_0", nil); err != nil {
+		t.Fatalf("PullModelWithProgress: %v", err)
+	}
+
+	// The card of a pulled model is cached in its manifest directory
+	repo.cardRequests = 0
+	for i := 0; i < 2; i++ {
+		if card, err := m.GetModelCard("acme/llama3:q8_0"); err != nil || card != "# Llama 3\n" {
+			t.Fatalf("GetModelCard() = %q, %v, want the repository's card", card, err)
+		}
+	}
+	if repo.cardRequests != 1 {
+		t.Errorf("card fetched %d times, want once", repo.cardRequests)
+	}
+	cachePath, err := m.modelCardPath("acme/llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Errorf("model card not cached: %v", err)
+	}
+
+	// Updating the model drops the cached card
+	repo.card = "# Llama 3.1\n"
+	repo.update(string(ggufFile(ggufKV{"general.file_type", uint32(7)}, ggufKV{"general.name", "v2"})), time.Now())
+	if _, err := m.UpdateModel(context.Background(), "acme/llama3:q8_0", nil); err != nil {
+		t.Fatalf("UpdateModel: %v", err)
+	}
+	if card, err := m.GetModelCard("acme/llama3:q8_0"); err != nil || card != "# Llama 3.1\n" {
+		t.Errorf("GetModelCard() after update = %q, %v, want the new card", card, err)
+	}
+
+	// Removing the model removes its cached card
+	if err := m.RemoveModel("acme/llama3:q8_0"); err != nil {
+		t.Fatalf("RemoveModel: %v", err)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("cached model card left after removal: %v", err)
+	}
+}
+
+func TestGetModelCardErrors(t *testing.T) {
+	srv := httptest.NewServer(&fakeHFRepository{})
+	defer srv.Close()
+
+	m := NewManager(t.TempDir())
+	m.hfRegistry.BaseURL = srv.URL
+	writeModel(t, m.modelsPath, "local.gguf", string(ggufFile()))
+
+	for _, ref := range []string{"local", "acme/missing", "acme/llama3:q4_0"} {
+		if card, err := m.GetModelCard(ref); err == nil {
+			t.Errorf("GetModelCard(%q) = %q, want an error", ref, card)
+		}
+	}
+}
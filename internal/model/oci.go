@@ -0,0 +1,677 @@
+package model
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ociModelConfigMediaType is the media type of the config blob of model
+// artifacts pushed by PushModel
+const ociModelConfigMediaType = "application/vnd.colossus.model.config.v1+json"
+
+// ociModelConfig is the config blob of a model artifact, describing the model
+// files packed in its layer
+type ociModelConfig struct {
+	Name         string   `json:"name"`
+	Files        []string `json:"files"`
+	Size         int64    `json:"size"`
+	Architecture string   `json:"architecture,omitempty"`
+	Quantization string   `json:"quantization,omitempty"`
+}
+
+// ociReference is a parsed reference to a model in an OCI registry, e.g.
+// "ghcr.io/acme/models/llama3:q4_k_m"
+type ociReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// IsOCIReference reports whether a model name refers to a model in an OCI
+// registry, i.e. its first path component is a registry host such as
+// "ghcr.io", "localhost" or "registry:5000" rather than a Hugging Face user
+func IsOCIReference(name string) bool {
+	host, rest, ok := strings.Cut(name, "/")
+	return ok && rest != "" && (strings.ContainsAny(host, ".:") || host == "localhost")
+}
+
+// parseOCIReference parses a REGISTRY/NAMESPACE/NAME[:TAG] reference. The tag
+// defaults to DefaultTag.
+func parseOCIReference(ref string) (ociReference, error) {
+	if !IsOCIReference(ref) {
+		return ociReference{}, fmt.Errorf("invalid registry reference %q: expected REGISTRY/NAMESPACE/NAME:TAG", ref)
+	}
+	registry, repository, _ := strings.Cut(ref, "/")
+
+	tag := DefaultTag
+	if i := strings.LastIndex(repository, ":"); i >= 0 {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	if repository == "" || tag == "" || repository != strings.ToLower(repository) || strings.Contains(repository, "//") {
+		return ociReference{}, fmt.Errorf("invalid registry reference %q: the repository must be lowercase and the tag not empty", ref)
+	}
+	return ociReference{Registry: registry, Repository: repository, Tag: tag}, nil
+}
+
+// String returns the reference in the REGISTRY/REPOSITORY:TAG form
+func (r ociReference) String() string {
+	return r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// url returns the URL of a registry API path under the repository, e.g.
+// "manifests/latest". Registries on loopback addresses are reached over
+// plain HTTP, all others over HTTPS.
+func (r ociReference) url(apiPath string) string {
+	scheme := "https"
+	host := r.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, r.Registry, r.Repository, apiPath)
+}
+
+// PushModel pushes a model to an OCI registry, e.g. "ghcr.io/acme/llama3:q4_k_m",
+// as an artifact whose layer is a gzipped tar of the model files. Registry
+// credentials are read from COLOSSUS_REGISTRY_USERNAME and
+// COLOSSUS_REGISTRY_PASSWORD.
+func (m *Manager) PushModel(modelName, registryURL string) error {
+	ref, err := parseOCIReference(registryURL)
+	if err != nil {
+		return err
+	}
+
+	modelPath, err := m.findModelVariant(modelName)
+	if err != nil {
+		return err
+	}
+
+	// Split models are pushed with all their parts in the layer
+	paths := []string{modelPath}
+	if _, _, _, ok := ParseSplitName(filepath.Base(modelPath)); ok {
+		if paths, err = FindSplitParts(modelPath); err != nil {
+			return err
+		}
+	}
+
+	config := ociModelConfig{Name: modelName}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		config.Files = append(config.Files, filepath.Base(p))
+		config.Size += info.Size()
+	}
+	if info, err := ValidateModel(modelPath); err == nil {
+		config.Architecture = info.Architecture
+		config.Quantization = info.Quantization
+	}
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	configDesc := v1.Descriptor{
+		MediaType: ociModelConfigMediaType,
+		Digest:    digest.FromBytes(configData),
+		Size:      int64(len(configData)),
+	}
+
+	layerPath, layerDesc, err := m.packModelLayer(paths)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(layerPath)
+
+	manifest, err := json.Marshal(v1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: v1.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []v1.Descriptor{layerDesc},
+		Annotations: map[string]string{
+			v1.AnnotationTitle: modelName,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	client := newOCIClient(ref)
+	logger.Infof("Pushing model %s to %s (%s)", modelName, ref, layerDesc.Digest)
+	if err := client.uploadBlob(layerDesc, func() (io.ReadCloser, error) { return os.Open(layerPath) }); err != nil {
+		return fmt.Errorf("failed to push model layer: %w", err)
+	}
+	if err := client.uploadBlob(configDesc, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(configData)), nil
+	}); err != nil {
+		return fmt.Errorf("failed to push model config: %w", err)
+	}
+	if err := client.putManifest(manifest); err != nil {
+		return fmt.Errorf("failed to push manifest: %w", err)
+	}
+	return nil
+}
+
+// packModelLayer writes model files into a gzipped tar, in a temporary file
+// next to the models directory, and returns its path and descriptor
+func (m *Manager) packModelLayer(paths []string) (string, v1.Descriptor, error) {
+	out, err := os.CreateTemp(filepath.Dir(m.modelsPath), "push-*.tar.gz")
+	if err != nil {
+		return "", v1.Descriptor{}, fmt.Errorf("failed to create model layer: %w", err)
+	}
+
+	digester := digest.Canonical.Digester()
+	counter := &countingWriter{w: io.MultiWriter(out, digester.Hash())}
+	err = writeModelTar(counter, paths)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", v1.Descriptor{}, fmt.Errorf("failed to create model layer: %w", err)
+	}
+
+	return out.Name(), v1.Descriptor{
+		MediaType: v1.MediaTypeImageLayerGzip,
+		Digest:    digester.Digest(),
+		Size:      counter.n,
+	}, nil
+}
+
+// writeModelTar writes model files into a gzipped tar. Model files hardly
+// compress, so the fastest compression is used.
+func writeModelTar(w io.Writer, paths []string) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		header := &tar.Header{
+			Name:    filepath.Base(p),
+			Mode:    0644,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			file.Close()
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// PullFromOCI downloads a model pushed to an OCI registry by PushModel. The
+// model is installed under the last component of the repository and the tag
+// of the reference, e.g. "llama3:q4_k_m" for "ghcr.io/acme/llama3:q4_k_m".
+func (m *Manager) PullFromOCI(reference string) error {
+	return m.pullFromOCI(context.Background(), reference, nil)
+}
+
+// pullFromOCI downloads a model from an OCI registry with progress reporting,
+// unless the same layer was already pulled for its tag
+func (m *Manager) pullFromOCI(ctx context.Context, reference string, progressCallback ProgressCallback) error {
+	ref, err := parseOCIReference(reference)
+	if err != nil {
+		return err
+	}
+	name, tag := path.Base(ref.Repository), strings.ToLower(ref.Tag)
+	client := newOCIClient(ref)
+
+	manifest, err := client.getManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if manifest.Config.MediaType != ociModelConfigMediaType || len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != v1.MediaTypeImageLayerGzip {
+		return fmt.Errorf("%s is not a model artifact", ref)
+	}
+	layer := manifest.Layers[0]
+
+	var config ociModelConfig
+	if err := client.getJSONBlob(ctx, manifest.Config, &config); err != nil {
+		return fmt.Errorf("failed to read model config: %w", err)
+	}
+	if len(config.Files) == 0 {
+		return fmt.Errorf("%s has no model files", ref)
+	}
+
+	// The files are stored like files downloaded for the tag, keeping the
+	// part numbers of split models
+	base := strings.TrimSuffix(m.taggedFilePath(name, tag), ".gguf")
+	targets := make(map[string]string, len(config.Files))
+	var first string
+	for _, fileName := range config.Files {
+		if fileName != filepath.Base(fileName) || !IsValidModelFormat(fileName) {
+			return fmt.Errorf("invalid model file in %s: %q", ref, fileName)
+		}
+		target := base + filepath.Ext(fileName)
+		if _, part, count, ok := ParseSplitName(fileName); ok {
+			target = SplitPartPath(base, part, count)
+		}
+		targets[fileName] = target
+		if first == "" || target < first {
+			first = target
+		}
+	}
+
+	source := ref.Registry + "/" + ref.Repository + "@" + layer.Digest.String()
+	if m.isPulled(name, tag, first, source, 0, "") {
+		logger.Infof("Model %s:%s is up to date", name, tag)
+		return nil
+	}
+	if err := m.ensureDiskSpace(config.Size); err != nil {
+		return err
+	}
+
+	blob, err := client.getBlob(ctx, layer.Digest)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	// Files are extracted to .part files, renamed once the layer is verified
+	verifier := layer.Digest.Verifier()
+	stream := io.TeeReader(blob, verifier)
+	extracted, err := m.extractModelTar(ctx, stream, targets, name, progressCallback)
+	removeParts := func() {
+		for _, p := range extracted {
+			os.Remove(p + partialDownloadExt)
+		}
+	}
+	if err != nil {
+		removeParts()
+		return fmt.Errorf("failed to extract model: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		removeParts()
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	if !verifier.Verified() {
+		removeParts()
+		return fmt.Errorf("digest verification failed for layer %s", layer.Digest)
+	}
+	if len(extracted) != len(targets) {
+		removeParts()
+		return fmt.Errorf("model layer of %s is missing files", ref)
+	}
+
+	for _, target := range extracted {
+		if err := os.Rename(target+partialDownloadExt, target); err != nil {
+			removeParts()
+			return err
+		}
+		m.invalidateModelCache(target)
+	}
+
+	logger.Infof("Successfully pulled model %s:%s from %s", name, tag, ref)
+	return m.recordPull(name, tag, first, source, "")
+}
+
+// extractModelTar extracts the model files of a gzipped tar to the .part
+// files of their targets and returns the targets extracted
+func (m *Manager) extractModelTar(ctx context.Context, r io.Reader, targets map[string]string, modelName string, progressCallback ProgressCallback) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var extracted []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, err
+		}
+		target, ok := targets[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			return extracted, fmt.Errorf("unexpected file in model layer: %q", header.Name)
+		}
+
+		out, err := os.Create(target + partialDownloadExt)
+		if err != nil {
+			return extracted, err
+		}
+		extracted = append(extracted, target)
+		if progressCallback != nil {
+			err = m.copyWithProgress(ctx, tr, out, header.Size, modelName, header.Name, progressCallback)
+		} else {
+			_, err = io.Copy(out, tr)
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return extracted, err
+		}
+	}
+}
+
+// ociClient is a client of the OCI distribution API of one repository
+type ociClient struct {
+	ref    ociReference
+	client *http.Client
+
+	// Credentials sent as basic auth, or exchanged for a bearer token when
+	// the registry asks for one
+	username, password string
+	token              string
+}
+
+// newOCIClient creates a client of a repository, with the registry
+// credentials from the environment
+func newOCIClient(ref ociReference) *ociClient {
+	return &ociClient{
+		ref:      ref,
+		client:   &http.Client{},
+		username: os.Getenv("COLOSSUS_REGISTRY_USERNAME"),
+		password: os.Getenv("COLOSSUS_REGISTRY_PASSWORD"),
+	}
+}
+
+// do sends a request, authenticating and sending it again when the registry
+// answers 401 Unauthorized
+func (c *ociClient) do(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, err
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authenticate answers an authentication challenge of the registry. Bearer
+// challenges are answered by fetching a token from the registry's token
+// service, basic challenges by sending the credentials.
+func (c *ociClient) authenticate(challenge string) error {
+	scheme, params := parseAuthChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") {
+		if c.username == "" {
+			return fmt.Errorf("registry %s requires authentication: set COLOSSUS_REGISTRY_USERNAME and COLOSSUS_REGISTRY_PASSWORD", c.ref.Registry)
+		}
+		return nil
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid authentication challenge from %s: %q", c.ref.Registry, challenge)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull,push"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with %s: %w", c.ref.Registry, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to authenticate with %s: %s", c.ref.Registry, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to authenticate with %s: %w", c.ref.Registry, err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("failed to authenticate with %s: no token received", c.ref.Registry)
+	}
+	return nil
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",scope="repository:a:pull,push"`
+func parseAuthChallenge(header string) (scheme string, params map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params = make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(value)
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
+
+// uploadBlob uploads a blob unless the registry already has it
+func (c *ociClient) uploadBlob(desc v1.Descriptor, open func() (io.ReadCloser, error)) error {
+	resp, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, c.ref.url("blobs/"+desc.Digest.String()), nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		logger.Debugf("Registry already has blob %s", desc.Digest)
+		return nil
+	}
+
+	resp, err = c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, c.ref.url("blobs/uploads/"), nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to start upload: %s", resp.Status)
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest.String())
+	location.RawQuery = query.Encode()
+
+	// The blob is uploaded in a single request
+	resp, err = c.do(func() (*http.Request, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodPut, location.String(), body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		req.ContentLength = desc.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to upload blob %s: %s", desc.Digest, resp.Status)
+	}
+	return nil
+}
+
+// putManifest uploads the manifest of the reference's tag
+func (c *ociClient) putManifest(manifest []byte) error {
+	resp, err := c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, c.ref.url("manifests/"+c.ref.Tag), bytes.NewReader(manifest))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", v1.MediaTypeImageManifest)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("registry responded %s", resp.Status)
+	}
+	return nil
+}
+
+// getManifest downloads the manifest of the reference's tag
+func (c *ociClient) getManifest(ctx context.Context) (*v1.Manifest, error) {
+	resp, err := c.do(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ref.url("manifests/"+c.ref.Tag), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", v1.MediaTypeImageManifest)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("model not found: %s", c.ref)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get manifest of %s: %s", c.ref, resp.Status)
+	}
+
+	var manifest v1.Manifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s: %w", c.ref, err)
+	}
+	return &manifest, nil
+}
+
+// getBlob downloads a blob. The caller verifies its digest.
+func (c *ociClient) getBlob(ctx context.Context, d digest.Digest) (io.ReadCloser, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	resp, err := c.do(func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, c.ref.url("blobs/"+d.String()), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download blob %s: %s", d, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// getJSONBlob downloads a small JSON blob, verifying its digest
+func (c *ociClient) getJSONBlob(ctx context.Context, desc v1.Descriptor, v interface{}) error {
+	blob, err := c.getBlob(ctx, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(io.LimitReader(blob, 1<<20))
+	if err != nil {
+		return err
+	}
+	if desc.Digest.Algorithm().FromBytes(data) != desc.Digest {
+		return fmt.Errorf("digest verification failed for blob %s", desc.Digest)
+	}
+	return json.Unmarshal(data, v)
+}
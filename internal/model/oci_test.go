@@ -0,0 +1,196 @@
+package model
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    ociReference
+		wantErr bool
+	}{
+		{ref: "ghcr.io/acme/llama3:q4_k_m", want: ociReference{"ghcr.io", "acme/llama3", "q4_k_m"}},
+		{ref: "ghcr.io/acme/llama3", want: ociReference{"ghcr.io", "acme/llama3", DefaultTag}},
+		{ref: "localhost:5000/llama3:v1", want: ociReference{"localhost:5000", "llama3", "v1"}},
+		{ref: "TheBloke/Llama-2-7B-GGUF", wantErr: true},
+		{ref: "ghcr.io/acme/Llama3", wantErr: true},
+		{ref: "ghcr.io/acme/llama3:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOCIReference(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOCIReference(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseOCIReference(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.example.com/token",service="registry",scope="repository:acme/llama3:pull,push"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.example.com/token" || params["service"] != "registry" || params["scope"] != "repository:acme/llama3:pull,push" {
+		t.Errorf("parseAuthChallenge() = %s, %v", scheme, params)
+	}
+}
+
+// fakeRegistry is an in-memory OCI registry that requires a bearer token
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+	blobGets  int
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		w.Write([]byte(`{"token":"secret"}`))
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+req.Host+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rest := strings.TrimPrefix(req.URL.Path, "/v2/acme/llama3/")
+	switch {
+	case req.Method == http.MethodPost && rest == "blobs/uploads/":
+		w.Header().Set("Location", "/v2/acme/llama3/blobs/uploads/1")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.HasPrefix(rest, "blobs/uploads/"):
+		data, _ := io.ReadAll(req.Body)
+		d := req.URL.Query().Get("digest")
+		if digest.FromBytes(data).String() != d {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[d] = data
+		r.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(rest, "blobs/"):
+		data, ok := r.blobs[strings.TrimPrefix(rest, "blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Method == http.MethodGet {
+			r.blobGets++
+			w.Write(data)
+		}
+	case req.Method == http.MethodPut && strings.HasPrefix(rest, "manifests/"):
+		data, _ := io.ReadAll(req.Body)
+		r.manifests[strings.TrimPrefix(rest, "manifests/")] = data
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodGet && strings.HasPrefix(rest, "manifests/"):
+		data, ok := r.manifests[strings.TrimPrefix(rest, "manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPushAndPullOCI(t *testing.T) {
+	registry := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	srv := httptest.NewServer(registry)
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/acme/llama3:q4_k_m"
+
+	pusher := NewManager(filepath.Join(t.TempDir(), "models"))
+	source := writeGGUF(t, pusher.modelsPath, "llama3.gguf",
+		ggufKV{"general.architecture", "llama"}, ggufKV{"general.file_type", uint32(15)})
+	if err := pusher.PushModel("llama3", ref); err != nil {
+		t.Fatalf("PushModel: %v", err)
+	}
+	if registry.uploads != 2 {
+		t.Errorf("uploaded %d blobs, want the layer and the config", registry.uploads)
+	}
+
+	// Blobs the registry already has are not uploaded again
+	if err := pusher.PushModel("llama3", ref); err != nil {
+		t.Fatalf("PushModel again: %v", err)
+	}
+	if registry.uploads != 2 {
+		t.Errorf("uploaded %d blobs after pushing again, want 2", registry.uploads)
+	}
+
+	puller := NewManager(filepath.Join(t.TempDir(), "models"))
+	if err := os.MkdirAll(puller.modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := puller.PullFromOCI(ref); err != nil {
+		t.Fatalf("PullFromOCI: %v", err)
+	}
+	path, err := puller.GetModelPath("llama3:q4_k_m")
+	if err != nil {
+		t.Fatalf("GetModelPath: %v", err)
+	}
+	want, _ := os.ReadFile(source)
+	if got, _ := os.ReadFile(path); string(got) != string(want) {
+		t.Error("pulled model differs from the pushed one")
+	}
+
+	// A model pulled from the same layer is not downloaded again
+	gets := registry.blobGets
+	if err := puller.PullFromOCI(ref); err != nil {
+		t.Fatalf("PullFromOCI again: %v", err)
+	}
+	if registry.blobGets != gets+1 {
+		t.Errorf("downloaded %d blobs pulling again, want only the config", registry.blobGets-gets)
+	}
+
+	if err := puller.PullFromOCI(strings.TrimSuffix(ref, "q4_k_m") + "q8_0"); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("PullFromOCI(missing tag) error = %v, want model not found", err)
+	}
+}
+
+func TestPullOCIRejectsCorruptLayer(t *testing.T) {
+	registry := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	srv := httptest.NewServer(registry)
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/acme/llama3:latest"
+
+	pusher := NewManager(filepath.Join(t.TempDir(), "models"))
+	writeGGUF(t, pusher.modelsPath, "llama3.gguf", ggufKV{"general.architecture", "llama"})
+	if err := pusher.PushModel("llama3", ref); err != nil {
+		t.Fatalf("PushModel: %v", err)
+	}
+
+	// Append a byte to the layer, after the end of the gzip stream
+	for d, data := range registry.blobs {
+		if len(data) > 0 && data[0] == 0x1f {
+			registry.blobs[d] = append(data, 0)
+		}
+	}
+
+	puller := NewManager(filepath.Join(t.TempDir(), "models"))
+	if err := os.MkdirAll(puller.modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := puller.PullFromOCI(ref); err == nil || !strings.Contains(err.Error(), "digest verification failed") {
+		t.Fatalf("PullFromOCI() error = %v, want digest verification failure", err)
+	}
+	files, _ := os.ReadDir(puller.modelsPath)
+	if len(files) != 0 {
+		t.Errorf("models directory has %d files after a failed pull, want none", len(files))
+	}
+}
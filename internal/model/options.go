@@ -0,0 +1,243 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"colossus-cli/internal/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// modelOptionsExt is the extension of the options file shipped next to a
+// model file, e.g. "llama3.yaml" for "llama3.gguf"
+const modelOptionsExt = ".yaml"
+
+// ModelOptions are the defaults a model's options file sets for the model,
+// overriding the global defaults. Unset fields keep the global defaults.
+//
+//	context_size: 8192
+//	gpu_layers: 33
+//	system_prompt: You are a helpful assistant.
+//	chat_template: llama3
+//	stop_sequences: ["<|eot_id|>"]
+type ModelOptions struct {
+	// ContextSize is the context size in tokens
+	ContextSize int `yaml:"context_size"`
+
+	// GPULayers is the number of layers offloaded to the GPU, 0 for none
+	GPULayers *int `yaml:"gpu_layers"`
+
+	// Threads is the number of CPU threads, 0 to detect
+	Threads int `yaml:"threads"`
+
+	// NUMAMode places the threads on the NUMA nodes of multi-socket machines:
+	// "distribute" spreads them across the nodes, "isolate" pins them to one
+	// node and "numactl" keeps the CPUs set by numactl
+	NUMAMode string `yaml:"numa_mode"`
+
+	// FlashAttention computes attention with flash attention, which roughly
+	// halves the VRAM used by long contexts
+	FlashAttention *bool `yaml:"flash_attention"`
+
+	// F16KVCache stores the KV cache in F16, the default, or in F32 when false
+	F16KVCache *bool `yaml:"f16_kv_cache"`
+
+	// KVCacheType is the type the KV cache is stored in: "f16", or "q8_0" and
+	// "q4_0", which take about a half and a quarter of its memory at a small
+	// and a noticeable cost in output quality. It overrides f16_kv_cache.
+	KVCacheType string `yaml:"kv_cache_type"`
+
+	// RopeScalingType extends the context beyond the training length with
+	// "linear" or "yarn" RoPE scaling, or disables it with "none". Without
+	// it, the scaling of the model metadata is used.
+	RopeScalingType string `yaml:"rope_scaling_type"`
+
+	// RopeScale is the factor the context is extended by, e.g. 8 to use a
+	// model trained on 4096 tokens with 32768
+	RopeScale float32 `yaml:"rope_scale"`
+
+	// YarnOrigCtxLen is the context length the model was trained with, read
+	// from the model metadata when 0
+	YarnOrigCtxLen int `yaml:"yarn_orig_ctx_len"`
+
+	// YarnBetaFast and YarnBetaSlow tune the YaRN correction, 0 for the
+	// llama.cpp defaults of 32 and 1
+	YarnBetaFast float32 `yaml:"yarn_beta_fast"`
+	YarnBetaSlow float32 `yaml:"yarn_beta_slow"`
+
+	// WarmUp evaluates a short prompt after loading the model, so that its
+	// first request responds sooner. It defaults to true for GPU inference.
+	WarmUp *bool `yaml:"warm_up"`
+
+	// DefragKVThreshold is the fragmentation of the KV cache, between 0 and
+	// 1, above which it is defragmented
+	DefragKVThreshold float32 `yaml:"defrag_kv_threshold"`
+
+	// BatchSize is the maximum number of tokens decoded in one batch
+	BatchSize int `yaml:"batch_size"`
+
+	// Parallel is the maximum number of requests decoded together
+	Parallel int `yaml:"parallel"`
+
+	// SystemPrompt is used for requests that do not have a system prompt
+	SystemPrompt string `yaml:"system_prompt"`
+
+	// ChatTemplate formats chat messages into a prompt. It is the name of a
+	// built-in template, e.g. "llama3", or a Go template executed with
+	// .Messages, each of which has a .Role and a .Content, and .System.
+	ChatTemplate string `yaml:"chat_template"`
+
+	// StopSequences are used for requests that do not set stop sequences
+	StopSequences []string `yaml:"stop_sequences"`
+
+	// LoRAAdapters are applied to the model in order instead of the adapters
+	// installed for it. Relative paths are relative to the options file.
+	LoRAAdapters []LoRAAdapterOption `yaml:"lora_adapters"`
+
+	// DraftModel is the name of an installed model with the same vocabulary
+	// that proposes tokens for speculative decoding
+	DraftModel string `yaml:"draft_model"`
+
+	// DraftTokens is the number of tokens the draft model proposes per step
+	DraftTokens int `yaml:"draft_tokens"`
+
+	// Projector is the vision encoder of a multimodal model, e.g. a LLaVA
+	// mmproj file. A relative path is relative to the options file.
+	Projector string `yaml:"projector"`
+}
+
+// LoRAAdapterOption is a LoRA adapter in a model options file. A scale of 0
+// or none applies the adapter fully.
+type LoRAAdapterOption struct {
+	Path  string  `yaml:"path"`
+	Scale float32 `yaml:"scale"`
+}
+
+// ModelOptionsPath returns the path of the options file of a model file. Split
+// models share one options file, named after the model without the part suffix.
+func ModelOptionsPath(modelPath string) string {
+	dir, fileName := filepath.Split(modelPath)
+	if base, _, _, ok := ParseSplitName(fileName); ok {
+		return filepath.Join(dir, base+modelOptionsExt)
+	}
+	return filepath.Join(dir, strings.TrimSuffix(fileName, filepath.Ext(fileName))+modelOptionsExt)
+}
+
+// GetModelOptions returns the options set by the options file next to a model
+// file, or nil if the model has no options file
+func (m *Manager) GetModelOptions(modelPath string) (*ModelOptions, error) {
+	path := ModelOptionsPath(modelPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model options: %w", err)
+	}
+
+	options, err := ParseModelOptions(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model options in %s: %w", path, err)
+	}
+
+	for i, adapter := range options.LoRAAdapters {
+		if !filepath.IsAbs(adapter.Path) {
+			options.LoRAAdapters[i].Path = filepath.Join(filepath.Dir(path), adapter.Path)
+		}
+	}
+	if options.Projector != "" && !filepath.IsAbs(options.Projector) {
+		options.Projector = filepath.Join(filepath.Dir(path), options.Projector)
+	}
+	return options, nil
+}
+
+// ParseModelOptions parses and validates the contents of a model options file.
+// Unknown fields are rejected so that misspelled options are not ignored.
+func ParseModelOptions(data []byte) (*ModelOptions, error) {
+	options := &ModelOptions{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(options); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// Validate checks that the options are within their allowed ranges
+func (o *ModelOptions) Validate() error {
+	if o.ContextSize < 0 {
+		return fmt.Errorf("context_size must not be negative, got %d", o.ContextSize)
+	}
+	if o.GPULayers != nil && *o.GPULayers < 0 {
+		return fmt.Errorf("gpu_layers must not be negative, got %d", *o.GPULayers)
+	}
+	if o.Threads < 0 {
+		return fmt.Errorf("threads must not be negative, got %d", o.Threads)
+	}
+	switch o.NUMAMode {
+	case "", "distribute", "isolate", "numactl":
+	default:
+		return fmt.Errorf("numa_mode must be distribute, isolate or numactl, got %q", o.NUMAMode)
+	}
+	switch o.KVCacheType {
+	case "", "f16", "f32", "q8_0", "q4_0":
+	default:
+		return fmt.Errorf("kv_cache_type must be f16, f32, q8_0 or q4_0, got %q", o.KVCacheType)
+	}
+	switch o.RopeScalingType {
+	case "", "none", "linear", "yarn":
+	default:
+		return fmt.Errorf("rope_scaling_type must be none, linear or yarn, got %q", o.RopeScalingType)
+	}
+	if o.RopeScale < 0 {
+		return fmt.Errorf("rope_scale must not be negative, got %g", o.RopeScale)
+	}
+	if o.YarnOrigCtxLen < 0 {
+		return fmt.Errorf("yarn_orig_ctx_len must not be negative, got %d", o.YarnOrigCtxLen)
+	}
+	if o.YarnBetaFast < 0 || o.YarnBetaSlow < 0 {
+		return fmt.Errorf("yarn_beta_fast and yarn_beta_slow must not be negative")
+	}
+	if o.DefragKVThreshold < 0 || o.DefragKVThreshold > 1 {
+		return fmt.Errorf("defrag_kv_threshold must be between 0 and 1, got %g", o.DefragKVThreshold)
+	}
+	if o.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative, got %d", o.BatchSize)
+	}
+	if o.Parallel < 0 {
+		return fmt.Errorf("parallel must not be negative, got %d", o.Parallel)
+	}
+	if o.DraftTokens < 0 {
+		return fmt.Errorf("draft_tokens must not be negative, got %d", o.DraftTokens)
+	}
+	if o.DraftTokens > 0 && o.DraftModel == "" {
+		return fmt.Errorf("draft_tokens requires a draft_model")
+	}
+	for _, stop := range o.StopSequences {
+		if stop == "" {
+			return fmt.Errorf("stop_sequences must not contain empty strings")
+		}
+	}
+	for _, adapter := range o.LoRAAdapters {
+		if adapter.Path == "" {
+			return fmt.Errorf("lora_adapters must have a path")
+		}
+	}
+	if o.ChatTemplate != "" {
+		if _, err := template.Get(o.ChatTemplate); err != nil {
+			return fmt.Errorf("invalid chat_template: %w", err)
+		}
+	}
+	return nil
+}
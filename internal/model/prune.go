@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// partialDownloadExt is the suffix of files still being downloaded
+	partialDownloadExt = ".part"
+
+	// minModelFileSize is the size below which a model file is taken to be an
+	// interrupted download, as no usable model is that small
+	minModelFileSize = 1024 * 1024
+)
+
+// FindIncompleteDownloads returns the paths of the files in the models
+// directory that were likely left behind by interrupted downloads: ".part"
+// files and model files smaller than 1 MB
+func (m *Manager) FindIncompleteDownloads() ([]string, error) {
+	var paths []string
+	err := m.walkModelFiles(func(path string, info os.FileInfo) {
+		if strings.HasSuffix(info.Name(), partialDownloadExt) ||
+			(IsValidModelFormat(info.Name()) && info.Size() < minModelFileSize) {
+			paths = append(paths, path)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan models: %w", err)
+	}
+	return paths, nil
+}
+
+// FindInvalidModels returns the paths of the model files that fail validation,
+// leaving out those FindIncompleteDownloads returns
+func (m *Manager) FindInvalidModels() ([]string, error) {
+	var paths []string
+	err := m.walkModelFiles(func(path string, info os.FileInfo) {
+		if !IsValidModelFormat(info.Name()) || info.Size() < minModelFileSize {
+			return
+		}
+		if validation, err := ValidateModel(path); err != nil || !validation.Valid {
+			paths = append(paths, path)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan models: %w", err)
+	}
+	return paths, nil
+}
+
+// RemoveModelFile removes one file from the models directory, such as an
+// incomplete download
+func (m *Manager) RemoveModelFile(path string) error {
+	rel, err := filepath.Rel(m.modelsPath, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("not in the models directory: %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	m.invalidateModelCache(path)
+	return nil
+}
+
+// walkModelFiles calls fn for every regular file in the models directory. A
+// missing models directory has no files.
+func (m *Manager) walkModelFiles(fn func(path string, info os.FileInfo)) error {
+	err := filepath.Walk(m.modelsPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			fn(path, info)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeSizedFile creates a file of size bytes under dir that starts with
+// content, returning its path
+func writeSizedFile(t *testing.T, dir, name, content string, size int64) string {
+	t.Helper()
+	path := writeModel(t, dir, name, content)
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindIncompleteDownloads(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name       string
+		content    string
+		size       int64
+		incomplete bool
+		invalid    bool
+	}{
+		{name: "empty.gguf", size: 0, incomplete: true},
+		{name: "tiny.gguf", size: 100, incomplete: true},
+		{name: "almost.gguf", size: minModelFileSize - 1, incomplete: true},
+		{name: "org_repo/model.Q4_K_M.gguf", size: 10, incomplete: true},
+		{name: "llama3.gguf.part", size: 2 * minModelFileSize, incomplete: true},
+		{name: "llama3.gguf", content: string(ggufFile(ggufKV{"general.file_type", uint32(15)})), size: minModelFileSize},
+		{name: "corrupt.gguf", content: "not a model", size: 2 * minModelFileSize, invalid: true},
+		{name: "notes.txt", size: 10},
+	}
+
+	var wantIncomplete, wantInvalid []string
+	for _, f := range files {
+		path := writeSizedFile(t, dir, f.name, f.content, f.size)
+		if f.incomplete {
+			wantIncomplete = append(wantIncomplete, path)
+		}
+		if f.invalid {
+			wantInvalid = append(wantInvalid, path)
+		}
+	}
+	sort.Strings(wantIncomplete)
+
+	m := NewManager(dir)
+
+	incomplete, err := m.FindIncompleteDownloads()
+	if err != nil {
+		t.Fatalf("FindIncompleteDownloads: %v", err)
+	}
+	sort.Strings(incomplete)
+	if !reflect.DeepEqual(incomplete, wantIncomplete) {
+		t.Errorf("FindIncompleteDownloads = %v, want %v", incomplete, wantIncomplete)
+	}
+
+	// Incomplete downloads are not reported as invalid models too
+	invalid, err := m.FindInvalidModels()
+	if err != nil {
+		t.Fatalf("FindInvalidModels: %v", err)
+	}
+	if !reflect.DeepEqual(invalid, wantInvalid) {
+		t.Errorf("FindInvalidModels = %v, want %v", invalid, wantInvalid)
+	}
+}
+
+func TestFindIncompleteDownloadsWithoutModels(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+
+	paths, err := m.FindIncompleteDownloads()
+	if err != nil || len(paths) != 0 {
+		t.Errorf("FindIncompleteDownloads = %v, %v, want nothing", paths, err)
+	}
+}
+
+func TestRemoveModelFile(t *testing.T) {
+	dir := t.TempDir()
+	modelsPath := filepath.Join(dir, "models")
+	partial := writeModel(t, modelsPath, "llama3.gguf.part", "GGUF")
+	outside := writeModel(t, dir, "config.yaml", "port: 11434")
+	m := NewManager(modelsPath)
+
+	if err := m.RemoveModelFile(partial); err != nil {
+		t.Errorf("RemoveModelFile: %v", err)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("%s not removed", partial)
+	}
+
+	for _, path := range []string{outside, modelsPath} {
+		if err := m.RemoveModelFile(path); err == nil {
+			t.Errorf("RemoveModelFile(%s) succeeded, want an error", path)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the models directory removed: %v", err)
+	}
+}
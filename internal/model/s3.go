@@ -0,0 +1,188 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"colossus-cli/internal/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// s3MaxParts is the most parts a multipart upload may have
+const s3MaxParts = 10000
+
+// S3Options configures an S3Backend
+type S3Options struct {
+	Bucket string
+	// Prefix of the object keys, e.g. "models" for "models/llama3.gguf"
+	Prefix string
+	Region string
+	// Endpoint of an S3-compatible service such as MinIO, empty for AWS
+	Endpoint string
+}
+
+// S3Backend stores models in an S3 bucket. Files are streamed to and from
+// the bucket without being written to the local disk.
+type S3Backend struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Backend creates a backend storing models in an S3 bucket, with the
+// credentials found by the AWS SDK, e.g. in AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY
+func NewS3Backend(ctx context.Context, opts S3Options) (*S3Backend, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("an S3 bucket is required")
+	}
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if opts.Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(opts.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// S3-compatible services generally only support path-style addressing
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Backend{
+		client: client,
+		bucket: opts.Bucket,
+		prefix: strings.Trim(opts.Prefix, "/"),
+	}, nil
+}
+
+// key returns the object key of a model file name
+func (b *S3Backend) key(fileName string) string {
+	return path.Join(b.prefix, fileName)
+}
+
+// Get opens the object of a model for streaming
+func (b *S3Backend) Get(name string) (io.ReadCloser, error) {
+	for _, ext := range []string{".gguf", ".bin"} {
+		out, err := b.client.GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(b.bucket),
+			Key:    aws.String(b.key(name + ext)),
+		})
+		if isS3NotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get model %s: %w", name, err)
+		}
+		return out.Body, nil
+	}
+	return nil, fmt.Errorf("model not found: %s", name)
+}
+
+// Put streams a model file into the bucket with a multipart upload. Parts are
+// sized so that files of a known size fit in the number of parts allowed.
+func (b *S3Backend) Put(name string, r io.Reader, size int64) error {
+	uploader := manager.NewUploader(b.client, func(u *manager.Uploader) {
+		if partSize := size / (s3MaxParts - 1); partSize > u.PartSize {
+			u.PartSize = partSize
+		}
+	})
+
+	_, err := uploader.Upload(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name + ".gguf")),
+		Body:   r,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload model %s: %w", name, err)
+	}
+	return nil
+}
+
+// List returns the models in the bucket under the prefix
+func (b *S3Backend) List() ([]types.ModelInfo, error) {
+	prefix := b.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var models []types.ModelInfo
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if !IsValidModelFormat(key) {
+				continue
+			}
+			name := strings.TrimPrefix(key, prefix)
+			models = append(models, types.ModelInfo{
+				Name:         strings.TrimSuffix(name, path.Ext(name)),
+				Size:         aws.ToInt64(object.Size),
+				ModifiedAt:   aws.ToTime(object.LastModified),
+				Quantization: "unknown",
+			})
+		}
+	}
+	return models, nil
+}
+
+// Delete removes the object of a model
+func (b *S3Backend) Delete(name string) error {
+	for _, ext := range []string{".gguf", ".bin"} {
+		key := aws.String(b.key(name + ext))
+		_, err := b.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(b.bucket),
+			Key:    key,
+		})
+		if isS3NotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete model %s: %w", name, err)
+		}
+
+		if _, err := b.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+			Bucket: aws.String(b.bucket),
+			Key:    key,
+		}); err != nil {
+			return fmt.Errorf("failed to delete model %s: %w", name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("model not found: %s", name)
+}
+
+// isS3NotFound reports whether an S3 request failed because the object does
+// not exist
+func isS3NotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return code == "NoSuchKey" || code == "NotFound"
+}
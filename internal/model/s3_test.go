@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory S3 service with path-style addressing
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	}
+}
+
+// list answers a ListObjectsV2 request
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type object struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []object
+	}{Name: f.bucket, Prefix: prefix}
+	for key, data := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, object{key, int64(len(data)), "2024-01-01T00:00:00.000Z"})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// newTestS3Backend creates a backend storing models under "models/" in a fake
+// S3 bucket
+func newTestS3Backend(t *testing.T) (*S3Backend, *fakeS3) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	fake := &fakeS3{bucket: "colossus", objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	backend, err := NewS3Backend(context.Background(), S3Options{Bucket: "colossus", Prefix: "/models/", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend, fake
+}
+
+func TestS3Backend(t *testing.T) {
+	backend, fake := newTestS3Backend(t)
+	content := string(ggufFile(ggufKV{"general.architecture", "llama"}))
+
+	if err := backend.Put("llama3", strings.NewReader(content), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.objects["models/llama3.gguf"]; !ok {
+		t.Fatalf("objects = %v, want models/llama3.gguf", fake.objects)
+	}
+	fake.objects["models/README.md"] = []byte("not a model")
+
+	models, err := backend.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "llama3" || models[0].Size != int64(len(content)) || !models[0].ModifiedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("List() = %+v, want llama3", models)
+	}
+
+	body, err := backend.Get("llama3")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != content {
+		t.Error("Get() returned different content than was put")
+	}
+
+	if err := backend.Delete("llama3"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := backend.Get("llama3"); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Get() after Delete error = %v, want model not found", err)
+	}
+	if err := backend.Delete("llama3"); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Delete() of a missing model error = %v, want model not found", err)
+	}
+}
+
+func TestPullToStorage(t *testing.T) {
+	backend, fake := newTestS3Backend(t)
+	content := string(ggufFile(ggufKV{"general.file_type", uint32(7)}))
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/models/acme/llama3":
+			io.WriteString(w, `{"id":"acme/llama3","siblings":[{"rfilename":"llama3.Q4_K_M.gguf"},{"rfilename":"llama3.Q8_0.gguf"}]}`)
+		case "/acme/llama3/resolve/main/llama3.Q8_0.gguf":
+			io.WriteString(w, content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer hf.Close()
+
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	m.hfRegistry.BaseURL = hf.URL
+	m.SetStorage(backend)
+
+	if err := m.PullModelWithProgress(context.Background(), "acme/llama3:q8_0", nil); err != nil {
+		t.Fatalf("PullModelWithProgress: %v", err)
+	}
+	if got := string(fake.objects["models/acme_llama3-q8_0.gguf"]); got != content {
+		t.Errorf("objects = %v, want the pulled model as models/acme_llama3-q8_0.gguf", fake.objects)
+	}
+
+	models, err := m.ListModels()
+	if err != nil || len(models) != 1 || models[0].Name != "acme_llama3-q8_0" {
+		t.Errorf("ListModels() = %+v, %v, want the model in the bucket", models, err)
+	}
+	if err := m.RemoveModel("acme_llama3-q8_0"); err != nil || len(fake.objects) != 0 {
+		t.Errorf("RemoveModel() = %v, objects = %v, want the model deleted from the bucket", err, fake.objects)
+	}
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// splitPattern matches the part files of a split GGUF model, e.g.
+// "model-00001-of-00004.gguf"
+var splitPattern = regexp.MustCompile(`^(.+)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// ParseSplitName returns the base name, 1-based part number and part count of
+// a split GGUF file name. ok is false for files that are not split parts.
+func ParseSplitName(fileName string) (base string, part, count int, ok bool) {
+	matches := splitPattern.FindStringSubmatch(fileName)
+	if matches == nil {
+		return "", 0, 0, false
+	}
+
+	part, _ = strconv.Atoi(matches[2])
+	count, _ = strconv.Atoi(matches[3])
+	if part < 1 || count < 1 || part > count {
+		return "", 0, 0, false
+	}
+
+	return matches[1], part, count, true
+}
+
+// SplitPartPath returns the path of one part of a split model
+func SplitPartPath(basePath string, part, count int) string {
+	return fmt.Sprintf("%s-%05d-of-%05d.gguf", basePath, part, count)
+}
+
+// FindSplitParts returns the paths of all parts of the split model that path
+// belongs to, in order. It returns an error if any part is missing.
+func FindSplitParts(path string) ([]string, error) {
+	base, _, count, ok := ParseSplitName(filepath.Base(path))
+	if !ok {
+		return nil, fmt.Errorf("not a split model file: %s", path)
+	}
+
+	basePath := filepath.Join(filepath.Dir(path), base)
+	parts := make([]string, 0, count)
+	var missing []int
+	for i := 1; i <= count; i++ {
+		partPath := SplitPartPath(basePath, i, count)
+		if _, err := os.Stat(partPath); err != nil {
+			missing = append(missing, i)
+			continue
+		}
+		parts = append(parts, partPath)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("split model %s is incomplete: missing parts %v of %d", base, missing, count)
+	}
+
+	return parts, nil
+}
+
+// findSplitModel returns the parts of a split model stored under the given
+// base path (without the part suffix), if one exists
+func findSplitModel(basePath string) ([]string, error) {
+	matches, err := filepath.Glob(basePath + "-00001-of-*.gguf")
+	if err != nil || len(matches) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	for _, match := range matches {
+		if base, _, _, ok := ParseSplitName(filepath.Base(match)); ok && base == filepath.Base(basePath) {
+			return FindSplitParts(match)
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSplitName(t *testing.T) {
+	tests := []struct {
+		fileName  string
+		base      string
+		part      int
+		count     int
+		wantSplit bool
+	}{
+		{fileName: "llama-70b-00001-of-00004.gguf", base: "llama-70b", part: 1, count: 4, wantSplit: true},
+		{fileName: "llama-70b-00004-of-00004.gguf", base: "llama-70b", part: 4, count: 4, wantSplit: true},
+		{fileName: "model.Q4_K_M-00002-of-00003.gguf", base: "model.Q4_K_M", part: 2, count: 3, wantSplit: true},
+		{fileName: "llama-70b.gguf"},
+		{fileName: "llama-70b-00005-of-00004.gguf"},
+		{fileName: "llama-70b-00000-of-00004.gguf"},
+		{fileName: "llama-70b-1-of-4.gguf"},
+		{fileName: "llama-70b-00001-of-00004.bin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			base, part, count, ok := ParseSplitName(tt.fileName)
+			if ok != tt.wantSplit || base != tt.base || part != tt.part || count != tt.count {
+				t.Errorf("ParseSplitName = %q, %d, %d, %v, want %q, %d, %d, %v",
+					base, part, count, ok, tt.base, tt.part, tt.count, tt.wantSplit)
+			}
+		})
+	}
+}
+
+// writeSplitModel creates the given parts of a split model of count parts,
+// each of size bytes
+func writeSplitModel(t *testing.T, dir, base string, count int, parts []int, size int) {
+	t.Helper()
+	for _, part := range parts {
+		path := SplitPartPath(filepath.Join(dir, base), part, count)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindSplitParts(t *testing.T) {
+	dir := t.TempDir()
+	writeSplitModel(t, dir, "complete", 3, []int{1, 2, 3}, 16)
+	writeSplitModel(t, dir, "incomplete", 3, []int{1, 3}, 16)
+
+	parts, err := FindSplitParts(filepath.Join(dir, "complete-00002-of-00003.gguf"))
+	if err != nil {
+		t.Fatalf("FindSplitParts: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "complete-00001-of-00003.gguf"),
+		filepath.Join(dir, "complete-00002-of-00003.gguf"),
+		filepath.Join(dir, "complete-00003-of-00003.gguf"),
+	}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+
+	_, err = FindSplitParts(filepath.Join(dir, "incomplete-00001-of-00003.gguf"))
+	if err == nil || !strings.Contains(err.Error(), "missing parts [2] of 3") {
+		t.Errorf("error = %v, want missing part 2", err)
+	}
+
+	if _, err := FindSplitParts(filepath.Join(dir, "model.gguf")); err == nil {
+		t.Error("FindSplitParts accepted a file that is not split")
+	}
+}
+
+func TestSplitModelIsOneLogicalModel(t *testing.T) {
+	dir := t.TempDir()
+	writeSplitModel(t, dir, "llama-70b", 3, []int{1, 2, 3}, 100)
+
+	manager := NewManager(dir)
+	models, err := manager.ListModels()
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("listed %d models, want the split model once: %+v", len(models), models)
+	}
+	if models[0].Name != "llama-70b" || models[0].Size != 300 {
+		t.Errorf("listed %s of %d bytes, want llama-70b of 300 bytes", models[0].Name, models[0].Size)
+	}
+
+	// The model is loaded from its first part, which finds the others
+	path, err := manager.GetModelPath("llama-70b")
+	if err != nil {
+		t.Fatalf("GetModelPath: %v", err)
+	}
+	if want := filepath.Join(dir, "llama-70b-00001-of-00003.gguf"); path != want {
+		t.Errorf("GetModelPath = %s, want %s", path, want)
+	}
+}
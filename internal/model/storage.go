@@ -0,0 +1,206 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"colossus-cli/internal/registry"
+	"colossus-cli/internal/types"
+)
+
+// StorageBackend stores model files. Models are named without their file
+// extension, e.g. "llama3" for "llama3.gguf".
+type StorageBackend interface {
+	// Get opens a model file for reading
+	Get(name string) (io.ReadCloser, error)
+
+	// Put stores a model file read from r. size is the length of the file,
+	// or -1 when unknown.
+	Put(name string, r io.Reader, size int64) error
+
+	List() ([]types.ModelInfo, error)
+	Delete(name string) error
+}
+
+// LocalBackend stores models in a local models directory, which is where the
+// Manager keeps models when no other backend is set
+type LocalBackend struct {
+	manager *Manager
+}
+
+// NewLocalBackend creates a backend storing models in modelsPath
+func NewLocalBackend(modelsPath string) *LocalBackend {
+	return &LocalBackend{manager: NewManager(modelsPath)}
+}
+
+// Get opens the file of a model, resolved like models to be loaded
+func (b *LocalBackend) Get(name string) (io.ReadCloser, error) {
+	path, err := b.manager.GetModelPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Put writes a model file to the models directory. The file is written to a
+// .part file first, so that an interrupted write leaves no partial model.
+func (b *LocalBackend) Put(name string, r io.Reader, size int64) error {
+	path := filepath.Join(b.manager.modelsPath, name+".gguf")
+	if filepath.Dir(path) != filepath.Clean(b.manager.modelsPath) {
+		return fmt.Errorf("invalid model name: %q", name)
+	}
+	if err := b.manager.ensureDiskSpace(size); err != nil {
+		return err
+	}
+
+	tmpPath := path + partialDownloadExt
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create model file: %w", err)
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write model file: %w", err)
+	}
+
+	b.manager.invalidateModelCache(path)
+	return nil
+}
+
+// List returns the models in the models directory
+func (b *LocalBackend) List() ([]types.ModelInfo, error) {
+	return b.manager.ListModels()
+}
+
+// Delete removes a model from the models directory
+func (b *LocalBackend) Delete(name string) error {
+	return b.manager.RemoveModel(name)
+}
+
+// pullToStorage streams a model from its download URL into the storage
+// backend, without writing it to the local disk
+func (m *Manager) pullToStorage(ctx context.Context, ref string, progressCallback ProgressCallback) error {
+	if IsOCIReference(ref) {
+		return fmt.Errorf("models cannot be pulled from OCI registries into the storage backend: %s", ref)
+	}
+	if IsCivitaiReference(ref) {
+		return fmt.Errorf("models cannot be pulled from Civitai into the storage backend: %s", ref)
+	}
+	name, tag := ParseModelTag(ref)
+	logger.Infof("Pulling model %s:%s into the storage backend", name, tag)
+
+	urls, err := m.downloadURLs(name, tag)
+	if err != nil {
+		return err
+	}
+
+	// The first URL that can be downloaded is used
+	var resp *http.Response
+	for _, url := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if m.hfRegistry.Token != "" && strings.HasPrefix(url, m.hfRegistry.BaseURL+"/") {
+			req.Header.Set("Authorization", "Bearer "+m.hfRegistry.Token)
+		}
+		resp, err = http.DefaultClient.Do(req)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("failed to download: %s", resp.Status)
+		}
+		logger.Warnf("Failed to download from %s: %v", url, err)
+		resp = nil
+	}
+	if resp == nil {
+		return fmt.Errorf("failed to download model %s:%s", name, tag)
+	}
+	defer resp.Body.Close()
+
+	// Stored like files downloaded for the tag, e.g. "llama3-q8_0"
+	storedName := strings.TrimSuffix(filepath.Base(m.taggedFilePath(strings.ReplaceAll(name, "/", "_"), tag)), ".gguf")
+
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		if progressCallback != nil && resp.ContentLength > 0 {
+			err = m.copyWithProgress(ctx, resp.Body, pw, resp.ContentLength, ref, storedName, progressCallback)
+		} else {
+			_, err = io.Copy(pw, resp.Body)
+		}
+		pw.CloseWithError(err)
+	}()
+	err = m.storage.Put(storedName, pr, resp.ContentLength)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Successfully pulled model %s:%s into the storage backend as %s", name, tag, storedName)
+	return nil
+}
+
+// downloadURLs returns the URLs a model can be downloaded from for a tag, in
+// the order they are tried in: the popular GGUF repositories, the predefined
+// URLs and then the best matching GGUF file on Hugging Face
+func (m *Manager) downloadURLs(name, tag string) ([]string, error) {
+	var urls []string
+	for _, url := range popularGGUFRepositories[strings.ToLower(name)] {
+		if urlMatchesTag(url, tag) {
+			urls = append(urls, url)
+		}
+	}
+	if url := m.getModelURL(name); url != "" && urlMatchesTag(url, tag) {
+		urls = append(urls, url)
+	}
+	if len(urls) > 0 {
+		return urls, nil
+	}
+
+	modelID := name
+	if !strings.Contains(name, "/") {
+		results, err := m.hfRegistry.SearchModels(name, registry.SearchOptions{
+			Limit:     1,
+			Sort:      "downloads",
+			Direction: "desc",
+			GGUFOnly:  true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for model: %w", err)
+		}
+		if len(results.Models) == 0 {
+			return nil, fmt.Errorf("model not found: %s", name)
+		}
+		modelID = results.Models[0].ID
+	}
+
+	files, err := m.hfRegistry.ListGGUFFiles(modelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", modelID, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no GGUF files found for model %s", modelID)
+	}
+	file := m.hfRegistry.SelectBestGGUF(files)
+	if tag != DefaultTag {
+		if file, err = selectTaggedGGUF(files, tag); err != nil {
+			return nil, fmt.Errorf("%s: %w", modelID, err)
+		}
+	}
+	return []string{fmt.Sprintf("%s/%s/resolve/main/%s", m.hfRegistry.BaseURL, modelID, file.RFileName)}, nil
+}
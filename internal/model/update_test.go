@@ -0,0 +1,145 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/registry"
+)
+
+// fakeHFRepository serves a Hugging Face repository with one GGUF file, whose
+// content and modification time can be changed, and a model card
+type fakeHFRepository struct {
+	mu           sync.Mutex
+	content      string
+	card         string
+	lastModified time.Time
+	infoRequests int
+	cardRequests int
+}
+
+func (f *fakeHFRepository) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/models/acme/llama3":
+		f.infoRequests++
+		json.NewEncoder(w).Encode(registry.ModelInfo{
+			ID:           "acme/llama3",
+			LastModified: f.lastModified,
+			Siblings:     []registry.FileInfo{{RFileName: "llama3.Q8_0.gguf"}},
+		})
+	case "/acme/llama3/resolve/main/llama3.Q8_0.gguf":
+		w.Write([]byte(f.content))
+	case "/acme/llama3/raw/main/README.md":
+		f.cardRequests++
+		w.Write([]byte(f.card))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// update changes the repository's file
+func (f *fakeHFRepository) update(content string, modified time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content, f.lastModified = content, modified
+}
+
+func TestUpdateModel(t *testing.T) {
+	repo := &fakeHFRepository{
+		content:      string(ggufFile(ggufKV{"general.file_type", uint32(7)})),
+		lastModified: time.Now().Add(-time.Hour),
+	}
+	srv := httptest.NewServer(repo)
+	defer srv.Close()
+
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	m.hfRegistry.BaseURL = srv.URL
+	if err := os.MkdirAll(m.modelsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PullModelWithProgress(context.Background(), "acme/llama3:q8_0", nil); err != nil {
+		t.Fatalf("PullModelWithProgress: %v", err)
+	}
+
+	if update, err := m.CheckForUpdate("acme/llama3:q8_0"); err != nil || update {
+		t.Fatalf("CheckForUpdate() = %t, %v, want no update", update, err)
+	}
+
+	// The result of the last check is used until the interval has passed
+	newContent := string(ggufFile(ggufKV{"general.file_type", uint32(7)}, ggufKV{"general.name", "llama3 v2"}))
+	repo.update(newContent, time.Now().Add(time.Minute))
+	requests := repo.infoRequests
+	if update, err := m.CheckForUpdate("acme/llama3:q8_0"); err != nil || update {
+		t.Errorf("CheckForUpdate() within the interval = %t, %v, want the cached result", update, err)
+	}
+	if repo.infoRequests != requests {
+		t.Errorf("repository checked %d times within the interval", repo.infoRequests-requests)
+	}
+
+	m.SetUpdateCheckInterval(0)
+	updated, err := m.UpdateModel(context.Background(), "acme/llama3:q8_0", nil)
+	if err != nil || !updated {
+		t.Fatalf("UpdateModel() = %t, %v, want the model updated", updated, err)
+	}
+	path, err := m.GetModelPath("acme/llama3:q8_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != newContent {
+		t.Error("model file was not replaced by the updated one")
+	}
+
+	// The update made the model up to date
+	if updated, err := m.UpdateModel(context.Background(), "acme/llama3:q8_0", nil); err != nil || updated {
+		t.Errorf("UpdateModel() after updating = %t, %v, want no update", updated, err)
+	}
+
+	if refs, err := m.PulledModels(); err != nil || !reflect.DeepEqual(refs, []string{"acme/llama3:q8_0"}) {
+		t.Errorf("PulledModels() = %v, %v", refs, err)
+	}
+}
+
+func TestCheckForUpdateOfModelNotFromHuggingFace(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "models"))
+	path := writeGGUF(t, m.modelsPath, "llama3.gguf")
+	if err := m.recordPull("llama3", "latest", path, "ghcr.io/acme/llama3@sha256:0123", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.CheckForUpdate("llama3"); err == nil {
+		t.Error("CheckForUpdate() succeeded for a model pulled from an OCI registry")
+	}
+	if _, err := m.CheckForUpdate("llama3:q8_0"); err == nil {
+		t.Error("CheckForUpdate() succeeded for a tag that was not pulled")
+	}
+}
+
+func TestHFModelID(t *testing.T) {
+	m := NewManager(t.TempDir())
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: m.hfRegistry.BaseURL + "/TheBloke/Llama-2-7B-GGUF/resolve/main/llama-2-7b.Q4_K_M.gguf", want: "TheBloke/Llama-2-7B-GGUF"},
+		{source: "TheBloke/Llama-2-7B-GGUF/llama-2-7b.Q4_K_M.gguf", want: "TheBloke/Llama-2-7B-GGUF"},
+		{source: "https://example.com/llama3.gguf", want: ""},
+		{source: "ghcr.io/acme/llama3@sha256:0123", want: ""},
+		{source: "llama3.gguf", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := m.hfModelID(tt.source); got != tt.want {
+			t.Errorf("hfModelID(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
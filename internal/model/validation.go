@@ -1,7 +1,10 @@
 package model
 
 import (
+	"bufio"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -49,6 +52,107 @@ type ModelInfo struct {
 	VocabSize   int
 	Valid       bool
 	Error       string
+	
+	// GGUF hyperparameters, zero when not present in the file
+	EmbeddingLength   int
+	FeedForwardLength int
+	AttentionHeads    int
+	AttentionHeadsKV  int
+	Layers            int
+	TensorCount       int64
+	
+	// GGUF tokenizer and quantization details
+	TokenizerModel      string
+	Quantization        string // e.g. "Q4_K_M", empty when unknown
+	QuantizationVersion int
+	BOSTokenID          int
+	EOSTokenID          int
+	
+	// RoPE scaling the model was trained for, e.g. "yarn", with its factor
+	// and the context length before scaling; empty when not present
+	RopeScalingType          string
+	RopeScalingFactor        float64
+	RopeScalingOrigCtxLength int
+	
+	// Metadata holds every GGUF metadata key-value pair. Arrays are stored
+	// as GGUFArray values.
+	Metadata map[string]interface{}
+	
+	// Tensors lists the GGUF tensor index, in file order
+	Tensors []GGUFTensorInfo
+}
+
+// GGUFTensorInfo is an entry of the GGUF tensor index
+type GGUFTensorInfo struct {
+	Name   string   `json:"name"`
+	Shape  []uint64 `json:"shape"`
+	Type   uint32   `json:"type"`
+	Offset uint64   `json:"offset"`
+}
+
+// Elements returns the number of values in the tensor
+func (t *GGUFTensorInfo) Elements() int64 {
+	elements := int64(1)
+	for _, dim := range t.Shape {
+		elements *= int64(dim)
+	}
+	return elements
+}
+
+// TypeName returns the name of the tensor's GGML type, e.g. "Q4_K"
+func (t *GGUFTensorInfo) TypeName() string {
+	if name, ok := ggmlTypes[t.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("type %d", t.Type)
+}
+
+// GGUFArray is a GGUF metadata array. Only the first elements are kept, as
+// arrays such as the tokenizer vocabulary can be very large.
+type GGUFArray struct {
+	Type   uint32        `json:"type"`
+	Len    uint64        `json:"len"`
+	Values []interface{} `json:"values"`
+}
+
+// Limits for reading GGUF metadata arrays
+const (
+	maxGGUFArrayValues = 16       // elements kept in GGUFArray
+	maxGGUFArrayLen    = 16 << 20 // longest array accepted
+)
+
+// ggufFileTypes maps general.file_type values to quantization names
+var ggufFileTypes = map[int64]string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	7:  "Q8_0",
+	8:  "Q5_0",
+	9:  "Q5_1",
+	10: "Q2_K",
+	11: "Q3_K_S",
+	12: "Q3_K_M",
+	13: "Q3_K_L",
+	14: "Q4_K_S",
+	15: "Q4_K_M",
+	16: "Q5_K_S",
+	17: "Q5_K_M",
+	18: "Q6_K",
+	19: "IQ2_XXS",
+	20: "IQ2_XS",
+	21: "Q2_K_S",
+	22: "IQ3_XS",
+	23: "IQ3_XXS",
+	24: "IQ1_S",
+	25: "IQ4_NL",
+	26: "IQ3_S",
+	27: "IQ3_M",
+	28: "IQ2_S",
+	29: "IQ2_M",
+	30: "IQ4_XS",
+	31: "IQ1_M",
+	32: "BF16",
 }
 
 // GGUF magic number and constants
@@ -169,6 +273,7 @@ func readMagic(file *os.File) uint32 {
 // validateGGUF validates a GGUF format model
 func validateGGUF(file *os.File) (*ModelInfo, error) {
 	file.Seek(0, 0)
+	reader := bufio.NewReader(file)
 	
 	info := &ModelInfo{
 		Format: FormatGGUF,
@@ -181,7 +286,7 @@ func validateGGUF(file *os.File) (*ModelInfo, error) {
 	var tensorCount uint64
 	var metadataKVCount uint64
 	
-	if err := binary.Read(file, binary.LittleEndian, &magic); err != nil {
+	if err := binary.Read(reader, binary.LittleEndian, &magic); err != nil {
 		info.Valid = false
 		info.Error = "Failed to read magic number"
 		return info, nil
@@ -193,7 +298,7 @@ func validateGGUF(file *os.File) (*ModelInfo, error) {
 		return info, nil
 	}
 	
-	if err := binary.Read(file, binary.LittleEndian, &version); err != nil {
+	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil {
 		info.Valid = false
 		info.Error = "Failed to read version"
 		return info, nil
@@ -207,45 +312,154 @@ func validateGGUF(file *os.File) (*ModelInfo, error) {
 	
 	info.Version = fmt.Sprintf("v%d", version)
 	
-	if err := binary.Read(file, binary.LittleEndian, &tensorCount); err != nil {
+	if err := binary.Read(reader, binary.LittleEndian, &tensorCount); err != nil {
 		info.Valid = false
 		info.Error = "Failed to read tensor count"
 		return info, nil
 	}
 	
-	if err := binary.Read(file, binary.LittleEndian, &metadataKVCount); err != nil {
+	if err := binary.Read(reader, binary.LittleEndian, &metadataKVCount); err != nil {
 		info.Valid = false
 		info.Error = "Failed to read metadata count"
 		return info, nil
 	}
 	
 	// Parse metadata to extract model information
-	metadata, err := parseGGUFMetadata(file, metadataKVCount)
+	metadata, err := parseGGUFMetadata(reader, metadataKVCount)
 	if err != nil {
 		info.Valid = false
 		info.Error = fmt.Sprintf("Failed to parse metadata: %v", err)
 		return info, nil
 	}
 	
+	info.Metadata = metadata
+	info.TensorCount = int64(tensorCount)
+	
 	// Extract model information from metadata
 	if arch, ok := metadata["general.architecture"].(string); ok {
 		info.Architecture = arch
 	}
 	
-	if contextLength, ok := metadata[info.Architecture+".context_length"].(uint64); ok {
-		info.ContextSize = int(contextLength)
+	arch := info.Architecture
+	info.ContextSize = int(metadataInt(metadata, arch+".context_length", 0))
+	info.EmbeddingLength = int(metadataInt(metadata, arch+".embedding_length", 0))
+	info.FeedForwardLength = int(metadataInt(metadata, arch+".feed_forward_length", 0))
+	info.AttentionHeads = int(metadataInt(metadata, arch+".attention.head_count", 0))
+	info.AttentionHeadsKV = int(metadataInt(metadata, arch+".attention.head_count_kv", int64(info.AttentionHeads)))
+	info.Layers = int(metadataInt(metadata, arch+".block_count", 0))
+	info.BOSTokenID = int(metadataInt(metadata, "tokenizer.ggml.bos_token_id", -1))
+	info.EOSTokenID = int(metadataInt(metadata, "tokenizer.ggml.eos_token_id", -1))
+	
+	if scaling, ok := metadata[arch+".rope.scaling.type"].(string); ok {
+		info.RopeScalingType = scaling
+	}
+	info.RopeScalingFactor = metadataFloat(metadata, arch+".rope.scaling.factor", 0)
+	info.RopeScalingOrigCtxLength = int(metadataInt(metadata, arch+".rope.scaling.original_context_length", 0))
+	
+	info.VocabSize = int(metadataInt(metadata, arch+".vocab_size", 0))
+	if tokens, ok := metadata["tokenizer.ggml.tokens"].(GGUFArray); ok && info.VocabSize == 0 {
+		info.VocabSize = int(tokens.Len)
 	}
 	
-	if vocabSize, ok := metadata[info.Architecture+".vocab_size"].(uint64); ok {
-		info.VocabSize = int(vocabSize)
+	if tokenizer, ok := metadata["tokenizer.ggml.model"].(string); ok {
+		info.TokenizerModel = tokenizer
 	}
 	
-	// Estimate parameters from tensor count and model architecture
-	info.Parameters = estimateParametersFromTensors(int64(tensorCount), info.Architecture)
+	info.QuantizationVersion = int(metadataInt(metadata, "general.quantization_version", 0))
+	if fileType := metadataInt(metadata, "general.file_type", -1); fileType >= 0 {
+		info.Quantization = ggufFileTypes[fileType]
+	}
+	
+	// The tensor infos follow the metadata and give the exact parameter count
+	// and, for files without general.file_type, the quantization
+	tensors, err := parseGGUFTensorInfos(reader, tensorCount)
+	if err != nil {
+		info.Parameters = estimateParametersFromTensors(int64(tensorCount), info.Architecture)
+		return info, nil
+	}
+	
+	info.Tensors = tensors
+	
+	typeParams := make(map[uint32]int64)
+	for i := range tensors {
+		elements := tensors[i].Elements()
+		info.Parameters += elements
+		typeParams[tensors[i].Type] += elements
+	}
+	
+	if info.Quantization == "" {
+		info.Quantization = dominantTensorType(typeParams)
+	}
 	
 	return info, nil
 }
 
+// ggmlTypes maps GGML tensor types to their names
+var ggmlTypes = map[uint32]string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	6:  "Q5_0",
+	7:  "Q5_1",
+	8:  "Q8_0",
+	9:  "Q8_1",
+	10: "Q2_K",
+	11: "Q3_K",
+	12: "Q4_K",
+	13: "Q5_K",
+	14: "Q6_K",
+	15: "Q8_K",
+	16: "IQ2_XXS",
+	17: "IQ2_XS",
+	18: "IQ3_XXS",
+	19: "IQ1_S",
+	20: "IQ4_NL",
+	21: "IQ3_S",
+	22: "IQ2_S",
+	23: "IQ4_XS",
+	24: "I8",
+	25: "I16",
+	26: "I32",
+	27: "I64",
+	28: "F64",
+	29: "IQ1_M",
+	30: "BF16",
+}
+
+// dominantTensorType returns the name of the quantized tensor type holding the
+// most parameters. Unquantized types are only used when nothing is quantized,
+// as norms and embeddings are commonly kept in F32 or F16.
+func dominantTensorType(typeParams map[uint32]int64) string {
+	var best string
+	var bestParams int64
+	var fallback string
+	var fallbackParams int64
+	
+	for ggmlType, params := range typeParams {
+		name, ok := ggmlTypes[ggmlType]
+		if !ok {
+			continue
+		}
+		
+		switch name {
+		case "F32", "F16", "BF16", "F64":
+			if params > fallbackParams {
+				fallback, fallbackParams = name, params
+			}
+		default:
+			if params > bestParams {
+				best, bestParams = name, params
+			}
+		}
+	}
+	
+	if best != "" {
+		return best
+	}
+	return fallback
+}
+
 // validateGGML validates a GGML format model
 func validateGGML(file *os.File) (*ModelInfo, error) {
 	file.Seek(0, 0)
@@ -364,16 +578,16 @@ func validateONNX(file *os.File) (*ModelInfo, error) {
 
 // Helper functions
 
-func parseGGUFMetadata(file *os.File, kvCount uint64) (map[string]interface{}, error) {
+func parseGGUFMetadata(r io.Reader, kvCount uint64) (map[string]interface{}, error) {
 	metadata := make(map[string]interface{})
 	
 	for i := uint64(0); i < kvCount; i++ {
-		key, err := readGGUFString(file)
+		key, err := readGGUFString(r)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read metadata key: %w", err)
 		}
 		
-		value, err := readGGUFValue(file)
+		value, err := readGGUFValue(r)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read metadata value for key %s: %w", key, err)
 		}
@@ -384,9 +598,9 @@ func parseGGUFMetadata(file *os.File, kvCount uint64) (map[string]interface{}, e
 	return metadata, nil
 }
 
-func readGGUFString(file *os.File) (string, error) {
+func readGGUFString(r io.Reader) (string, error) {
 	var length uint64
-	if err := binary.Read(file, binary.LittleEndian, &length); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
 		return "", err
 	}
 	
@@ -395,60 +609,184 @@ func readGGUFString(file *os.File) (string, error) {
 	}
 	
 	data := make([]byte, length)
-	if _, err := io.ReadFull(file, data); err != nil {
+	if _, err := io.ReadFull(r, data); err != nil {
 		return "", err
 	}
 	
 	return string(data), nil
 }
 
-func readGGUFValue(file *os.File) (interface{}, error) {
+func readGGUFValue(r io.Reader) (interface{}, error) {
 	var valueType uint32
-	if err := binary.Read(file, binary.LittleEndian, &valueType); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &valueType); err != nil {
 		return nil, err
 	}
 	
+	return readGGUFTypedValue(r, valueType)
+}
+
+func readGGUFTypedValue(r io.Reader, valueType uint32) (interface{}, error) {
 	switch valueType {
 	case GGUFTypeUint8:
 		var value uint8
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeInt8:
 		var value int8
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeUint32:
 		var value uint32
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeInt32:
 		var value int32
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeUint64:
 		var value uint64
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeInt64:
 		var value int64
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeFloat32:
 		var value float32
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value, nil
 	case GGUFTypeString:
-		return readGGUFString(file)
+		return readGGUFString(r)
 	case GGUFTypeBool:
 		var value uint8
-		binary.Read(file, binary.LittleEndian, &value)
+		binary.Read(r, binary.LittleEndian, &value)
 		return value != 0, nil
+	case GGUFTypeUint16:
+		var value uint16
+		binary.Read(r, binary.LittleEndian, &value)
+		return value, nil
+	case GGUFTypeInt16:
+		var value int16
+		binary.Read(r, binary.LittleEndian, &value)
+		return value, nil
+	case GGUFTypeFloat64:
+		var value float64
+		binary.Read(r, binary.LittleEndian, &value)
+		return value, nil
+	case GGUFTypeArray:
+		return readGGUFArray(r)
 	default:
 		// Skip unknown types
 		return nil, fmt.Errorf("unsupported value type: %d", valueType)
 	}
 }
 
+// parseGGUFTensorInfos reads the tensor info entries that follow the metadata
+func parseGGUFTensorInfos(r io.Reader, tensorCount uint64) ([]GGUFTensorInfo, error) {
+	// The count comes from the file, so it only sizes the initial allocation up to a limit
+	tensors := make([]GGUFTensorInfo, 0, min(tensorCount, 4096))
+	
+	for i := uint64(0); i < tensorCount; i++ {
+		name, err := readGGUFString(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tensor name: %w", err)
+		}
+		
+		var nDims uint32
+		if err := binary.Read(r, binary.LittleEndian, &nDims); err != nil {
+			return nil, err
+		}
+		if nDims > 8 {
+			return nil, fmt.Errorf("invalid tensor dimension count: %d", nDims)
+		}
+		
+		dims := make([]uint64, nDims)
+		if err := binary.Read(r, binary.LittleEndian, dims); err != nil {
+			return nil, err
+		}
+		
+		tensor := GGUFTensorInfo{Name: name, Shape: dims}
+		if err := binary.Read(r, binary.LittleEndian, &tensor.Type); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &tensor.Offset); err != nil {
+			return nil, err
+		}
+		
+		tensors = append(tensors, tensor)
+	}
+	
+	return tensors, nil
+}
+
+// readGGUFArray reads a metadata array, keeping only its first elements
+func readGGUFArray(r io.Reader) (interface{}, error) {
+	var elemType uint32
+	if err := binary.Read(r, binary.LittleEndian, &elemType); err != nil {
+		return nil, err
+	}
+	
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	
+	if length > maxGGUFArrayLen {
+		return nil, fmt.Errorf("array too long: %d elements", length)
+	}
+	
+	array := GGUFArray{Type: elemType, Len: length}
+	for i := uint64(0); i < length; i++ {
+		value, err := readGGUFTypedValue(r, elemType)
+		if err != nil {
+			return nil, err
+		}
+		if i < maxGGUFArrayValues {
+			array.Values = append(array.Values, value)
+		}
+	}
+	
+	return array, nil
+}
+
+// metadataInt returns an integer metadata value of any integer type, or def
+// when the key is missing or not an integer
+func metadataInt(metadata map[string]interface{}, key string, def int64) int64 {
+	switch value := metadata[key].(type) {
+	case uint8:
+		return int64(value)
+	case int8:
+		return int64(value)
+	case uint16:
+		return int64(value)
+	case int16:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	case int32:
+		return int64(value)
+	case uint64:
+		return int64(value)
+	case int64:
+		return value
+	default:
+		return def
+	}
+}
+
+// metadataFloat returns a float metadata value, or def when the key is
+// missing or not a float
+func metadataFloat(metadata map[string]interface{}, key string, def float64) float64 {
+	switch value := metadata[key].(type) {
+	case float32:
+		return float64(value)
+	case float64:
+		return value
+	default:
+		return def
+	}
+}
+
 func isPyTorchFile(file *os.File) bool {
 	file.Seek(0, 0)
 	header := make([]byte, 10)
@@ -495,3 +833,26 @@ func IsValidModelFormat(filename string) bool {
 func GetSupportedFormats() []string {
 	return []string{"GGUF", "GGML", "SafeTensors", "PyTorch", "ONNX"}
 }
+
+// VerifyChecksum computes the SHA256 digest of the file at path and compares
+// it against the expected hex-encoded digest
+func VerifyChecksum(path, expectedSHA256 string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+	
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	expected := strings.ToLower(strings.TrimSpace(expectedSHA256))
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filepath.Base(path), expected, actual)
+	}
+	
+	return nil
+}
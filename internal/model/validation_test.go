@@ -0,0 +1,144 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ggufKV is a metadata key-value pair of a test GGUF file
+type ggufKV struct {
+	key   string
+	value interface{}
+}
+
+// ggufTensor is a tensor of a test GGUF file
+type ggufTensor struct {
+	name  string
+	shape []uint64
+	typ   uint32
+	data  []byte
+}
+
+// ggufFile returns a GGUF v3 file without tensors holding the given metadata.
+// Values may be strings, string slices, bools, uint32, int32, uint64 or
+// float32.
+func ggufFile(metadata ...ggufKV) []byte {
+	return encodeGGUF(GGUFVersion3, nil, metadata...)
+}
+
+// encodeGGUF returns a GGUF file of the given version holding the metadata
+// and tensors. Version 1 counts and string lengths are 32 bits wide.
+func encodeGGUF(version uint32, tensors []ggufTensor, metadata ...ggufKV) []byte {
+	var buf bytes.Buffer
+	write := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	writeCount := func(n int) {
+		if version == GGUFVersion1 {
+			write(uint32(n))
+		} else {
+			write(uint64(n))
+		}
+	}
+	writeString := func(s string) {
+		writeCount(len(s))
+		write([]byte(s))
+	}
+	pad := func(alignment int) {
+		write(make([]byte, (alignment-buf.Len()%alignment)%alignment))
+	}
+
+	write(uint32(GGUFMagic), version)
+	writeCount(len(tensors))
+	writeCount(len(metadata))
+	for _, kv := range metadata {
+		writeString(kv.key)
+		switch v := kv.value.(type) {
+		case string:
+			write(uint32(GGUFTypeString))
+			writeString(v)
+		case []string:
+			write(uint32(GGUFTypeArray), uint32(GGUFTypeString))
+			writeCount(len(v))
+			for _, s := range v {
+				writeString(s)
+			}
+		case bool:
+			write(uint32(GGUFTypeBool), v)
+		case uint32:
+			write(uint32(GGUFTypeUint32), v)
+		case int32:
+			write(uint32(GGUFTypeInt32), v)
+		case uint64:
+			write(uint32(GGUFTypeUint64), v)
+		case float32:
+			write(uint32(GGUFTypeFloat32), v)
+		default:
+			panic(fmt.Sprintf("unsupported GGUF value %T", v))
+		}
+	}
+
+	offset := 0
+	for _, tensor := range tensors {
+		writeString(tensor.name)
+		write(uint32(len(tensor.shape)))
+		for _, dim := range tensor.shape {
+			writeCount(int(dim))
+		}
+		write(tensor.typ, uint64(offset))
+		offset += len(tensor.data) + (defaultGGUFAlignment-len(tensor.data)%defaultGGUFAlignment)%defaultGGUFAlignment
+	}
+	if len(tensors) > 0 {
+		pad(defaultGGUFAlignment)
+		for _, tensor := range tensors {
+			write(tensor.data)
+			pad(defaultGGUFAlignment)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeGGUF writes a GGUF file holding the given metadata to name under dir,
+// and returns its path
+func writeGGUF(t *testing.T, dir, name string, metadata ...ggufKV) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ggufFile(metadata...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateGGUF(t *testing.T) {
+	path := writeGGUF(t, t.TempDir(), "llama.gguf",
+		ggufKV{"general.architecture", "llama"},
+		ggufKV{"general.file_type", uint32(15)},
+		ggufKV{"llama.context_length", uint32(4096)},
+		ggufKV{"llama.block_count", uint32(32)},
+		ggufKV{"tokenizer.ggml.model", "llama"},
+	)
+
+	info, err := ValidateModel(path)
+	if err != nil {
+		t.Fatalf("ValidateModel: %v", err)
+	}
+	if !info.Valid || info.Format != FormatGGUF || info.Version != "v3" {
+		t.Fatalf("info = %+v, want a valid GGUF v3 file", info)
+	}
+	if info.Architecture != "llama" || info.ContextSize != 4096 || info.Layers != 32 {
+		t.Errorf("architecture %q, context %d, layers %d, want llama, 4096, 32",
+			info.Architecture, info.ContextSize, info.Layers)
+	}
+	if info.Quantization != "Q4_K_M" || info.TokenizerModel != "llama" {
+		t.Errorf("quantization %q, tokenizer %q, want Q4_K_M, llama", info.Quantization, info.TokenizerModel)
+	}
+}
@@ -0,0 +1,268 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CivitaiRegistry handles interactions with the Civitai model hub
+type CivitaiRegistry struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+
+	// MaxRetries is how many times a download request is retried after a
+	// transient error
+	MaxRetries int
+}
+
+// CivitaiModel represents a model on Civitai
+type CivitaiModel struct {
+	ID            int                   `json:"id"`
+	Name          string                `json:"name"`
+	Description   string                `json:"description"`
+	Type          string                `json:"type"`
+	NSFW          bool                  `json:"nsfw"`
+	Tags          []string              `json:"tags"`
+	Creator       CivitaiCreator        `json:"creator"`
+	Stats         CivitaiStats          `json:"stats"`
+	ModelVersions []CivitaiModelVersion `json:"modelVersions"`
+}
+
+// CivitaiCreator represents the creator of a Civitai model
+type CivitaiCreator struct {
+	Username string `json:"username"`
+}
+
+// CivitaiStats represents the download and rating counts of a Civitai model
+type CivitaiStats struct {
+	DownloadCount int     `json:"downloadCount"`
+	FavoriteCount int     `json:"favoriteCount"`
+	Rating        float64 `json:"rating"`
+}
+
+// CivitaiModelVersion represents a version of a Civitai model. The newest
+// version comes first in CivitaiModel.ModelVersions.
+type CivitaiModelVersion struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	BaseModel   string        `json:"baseModel"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	DownloadURL string        `json:"downloadUrl"`
+	Files       []CivitaiFile `json:"files"`
+}
+
+// CivitaiFile represents a file of a Civitai model version
+type CivitaiFile struct {
+	ID          int                 `json:"id"`
+	Name        string              `json:"name"`
+	SizeKB      float64             `json:"sizeKB"`
+	Type        string              `json:"type"`
+	Primary     bool                `json:"primary"`
+	Metadata    CivitaiFileMetadata `json:"metadata"`
+	Hashes      map[string]string   `json:"hashes"`
+	DownloadURL string              `json:"downloadUrl"`
+}
+
+// CivitaiFileMetadata describes the contents of a Civitai file
+type CivitaiFileMetadata struct {
+	Format string `json:"format"` // e.g., "GGUF", "SafeTensor", "PickleTensor"
+	FP     string `json:"fp"`     // e.g., "fp16"
+	Size   string `json:"size"`   // e.g., "pruned", "full"
+}
+
+// Size returns the size of the file in bytes
+func (f CivitaiFile) Size() int64 {
+	return int64(f.SizeKB * 1024)
+}
+
+// Checksum returns the SHA-256 checksum of the file, lower-cased, or an
+// empty string when Civitai does not publish one
+func (f CivitaiFile) Checksum() string {
+	for algorithm, hash := range f.Hashes {
+		if strings.EqualFold(algorithm, "SHA256") {
+			return strings.ToLower(hash)
+		}
+	}
+	return ""
+}
+
+// CivitaiSearchResult represents a page of Civitai search results
+type CivitaiSearchResult struct {
+	Models []CivitaiModel `json:"items"`
+
+	// NextCursor is the Cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// NewCivitaiRegistry creates a new Civitai registry client. The token, an
+// API key, is needed for models that require signing in to download.
+func NewCivitaiRegistry(token string) *CivitaiRegistry {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	return &CivitaiRegistry{
+		BaseURL:    "https://civitai.com",
+		Token:      token,
+		Client:     client,
+		MaxRetries: DefaultMaxRetries,
+	}
+}
+
+// SearchModels searches for models on Civitai. It returns one page of up to
+// options.Limit models, starting at options.Cursor. options.Filter restricts
+// the model type, e.g. "Checkpoint", and options.Sort takes Civitai's sort
+// orders, e.g. "Most Downloaded" or "Newest".
+func (r *CivitaiRegistry) SearchModels(query string, options SearchOptions) (*CivitaiSearchResult, error) {
+	params := url.Values{}
+	if query != "" {
+		params.Set("query", query)
+	}
+	if options.Filter != "" {
+		params.Set("types", options.Filter)
+	}
+	if options.Sort != "" {
+		params.Set("sort", options.Sort)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+
+	var page struct {
+		Items    []CivitaiModel `json:"items"`
+		Metadata struct {
+			NextCursor json.RawMessage `json:"nextCursor"`
+		} `json:"metadata"`
+	}
+	searchURL := fmt.Sprintf("%s/api/v1/models?%s", r.BaseURL, params.Encode())
+	if err := r.getJSON(searchURL, &page); err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	result := &CivitaiSearchResult{Models: page.Items}
+	if result.Models == nil {
+		result.Models = []CivitaiModel{}
+	}
+
+	// The cursor is a number or a string depending on the sort order
+	cursor := strings.Trim(string(page.Metadata.NextCursor), `"`)
+	if cursor != "null" {
+		result.NextCursor = cursor
+	}
+	return result, nil
+}
+
+// GetModelInfo retrieves a model and its versions
+func (r *CivitaiRegistry) GetModelInfo(modelID int) (*CivitaiModel, error) {
+	var model CivitaiModel
+	modelURL := fmt.Sprintf("%s/api/v1/models/%d", r.BaseURL, modelID)
+	if err := r.getJSON(modelURL, &model); err != nil {
+		return nil, fmt.Errorf("failed to get model %d: %w", modelID, err)
+	}
+	return &model, nil
+}
+
+// DownloadModel downloads a file of a model version. The download request is
+// retried after transient errors until ctx is done.
+func (r *CivitaiRegistry) DownloadModel(ctx context.Context, modelID int, file CivitaiFile, outputPath string, callback ProgressCallback) error {
+	if file.DownloadURL == "" {
+		return fmt.Errorf("file has no download URL: %s", file.Name)
+	}
+
+	// Downloads outlast the client timeout meant for API calls
+	client := &http.Client{Transport: r.Client.Transport}
+
+	resp, err := sendWithRetry(ctx, client, r.MaxRetries, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", file.DownloadURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create download request: %w", err)
+		}
+
+		if r.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("download request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("download of %s requires a Civitai API key: set CIVITAI_TOKEN", file.Name)
+	default:
+		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	// Create output directory
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Create output file
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	totalSize := resp.ContentLength
+	if totalSize <= 0 {
+		totalSize = file.Size()
+	}
+
+	// Download with progress reporting, removing the partial file of a
+	// canceled or failed download
+	err = downloadWithProgress(ctx, resp.Body, outFile, totalSize, strconv.Itoa(modelID), file.Name, file.Checksum(), callback)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+	}
+	return err
+}
+
+// getJSON decodes the JSON response of a GET request into v
+func (r *CivitaiRegistry) getJSON(requestURL string, v interface{}) error {
+	resp, err := sendWithRetry(context.Background(), r.Client, r.MaxRetries, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", requestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if r.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestCivitai starts a server answering Civitai API requests with handler,
+// and returns a registry using it
+func newTestCivitai(t *testing.T, handler http.HandlerFunc) *CivitaiRegistry {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	r := NewCivitaiRegistry("civitai_token")
+	r.BaseURL = srv.URL
+	return r
+}
+
+func TestCivitaiSearchModels(t *testing.T) {
+	var query map[string][]string
+	r := newTestCivitai(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		if r.Header.Get("Authorization") != "Bearer civitai_token" {
+			t.Errorf("Authorization = %q, want the token", r.Header.Get("Authorization"))
+		}
+
+		// The first page has a numeric cursor, the last a null one
+		cursor := `12`
+		if r.URL.Query().Get("cursor") == "12" {
+			cursor = `null`
+		}
+		w.Write([]byte(`{"items": [{"id": 1, "name": "Llama", "type": "Checkpoint"}], "metadata": {"nextCursor": ` + cursor + `}}`))
+	})
+
+	result, err := r.SearchModels("llama", SearchOptions{Filter: "Checkpoint", Sort: "Most Downloaded", Limit: 5})
+	if err != nil {
+		t.Fatalf("SearchModels: %v", err)
+	}
+	want := map[string][]string{
+		"query": {"llama"},
+		"types": {"Checkpoint"},
+		"sort":  {"Most Downloaded"},
+		"limit": {"5"},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+	if len(result.Models) != 1 || result.Models[0].Name != "Llama" || result.NextCursor != "12" {
+		t.Errorf("result = %+v, want one model and the next cursor", result)
+	}
+
+	result, err = r.SearchModels("llama", SearchOptions{Cursor: result.NextCursor})
+	if err != nil {
+		t.Fatalf("SearchModels: %v", err)
+	}
+	if result.NextCursor != "" {
+		t.Errorf("NextCursor = %q on the last page, want none", result.NextCursor)
+	}
+}
+
+func TestCivitaiGetModelInfo(t *testing.T) {
+	r := newTestCivitai(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/models/42" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":   42,
+			"name": "Tiny",
+			"modelVersions": []map[string]interface{}{{
+				"id":   7,
+				"name": "v1.0",
+				"files": []map[string]interface{}{{
+					"name":     "tiny.Q4_K_M.gguf",
+					"sizeKB":   2.5,
+					"primary":  true,
+					"metadata": map[string]string{"format": "GGUF"},
+					"hashes":   map[string]string{"SHA256": "ABCDEF"},
+				}},
+			}},
+		})
+	})
+
+	model, err := r.GetModelInfo(42)
+	if err != nil {
+		t.Fatalf("GetModelInfo: %v", err)
+	}
+	if len(model.ModelVersions) != 1 || len(model.ModelVersions[0].Files) != 1 {
+		t.Fatalf("model = %+v, want one version with one file", model)
+	}
+	file := model.ModelVersions[0].Files[0]
+	if file.Size() != 2560 || file.Checksum() != "abcdef" || file.Metadata.Format != "GGUF" {
+		t.Errorf("file = %+v, size %d, checksum %q", file, file.Size(), file.Checksum())
+	}
+
+	if _, err := r.GetModelInfo(43); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("GetModelInfo() of a missing model error = %v, want not found", err)
+	}
+}
+
+func TestCivitaiDownloadModel(t *testing.T) {
+	content := []byte("GGUF model data")
+	r := newTestCivitai(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/download/models/7":
+			w.Write(content)
+		case "/api/download/models/8":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	outputPath := filepath.Join(t.TempDir(), "models", "tiny.gguf")
+	var downloaded int64
+	file := CivitaiFile{Name: "tiny.gguf", DownloadURL: r.BaseURL + "/api/download/models/7"}
+	err := r.DownloadModel(context.Background(), 42, file, outputPath, func(progress DownloadProgress) error {
+		downloaded = progress.Downloaded
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DownloadModel: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil || string(data) != string(content) {
+		t.Errorf("downloaded %q, %v, want %q", data, err, content)
+	}
+	if downloaded != int64(len(content)) {
+		t.Errorf("progress reported %d bytes, want %d", downloaded, len(content))
+	}
+
+	// Models that require signing in ask for an API key
+	file = CivitaiFile{Name: "gated.gguf", DownloadURL: r.BaseURL + "/api/download/models/8"}
+	if err := r.DownloadModel(context.Background(), 42, file, outputPath, nil); err == nil || !strings.Contains(err.Error(), "CIVITAI_TOKEN") {
+		t.Errorf("DownloadModel() of a gated model error = %v, want a hint to set CIVITAI_TOKEN", err)
+	}
+	if err := r.DownloadModel(context.Background(), 42, CivitaiFile{Name: "none.gguf"}, outputPath, nil); err == nil {
+		t.Error("DownloadModel() of a file without a download URL succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDownloadModelCancelled(t *testing.T) {
+	const (
+		size    = 100 * 1024
+		partial = 10 * 1024
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.gguf")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The server sends the first 10KB, then waits for them to be written
+	// before cancelling the download
+	r := newTestHub(t, "org/model", "model.gguf", size, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write(bytes.Repeat([]byte("x"), partial))
+		w.(http.Flusher).Flush()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if info, err := os.Stat(outputPath); err == nil && info.Size() >= partial {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-r.Context().Done()
+	})
+
+	err := r.DownloadModel(ctx, "org/model", "model.gguf", outputPath, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("partial file exists after a cancelled download")
+	}
+}
+
+func TestDownloadWithProgressCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	err := downloadWithProgress(ctx, bytes.NewReader(make([]byte, 1024)), &out, 1024, "org/model", "model.gguf", "", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %d bytes after the download was cancelled", out.Len())
+	}
+}
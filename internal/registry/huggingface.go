@@ -1,13 +1,16 @@
 package registry
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -20,8 +23,25 @@ type HuggingFaceRegistry struct {
 	BaseURL string
 	Token   string
 	Client  *http.Client
+	
+	// MaxRetries is how many times a download request is retried after a
+	// transient error
+	MaxRetries int
+	
+	// Proxy and NoProxy are set with SetProxy. Without them, the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables apply.
+	Proxy   string
+	NoProxy []string
+	
+	// ConfirmDownload, when set, is called with the file and size of a
+	// model before it is downloaded; an error cancels the download, e.g.
+	// ErrDownloadDeclined
+	ConfirmDownload func(fileName string, size int64) error
 }
 
+// ErrDownloadDeclined is returned when a download is not confirmed
+var ErrDownloadDeclined = errors.New("download declined")
+
 // ModelInfo represents model information from Hugging Face Hub
 type ModelInfo struct {
 	ID             string    `json:"id"`
@@ -54,6 +74,9 @@ type SearchResult struct {
 	NumPages    int         `json:"numPages"`
 	PageIndex   int         `json:"pageIndex"`
 	TotalItems  int         `json:"totalItems"`
+	
+	// NextCursor is the Cursor of the next page, empty on the last page
+	NextCursor  string      `json:"nextCursor,omitempty"`
 }
 
 // DownloadProgress represents download progress information
@@ -65,6 +88,7 @@ type DownloadProgress struct {
 	Speed        int64 // bytes per second
 	ETA          time.Duration
 	Status       string
+	Checksum     string // expected SHA256 of the file, if known
 }
 
 // ProgressCallback is called during downloads to report progress
@@ -77,15 +101,96 @@ func NewHuggingFaceRegistry(token string) *HuggingFaceRegistry {
 	}
 
 	return &HuggingFaceRegistry{
-		BaseURL: "https://huggingface.co",
-		Token:   token,
-		Client:  client,
+		BaseURL:    "https://huggingface.co",
+		Token:      token,
+		Client:     client,
+		MaxRetries: DefaultMaxRetries,
 	}
 }
 
-// SearchModels searches for models on Hugging Face Hub
+// SearchModels searches for models on Hugging Face Hub. It returns one page
+// of up to options.Limit models: the page options.Page, counting from 1, or
+// the page starting at options.Cursor. The result's NextCursor continues the
+// search.
 func (r *HuggingFaceRegistry) SearchModels(query string, options SearchOptions) (*SearchResult, error) {
-	// Build search URL
+	pageURL := r.searchURL(query, options)
+	
+	// The API pages with cursors, so earlier pages are skipped by following
+	// their next links
+	page := max(options.Page, 1)
+	for i := 1; i < page; i++ {
+		_, next, err := r.searchPage(context.Background(), pageURL)
+		if err != nil {
+			return nil, err
+		}
+		if next == "" {
+			return &SearchResult{PageIndex: page}, nil
+		}
+		pageURL = next
+	}
+	
+	models, next, err := r.searchPage(context.Background(), pageURL)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Filter for GGUF models
+	filteredModels := models
+	if options.GGUFOnly {
+		filteredModels = r.filterGGUF(models)
+	}
+	
+	return &SearchResult{
+		Models:     filteredModels,
+		NumItems:   len(filteredModels),
+		PageIndex:  page,
+		TotalItems: len(filteredModels),
+		NextCursor: linkCursor(next),
+	}, nil
+}
+
+// SearchModelsAll searches for models on Hugging Face Hub, streaming the
+// results of every page, options.Limit models per request, through the
+// returned channel. The first page is fetched before returning; an error on
+// a later page is logged and ends the results. The channel is closed at the
+// end of the results or when ctx is canceled.
+func (r *HuggingFaceRegistry) SearchModelsAll(ctx context.Context, query string, options SearchOptions) (<-chan ModelInfo, error) {
+	models, next, err := r.searchPage(ctx, r.searchURL(query, options))
+	if err != nil {
+		return nil, err
+	}
+	
+	results := make(chan ModelInfo)
+	go func() {
+		defer close(results)
+		for {
+			if options.GGUFOnly {
+				models = r.filterGGUF(models)
+			}
+			for _, model := range models {
+				select {
+				case results <- model:
+				case <-ctx.Done():
+					return
+				}
+			}
+			
+			if next == "" {
+				return
+			}
+			if models, next, err = r.searchPage(ctx, next); err != nil {
+				if ctx.Err() == nil {
+					logrus.Warnf("Search stopped early: %v", err)
+				}
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// searchURL returns the URL of the first page of a search
+func (r *HuggingFaceRegistry) searchURL(query string, options SearchOptions) string {
 	searchURL := fmt.Sprintf("%s/api/models", r.BaseURL)
 	
 	params := url.Values{}
@@ -104,19 +209,32 @@ func (r *HuggingFaceRegistry) SearchModels(query string, options SearchOptions)
 	if options.Limit > 0 {
 		params.Add("limit", strconv.Itoa(options.Limit))
 	}
+	if options.Cursor != "" {
+		params.Add("cursor", options.Cursor)
+	}
 	
-	// Add model type filters for LLMs
-	params.Add("pipeline_tag", "text-generation")
-	params.Add("library", "transformers")
-	
-	if len(params) > 0 {
-		searchURL += "?" + params.Encode()
+	// Add model type filters, defaulting to LLMs
+	pipelineTag := options.PipelineTag
+	if pipelineTag == "" {
+		pipelineTag = "text-generation"
+	}
+	params.Add("pipeline_tag", pipelineTag)
+	if options.Library != "" {
+		params.Add("library", options.Library)
 	}
 	
-	// Create request
-	req, err := http.NewRequest("GET", searchURL, nil)
+	// Include the file list so GGUF repositories can be identified
+	params.Add("full", "true")
+	
+	return searchURL + "?" + params.Encode()
+}
+
+// searchPage fetches a page of search results, returning the URL of the next
+// page from the Link header, or "" on the last page
+func (r *HuggingFaceRegistry) searchPage(ctx context.Context, pageURL string) ([]ModelInfo, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create search request: %w", err)
+		return nil, "", fmt.Errorf("failed to create search request: %w", err)
 	}
 	
 	// Add authorization header if token is provided
@@ -124,42 +242,79 @@ func (r *HuggingFaceRegistry) SearchModels(query string, options SearchOptions)
 		req.Header.Set("Authorization", "Bearer "+r.Token)
 	}
 	
-	// Make request
 	resp, err := r.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
+		return nil, "", fmt.Errorf("search request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("search failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, "", fmt.Errorf("search failed with status %d: %s", resp.StatusCode, string(body))
 	}
 	
-	// Parse response
 	var models []ModelInfo
 	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
-		return nil, fmt.Errorf("failed to parse search results: %w", err)
+		return nil, "", fmt.Errorf("failed to parse search results: %w", err)
 	}
 	
-	// Filter for GGUF models
-	var filteredModels []ModelInfo
+	next := nextLink(resp.Header.Values("Link"))
+	if next != "" {
+		// Relative links are resolved against the page they came from
+		if nextURL, err := resp.Request.URL.Parse(next); err == nil {
+			next = nextURL.String()
+		}
+	}
+	return models, next, nil
+}
+
+// filterGGUF returns the models whose repositories contain GGUF files
+func (r *HuggingFaceRegistry) filterGGUF(models []ModelInfo) []ModelInfo {
+	var filtered []ModelInfo
 	for _, model := range models {
 		if r.hasGGUFFiles(model) {
-			filteredModels = append(filteredModels, model)
+			filtered = append(filtered, model)
 		}
 	}
-	
-	return &SearchResult{
-		Models:     filteredModels,
-		NumItems:   len(filteredModels),
-		TotalItems: len(filteredModels),
-	}, nil
+	return filtered
+}
+
+// nextLink returns the URL of the rel="next" link of Link headers, such as
+// <https://huggingface.co/api/models?cursor=abc>; rel="next"
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, found := strings.Cut(link, ";")
+			if !found {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if key == "rel" && strings.Trim(value, `"`) == "next" {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// linkCursor returns the cursor of a next page link, or "" if it has none
+func linkCursor(link string) string {
+	if link == "" {
+		return ""
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get("cursor")
 }
 
 // GetModelInfo retrieves detailed information about a specific model
 func (r *HuggingFaceRegistry) GetModelInfo(modelID string) (*ModelInfo, error) {
-	url := fmt.Sprintf("%s/api/models/%s", r.BaseURL, modelID)
+	// blobs=true includes file sizes in the sibling list
+	url := fmt.Sprintf("%s/api/models/%s?blobs=true", r.BaseURL, modelID)
 	
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -188,6 +343,84 @@ func (r *HuggingFaceRegistry) GetModelInfo(modelID string) (*ModelInfo, error) {
 	return &model, nil
 }
 
+// maxModelCardSize limits the size of a model card read into memory
+const maxModelCardSize = 1 << 20
+
+// GetModelCard retrieves the model card of a model, the README.md of its
+// repository, as markdown
+func (r *HuggingFaceRegistry) GetModelCard(modelID string) (string, error) {
+	url := fmt.Sprintf("%s/%s/raw/main/README.md", r.BaseURL, modelID)
+	
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("model card not found: %s", modelID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch model card: %s", resp.Status)
+	}
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxModelCardSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read model card: %w", err)
+	}
+	
+	return string(body), nil
+}
+
+// ErrInvalidToken is returned by WhoAmI when Hugging Face rejects the token
+var ErrInvalidToken = errors.New("token is invalid or expired")
+
+// WhoAmI returns the name of the user the registry's token belongs to, which
+// fails when the token is missing, invalid or expired
+func (r *HuggingFaceRegistry) WhoAmI() (string, error) {
+	url := fmt.Sprintf("%s/api/whoami", r.BaseURL)
+	
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", ErrInvalidToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	
+	var user struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("failed to parse user info: %w", err)
+	}
+	
+	return user.Name, nil
+}
+
 // ListGGUFFiles lists available GGUF files for a model
 func (r *HuggingFaceRegistry) ListGGUFFiles(modelID string) ([]FileInfo, error) {
 	model, err := r.GetModelInfo(modelID)
@@ -205,8 +438,9 @@ func (r *HuggingFaceRegistry) ListGGUFFiles(modelID string) ([]FileInfo, error)
 	return ggufFiles, nil
 }
 
-// DownloadModel downloads a specific file from a model repository
-func (r *HuggingFaceRegistry) DownloadModel(modelID, fileName, outputPath string, callback ProgressCallback) error {
+// DownloadModel downloads a specific file from a model repository. The
+// download request is retried after transient errors until ctx is done.
+func (r *HuggingFaceRegistry) DownloadModel(ctx context.Context, modelID, fileName, outputPath string, callback ProgressCallback) error {
 	// Get file information
 	files, err := r.ListGGUFFiles(modelID)
 	if err != nil {
@@ -226,21 +460,27 @@ func (r *HuggingFaceRegistry) DownloadModel(modelID, fileName, outputPath string
 		return fmt.Errorf("file not found: %s", fileName)
 	}
 	
-	// Build download URL
-	downloadURL := fmt.Sprintf("%s/%s/resolve/main/%s", r.BaseURL, modelID, fileName)
-	
-	// Create request
-	req, err := http.NewRequest("GET", downloadURL, nil)
+	// Fetch the expected checksum from the sidecar file if one is published
+	checksum, err := r.FetchChecksum(modelID, fileName)
 	if err != nil {
-		return fmt.Errorf("failed to create download request: %w", err)
+		logrus.Debugf("No checksum available for %s: %v", fileName, err)
 	}
 	
-	if r.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+r.Token)
-	}
+	// Build download URL
+	downloadURL := fmt.Sprintf("%s/%s/resolve/main/%s", r.BaseURL, modelID, fileName)
 	
-	// Make request
-	resp, err := r.Client.Do(req)
+	// Make request, retrying transient failures
+	resp, err := r.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", downloadURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create download request: %w", err)
+		}
+		
+		if r.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("download request failed: %w", err)
 	}
@@ -260,14 +500,104 @@ func (r *HuggingFaceRegistry) DownloadModel(modelID, fileName, outputPath string
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outFile.Close()
 	
-	// Download with progress reporting
-	return r.downloadWithProgress(resp.Body, outFile, targetFile.Size, modelID, fileName, callback)
+	// Download with progress reporting, removing the partial file of a
+	// canceled or failed download
+	err = downloadWithProgress(ctx, resp.Body, outFile, targetFile.Size, modelID, fileName, checksum, callback)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+	}
+	return err
+}
+
+// GetDownloadSize returns the size of a file in a model repository from the
+// Content-Length of a HEAD request, following the redirect to the storage
+// of large files
+func (r *HuggingFaceRegistry) GetDownloadSize(modelID, fileName string) (int64, error) {
+	downloadURL := fmt.Sprintf("%s/%s/resolve/main/%s", r.BaseURL, modelID, fileName)
+	
+	resp, err := r.doWithRetry(context.Background(), func() (*http.Request, error) {
+		req, err := http.NewRequest("HEAD", downloadURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if r.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("size request failed: %w", err)
+	}
+	resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("size request failed with status %d", resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("size of %s is unknown", fileName)
+	}
+	return resp.ContentLength, nil
+}
+
+// FetchChecksum retrieves the SHA256 checksum for a file from its ".sha256"
+// sidecar file in the model repository
+func (r *HuggingFaceRegistry) FetchChecksum(modelID, fileName string) (string, error) {
+	checksumURL := fmt.Sprintf("%s/%s/resolve/main/%s.sha256", r.BaseURL, modelID, fileName)
+	
+	req, err := http.NewRequest("GET", checksumURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create checksum request: %w", err)
+	}
+	
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("checksum request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checksum not found (status %d)", resp.StatusCode)
+	}
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	
+	return ParseChecksum(string(body))
+}
+
+// ParseChecksum extracts a hex-encoded SHA256 digest from the contents of a
+// checksum file in either "<digest>" or "<digest>  <filename>" form
+func ParseChecksum(content string) (string, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	
+	digest := strings.ToLower(fields[0])
+	if len(digest) != 64 {
+		return "", fmt.Errorf("invalid SHA256 checksum: %s", fields[0])
+	}
+	for _, c := range digest {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", fmt.Errorf("invalid SHA256 checksum: %s", fields[0])
+		}
+	}
+	
+	return digest, nil
 }
 
 // DownloadBestGGUF downloads the best GGUF variant for a model
-func (r *HuggingFaceRegistry) DownloadBestGGUF(modelID, outputPath string, callback ProgressCallback) (string, error) {
+func (r *HuggingFaceRegistry) DownloadBestGGUF(ctx context.Context, modelID, outputPath string, callback ProgressCallback) (string, error) {
 	files, err := r.ListGGUFFiles(modelID)
 	if err != nil {
 		return "", err
@@ -283,10 +613,21 @@ func (r *HuggingFaceRegistry) DownloadBestGGUF(modelID, outputPath string, callb
 	// Determine output filename
 	outputFile := filepath.Join(outputPath, bestFile.RFileName)
 	
+	if bestFile.Size <= 0 {
+		if size, err := r.GetDownloadSize(modelID, bestFile.RFileName); err == nil {
+			bestFile.Size = size
+		}
+	}
 	logrus.Infof("Selected GGUF file: %s (%.1f MB)", bestFile.RFileName, float64(bestFile.Size)/(1024*1024))
 	
+	if r.ConfirmDownload != nil {
+		if err := r.ConfirmDownload(bestFile.RFileName, bestFile.Size); err != nil {
+			return "", err
+		}
+	}
+	
 	// Download the file
-	err = r.DownloadModel(modelID, bestFile.RFileName, outputFile, callback)
+	err = r.DownloadModel(ctx, modelID, bestFile.RFileName, outputFile, callback)
 	if err != nil {
 		return "", err
 	}
@@ -305,6 +646,11 @@ func (r *HuggingFaceRegistry) hasGGUFFiles(model ModelInfo) bool {
 	return false
 }
 
+// SelectBestGGUF returns the GGUF file that would be downloaded for a model
+func (r *HuggingFaceRegistry) SelectBestGGUF(files []FileInfo) FileInfo {
+	return r.selectBestGGUF(files)
+}
+
 func (r *HuggingFaceRegistry) selectBestGGUF(files []FileInfo) FileInfo {
 	// Preference order: Q4_K_M > Q5_K_M > Q4_K_S > Q8_0 > others
 	preferences := []string{
@@ -323,13 +669,21 @@ func (r *HuggingFaceRegistry) selectBestGGUF(files []FileInfo) FileInfo {
 	return files[0]
 }
 
-func (r *HuggingFaceRegistry) downloadWithProgress(reader io.Reader, writer io.Writer, totalSize int64, modelID, fileName string, callback ProgressCallback) error {
+// downloadWithProgress copies a download with progress reporting, stopping
+// with ctx.Err() as soon as ctx is canceled
+func downloadWithProgress(ctx context.Context, reader io.Reader, writer io.Writer, totalSize int64, modelID, fileName, checksum string, callback ProgressCallback) error {
 	buffer := make([]byte, 32*1024) // 32KB buffer
 	var downloaded int64
 	startTime := time.Now()
 	lastUpdate := startTime
 	
 	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		
 		n, err := reader.Read(buffer)
 		if err != nil && err != io.EOF {
 			return fmt.Errorf("read error: %w", err)
@@ -362,6 +716,7 @@ func (r *HuggingFaceRegistry) downloadWithProgress(reader io.Reader, writer io.W
 					Speed:      speed,
 					ETA:        eta,
 					Status:     "downloading",
+					Checksum:   checksum,
 				}
 				
 				if err := callback(progress); err != nil {
@@ -387,6 +742,7 @@ func (r *HuggingFaceRegistry) downloadWithProgress(reader io.Reader, writer io.W
 			Speed:      0,
 			ETA:        0,
 			Status:     "completed",
+			Checksum:   checksum,
 		}
 		callback(progress)
 	}
@@ -396,8 +752,27 @@ func (r *HuggingFaceRegistry) downloadWithProgress(reader io.Reader, writer io.W
 
 // SearchOptions represents options for searching models
 type SearchOptions struct {
-	Filter    string // e.g., "text-generation"
-	Sort      string // e.g., "downloads", "created", "updated"
-	Direction string // "asc" or "desc"
-	Limit     int    // max results to return
+	Filter      string // e.g., "text-generation"
+	Sort        string // e.g., "downloads", "created", "updated"
+	Direction   string // "asc" or "desc"
+	Limit       int    // max results to return
+	PipelineTag string // e.g., "text-generation" (the default)
+	Library     string // e.g., "gguf", "transformers"
+	GGUFOnly    bool   // only return repositories containing GGUF files
+	Page        int    // page of Limit results to return, counting from 1
+	Cursor      string // where to continue a search, from SearchResult.NextCursor
+}
+
+var quantizationPattern = regexp.MustCompile(`(?i)(?:^|[-_.])(IQ\d_[A-Z]+(?:_[SML])?|Q\d_K(?:_[SML])?|Q\d_\d|Q\d|BF16|F16|F32)(?:[-_.]|$)`)
+
+// QuantizationFromFileName extracts the quantization type from a GGUF file
+// name, e.g. "Q4_K_M" for "mistral-7b-instruct.Q4_K_M.gguf". It returns an
+// empty string when the name does not include one.
+func QuantizationFromFileName(fileName string) string {
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	matches := quantizationPattern.FindAllStringSubmatch(name, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return strings.ToUpper(matches[len(matches)-1][1])
 }
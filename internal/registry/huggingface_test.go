@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetModelCard(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/org/model/raw/main/README.md":
+			w.Write([]byte("# Model\n"))
+		case "/org/private/raw/main/README.md":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewHuggingFaceRegistry("hf_token")
+	r.BaseURL = srv.URL
+
+	card, err := r.GetModelCard("org/model")
+	if err != nil || card != "# Model\n" {
+		t.Fatalf("GetModelCard() = %q, %v, want the README", card, err)
+	}
+	if auth != "Bearer hf_token" {
+		t.Errorf("Authorization = %q, want the token", auth)
+	}
+
+	if _, err := r.GetModelCard("org/missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("GetModelCard() of a missing repository error = %v, want not found", err)
+	}
+	if _, err := r.GetModelCard("org/private"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("GetModelCard() of a private repository error = %v, want the status", err)
+	}
+}
+
+func TestGetDownloadSize(t *testing.T) {
+	var methods []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/org/model/resolve/main/model.gguf", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		http.Redirect(w, r, "/storage/model.gguf", http.StatusFound)
+	})
+	mux.HandleFunc("/storage/model.gguf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4509715660")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r := NewHuggingFaceRegistry("")
+	r.BaseURL = srv.URL
+
+	size, err := r.GetDownloadSize("org/model", "model.gguf")
+	if err != nil || size != 4509715660 {
+		t.Fatalf("GetDownloadSize() = %d, %v, want the Content-Length of the redirect target", size, err)
+	}
+	if len(methods) != 1 || methods[0] != http.MethodHead {
+		t.Errorf("requests = %v, want a single HEAD request", methods)
+	}
+
+	if _, err := r.GetDownloadSize("org/model", "missing.gguf"); err == nil {
+		t.Error("GetDownloadSize() of a missing file succeeded")
+	}
+}
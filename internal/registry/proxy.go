@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// SetProxy makes requests go through the proxy at proxyURL, except requests
+// to the noProxy hosts. Entries of noProxy are host names, domain suffixes
+// such as ".example.com", IP addresses or CIDR ranges, as in NO_PROXY. With
+// an empty proxyURL the HTTP_PROXY and HTTPS_PROXY environment variables
+// apply, and noProxy, when set, replaces NO_PROXY.
+func (r *HuggingFaceRegistry) SetProxy(proxyURL string, noProxy []string) error {
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("invalid proxy URL: %q", proxyURL)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q: must be http, https or socks5", parsed.Scheme)
+		}
+	}
+
+	r.Proxy = proxyURL
+	r.NoProxy = noProxy
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = r.proxyFunc()
+	r.Client.Transport = transport
+	return nil
+}
+
+// proxyFunc returns the function choosing the proxy of each request
+func (r *HuggingFaceRegistry) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if r.Proxy == "" && len(r.NoProxy) == 0 {
+		return http.ProxyFromEnvironment
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if r.Proxy != "" {
+		cfg.HTTPProxy = r.Proxy
+		cfg.HTTPSProxy = r.Proxy
+	}
+	if len(r.NoProxy) > 0 {
+		cfg.NoProxy = strings.Join(r.NoProxy, ",")
+	}
+
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
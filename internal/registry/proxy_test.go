@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// forwardProxy is a forward proxy that answers every request itself with a
+// model card, recording the URLs it was asked for
+type forwardProxy struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (p *forwardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Requests to a proxy carry the absolute URL
+	p.urls = append(p.urls, r.URL.String())
+	w.Write([]byte("# Proxied\n"))
+}
+
+// newProxiedRegistry returns a registry for a hub that only the proxy can
+// reach. Loopback hosts are never proxied, so the hub has a made-up name.
+func newProxiedRegistry() *HuggingFaceRegistry {
+	r := NewHuggingFaceRegistry("")
+	r.BaseURL = "http://hub.colossus.test"
+	return r
+}
+
+func TestSetProxy(t *testing.T) {
+	proxy := &forwardProxy{}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	r := newProxiedRegistry()
+	if err := r.SetProxy(srv.URL, nil); err != nil {
+		t.Fatalf("SetProxy: %v", err)
+	}
+	card, err := r.GetModelCard("org/model")
+	if err != nil || card != "# Proxied\n" {
+		t.Fatalf("GetModelCard() = %q, %v, want the proxy's response", card, err)
+	}
+	if want := "http://hub.colossus.test/org/model/raw/main/README.md"; len(proxy.urls) != 1 || proxy.urls[0] != want {
+		t.Errorf("proxy requests = %q, want %q", proxy.urls, want)
+	}
+}
+
+func TestSetProxyFromEnvironment(t *testing.T) {
+	proxy := &forwardProxy{}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+	t.Setenv("HTTP_PROXY", srv.URL)
+	t.Setenv("NO_PROXY", "hub.colossus.test")
+
+	// NoProxy replaces NO_PROXY, and HTTP_PROXY still applies
+	r := newProxiedRegistry()
+	if err := r.SetProxy("", []string{"other.colossus.test"}); err != nil {
+		t.Fatalf("SetProxy: %v", err)
+	}
+	if card, err := r.GetModelCard("org/model"); err != nil || card != "# Proxied\n" {
+		t.Fatalf("GetModelCard() = %q, %v, want the proxy's response", card, err)
+	}
+	if len(proxy.urls) != 1 {
+		t.Errorf("proxy requests = %q, want 1", proxy.urls)
+	}
+}
+
+func TestProxyFuncNoProxy(t *testing.T) {
+	r := newProxiedRegistry()
+	if err := r.SetProxy("http://proxy.colossus.test:3128", []string{".internal.test", "10.0.0.0/8"}); err != nil {
+		t.Fatalf("SetProxy: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://huggingface.co/api/models", want: "http://proxy.colossus.test:3128"},
+		{url: "http://mirror.internal.test/model.gguf"},
+		{url: "http://10.1.2.3/model.gguf"},
+	}
+
+	proxy := r.proxyFunc()
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		got, err := proxy(&http.Request{URL: u})
+		if err != nil {
+			t.Fatalf("proxy(%s): %v", tt.url, err)
+		}
+		if (got == nil && tt.want != "") || (got != nil && got.String() != tt.want) {
+			t.Errorf("proxy(%s) = %v, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSetProxyRejectsInvalidURLs(t *testing.T) {
+	for _, proxyURL := range []string{"proxy.colossus.test:3128", "ftp://proxy.colossus.test", "http://"} {
+		if err := NewHuggingFaceRegistry("").SetProxy(proxyURL, nil); err == nil {
+			t.Errorf("SetProxy(%q) succeeded", proxyURL)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxRetries is how many times a failed download is retried, for at
+	// most five attempts
+	DefaultMaxRetries = 4
+
+	// retryBaseDelay is the delay before the first retry, doubled for each
+	// following retry up to retryMaxDelay
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 60 * time.Second
+)
+
+// doWithRetry sends the request made by newRequest with the registry client,
+// retrying transient failures up to maxRetries times
+func (r *HuggingFaceRegistry) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	return sendWithRetry(ctx, r.Client, r.MaxRetries, newRequest)
+}
+
+// sendWithRetry sends the request made by newRequest, retrying with
+// exponential backoff while it fails with a transient error: a network error
+// or a 5xx or 429 response. Retrying stops early when ctx is done.
+func sendWithRetry(ctx context.Context, client *http.Client, maxRetries int, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err == nil && !isTransientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		// Return the last failure as is once the retries are used up
+		if err == nil {
+			if attempt >= maxRetries {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("server returned status %d", resp.StatusCode)
+		} else if attempt >= maxRetries || !isTransientError(err) {
+			return nil, err
+		}
+
+		delay := retryDelay(attempt)
+		logrus.Warnf("Request to %s failed (%v), retrying in %s (attempt %d of %d)",
+			req.URL, err, delay.Round(time.Millisecond), attempt+2, maxRetries+1)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryDelay returns the backoff before retry number attempt+1, with up to
+// half of it randomized so that clients do not retry in lockstep
+func retryDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 6 {
+		delay = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isTransientStatus reports whether a response status is worth retrying
+func isTransientStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// isTransientError reports whether a request error is worth retrying
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
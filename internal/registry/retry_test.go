@@ -0,0 +1,142 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHub starts a server for a Hugging Face repository holding one GGUF
+// file, which download serves. It returns a registry using the server.
+func newTestHub(t *testing.T, modelID, fileName string, size int64, download http.HandlerFunc) *HuggingFaceRegistry {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/models/"+modelID, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ModelInfo{
+			ID:       modelID,
+			Siblings: []FileInfo{{RFileName: fileName, Size: size}},
+		})
+	})
+	mux.HandleFunc("/"+modelID+"/resolve/main/"+fileName, download)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	r := NewHuggingFaceRegistry("")
+	r.BaseURL = srv.URL
+	return r
+}
+
+func TestDownloadModelRetriesTransientErrors(t *testing.T) {
+	content := []byte("GGUF model data")
+
+	// The first 2 requests fail
+	var requests atomic.Int32
+	r := newTestHub(t, "org/model", "model.gguf", int64(len(content)), func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(content)
+	})
+
+	outputPath := filepath.Join(t.TempDir(), "model.gguf")
+	if err := r.DownloadModel(context.Background(), "org/model", "model.gguf", outputPath, nil); err != nil {
+		t.Fatalf("DownloadModel: %v", err)
+	}
+
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d download requests, want 3", n)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(content) {
+		t.Errorf("downloaded %q, want %q", data, content)
+	}
+}
+
+func TestDownloadModelRetryLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		maxRetries   int
+		wantRequests int32
+	}{
+		{name: "client errors are not retried", status: http.StatusForbidden, maxRetries: 4, wantRequests: 1},
+		{name: "retries are used up", status: http.StatusBadGateway, maxRetries: 1, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			r := newTestHub(t, "org/model", "model.gguf", 1, func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(tt.status)
+			})
+			r.MaxRetries = tt.maxRetries
+
+			outputPath := filepath.Join(t.TempDir(), "model.gguf")
+			if err := r.DownloadModel(context.Background(), "org/model", "model.gguf", outputPath, nil); err == nil {
+				t.Error("DownloadModel succeeded, want an error")
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("got %d download requests, want %d", n, tt.wantRequests)
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Errorf("output file exists after a failed download")
+			}
+		})
+	}
+}
+
+func TestDownloadModelRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The download is cancelled while waiting to retry the first failure
+	r := newTestHub(t, "org/model", "model.gguf", 1, func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	start := time.Now()
+	err := r.DownloadModel(ctx, "org/model", "model.gguf", filepath.Join(t.TempDir(), "model.gguf"), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed >= retryBaseDelay/2 {
+		t.Errorf("DownloadModel returned after %v, want it to stop without waiting to retry", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: time.Second},
+		{attempt: 1, max: 2 * time.Second},
+		{attempt: 3, max: 8 * time.Second},
+		{attempt: 6, max: retryMaxDelay},
+		{attempt: 40, max: retryMaxDelay},
+	}
+
+	// Up to half of the delay is random
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if delay := retryDelay(tt.attempt); delay < tt.max/2 || delay > tt.max {
+				t.Errorf("retryDelay(%d) = %v, want between %v and %v", tt.attempt, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// newPagedHub starts a server returning search results in pages of 2 models,
+// 3 pages in all, linked by cursors. The link to the second page is
+// absolute and the link to the third relative. It returns a registry using
+// the server and the number of pages served.
+func newPagedHub(t *testing.T) (*HuggingFaceRegistry, *atomic.Int32) {
+	t.Helper()
+
+	pages := map[string][]ModelInfo{
+		"":   {{ID: "org/model-1"}, {ID: "org/model-2"}},
+		"p2": {{ID: "org/model-3"}, {ID: "org/model-4"}},
+		"p3": {{ID: "org/model-5"}},
+	}
+	var served atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		models, ok := pages[cursor]
+		if r.URL.Path != "/api/models" || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		served.Add(1)
+
+		switch cursor {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/models?cursor=p2&limit=2>; rel="next"`, srv.URL))
+		case "p2":
+			w.Header().Set("Link", `</api/models?cursor=p3&limit=2>; rel="next"`)
+		}
+		json.NewEncoder(w).Encode(models)
+	}))
+	t.Cleanup(srv.Close)
+
+	r := NewHuggingFaceRegistry("")
+	r.BaseURL = srv.URL
+	return r, &served
+}
+
+func TestSearchModelsAllFollowsPages(t *testing.T) {
+	r, served := newPagedHub(t)
+
+	results, err := r.SearchModelsAll(context.Background(), "llama", SearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchModelsAll: %v", err)
+	}
+
+	var ids []string
+	for model := range results {
+		ids = append(ids, model.ID)
+	}
+	want := []string{"org/model-1", "org/model-2", "org/model-3", "org/model-4", "org/model-5"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("got models %v, want %v", ids, want)
+	}
+	if n := served.Load(); n != 3 {
+		t.Errorf("served %d pages, want 3", n)
+	}
+}
+
+func TestSearchModelsAllStopsWhenCancelled(t *testing.T) {
+	r, served := newPagedHub(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := r.SearchModelsAll(ctx, "llama", SearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchModelsAll: %v", err)
+	}
+
+	<-results
+	cancel()
+	for range results {
+	}
+	if n := served.Load(); n != 1 {
+		t.Errorf("served %d pages, want the search to stop on the first", n)
+	}
+}
+
+func TestSearchModelsPage(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    SearchOptions
+		wantIDs    []string
+		wantCursor string
+	}{
+		{name: "first page", options: SearchOptions{Limit: 2}, wantIDs: []string{"org/model-1", "org/model-2"}, wantCursor: "p2"},
+		{name: "page number", options: SearchOptions{Limit: 2, Page: 2}, wantIDs: []string{"org/model-3", "org/model-4"}, wantCursor: "p3"},
+		{name: "last page", options: SearchOptions{Limit: 2, Page: 3}, wantIDs: []string{"org/model-5"}},
+		{name: "cursor", options: SearchOptions{Limit: 2, Cursor: "p3"}, wantIDs: []string{"org/model-5"}},
+		{name: "past the end", options: SearchOptions{Limit: 2, Page: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newPagedHub(t)
+			result, err := r.SearchModels("llama", tt.options)
+			if err != nil {
+				t.Fatalf("SearchModels: %v", err)
+			}
+
+			var ids []string
+			for _, model := range result.Models {
+				ids = append(ids, model.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got models %v, want %v", ids, tt.wantIDs)
+			}
+			if result.NextCursor != tt.wantCursor {
+				t.Errorf("NextCursor = %q, want %q", result.NextCursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{name: "none"},
+		{
+			name:    "next",
+			headers: []string{`<https://huggingface.co/api/models?cursor=abc>; rel="next"`},
+			want:    "https://huggingface.co/api/models?cursor=abc",
+		},
+		{
+			name:    "several links",
+			headers: []string{`</api/models?cursor=a>; rel="prev", </api/models?cursor=b>; rel=next`},
+			want:    "/api/models?cursor=b",
+		},
+		{
+			name:    "several headers",
+			headers: []string{`</api/models?cursor=a>; rel="prev"`, `</api/models?cursor=b>; title="more"; rel="next"`},
+			want:    "/api/models?cursor=b",
+		},
+		{
+			name:    "no next link",
+			headers: []string{`</api/models?cursor=a>; rel="prev"`, `</api/models>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextLink(tt.headers)
+			if got != tt.want {
+				t.Errorf("nextLink = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && linkCursor(got) == "" {
+				t.Errorf("linkCursor(%q) is empty", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// variablePattern matches Mustache-style variables such as {{user_input}}
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// keywords are the Go template actions that look like variables
+var keywords = map[string]bool{
+	"if": true, "else": true, "end": true, "range": true, "with": true,
+	"break": true, "continue": true, "define": true, "block": true,
+	"template": true, "nil": true, "true": true, "false": true,
+}
+
+// Prompt is a prompt template with Mustache-style variables. Other Go
+// template actions, e.g. {{if .context}}...{{end}}, are supported as well.
+type Prompt struct {
+	// Variables are the names of the variables, in order of appearance
+	Variables []string
+
+	tmpl *template.Template
+}
+
+// ParsePrompt parses a prompt template
+func ParsePrompt(text string) (*Prompt, error) {
+	var variables []string
+	seen := make(map[string]bool)
+
+	// {{name}} becomes {{.name}}, a key of the variables map
+	converted := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if keywords[name] {
+			return match
+		}
+		if !seen[name] {
+			seen[name] = true
+			variables = append(variables, name)
+		}
+		return "{{." + name + "}}"
+	})
+
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(converted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return &Prompt{Variables: variables, tmpl: tmpl}, nil
+}
+
+// Render substitutes the variables of the template. Every variable must be
+// given a value, even an empty one.
+func (p *Prompt) Render(vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range p.Variables {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	var prompt strings.Builder
+	if err := p.tmpl.Execute(&prompt, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return prompt.String(), nil
+}
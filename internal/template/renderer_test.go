@@ -0,0 +1,112 @@
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		vars          map[string]string
+		wantVariables []string
+		want          string
+	}{
+		{
+			name:          "substitution",
+			text:          "Summarize this {{ kind }} in {{language}}:\n{{user_input}}",
+			vars:          map[string]string{"kind": "article", "language": "French", "user_input": "Llamas are camelids."},
+			wantVariables: []string{"kind", "language", "user_input"},
+			want:          "Summarize this article in French:\nLlamas are camelids.",
+		},
+		{
+			name:          "repeated variable",
+			text:          "{{name}}, {{name}}!",
+			vars:          map[string]string{"name": "hello"},
+			wantVariables: []string{"name"},
+			want:          "hello, hello!",
+		},
+		{
+			name:          "empty value",
+			text:          "Context: {{context}}.",
+			vars:          map[string]string{"context": ""},
+			wantVariables: []string{"context"},
+			want:          "Context: .",
+		},
+		{
+			name:          "values are not templates",
+			text:          "Echo {{text}}",
+			vars:          map[string]string{"text": "{{secret}}"},
+			wantVariables: []string{"text"},
+			want:          "Echo {{secret}}",
+		},
+		{
+			name:          "Go template actions",
+			text:          "{{if .context}}Context: {{context}}\n{{end}}Question: {{question}}",
+			vars:          map[string]string{"context": "Llamas hum.", "question": "Why?"},
+			wantVariables: []string{"context", "question"},
+			want:          "Context: Llamas hum.\nQuestion: Why?",
+		},
+		{
+			name: "no variables",
+			text: "Tell me a joke.",
+			want: "Tell me a joke.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := ParsePrompt(tt.text)
+			if err != nil {
+				t.Fatalf("ParsePrompt: %v", err)
+			}
+			if !reflect.DeepEqual(prompt.Variables, tt.wantVariables) {
+				t.Errorf("Variables = %q, want %q", prompt.Variables, tt.wantVariables)
+			}
+
+			got, err := prompt.Render(tt.vars)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPromptMissingVariables(t *testing.T) {
+	prompt, err := ParsePrompt("Translate {{text}} from {{source}} to {{target}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{name: "no values", vars: nil, wantErr: "missing template variables: source, target, text"},
+		{name: "one missing", vars: map[string]string{"text": "hola", "source": "Spanish"}, wantErr: "missing template variables: target"},
+		{name: "misspelled", vars: map[string]string{"text": "hola", "source": "Spanish", "targte": "English"}, wantErr: "missing template variables: target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prompt.Render(tt.vars)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Render = %q, %v, want error %q", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePromptInvalid(t *testing.T) {
+	for _, text := range []string{"{{if .context}}unclosed", "{{user_input", "{{end}}"} {
+		if _, err := ParsePrompt(text); err == nil || !strings.Contains(err.Error(), "invalid prompt template") {
+			t.Errorf("ParsePrompt(%q) error = %v, want an invalid template", text, err)
+		}
+	}
+}
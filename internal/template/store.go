@@ -0,0 +1,171 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// StoreFileName is the prompt template store kept next to the models
+// directory, e.g. ~/.colossus/templates.json
+const StoreFileName = "templates.json"
+
+// ErrTemplateNotFound is returned for prompt templates that do not exist
+var ErrTemplateNotFound = errors.New("prompt template not found")
+
+// Store keeps named prompt templates in a JSON file
+type Store struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewStore returns a store of prompt templates kept in the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// List returns the prompt templates, sorted by name
+func (s *Store) List() ([]types.PromptTemplate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	templates, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]types.PromptTemplate, 0, len(templates))
+	for _, t := range templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns the prompt template with the given name
+func (s *Store) Get(name string) (*types.PromptTemplate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	templates, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return &t, nil
+}
+
+// Create parses a prompt template and stores it under name, replacing any
+// template of the same name
+func (s *Store) Create(name, text string) (*types.PromptTemplate, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid template name: %q", name)
+	}
+	prompt, err := ParsePrompt(text)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	templates, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	t := types.PromptTemplate{
+		Name:      name,
+		Template:  text,
+		Variables: prompt.Variables,
+		CreatedAt: time.Now(),
+	}
+	if t.Variables == nil {
+		t.Variables = []string{}
+	}
+	templates[name] = t
+	if err := s.save(templates); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Delete removes the prompt template with the given name
+func (s *Store) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	templates, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := templates[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	delete(templates, name)
+	return s.save(templates)
+}
+
+// Render renders the named prompt template with the given variables
+func (s *Store) Render(name string, vars map[string]string) (string, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := ParsePrompt(t.Template)
+	if err != nil {
+		return "", err
+	}
+	return prompt.Render(vars)
+}
+
+// load reads the templates, keyed by name. The mutex must be held.
+func (s *Store) load() (map[string]types.PromptTemplate, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return map[string]types.PromptTemplate{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+
+	templates := make(map[string]types.PromptTemplate)
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return templates, nil
+}
+
+// save writes the templates, replacing the previous file atomically. The
+// mutex must be held.
+func (s *Store) save(templates map[string]types.PromptTemplate) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode prompt templates: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write prompt templates: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write prompt templates: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package template formats chat messages into the prompt format a model was
+// trained with
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"colossus-cli/internal/types"
+)
+
+// builtins are the named chat templates. The BOS token is left out because
+// the tokenizer adds it.
+var builtins = map[string]string{
+	"llama3": "{{range .Messages}}<|start_header_id|>{{.Role}}<|end_header_id|>\n\n{{.Content}}<|eot_id|>{{end}}" +
+		"<|start_header_id|>assistant<|end_header_id|>\n\n",
+
+	"chatml": "{{range .Messages}}<|im_start|>{{.Role}}\n{{.Content}}<|im_end|>\n{{end}}" +
+		"<|im_start|>assistant\n",
+
+	// Mistral has no system role, so the system prompt starts the first instruction
+	"mistral": `{{$system := .System}}{{range .Messages}}` +
+		`{{if eq .Role "user"}}[INST] {{if $system}}{{$system}}` + "\n\n" + `{{$system = ""}}{{end}}{{.Content}} [/INST]` +
+		`{{else if eq .Role "assistant"}} {{.Content}}</s>` +
+		`{{else if eq .Role "tool"}}[TOOL_RESULTS] {{.Content}} [/TOOL_RESULTS]{{end}}{{end}}`,
+
+	// Gemma has no system role either and calls the assistant "model"
+	"gemma": `{{$system := .System}}{{range .Messages}}` +
+		`{{if eq .Role "user"}}<start_of_turn>user` + "\n" + `{{if $system}}{{$system}}` + "\n\n" + `{{$system = ""}}{{end}}{{.Content}}<end_of_turn>` + "\n" +
+		`{{else if eq .Role "assistant"}}<start_of_turn>model` + "\n" + `{{.Content}}<end_of_turn>` + "\n" +
+		`{{else if eq .Role "tool"}}<start_of_turn>user` + "\n" + `{{.Content}}<end_of_turn>` + "\n" + `{{end}}{{end}}` +
+		"<start_of_turn>model\n",
+
+	"alpaca": `{{if .System}}{{.System}}` + "\n\n" + `{{end}}{{range .Messages}}` +
+		`{{if eq .Role "user"}}### Instruction:` + "\n" + `{{.Content}}` + "\n\n" +
+		`{{else if eq .Role "assistant"}}### Response:` + "\n" + `{{.Content}}` + "\n\n" +
+		`{{else if eq .Role "tool"}}### Input:` + "\n" + `{{.Content}}` + "\n\n" + `{{end}}{{end}}` +
+		"### Response:\n",
+}
+
+// markers identify the built-in template a GGUF chat template corresponds to
+// by a token only that format uses
+var markers = []struct {
+	marker string
+	name   string
+}{
+	{"<|start_header_id|>", "llama3"},
+	{"<|im_start|>", "chatml"},
+	{"<start_of_turn>", "gemma"},
+	{"[INST]", "mistral"},
+	{"### Instruction", "alpaca"},
+}
+
+// Template formats chat messages into a prompt
+type Template struct {
+	// Name is the name of a built-in template, or "custom"
+	Name string
+
+	tmpl *template.Template
+}
+
+// Data is what a template is executed with. Messages holds every message,
+// including system messages, while System joins the system messages for
+// formats that place them elsewhere.
+type Data struct {
+	System   string
+	Messages []types.Message
+}
+
+// Names returns the names of the built-in templates, sorted
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the built-in template with the given name, or otherwise parses
+// the text as a custom Go template
+func Get(nameOrText string) (*Template, error) {
+	if text, ok := builtins[nameOrText]; ok {
+		return &Template{Name: nameOrText, tmpl: template.Must(template.New(nameOrText).Parse(text))}, nil
+	}
+
+	// Text without actions cannot include the messages, so it is taken to be
+	// a misspelled name
+	if !strings.Contains(nameOrText, "{{") {
+		return nil, fmt.Errorf("unknown chat template %q, expected one of %s or a Go template",
+			nameOrText, strings.Join(Names(), ", "))
+	}
+
+	tmpl, err := template.New("custom").Parse(nameOrText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chat template: %w", err)
+	}
+	return &Template{Name: "custom", tmpl: tmpl}, nil
+}
+
+// Detect returns the built-in template matching a model's Jinja chat template
+// from its GGUF "tokenizer.chat_template" metadata, or nil if none matches
+func Detect(jinja string) *Template {
+	for _, m := range markers {
+		if strings.Contains(jinja, m.marker) {
+			tmpl, _ := Get(m.name)
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// Format formats chat messages into a prompt ending where the assistant's
+// reply starts
+func (t *Template) Format(messages []types.Message) (string, error) {
+	data := Data{Messages: messages}
+
+	var system []string
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+		}
+	}
+	data.System = strings.Join(system, "\n\n")
+
+	var prompt strings.Builder
+	if err := t.tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to apply %s chat template: %w", t.Name, err)
+	}
+	return prompt.String(), nil
+}
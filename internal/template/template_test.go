@@ -0,0 +1,117 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"colossus-cli/internal/types"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		jinja string
+		want  string
+	}{
+		{name: "llama3", jinja: "{% for message in messages %}<|start_header_id|>{{ message['role'] }}<|end_header_id|>", want: "llama3"},
+		{name: "chatml", jinja: "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\\n'}}", want: "chatml"},
+		{name: "gemma", jinja: "{{ '<start_of_turn>' + role + '\\n' }}", want: "gemma"},
+		{name: "mistral", jinja: "{{ '[INST] ' + message['content'] + ' [/INST]' }}", want: "mistral"},
+		{name: "alpaca", jinja: "### Instruction:\n{{ message['content'] }}", want: "alpaca"},
+		{name: "unknown", jinja: "{{ message['content'] }}"},
+		{name: "missing", jinja: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := Detect(tt.jinja)
+			if tt.want == "" {
+				if tmpl != nil {
+					t.Errorf("Detect() = %s, want no template", tmpl.Name)
+				}
+				return
+			}
+			if tmpl == nil || tmpl.Name != tt.want {
+				t.Errorf("Detect() = %v, want %s", tmpl, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	messages := []types.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello!"},
+		{Role: "user", Content: "Bye"},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{
+			name: "chatml",
+			want: "<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n" +
+				"<|im_start|>assistant\nHello!<|im_end|>\n<|im_start|>user\nBye<|im_end|>\n<|im_start|>assistant\n",
+		},
+		{
+			name: "llama3",
+			want: "<|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\nHello!<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nBye<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name: "mistral",
+			want: "[INST] Be brief.\n\nHi [/INST] Hello!</s>[INST] Bye [/INST]",
+		},
+		{
+			name: "gemma",
+			want: "<start_of_turn>user\nBe brief.\n\nHi<end_of_turn>\n<start_of_turn>model\nHello!<end_of_turn>\n" +
+				"<start_of_turn>user\nBye<end_of_turn>\n<start_of_turn>model\n",
+		},
+		{
+			name: "alpaca",
+			want: "Be brief.\n\n### Instruction:\nHi\n\n### Response:\nHello!\n\n### Instruction:\nBye\n\n### Response:\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Get(tt.name)
+			if err != nil {
+				t.Fatalf("Get(%s): %v", tt.name, err)
+			}
+			got, err := tmpl.Format(messages)
+			if err != nil {
+				t.Fatalf("Format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCustom(t *testing.T) {
+	tmpl, err := Get("{{range .Messages}}{{.Role}}: {{.Content}}\n{{end}}")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if tmpl.Name != "custom" {
+		t.Errorf("Name = %s, want custom", tmpl.Name)
+	}
+	got, err := tmpl.Format([]types.Message{{Role: "user", Content: "Hi"}})
+	if err != nil || got != "user: Hi\n" {
+		t.Errorf("Format() = %q, %v, want %q", got, err, "user: Hi\n")
+	}
+
+	if _, err := Get("chatlm"); err == nil || !strings.Contains(err.Error(), "unknown chat template") {
+		t.Errorf("Get(misspelled name) error = %v, want unknown chat template", err)
+	}
+	if _, err := Get("{{range .Messages}"); err == nil {
+		t.Error("Get accepted an invalid template")
+	}
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExporterOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		options  int
+		wantErr  bool
+	}{
+		{name: "host and port", endpoint: "localhost:4318", options: 2},
+		{name: "http URL", endpoint: "http://collector:4318", options: 2},
+		{name: "https URL", endpoint: "https://collector", options: 1},
+		{name: "URL with path", endpoint: "https://collector/otlp/v1/traces/", options: 2},
+		{name: "unsupported scheme", endpoint: "grpc://collector:4317", wantErr: true},
+		{name: "URL without host", endpoint: "http://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := exporterOptions(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if len(options) != tt.options {
+				t.Errorf("got %d options, want %d", len(options), tt.options)
+			}
+		})
+	}
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	requests := make(chan *coltracepb.ExportTraceServiceRequest, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("export to %s, want /v1/traces", r.URL.Path)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		var req coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid export request: %v", err)
+		}
+		requests <- &req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), collector.URL)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "Generate")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	// Shutting down flushes the batched span
+	var spans []string
+	service := ""
+	close(requests)
+	for req := range requests {
+		for _, resourceSpans := range req.ResourceSpans {
+			for _, attr := range resourceSpans.Resource.Attributes {
+				if attr.Key == "service.name" {
+					service = attr.Value.GetStringValue()
+				}
+			}
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, s := range scopeSpans.Spans {
+					spans = append(spans, s.Name)
+				}
+			}
+		}
+	}
+	if len(spans) != 1 || spans[0] != "Generate" {
+		t.Errorf("exported spans %v, want Generate", spans)
+	}
+	if service != serviceName {
+		t.Errorf("service.name = %q, want %q", service, serviceName)
+	}
+}
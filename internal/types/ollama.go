@@ -0,0 +1,66 @@
+package types
+
+import "time"
+
+// OllamaModelRequest names a model in the Ollama API. Older clients send the
+// name as "name", newer ones as "model".
+type OllamaModelRequest struct {
+	Model   string `json:"model"`
+	Name    string `json:"name"`
+	Stream  *bool  `json:"stream,omitempty"`
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+// ModelName returns the model named by the request
+func (r *OllamaModelRequest) ModelName() string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return r.Name
+}
+
+// OllamaCopyRequest represents an Ollama model copy request
+type OllamaCopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// OllamaModelDetails describes a model in Ollama responses
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// OllamaModel represents a model in the Ollama model list
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaTagsResponse represents the Ollama response for listing models
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaShowResponse represents the Ollama response describing a model
+type OllamaShowResponse struct {
+	Modelfile  string                 `json:"modelfile"`
+	Parameters string                 `json:"parameters,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	System     string                 `json:"system,omitempty"`
+	Details    OllamaModelDetails     `json:"details"`
+	ModelInfo  map[string]interface{} `json:"model_info"`
+	ModifiedAt time.Time              `json:"modified_at"`
+}
+
+// OllamaVersionResponse represents the Ollama server version response
+type OllamaVersionResponse struct {
+	Version string `json:"version"`
+}
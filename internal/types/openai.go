@@ -0,0 +1,226 @@
+package types
+
+import "encoding/json"
+
+// OpenAIMessage represents a chat message in the OpenAI API format
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// ToolCalls are the calls made by an assistant message, and ToolCallID
+	// the call a "tool" message holds the result of
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAITool describes a tool the model may call. Only "function" tools exist.
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction describes a function tool
+type OpenAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// OpenAIToolCall is a call to a function tool. Index is only set in
+// streaming chunks.
+type OpenAIToolCall struct {
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall holds the function called and its arguments as a JSON
+// encoded string
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAIToolChoice holds "tool_choice", which OpenAI accepts either as
+// "auto", "none" or "required", or as an object naming the function to call.
+// The object form is stored as the function name.
+type OpenAIToolChoice string
+
+// UnmarshalJSON accepts both the string and object forms
+func (c *OpenAIToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		*c = OpenAIToolChoice(mode)
+		return nil
+	}
+
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	*c = OpenAIToolChoice(named.Function.Name)
+	return nil
+}
+
+// OpenAIStringList holds fields such as "stop" and "input", which OpenAI
+// accepts either as a single string or as an array of strings
+type OpenAIStringList []string
+
+// UnmarshalJSON accepts both the string and array forms
+func (s *OpenAIStringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single != "" {
+			*s = OpenAIStringList{single}
+		}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*s = multiple
+	return nil
+}
+
+// OpenAIChatCompletionRequest represents an OpenAI chat completion request
+type OpenAIChatCompletionRequest struct {
+	Model            string           `json:"model"`
+	Messages         []OpenAIMessage  `json:"messages"`
+	Stream           bool             `json:"stream,omitempty"`
+	Temperature      *float64         `json:"temperature,omitempty"`
+	TopP             *float64         `json:"top_p,omitempty"`
+	MaxTokens        int              `json:"max_tokens,omitempty"`
+	Stop             OpenAIStringList `json:"stop,omitempty"`
+	N                int              `json:"n,omitempty"`
+	PresencePenalty  float64          `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64          `json:"frequency_penalty,omitempty"`
+	User             string           `json:"user,omitempty"`
+
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Logprobs       bool                  `json:"logprobs,omitempty"`
+	TopLogprobs    int                   `json:"top_logprobs,omitempty"`
+
+	Tools      []OpenAITool     `json:"tools,omitempty"`
+	ToolChoice OpenAIToolChoice `json:"tool_choice,omitempty"`
+}
+
+// OpenAIResponseFormat selects the output format of a chat completion:
+// "text", "json_object" or "json_schema"
+type OpenAIResponseFormat struct {
+	Type       string                  `json:"type"`
+	JSONSchema *OpenAIJSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchemaFormat describes the schema for the "json_schema" response format
+type OpenAIJSONSchemaFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+// OpenAIChatCompletionChoice represents a single completion choice
+type OpenAIChatCompletionChoice struct {
+	Index        int             `json:"index"`
+	Message      OpenAIMessage   `json:"message"`
+	Logprobs     *OpenAILogprobs `json:"logprobs,omitempty"`
+	FinishReason string          `json:"finish_reason"`
+}
+
+// OpenAILogprobs holds the log probabilities of the tokens in a choice
+type OpenAILogprobs struct {
+	Content []OpenAITokenLogprob `json:"content"`
+}
+
+// OpenAITokenLogprob holds the log probability of a single generated token
+type OpenAITokenLogprob struct {
+	Token       string             `json:"token"`
+	Logprob     float32            `json:"logprob"`
+	TopLogprobs []OpenAITopLogprob `json:"top_logprobs"`
+}
+
+// OpenAITopLogprob holds the log probability of an alternative token
+type OpenAITopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float32 `json:"logprob"`
+}
+
+// OpenAIUsage reports token usage for a completion
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// OpenAIChatCompletionResponse represents an OpenAI chat completion response
+type OpenAIChatCompletionResponse struct {
+	ID      string                       `json:"id"`
+	Object  string                       `json:"object"`
+	Created int64                        `json:"created"`
+	Model   string                       `json:"model"`
+	Choices []OpenAIChatCompletionChoice `json:"choices"`
+	Usage   *OpenAIUsage                 `json:"usage,omitempty"`
+}
+
+// OpenAIDelta represents the incremental message content of a streaming chunk
+type OpenAIDelta struct {
+	Role      string           `json:"role,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// OpenAIChatCompletionChunkChoice represents a choice within a streaming chunk
+type OpenAIChatCompletionChunkChoice struct {
+	Index        int         `json:"index"`
+	Delta        OpenAIDelta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// OpenAIChatCompletionChunk represents a streaming chat completion chunk
+type OpenAIChatCompletionChunk struct {
+	ID      string                            `json:"id"`
+	Object  string                            `json:"object"`
+	Created int64                             `json:"created"`
+	Model   string                            `json:"model"`
+	Choices []OpenAIChatCompletionChunkChoice `json:"choices"`
+}
+
+// OpenAIError describes an error in the OpenAI API format
+type OpenAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// OpenAIErrorResponse represents an error response in the OpenAI API format
+type OpenAIErrorResponse struct {
+	Error OpenAIError `json:"error"`
+}
+
+// OpenAIEmbeddingRequest represents an OpenAI embeddings request
+type OpenAIEmbeddingRequest struct {
+	Model          string           `json:"model"`
+	Input          OpenAIStringList `json:"input"`
+	EncodingFormat string           `json:"encoding_format,omitempty"`
+	User           string           `json:"user,omitempty"`
+}
+
+// OpenAIEmbedding represents a single embedding vector
+type OpenAIEmbedding struct {
+	Object    string    `json:"object"`
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// OpenAIEmbeddingResponse represents an OpenAI embeddings response
+type OpenAIEmbeddingResponse struct {
+	Object string            `json:"object"`
+	Data   []OpenAIEmbedding `json:"data"`
+	Model  string            `json:"model"`
+	Usage  OpenAIUsage       `json:"usage"`
+}
@@ -1,11 +1,17 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// Images are PNG or JPEG images for multimodal models, base64-encoded in JSON
+	Images [][]byte `json:"images,omitempty"`
 }
 
 // ChatRequest represents a chat completion request
@@ -14,14 +20,44 @@ type ChatRequest struct {
 	Messages []Message `json:"messages"`
 	Stream   bool      `json:"stream,omitempty"`
 	Options  *Options  `json:"options,omitempty"`
+
+	// JSONSchema constrains the response to JSON matching the schema
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+
+	// Tools are the functions the model may call instead of replying.
+	// ToolChoice is "auto" (the default), "none", "required" or the name of
+	// the tool that must be called.
+	Tools      []ToolDefinition `json:"tools,omitempty"`
+	ToolChoice string           `json:"tool_choice,omitempty"`
+}
+
+// ToolDefinition describes a function the model may call. Parameters is the
+// JSON schema of the function's arguments.
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a call to one of the request's tools made by the model
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
 }
 
 // ChatResponse represents a chat completion response
 type ChatResponse struct {
-	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Message   Message   `json:"message"`
-	Done      bool      `json:"done"`
+	Model     string         `json:"model"`
+	CreatedAt time.Time      `json:"created_at"`
+	Message   Message        `json:"message"`
+	Done      bool           `json:"done"`
+	Logprobs  []TokenLogprob `json:"logprobs,omitempty"`
+	ToolCalls []ToolCall     `json:"tool_calls,omitempty"`
+
+	// WarningTokensRemaining is set on the final response once the messages
+	// use more than 80% of the request's token budget, to the tokens left
+	WarningTokensRemaining int `json:"warning_tokens_remaining,omitempty"`
 }
 
 // GenerateRequest represents a generate completion request
@@ -30,15 +66,124 @@ type GenerateRequest struct {
 	Prompt  string   `json:"prompt"`
 	Stream  bool     `json:"stream,omitempty"`
 	Options *Options `json:"options,omitempty"`
+
+	// System is an instruction placed before the prompt of a new conversation
+	System string `json:"system,omitempty"`
+
+	// Images are PNG or JPEG images for multimodal models, base64-encoded in
+	// JSON. The model reads them before the prompt.
+	Images [][]byte `json:"images,omitempty"`
+
+	// JSONSchema constrains the response to JSON matching the schema
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+
+	// Context holds the tokens returned in a previous response's Context.
+	// The prompt is appended to them instead of starting a new conversation.
+	Context []int `json:"context,omitempty"`
+
+	// SessionID persists the KV cache between calls so follow-up prompts
+	// sharing a prefix with the previous call skip re-evaluating it
+	SessionID string `json:"session_id,omitempty"`
+
+	// Template names a prompt template created with POST /api/templates. It
+	// is rendered with TemplateVars to form the prompt, in place of Prompt.
+	Template     string            `json:"template,omitempty"`
+	TemplateVars map[string]string `json:"template_vars,omitempty"`
+}
+
+// BatchGenerateRequest represents a request to generate responses to several
+// prompts at once. The system prompt and options apply to every prompt.
+type BatchGenerateRequest struct {
+	Model   string   `json:"model"`
+	Prompts []string `json:"prompts"`
+	System  string   `json:"system,omitempty"`
+	Options *Options `json:"options,omitempty"`
+}
+
+// BatchGenerateResponse holds the responses to a batch generate request, in
+// the order of its prompts
+type BatchGenerateResponse struct {
+	Model         string              `json:"model"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Responses     []*GenerateResponse `json:"responses"`
+	TotalDuration time.Duration       `json:"total_duration"`
+}
+
+// SessionDeleteRequest represents a request to delete a session
+type SessionDeleteRequest struct {
+	SessionID string `json:"session_id"`
 }
 
 // GenerateResponse represents a generate completion response
 type GenerateResponse struct {
+	Model     string         `json:"model"`
+	CreatedAt time.Time      `json:"created_at"`
+	Response  string         `json:"response"`
+	Done      bool           `json:"done"`
+	Context   []int          `json:"context,omitempty"`
+	Logprobs  []TokenLogprob `json:"logprobs,omitempty"`
+	
+	// Timing breakdown, set on the final response
+	*InferenceMetrics
+}
+
+// InferenceMetrics is the timing breakdown of a generation. Its fields are
+// those of Ollama's final response, with durations in nanoseconds.
+type InferenceMetrics struct {
+	// TotalDuration includes loading the model
+	TotalDuration      time.Duration `json:"total_duration,omitempty"`
+	LoadDuration       time.Duration `json:"load_duration,omitempty"`
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int           `json:"eval_count,omitempty"`
+	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+}
+
+// TokenLogprob holds the log probability of a generated token and, for the
+// generated token, the most likely alternatives at that position
+type TokenLogprob struct {
+	Token       string         `json:"token"`
+	Logprob     float32        `json:"logprob"`
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// LoadRequest represents a request to load a model. KVCacheType, "f16",
+// "q8_0" or "q4_0", overrides the KV cache type of the model options.
+type LoadRequest struct {
+	Model       string `json:"model"`
+	KVCacheType string `json:"kv_cache_type,omitempty"`
+}
+
+// LoadResponse represents the response for loading a model
+type LoadResponse struct {
+	Model       string `json:"model"`
+	ContextSize int    `json:"context_size"`
+	GPULayers   int    `json:"gpu_layers"`
+	KVCacheType string `json:"kv_cache_type,omitempty"`
+}
+
+// TokenizeRequest represents a tokenization request
+type TokenizeRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// TokenizeResponse represents a tokenization response
+type TokenizeResponse struct {
+	Tokens []int `json:"tokens"`
+	Count  int   `json:"count"`
+}
+
+// EmbedRequest represents an embedding request
+type EmbedRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// EmbedResponse represents an embedding response
+type EmbedResponse struct {
 	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Response  string    `json:"response"`
-	Done      bool      `json:"done"`
-	Context   []int     `json:"context,omitempty"`
+	Embedding []float32 `json:"embedding"`
 }
 
 // Options represents model options for inference
@@ -48,14 +193,61 @@ type Options struct {
 	TopK        int     `json:"top_k,omitempty"`
 	NumPredict  int     `json:"num_predict,omitempty"`
 	Stop        []string `json:"stop,omitempty"`
+	
+	// Min-p keeps the tokens with probability >= min_p * max_prob, and top-a
+	// those with probability >= top_a * max_prob^2. Like top_p, they cut off
+	// the unlikely tail of the distribution, so only one of the three should
+	// be set.
+	MinP float32 `json:"min_p,omitempty"`
+	TopA float32 `json:"top_a,omitempty"`
+	
+	// Mirostat sampling (0 = disabled, 1 = Mirostat, 2 = Mirostat 2.0).
+	// Mirostat replaces top-p/top-k filtering, so the two cannot be combined.
+	MirostatMode int     `json:"mirostat,omitempty"`
+	MirostatTau  float32 `json:"mirostat_tau,omitempty"`
+	MirostatEta  float32 `json:"mirostat_eta,omitempty"`
+	
+	// Repetition penalties applied over the last RepeatLastN generated tokens
+	RepeatPenalty    float32 `json:"repeat_penalty,omitempty"`
+	RepeatLastN      int     `json:"repeat_last_n,omitempty"`
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float32 `json:"presence_penalty,omitempty"`
+	
+	// DRY ("Don't Repeat Yourself") penalizes tokens that would repeat a
+	// sequence of at least DRYAllowedLength tokens from the last
+	// DRYPenaltyLastN generated tokens (0 = the whole response), by
+	// DRYMultiplier * DRYBase^(length - DRYAllowedLength). 0 disables it.
+	DRYMultiplier    float32 `json:"dry_multiplier,omitempty"`
+	DRYBase          float32 `json:"dry_base,omitempty"`
+	DRYAllowedLength int     `json:"dry_allowed_length,omitempty"`
+	DRYPenaltyLastN  int     `json:"dry_penalty_last_n,omitempty"`
+	
+	// Seed for the sampler's random number generator; unset or -1 picks a
+	// random seed. A pointer so that 0 remains a valid seed.
+	Seed *int64 `json:"seed,omitempty"`
+	
+	// Grammar constrains generation to a GBNF grammar. Format "json" selects
+	// the built-in JSON object grammar instead.
+	Grammar string `json:"grammar,omitempty"`
+	Format  string `json:"format,omitempty"`
+	
+	// Logprobs returns log probabilities for the top N candidates at each position
+	Logprobs int `json:"logprobs,omitempty"`
+	
+	// TokenBudget is the number of tokens the messages of a chat may use.
+	// Responses warn once they use 80% of it, and chats are refused at 95%.
+	TokenBudget int `json:"token_budget,omitempty"`
 }
 
 // ModelInfo represents information about a model
 type ModelInfo struct {
-	Name       string    `json:"name"`
-	Size       int64     `json:"size"`
-	Digest     string    `json:"digest"`
-	ModifiedAt time.Time `json:"modified_at"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	// Digest is the SHA256 of a pulled model's file, e.g. "sha256:9f86d0...",
+	// and empty for models that were not pulled
+	Digest       string    `json:"digest"`
+	ModifiedAt   time.Time `json:"modified_at"`
+	Quantization string    `json:"quantization"`
 }
 
 // ModelsResponse represents the response for listing models
@@ -63,6 +255,90 @@ type ModelsResponse struct {
 	Models []ModelInfo `json:"models"`
 }
 
+// RunningModel represents a model loaded by the server
+type RunningModel struct {
+	Name           string    `json:"name"`
+	Size           int64     `json:"size"`
+	ContextSize    int       `json:"context_size"`
+	ActiveRequests int       `json:"active_requests"`
+	QueuedRequests int       `json:"queued_requests"`
+	LoadedAt       time.Time `json:"loaded_at"`
+	LastRequest    time.Time `json:"last_request"`
+	// IdleSeconds is how long the model has had no requests in progress
+	IdleSeconds    int64     `json:"idle_seconds"`
+	// KVCacheType is the type the model's KV cache is stored in, e.g. "q8_0"
+	KVCacheType    string    `json:"kv_cache_type,omitempty"`
+	// CircuitState is "closed", "open" or "half-open"; requests to a model
+	// whose circuit is open fail with 503 until it recovers
+	CircuitState   string    `json:"circuit_state"`
+}
+
+// ProcessResponse represents the response for listing loaded models
+type ProcessResponse struct {
+	Models []RunningModel `json:"models"`
+}
+
+// SlowQuery describes a generation that took longer than the slow query
+// threshold. Durations are in nanoseconds.
+type SlowQuery struct {
+	Time            time.Time     `json:"time"`
+	RequestID       string        `json:"request_id,omitempty"`
+	Model           string        `json:"model"`
+	PromptTokens    int           `json:"prompt_tokens"`
+	ResponseTokens  int           `json:"response_tokens"`
+	TokensPerSecond float64       `json:"tokens_per_second"`
+	Threshold       time.Duration `json:"threshold"`
+	Duration        time.Duration `json:"duration"`
+}
+
+// SlowQueriesResponse represents the response for listing slow queries,
+// newest first
+type SlowQueriesResponse struct {
+	Queries []SlowQuery `json:"queries"`
+}
+
+// ModelStats is the performance of a model across the generations it served.
+// RequestCount counts the generations that produced a response, including
+// streams that failed part way, and ErrorCount the failed generations, not
+// counting those canceled by the client.
+// Durations are in nanoseconds.
+type ModelStats struct {
+	Model                  string        `json:"model"`
+	RequestCount           int64         `json:"request_count"`
+	TotalTokensGenerated   int64         `json:"total_tokens_generated"`
+	TotalPromptTokens      int64         `json:"total_prompt_tokens"`
+	TotalDuration          time.Duration `json:"total_duration"`
+	AverageTokensPerSecond float64       `json:"average_tokens_per_second"`
+	ErrorCount             int64         `json:"error_count"`
+}
+
+// StatsResponse represents the response for listing the statistics of every
+// model that served a generation, sorted by name
+type StatsResponse struct {
+	Models []ModelStats `json:"models"`
+}
+
+// PromptTemplateRequest represents a request to create a prompt template
+type PromptTemplateRequest struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+// PromptTemplate is a named prompt template with Mustache-style variables
+// such as {{user_input}}
+type PromptTemplate struct {
+	Name      string    `json:"name"`
+	Template  string    `json:"template"`
+	Variables []string  `json:"variables"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptTemplatesResponse represents the response for listing prompt
+// templates, sorted by name
+type PromptTemplatesResponse struct {
+	Templates []PromptTemplate `json:"templates"`
+}
+
 // PullRequest represents a model pull request
 type PullRequest struct {
 	Name string `json:"name"`
@@ -76,7 +352,15 @@ type PullResponse struct {
 	Completed int64  `json:"completed,omitempty"`
 }
 
+// LogEntry is a server log line, sent by GET /api/logs
+type LogEntry struct {
+	Level string `json:"level"`
+	Line  string `json:"line"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Done marks an error that ends a streamed response
+	Done bool `json:"done,omitempty"`
 }
@@ -0,0 +1,333 @@
+// Package client is a Go client of the REST API of a running Colossus server:
+//
+//	c := client.New(client.WithBaseURL("http://localhost:11434"))
+//
+//	err := c.GenerateStream(ctx, &client.GenerateRequest{
+//		Model:  "tinyllama",
+//		Prompt: "Why is the sky blue?",
+//	}, func(resp *client.GenerateResponse) error {
+//		fmt.Print(resp.Response)
+//		return nil
+//	})
+//
+// To run models inside a Go program without a server, use the
+// colossus-cli/pkg/colossus package instead.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// DefaultBaseURL is the URL of a server started with the default host and port
+const DefaultBaseURL = "http://127.0.0.1:11434"
+
+// maxLineSize is the size of the longest streamed response line accepted
+const maxLineSize = 16 * 1024 * 1024
+
+// Requests and responses are those of the server
+type (
+	GenerateRequest  = types.GenerateRequest
+	GenerateResponse = types.GenerateResponse
+	ChatRequest      = types.ChatRequest
+	ChatResponse     = types.ChatResponse
+	Message          = types.Message
+	Options          = types.Options
+	ModelInfo        = types.ModelInfo
+	PullResponse     = types.PullResponse
+)
+
+// APIError is an error returned by the server
+type APIError struct {
+	// StatusCode is the HTTP status of the response, or 200 for an error
+	// ending a streamed response
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Client sends requests to a Colossus server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+}
+
+// New creates a client of the server at DefaultBaseURL, unless configured
+// otherwise by options
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+		backoff:    time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Generate generates a response to a prompt
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	r := *req
+	r.Stream = false
+
+	var resp GenerateResponse
+	if err := c.call(ctx, http.MethodPost, "/api/generate", &r, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateStream generates a response to a prompt, passing each chunk to fn
+// as it is generated. The last chunk has Done set and carries the metrics.
+// Generation stops when fn returns an error, which is returned, or when ctx
+// is cancelled.
+func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest, fn func(*GenerateResponse) error) error {
+	r := *req
+	r.Stream = true
+
+	return c.stream(ctx, "/api/generate", &r, func(line []byte) error {
+		var resp GenerateResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return fn(&resp)
+	})
+}
+
+// Chat generates the next message of a conversation
+func (c *Client) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	r := *req
+	r.Stream = false
+
+	var resp ChatResponse
+	if err := c.call(ctx, http.MethodPost, "/api/chat", &r, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChatStream generates the next message of a conversation, passing each
+// chunk to fn as it is generated
+func (c *Client) ChatStream(ctx context.Context, req *ChatRequest, fn func(*ChatResponse) error) error {
+	r := *req
+	r.Stream = true
+
+	return c.stream(ctx, "/api/chat", &r, func(line []byte) error {
+		var resp ChatResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return fn(&resp)
+	})
+}
+
+// ListModels returns the models installed on the server
+func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var resp types.ModelsResponse
+	if err := c.call(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Models, nil
+}
+
+// PullModel downloads a model to the server, passing its progress to fn,
+// which may be nil
+func (c *Client) PullModel(ctx context.Context, name string, fn func(*PullResponse) error) error {
+	return c.stream(ctx, "/api/pull", &types.PullRequest{Name: name}, func(line []byte) error {
+		var resp PullResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("failed to decode progress: %w", err)
+		}
+		// A failed pull ends with an "error: ..." status
+		if message, ok := strings.CutPrefix(resp.Status, "error: "); ok {
+			return &APIError{StatusCode: http.StatusOK, Message: message}
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(&resp)
+	})
+}
+
+// DeleteModel removes a model from the server
+func (c *Client) DeleteModel(ctx context.Context, name string) error {
+	return c.call(ctx, http.MethodDelete, "/api/delete", &types.PullRequest{Name: name}, nil)
+}
+
+// call sends a request and decodes the JSON response into out, unless out is
+// nil
+func (c *Client) call(ctx context.Context, method, path string, body, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// stream sends a request answered with newline-delimited JSON, passing each
+// line to fn. An error line ends the stream with that error.
+func (c *Client) stream(ctx context.Context, path string, body interface{}, fn func(line []byte) error) error {
+	resp, err := c.do(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var errResp types.ErrorResponse
+		if json.Unmarshal(line, &errResp) == nil && errResp.Error != "" {
+			return &APIError{StatusCode: resp.StatusCode, Message: errResp.Error}
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request, retrying as configured by WithRetry, and returns the
+// response if it succeeded. The caller must close its body.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			err = fmt.Errorf("failed to send request: %w", err)
+		case resp.StatusCode == http.StatusOK:
+			return resp, nil
+		default:
+			err = responseError(resp)
+			wait = retryAfter(resp)
+			resp.Body.Close()
+			if !retryable(resp.StatusCode) {
+				return nil, err
+			}
+		}
+
+		if attempt >= c.maxRetries {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = c.backoff << attempt
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable reports whether a request answered with status may succeed
+// when sent again
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter returns the delay asked for by the Retry-After header of a
+// response, in seconds, or 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// responseError returns the error of a failed response, with the message of
+// its JSON error body if it has one
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var errResp types.ErrorResponse
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// IsNotFound reports whether err is a 404 from the server, e.g. for a model
+// that is not installed
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
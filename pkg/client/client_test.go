@@ -0,0 +1,362 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"colossus-cli/internal/types"
+)
+
+// newTestClient starts a server answering every request with handler and
+// returns a client of it
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return New(append([]Option{WithBaseURL(srv.URL + "/")}, opts...)...)
+}
+
+// expectRequest fails the test unless r is a method request for path whose
+// JSON body, if want is not nil, decodes to want
+func expectRequest(t *testing.T, r *http.Request, method, path string, body, want interface{}) {
+	t.Helper()
+	if r.Method != method || r.URL.Path != path {
+		t.Errorf("request = %s %s, want %s %s", r.Method, r.URL.Path, method, path)
+	}
+	if want == nil {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("request body = %+v, want %+v", body, want)
+	}
+}
+
+// writeLines writes values as newline-delimited JSON, flushing each line
+func writeLines(w http.ResponseWriter, values ...interface{}) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, v := range values {
+		json.NewEncoder(w).Encode(v)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodPost, "/api/generate", &GenerateRequest{},
+			&GenerateRequest{Model: "tinyllama", Prompt: "hello"})
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the API key", auth)
+		}
+		json.NewEncoder(w).Encode(GenerateResponse{Model: "tinyllama", Response: "hi there", Done: true})
+	}, WithAPIKey("secret"))
+
+	// The request asks for a single response even if Stream is set
+	resp, err := c.Generate(context.Background(), &GenerateRequest{Model: "tinyllama", Prompt: "hello", Stream: true})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Response != "hi there" || !resp.Done {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodPost, "/api/generate", &GenerateRequest{},
+			&GenerateRequest{Model: "tinyllama", Prompt: "hello", Stream: true})
+		writeLines(w,
+			GenerateResponse{Response: "hi"},
+			GenerateResponse{Response: " there"},
+			GenerateResponse{Done: true},
+		)
+	})
+
+	var chunks []string
+	err := c.GenerateStream(context.Background(), &GenerateRequest{Model: "tinyllama", Prompt: "hello"}, func(resp *GenerateResponse) error {
+		chunks = append(chunks, fmt.Sprintf("%q %v", resp.Response, resp.Done))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream: %v", err)
+	}
+	want := []string{`"hi" false`, `" there" false`, `"" true`}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q, want %q", chunks, want)
+	}
+}
+
+func TestChat(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "hello"}}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodPost, "/api/chat", &ChatRequest{},
+			&ChatRequest{Model: "tinyllama", Messages: messages})
+		json.NewEncoder(w).Encode(ChatResponse{Message: Message{Role: "assistant", Content: "hi"}, Done: true})
+	})
+
+	resp, err := c.Chat(context.Background(), &ChatRequest{Model: "tinyllama", Messages: messages})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Message.Content != "hi" || resp.Message.Role != "assistant" {
+		t.Errorf("message = %+v", resp.Message)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "hello"}}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodPost, "/api/chat", &ChatRequest{},
+			&ChatRequest{Model: "tinyllama", Messages: messages, Stream: true})
+		writeLines(w,
+			ChatResponse{Message: Message{Role: "assistant", Content: "hi"}},
+			ChatResponse{Message: Message{Role: "assistant", Content: "!"}},
+			ChatResponse{Done: true},
+		)
+	})
+
+	var content string
+	var done bool
+	err := c.ChatStream(context.Background(), &ChatRequest{Model: "tinyllama", Messages: messages}, func(resp *ChatResponse) error {
+		content += resp.Message.Content
+		done = resp.Done
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content != "hi!" || !done {
+		t.Errorf("streamed %q, done %v, want \"hi!\" ending with done", content, done)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodGet, "/api/tags", nil, nil)
+		json.NewEncoder(w).Encode(types.ModelsResponse{Models: []ModelInfo{
+			{Name: "tinyllama", Size: 1024, Quantization: "Q4_K_M"},
+			{Name: "phi", Size: 2048},
+		}})
+	})
+
+	models, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 2 || models[0].Name != "tinyllama" || models[0].Quantization != "Q4_K_M" || models[1].Name != "phi" {
+		t.Errorf("models = %+v", models)
+	}
+}
+
+func TestPullModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		progress   []PullResponse
+		wantStatus []string
+		wantErr    string
+	}{
+		{
+			name: "success",
+			progress: []PullResponse{
+				{Status: "downloading", Total: 100, Completed: 50},
+				{Status: "downloading", Total: 100, Completed: 100},
+				{Status: "success"},
+			},
+			wantStatus: []string{"downloading", "downloading", "success"},
+		},
+		{
+			name: "failure",
+			progress: []PullResponse{
+				{Status: "downloading", Total: 100, Completed: 50},
+				{Status: "error: disk full"},
+			},
+			wantStatus: []string{"downloading"},
+			wantErr:    "server error (200): disk full",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				expectRequest(t, r, http.MethodPost, "/api/pull", &types.PullRequest{}, &types.PullRequest{Name: "tinyllama"})
+				values := make([]interface{}, len(tt.progress))
+				for i, p := range tt.progress {
+					values[i] = p
+				}
+				writeLines(w, values...)
+			})
+
+			var statuses []string
+			err := c.PullModel(context.Background(), "tinyllama", func(resp *PullResponse) error {
+				statuses = append(statuses, resp.Status)
+				return nil
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("PullModel: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatus) {
+				t.Errorf("statuses = %q, want %q", statuses, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDeleteModel(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		expectRequest(t, r, http.MethodDelete, "/api/delete", nil, nil)
+		var req types.PullRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name != "tinyllama" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(types.ErrorResponse{Error: "model not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Model deleted successfully"})
+	})
+
+	if err := c.DeleteModel(context.Background(), "tinyllama"); err != nil {
+		t.Errorf("DeleteModel: %v", err)
+	}
+
+	err := c.DeleteModel(context.Background(), "phi")
+	if !IsNotFound(err) {
+		t.Errorf("error = %v, want a 404", err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Message != "model not found" {
+		t.Errorf("message = %q, want the server's error", apiErr.Message)
+	}
+}
+
+func TestStreamErrorLine(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeLines(w,
+			GenerateResponse{Response: "hi"},
+			types.ErrorResponse{Error: "request timed out", Done: true},
+		)
+	})
+
+	var chunks int
+	err := c.GenerateStream(context.Background(), &GenerateRequest{Model: "tinyllama"}, func(*GenerateResponse) error {
+		chunks++
+		return nil
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "request timed out" {
+		t.Errorf("error = %v, want the streamed error", err)
+	}
+	if chunks != 1 {
+		t.Errorf("got %d chunks before the error, want 1", chunks)
+	}
+}
+
+func TestStreamCancelled(t *testing.T) {
+	// The server streams until the client goes away
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		for {
+			writeLines(w, GenerateResponse{Response: "more"})
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var chunks int
+	err := c.GenerateStream(ctx, &GenerateRequest{Model: "tinyllama"}, func(*GenerateResponse) error {
+		chunks++
+		if chunks == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+
+	// A callback error stops the stream and is returned
+	stop := errors.New("stop")
+	err = c.GenerateStream(context.Background(), &GenerateRequest{Model: "tinyllama"}, func(*GenerateResponse) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("error = %v, want the callback's error", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		failures     int32
+		maxRetries   int
+		wantRequests int32
+		wantErr      bool
+	}{
+		{name: "not retried by default", status: http.StatusServiceUnavailable, failures: 1, wantRequests: 1, wantErr: true},
+		{name: "recovers", status: http.StatusServiceUnavailable, failures: 2, maxRetries: 3, wantRequests: 3},
+		{name: "queue full", status: http.StatusTooManyRequests, failures: 1, maxRetries: 1, wantRequests: 2},
+		{name: "retries used up", status: http.StatusBadGateway, failures: 5, maxRetries: 2, wantRequests: 3, wantErr: true},
+		{name: "client errors", status: http.StatusBadRequest, failures: 1, maxRetries: 3, wantRequests: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					json.NewEncoder(w).Encode(types.ErrorResponse{Error: "busy"})
+					return
+				}
+				json.NewEncoder(w).Encode(types.ModelsResponse{})
+			}, WithRetry(tt.maxRetries, time.Millisecond))
+
+			_, err := c.ListModels(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if tt.wantErr && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("error = %v, want a %d", err, tt.status)
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}, WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := c.ListModels(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ListModels returned after %v, want the 50ms timeout", elapsed)
+	}
+}
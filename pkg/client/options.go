@@ -0,0 +1,54 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL sets the URL of the server, DefaultBaseURL by default
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(url, "/")
+	}
+}
+
+// WithAPIKey sets the API key sent as a Bearer token, for servers started
+// with --api-key or --api-keys-file
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTimeout bounds how long requests that are not streamed may take,
+// retries included. Streamed requests, which last as long as the
+// generation or download, are only bounded by their context. The default,
+// 0, sets no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry retries requests up to maxRetries times when the server cannot be
+// reached or answers 429, 502, 503 or 504, e.g. when the queue of a model is
+// full. Retries wait for the Retry-After the server asks for, or for backoff
+// doubled after each attempt. Requests are not retried by default.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with,
+// http.DefaultClient by default
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
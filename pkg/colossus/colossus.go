@@ -0,0 +1,185 @@
+// Package colossus runs models in the calling program, without the Colossus
+// HTTP server. A Client loads GGUF model files and generates with them by
+// calling the inference engine directly:
+//
+//	client, err := colossus.New(nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	if err := client.LoadModel("tinyllama", "/models/tinyllama.gguf", nil); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	resp, err := client.Generate(context.Background(), &colossus.GenerateRequest{
+//		Model:  "tinyllama",
+//		Prompt: "Why is the sky blue?",
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(resp.Response)
+//
+// Real inference needs a binary built with the llama.cpp bindings (cgo and
+// the llamacpp_cgo build tag). The simulated engine, selected with
+// Config.Engine, answers without a model file and suits tests of programs
+// embedding Colossus.
+package colossus
+
+import (
+	"context"
+	"fmt"
+
+	"colossus-cli/internal/inference"
+	"colossus-cli/internal/types"
+)
+
+// Requests, responses and options are those of the HTTP API
+type (
+	GenerateRequest  = types.GenerateRequest
+	GenerateResponse = types.GenerateResponse
+	ChatRequest      = types.ChatRequest
+	ChatResponse     = types.ChatResponse
+	Message          = types.Message
+	Options          = types.Options
+
+	ModelOptions            = inference.ModelOptions
+	ModelInfo               = inference.ModelInfo
+	LoRAAdapter             = inference.LoRAAdapter
+	ContextOverflowStrategy = inference.ContextOverflowStrategy
+)
+
+// How prompts longer than the context of a model are handled
+const (
+	ErrorOnOverflow = inference.ErrorOnOverflow
+	TruncateLeft    = inference.TruncateLeft
+	TruncateRight   = inference.TruncateRight
+)
+
+// Inference engines
+const (
+	// EngineLlamaCpp runs models with llama.cpp
+	EngineLlamaCpp = string(inference.EngineTypeLlamaCpp)
+
+	// EngineSimulated returns canned responses without running a model,
+	// for testing only
+	EngineSimulated = string(inference.EngineTypeSimulated)
+)
+
+// Config configures a Client
+type Config struct {
+	// Engine is the inference engine, EngineLlamaCpp (the default) or
+	// EngineSimulated
+	Engine string
+}
+
+// Client loads models and generates with them in-process. It is safe for
+// concurrent use.
+type Client struct {
+	engineType inference.EngineType
+	engine     inference.InferenceEngine
+}
+
+// New creates a client. A nil config uses the defaults.
+func New(config *Config) (*Client, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	engineType := inference.EngineTypeLlamaCpp
+	switch config.Engine {
+	case "", EngineLlamaCpp:
+	case EngineSimulated:
+		engineType = inference.EngineTypeSimulated
+	default:
+		return nil, fmt.Errorf("unknown engine %q: must be %s or %s", config.Engine, EngineLlamaCpp, EngineSimulated)
+	}
+
+	return &Client{
+		engineType: engineType,
+		engine:     inference.NewEngine(engineType),
+	}, nil
+}
+
+// DefaultModelOptions returns the options LoadModel uses when none are
+// given, with GPU offloading configured for the GPUs found
+func (c *Client) DefaultModelOptions() *ModelOptions {
+	return inference.GetDefaultModelOptions(c.engineType)
+}
+
+// LoadModel loads the model file at path under name, the model name of
+// requests to it. Nil options use DefaultModelOptions.
+func (c *Client) LoadModel(name, path string, opts *ModelOptions) error {
+	if name == "" {
+		return fmt.Errorf("model name is required")
+	}
+	if opts == nil {
+		opts = c.DefaultModelOptions()
+	}
+	if err := c.engine.LoadModel(name, path, opts); err != nil {
+		return fmt.Errorf("failed to load model %s: %w", name, err)
+	}
+	return nil
+}
+
+// UnloadModel frees a loaded model
+func (c *Client) UnloadModel(name string) error {
+	return c.engine.UnloadModel(name)
+}
+
+// LoadedModels returns information about the loaded models
+func (c *Client) LoadedModels() []*ModelInfo {
+	return c.engine.LoadedModels()
+}
+
+// Generate generates a response to a prompt with a loaded model. req.Stream
+// is ignored; use GenerateStream to receive the response as it is generated.
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	if err := c.checkModel(req.Model); err != nil {
+		return nil, err
+	}
+	return c.engine.Generate(ctx, req)
+}
+
+// GenerateStream generates a response to a prompt, passing each chunk to
+// callback as it is generated. Generation stops when callback returns an
+// error or ctx is cancelled.
+func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest, callback func(*GenerateResponse) error) error {
+	if err := c.checkModel(req.Model); err != nil {
+		return err
+	}
+	return c.engine.GenerateStream(ctx, req, callback)
+}
+
+// Chat generates the next message of a conversation with a loaded model.
+// req.Stream is ignored; use ChatStream to receive the message as it is
+// generated.
+func (c *Client) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if err := c.checkModel(req.Model); err != nil {
+		return nil, err
+	}
+	return c.engine.Chat(ctx, req)
+}
+
+// ChatStream generates the next message of a conversation, passing each
+// chunk to callback as it is generated
+func (c *Client) ChatStream(ctx context.Context, req *ChatRequest, callback func(*ChatResponse) error) error {
+	if err := c.checkModel(req.Model); err != nil {
+		return err
+	}
+	return c.engine.ChatStream(ctx, req, callback)
+}
+
+// Close unloads all models and shuts down the inference engine
+func (c *Client) Close() error {
+	return c.engine.Shutdown()
+}
+
+// checkModel returns an error unless a model is loaded
+func (c *Client) checkModel(name string) error {
+	if !c.engine.IsModelLoaded(name) {
+		return fmt.Errorf("model not loaded: %s", name)
+	}
+	return nil
+}
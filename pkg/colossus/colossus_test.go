@@ -0,0 +1,39 @@
+package colossus
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClientRequiresLoadedModel(t *testing.T) {
+	client, err := New(&Config{Engine: EngineSimulated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.LoadModel("", "/models/tinyllama.gguf", nil); err == nil {
+		t.Error("LoadModel succeeded without a model name")
+	}
+
+	ctx := context.Background()
+	if _, err := client.Generate(ctx, &GenerateRequest{Model: "tinyllama", Prompt: "Hello"}); err == nil {
+		t.Error("Generate succeeded with no model loaded")
+	}
+	if _, err := client.Chat(ctx, &ChatRequest{Model: "tinyllama"}); err == nil {
+		t.Error("Chat succeeded with no model loaded")
+	}
+
+	if err := client.LoadModel("tinyllama", "/models/tinyllama.gguf", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UnloadModel("tinyllama"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Generate(ctx, &GenerateRequest{Model: "tinyllama", Prompt: "Hello"}); err == nil {
+		t.Error("Generate succeeded after the model was unloaded")
+	}
+	if models := client.LoadedModels(); len(models) != 0 {
+		t.Errorf("%d models loaded after unloading, want none", len(models))
+	}
+}
@@ -0,0 +1,102 @@
+package colossus_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"colossus-cli/pkg/colossus"
+)
+
+// The simulated engine answers without a model file, so the examples run
+// anywhere. Programs running real models leave Config.Engine unset.
+func newClient() *colossus.Client {
+	client, err := colossus.New(&colossus.Config{Engine: colossus.EngineSimulated})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := client.LoadModel("tinyllama", "/models/tinyllama.gguf", nil); err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+func ExampleClient_Generate() {
+	client := newClient()
+	defer client.Close()
+
+	resp, err := client.Generate(context.Background(), &colossus.GenerateRequest{
+		Model:  "tinyllama",
+		Prompt: "Hello!",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(resp.Done, resp.Response != "")
+	// Output: true true
+}
+
+func ExampleClient_GenerateStream() {
+	client := newClient()
+	defer client.Close()
+
+	var response strings.Builder
+	err := client.GenerateStream(context.Background(), &colossus.GenerateRequest{
+		Model:  "tinyllama",
+		Prompt: "Hello!",
+	}, func(chunk *colossus.GenerateResponse) error {
+		response.WriteString(chunk.Response)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(response.Len() > 0)
+	// Output: true
+}
+
+func ExampleClient_Chat() {
+	client := newClient()
+	defer client.Close()
+
+	resp, err := client.Chat(context.Background(), &colossus.ChatRequest{
+		Model: "tinyllama",
+		Messages: []colossus.Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "Hello!"},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(resp.Message.Role)
+	// Output: assistant
+}
+
+func ExampleClient_LoadModel() {
+	client, err := colossus.New(&colossus.Config{Engine: colossus.EngineSimulated})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// Offload every layer to the GPU with a larger context than the default
+	opts := client.DefaultModelOptions()
+	opts.ContextSize = 8192
+	opts.GPULayers = -1
+	if err := client.LoadModel("tinyllama", "/models/tinyllama.gguf", opts); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, model := range client.LoadedModels() {
+		fmt.Println(model.Name, model.ContextSize)
+	}
+	// Output: tinyllama 8192
+}
+
+func ExampleNew() {
+	_, err := colossus.New(&colossus.Config{Engine: "onnx"})
+	fmt.Println(err)
+	// Output: unknown engine "onnx": must be llamacpp or simulated
+}